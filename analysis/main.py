@@ -87,6 +87,8 @@ class CameraTask:
         self.frames_processed = 0
         self.retry_count = 0
         self.last_error = ""
+        # owl 事件入库积压时要求暂停推送检测事件，到期前丢弃检测结果
+        self.paused_until = 0.0
         self._stop_event = threading.Event()
         self._thread: threading.Thread | None = None
 
@@ -147,6 +149,9 @@ class CameraTask:
                 if not has_motion:
                     continue
 
+                if time.monotonic() < self.paused_until:
+                    continue
+
                 try:
                     labels = self.config.get("labels")
                     if labels and isinstance(labels, list):
@@ -223,7 +228,17 @@ class CameraTask:
             "snapshot_height": frame.shape[0],
         }
 
-        send_callback(self.config, "/events", payload)
+        send_callback(self.config, "/events", payload, self._on_events_response)
+
+    def _on_events_response(self, body: dict):
+        """处理检测事件回调的响应，owl 要求减速时暂停推送"""
+        retry_after = backpressure_delay(body)
+        if retry_after <= 0:
+            return
+        self.paused_until = max(self.paused_until, time.monotonic() + retry_after)
+        slog.warning(
+            f"CameraTask {self.camera_id} owl 事件积压，暂停推送 {retry_after:.1f}s"
+        )
 
     def _send_stopped_callback(self, reason, message):
         payload = {
@@ -364,11 +379,37 @@ class AnalysisServiceServicer(analysis_pb2_grpc.AnalysisServiceServicer):
         return response
 
 
-def send_callback(config: dict, path: str, payload: dict):
+def backpressure_delay(body: Any) -> float:
+    """
+    解析回调响应中的背压建议，返回建议暂停推送的秒数，无需减速时返回 0。
+    """
+    if not isinstance(body, dict):
+        return 0.0
+    bp = body.get("backpressure")
+    if not isinstance(bp, dict) or not bp.get("slow_down"):
+        return 0.0
+    try:
+        return max(float(bp.get("retry_after_ms", 0)), 0.0) / 1000
+    except (TypeError, ValueError):
+        return 0.0
+
+
+def _post_callback(full_url: str, payload: dict, headers: dict, on_response):
+    resp = requests.post(full_url, json=payload, headers=headers, timeout=5.0)
+    if on_response is None or not resp.ok:
+        return
+    try:
+        on_response(resp.json())
+    except ValueError:
+        pass
+
+
+def send_callback(config: dict, path: str, payload: dict, on_response=None):
     """
     发送回调到指定路径，路径会拼接到 callback_url 后面。
     例如: callback_url=http://127.0.0.1:15123, path=/events
     最终请求: POST http://127.0.0.1:15123/events
+    on_response 非空时以响应 JSON 回调，用于处理背压等反馈
     """
     url = config.get("callback_url", "")
     secret = config.get("callback_secret", "")
@@ -382,13 +423,8 @@ def send_callback(config: dict, path: str, payload: dict):
 
     try:
         threading.Thread(
-            target=requests.post,
-            args=(full_url,),
-            kwargs={
-                "json": payload,
-                "headers": headers,
-                "timeout": 5.0,
-            },
+            target=_post_callback,
+            args=(full_url, payload, headers, on_response),
         ).start()
     except Exception as e:
         slog.error(f"Failed to send callback to {path}: {e}")
@@ -2,13 +2,27 @@ package gbadapter
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/gowvp/owl/internal/core/ipc"
 	"github.com/gowvp/owl/internal/core/sms"
 	"github.com/gowvp/owl/pkg/gbs"
+	"github.com/gowvp/owl/pkg/zlm"
 )
 
-var _ ipc.Protocoler = (*Adapter)(nil)
+var (
+	_ ipc.Protocoler            = (*Adapter)(nil)
+	_ ipc.OnlineSnapshoter      = (*Adapter)(nil)
+	_ ipc.PTZController         = (*Adapter)(nil)
+	_ ipc.PTZPositioner         = (*Adapter)(nil)
+	_ ipc.PTZAbsoluteController = (*Adapter)(nil)
+	_ ipc.PTZCommander          = (*Adapter)(nil)
+	_ ipc.PresetQueryer         = (*Adapter)(nil)
+	_ ipc.KeepAliver            = (*Adapter)(nil)
+)
 
 type Adapter struct {
 	adapter ipc.Adapter
@@ -17,7 +31,21 @@ type Adapter struct {
 }
 
 // DeleteDevice implements ipc.Protocoler.
+// 删除前结束设备下所有点播并关闭 RTP 服务，避免媒体服务器残留孤儿流
 func (a *Adapter) DeleteDevice(ctx context.Context, device *ipc.Device) error {
+	streams := a.gbs.StopDevicePlay(device.GetGB28181DeviceID())
+	if len(streams) == 0 {
+		return nil
+	}
+	svr, err := a.smsCore.GetMediaServer(ctx, sms.DefaultMediaServerID)
+	if err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		if _, err := a.smsCore.CloseRTPServer(svr, zlm.CloseRTPServerRequest{StreamID: stream}); err != nil {
+			slog.WarnContext(ctx, "关闭 RTP 服务失败", "device_id", device.ID, "stream", stream, "err", err)
+		}
+	}
 	return nil
 }
 
@@ -62,16 +90,35 @@ func (a *Adapter) OnStreamNotFound(ctx context.Context, app string, stream strin
 		return err
 	}
 
-	return a.gbs.Play(&gbs.PlayInput{
-		Channel:    ch,
-		StreamMode: dev.StreamMode,
-		SMS:        svr,
-	})
+	return toIPCError(a.gbs.Play(&gbs.PlayInput{
+		Channel:      ch,
+		StreamMode:   dev.StreamMode,
+		SMS:          svr,
+		MediaFormats: dev.Ext.SDPFormats,
+		SSRCPrefix:   dev.Ext.SSRCPrefix,
+		SSRCFormat:   dev.Ext.SSRCFormat,
+		StreamNumber: a.gbs.StreamNumber(ch.ID),
+	}))
 }
 
 // QueryCatalog implements ipc.Protocoler.
 func (a *Adapter) QueryCatalog(ctx context.Context, device *ipc.Device) error {
-	return a.gbs.QueryCatalog(device.DeviceID)
+	return toIPCError(a.gbs.QueryCatalog(device.DeviceID))
+}
+
+// toIPCError 将国标信令错误转换为带错误码的领域错误，未识别的错误原样返回
+func toIPCError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gbs.ErrDeviceOffline), errors.Is(err, gbs.ErrRegisterRequired), errors.Is(err, gbs.ErrChannelOffline):
+		return ipc.ErrDeviceOffline.With(err.Error())
+	case errors.Is(err, gbs.ErrDeviceNotExist):
+		return ipc.ErrDeviceNotFound.With(err.Error())
+	case errors.Is(err, gbs.ErrChannelNotExist):
+		return ipc.ErrChannelNotFound.With(err.Error())
+	}
+	return err
 }
 
 // StartPlay implements ipc.Protocoler.
@@ -88,3 +135,95 @@ func (a *Adapter) StopPlay(ctx context.Context, device *ipc.Device, channel *ipc
 func (a *Adapter) ValidateDevice(ctx context.Context, device *ipc.Device) error {
 	return nil
 }
+
+// ShouldKeepAlive implements ipc.KeepAliver.
+// 国标为按需点播，仅持续录像或 AI 分析的通道保持，其余无人观看即关闭以释放设备推流
+func (a *Adapter) ShouldKeepAlive(_ context.Context, ch *ipc.Channel) bool {
+	return ch.Ext.IsStreamKeepAlive()
+}
+
+// OnlineSnapshot implements ipc.OnlineSnapshoter.
+func (a *Adapter) OnlineSnapshot() map[string]bool {
+	return a.gbs.OnlineSnapshot()
+}
+
+// PTZControl implements ipc.PTZController.
+func (a *Adapter) PTZControl(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.PTZControlInput) error {
+	return toIPCError(a.gbs.PTZControl(ctx, &gbs.PTZControlInput{
+		Channel:   channel,
+		Direction: in.Direction,
+		Speed:     in.Speed,
+	}))
+}
+
+// PTZPosition implements ipc.PTZPositioner.
+func (a *Adapter) PTZPosition(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.PTZPositionInput) error {
+	return toIPCError(a.gbs.PTZPosition(ctx, &gbs.PTZPositionInput{
+		Channel: channel,
+		X:       in.X,
+		Y:       in.Y,
+		Zoom:    in.Zoom,
+	}))
+}
+
+// PTZFIControl implements ipc.PTZController.
+func (a *Adapter) PTZFIControl(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.PTZFIInput) error {
+	return toIPCError(a.gbs.PTZFIControl(ctx, &gbs.PTZFIInput{
+		Channel: channel,
+		Focus:   in.Focus,
+		Iris:    in.Iris,
+	}))
+}
+
+// PTZCommand implements ipc.PTZCommander.
+func (a *Adapter) PTZCommand(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.PTZCommandInput) error {
+	return toIPCError(a.gbs.PTZCommand(ctx, &gbs.PTZCommandInput{
+		Channel:         channel,
+		PTZCommandInput: *in,
+	}))
+}
+
+// PTZAbsoluteControl implements ipc.PTZAbsoluteController.
+func (a *Adapter) PTZAbsoluteControl(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.PTZAbsoluteInput) error {
+	err := a.gbs.PTZAbsoluteControl(ctx, &gbs.PTZAbsoluteInput{
+		Channel: channel,
+		Pan:     in.Pan,
+		Tilt:    in.Tilt,
+		Zoom:    in.Zoom,
+	})
+	if errors.Is(err, gbs.ErrControlFailed) {
+		return ipc.ErrPTZControlFailed.With(err.Error())
+	}
+	return toIPCError(err)
+}
+
+// ResetAlarm implements ipc.AlarmResetter.
+func (a *Adapter) ResetAlarm(ctx context.Context, _ *ipc.Device, channel *ipc.Channel, in *ipc.ResetAlarmInput) error {
+	err := a.gbs.ResetAlarm(ctx, &gbs.ResetAlarmInput{
+		Channel:     channel,
+		AlarmMethod: in.AlarmMethod,
+		AlarmType:   in.AlarmType,
+	})
+	if errors.Is(err, gbs.ErrControlTimeout) || errors.Is(err, gbs.ErrControlFailed) {
+		return ipc.ErrAlarmResetFailed.With(err.Error())
+	}
+	return toIPCError(err)
+}
+
+// QueryPreset implements ipc.PresetQueryer.
+// 国标预置位编号为数字字符串，无法解析的条目忽略
+func (a *Adapter) QueryPreset(ctx context.Context, _ *ipc.Device, channel *ipc.Channel) ([]ipc.PresetItem, error) {
+	items, err := a.gbs.QueryPreset(ctx, channel)
+	if err != nil {
+		return nil, toIPCError(err)
+	}
+	out := make([]ipc.PresetItem, 0, len(items))
+	for _, item := range items {
+		id, err := strconv.Atoi(strings.TrimSpace(item.PresetID))
+		if err != nil {
+			continue
+		}
+		out = append(out, ipc.PresetItem{PresetID: id, DeviceName: item.PresetName})
+	}
+	return out, nil
+}
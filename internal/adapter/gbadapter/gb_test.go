@@ -0,0 +1,61 @@
+package gbadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+func TestToIPCError(t *testing.T) {
+	other := errors.New("timeout")
+	cases := []struct {
+		err    error
+		expect error
+	}{
+		{err: gbs.ErrDeviceOffline, expect: ipc.ErrDeviceOffline},
+		{err: fmt.Errorf("play: %w", gbs.ErrRegisterRequired), expect: ipc.ErrDeviceOffline},
+		{err: gbs.ErrChannelOffline, expect: ipc.ErrDeviceOffline},
+		{err: gbs.ErrDeviceNotExist, expect: ipc.ErrDeviceNotFound},
+		{err: gbs.ErrChannelNotExist, expect: ipc.ErrChannelNotFound},
+	}
+	for _, tc := range cases {
+		got := toIPCError(tc.err)
+		var e *reason.Error
+		if !errors.As(got, &e) || !errors.Is(got, tc.expect) {
+			t.Fatalf("%v expect reason %v, got %v", tc.err, tc.expect, got)
+		}
+	}
+	if got := toIPCError(other); got != other {
+		t.Fatalf("unknown error should pass through, got %v", got)
+	}
+	if toIPCError(nil) != nil {
+		t.Fatal("nil should stay nil")
+	}
+}
+
+func TestShouldKeepAlive(t *testing.T) {
+	var a Adapter
+	off := false
+	cases := []struct {
+		ext  ipc.DeviceExt
+		keep bool
+	}{
+		{ext: ipc.DeviceExt{}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "always"}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "none", EnabledAI: true}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "ai"}, keep: false},
+		{ext: ipc.DeviceExt{RecordMode: "none"}, keep: false},
+		{ext: ipc.DeviceExt{RecordMode: "always", EnabledRecording: &off}, keep: false},
+	}
+	for _, tc := range cases {
+		ch := ipc.Channel{Type: ipc.TypeGB28181, Ext: tc.ext}
+		if got := ipc.ShouldKeepAlive(context.Background(), &a, &ch); got != tc.keep {
+			t.Fatalf("ext %+v expect keep %v, got %v", tc.ext, tc.keep, got)
+		}
+	}
+}
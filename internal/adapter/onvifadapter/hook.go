@@ -10,6 +10,14 @@ import (
 	"github.com/ixugo/goddd/pkg/orm"
 )
 
+var _ ipc.KeepAliver = (*Adapter)(nil)
+
+// ShouldKeepAlive implements ipc.KeepAliver.
+// 与拉流代理的 auto_close 保持一致
+func (a *Adapter) ShouldKeepAlive(_ context.Context, ch *ipc.Channel) bool {
+	return ch.Ext.IsStreamKeepAlive()
+}
+
 // OnStreamChanged implements ipc.Protocoler.
 // ONVIF 协议的 stream 就是 channel.ID，app 固定为 live
 func (a *Adapter) OnStreamChanged(ctx context.Context, app, stream string) error {
@@ -47,6 +55,8 @@ func (a *Adapter) OnStreamNotFound(ctx context.Context, app, stream string) erro
 		App:    app,
 		Stream: stream,
 		URL:    streamURI,
+		// 持续录像或 AI 分析的通道无人观看时也保持拉流
+		AutoClose: new(!ch.Ext.IsStreamKeepAlive()),
 	})
 	if err == nil {
 		if err := a.adapter.EditPlaying(ctx, ch.DeviceID, ch.ChannelID, true); err != nil {
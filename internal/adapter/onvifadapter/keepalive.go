@@ -91,7 +91,9 @@ func (a *Adapter) startStatusChecker(ctx context.Context, interval, heartbeatTim
 // syncDeviceStatusToDB 同步设备状态到数据库（状态变化时调用）
 func (a *Adapter) syncDeviceStatusToDB(ctx context.Context, did string, isOnline bool) {
 	// 更新设备状态
+	var id string
 	if err := a.adapter.Edit(did, func(d *ipc.Device) {
+		id = d.ID
 		d.IsOnline = isOnline
 		if isOnline {
 			d.KeepaliveAt = orm.Now()
@@ -100,4 +102,10 @@ func (a *Adapter) syncDeviceStatusToDB(ctx context.Context, did string, isOnline
 		slog.ErrorContext(ctx, "更新设备在线状态失败", "err", err, "device_id", did)
 		return
 	}
+
+	why := ipc.DeviceStateKeepalive
+	if !isOnline {
+		why = ipc.DeviceStateKeepaliveTimeout
+	}
+	a.adapter.RecordDeviceState(ctx, id, isOnline, why)
 }
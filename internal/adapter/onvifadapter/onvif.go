@@ -22,7 +22,10 @@ import (
 	"github.com/ixugo/goddd/pkg/orm"
 )
 
-var _ ipc.Protocoler = (*Adapter)(nil)
+var (
+	_ ipc.Protocoler       = (*Adapter)(nil)
+	_ ipc.OnlineSnapshoter = (*Adapter)(nil)
+)
 
 // Adapter ONVIF 协议适配器
 //
@@ -290,3 +293,13 @@ func (a *Adapter) Discover(ctx context.Context, w io.Writer) error {
 		}
 	}
 }
+
+// OnlineSnapshot implements ipc.OnlineSnapshoter.
+func (a *Adapter) OnlineSnapshot() map[string]bool {
+	out := make(map[string]bool, 16)
+	a.devices.Range(func(key string, dev *Device) bool {
+		out[key] = dev.IsOnline
+		return true
+	})
+	return out
+}
@@ -0,0 +1,78 @@
+package onvifadapter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+const soapEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope" xmlns:tds="http://www.onvif.org/ver10/device/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+<SOAP-ENV:Body>%s</SOAP-ENV:Body>
+</SOAP-ENV:Envelope>`
+
+// newONVIFServer 模拟设备，仅响应 GetCapabilities 与 GetDeviceInformation，用户名不是 admin 时鉴权失败
+func newONVIFServer(t *testing.T) (string, int) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body := string(b)
+		w.Header().Set("Content-Type", "application/soap+xml")
+		switch {
+		case strings.Contains(body, "GetCapabilities"):
+			_, _ = io.WriteString(w, strings.Replace(soapEnvelope, "%s", `<tds:GetCapabilitiesResponse><tds:Capabilities></tds:Capabilities></tds:GetCapabilitiesResponse>`, 1))
+		case !strings.Contains(body, ">admin<"):
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, strings.Replace(soapEnvelope, "%s", `<SOAP-ENV:Fault><SOAP-ENV:Reason><SOAP-ENV:Text>Sender not Authorized</SOAP-ENV:Text></SOAP-ENV:Reason></SOAP-ENV:Fault>`, 1))
+		default:
+			_, _ = io.WriteString(w, strings.Replace(soapEnvelope, "%s", `<tds:GetDeviceInformationResponse><tds:Manufacturer>HIK</tds:Manufacturer><tds:Model>DS-2CD</tds:Model><tds:FirmwareVersion>V5.7</tds:FirmwareVersion><tds:SerialNumber>1</tds:SerialNumber><tds:HardwareId>1</tds:HardwareId></tds:GetDeviceInformationResponse>`, 1))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func TestValidateDevice(t *testing.T) {
+	host, port := newONVIFServer(t)
+	a := Adapter{client: http.DefaultClient}
+
+	dev := ipc.Device{IP: host, Port: port, Username: "admin", Password: "123456"}
+	if err := a.ValidateDevice(t.Context(), &dev); err != nil {
+		t.Fatal(err)
+	}
+	if dev.Ext.Manufacturer != "HIK" || dev.Ext.Model != "DS-2CD" || dev.Ext.Firmware != "V5.7" || !dev.IsOnline {
+		t.Fatalf("device info not filled: %+v", dev)
+	}
+
+	bad := ipc.Device{IP: host, Port: port, Username: "guest", Password: "123456"}
+	if err := a.ValidateDevice(t.Context(), &bad); err == nil {
+		t.Fatal("expect error for wrong credential")
+	}
+}
+
+func TestShouldKeepAlive(t *testing.T) {
+	var a Adapter
+	cases := []struct {
+		ext  ipc.DeviceExt
+		keep bool
+	}{
+		{ext: ipc.DeviceExt{RecordMode: "always"}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "none", EnabledAI: true}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "ai"}, keep: false},
+		{ext: ipc.DeviceExt{RecordMode: "none"}, keep: false},
+	}
+	for _, tc := range cases {
+		ch := ipc.Channel{Type: ipc.TypeOnvif, Ext: tc.ext}
+		if got := ipc.ShouldKeepAlive(context.Background(), &a, &ch); got != tc.keep {
+			t.Fatalf("ext %+v expect keep %v, got %v", tc.ext, tc.keep, got)
+		}
+	}
+}
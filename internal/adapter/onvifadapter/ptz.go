@@ -0,0 +1,72 @@
+package onvifadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gowvp/onvif/ptz"
+	sdkptz "github.com/gowvp/onvif/sdk/ptz"
+	xsdonvif "github.com/gowvp/onvif/xsd/onvif"
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+var _ ipc.PTZPositioner = (*Adapter)(nil)
+
+// ONVIF 通用坐标空间水平 [-1,1] 对应 360°，垂直 [-1,1] 对应 180°，变倍 [0,1] 对应最小到最大倍率
+// 设备不上报视场角，按常见 60°x34° 广角视场与 30 倍光学变倍估算画面对应的位置范围
+const (
+	onvifHalfFOVPan  = 60.0 / 360
+	onvifHalfFOVTilt = 34.0 / 180
+	onvifZoomRange   = 30.0
+)
+
+// PTZPosition implements ipc.PTZPositioner.
+// 读取当前位置后计算目标绝对位置，使用 AbsoluteMove 完成点击居中
+func (a *Adapter) PTZPosition(ctx context.Context, _ *ipc.Device, ch *ipc.Channel, in *ipc.PTZPositionInput) error {
+	dev, ok := a.devices.Load(ch.DeviceID)
+	if !ok || !dev.IsOnline {
+		return ipc.ErrDeviceOffline
+	}
+	token := xsdonvif.ReferenceToken(ch.ChannelID)
+	status, err := sdkptz.Call_GetStatus(ctx, dev.Device, ptz.GetStatus{ProfileToken: token})
+	if err != nil {
+		return ipc.ErrPTZNotSupported.With(fmt.Sprintf("get ptz status: %s", err))
+	}
+
+	_, err = sdkptz.Call_AbsoluteMove(ctx, dev.Device, ptz.AbsoluteMove{
+		ProfileToken: token,
+		Position:     absolutePosition(status.PTZStatus.Position, in),
+		Speed: xsdonvif.PTZSpeed{
+			PanTilt: xsdonvif.Vector2D{X: 1, Y: 1},
+			Zoom:    xsdonvif.Vector1D{X: 1},
+		},
+	})
+	return err
+}
+
+// absolutePosition 将画面归一化坐标换算为通用坐标空间中的目标位置
+// 当前变倍越大视场越小，同样的画面偏移对应的转动角度越小
+func absolutePosition(cur xsdonvif.PTZVector, in *ipc.PTZPositionInput) xsdonvif.PTZVector {
+	magnification := 1 + clamp(cur.Zoom.X, 0, 1)*(onvifZoomRange-1)
+	dx := (in.X - 0.5) * 2 * onvifHalfFOVPan / magnification
+	dy := (0.5 - in.Y) * 2 * onvifHalfFOVTilt / magnification
+
+	pan := cur.PanTilt.X + dx
+	// 水平方向可连续旋转，越界时回绕
+	if pan > 1 {
+		pan -= 2
+	} else if pan < -1 {
+		pan += 2
+	}
+	zoom := (magnification*in.ZoomRatio() - 1) / (onvifZoomRange - 1)
+
+	var out xsdonvif.PTZVector
+	out.PanTilt.X = pan
+	out.PanTilt.Y = clamp(cur.PanTilt.Y+dy, -1, 1)
+	out.Zoom.X = clamp(zoom, 0, 1)
+	return out
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return min(max(v, lo), hi)
+}
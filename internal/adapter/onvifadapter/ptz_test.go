@@ -0,0 +1,41 @@
+package onvifadapter
+
+import (
+	"math"
+	"testing"
+
+	xsdonvif "github.com/gowvp/onvif/xsd/onvif"
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+func TestAbsolutePosition(t *testing.T) {
+	vector := func(pan, tilt, zoom float64) xsdonvif.PTZVector {
+		var v xsdonvif.PTZVector
+		v.PanTilt.X, v.PanTilt.Y, v.Zoom.X = pan, tilt, zoom
+		return v
+	}
+	tests := []struct {
+		name   string
+		cur    xsdonvif.PTZVector
+		in     ipc.PTZPositionInput
+		expect xsdonvif.PTZVector
+	}{
+		{name: "center keeps position", cur: vector(0.2, -0.1, 0), in: ipc.PTZPositionInput{X: 0.5, Y: 0.5}, expect: vector(0.2, -0.1, 0)},
+		{name: "right edge at wide", cur: vector(0, 0, 0), in: ipc.PTZPositionInput{X: 1, Y: 0.5}, expect: vector(onvifHalfFOVPan, 0, 0)},
+		{name: "top edge at wide", cur: vector(0, 0, 0), in: ipc.PTZPositionInput{X: 0.5, Y: 0}, expect: vector(0, onvifHalfFOVTilt, 0)},
+		{name: "offset shrinks when zoomed", cur: vector(0, 0, 1), in: ipc.PTZPositionInput{X: 1, Y: 0.5}, expect: vector(onvifHalfFOVPan/onvifZoomRange, 0, 1)},
+		{name: "pan wraps around", cur: vector(0.95, 0, 0), in: ipc.PTZPositionInput{X: 1, Y: 0.5}, expect: vector(0.95+onvifHalfFOVPan-2, 0, 0)},
+		{name: "tilt clamps", cur: vector(0, 0.95, 0), in: ipc.PTZPositionInput{X: 0.5, Y: 0}, expect: vector(0, 1, 0)},
+		{name: "zoom in doubles magnification", cur: vector(0, 0, 0), in: ipc.PTZPositionInput{X: 0.5, Y: 0.5, Zoom: 2}, expect: vector(0, 0, 1/(onvifZoomRange-1))},
+		{name: "zoom out clamps", cur: vector(0, 0, 0), in: ipc.PTZPositionInput{X: 0.5, Y: 0.5, Zoom: 0.5}, expect: vector(0, 0, 0)},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := absolutePosition(tt.cur, &tt.in)
+			if !near(got.PanTilt.X, tt.expect.PanTilt.X) || !near(got.PanTilt.Y, tt.expect.PanTilt.Y) || !near(got.Zoom.X, tt.expect.Zoom.X) {
+				t.Fatalf("expect %+v, got %+v", tt.expect, got)
+			}
+		})
+	}
+}
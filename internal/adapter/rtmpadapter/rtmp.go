@@ -53,7 +53,7 @@ func (a *Adapter) OnStreamChanged(ctx context.Context, app, stream string) error
 		slog.WarnContext(ctx, "更新 RTMP 通道停流状态失败", "app", app, "stream", stream, "err", err)
 	}
 	// 同时更新 IsPlaying
-	if _, err := a.ipcCore.EditChannelPlaying(ctx, ch.Stream, false); err != nil {
+	if _, err := a.ipcCore.EditChannelPlaying(ctx, app, stream, false); err != nil {
 		slog.WarnContext(ctx, "更新 RTMP 通道播放状态失败", "stream", stream, "err", err)
 	}
 	return nil
@@ -8,7 +8,10 @@ import (
 	"github.com/gowvp/owl/internal/core/sms"
 )
 
-var _ ipc.Protocoler = (*Adapter)(nil)
+var (
+	_ ipc.Protocoler = (*Adapter)(nil)
+	_ ipc.KeepAliver = (*Adapter)(nil)
+)
 
 // Adapter RTSP 协议适配器
 // 处理 RTSP 拉流的状态管理
@@ -62,12 +65,7 @@ func (a *Adapter) OnStreamNotFound(ctx context.Context, app string, stream strin
 	if err != nil {
 		return err
 	}
-	resp, err := a.smsCore.AddStreamProxy(svr, sms.AddStreamProxyRequest{
-		App:     ch.App,
-		Stream:  ch.Stream,
-		URL:     ch.Config.SourceURL,
-		RTPType: ch.Config.Transport,
-	})
+	resp, err := a.smsCore.AddStreamProxy(svr, proxyRequest(ch))
 	if err != nil {
 		return err
 	}
@@ -80,6 +78,40 @@ func (a *Adapter) OnStreamNotFound(ctx context.Context, app string, stream strin
 	return err
 }
 
+// ShouldKeepAlive implements ipc.KeepAliver.
+// 与拉流代理的 auto_close 保持一致
+func (a *Adapter) ShouldKeepAlive(_ context.Context, ch *ipc.Channel) bool {
+	return keepPulling(ch)
+}
+
+// keepPulling 无人观看时是否保持拉流
+// 无人观看时删除或禁用，都需要流媒体在无人观看时关闭拉流；持续录像或 AI 分析的通道保持拉流
+func keepPulling(ch *ipc.Channel) bool {
+	cfg := ch.Config
+	return ch.Ext.IsStreamKeepAlive() || !(cfg.EnabledRemoveNoneReader || cfg.EnabledDisabledNoneReader)
+}
+
+// proxyRequest 按通道的拉流配置构建代理请求
+func proxyRequest(ch *ipc.Channel) sms.AddStreamProxyRequest {
+	cfg := ch.Config
+	autoClose := !keepPulling(ch)
+	return sms.AddStreamProxyRequest{
+		App:         ch.App,
+		Stream:      ch.Stream,
+		URL:         cfg.SourceURL,
+		RTPType:     cfg.Transport,
+		TimeoutSec:  cfg.TimeoutS,
+		RetryCount:  cfg.RetryCount,
+		EnableAudio: new(cfg.AudioEnabled()),
+		AutoClose:   new(autoClose),
+
+		EnableRTSP:    new(!cfg.DisabledRTSP),
+		EnableRTMP:    new(!cfg.DisabledRTMP),
+		EnableHLSFMP4: new(!cfg.DisabledHLS),
+		AddMuteAudio:  new(!cfg.DisabledMuteAudio),
+	}
+}
+
 // QueryCatalog implements ipc.Protocoler.
 func (a *Adapter) QueryCatalog(ctx context.Context, device *ipc.Device) error {
 	return nil
@@ -0,0 +1,108 @@
+package rtspadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+func TestProxyRequest(t *testing.T) {
+	ch := ipc.Channel{
+		App:    "rtsp",
+		Stream: "cam1",
+		Ext:    ipc.DeviceExt{RecordMode: "ai"},
+		Config: ipc.StreamConfig{
+			SourceURL:                 "rtsp://192.168.1.10/live",
+			Transport:                 1,
+			TimeoutS:                  25,
+			RetryCount:                -1,
+			EnabledAudio:              new(false),
+			EnabledDisabledNoneReader: true,
+		},
+	}
+	req := proxyRequest(&ch)
+	if req.App != "rtsp" || req.Stream != "cam1" || req.URL != ch.Config.SourceURL {
+		t.Fatalf("unexpected stream %+v", req)
+	}
+	if req.RTPType != 1 || req.TimeoutSec != 25 || req.RetryCount != -1 {
+		t.Fatalf("expect transport 1 timeout 25 retry -1, got %d %d %d", req.RTPType, req.TimeoutSec, req.RetryCount)
+	}
+	if req.EnableAudio == nil || *req.EnableAudio {
+		t.Fatal("expect audio disabled")
+	}
+	if req.AutoClose == nil || !*req.AutoClose {
+		t.Fatal("expect auto close when disabled on none reader")
+	}
+
+	ch.Config.EnabledAudio = new(true)
+	ch.Config.EnabledDisabledNoneReader = false
+	req = proxyRequest(&ch)
+	if !*req.EnableAudio || *req.AutoClose {
+		t.Fatalf("expect audio enabled and keep pulling, got audio=%v auto_close=%v", *req.EnableAudio, *req.AutoClose)
+	}
+	if !*req.EnableRTSP || !*req.EnableRTMP || !*req.EnableHLSFMP4 || !*req.AddMuteAudio {
+		t.Fatal("expect all protocols enabled by default")
+	}
+
+	// 持续录像或启用 AI 的通道即使配置了无人观看关闭也保持拉流
+	ch.Config.EnabledRemoveNoneReader = true
+	for _, ext := range []ipc.DeviceExt{{RecordMode: "always"}, {}, {RecordMode: "none", EnabledAI: true}} {
+		ch.Ext = ext
+		if req = proxyRequest(&ch); *req.AutoClose {
+			t.Fatalf("expect keep pulling for ext %+v", ext)
+		}
+	}
+	off := false
+	ch.Ext = ipc.DeviceExt{RecordMode: "always", EnabledRecording: &off}
+	if req = proxyRequest(&ch); !*req.AutoClose {
+		t.Fatal("expect auto close when recording disabled")
+	}
+
+	ch.Config.DisabledRTMP = true
+	ch.Config.DisabledHLS = true
+	ch.Config.DisabledMuteAudio = true
+	req = proxyRequest(&ch)
+	if !*req.EnableRTSP || *req.EnableRTMP || *req.EnableHLSFMP4 || *req.AddMuteAudio {
+		t.Fatalf("expect only rtsp enabled, got rtsp=%v rtmp=%v hls=%v mute=%v",
+			*req.EnableRTSP, *req.EnableRTMP, *req.EnableHLSFMP4, *req.AddMuteAudio)
+	}
+}
+
+func TestProxyRequestLegacyAudio(t *testing.T) {
+	// 历史通道的配置未保存 enabled_audio，应保持升级前开启音频的行为
+	var cfg ipc.StreamConfig
+	if err := cfg.Scan([]byte(`{"source_url":"rtsp://192.168.1.10/live","transport":0}`)); err != nil {
+		t.Fatal(err)
+	}
+	req := proxyRequest(&ipc.Channel{App: "rtsp", Stream: "cam1", Config: cfg})
+	if req.EnableAudio == nil || !*req.EnableAudio {
+		t.Fatal("expect audio enabled for legacy channel")
+	}
+}
+
+func TestShouldKeepAlive(t *testing.T) {
+	var a Adapter
+	cases := []struct {
+		ext  ipc.DeviceExt
+		cfg  ipc.StreamConfig
+		keep bool
+	}{
+		// 未配置无人观看关闭的拉流常驻
+		{ext: ipc.DeviceExt{RecordMode: "none"}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "none"}, cfg: ipc.StreamConfig{EnabledRemoveNoneReader: true}, keep: false},
+		{ext: ipc.DeviceExt{RecordMode: "ai"}, cfg: ipc.StreamConfig{EnabledDisabledNoneReader: true}, keep: false},
+		{ext: ipc.DeviceExt{RecordMode: "always"}, cfg: ipc.StreamConfig{EnabledRemoveNoneReader: true}, keep: true},
+		{ext: ipc.DeviceExt{RecordMode: "none", EnabledAI: true}, cfg: ipc.StreamConfig{EnabledDisabledNoneReader: true}, keep: true},
+	}
+	for _, tc := range cases {
+		ch := ipc.Channel{Type: ipc.TypeRTSP, Ext: tc.ext, Config: tc.cfg}
+		if got := ipc.ShouldKeepAlive(context.Background(), &a, &ch); got != tc.keep {
+			t.Fatalf("ext %+v config %+v expect keep %v, got %v", tc.ext, tc.cfg, tc.keep, got)
+		}
+		// 与拉流代理的 auto_close 一致
+		if req := proxyRequest(&ch); *req.AutoClose == tc.keep {
+			t.Fatalf("auto_close %v conflicts with keep %v", *req.AutoClose, tc.keep)
+		}
+	}
+}
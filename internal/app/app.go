@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/pkg/ffwork"
 	"github.com/ixugo/goddd/domain/version/versionapi"
 	"github.com/ixugo/goddd/pkg/logger"
 	"github.com/ixugo/goddd/pkg/server"
@@ -46,6 +47,17 @@ func Run(bc *conf.Bootstrap) {
 	defer clean()
 
 	go setupZLM(ctx, bc.ConfigDir)
+	capture := bc.Server.AI.Capture
+	if err := ffwork.SetDefaults(ffwork.Options{
+		Transport: capture.Transport,
+		Timeout:   capture.Timeout.Duration(),
+		ProbeSize: capture.ProbeSize,
+	}); err != nil {
+		slog.Warn("拉流参数配置无效，使用默认值", "err", err)
+	}
+	if err := bc.Server.Snapshot.Normalize(); err != nil {
+		slog.Warn("快照格式配置无效", "err", err)
+	}
 	if !bc.Server.AI.Disabled {
 		go setupAIClient(ctx, "http://127.0.0.1:15123/ai", bc.Debug)
 	}
@@ -25,24 +25,26 @@ func wireApp(bc *conf.Bootstrap, log *slog.Logger) (http.Handler, func(), error)
 	}
 	core := versionapi.NewVersionCore(db)
 	versionapiAPI := versionapi.New(core)
-	smsCore := api.NewSMSCore(db, bc)
+	smsCore, cleanup := api.NewSMSCore(db, bc)
 	smsAPI := api.NewSmsAPI(smsCore)
 	storer := api.NewIPCStore(db)
 	uniqueidCore := api.NewUniqueID(db)
 	adapter := api.NewGBAdapter(storer, uniqueidCore)
-	server, cleanup := gbs.NewServer(bc, adapter, smsCore)
+	server, cleanup2 := gbs.NewServer(bc, adapter, smsCore)
 	ipcBundle := api.NewIPCCoreWithProtocols(storer, uniqueidCore, adapter, smsCore, server, bc)
 	recordingStorer := api.NewRecordingStore(db)
 	smsProvider := api.NewSMSProviderAdapter(smsCore)
-	recordingCore := api.NewRecordingCore(recordingStorer, bc, smsProvider)
+	channelProvider := api.NewChannelProviderAdapter(ipcBundle)
+	eventCore, cleanup3 := api.NewEventCore(db, bc)
+	recordingCore := api.NewRecordingCore(recordingStorer, bc, smsProvider, channelProvider, eventCore)
 	webHookAPI := api.NewWebHookAPI(smsCore, bc, server, ipcBundle, recordingCore)
 	ipcapi := api.NewIPCAPI(ipcBundle, recordingCore)
 	configAPI := api.NewConfigAPI(db, bc)
 	userAPI := api.NewUserAPI(bc)
-	eventCore := api.NewEventCore(db, bc)
 	aiWebhookAPI := api.NewAIWebhookAPIWithDeps(bc, eventCore, ipcBundle)
 	eventAPI := api.NewEventAPI(eventCore, bc)
 	recordingAPI := api.NewRecordingAPI(recordingCore, bc)
+	ptzapi := api.NewPTZAPI(ipcBundle, bc)
 	usecase := &api.Usecase{
 		Conf:         bc,
 		DB:           db,
@@ -57,9 +59,12 @@ func wireApp(bc *conf.Bootstrap, log *slog.Logger) (http.Handler, func(), error)
 		AIWebhookAPI: aiWebhookAPI,
 		EventAPI:     eventAPI,
 		RecordingAPI: recordingAPI,
+		PTZAPI:       ptzapi,
 	}
 	handler := api.NewHTTPHandler(usecase)
 	return handler, func() {
+		cleanup3()
+		cleanup2()
 		cleanup()
 	}, nil
 }
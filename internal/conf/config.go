@@ -1,6 +1,10 @@
 package conf
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Bootstrap struct {
 	Debug        bool   `toml:"-" json:"-"`
@@ -25,6 +29,52 @@ type Server struct {
 	AI        ServerAI        `comment:"ai 分析服务"`
 	HTTP      ServerHTTP      `comment:"对外提供的服务，建议由 nginx 代理"` // HTTP服务器
 	Recording ServerRecording `comment:"录像配置"`
+	Tamper    ServerTamper    `comment:"画面遮挡/移位检测"`
+	OTA       ServerOTA       `comment:"版本检查与在线升级"`
+	Poster    ServerPoster    `comment:"通道封面缓存"`
+	Snapshot  ServerSnapshot  `comment:"快照图片格式"`
+}
+
+// ServerSnapshot 事件快照与通道封面落盘前统一转码，带宽受限的现场可降低质量以减小图片
+type ServerSnapshot struct {
+	Format  string `comment:"图片格式 jpeg/webp，为空保持流媒体返回的原图；webp 为无损编码，quality 不生效"`
+	Quality int    `comment:"jpeg 质量 1~100，0 使用默认值 80"`
+
+	WithRecordings bool `comment:"事件快照存放到录像的按天目录 {录像目录}/{app}/{stream}/{日期}/，与录像一起归档；默认 false 存放在 configs/events/{cid}/，切换后历史快照仍可访问"`
+}
+
+// Normalize 统一图片格式大小写，不支持的格式回退为保持原图，避免每次抓拍都因转码失败丢失快照
+func (s *ServerSnapshot) Normalize() error {
+	s.Format = strings.ToLower(strings.TrimSpace(s.Format))
+	switch s.Format {
+	case "", "jpeg", "jpg", "webp":
+		return nil
+	}
+	format := s.Format
+	s.Format = ""
+	return fmt.Errorf("unsupported snapshot format %q, keep original image", format)
+}
+
+// ServerPoster 定时从正在拉流的通道抓取关键帧作为封面，设备墙直接读取缓存，无需实时抓拍
+type ServerPoster struct {
+	Dir string   `comment:"封面缓存目录，为空使用 configs/cover"`
+	TTL Duration `comment:"封面有效期，过期后下一轮巡检重新抓取，0 表示不定时刷新"`
+
+	Fallback    []string `comment:"设备墙获取封面的顺序，前一项失败时使用下一项；live 为拉流中且封面过期时实时抓拍，poster 为缓存封面，placeholder 为离线占位图"`
+	Placeholder string   `comment:"离线占位图路径，为空使用内置灰色图片"`
+}
+
+// ServerOTA 无法访问 GitHub 的网络或自行维护的分支，可替换仓库与镜像地址
+type ServerOTA struct {
+	Repo        string `comment:"GitHub 仓库，如 gowvp/owl，为空使用官方仓库"`
+	APIURL      string `comment:"版本查询 API 地址，需兼容 GitHub API，为空使用 https://api.github.com"`
+	DownloadURL string `comment:"升级包下载镜像地址，需兼容 GitHub 路径，为空使用 https://github.com"`
+}
+
+// ServerTamper 将通道当前画面与参考图比对，识别镜头被遮挡或转向
+type ServerTamper struct {
+	Interval  Duration `comment:"定时巡检间隔，0 表示不巡检，仅支持手动检测"`
+	Threshold float64  `comment:"篡改分数阈值 (0,1]，超过则产生事件"`
 }
 
 // ServerRecording 录像配置，控制流媒体录制行为和存储策略
@@ -34,12 +84,42 @@ type ServerRecording struct {
 	StorageDir         string  `comment:"录像存储根目录（相对于工作目录）"`
 	RetainDays         int     `comment:"录像保留天数（超过则清理）"`
 	DiskUsageThreshold float64 `comment:"磁盘使用率阈值（百分比），超过则触发循环覆盖"`
+	MinFreeBytes       int64   `comment:"磁盘最少保留的可用空间（字节），低于则持续删除最旧录像，0 表示不限制"`
 	SegmentSeconds     int     `comment:"MP4 切片时长（秒）"`
+	ExpectedMount      string  `comment:"录像目录应所在的挂载点（如数据盘 /data），启动时校验，为空不校验"`
+
+	CleanupInterval Duration `comment:"录像清理巡检间隔，最小 1 分钟，0 表示默认 60 分钟"`
+	BatchSize       int      `comment:"清理时每批删除的录像数量(1~1000)，0 表示默认 100"`
+
+	Timezone string `comment:"按天统计与清理使用的时区，如 Asia/Shanghai，为空使用服务器本地时区"`
+
+	EventRetainDays int `comment:"与 AI 事件时间重叠的录像在保留天数之外额外保留的天数，0 表示不区分，磁盘空间不足时仍会删除"`
+
+	NodeStorageDirs map[string]string `comment:"其它媒体节点的录像在本机的访问目录（如 NFS 挂载点），键为节点 ID，值为该节点 ZLM http 根目录的挂载路径；未配置的节点使用 StorageDir"`
+
+	PublicBaseURL string `comment:"录像播放列表中片段地址的前缀（如 CDN 域名 https://cdn.example.com），路径保持 /static/recordings/...，为空使用相对路径"`
+
+	NotifyURL     string `comment:"录像切片入库后 POST 通知的外部地址（如 VMS 归档服务），为空不通知"`
+	NotifyRetries int    `comment:"录像通知失败重试次数，0 表示默认 3 次"`
 }
 
 type ServerAI struct {
-	Disabled   bool `comment:"是否禁用 ai 分析服务"`
-	RetainDays int  `comment:"保留天数"`
+	Disabled      bool     `comment:"是否禁用 ai 分析服务"`
+	RetainDays    int      `comment:"保留天数"`
+	CleanupTime   string   `comment:"过期事件每日清理时间(HH:MM)，为空默认 03:00"`
+	MaxEvents     int      `comment:"每个通道最多保留的事件数，超出删除最旧的，0 表示不限制"`
+	DefaultLabels []string `comment:"区域未指定标签时默认检测的标签"`
+	Labels        []string `comment:"模型支持的标签目录，为空时使用内置 COCO 标签"`
+	MaxTotalFPS   int      `comment:"所有通道 AI 检测帧率之和的上限，超出时降低新任务帧率或拒绝启动，0 表示不限制"`
+
+	Capture ServerCapture `comment:"ffmpeg 拉流抓帧参数（运动预过滤等），广域网或弱网环境下频繁断流时调整"`
+}
+
+// ServerCapture ffmpeg 拉流参数
+type ServerCapture struct {
+	Transport string   `comment:"rtsp 传输方式 tcp/udp/udp_multicast/http/https，为空默认 tcp"`
+	Timeout   Duration `comment:"读写超时，至少 1s，0 默认 10s"`
+	ProbeSize int64    `comment:"探测字节数，至少 32，0 使用 ffmpeg 默认值"`
 }
 
 type ServerHTTP struct {
@@ -47,6 +127,9 @@ type ServerHTTP struct {
 	Timeout   Duration    `comment:"请求超时时间"`                 // 请求超时时间
 	JwtSecret string      `comment:"jwt 秘钥，空串时，每次启动程序将随机赋值"` // JWT密钥
 	PProf     ServerPPROF // Pprof配置
+
+	MaxBodyMB   int `comment:"普通接口请求体大小上限(MB)，超出返回 413，流媒体代理不受限制，0 使用默认 32"`
+	MaxUploadMB int `comment:"上传接口请求体大小上限(MB)，如离线升级包，0 使用默认 512"`
 }
 
 // ServerPPROF 结构体，包含 Enabled 和 AccessIps 两个字段
@@ -86,6 +169,35 @@ type SIP struct {
 	ID       string `comment:"gb/t28181 20 位国标 ID" json:"id"`
 	Domain   string `comment:"域" json:"domain"`
 	Password string `comment:"注册密码" json:"password"`
+	Host     string `comment:"向设备通告的 SIP 地址，NAT 或多网卡时填写设备可达的 IP(如公网映射 IP)，为空自动取内网 IP" json:"host"`
+
+	KeepaliveInterval int `comment:"默认心跳间隔(秒)，设备未上报心跳配置时使用" json:"keepalive_interval"`
+	KeepaliveMaxMiss  int `comment:"连续丢失心跳次数达到该值才判定离线，0 表示使用设备上报的超时次数" json:"keepalive_max_miss"`
+
+	StrictKeepalive bool `comment:"关闭快速登录，离线设备须重新注册才能上线；默认 false 即有心跳就认为在线" json:"strict_keepalive"`
+
+	SDPFormats []string `comment:"点播 INVITE 的 SDP 媒体格式，每项为 '载荷号 编码/时钟频率'，如 '96 PS/90000'，编码可选 PS/MPEG4/H264/H265/SVAC，为空使用 PS/MPEG4/H264" json:"sdp_formats"`
+
+	SSRCPrefix string `comment:"点播 SSRC 前缀，仅数字，为空取 SIP 域第 4~8 位" json:"ssrc_prefix"`
+	SSRCFormat string `comment:"点播 SSRC 格式，共 10 位十进制，{type} 为 0 实时/1 历史，{seq} 为流序号并占满剩余位数(至少 3 位)，为空使用 {type}{prefix}{seq}" json:"ssrc_format"`
+
+	CatalogInterval Duration `comment:"定时查询在线设备目录的间隔，用于发现通道增减并记录日志，最小 1 分钟，0 表示不定时查询" json:"catalog_interval"`
+
+	PTZAutoStop Duration `comment:"云台移动后未收到新指令时自动停止的时长，防止客户端异常退出导致云台一直转动，0 表示默认 60 秒" json:"ptz_auto_stop"`
+
+	Cascades []SIPCascade `comment:"级联的上级平台，本平台作为下级向其注册并共享国标通道" json:"cascades"`
+}
+
+// SIPCascade 上级平台
+type SIPCascade struct {
+	Enabled   bool   `comment:"是否启用" json:"enabled"`
+	ID        string `comment:"上级平台 20 位国标 ID" json:"id"`
+	Domain    string `comment:"上级平台 SIP 域" json:"domain"`
+	Host      string `comment:"上级平台 SIP 地址" json:"host"`
+	Port      int    `comment:"上级平台 SIP 端口(udp)" json:"port"`
+	Password  string `comment:"向上级注册的密码，用户名为本平台国标 ID" json:"password"`
+	Expires   int    `comment:"注册有效期(秒)，0 表示默认 3600" json:"expires"`
+	Keepalive int    `comment:"心跳间隔(秒)，0 表示默认 60" json:"keepalive"`
 }
 
 type Media struct {
@@ -96,6 +208,10 @@ type Media struct {
 	WebHookIP    string `comment:"用于流媒体 webhook 回调"`
 	RTPPortRange string `comment:"媒体服务器 RTP 端口范围"`
 	SDPIP        string `comment:"媒体服务器 SDP IP"`
+	MaxPlays     int    `comment:"默认媒体服务器最大并发点播数，0 表示不限制"`
+
+	SnapshotConcurrency int      `comment:"同时向流媒体发起的抓拍数，各功能共用，0 使用默认值 4"`
+	SnapshotCacheTTL    Duration `comment:"同一通道抓拍结果的复用时长，0 使用默认值 2s，小于 0 不复用"`
 }
 
 type Duration time.Duration
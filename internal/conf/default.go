@@ -13,24 +13,49 @@ func DefaultConfig() Bootstrap {
 			Password:   "admin",
 			RTMPSecret: "123",
 			HTTP: ServerHTTP{
-				Port:      15123,
-				Timeout:   Duration(60 * time.Second),
-				JwtSecret: orm.GenerateRandomString(24),
+				Port:        15123,
+				Timeout:     Duration(60 * time.Second),
+				JwtSecret:   orm.GenerateRandomString(24),
+				MaxBodyMB:   32,
+				MaxUploadMB: 512,
 				PProf: ServerPPROF{
 					Enabled:   true,
 					AccessIps: []string{"::1", "127.0.0.1"},
 				},
 			},
 			AI: ServerAI{
-				Disabled:   false,
-				RetainDays: 7,
+				Disabled:      false,
+				RetainDays:    7,
+				CleanupTime:   "03:00",
+				DefaultLabels: []string{"person", "car", "cat", "dog"},
+				Capture: ServerCapture{
+					Transport: "tcp",
+					Timeout:   Duration(10 * time.Second),
+				},
+			},
+			Tamper: ServerTamper{
+				Interval:  0,
+				Threshold: 0.6,
+			},
+			Poster: ServerPoster{
+				TTL:      Duration(5 * time.Minute),
+				Fallback: []string{"live", "poster", "placeholder"},
+			},
+			Snapshot: ServerSnapshot{
+				Quality: 80,
+			},
+			OTA: ServerOTA{
+				Repo: "gowvp/owl",
 			},
 			Recording: ServerRecording{
 				Disabled:           false,
 				StorageDir:         "./configs/recordings",
 				RetainDays:         3,
 				DiskUsageThreshold: 95.0,
+				MinFreeBytes:       1 << 30,
 				SegmentSeconds:     300,
+				CleanupInterval:    Duration(60 * time.Minute),
+				BatchSize:          100,
 			},
 		},
 		Data: Data{
@@ -47,6 +72,10 @@ func DefaultConfig() Bootstrap {
 			ID:       "34010000002000000001",
 			Domain:   "3401000000",
 			Password: "",
+
+			KeepaliveInterval: 60,
+			KeepaliveMaxMiss:  3,
+			PTZAutoStop:       Duration(60 * time.Second),
 		},
 		Media: Media{
 			IP:           "127.0.0.1",
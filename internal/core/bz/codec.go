@@ -0,0 +1,89 @@
+package bz
+
+import "strings"
+
+// Codec 归一化后的音视频编码
+// 不同流媒体与 ffprobe 对同一编码的命名不同，统一后前端据此选择播放器，服务端据此决定是否需要纯视频/转码
+type Codec string
+
+const (
+	CodecUnknown Codec = ""
+	CodecH264    Codec = "H264"
+	CodecH265    Codec = "H265"
+	CodecAAC     Codec = "AAC"
+	CodecG711A   Codec = "G711A"
+	CodecG711U   Codec = "G711U"
+	CodecOpus    Codec = "OPUS"
+	CodecMP3     Codec = "MP3"
+)
+
+// ParseCodec 将流媒体或 ffprobe 上报的编码名称转为 Codec，无法识别时返回 CodecUnknown
+// 支持 ZLM codec_id_name（CodecH264）、lalmax（H264/HEVC/PCMA）、ffprobe codec_name（hevc/pcm_alaw）
+func ParseCodec(name string) Codec {
+	s := strings.ToLower(strings.TrimSpace(name))
+	s = strings.TrimPrefix(s, "codec")
+	s = strings.NewReplacer(".", "", "_", "", "-", "").Replace(s)
+	switch s {
+	case "h264", "avc", "avc1":
+		return CodecH264
+	case "h265", "hevc", "hvc1", "hev1":
+		return CodecH265
+	case "aac", "mp4a":
+		return CodecAAC
+	case "g711a", "pcma", "pcmalaw", "alaw":
+		return CodecG711A
+	case "g711u", "pcmu", "pcmmulaw", "mulaw", "ulaw":
+		return CodecG711U
+	case "opus":
+		return CodecOpus
+	case "mp3", "mp3float":
+		return CodecMP3
+	}
+	return CodecUnknown
+}
+
+// ZLM codec_id 取值，codec_id_name 缺失时使用
+var zlmCodecIDs = map[int]Codec{
+	0: CodecH264,
+	1: CodecH265,
+	2: CodecAAC,
+	3: CodecG711A,
+	4: CodecG711U,
+	5: CodecOpus,
+}
+
+// ParseZLMCodec 解析 ZLM 轨道编码，优先使用名称
+func ParseZLMCodec(id int, name string) Codec {
+	if c := ParseCodec(name); c != CodecUnknown {
+		return c
+	}
+	return zlmCodecIDs[id]
+}
+
+// IsVideo 是否为视频编码
+func (c Codec) IsVideo() bool {
+	return c == CodecH264 || c == CodecH265
+}
+
+// StreamCodec 流或录像文件的编码信息
+type StreamCodec struct {
+	Video  Codec `json:"video"`            // 视频编码
+	Audio  Codec `json:"audio"`            // 音频编码，无音频时为空
+	Width  int   `json:"width,omitempty"`  // 视频宽
+	Height int   `json:"height,omitempty"` // 视频高
+}
+
+// IsEmpty 未识别出任何编码
+func (s StreamCodec) IsEmpty() bool {
+	return s.Video == CodecUnknown && s.Audio == CodecUnknown
+}
+
+// NeedVideoOnly 浏览器 MSE 无法解码 G.711，此时应提供去掉音频的纯视频版本
+func (s StreamCodec) NeedVideoOnly() bool {
+	return s.Audio == CodecG711A || s.Audio == CodecG711U
+}
+
+// NeedTranscode H.265 在多数浏览器中无法直接播放，需转码或使用支持 H.265 的播放器
+func (s StreamCodec) NeedTranscode() bool {
+	return s.Video == CodecH265
+}
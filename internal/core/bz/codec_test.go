@@ -0,0 +1,61 @@
+package bz
+
+import "testing"
+
+func TestParseCodec(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect Codec
+	}{
+		// ZLM codec_id_name
+		{"CodecH264", CodecH264},
+		{"CodecH265", CodecH265},
+		{"CodecAAC", CodecAAC},
+		{"CodecG711A", CodecG711A},
+		{"CodecG711U", CodecG711U},
+		{"CodecOpus", CodecOpus},
+		// lalmax
+		{"H264", CodecH264},
+		{"HEVC", CodecH265},
+		{"PCMA", CodecG711A},
+		{"PCMU", CodecG711U},
+		// ffprobe codec_name
+		{"h264", CodecH264},
+		{"hevc", CodecH265},
+		{"aac", CodecAAC},
+		{"pcm_alaw", CodecG711A},
+		{"pcm_mulaw", CodecG711U},
+		{"mp3float", CodecMP3},
+		{" avc1 ", CodecH264},
+		{"", CodecUnknown},
+		{"vp9", CodecUnknown},
+	}
+	for _, tt := range tests {
+		if got := ParseCodec(tt.name); got != tt.expect {
+			t.Errorf("ParseCodec(%q) expect %q, got %q", tt.name, tt.expect, got)
+		}
+	}
+}
+
+func TestParseZLMCodec(t *testing.T) {
+	if got := ParseZLMCodec(1, ""); got != CodecH265 {
+		t.Fatalf("expect fallback to codec_id, got %q", got)
+	}
+	if got := ParseZLMCodec(0, "CodecG711A"); got != CodecG711A {
+		t.Fatalf("expect codec_id_name first, got %q", got)
+	}
+	if got := ParseZLMCodec(99, ""); got != CodecUnknown {
+		t.Fatalf("expect unknown, got %q", got)
+	}
+}
+
+func TestStreamCodecDecision(t *testing.T) {
+	s := StreamCodec{Video: CodecH264, Audio: CodecG711A}
+	if !s.NeedVideoOnly() || s.NeedTranscode() {
+		t.Fatalf("h264+g711a expect video only without transcode")
+	}
+	s = StreamCodec{Video: CodecH265, Audio: CodecAAC}
+	if s.NeedVideoOnly() || !s.NeedTranscode() {
+		t.Fatalf("h265+aac expect transcode with audio")
+	}
+}
@@ -2,36 +2,85 @@ package event
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/ixugo/goddd/pkg/orm"
-	"github.com/ixugo/goddd/pkg/system"
 	"github.com/ixugo/goddd/pkg/web"
 	"gorm.io/gorm"
 )
 
-// StartCleanupWorker 启动定时清理协程，每天凌晨 3 点执行一次清理
-// days 参数指定保留的天数，超过该天数的事件将被删除
-func (c Core) StartCleanupWorker(days int) {
-	if days <= 0 {
-		slog.Info("event cleanup disabled", "days", days)
+// DefaultCleanupClock 默认每日清理时间，避开业务高峰
+const DefaultCleanupClock = "03:00"
+
+// ParseCleanupClock 解析 HH:MM 格式的每日清理时间，空串使用默认值
+func ParseCleanupClock(s string) (hour, minute int, err error) {
+	if s == "" {
+		s = DefaultCleanupClock
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cleanup time %q, expect HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// nextCleanupAt 计算 now 之后下一次到达 hour:minute 的本地时间
+// 每次都按日历重新计算，而不是固定间隔累加，避免漂移且能正确跨越夏令时
+func nextCleanupAt(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return next
+}
+
+// CleanupPolicy 事件清理策略，按天数与按通道数量上限同时生效，任一条件满足即删除
+type CleanupPolicy struct {
+	RetainDays    int // 保留天数，0 表示不按时间清理
+	MaxPerChannel int // 每个通道最多保留的事件数，0 表示不限制
+	Hour, Minute  int // 每日清理时间
+}
+
+// StartCleanupWorker 启动定时清理协程，启动时立即清理一次，之后每天在 Hour:Minute 执行
+// ctx 取消后协程退出
+func (c Core) StartCleanupWorker(ctx context.Context, p CleanupPolicy) {
+	if p.RetainDays <= 0 && p.MaxPerChannel <= 0 {
+		slog.Info("event cleanup disabled", "days", p.RetainDays, "max_per_channel", p.MaxPerChannel)
 		return
 	}
 
-	slog.Info("event cleanup worker started", "retain_days", days)
+	slog.Info("event cleanup worker started",
+		"retain_days", p.RetainDays,
+		"max_per_channel", p.MaxPerChannel,
+		"at", fmt.Sprintf("%02d:%02d", p.Hour, p.Minute),
+	)
 
 	// 启动时先执行一次清理
-	c.cleanupExpiredEvents(days)
+	c.runCleanup(ctx, p)
 
-	// 计算到下一个凌晨 3 点的时间
-	ticker := time.NewTicker(24 * time.Hour)
-	defer ticker.Stop()
+	for {
+		timer := time.NewTimer(time.Until(nextCleanupAt(time.Now(), p.Hour, p.Minute)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			c.runCleanup(ctx, p)
+		}
+	}
+}
 
-	for range ticker.C {
-		c.cleanupExpiredEvents(days)
+func (c Core) runCleanup(ctx context.Context, p CleanupPolicy) {
+	if p.RetainDays > 0 {
+		c.cleanupExpiredEvents(p.RetainDays)
+	}
+	if p.MaxPerChannel > 0 {
+		c.CleanupByChannelCap(ctx, p.MaxPerChannel)
 	}
 }
 
@@ -43,20 +92,96 @@ func (c Core) cleanupExpiredEvents(days int) {
 
 	slog.Info("starting event cleanup", "cutoff_time", cutoffTime.Format(time.DateTime), "retain_days", days)
 
+	// 错误已在删除时记录日志
+	totalDeleted, totalFilesDeleted, _ := c.batchDeleteEvents(ctx, orm.Where("started_at < ?", cutoffMs))
+
+	slog.Info("event cleanup completed",
+		"events_deleted", totalDeleted,
+		"files_deleted", totalFilesDeleted,
+	)
+}
+
+// channelCount 用于接收按通道分组的计数
+type channelCount struct {
+	CID   string `gorm:"column:cid"`
+	Count int64  `gorm:"column:cnt"`
+}
+
+// CleanupByChannelCap 每个通道只保留最新的 maxPerChannel 条事件，更旧的事件连同图片一并删除
+// 返回删除的事件数
+func (c Core) CleanupByChannelCap(ctx context.Context, maxPerChannel int) int {
+	if maxPerChannel <= 0 {
+		return 0
+	}
+
+	var counts []channelCount
+	err := c.store.Event().Session(ctx, func(db *gorm.DB) error {
+		return db.Model(&Event{}).
+			Select("cid, COUNT(*) as cnt").
+			Group("cid").
+			Having("COUNT(*) > ?", maxPerChannel).
+			Find(&counts).Error
+	})
+	if err != nil {
+		slog.Error("failed to count events by channel", "err", err)
+		return 0
+	}
+
+	var total, totalFiles int
+	for _, cc := range counts {
+		// 取第 maxPerChannel+1 新的事件作为分界，它及更旧的事件全部删除
+		var boundary []*Event
+		pager := web.PagerFilter{Page: maxPerChannel + 1, Size: 1}
+		if _, err := c.store.Event().Find(ctx, &boundary, &pager,
+			orm.Where("cid = ?", cc.CID),
+			orm.OrderBy("started_at DESC, id DESC"),
+		); err != nil || len(boundary) == 0 {
+			continue
+		}
+		b := boundary[0]
+		deleted, filesDeleted, _ := c.batchDeleteEvents(ctx, orm.Where(
+			"cid = ? AND (started_at < ? OR (started_at = ? AND id <= ?))",
+			cc.CID, b.StartedAt, b.StartedAt, b.ID,
+		))
+		total += deleted
+		totalFiles += filesDeleted
+	}
+
+	if total > 0 {
+		slog.Info("event cap cleanup completed",
+			"max_per_channel", maxPerChannel,
+			"channels", len(counts),
+			"events_deleted", total,
+			"files_deleted", totalFiles,
+		)
+	}
+	return total
+}
+
+// PurgeByChannels 删除指定通道的全部事件及图片，用于删除通道/设备时清理孤儿数据
+// 返回已删除的事件数，有图片或记录删除失败时同时返回错误
+func (c Core) PurgeByChannels(ctx context.Context, cids ...string) (int, error) {
+	if len(cids) == 0 {
+		return 0, nil
+	}
+	deleted, filesDeleted, err := c.batchDeleteEvents(ctx, orm.Where("cid IN ?", cids))
+	slog.Info("event purge completed", "cids", cids, "events_deleted", deleted, "files_deleted", filesDeleted, "err", err)
+	return deleted, err
+}
+
+// batchDeleteEvents 分批删除满足条件的事件，先删除本地图片文件，再删除数据库记录
+// 查询、图片或记录删除失败时返回汇总的错误
+func (c Core) batchDeleteEvents(ctx context.Context, conditions ...orm.QueryOption) (totalDeleted, totalFilesDeleted int, err error) {
 	// 分批查询并删除，避免一次性加载过多数据
 	batchSize := 100
-	totalDeleted := 0
-	totalFilesDeleted := 0
+	var failedFiles int
 
 	for {
-		// 查询一批过期事件
 		var events []*Event
 		pager := web.PagerFilter{Page: 1, Size: batchSize}
-		_, err := c.store.Event().Find(ctx, &events, &pager,
-			orm.Where("started_at < ?", cutoffMs),
-		)
-		if err != nil {
-			slog.Error("failed to query expired events", "err", err)
+		if _, findErr := c.store.Event().Find(ctx, &events, &pager, conditions...); findErr != nil {
+			slog.Error("failed to query events", "err", findErr)
+			err = errors.Join(err, findErr)
 			break
 		}
 
@@ -75,12 +200,18 @@ func (c Core) cleanupExpiredEvents(days int) {
 		}
 
 		// 先删除本地图片文件
-		eventsDir := filepath.Join(system.Getwd(), "configs", "events")
 		for imagePath := range imagePaths {
-			fullPath := filepath.Join(eventsDir, imagePath)
+			fullPath, err := c.snapshots.Lookup(imagePath)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					slog.Warn("invalid event image path", "path", imagePath, "err", err)
+				}
+				continue
+			}
 			if err := os.Remove(fullPath); err != nil {
 				if !os.IsNotExist(err) {
 					slog.Warn("failed to delete event image", "path", fullPath, "err", err)
+					failedFiles++
 				}
 			} else {
 				totalFilesDeleted++
@@ -88,23 +219,27 @@ func (c Core) cleanupExpiredEvents(days int) {
 		}
 
 		// 批量删除数据库记录，使用 WHERE IN 一次性删除
-		err = c.store.Event().Session(ctx, func(tx *gorm.DB) error {
+		if delErr := c.store.Event().Session(ctx, func(tx *gorm.DB) error {
 			return tx.Where("id IN ?", eventIDs).Delete(&Event{}).Error
-		})
-		if err != nil {
-			slog.Warn("failed to batch delete events", "count", len(eventIDs), "err", err)
-		} else {
-			totalDeleted += len(eventIDs)
+		}); delErr != nil {
+			slog.Warn("failed to batch delete events", "count", len(eventIDs), "err", delErr)
+			err = errors.Join(err, delErr)
+			// 删除失败时退出，避免重复查询到同一批数据导致死循环
+			break
 		}
+		totalDeleted += len(eventIDs)
 	}
 
-	// 清理空目录
-	cleanupEmptyDirs(filepath.Join(system.Getwd(), "configs", "events"))
-
-	slog.Info("event cleanup completed",
-		"events_deleted", totalDeleted,
-		"files_deleted", totalFilesDeleted,
-	)
+	// 清理空目录，与录像共用的目录交由录像清理处理
+	if !c.snapshots.Shared {
+		cleanupEmptyDirs(c.snapshots.root())
+	} else if c.snapshots.Fallback != "" {
+		cleanupEmptyDirs(c.snapshots.Fallback)
+	}
+	if failedFiles > 0 {
+		err = errors.Join(err, fmt.Errorf("%d event images failed to delete", failedFiles))
+	}
+	return totalDeleted, totalFilesDeleted, err
 }
 
 // cleanupEmptyDirs 递归删除空目录
@@ -0,0 +1,50 @@
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextCleanupAt(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(month time.Month, day, hour, minute, sec int) time.Time {
+		return time.Date(2026, month, day, hour, minute, sec, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		hour   int
+		minute int
+		expect time.Time
+	}{
+		{name: "before today", now: at(3, 10, 1, 30, 0), hour: 3, expect: at(3, 10, 3, 0, 0)},
+		{name: "exactly at", now: at(3, 10, 3, 0, 0), hour: 3, expect: at(3, 11, 3, 0, 0)},
+		{name: "just after", now: at(3, 10, 3, 0, 1), hour: 3, expect: at(3, 11, 3, 0, 0)},
+		{name: "evening", now: at(3, 10, 23, 59, 59), hour: 3, expect: at(3, 11, 3, 0, 0)},
+		{name: "month end", now: at(3, 31, 12, 0, 0), hour: 3, expect: at(4, 1, 3, 0, 0)},
+		{name: "year end", now: time.Date(2026, 12, 31, 22, 0, 0, 0, loc), hour: 3, expect: time.Date(2027, 1, 1, 3, 0, 0, 0, loc)},
+		{name: "midnight with minute", now: at(3, 10, 0, 10, 0), hour: 0, minute: 30, expect: at(3, 10, 0, 30, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextCleanupAt(tt.now, tt.hour, tt.minute); !got.Equal(tt.expect) {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestParseCleanupClock(t *testing.T) {
+	if h, m, err := ParseCleanupClock(""); err != nil || h != 3 || m != 0 {
+		t.Fatalf("expect default 03:00, got %d:%d %v", h, m, err)
+	}
+	if h, m, err := ParseCleanupClock("23:45"); err != nil || h != 23 || m != 45 {
+		t.Fatalf("expect 23:45, got %d:%d %v", h, m, err)
+	}
+	for _, s := range []string{"24:00", "3", "03:60", "abc"} {
+		if _, _, err := ParseCleanupClock(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+}
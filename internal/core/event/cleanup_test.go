@@ -0,0 +1,115 @@
+package event_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestPurgeByChannels(t *testing.T) {
+	db, store := newTestDB(t)
+	core := event.NewCore(store)
+	ctx := context.Background()
+
+	for _, cid := range []string{"cid1", "cid1", "cid2"} {
+		if err := store.Event().Add(ctx, &event.Event{DID: "did1", CID: cid, Label: "person"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := core.PurgeByChannels(ctx, "cid1"); err != nil || n != 2 {
+		t.Fatalf("expect 2 events purged, got %d %v", n, err)
+	}
+
+	var remain []*event.Event
+	if err := db.Find(&remain).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remain) != 1 || remain[0].CID != "cid2" {
+		t.Fatalf("expect only cid2 remain, got %+v", remain)
+	}
+}
+
+func TestPurgeByChannelsImageFailed(t *testing.T) {
+	t.Chdir(t.TempDir())
+	core, store := newTestCore(t)
+	ctx := context.Background()
+
+	// 非空目录无法被 os.Remove 删除，模拟图片删除失败
+	if err := os.MkdirAll(filepath.Join("configs", "events", "cid1", "a.jpg", "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Event().Add(ctx, &event.Event{CID: "cid1", ImagePath: "cid1/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := core.PurgeByChannels(ctx, "cid1"); n != 1 || err == nil {
+		t.Fatalf("expect event purged with image error, got %d %v", n, err)
+	}
+}
+
+func TestCleanupByChannelCap(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db, store := newTestDB(t)
+	core := event.NewCore(store)
+	ctx := context.Background()
+
+	eventsDir := filepath.Join("configs", "events")
+	base := time.Now().Add(-time.Hour)
+	add := func(cid string, i int) string {
+		image := fmt.Sprintf("%s/%d.jpg", cid, i)
+		full := filepath.Join(eventsDir, image)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("jpg"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		at := orm.Time{Time: base.Add(time.Duration(i) * time.Minute)}
+		if err := store.Event().Add(ctx, &event.Event{CID: cid, StartedAt: at, EndedAt: at, ImagePath: image}); err != nil {
+			t.Fatal(err)
+		}
+		return image
+	}
+	// 故意乱序写入，删除应以 started_at 为准
+	images := make(map[int]string)
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		images[i] = add("cid1", i)
+	}
+	add("cid2", 0)
+	add("cid2", 1)
+
+	if n := core.CleanupByChannelCap(ctx, 3); n != 2 {
+		t.Fatalf("expect 2 events deleted, got %d", n)
+	}
+
+	var remain []*event.Event
+	if err := db.Order("cid, started_at").Find(&remain).Error; err != nil {
+		t.Fatal(err)
+	}
+	var cid1 []string
+	for _, e := range remain {
+		if e.CID == "cid1" {
+			cid1 = append(cid1, e.ImagePath)
+		}
+	}
+	if len(remain) != 5 || !slices.Equal(cid1, []string{images[2], images[3], images[4]}) {
+		t.Fatalf("expect newest 3 of cid1 and all of cid2 kept, got %v (total %d)", cid1, len(remain))
+	}
+	for i, image := range images {
+		_, err := os.Stat(filepath.Join(eventsDir, image))
+		if deleted := os.IsNotExist(err); deleted != (i < 2) {
+			t.Fatalf("image %s deleted=%v, err=%v", image, deleted, err)
+		}
+	}
+
+	if n := core.CleanupByChannelCap(ctx, 3); n != 0 {
+		t.Fatalf("expect nothing to delete under cap, got %d", n)
+	}
+}
@@ -8,10 +8,24 @@ type Storer interface {
 
 // Core business domain
 type Core struct {
-	store Storer
+	store     Storer
+	snapshots SnapshotPath
+}
+
+type Option func(*Core)
+
+// WithSnapshotPath 注入快照存放位置，清理事件时据此删除图片
+func WithSnapshotPath(p SnapshotPath) Option {
+	return func(c *Core) {
+		c.snapshots = p
+	}
 }
 
 // NewCore create business domain
-func NewCore(store Storer) Core {
-	return Core{store: store}
+func NewCore(store Storer, opts ...Option) Core {
+	c := Core{store: store}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
 }
@@ -0,0 +1,27 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/gowvp/owl/internal/core/event/store/eventdb"
+	"gorm.io/gorm"
+)
+
+// newTestDB 打开内存 sqlite 并迁移事件表
+func newTestDB(t *testing.T) (*gorm.DB, event.Storer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, eventdb.NewDB(db).AutoMigrate(true)
+}
+
+// newTestCore 基于内存 sqlite 创建 Core，返回 store 用于准备数据
+func newTestCore(t *testing.T, opts ...event.Option) (event.Core, event.Storer) {
+	t.Helper()
+	_, store := newTestDB(t)
+	return event.NewCore(store, opts...), store
+}
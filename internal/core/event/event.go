@@ -26,8 +26,18 @@ type EventStorer interface {
 
 // FindEvents 分页查询事件列表，支持按 CID 和时间范围筛选
 func (c Core) FindEvents(ctx context.Context, in *FindEventInput) ([]*Event, int64, error) {
-	query := orm.NewQuery(4).OrderBy("started_at DESC")
+	query := in.filter(orm.NewQuery(5)).OrderBy("started_at DESC")
 
+	items := make([]*Event, 0, in.Limit())
+	total, err := c.store.Event().Find(ctx, &items, in, query.Encode()...)
+	if err != nil {
+		return nil, 0, reason.ErrDB.Withf(`Find in[%+v] err[%s]`, in, err.Error())
+	}
+	return items, total, nil
+}
+
+// filter 列表与导出共用的筛选条件
+func (in *FindEventInput) filter(query *orm.Query) *orm.Query {
 	if in.CID != "" {
 		query.Where("cid = ?", in.CID)
 	}
@@ -40,13 +50,7 @@ func (c Core) FindEvents(ctx context.Context, in *FindEventInput) ([]*Event, int
 	if in.StartMs > 0 && in.EndMs > 0 {
 		query.Where("started_at >= ? AND started_at <= ?", in.StartAt(), in.EndAt())
 	}
-
-	items := make([]*Event, 0, in.Limit())
-	total, err := c.store.Event().Find(ctx, &items, in, query.Encode()...)
-	if err != nil {
-		return nil, 0, reason.ErrDB.Withf(`Find in[%+v] err[%s]`, in, err.Error())
-	}
-	return items, total, nil
+	return query
 }
 
 // GetEvent 根据 ID 查询单个事件
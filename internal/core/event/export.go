@@ -0,0 +1,68 @@
+package event
+
+import (
+	"context"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// 导出格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// exportBatchSize 导出时每批读取的事件数，控制单次内存占用
+var exportBatchSize = 500
+
+// ExportEventInput 事件导出参数，筛选条件与列表一致
+type ExportEventInput struct {
+	FindEventInput
+	From   int64  `form:"from"`   // 开始时间 (毫秒时间戳)，等价于 start_ms
+	To     int64  `form:"to"`     // 结束时间 (毫秒时间戳)，等价于 end_ms
+	Format string `form:"format"` // csv/json，默认 csv
+}
+
+// Normalize 合并时间参数并校验导出格式
+func (in *ExportEventInput) Normalize() error {
+	if in.From > 0 {
+		in.StartMs = in.From
+	}
+	if in.To > 0 {
+		in.EndMs = in.To
+	}
+	switch in.Format {
+	case "":
+		in.Format = ExportFormatCSV
+	case ExportFormatCSV, ExportFormatJSON:
+	default:
+		return reason.ErrBadRequest.SetMsg("format 仅支持 csv/json")
+	}
+	return nil
+}
+
+// ExportEvents 按 ID 顺序分批遍历匹配的事件，fn 返回错误时中止
+// 使用 id 游标而非 offset 分页，导出期间新增事件不会导致重复或遗漏
+func (c Core) ExportEvents(ctx context.Context, in *FindEventInput, fn func([]*Event) error) error {
+	pager := web.PagerFilter{Page: 1, Size: exportBatchSize}
+	var lastID int64
+	for {
+		query := in.filter(orm.NewQuery(6)).Where("id > ?", lastID).OrderBy("id ASC")
+		items := make([]*Event, 0, exportBatchSize)
+		if _, err := c.store.Event().Find(ctx, &items, pager, query.Encode()...); err != nil {
+			return reason.ErrDB.Withf(`Find in[%+v] err[%s]`, in, err.Error())
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < exportBatchSize {
+			return nil
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
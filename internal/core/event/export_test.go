@@ -0,0 +1,84 @@
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestExportEventsBatches(t *testing.T) {
+	db, store := newTestDB(t)
+	core := event.NewCore(store)
+	ctx := context.Background()
+
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	const total = 1203
+	events := make([]*event.Event, 0, total+10)
+	for i := range total {
+		events = append(events, &event.Event{CID: "cid1", Label: "person", StartedAt: orm.Time{Time: base.Add(time.Duration(i) * time.Second)}})
+	}
+	for range 10 {
+		events = append(events, &event.Event{CID: "cid2", Label: "person", StartedAt: orm.Time{Time: base}})
+	}
+	if err := db.CreateInBatches(events, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var batches []int
+	seen := make(map[int64]struct{}, total)
+	var lastID int64
+	err := core.ExportEvents(ctx, &event.FindEventInput{CID: "cid1"}, func(items []*event.Event) error {
+		batches = append(batches, len(items))
+		for _, v := range items {
+			if v.CID != "cid1" {
+				t.Fatalf("unexpected cid %s", v.CID)
+			}
+			if v.ID <= lastID {
+				t.Fatalf("expect ascending id, got %d after %d", v.ID, lastID)
+			}
+			lastID = v.ID
+			seen[v.ID] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != total {
+		t.Fatalf("expect %d events, got %d", total, len(seen))
+	}
+	// 分批读取，单批不超过 500
+	if len(batches) != 3 || batches[0] != 500 || batches[2] != 203 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+
+	// 时间范围与列表一致
+	in := event.ExportEventInput{
+		FindEventInput: event.FindEventInput{CID: "cid1"},
+		From:           base.Add(10 * time.Second).UnixMilli(),
+		To:             base.Add(19 * time.Second).UnixMilli(),
+	}
+	if err := in.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if in.Format != event.ExportFormatCSV {
+		t.Fatalf("expect default csv, got %s", in.Format)
+	}
+	var count int
+	if err := core.ExportEvents(ctx, &in.FindEventInput, func(items []*event.Event) error {
+		count += len(items)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Fatalf("expect 10 events in range, got %d", count)
+	}
+
+	if err := (&event.ExportEventInput{Format: "xlsx"}).Normalize(); err == nil {
+		t.Fatal("expect error for unsupported format")
+	}
+}
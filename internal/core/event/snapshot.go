@@ -0,0 +1,49 @@
+package event
+
+import (
+	"context"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// 最近快照列表的默认与最大条数
+const (
+	DefaultRecentSnapshots = 12
+	MaxRecentSnapshots     = 100
+)
+
+// FindRecentSnapshotsInput 最近快照查询参数
+type FindRecentSnapshotsInput struct {
+	Limit int `form:"limit"` // 返回条数，默认 12，最大 100
+}
+
+// Snapshot 事件缩略图，仅包含展示所需字段，避免加载完整事件
+type Snapshot struct {
+	EventID   int64    `json:"event_id"`
+	Label     string   `json:"label"`
+	StartedAt orm.Time `json:"started_at"`
+	ImagePath string   `json:"-"`
+	URL       string   `json:"url"` // 图片地址，由 API 层填充
+}
+
+// FindRecentSnapshots 查询通道最近带图片的事件，按开始时间倒序
+func (c Core) FindRecentSnapshots(ctx context.Context, cid string, limit int) ([]*Snapshot, error) {
+	if limit <= 0 {
+		limit = DefaultRecentSnapshots
+	}
+	pager := web.PagerFilter{Page: 1, Size: min(limit, MaxRecentSnapshots)}
+
+	events := make([]*Event, 0, pager.Limit())
+	query := orm.NewQuery(2).Where("cid = ?", cid).Where("image_path <> ''").OrderBy("started_at DESC, id DESC")
+	if _, err := c.store.Event().Find(ctx, &events, pager, query.Encode()...); err != nil {
+		return nil, reason.ErrDB.Withf(`Find cid[%s] err[%s]`, cid, err.Error())
+	}
+
+	out := make([]*Snapshot, 0, len(events))
+	for _, e := range events {
+		out = append(out, &Snapshot{EventID: e.ID, Label: e.Label, StartedAt: e.StartedAt, ImagePath: e.ImagePath})
+	}
+	return out, nil
+}
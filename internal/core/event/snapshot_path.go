@@ -0,0 +1,177 @@
+package event
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/system"
+)
+
+// 快照目录模板，支持变量 {cid} {app} {stream} {date}
+const (
+	DefaultSnapshotTemplate   = "{cid}"                 // configs/events/{cid}/
+	RecordingSnapshotTemplate = "{app}/{stream}/{date}" // 与 ZLM 录像目录 {app}/{stream}/{日期} 一致
+)
+
+// errInvalidSnapshotPath 快照路径越出根目录
+var errInvalidSnapshotPath = errors.New("invalid snapshot path")
+
+var (
+	// snapshotFileRe 快照文件名 年月日时分秒_随机6位.扩展名
+	snapshotFileRe = regexp.MustCompile(`^\d{14}_\d{6}\.[a-z]+$`)
+	// snapshotDateRe 模板变量 {date} 生成的目录名
+	snapshotDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// SnapshotVars 快照目录模板变量
+type SnapshotVars struct {
+	CID    string
+	App    string
+	Stream string
+	Time   time.Time
+}
+
+// SnapshotPath 事件快照的存放位置，事件中的 image_path 为相对 Root 的路径
+type SnapshotPath struct {
+	Root     string
+	Template string
+	// Fallback 切换存放位置前的根目录，历史快照仍可访问与清理，为空表示无
+	Fallback string
+	// Shared 根目录与录像等其它数据共用，清理时不递归删除空目录
+	Shared bool
+}
+
+// DefaultSnapshotRoot 默认快照根目录 configs/events
+func DefaultSnapshotRoot() string {
+	return filepath.Join(system.Getwd(), "configs", "events")
+}
+
+// DefaultSnapshotPath 快照存放在 configs/events/{cid}/
+func DefaultSnapshotPath() SnapshotPath {
+	return SnapshotPath{Root: DefaultSnapshotRoot(), Template: DefaultSnapshotTemplate}
+}
+
+// RecordingSnapshotPath 快照存放在录像的按天目录，与录像一起归档
+func RecordingSnapshotPath(storageDir string) SnapshotPath {
+	root, err := filepath.Abs(storageDir)
+	if err != nil {
+		root = storageDir
+	}
+	return SnapshotPath{
+		Root:     root,
+		Template: RecordingSnapshotTemplate,
+		Fallback: DefaultSnapshotRoot(),
+		Shared:   true,
+	}
+}
+
+// Dir 按模板生成相对目录，变量中的路径分隔符会被替换，避免越出根目录
+func (p SnapshotPath) Dir(v SnapshotVars) string {
+	dir := strings.NewReplacer(
+		"{cid}", pathSegment(v.CID),
+		"{app}", pathSegment(v.App),
+		"{stream}", pathSegment(v.Stream),
+		"{date}", v.Time.Local().Format(time.DateOnly),
+	).Replace(p.template())
+	return filepath.Clean(dir)
+}
+
+// Resolve 返回相对路径在根目录下的完整路径，越出根目录时返回错误
+func (p SnapshotPath) Resolve(rel string) (string, error) {
+	return resolveIn(p.root(), rel)
+}
+
+// Lookup 查找已存在的快照文件，当前根目录不存在时回退到切换前的目录
+func (p SnapshotPath) Lookup(rel string) (string, error) {
+	full, err := p.Resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(full); err == nil || p.Fallback == "" {
+		return full, err
+	}
+	old, err := resolveIn(p.Fallback, rel)
+	if err != nil {
+		return "", err
+	}
+	_, err = os.Stat(old)
+	return old, err
+}
+
+// LookupSnapshot 与 Lookup 相同，但只接受符合目录模板与快照命名的路径
+// 快照与录像共用根目录时，避免通过快照接口读取录像目录下的其它文件
+func (p SnapshotPath) LookupSnapshot(rel string) (string, error) {
+	rel = strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if matchSnapshotLayout(p.template(), rel) {
+		return p.Lookup(rel)
+	}
+	if p.Fallback == "" || !matchSnapshotLayout(DefaultSnapshotTemplate, rel) {
+		return "", errInvalidSnapshotPath
+	}
+	full, err := resolveIn(p.Fallback, rel)
+	if err != nil {
+		return "", err
+	}
+	_, err = os.Stat(full)
+	return full, err
+}
+
+func (p SnapshotPath) template() string {
+	if p.Template == "" {
+		return DefaultSnapshotTemplate
+	}
+	return p.Template
+}
+
+func (p SnapshotPath) root() string {
+	if p.Root == "" {
+		return DefaultSnapshotRoot()
+	}
+	return p.Root
+}
+
+func resolveIn(root, rel string) (string, error) {
+	root = filepath.Clean(root)
+	full := filepath.Join(root, strings.TrimPrefix(rel, "/"))
+	if full != root && !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", errInvalidSnapshotPath
+	}
+	return full, nil
+}
+
+// matchSnapshotLayout 判断 rel 是否为模板生成的目录加快照文件名
+func matchSnapshotLayout(tpl, rel string) bool {
+	dirs := strings.Split(tpl, "/")
+	segs := strings.Split(rel, "/")
+	if len(segs) != len(dirs)+1 || !snapshotFileRe.MatchString(segs[len(dirs)]) {
+		return false
+	}
+	for i, d := range dirs {
+		switch s := segs[i]; {
+		case s == "" || s == "." || s == "..":
+			return false
+		case d == "{date}":
+			if !snapshotDateRe.MatchString(s) {
+				return false
+			}
+		case !strings.Contains(d, "{"):
+			if s != d {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pathSegment 将变量值限制为单级目录名
+func pathSegment(s string) string {
+	s = strings.NewReplacer("/", "_", `\`, "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
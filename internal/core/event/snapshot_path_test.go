@@ -0,0 +1,121 @@
+package event_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/event"
+)
+
+func TestSnapshotPathDir(t *testing.T) {
+	ts := time.Date(2026, 3, 5, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		p    event.SnapshotPath
+		v    event.SnapshotVars
+		want string
+	}{
+		{name: "default", p: event.DefaultSnapshotPath(), v: event.SnapshotVars{CID: "cid1", Time: ts}, want: "cid1"},
+		{name: "empty template", p: event.SnapshotPath{Root: "/data"}, v: event.SnapshotVars{CID: "cid1"}, want: "cid1"},
+		{
+			name: "recording",
+			p:    event.RecordingSnapshotPath("/data/recordings"),
+			v:    event.SnapshotVars{CID: "cid1", App: "rtp", Stream: "cid1", Time: ts},
+			want: filepath.Join("rtp", "cid1", "2026-03-05"),
+		},
+		{
+			name: "sanitize",
+			p:    event.RecordingSnapshotPath("/data/recordings"),
+			v:    event.SnapshotVars{App: "..", Stream: "../../etc", Time: ts},
+			want: filepath.Join("_", ".._.._etc", "2026-03-05"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.Dir(tt.v); got != tt.want {
+				t.Fatalf("expect %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSnapshotPathResolve(t *testing.T) {
+	root := t.TempDir()
+	p := event.SnapshotPath{Root: root, Template: event.RecordingSnapshotTemplate}
+
+	full, err := p.Resolve("/rtp/cid1/2026-03-05/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "rtp", "cid1", "2026-03-05", "a.jpg"); full != want {
+		t.Fatalf("expect %s, got %s", want, full)
+	}
+	for _, rel := range []string{"../a.jpg", "rtp/../../a.jpg"} {
+		if _, err := p.Resolve(rel); err == nil {
+			t.Fatalf("expect %s rejected", rel)
+		}
+	}
+}
+
+func TestSnapshotPathLookupFallback(t *testing.T) {
+	t.Chdir(t.TempDir())
+	p := event.RecordingSnapshotPath("recordings")
+
+	// 切换配置前保存在 configs/events 下的快照
+	old := filepath.Join(event.DefaultSnapshotRoot(), "cid1", "old.jpg")
+	writeSnapshot(t, old)
+	got, err := p.Lookup("cid1/old.jpg")
+	if err != nil || got != old {
+		t.Fatalf("expect fallback %s, got %s err %v", old, got, err)
+	}
+
+	// 新快照优先从录像目录查找
+	cur := filepath.Join(p.Root, "rtp", "cid1", "2026-03-05", "new.jpg")
+	writeSnapshot(t, cur)
+	got, err = p.Lookup("rtp/cid1/2026-03-05/new.jpg")
+	if err != nil || got != cur {
+		t.Fatalf("expect %s, got %s err %v", cur, got, err)
+	}
+
+	if _, err := p.Lookup("cid1/missing.jpg"); !os.IsNotExist(err) {
+		t.Fatalf("expect not exist, got %v", err)
+	}
+}
+
+func TestPurgeWithRecordingSnapshotPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	p := event.RecordingSnapshotPath("recordings")
+	core, store := newTestCore(t, event.WithSnapshotPath(p))
+	ctx := context.Background()
+
+	rel := filepath.Join("rtp", "cid1", "2026-03-05", "a.jpg")
+	full := filepath.Join(p.Root, rel)
+	writeSnapshot(t, full)
+	if err := store.Event().Add(ctx, &event.Event{CID: "cid1", ImagePath: rel}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := core.PurgeByChannels(ctx, "cid1"); err != nil || n != 1 {
+		t.Fatalf("expect 1 event purged, got %d %v", n, err)
+	}
+	if _, err := os.Stat(full); !os.IsNotExist(err) {
+		t.Fatalf("expect snapshot removed, got %v", err)
+	}
+	// 录像目录与录像共用，不应被删除
+	if _, err := os.Stat(filepath.Dir(full)); err != nil {
+		t.Fatalf("expect recording day dir kept, got %v", err)
+	}
+}
+
+func writeSnapshot(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("jpg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
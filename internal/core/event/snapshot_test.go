@@ -0,0 +1,56 @@
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestFindRecentSnapshots(t *testing.T) {
+	core, store := newTestCore(t)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	events := []event.Event{
+		{CID: "cid1", Label: "a", ImagePath: "cid1/a.jpg", StartedAt: orm.Time{Time: base}},
+		{CID: "cid1", Label: "c", ImagePath: "cid1/c.jpg", StartedAt: orm.Time{Time: base.Add(2 * time.Minute)}},
+		{CID: "cid1", Label: "b", ImagePath: "cid1/b.jpg", StartedAt: orm.Time{Time: base.Add(time.Minute)}},
+		{CID: "cid1", Label: "noimage", StartedAt: orm.Time{Time: base.Add(3 * time.Minute)}},
+		{CID: "cid2", Label: "other", ImagePath: "cid2/o.jpg", StartedAt: orm.Time{Time: base.Add(4 * time.Minute)}},
+	}
+	for i := range events {
+		if err := store.Event().Add(ctx, &events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	labels := func(items []*event.Snapshot) []string {
+		out := make([]string, 0, len(items))
+		for _, v := range items {
+			out = append(out, v.Label)
+		}
+		return out
+	}
+
+	items, err := core.FindRecentSnapshots(ctx, "cid1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(items); len(got) != 3 || got[0] != "c" || got[1] != "b" || got[2] != "a" {
+		t.Fatalf("expect newest first with images only [c b a], got %v", got)
+	}
+
+	items, err = core.FindRecentSnapshots(ctx, "cid1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(items); len(got) != 2 || got[0] != "c" || got[1] != "b" {
+		t.Fatalf("expect limit 2 [c b], got %v", got)
+	}
+	if items[0].ImagePath != "cid1/c.jpg" || items[0].EventID == 0 {
+		t.Fatalf("unexpected snapshot %+v", items[0])
+	}
+}
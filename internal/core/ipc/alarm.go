@@ -0,0 +1,32 @@
+package ipc
+
+import "context"
+
+// ResetAlarmInput 报警复位参数，均为空时复位通道全部报警
+type ResetAlarmInput struct {
+	AlarmMethod string `json:"alarm_method"` // 报警方式，国标 1-电话 2-设备 3-短信 4-GPS 5-视频 6-设备故障 7-其它
+	AlarmType   string `json:"alarm_type"`   // 报警类型，取值随报警方式变化
+}
+
+// AlarmResetter 报警复位接口（可选实现）
+// 协议适配器实现此接口表示支持清除设备上锁存的报警
+type AlarmResetter interface {
+	ResetAlarm(ctx context.Context, device *Device, channel *Channel, in *ResetAlarmInput) error
+}
+
+// ResetAlarm 报警复位，按设备协议分发到对应的适配器，设备应答成功后返回
+func (c *Core) ResetAlarm(ctx context.Context, channelID string, in *ResetAlarmInput) error {
+	ch, err := c.GetChannel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	dev, err := c.GetDevice(ctx, ch.DID)
+	if err != nil {
+		return err
+	}
+	r, ok := c.GetProtocol(dev.GetType()).(AlarmResetter)
+	if !ok {
+		return ErrProtocolNotSupported.With("alarm reset is not supported")
+	}
+	return r.ResetAlarm(ctx, dev, ch, in)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
 	"github.com/jinzhu/copier"
 	"gorm.io/gorm"
 )
@@ -71,19 +72,65 @@ func (c *Core) FindChannel(ctx context.Context, in *FindChannelInput) ([]*Channe
 		query.Where("stream = ?", in.Stream)
 	}
 
+	// 按行政区划过滤，区划代码即国标编码前缀
+	// 仅接受数字，避免 % _ 等 LIKE 通配符扩大匹配范围
+	if in.CivilCode != "" {
+		if !validCivilCode(in.CivilCode) {
+			return nil, 0, reason.ErrBadRequest.SetMsg("civil_code 应为 2~8 位数字")
+		}
+		query.Where("channel_id LIKE ?", in.CivilCode+"%")
+	}
+
 	total, err := c.store.Channel().Find(ctx, &items, in, query.Encode()...)
 	if err != nil {
 		return nil, 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
 	}
+	for _, item := range items {
+		item.CivilCode = ParseCivilCode(item.ChannelID)
+	}
 	return items, total, nil
 }
 
+// GroupChannelsByCivilCode 按行政区划统计国标通道，无法解析区划的通道不参与分组
+func (c *Core) GroupChannelsByCivilCode(ctx context.Context) ([]*CivilCodeGroup, error) {
+	channels := make([]*Channel, 0, 100)
+	if _, err := c.store.Channel().Find(ctx, &channels, web.NewPagerFilterMaxSize(), orm.Where("channel_id != ''")); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+
+	groups := make(map[string]*CivilCodeGroup)
+	for _, ch := range channels {
+		code := ParseCivilCode(ch.ChannelID)
+		if code == "" {
+			continue
+		}
+		g, ok := groups[code]
+		if !ok {
+			g = &CivilCodeGroup{CivilCode: code}
+			groups[code] = g
+		}
+		g.Total++
+		if ch.IsOnline {
+			g.Online++
+		}
+	}
+
+	out := make([]*CivilCodeGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	slices.SortFunc(out, func(a, b *CivilCodeGroup) int {
+		return strings.Compare(a.CivilCode, b.CivilCode)
+	})
+	return out, nil
+}
+
 // GetChannel Query a single object
 func (c *Core) GetChannel(ctx context.Context, id string) (*Channel, error) {
 	var out Channel
 	if err := c.store.Channel().Get(ctx, &out, orm.Where("id=?", id)); err != nil {
 		if orm.IsErrRecordNotFound(err) {
-			return nil, reason.ErrNotFound.Withf(`Get err[%s]`, err.Error())
+			return nil, ErrChannelNotFound.Withf(`Get err[%s]`, err.Error())
 		}
 		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
 	}
@@ -107,6 +154,9 @@ func (c *Core) AddChannel(ctx context.Context, in *AddChannelInput) (*Channel, e
 	if strings.EqualFold(in.App, "rtp") {
 		return nil, reason.ErrBadRequest.SetMsg("app=rtp 为 GB28181 专用，RTMP/RTSP 不可使用")
 	}
+	if err := in.Config.ValidatePull(); err != nil {
+		return nil, err
+	}
 
 	var deviceID string
 
@@ -208,15 +258,37 @@ func (c *Core) EditChannel(ctx context.Context, in *EditChannelInput, id string)
 	if strings.EqualFold(in.App, "rtp") {
 		return nil, reason.ErrBadRequest.SetMsg("app=rtp 为 GB28181 专用，RTMP/RTSP 不可使用")
 	}
+	if err := in.Config.ValidatePull(); err != nil {
+		return nil, err
+	}
+	if in.Ext.PTZSpeed < 0 || in.Ext.PTZSpeed > PTZSpeedMax {
+		return nil, ErrPTZInvalidParam.Withf("ptz_speed must be between 0 and %d", PTZSpeedMax)
+	}
+	if in.Ext.NoneReaderDelay < 0 || in.Ext.NoneReaderDelay > NoneReaderDelayMax {
+		return nil, reason.ErrBadRequest.Withf("none_reader_delay must be between 0 and %d", NoneReaderDelayMax)
+	}
+	if in.Ext.DetectFPS < 0 || in.Ext.DetectFPS > DetectFPSMax {
+		return nil, reason.ErrBadRequest.Withf("detect_fps must be between 0 and %d", DetectFPSMax)
+	}
 
 	// TODO: 修改 onvif 的账号/密码 后需要重新连接设备
 	var out Channel
+	var zoneErr error
 	if err := c.store.Channel().Edit(ctx, &out, func(b *Channel) error {
+		// 仅校验本次提交且有变化的区域，历史数据中不合法的区域不影响修改名称等其它字段
+		if in.Ext.Zones != nil && !slices.EqualFunc(in.Ext.Zones, b.Ext.Zones, Zone.Equal) {
+			if zoneErr = ValidateZones(in.Ext.Zones); zoneErr != nil {
+				return zoneErr
+			}
+		}
 		if err := copier.Copy(b, in); err != nil {
 			slog.ErrorContext(ctx, "Copy", "err", err)
 		}
 		return nil
 	}, orm.Where("id=?", id)); err != nil {
+		if zoneErr != nil {
+			return nil, zoneErr
+		}
 		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
 	}
 	return &out, nil
@@ -243,6 +315,10 @@ func (c *Core) DelChannel(ctx context.Context, id string) (*Channel, error) {
 		}
 	}
 
+	if err := c.store.Preset().Del(ctx, new(Preset), orm.Where("cid=?", id)); err != nil {
+		slog.WarnContext(ctx, "删除通道预置位名称失败", "channelID", id, "err", err)
+	}
+
 	return &out, nil
 }
 
@@ -253,6 +329,9 @@ func (c *Core) AddZone(ctx context.Context, in *AddZoneInput, channelID string)
 		Color:       in.Color,
 		Labels:      in.Labels,
 	}
+	if err := newZone.Validate(); err != nil {
+		return nil, err
+	}
 
 	var out Channel
 	if err := c.store.Channel().Edit(ctx, &out, func(b *Channel) error {
@@ -273,6 +352,22 @@ func (c *Core) AddZone(ctx context.Context, in *AddZoneInput, channelID string)
 	return &newZone, nil
 }
 
+// SetZones 整体替换通道的区域配置，支持多个命名区域
+func (c *Core) SetZones(ctx context.Context, channelID string, zones []Zone) ([]Zone, error) {
+	if err := ValidateZones(zones); err != nil {
+		return nil, err
+	}
+
+	var out Channel
+	if err := c.store.Channel().Edit(ctx, &out, func(b *Channel) error {
+		b.Ext.Zones = zones
+		return nil
+	}, orm.Where("id=?", channelID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
+	}
+	return out.Ext.Zones, nil
+}
+
 func (c *Core) GetZones(ctx context.Context, channelID string) ([]Zone, error) {
 	var out Channel
 	if err := c.store.Channel().Get(ctx, &out, orm.Where("id=?", channelID)); err != nil {
@@ -383,6 +478,78 @@ func (c *Core) BatchOfflineRTMP(ctx context.Context) error {
 	return c.store.Channel().BatchEdit(ctx, "is_online", false, orm.Where("type=?", TypeRTMP))
 }
 
+// StreamKey 流媒体上在线的流
+type StreamKey struct {
+	App    string
+	Stream string
+}
+
+// ReconcilePlaying 按流媒体节点上实际在线的流校正通道播放状态，返回校正的通道数
+// onNode 判断通道是否使用该节点，其它节点的通道不受影响
+// RTMP 通道的在线状态取决于是否在推流，一并校正
+func (c *Core) ReconcilePlaying(ctx context.Context, onNode func(*Channel) bool, streams []StreamKey) (int, error) {
+	var channels []*Channel
+	if _, err := c.store.Channel().Find(ctx, &channels, web.NewPagerFilterMaxSize()); err != nil {
+		return 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+
+	var changed int
+	for _, ch := range reconcilePlaying(channels, onNode, streams) {
+		if err := c.store.Channel().Edit(ctx, &Channel{}, func(b *Channel) error {
+			b.IsPlaying = ch.IsPlaying
+			if b.IsRTMP() {
+				b.IsOnline = ch.IsPlaying
+			}
+			return nil
+		}, orm.Where("id=?", ch.ID)); err != nil {
+			slog.WarnContext(ctx, "校正通道播放状态失败", "id", ch.ID, "err", err)
+			continue
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// reconcilePlaying 返回播放状态与在线流不一致的通道，并已更新为实际状态
+// 通道按 app+stream 匹配，兼容按 stream=通道 ID 匹配；lalmax 不区分 app 时仅按 stream 匹配
+func reconcilePlaying(channels []*Channel, onNode func(*Channel) bool, streams []StreamKey) []*Channel {
+	keys := make(map[StreamKey]struct{}, len(streams))
+	names := make(map[string]struct{}, len(streams))   // 所有在线流的 stream
+	appless := make(map[string]struct{}, len(streams)) // 不区分 app 的流
+	for _, s := range streams {
+		keys[s] = struct{}{}
+		names[s.Stream] = struct{}{}
+		if s.App == "" {
+			appless[s.Stream] = struct{}{}
+		}
+	}
+	active := func(ch *Channel) bool {
+		if _, ok := names[ch.ID]; ok {
+			return true
+		}
+		if ch.Stream == "" {
+			return false
+		}
+		if _, ok := keys[StreamKey{App: ch.App, Stream: ch.Stream}]; ok {
+			return true
+		}
+		_, ok := appless[ch.Stream]
+		return ok
+	}
+
+	out := make([]*Channel, 0, 8)
+	for _, ch := range channels {
+		if onNode != nil && !onNode(ch) {
+			continue
+		}
+		if playing := active(ch); playing != ch.IsPlaying {
+			ch.IsPlaying = playing
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
 // EditChannelConfigByAppStream 通过 app+stream 更新通道配置
 func (c *Core) EditChannelConfigByAppStream(ctx context.Context, app, stream string, fn func(*StreamConfig)) (*Channel, error) {
 	var out Channel
@@ -416,21 +583,24 @@ func (c *Core) EditChannelConfigAndOnlineByAppStream(ctx context.Context, app, s
 
 // EditChannelPlaying 更新通道播放状态
 // 任何协议的流被播放或停止播放时都需要更新此状态
-func (c *Core) EditChannelPlaying(ctx context.Context, stream string, isPlaying bool) (*Channel, error) {
+// 与 GetChannelByAppStreamOrID 一致：先按 app+stream 查找自定义流的 RTMP/RTSP 通道，
+// 再按 id=stream 查找国标(app=rtp)及使用默认 ID 作为 stream 的通道
+func (c *Core) EditChannelPlaying(ctx context.Context, app, stream string, isPlaying bool) (*Channel, error) {
 	var out Channel
-	// 先按 stream 字段查找，再按 ID 查找
-	if err := c.store.Channel().Edit(ctx, &out, func(b *Channel) error {
+	editFn := func(b *Channel) error {
 		b.IsPlaying = isPlaying
 		return nil
-	}, orm.Where("stream=?", stream)); err == nil {
+	}
+	err := c.store.Channel().Edit(ctx, &out, editFn, orm.Where("app=? AND stream=?", app, stream))
+	if err == nil {
 		return &out, nil
 	}
-	if err := c.store.Channel().Edit(ctx, &out, func(b *Channel) error {
-		b.IsPlaying = isPlaying
-		return nil
-	}, orm.Where("id=?", stream)); err != nil {
+	if !orm.IsErrRecordNotFound(err) {
+		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
+	}
+	if err := c.store.Channel().Edit(ctx, &out, editFn, orm.Where("id=?", stream)); err != nil {
 		if orm.IsErrRecordNotFound(err) {
-			return nil, reason.ErrNotFound.Withf(`Channel not found stream[%s]`, stream)
+			return nil, reason.ErrNotFound.Withf(`Channel not found app[%s] stream[%s]`, app, stream)
 		}
 		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
 	}
@@ -29,7 +29,11 @@ type Channel struct {
 	Config StreamConfig `gorm:"column:config;notNull;default:'{}';type:jsonb" json:"config"`       // 流配置 (RTMP/RTSP)
 
 	// 非持久化字段，用于 API 响应
-	HasRecording bool `gorm:"-" json:"has_recording"` // 是否存在录像（查询时动态填充）
+	HasRecording bool   `gorm:"-" json:"has_recording"`        // 是否存在录像（查询时动态填充）
+	CivilCode    string `gorm:"-" json:"civil_code,omitempty"` // 行政区划代码（由国标编码解析）
+
+	LastFrameAt  *orm.Time `gorm:"-" json:"last_frame_at,omitempty"` // 最近一次确认有画面的时间（抓拍成功、流注册、画质查询）
+	IsFrameStale bool      `gorm:"-" json:"is_frame_stale"`          // 在线但超过 FrameStaleAfter 未收到画面
 }
 
 // TableName database table name
@@ -50,6 +54,38 @@ func (c *Channel) GetGB28181DeviceID() string {
 	return c.DeviceID
 }
 
+// validCivilCode 行政区划代码为 2~8 位数字
+func validCivilCode(code string) bool {
+	if len(code) < 2 || len(code) > 8 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCivilCode 解析国标编码中的行政区划代码
+// 标准 20 位编码前 8 位为中心编码（省、市、区县、基层单位各 2 位）
+// 18~19 位等非标准编码只取前 6 位区县级代码，含非数字字符时返回空串
+func ParseCivilCode(gbID string) string {
+	n := len(gbID)
+	if n < 18 || n > 20 {
+		return ""
+	}
+	for _, r := range gbID {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	if n == 20 {
+		return gbID[:8]
+	}
+	return gbID[:6]
+}
+
 func (c *Channel) IsOnvif() bool {
 	return c.Type == TypeOnvif || bz.IsOnvif(c.ID)
 }
@@ -12,6 +12,15 @@ type FindChannelInput struct {
 	Type     string `form:"type"`      // 通道类型 (GB28181/ONVIF/RTMP/RTSP)
 	App      string `form:"app"`       // 应用名
 	Stream   string `form:"stream"`    // 流 ID
+
+	CivilCode string `form:"civil_code"` // 行政区划代码前缀，如省级 34、市级 3402
+}
+
+// CivilCodeGroup 按行政区划分组的通道统计
+type CivilCodeGroup struct {
+	CivilCode string `json:"civil_code"` // 行政区划代码
+	Total     int    `json:"total"`      // 通道数
+	Online    int    `json:"online"`     // 在线通道数
 }
 
 type EditChannelInput struct {
@@ -47,3 +56,8 @@ type AddZoneInput struct {
 	Labels      []string  `json:"labels"`      // 标签
 	ChannelID   string    `json:"-"`           // 通道 id
 }
+
+// SetZonesInput 整体替换通道区域
+type SetZonesInput struct {
+	Zones []Zone `json:"zones"` // 区域列表，空表示清除全部区域
+}
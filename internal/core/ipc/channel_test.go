@@ -0,0 +1,159 @@
+package ipc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+func TestReconcilePlaying(t *testing.T) {
+	channels := []*ipc.Channel{
+		{ID: "rtmp1", Type: ipc.TypeRTMP, App: "push", Stream: "cam1", IsPlaying: false}, // 实际在推流
+		{ID: "rtsp1", Type: ipc.TypeRTSP, App: "pull", Stream: "rtsp1", IsPlaying: true}, // 重连后已断开
+		{ID: "34020000001320000001", Type: ipc.TypeGB28181, IsPlaying: false},            // 按 stream=通道 ID 匹配
+		{ID: "rtsp2", Type: ipc.TypeRTSP, App: "pull", Stream: "rtsp2", IsPlaying: true}, // 状态一致
+		{ID: "rtsp3", Type: ipc.TypeRTSP, App: "pull", Stream: "rtsp3", IsPlaying: true, // 其它节点
+			Config: ipc.StreamConfig{MediaServerID: "node2"}},
+	}
+	streams := []ipc.StreamKey{
+		{App: "push", Stream: "cam1"},
+		{App: "rtp", Stream: "34020000001320000001"},
+		{App: "pull", Stream: "rtsp2"},
+	}
+	onNode := func(ch *ipc.Channel) bool { return ch.Config.MediaServerID == "" }
+
+	changed := ipc.ReconcilePlaying(channels, onNode, streams)
+	got := make(map[string]bool, len(changed))
+	for _, ch := range changed {
+		got[ch.ID] = ch.IsPlaying
+	}
+	expect := map[string]bool{"rtmp1": true, "rtsp1": false, "34020000001320000001": true}
+	if len(got) != len(expect) {
+		t.Fatalf("expect changed %v, got %v", expect, got)
+	}
+	for id, playing := range expect {
+		if v, ok := got[id]; !ok || v != playing {
+			t.Fatalf("channel %s expect playing=%v, got %v (changed=%v)", id, playing, v, ok)
+		}
+	}
+	if !channels[4].IsPlaying {
+		t.Fatal("channel on other node should not be touched")
+	}
+
+	// lalmax 不区分 app，仅按 stream 匹配
+	ch := &ipc.Channel{ID: "rtsp9", App: "pull", Stream: "s9"}
+	if changed := ipc.ReconcilePlaying([]*ipc.Channel{ch}, nil, []ipc.StreamKey{{Stream: "s9"}}); len(changed) != 1 || !ch.IsPlaying {
+		t.Fatal("expect appless stream matched by stream name")
+	}
+}
+
+func TestEditChannelPlaying(t *testing.T) {
+	core, store := newTestCore(t, nil)
+	ctx := context.Background()
+
+	channels := []*ipc.Channel{
+		{ID: "ch34020000001320000001", Type: ipc.TypeGB28181},
+		{ID: "rtmp1", Type: ipc.TypeRTMP, App: "push", Stream: "cam"},
+		{ID: "rtmp2", Type: ipc.TypeRTMP, App: "push2", Stream: "cam"}, // 与 rtmp1 同名 stream，不同 app
+		{ID: "rtsp1", Type: ipc.TypeRTSP, App: "pull", Stream: "door"},
+		{ID: "rtsp2", Type: ipc.TypeRTSP, App: "pull", Stream: ""}, // 旧通道使用 ID 作为 stream
+		{ID: "onvif1", Type: ipc.TypeOnvif},
+	}
+	for _, ch := range channels {
+		if err := store.Channel().Add(ctx, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		app, stream string
+		expect      string
+	}{
+		{name: "gb28181 by id", app: "rtp", stream: "ch34020000001320000001", expect: "ch34020000001320000001"},
+		{name: "rtmp custom stream", app: "push2", stream: "cam", expect: "rtmp2"},
+		{name: "rtsp custom stream", app: "pull", stream: "door", expect: "rtsp1"},
+		{name: "rtsp legacy id stream", app: "pull", stream: "rtsp2", expect: "rtsp2"},
+		{name: "onvif by id", app: "live", stream: "onvif1", expect: "onvif1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := core.EditChannelPlaying(ctx, tt.app, tt.stream, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.ID != tt.expect || !out.IsPlaying {
+				t.Fatalf("expect %s playing, got %s playing=%v", tt.expect, out.ID, out.IsPlaying)
+			}
+		})
+	}
+
+	rtmp1, err := core.GetChannel(ctx, "rtmp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtmp1.IsPlaying {
+		t.Fatal("channel with same stream but different app should not be updated")
+	}
+	if _, err := core.EditChannelPlaying(ctx, "push", "missing", true); err == nil {
+		t.Fatal("expect not found error")
+	}
+}
+
+func TestEditChannelLegacyZones(t *testing.T) {
+	core, store := newTestCore(t, nil)
+	ctx := context.Background()
+
+	// 校验上线前保存的区域，坐标不足 3 个点
+	legacy := []ipc.Zone{{Name: "door", Coordinates: []float32{0.1, 0.1, 0.2, 0.2}}}
+	if err := store.Channel().Add(ctx, &ipc.Channel{ID: "rtsp1", Type: ipc.TypeRTSP, Name: "old", Ext: ipc.DeviceExt{Zones: legacy}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未提交区域或原样回传区域时可修改其它字段
+	for _, zones := range [][]ipc.Zone{legacy, nil} {
+		out, err := core.EditChannel(ctx, &ipc.EditChannelInput{Name: "new", Ext: ipc.DeviceExt{Zones: zones}}, "rtsp1")
+		if err != nil {
+			t.Fatalf("zones %v: %v", zones, err)
+		}
+		if out.Name != "new" {
+			t.Fatalf("expect name updated, got %s", out.Name)
+		}
+	}
+
+	// 修改后的区域仍需校验
+	changed := []ipc.Zone{{Name: "door", Coordinates: []float32{0.1, 0.1, 0.3, 0.3}}}
+	if _, err := core.EditChannel(ctx, &ipc.EditChannelInput{Name: "new", Ext: ipc.DeviceExt{Zones: changed}}, "rtsp1"); err == nil {
+		t.Fatal("expect invalid zones rejected")
+	}
+}
+
+func TestFindChannelCivilCode(t *testing.T) {
+	core, store := newTestCore(t, nil)
+	ctx := context.Background()
+	for _, id := range []string{"34020000001320000001", "35020000001320000001"} {
+		if err := store.Channel().Add(ctx, &ipc.Channel{ID: id, ChannelID: id, Type: ipc.TypeGB28181}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, _, err := core.FindChannel(ctx, &ipc.FindChannelInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, CivilCode: "3402"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != "34020000001320000001" {
+		t.Fatalf("expect only 3402 channel, got %+v", items)
+	}
+
+	// 通配符与非法长度不能作为前缀匹配
+	for _, code := range []string{"%", "3_", "34%", "3", "340200001", "34a2"} {
+		_, _, err := core.FindChannel(ctx, &ipc.FindChannelInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, CivilCode: code})
+		if !errors.Is(err, reason.ErrBadRequest) {
+			t.Fatalf("civil_code %q: expect ErrBadRequest, got %v", code, err)
+		}
+	}
+}
@@ -2,20 +2,26 @@
 package ipc
 
 import (
+	"time"
+
 	"github.com/ixugo/goddd/domain/uniqueid"
+	"github.com/ixugo/goddd/pkg/conc"
 )
 
 // Storer data persistence
 type Storer interface {
 	Device() DeviceStorer
 	Channel() ChannelStorer
+	Preset() PresetStorer
+	DeviceStateLog() DeviceStateLogStorer
 }
 
 // Core business domain
 type Core struct {
 	store     Storer
 	uniqueID  uniqueid.Core
-	protocols map[string]Protocoler // 协议映射（Protocol 在同一个包内）
+	protocols map[string]Protocoler        // 协议映射（Protocol 在同一个包内）
+	frames    *conc.Map[string, time.Time] // 通道最近一次确认有画面的时间，仅保存在内存
 }
 
 // NewCore create business domain
@@ -24,6 +30,7 @@ func NewCore(store Storer, uni uniqueid.Core, protocols map[string]Protocoler) C
 		store:     store,
 		uniqueID:  uni,
 		protocols: protocols,
+		frames:    conc.NewMap[string, time.Time](),
 	}
 }
 
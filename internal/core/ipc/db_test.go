@@ -0,0 +1,28 @@
+package ipc_test
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/ipc/store/ipcdb"
+	"github.com/ixugo/goddd/domain/uniqueid"
+	"gorm.io/gorm"
+)
+
+// newTestDB 打开内存 sqlite 并迁移 ipc 相关表
+func newTestDB(t *testing.T) (*gorm.DB, ipc.Storer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, ipcdb.NewDB(db).AutoMigrate(true)
+}
+
+// newTestCore 基于内存 sqlite 创建 Core，返回 store 用于准备数据
+func newTestCore(t *testing.T, protocols map[string]ipc.Protocoler) (ipc.Core, ipc.Storer) {
+	t.Helper()
+	_, store := newTestDB(t)
+	return ipc.NewCore(store, uniqueid.Core{}, protocols), store
+}
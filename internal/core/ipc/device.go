@@ -3,7 +3,11 @@ package ipc
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
@@ -77,7 +81,7 @@ func (c Core) GetDevice(ctx context.Context, id string) (*Device, error) {
 	var out Device
 	if err := c.store.Device().Get(ctx, &out, orm.Where("id=?", id)); err != nil {
 		if orm.IsErrRecordNotFound(err) {
-			return nil, reason.ErrNotFound.Withf(`Get err[%s]`, err.Error())
+			return nil, ErrDeviceNotFound.Withf(`Get err[%s]`, err.Error())
 		}
 		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
 	}
@@ -88,13 +92,70 @@ func (c Core) GetDeviceByDeviceID(ctx context.Context, deviceID string) (*Device
 	var out Device
 	if err := c.store.Device().Get(ctx, &out, orm.Where("device_id=?", deviceID)); err != nil {
 		if orm.IsErrRecordNotFound(err) {
-			return nil, reason.ErrNotFound.Withf(`Get err[%s]`, err.Error())
+			return nil, ErrDeviceNotFound.Withf(`Get err[%s]`, err.Error())
 		}
 		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
 	}
 	return &out, nil
 }
 
+// ValidateDevice 按设备类型分发到协议适配器校验连接参数，返回补全厂商/型号/固件后的设备信息，不落库
+func (c Core) ValidateDevice(ctx context.Context, in *AddDeviceInput) (*Device, error) {
+	var out Device
+	if err := copier.Copy(&out, in); err != nil {
+		slog.ErrorContext(ctx, "Copy", "err", err)
+	}
+	out.Type = strings.ToUpper(in.Type)
+	if !slices.Contains([]string{TypeGB28181, TypeOnvif, TypeRTSP}, out.Type) {
+		return nil, ErrProtocolNotSupported.Withf("type[%s]", in.Type)
+	}
+	protocol, ok := c.protocols[out.Type]
+	if !ok {
+		return nil, ErrProtocolNotSupported.Withf("type[%s]", in.Type)
+	}
+	if err := validateDevice(ctx, protocol, &out); err != nil {
+		return nil, err
+	}
+	// 校验结果会返回给前端，不回显密码
+	out.Password = ""
+	return &out, nil
+}
+
+// ValidateDeviceTimeout 协议校验设备的最长时间，请求上下文的截止时间更早时以其为准
+const ValidateDeviceTimeout = 10 * time.Second
+
+// validateDevice 协议校验失败统一转换为 ErrDeviceValidateFailed，已带错误码的错误原样返回
+// 部分协议的校验可能不响应 ctx，因此在协程中执行，超时或取消后立即返回 ErrDeviceValidateTimeout
+// 协程操作设备副本，仅在校验完成时回写，避免超时后仍修改调用方的设备
+func validateDevice(ctx context.Context, protocol Protocoler, dev *Device) error {
+	ctx, cancel := context.WithTimeout(ctx, ValidateDeviceTimeout)
+	defer cancel()
+
+	tmp := *dev
+	done := make(chan error, 1)
+	go func() {
+		done <- protocol.ValidateDevice(ctx, &tmp)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return ErrDeviceValidateTimeout.Withf("err[%s]", ctx.Err())
+	}
+	if err == nil {
+		*dev = tmp
+		return nil
+	}
+	if reason.IsCustomError(err) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrDeviceValidateTimeout.Withf("err[%s]", err)
+	}
+	return ErrDeviceValidateFailed.SetMsg(err.Error())
+}
+
 // AddDevice Insert into database
 func (c Core) AddDevice(ctx context.Context, in *AddDeviceInput) (*Device, error) {
 	var out Device
@@ -104,8 +165,8 @@ func (c Core) AddDevice(ctx context.Context, in *AddDeviceInput) (*Device, error
 
 	// 协议验证（通过接口调用）
 	if protocol, ok := c.protocols[out.GetType()]; ok {
-		if err := protocol.ValidateDevice(ctx, &out); err != nil {
-			return nil, reason.ErrBadRequest.SetMsg(err.Error())
+		if err := validateDevice(ctx, protocol, &out); err != nil {
+			return nil, err
 		}
 	}
 
@@ -115,6 +176,9 @@ func (c Core) AddDevice(ctx context.Context, in *AddDeviceInput) (*Device, error
 	} else {
 		out.Username = out.DeviceID
 	}
+	out.Ext.AuthDisabled = in.AuthDisabled
+	out.Ext.SDPFormats = in.SDPFormats
+	out.normalizeAuth()
 
 	if err := out.Check(); err != nil {
 		return nil, reason.ErrBadRequest.SetMsg(err.Error())
@@ -138,6 +202,30 @@ func (c Core) AddDevice(ctx context.Context, in *AddDeviceInput) (*Device, error
 	return &out, nil
 }
 
+// EditDeviceStreamMode 修改国标设备的媒体传输模式，下次点播时生效
+func (c Core) EditDeviceStreamMode(ctx context.Context, id string, in *EditStreamModeInput) (*Device, error) {
+	mode := *in.StreamMode
+	if mode < StreamModeUDP || mode > StreamModeTCPActive {
+		return nil, reason.ErrBadRequest.Withf("stream_mode must be one of 0(UDP), 1(TCP_PASSIVE), 2(TCP_ACTIVE)")
+	}
+	dev, err := c.GetDevice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !dev.IsGB28181() {
+		return nil, ErrProtocolNotSupported.SetMsg("仅国标设备支持设置传输模式")
+	}
+
+	var out Device
+	if err := c.store.Device().Edit(ctx, &out, func(b *Device) error {
+		b.StreamMode = mode
+		return nil
+	}, orm.Where("id=?", id)); err != nil {
+		return nil, reason.ErrDB.Withf(`Edit err[%s] id[%s]`, err.Error(), id)
+	}
+	return &out, nil
+}
+
 // EditDevice Update object information
 func (c Core) EditDevice(ctx context.Context, in *EditDeviceInput, id string) (*Device, error) {
 	var out Device
@@ -145,10 +233,30 @@ func (c Core) EditDevice(ctx context.Context, in *EditDeviceInput, id string) (*
 		if err := copier.Copy(b, in); err != nil {
 			slog.ErrorContext(ctx, "Copy", "err", err)
 		}
+		if in.SDPFormats != nil {
+			b.Ext.SDPFormats = *in.SDPFormats
+		}
+		if in.SSRCPrefix != nil {
+			b.Ext.SSRCPrefix = *in.SSRCPrefix
+		}
+		if in.SSRCFormat != nil {
+			b.Ext.SSRCFormat = *in.SSRCFormat
+		}
+		if in.AuthDisabled != nil {
+			b.Ext.AuthDisabled = *in.AuthDisabled
+			// 关闭免鉴权时清掉历史的 "#"，改由密码或全局配置鉴权
+			if !b.Ext.AuthDisabled && b.Password == PasswordNoAuth {
+				b.Password = ""
+			}
+		}
+		b.normalizeAuth()
+		if err := b.checkAuth(); err != nil {
+			return reason.ErrBadRequest.SetMsg(err.Error())
+		}
 
 		protocol, ok := c.protocols[out.GetType()]
 		if ok {
-			if err := protocol.ValidateDevice(ctx, &out); err != nil {
+			if err := validateDevice(ctx, protocol, b); err != nil {
 				slog.WarnContext(ctx, "验证协议失败", "err", err, "device_id", out.ID)
 				return err
 			}
@@ -156,6 +264,9 @@ func (c Core) EditDevice(ctx context.Context, in *EditDeviceInput, id string) (*
 
 		return nil
 	}, orm.Where("id=?", id)); err != nil {
+		if reason.IsCustomError(err) {
+			return nil, err
+		}
 		return nil, reason.ErrDB.Withf(`Edit err[%s] id[%s]`, err.Error(), id)
 	}
 
@@ -169,27 +280,27 @@ func (c Core) EditDevice(ctx context.Context, in *EditDeviceInput, id string) (*
 }
 
 // DelDevice Delete object
+// 先由协议结束设备下的点播，再删除设备与通道，协议清理失败不阻断删除
 func (c Core) DelDevice(ctx context.Context, id string) (*Device, error) {
+	if dev, err := c.GetDevice(ctx, id); err == nil {
+		if protocol, ok := c.protocols[dev.GetType()]; ok {
+			if err := protocol.DeleteDevice(ctx, dev); err != nil {
+				slog.WarnContext(ctx, "删除设备前清理协议资源失败", "err", err, "device_id", id)
+			}
+		}
+	}
+
 	var dev Device
 	if err := c.store.Device().Del(ctx, &dev, orm.Where("id=?", id)); err != nil {
 		return nil, reason.ErrDB.Withf(`Del err[%s]`, err.Error())
 	}
 
-	if err := c.store.Channel().Session(ctx, func(tx *gorm.DB) error {
-		if err := orm.Delete(tx, &dev, orm.Where("id=?", id)); err != nil {
-			return err
-		}
-		if protocol, ok := c.protocols[dev.GetType()]; ok {
-			if err := protocol.DeleteDevice(ctx, &dev); err != nil {
-				return err
-			}
-		}
-		return nil
-	}, func(d *gorm.DB) error {
+	if err := c.store.Channel().Session(ctx, func(d *gorm.DB) error {
 		return d.Where("did=?", id).Delete(&Channel{}).Error
 	}); err != nil {
 		return nil, reason.ErrDB.Withf(`DelChannel err[%s]`, err.Error())
 	}
+	c.delDeviceState(ctx, id)
 
 	return &dev, nil
 }
@@ -199,8 +310,39 @@ func (c Core) QueryCatalog(ctx context.Context, deviceID string) error {
 	if err != nil {
 		return err
 	}
-	if err := c.protocols[device.GetType()].QueryCatalog(ctx, device); err != nil {
+	protocol, ok := c.protocols[device.GetType()]
+	if !ok {
+		return ErrProtocolNotSupported.Withf("type[%s]", device.GetType())
+	}
+	if err := protocol.QueryCatalog(ctx, device); err != nil {
+		if reason.IsCustomError(err) {
+			return err
+		}
 		return reason.ErrBadRequest.SetMsg(err.Error())
 	}
 	return nil
 }
+
+// MergeOnlineState 以协议内存中的在线状态覆盖数据库状态，每种协议只取一次快照
+func (c Core) MergeOnlineState(devices []*Device) {
+	snapshots := make(map[string]map[string]bool, 2)
+	for _, d := range devices {
+		protocol, key := TypeGB28181, d.GetGB28181DeviceID()
+		if d.IsOnvif() {
+			protocol, key = TypeOnvif, d.ID
+		} else if !d.IsGB28181() {
+			continue
+		}
+
+		snapshot, ok := snapshots[protocol]
+		if !ok {
+			if p, ok := c.protocols[protocol].(OnlineSnapshoter); ok {
+				snapshot = p.OnlineSnapshot()
+			}
+			snapshots[protocol] = snapshot
+		}
+		if online, ok := snapshot[key]; ok {
+			d.IsOnline = online
+		}
+	}
+}
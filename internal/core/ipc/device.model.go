@@ -9,6 +9,16 @@ import (
 	"github.com/ixugo/goddd/pkg/orm"
 )
 
+// PasswordNoAuth 历史约定的免鉴权密码，新数据应使用 DeviceExt.AuthDisabled
+const PasswordNoAuth = "#"
+
+// 国标点播的媒体传输模式，决定 SDP 中的传输协议与 TCP 连接发起方
+const (
+	StreamModeUDP        int8 = 0 // UDP
+	StreamModeTCPPassive int8 = 1 // TCP 被动，设备主动连接平台
+	StreamModeTCPActive  int8 = 2 // TCP 主动，平台主动连接设备
+)
+
 // Device domain model
 type Device struct {
 	ID   string `gorm:"primaryKey" json:"id"`
@@ -56,6 +66,9 @@ func (d Device) Check() error {
 	if d.IsGB28181() && len(d.Username) < 18 {
 		return fmt.Errorf("国标 ID 长度应大于等于 18 位")
 	}
+	if err := d.checkAuth(); err != nil {
+		return err
+	}
 	if d.IsOnvif() {
 		if d.Username == "" {
 			return fmt.Errorf("用户名不能为空")
@@ -73,6 +86,27 @@ func (d Device) Check() error {
 	return nil
 }
 
+// normalizeAuth 将密码 "#" 转为免鉴权标记，避免密码字段承担两种含义
+func (d *Device) normalizeAuth() {
+	if d.IsGB28181() && d.Password == PasswordNoAuth {
+		d.Password = ""
+		d.Ext.AuthDisabled = true
+	}
+}
+
+// checkAuth 免鉴权与密码互斥，同时设置时无法判断使用者的本意
+func (d *Device) checkAuth() error {
+	if d.IsGB28181() && d.Ext.AuthDisabled && d.Password != "" && d.Password != PasswordNoAuth {
+		return fmt.Errorf("已关闭注册鉴权，请勿同时设置密码")
+	}
+	return nil
+}
+
+// IsAuthDisabled 国标设备注册是否免鉴权，兼容密码为 "#" 的历史数据
+func (d *Device) IsAuthDisabled() bool {
+	return d.Ext.AuthDisabled || d.Password == PasswordNoAuth
+}
+
 func (d *Device) init(id, gbid string) {
 	d.ID = id
 	d.DeviceID = gbid
@@ -34,6 +34,14 @@ type EditDeviceInput struct {
 	IP       string `json:"ip"`       // ip
 	Port     int    `json:"port"`     // port
 
+	// nil 表示不修改，空数组表示恢复使用全局配置
+	SDPFormats *[]string `json:"sdp_formats"` // 国标点播 SDP 媒体格式
+	// nil 表示不修改，空串表示恢复使用全局配置
+	SSRCPrefix *string `json:"ssrc_prefix"` // 国标点播 SSRC 前缀
+	SSRCFormat *string `json:"ssrc_format"` // 国标点播 SSRC 格式
+	// nil 表示不修改
+	AuthDisabled *bool `json:"auth_disabled"` // 国标注册免鉴权
+
 	// IP           string    `json:"ip"`
 	// Port         int       `json:"port"`
 	// IsOnline     bool      `json:"is_online"`
@@ -45,6 +53,11 @@ type EditDeviceInput struct {
 	// Ext          DeviceExt `json:"ext"`           // 设备属性
 }
 
+// EditStreamModeInput 修改国标设备的媒体传输模式
+type EditStreamModeInput struct {
+	StreamMode *int8 `json:"stream_mode" binding:"required"` // 0:UDP 1:TCP_PASSIVE 2:TCP_ACTIVE
+}
+
 // 目前仅应用于 onvif 添加
 type AddDeviceInput struct {
 	DeviceID string `json:"device_id"` // 20 位国标编号
@@ -56,6 +69,9 @@ type AddDeviceInput struct {
 	Name     string `json:"name"`     // 设备名称
 	Password string `json:"password"` // 注册密码
 
+	AuthDisabled bool     `json:"auth_disabled"` // 国标注册免鉴权
+	SDPFormats   []string `json:"sdp_formats"`   // 国标点播 SDP 媒体格式，为空使用全局配置
+
 	Type string `json:"type"` // 设备类型(ONVIF/GB28181)
 
 	// Addr     string `json:"addr"`     // 地址(ip:port)
@@ -0,0 +1,112 @@
+package ipc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+	"gorm.io/gorm"
+)
+
+// 设备上下线原因
+const (
+	DeviceStateRegister         = "register"          // 国标注册
+	DeviceStateUnregister       = "unregister"        // 国标注销
+	DeviceStateRegisterTimeout  = "register_timeout"  // 国标注册后未收到心跳且超时
+	DeviceStateKeepaliveTimeout = "keepalive_timeout" // 连续丢失心跳或连接断开
+	DeviceStateKeepalive        = "keepalive"         // ONVIF 心跳恢复
+)
+
+// 上下线记录的保留策略，频繁掉线的设备按条数截断
+var (
+	deviceStateRetention = 30 * 24 * time.Hour
+	deviceStateMaxRows   = 1000
+)
+
+// DeviceStateLogStorer Instantiation interface
+type DeviceStateLogStorer interface {
+	Find(context.Context, *[]*DeviceStateLog, orm.Pager, ...orm.QueryOption) (int64, error)
+	Get(context.Context, *DeviceStateLog, ...orm.QueryOption) error
+	Add(context.Context, *DeviceStateLog) error
+	Del(context.Context, *DeviceStateLog, ...orm.QueryOption) error
+}
+
+// FindDeviceHistoryInput 设备上下线记录查询条件
+type FindDeviceHistoryInput struct {
+	From int64 `form:"from"` // 开始时间 (毫秒时间戳)
+	To   int64 `form:"to"`   // 结束时间 (毫秒时间戳)
+}
+
+// FindDeviceHistory 按时间升序返回设备的上下线记录
+func (c Core) FindDeviceHistory(ctx context.Context, did string, in *FindDeviceHistoryInput) ([]*DeviceStateLog, error) {
+	if _, err := c.GetDevice(ctx, did); err != nil {
+		return nil, err
+	}
+	query := orm.NewQuery(3).Where("did = ?", did).OrderBy("created_at ASC, id ASC")
+	if in.From > 0 {
+		query.Where("created_at >= ?", orm.Time{Time: time.UnixMilli(in.From)})
+	}
+	if in.To > 0 {
+		query.Where("created_at <= ?", orm.Time{Time: time.UnixMilli(in.To)})
+	}
+	items := make([]*DeviceStateLog, 0, 16)
+	pager := web.PagerFilter{Page: 1, Size: deviceStateMaxRows}
+	if _, err := c.store.DeviceStateLog().Find(ctx, &items, pager, query.Encode()...); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	return items, nil
+}
+
+// RecordDeviceState 记录设备上下线，与最近一条记录状态相同时忽略，重复注册不会产生记录
+func (g Adapter) RecordDeviceState(ctx context.Context, did string, online bool, why string) {
+	if did == "" {
+		return
+	}
+	store := g.store.DeviceStateLog()
+	last := make([]*DeviceStateLog, 0, 1)
+	if _, err := store.Find(ctx, &last, web.PagerFilter{Page: 1, Size: 1}, orm.Where("did = ?", did), orderByIDDesc); err != nil {
+		slog.ErrorContext(ctx, "查询设备上下线记录失败", "err", err, "did", did)
+		return
+	}
+	if len(last) > 0 && last[0].IsOnline == online {
+		return
+	}
+	if err := store.Add(ctx, &DeviceStateLog{DID: did, IsOnline: online, Reason: why, CreatedAt: orm.Now()}); err != nil {
+		slog.ErrorContext(ctx, "写入设备上下线记录失败", "err", err, "did", did)
+		return
+	}
+	g.pruneDeviceState(ctx, did)
+}
+
+// pruneDeviceState 删除超出保留时长或条数的记录
+func (g Adapter) pruneDeviceState(ctx context.Context, did string) {
+	store := g.store.DeviceStateLog()
+	expired := orm.Time{Time: time.Now().Add(-deviceStateRetention)}
+	if err := store.Del(ctx, new(DeviceStateLog), orm.Where("did = ? AND created_at < ?", did, expired)); err != nil {
+		slog.WarnContext(ctx, "清理过期设备上下线记录失败", "err", err, "did", did)
+	}
+
+	// 第 max+1 条及更早的记录
+	oldest := make([]*DeviceStateLog, 0, 1)
+	pager := web.PagerFilter{Page: deviceStateMaxRows + 1, Size: 1}
+	if _, err := store.Find(ctx, &oldest, pager, orm.Where("did = ?", did), orderByIDDesc); err != nil || len(oldest) == 0 {
+		return
+	}
+	if err := store.Del(ctx, new(DeviceStateLog), orm.Where("did = ? AND id <= ?", did, oldest[0].ID)); err != nil {
+		slog.WarnContext(ctx, "截断设备上下线记录失败", "err", err, "did", did)
+	}
+}
+
+// delDeviceState 删除设备时清理其上下线记录
+func (c Core) delDeviceState(ctx context.Context, did string) {
+	if err := c.store.DeviceStateLog().Del(ctx, new(DeviceStateLog), orm.Where("did = ?", did)); err != nil {
+		slog.WarnContext(ctx, "删除设备上下线记录失败", "err", err, "did", did)
+	}
+}
+
+func orderByIDDesc(db *gorm.DB) *gorm.DB {
+	return db.Order("id DESC")
+}
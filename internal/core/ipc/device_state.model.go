@@ -0,0 +1,17 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/orm"
+
+// DeviceStateLog 设备上下线记录，仅在状态变化时写入
+type DeviceStateLog struct {
+	ID        int64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	DID       string   `gorm:"column:did;notNull;default:'';index:idx_device_state_logs_did_created;comment:设备 ID" json:"did"`                             // 设备 ID
+	IsOnline  bool     `gorm:"column:is_online;notNull;default:FALSE;comment:变化后的在线状态" json:"is_online"`                                                   // 变化后的在线状态
+	Reason    string   `gorm:"column:reason;notNull;default:'';comment:变化原因" json:"reason"`                                                                // 变化原因
+	CreatedAt orm.Time `gorm:"column:created_at;notNull;default:CURRENT_TIMESTAMP;index:idx_device_state_logs_did_created;comment:变化时间" json:"created_at"` // 变化时间
+}
+
+// TableName database table name
+func (*DeviceStateLog) TableName() string {
+	return "device_state_logs"
+}
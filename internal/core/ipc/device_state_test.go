@@ -0,0 +1,74 @@
+package ipc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/domain/uniqueid"
+)
+
+func TestDeviceStateHistory(t *testing.T) {
+	db, store := newTestDB(t)
+	adapter := ipc.NewAdapter(store, uniqueid.Core{})
+	core := ipc.NewCore(store, uniqueid.Core{}, map[string]ipc.Protocoler{})
+	ctx := context.Background()
+
+	if err := store.Device().Add(ctx, &ipc.Device{ID: "gb1", DeviceID: "34020000001110000001", Type: ipc.TypeGB28181}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟注册、重复注册、心跳超时、再次注册、注销
+	transitions := []struct {
+		online bool
+		why    string
+	}{
+		{true, ipc.DeviceStateRegister},
+		{true, ipc.DeviceStateRegister},
+		{false, ipc.DeviceStateKeepaliveTimeout},
+		{true, ipc.DeviceStateRegister},
+		{false, ipc.DeviceStateUnregister},
+	}
+	for _, v := range transitions {
+		adapter.RecordDeviceState(ctx, "gb1", v.online, v.why)
+	}
+
+	items, err := core.FindDeviceHistory(ctx, "gb1", &ipc.FindDeviceHistoryInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		online bool
+		why    string
+	}{
+		{true, ipc.DeviceStateRegister},
+		{false, ipc.DeviceStateKeepaliveTimeout},
+		{true, ipc.DeviceStateRegister},
+		{false, ipc.DeviceStateUnregister},
+	}
+	if len(items) != len(expect) {
+		t.Fatalf("expect %d rows, got %d", len(expect), len(items))
+	}
+	for i, v := range expect {
+		if items[i].IsOnline != v.online || items[i].Reason != v.why {
+			t.Fatalf("row %d expect %v/%s, got %v/%s", i, v.online, v.why, items[i].IsOnline, items[i].Reason)
+		}
+		if i > 0 && items[i].ID <= items[i-1].ID {
+			t.Fatalf("rows not in order: %d after %d", items[i].ID, items[i-1].ID)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UnixMilli()
+	if items, err := core.FindDeviceHistory(ctx, "gb1", &ipc.FindDeviceHistoryInput{From: future}); err != nil || len(items) != 0 {
+		t.Fatalf("expect no rows after %d, got %d %v", future, len(items), err)
+	}
+
+	if _, err := core.DelDevice(ctx, "gb1"); err != nil {
+		t.Fatal(err)
+	}
+	var remain int64
+	if err := db.Model(&ipc.DeviceStateLog{}).Count(&remain).Error; err != nil || remain != 0 {
+		t.Fatalf("expect history removed with device, got %d %v", remain, err)
+	}
+}
@@ -0,0 +1,163 @@
+package ipc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// slowValidator 校验一直阻塞，直到 release 关闭，且不响应 ctx
+type slowValidator struct {
+	ipc.Protocoler
+	release chan struct{}
+}
+
+func (s slowValidator) ValidateDevice(_ context.Context, dev *ipc.Device) error {
+	<-s.release
+	dev.Ext.Model = "late"
+	return nil
+}
+
+// ctxValidator 响应 ctx 的校验
+type ctxValidator struct {
+	ipc.Protocoler
+}
+
+func (ctxValidator) ValidateDevice(ctx context.Context, _ *ipc.Device) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestValidateDeviceCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var dev ipc.Device
+	start := time.Now()
+	err := ipc.ValidateDevice(ctx, slowValidator{release: release}, &dev)
+	if !errors.Is(err, ipc.ErrDeviceValidateTimeout) {
+		t.Fatalf("expect ipc.ErrDeviceValidateTimeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("validate not cancelled by context")
+	}
+	if dev.Ext.Model != "" {
+		t.Fatal("device modified after timeout")
+	}
+}
+
+func TestValidateDeviceContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var dev ipc.Device
+	if err := ipc.ValidateDevice(ctx, ctxValidator{}, &dev); !errors.Is(err, ipc.ErrDeviceValidateTimeout) {
+		t.Fatalf("expect ipc.ErrDeviceValidateTimeout, got %v", err)
+	}
+}
+
+// stopRecorder 记录删除设备时协议层被调用的时机
+type stopRecorder struct {
+	ipc.Protocoler
+	store   ipc.Storer
+	stopped []string
+	// rowExists 调用 DeleteDevice 时设备记录是否仍存在
+	rowExists bool
+}
+
+func (s *stopRecorder) DeleteDevice(ctx context.Context, device *ipc.Device) error {
+	var dev ipc.Device
+	s.rowExists = s.store.Device().Get(ctx, &dev, orm.Where("id=?", device.ID)) == nil
+	var chs []*ipc.Channel
+	if _, err := s.store.Channel().Find(ctx, &chs, &web.PagerFilter{Page: 1, Size: 10}, orm.Where("did=? AND is_playing=?", device.ID, true)); err != nil {
+		return err
+	}
+	for _, ch := range chs {
+		s.stopped = append(s.stopped, ch.ID)
+	}
+	return nil
+}
+
+func TestDelDeviceStopsActiveStream(t *testing.T) {
+	rec := &stopRecorder{}
+	core, store := newTestCore(t, map[string]ipc.Protocoler{ipc.TypeGB28181: rec})
+	rec.store = store
+	ctx := context.Background()
+
+	if err := store.Device().Add(ctx, &ipc.Device{ID: "gb1", DeviceID: "34020000001110000001", Type: ipc.TypeGB28181}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range []*ipc.Channel{
+		{ID: "ch1", DID: "gb1", Type: ipc.TypeGB28181, IsPlaying: true},
+		{ID: "ch2", DID: "gb1", Type: ipc.TypeGB28181},
+	} {
+		if err := store.Channel().Add(ctx, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := core.DelDevice(ctx, "gb1"); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.rowExists {
+		t.Fatal("protocol cleanup must run before device rows are removed")
+	}
+	if len(rec.stopped) != 1 || rec.stopped[0] != "ch1" {
+		t.Fatalf("expect active stream ch1 stopped, got %v", rec.stopped)
+	}
+	if _, err := core.GetDevice(ctx, "gb1"); err == nil {
+		t.Fatal("device should be deleted")
+	}
+	var chs []*ipc.Channel
+	if _, err := store.Channel().Find(ctx, &chs, &web.PagerFilter{Page: 1, Size: 10}, orm.Where("did=?", "gb1")); err != nil || len(chs) != 0 {
+		t.Fatalf("channels should be deleted, got %d %v", len(chs), err)
+	}
+}
+
+func TestEditDeviceStreamMode(t *testing.T) {
+	core, store := newTestCore(t, nil)
+	ctx := context.Background()
+
+	for _, dev := range []*ipc.Device{
+		{ID: "gb1", DeviceID: "34020000001110000001", Type: ipc.TypeGB28181, StreamMode: ipc.StreamModeTCPPassive},
+		{ID: "onvif1", Type: ipc.TypeOnvif},
+	} {
+		if err := store.Device().Add(ctx, dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mode := func(v int8) *ipc.EditStreamModeInput { return &ipc.EditStreamModeInput{StreamMode: &v} }
+
+	out, err := core.EditDeviceStreamMode(ctx, "gb1", mode(ipc.StreamModeTCPActive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StreamMode != ipc.StreamModeTCPActive {
+		t.Fatalf("expect stream mode %d, got %d", ipc.StreamModeTCPActive, out.StreamMode)
+	}
+	dev, err := core.GetDevice(ctx, "gb1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.StreamMode != ipc.StreamModeTCPActive {
+		t.Fatalf("stream mode not persisted, got %d", dev.StreamMode)
+	}
+
+	for _, v := range []int8{-1, 3} {
+		if _, err := core.EditDeviceStreamMode(ctx, "gb1", mode(v)); err == nil {
+			t.Fatalf("expect error for mode %d", v)
+		}
+	}
+	if _, err := core.EditDeviceStreamMode(ctx, "onvif1", mode(ipc.StreamModeUDP)); err == nil {
+		t.Fatal("expect error for non gb28181 device")
+	}
+}
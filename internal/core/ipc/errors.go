@@ -0,0 +1,30 @@
+package ipc
+
+import "github.com/ixugo/goddd/pkg/reason"
+
+// 设备/通道/播放相关的错误，响应体中的 reason 字段为稳定的错误码，客户端据此做本地化提示与重试
+//
+//	ErrDeviceNotFound        设备不存在，不应重试
+//	ErrChannelNotFound       通道不存在，不应重试
+//	ErrDeviceOffline         设备离线，可等待设备上线后重试
+//	ErrStreamNotPublished    RTMP 通道未推流，可等待推流后重试
+//	ErrProtocolNotSupported  设备/通道的协议不支持该操作
+//	ErrDeviceValidateFailed  设备连接或账号校验失败，msg 中为协议返回的原因
+//	ErrDeviceValidateTimeout 设备校验超时或被取消，通常是设备地址不可达
+//	ErrPTZNotSupported       通道不具备云台或协议未实现云台控制
+//	ErrPTZInvalidParam       云台控制参数错误
+//	ErrPTZControlFailed      设备未应答或拒绝云台控制，msg 中为失败原因
+//	ErrAlarmResetFailed      设备未应答或拒绝报警复位，msg 中为失败原因
+var (
+	ErrDeviceNotFound        = reason.NewError("ErrDeviceNotFound", "设备不存在")
+	ErrChannelNotFound       = reason.NewError("ErrChannelNotFound", "通道不存在")
+	ErrDeviceOffline         = reason.NewError("ErrDeviceOffline", "设备离线")
+	ErrStreamNotPublished    = reason.NewError("ErrStreamNotPublished", "未推流")
+	ErrProtocolNotSupported  = reason.NewError("ErrProtocolNotSupported", "不支持的协议")
+	ErrDeviceValidateFailed  = reason.NewError("ErrDeviceValidateFailed", "设备校验失败")
+	ErrDeviceValidateTimeout = reason.NewError("ErrDeviceValidateTimeout", "设备校验超时，请检查设备地址是否可达")
+	ErrPTZNotSupported       = reason.NewError("ErrPTZNotSupported", "不支持云台控制")
+	ErrPTZInvalidParam       = reason.NewError("ErrPTZInvalidParam", "云台控制参数错误")
+	ErrPTZControlFailed      = reason.NewError("ErrPTZControlFailed", "云台控制失败")
+	ErrAlarmResetFailed      = reason.NewError("ErrAlarmResetFailed", "报警复位失败")
+)
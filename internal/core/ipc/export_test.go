@@ -0,0 +1,8 @@
+package ipc
+
+// 供 ipc_test 包测试未导出的函数
+var (
+	ReconcilePlaying = reconcilePlaying
+	MergePresets     = mergePresets
+	ValidateDevice   = validateDevice
+)
@@ -0,0 +1,53 @@
+package ipc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// FrameStaleAfter 在线通道超过该时长未确认有画面即视为无画面
+// 封面巡检默认每 2.5 分钟抓拍一次正在拉流的通道，取其两倍有余
+const FrameStaleAfter = 5 * time.Minute
+
+// TouchFrame 记录通道在 at 时刻确认有画面，只前进不后退
+// 仅保存在内存中，抓拍与画质查询频繁，不写数据库
+func (c Core) TouchFrame(channelID string, at time.Time) {
+	if c.frames == nil || channelID == "" {
+		return
+	}
+	for {
+		last, loaded := c.frames.LoadOrStore(channelID, at)
+		if !loaded || !at.After(last) || c.frames.CompareAndSwap(channelID, last, at) {
+			return
+		}
+	}
+}
+
+// LastFrameAt 通道最近一次确认有画面的时间
+func (c Core) LastFrameAt(channelID string) (time.Time, bool) {
+	if c.frames == nil {
+		return time.Time{}, false
+	}
+	return c.frames.Load(channelID)
+}
+
+// MergeFrameState 填充通道的最近画面时间与无画面标记
+// SIP 注册在线但不发送媒体的设备，在这里体现为 IsFrameStale
+// 仅正在拉流或常驻拉流的通道会被巡检抓拍，按需点播的空闲通道没有画面属正常，不做判断
+func (c Core) MergeFrameState(ctx context.Context, channels []*Channel, now time.Time) {
+	for _, ch := range channels {
+		last, ok := c.LastFrameAt(ch.ID)
+		if ok {
+			ch.LastFrameAt = &orm.Time{Time: last}
+		}
+		expectFrame := ch.IsPlaying || ShouldKeepAlive(ctx, c.protocols[ch.Type], ch)
+		ch.IsFrameStale = ch.IsOnline && expectFrame && IsFrameStale(last, now, FrameStaleAfter)
+	}
+}
+
+// IsFrameStale 距最近一次画面超过 staleAfter，从未收到画面也视为无画面
+func IsFrameStale(last, now time.Time, staleAfter time.Duration) bool {
+	return last.IsZero() || now.Sub(last) > staleAfter
+}
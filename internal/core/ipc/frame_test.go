@@ -0,0 +1,84 @@
+package ipc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ixugo/goddd/domain/uniqueid"
+)
+
+func TestTouchFrame(t *testing.T) {
+	c := NewCore(nil, uniqueid.Core{}, nil)
+	base := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+
+	if _, ok := c.LastFrameAt("ch1"); ok {
+		t.Fatal("expect no frame before touch")
+	}
+	c.TouchFrame("ch1", base)
+	c.TouchFrame("ch1", base.Add(time.Minute))
+	// 迟到的旧时间不能覆盖新时间
+	c.TouchFrame("ch1", base.Add(30*time.Second))
+	if last, ok := c.LastFrameAt("ch1"); !ok || !last.Equal(base.Add(time.Minute)) {
+		t.Fatalf("expect last frame %v, got %v", base.Add(time.Minute), last)
+	}
+
+	// 零值 Core 不记录也不 panic
+	var zero Core
+	zero.TouchFrame("ch1", base)
+	if _, ok := zero.LastFrameAt("ch1"); ok {
+		t.Fatal("expect zero core keeps nothing")
+	}
+}
+
+func TestMergeFrameState(t *testing.T) {
+	c := NewCore(nil, uniqueid.Core{}, nil)
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+	c.TouchFrame("fresh", now.Add(-time.Minute))
+	c.TouchFrame("silent", now.Add(-FrameStaleAfter-time.Second))
+	c.TouchFrame("offline", now.Add(-time.Hour))
+
+	idle := DeviceExt{RecordMode: "none"}
+	channels := []*Channel{
+		{ID: "fresh", IsOnline: true},
+		{ID: "silent", IsOnline: true},
+		{ID: "never", IsOnline: true},
+		{ID: "offline", IsOnline: false},
+		{ID: "idle", IsOnline: true, Ext: idle},
+		{ID: "playing", IsOnline: true, IsPlaying: true, Ext: idle},
+	}
+	c.MergeFrameState(context.Background(), channels, now)
+
+	tests := []struct {
+		stale   bool
+		hasLast bool
+	}{
+		{stale: false, hasLast: true},
+		{stale: true, hasLast: true},
+		{stale: true, hasLast: false},
+		// 离线通道本就没有画面，不标记
+		{stale: false, hasLast: true},
+		// 未拉流的按需通道不会被抓拍，不标记
+		{stale: false, hasLast: false},
+		{stale: true, hasLast: false},
+	}
+	for i, tt := range tests {
+		ch := channels[i]
+		if ch.IsFrameStale != tt.stale || (ch.LastFrameAt != nil) != tt.hasLast {
+			t.Fatalf("%s: expect stale=%v hasLast=%v, got %v %v", ch.ID, tt.stale, tt.hasLast, ch.IsFrameStale, ch.LastFrameAt)
+		}
+	}
+}
+
+func TestIsFrameStale(t *testing.T) {
+	now := time.Now()
+	if !IsFrameStale(time.Time{}, now, time.Minute) {
+		t.Fatal("expect never seen stale")
+	}
+	if IsFrameStale(now.Add(-time.Minute), now, time.Minute) {
+		t.Fatal("expect boundary not stale")
+	}
+	if !IsFrameStale(now.Add(-time.Minute-time.Nanosecond), now, time.Minute) {
+		t.Fatal("expect stale after threshold")
+	}
+}
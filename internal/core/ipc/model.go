@@ -4,9 +4,15 @@ package ipc
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
 )
 
 const (
@@ -16,21 +22,6 @@ const (
 	TypeRTMP    = "RTMP"
 )
 
-func GetType(stream string) string {
-	switch true {
-	case bz.IsGB28181(stream):
-		return TypeGB28181
-	case bz.IsOnvif(stream):
-		return TypeOnvif
-	case bz.IsRTSP(stream):
-		return TypeRTSP
-	case bz.IsRTMP(stream):
-		return TypeRTMP
-	default:
-		return ""
-	}
-}
-
 // DeviceExt domain model
 type DeviceExt struct {
 	Manufacturer string `json:"manufacturer"` // 生产厂商
@@ -43,6 +34,70 @@ type DeviceExt struct {
 
 	// 空串表示 always
 	RecordMode string `json:"record_mode"` // 录像模式, 一直录制:always, 按AI触发:ai, 不录制:none
+
+	// 0 表示不启用运动预过滤，直接运行 AI 检测
+	MotionSensitivity float64 `json:"motion_sensitivity"` // 运动预过滤灵敏度 (0,1]，越大越灵敏
+
+	// nil 表示启用，兼容历史数据
+	EnabledRecording *bool `json:"enabled_recording,omitempty"` // 是否启用录像
+
+	// 为空时使用全局配置，格式同 sip.sdp_formats
+	SDPFormats []string `json:"sdp_formats,omitempty"` // 国标点播 SDP 媒体格式
+
+	// 为空时使用全局配置，格式同 sip.ssrc_prefix/sip.ssrc_format
+	SSRCPrefix string `json:"ssrc_prefix,omitempty"` // 国标点播 SSRC 前缀
+	SSRCFormat string `json:"ssrc_format,omitempty"` // 国标点播 SSRC 格式
+
+	// 显式关闭注册鉴权，取代历史上以密码 "#" 表示免鉴权的约定
+	AuthDisabled bool `json:"auth_disabled,omitempty"` // 国标注册免鉴权
+
+	// 0 表示使用 DefaultPTZSpeed
+	PTZSpeed int `json:"ptz_speed,omitempty"` // 云台默认速度 1~255，控制指令未指定速度时使用
+
+	// 0 表示收到流媒体无人观看事件时立即关闭，流媒体自身的等待时长为全局配置
+	NoneReaderDelay int `json:"none_reader_delay,omitempty"` // 无人观看后额外保持流的秒数，便于快速重新播放
+
+	// 0 表示设备未上报，点播时不校验码流
+	StreamNumbers int `json:"stream_numbers,omitempty"` // 国标设备上报的码流数量(视频参数属性配置)
+
+	// 0 表示使用 DefaultDetectFPS，受全局 server.ai.max_total_fps 预算约束
+	DetectFPS int `json:"detect_fps,omitempty"` // AI 检测帧率 1~DetectFPSMax
+}
+
+const (
+	// DefaultDetectFPS 通道未配置检测帧率时使用
+	DefaultDetectFPS = 1
+	// DetectFPSMax 单通道 AI 检测帧率上限
+	DetectFPSMax = 30
+)
+
+// GetDetectFPS 通道的 AI 检测帧率
+func (e *DeviceExt) GetDetectFPS() int {
+	if e.DetectFPS <= 0 {
+		return DefaultDetectFPS
+	}
+	return min(e.DetectFPS, DetectFPSMax)
+}
+
+// NoneReaderDelayMax 无人观看后额外保持流的最长秒数
+const NoneReaderDelayMax = 24 * 60 * 60
+
+// GetNoneReaderDelay 无人观看后额外保持流的时长
+func (e *DeviceExt) GetNoneReaderDelay() time.Duration {
+	return time.Duration(min(max(e.NoneReaderDelay, 0), NoneReaderDelayMax)) * time.Second
+}
+
+// GetPTZSpeed 通道的云台默认速度
+func (e *DeviceExt) GetPTZSpeed() int {
+	if e.PTZSpeed <= 0 {
+		return DefaultPTZSpeed
+	}
+	return min(e.PTZSpeed, PTZSpeedMax)
+}
+
+// IsRecordingEnabled 通道级录像开关，未设置时默认启用
+func (e *DeviceExt) IsRecordingEnabled() bool {
+	return e.EnabledRecording == nil || *e.EnabledRecording
 }
 
 func (e *DeviceExt) GetRecordMode() string {
@@ -64,6 +119,11 @@ func (e *DeviceExt) IsNoneRecord() bool {
 	return e.RecordMode == "none"
 }
 
+// IsStreamKeepAlive 持续录像或启用 AI 的通道需要常驻拉流，无人观看时也不能关闭
+func (e *DeviceExt) IsStreamKeepAlive() bool {
+	return e.EnabledAI || (e.IsRecordingEnabled() && e.IsAlwaysRecord())
+}
+
 // Scan implements orm.Scaner.
 func (i *DeviceExt) Scan(input any) error {
 	return orm.JSONUnmarshal(input, i)
@@ -80,6 +140,51 @@ type Zone struct {
 	Labels      []string  `json:"labels"`      // 标签
 }
 
+// Validate 校验区域多边形，坐标为归一化的 (x1, y1, x2, y2, ...)，至少 3 个点
+// 坐标为空表示整个画面
+func (z Zone) Validate() error {
+	if strings.TrimSpace(z.Name) == "" {
+		return reason.ErrBadRequest.SetMsg("区域名称不能为空")
+	}
+	n := len(z.Coordinates)
+	if n == 0 {
+		return nil
+	}
+	if n%2 != 0 {
+		return reason.ErrBadRequest.SetMsg(fmt.Sprintf("区域[%s]坐标数量必须为偶数，当前 %d", z.Name, n))
+	}
+	if n < 6 {
+		return reason.ErrBadRequest.SetMsg(fmt.Sprintf("区域[%s]至少需要 3 个点构成多边形", z.Name))
+	}
+	for i, v := range z.Coordinates {
+		if math.IsNaN(float64(v)) || v < 0 || v > 1 {
+			return reason.ErrBadRequest.SetMsg(fmt.Sprintf("区域[%s]第 %d 个坐标值 %v 超出归一化范围 [0,1]", z.Name, i+1, v))
+		}
+	}
+	return nil
+}
+
+// Equal 区域内容是否相同
+func (z Zone) Equal(o Zone) bool {
+	return z.Name == o.Name && z.Color == o.Color &&
+		slices.Equal(z.Coordinates, o.Coordinates) && slices.Equal(z.Labels, o.Labels)
+}
+
+// ValidateZones 校验多个区域，区域名称不可重复
+func ValidateZones(zones []Zone) error {
+	names := make(map[string]struct{}, len(zones))
+	for _, z := range zones {
+		if err := z.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[z.Name]; ok {
+			return reason.ErrBadRequest.SetMsg("存在同名区域: " + z.Name)
+		}
+		names[z.Name] = struct{}{}
+	}
+	return nil
+}
+
 // StreamConfig 流配置，用于 RTMP 推流和 RTSP 拉流代理
 type StreamConfig struct {
 	// RTMP 推流配置
@@ -93,12 +198,48 @@ type StreamConfig struct {
 	// RTSP 拉流配置
 	SourceURL                 string `json:"source_url"`                   // 原始 URL
 	Transport                 int    `json:"transport"`                    // 拉流方式 (0:tcp, 1:udp)
-	TimeoutS                  int    `json:"timeout_s"`                    // 超时时间
-	EnabledAudio              bool   `json:"enabled_audio"`                // 是否启用音频
+	TimeoutS                  int    `json:"timeout_s"`                    // 超时时间，0 使用默认值
+	RetryCount                int    `json:"retry_count"`                  // 拉流重试次数，0 使用默认值，-1 无限重试
+	EnabledAudio              *bool  `json:"enabled_audio,omitempty"`      // 是否启用音频，为空时启用（兼容未保存该字段的历史通道）
 	EnabledRemoveNoneReader   bool   `json:"enabled_remove_none_reader"`   // 无人观看时删除
 	EnabledDisabledNoneReader bool   `json:"enabled_disabled_none_reader"` // 无人观看时禁用
 	StreamKey                 string `json:"stream_key"`                   // ZLM 返回的 key
 	Enabled                   bool   `json:"enabled"`                      // 是否启用
+
+	// 协议开关，仅通过单一协议观看的流可关闭其它协议以节省资源，默认全部开启
+	DisabledRTSP      bool `json:"disabled_rtsp,omitempty"`       // 不转 rtsp 协议
+	DisabledRTMP      bool `json:"disabled_rtmp,omitempty"`       // 不转 rtmp/flv 协议
+	DisabledHLS       bool `json:"disabled_hls,omitempty"`        // 不转 hls-fmp4 协议
+	DisabledMuteAudio bool `json:"disabled_mute_audio,omitempty"` // 无音频时不添加静音音频
+
+	// 实际生效的拉流参数（动态生成，不持久化）
+	EffectiveTimeoutS   int `json:"effective_timeout_s,omitempty"`   // 实际拉流超时时间(秒)
+	EffectiveRetryCount int `json:"effective_retry_count,omitempty"` // 实际拉流重试次数
+
+	// 流注册时由流媒体上报，前端据此选择播放器
+	Codec *bz.StreamCodec `json:"codec,omitempty"` // 最近一次推/拉流的编码信息
+}
+
+// 拉流参数取值范围
+const (
+	maxPullTimeoutS   = 300
+	maxPullRetryCount = 100
+)
+
+// AudioEnabled 是否启用音频，未设置时默认启用
+func (s *StreamConfig) AudioEnabled() bool {
+	return s.EnabledAudio == nil || *s.EnabledAudio
+}
+
+// ValidatePull 校验拉流超时与重试次数
+func (s *StreamConfig) ValidatePull() error {
+	if s.TimeoutS < 0 || s.TimeoutS > maxPullTimeoutS {
+		return reason.ErrBadRequest.SetMsg(fmt.Sprintf("拉流超时时间应在 0~%d 秒之间", maxPullTimeoutS))
+	}
+	if s.RetryCount < -1 || s.RetryCount > maxPullRetryCount {
+		return reason.ErrBadRequest.SetMsg(fmt.Sprintf("拉流重试次数应在 -1~%d 之间", maxPullRetryCount))
+	}
+	return nil
 }
 
 // Scan implements orm.Scanner
@@ -107,6 +248,9 @@ func (s *StreamConfig) Scan(input any) error {
 }
 
 // Value implements driver.Valuer
+// 推流地址与实际生效的拉流参数仅在查询时填充，入库前清空
 func (s StreamConfig) Value() (driver.Value, error) {
+	s.PushAddr = ""
+	s.EffectiveTimeoutS, s.EffectiveRetryCount = 0, 0
 	return json.Marshal(s)
 }
@@ -0,0 +1,122 @@
+package ipc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZoneValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		zone  Zone
+		valid bool
+	}{
+		{name: "triangle", zone: Zone{Name: "a", Coordinates: []float32{0.1, 0.1, 0.9, 0.1, 0.5, 0.9}}, valid: true},
+		{name: "quad on edges", zone: Zone{Name: "a", Coordinates: []float32{0, 0, 1, 0, 1, 1, 0, 1}}, valid: true},
+		{name: "whole frame", zone: Zone{Name: "a"}, valid: true},
+		{name: "empty name", zone: Zone{Coordinates: []float32{0.1, 0.1, 0.9, 0.1, 0.5, 0.9}}},
+		{name: "odd count", zone: Zone{Name: "a", Coordinates: []float32{0.1, 0.1, 0.9, 0.1, 0.5}}},
+		{name: "two points", zone: Zone{Name: "a", Coordinates: []float32{0.1, 0.1, 0.9, 0.1}}},
+		{name: "pixel coordinates", zone: Zone{Name: "a", Coordinates: []float32{10, 10, 200, 10, 100, 150}}},
+		{name: "negative", zone: Zone{Name: "a", Coordinates: []float32{-0.1, 0.1, 0.9, 0.1, 0.5, 0.9}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.zone.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expect valid, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expect error")
+			}
+		})
+	}
+}
+
+func TestValidateZones(t *testing.T) {
+	tri := []float32{0.1, 0.1, 0.9, 0.1, 0.5, 0.9}
+	if err := ValidateZones([]Zone{{Name: "door", Coordinates: tri}, {Name: "yard", Coordinates: tri}}); err != nil {
+		t.Fatalf("expect valid, got %v", err)
+	}
+	if err := ValidateZones([]Zone{{Name: "door", Coordinates: tri}, {Name: "door", Coordinates: tri}}); err == nil {
+		t.Fatal("expect error for duplicate zone name")
+	}
+	if err := ValidateZones([]Zone{{Name: "door", Coordinates: tri}, {Name: "bad", Coordinates: tri[:4]}}); err == nil {
+		t.Fatal("expect error for malformed zone")
+	}
+}
+
+func TestParseCivilCode(t *testing.T) {
+	cases := []struct {
+		id     string
+		expect string
+	}{
+		{id: "34020000001320000001", expect: "34020000"},
+		{id: "11010500001310000123", expect: "11010500"},
+		{id: "340200000013200001", expect: "340200"},
+		{id: "3402000000132000011", expect: "340200"},
+		{id: "3402000000132", expect: ""},
+		{id: "340200000013200000012", expect: ""},
+		{id: "3402000000132000000a", expect: ""},
+		{id: "", expect: ""},
+	}
+	for _, tc := range cases {
+		if got := ParseCivilCode(tc.id); got != tc.expect {
+			t.Fatalf("ParseCivilCode(%q) expect %q, got %q", tc.id, tc.expect, got)
+		}
+	}
+}
+
+func TestDeviceAuth(t *testing.T) {
+	dev := Device{Type: TypeGB28181, Password: PasswordNoAuth}
+	dev.normalizeAuth()
+	if dev.Password != "" || !dev.Ext.AuthDisabled || !dev.IsAuthDisabled() {
+		t.Fatalf("sentinel should be converted to flag, got %+v", dev)
+	}
+
+	dev = Device{Type: TypeGB28181, Password: "secret", Ext: DeviceExt{AuthDisabled: true}}
+	if err := dev.checkAuth(); err == nil {
+		t.Fatal("expect error when auth disabled with password")
+	}
+
+	dev = Device{Type: TypeGB28181, Password: "secret"}
+	if err := dev.checkAuth(); err != nil || dev.IsAuthDisabled() {
+		t.Fatalf("password device should require auth, err %v", err)
+	}
+}
+
+func TestStreamConfigValidatePull(t *testing.T) {
+	cases := []struct {
+		cfg StreamConfig
+		ok  bool
+	}{
+		{cfg: StreamConfig{}, ok: true},
+		{cfg: StreamConfig{TimeoutS: 30, RetryCount: -1}, ok: true},
+		{cfg: StreamConfig{TimeoutS: -1}, ok: false},
+		{cfg: StreamConfig{TimeoutS: maxPullTimeoutS + 1}, ok: false},
+		{cfg: StreamConfig{RetryCount: -2}, ok: false},
+		{cfg: StreamConfig{RetryCount: maxPullRetryCount + 1}, ok: false},
+	}
+	for _, tc := range cases {
+		if err := tc.cfg.ValidatePull(); (err == nil) != tc.ok {
+			t.Fatalf("ValidatePull(timeout=%d retry=%d) expect ok=%v, got %v", tc.cfg.TimeoutS, tc.cfg.RetryCount, tc.ok, err)
+		}
+	}
+}
+
+func TestStreamConfigValueSkipsDynamicFields(t *testing.T) {
+	cfg := StreamConfig{TimeoutS: 10, PushAddr: "rtmp://127.0.0.1/live/a?sign=x", EffectiveTimeoutS: 10, EffectiveRetryCount: 3}
+	v, err := cfg.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(v.([]byte))
+	for _, key := range []string{"push_addr", "effective_timeout_s", "effective_retry_count"} {
+		if strings.Contains(body, key) {
+			t.Fatalf("%s should not be persisted: %s", key, body)
+		}
+	}
+	if cfg.EffectiveRetryCount != 3 {
+		t.Fatal("Value should not modify the caller's config")
+	}
+}
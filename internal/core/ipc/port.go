@@ -34,6 +34,7 @@ type Protocoler interface {
 	// StopPlay 停止播放
 	StopPlay(ctx context.Context, device *Device, channel *Channel) error
 
+	// DeleteDevice 删除设备前调用，清理协议层资源（如进行中的点播）
 	DeleteDevice(ctx context.Context, device *Device) error
 
 	Hooker
@@ -61,3 +62,27 @@ type PlayResponse struct {
 	Stream string // 流 ID
 	RTSP   string // RTSP 地址 (ONVIF)
 }
+
+// OnlineSnapshoter 内存在线状态快照接口（可选实现）
+// 列表接口一次取全量快照合并，避免逐条查询内存
+type OnlineSnapshoter interface {
+	// OnlineSnapshot 返回设备在线状态，key 为设备在协议内的标识
+	// GB28181 为国标编码，ONVIF 为设备 ID
+	OnlineSnapshot() map[string]bool
+}
+
+// KeepAliver 无人观看时的保流策略（可选实现）
+// 由协议适配器决定，例如常驻拉流的 RTSP 保持，临时点播的国标关闭
+type KeepAliver interface {
+	// ShouldKeepAlive 返回 true 时拒绝流媒体关闭无人观看的流
+	ShouldKeepAlive(ctx context.Context, ch *Channel) bool
+}
+
+// ShouldKeepAlive 无人观看时是否保持通道的流
+// 协议未实现 KeepAliver 时，录像模式不为 none 即保持，以便继续录制
+func ShouldKeepAlive(ctx context.Context, p Protocoler, ch *Channel) bool {
+	if k, ok := p.(KeepAliver); ok {
+		return k.ShouldKeepAlive(ctx, ch)
+	}
+	return !ch.Ext.IsNoneRecord()
+}
@@ -0,0 +1,16 @@
+package ipc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestShouldKeepAliveFallback(t *testing.T) {
+	// 协议未实现 KeepAliver 时按录像模式判断
+	for mode, keep := range map[string]bool{"": true, "always": true, "ai": true, "none": false} {
+		ch := Channel{Type: TypeRTMP, Ext: DeviceExt{RecordMode: mode}}
+		if got := ShouldKeepAlive(context.Background(), nil, &ch); got != keep {
+			t.Fatalf("record mode %q expect keep %v, got %v", mode, keep, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ipc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// PresetStorer Instantiation interface
+type PresetStorer interface {
+	Find(context.Context, *[]*Preset, orm.Pager, ...orm.QueryOption) (int64, error)
+	Get(context.Context, *Preset, ...orm.QueryOption) error
+	Add(context.Context, *Preset) error
+	Edit(context.Context, *Preset, func(*Preset) error, ...orm.QueryOption) error
+	Del(context.Context, *Preset, ...orm.QueryOption) error
+}
+
+// PresetItem 合并设备上报与平台保存后的预置位
+type PresetItem struct {
+	PresetID   int    `json:"preset_id"`
+	Name       string `json:"name"`        // 平台保存的名称优先，否则为设备上报的名称
+	DeviceName string `json:"device_name"` // 设备上报的名称
+	OnDevice   bool   `json:"on_device"`   // 设备是否上报了该预置位
+}
+
+// PresetQueryer 预置位查询接口（可选实现）
+type PresetQueryer interface {
+	QueryPreset(ctx context.Context, device *Device, channel *Channel) ([]PresetItem, error)
+}
+
+// FindPresets 查询通道预置位，设备查询失败时仅返回平台保存的名称
+// 固定枪机没有预置位，返回空列表，便于前端统一展示
+func (c *Core) FindPresets(ctx context.Context, channelID string) ([]PresetItem, error) {
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		if errors.Is(err, ErrPTZNotSupported) {
+			return []PresetItem{}, nil
+		}
+		return nil, err
+	}
+
+	stored := make([]*Preset, 0, 8)
+	if _, err := c.store.Preset().Find(ctx, &stored, web.NewPagerFilterMaxSize(), orm.Where("cid=?", channelID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
+	}
+	var reported []PresetItem
+	if q, ok := c.GetProtocol(dev.GetType()).(PresetQueryer); ok {
+		reported, err = q.QueryPreset(ctx, dev, ch)
+		if err != nil {
+			slog.WarnContext(ctx, "查询设备预置位失败", "channel_id", channelID, "err", err)
+		}
+	}
+	return mergePresets(reported, stored), nil
+}
+
+// mergePresets 按预置位编号合并，平台保存的名称覆盖设备上报的名称
+func mergePresets(reported []PresetItem, stored []*Preset) []PresetItem {
+	items := make(map[int]*PresetItem, len(reported)+len(stored))
+	for _, r := range reported {
+		items[r.PresetID] = &PresetItem{PresetID: r.PresetID, Name: r.DeviceName, DeviceName: r.DeviceName, OnDevice: true}
+	}
+	for _, s := range stored {
+		item, ok := items[s.PresetID]
+		if !ok {
+			item = &PresetItem{PresetID: s.PresetID}
+			items[s.PresetID] = item
+		}
+		if s.Name != "" {
+			item.Name = s.Name
+		}
+	}
+
+	out := make([]PresetItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, *item)
+	}
+	slices.SortFunc(out, func(a, b PresetItem) int { return a.PresetID - b.PresetID })
+	return out
+}
+
+// SetPreset 保存预置位名称，已存在时更新
+func (c *Core) SetPreset(ctx context.Context, channelID string, in *SetPresetInput) (*Preset, error) {
+	if _, err := c.GetChannel(ctx, channelID); err != nil {
+		return nil, err
+	}
+
+	var out Preset
+	err := c.store.Preset().Get(ctx, &out, orm.Where("cid=? AND preset_id=?", channelID, in.PresetID))
+	if err == nil {
+		if err := c.store.Preset().Edit(ctx, &out, func(p *Preset) error {
+			p.Name = in.Name
+			return nil
+		}, orm.Where("id=?", out.ID)); err != nil {
+			return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
+		}
+		return &out, nil
+	}
+	if !orm.IsErrRecordNotFound(err) {
+		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
+	}
+
+	out = Preset{CID: channelID, PresetID: in.PresetID, Name: in.Name}
+	if err := c.store.Preset().Add(ctx, &out); err != nil {
+		return nil, reason.ErrDB.Withf(`Add err[%s]`, err.Error())
+	}
+	return &out, nil
+}
+
+// DelPreset 删除平台保存的预置位名称，不影响设备上的预置位
+func (c *Core) DelPreset(ctx context.Context, channelID string, presetID int) error {
+	var out Preset
+	if err := c.store.Preset().Del(ctx, &out, orm.Where("cid=? AND preset_id=?", channelID, presetID)); err != nil {
+		return reason.ErrDB.Withf(`Del err[%s]`, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package ipc
+
+// SetPresetInput 保存预置位名称
+type SetPresetInput struct {
+	PresetID int    `json:"preset_id" binding:"min=1,max=255"` // 国标预置位编号 1~255
+	Name     string `json:"name" binding:"required,max=64"`
+}
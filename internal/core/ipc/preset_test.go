@@ -0,0 +1,59 @@
+package ipc_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+func TestMergePresets(t *testing.T) {
+	reported := []ipc.PresetItem{
+		{PresetID: 3, DeviceName: "Preset3"},
+		{PresetID: 1, DeviceName: ""},
+		{PresetID: 2, DeviceName: "gate"},
+	}
+	stored := []*ipc.Preset{
+		{CID: "ch1", PresetID: 1, Name: "front gate"},
+		{CID: "ch1", PresetID: 2, Name: ""},
+		{CID: "ch1", PresetID: 9, Name: "parking"},
+	}
+
+	got := ipc.MergePresets(reported, stored)
+	expect := []ipc.PresetItem{
+		{PresetID: 1, Name: "front gate", OnDevice: true},
+		{PresetID: 2, Name: "gate", DeviceName: "gate", OnDevice: true},
+		{PresetID: 3, Name: "Preset3", DeviceName: "Preset3", OnDevice: true},
+		{PresetID: 9, Name: "parking", OnDevice: false},
+	}
+	if !slices.Equal(got, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, got)
+	}
+
+	// 设备查询失败时仅返回平台保存的名称
+	if got := ipc.MergePresets(nil, stored[:1]); !slices.Equal(got, []ipc.PresetItem{{PresetID: 1, Name: "front gate"}}) {
+		t.Fatalf("unexpected stored only result %+v", got)
+	}
+}
+
+func TestFindPresetsFixedChannel(t *testing.T) {
+	core, store := newTestCore(t, nil)
+	ctx := context.Background()
+
+	if err := store.Channel().Add(ctx, &ipc.Channel{ID: "fixed", Type: ipc.TypeGB28181, PTZType: ipc.PTZTypeFixed}); err != nil {
+		t.Fatal(err)
+	}
+	// 固定枪机不支持云台，预置位列表为空而不是报错
+	items, err := core.FindPresets(ctx, "fixed")
+	if err != nil {
+		t.Fatalf("expect no error for fixed channel, got %v", err)
+	}
+	if items == nil || len(items) != 0 {
+		t.Fatalf("expect empty list, got %#v", items)
+	}
+
+	if _, err := core.FindPresets(ctx, "missing"); err == nil {
+		t.Fatal("expect error for unknown channel")
+	}
+}
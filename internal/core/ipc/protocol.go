@@ -121,6 +121,17 @@ func (g Adapter) EditPlaying(ctx context.Context, deviceID, channelID string, pl
 // 3. 删除多余：不在上报列表中的通道标记为离线或删除
 // 4. 使用事务保证数据一致性
 func (g Adapter) SaveChannels(channels []*Channel) error {
+	return g.saveChannels(channels, true)
+}
+
+// MergeChannels 仅新增或更新上报的通道，不处理未上报的通道，也不修改设备的通道数量
+// 用于目录未收齐时保存已收到的部分，避免把缺失分片中的通道误判为离线
+func (g Adapter) MergeChannels(channels []*Channel) error {
+	return g.saveChannels(channels, false)
+}
+
+// saveChannels complete 为 true 表示 channels 是设备完整的通道列表
+func (g Adapter) saveChannels(channels []*Channel, complete bool) error {
 	if len(channels) <= 0 {
 		return nil
 	}
@@ -131,7 +142,9 @@ func (g Adapter) SaveChannels(channels []*Channel) error {
 	// 1. 获取设备信息
 	var dev Device
 	_ = g.store.Device().Edit(context.TODO(), &dev, func(d *Device) error {
-		d.Channels = len(channels)
+		if complete {
+			d.Channels = len(channels)
+		}
 		return nil
 	}, orm.Where("device_id=?", channels[0].DeviceID))
 
@@ -173,7 +186,7 @@ func (g Adapter) SaveChannels(channels []*Channel) error {
 
 	// 6. 删除不再存在的通道（设备上报的通道列表中没有的）
 	// 方案A：标记为离线（推荐，保留历史数据）
-	if len(currentChannelIDs) > 0 {
+	if complete && len(currentChannelIDs) > 0 {
 		_ = g.store.Channel().BatchEdit(ctx, "is_online", false,
 			orm.Where("device_id = ?", deviceID),
 			orm.Where("channel_id NOT IN ?", currentChannelIDs),
@@ -189,6 +202,9 @@ func (g Adapter) SaveChannels(channels []*Channel) error {
 	// )
 
 	// 7. 更新设备的通道数量
+	if !complete {
+		return nil
+	}
 	_ = g.store.Device().Edit(ctx, &dev, func(d *Device) error {
 		d.Channels = len(channels)
 		return nil
@@ -0,0 +1,301 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+)
+
+// PTZDirection 云台控制方向
+type PTZDirection string
+
+const (
+	PTZStop      PTZDirection = "stop"
+	PTZUp        PTZDirection = "up"
+	PTZDown      PTZDirection = "down"
+	PTZLeft      PTZDirection = "left"
+	PTZRight     PTZDirection = "right"
+	PTZLeftUp    PTZDirection = "left_up"
+	PTZLeftDown  PTZDirection = "left_down"
+	PTZRightUp   PTZDirection = "right_up"
+	PTZRightDown PTZDirection = "right_down"
+	PTZZoomIn    PTZDirection = "zoom_in"
+	PTZZoomOut   PTZDirection = "zoom_out"
+	PTZFocusNear PTZDirection = "focus_near"
+	PTZFocusFar  PTZDirection = "focus_far"
+	PTZIrisOpen  PTZDirection = "iris_open"
+	PTZIrisClose PTZDirection = "iris_close"
+)
+
+// PTZSpeedMax 国标 PTZ 指令中速度占一个字节
+const PTZSpeedMax = 255
+
+// DefaultPTZSpeed 通道未配置默认速度时使用
+const DefaultPTZSpeed = 128
+
+// PTZTypeFixed 国标目录中摄像机结构类型：1-球机 2-半球 3-固定枪机 4-遥控枪机
+const PTZTypeFixed = 3
+
+var ptzDirections = map[PTZDirection]struct{}{
+	PTZStop: {}, PTZUp: {}, PTZDown: {}, PTZLeft: {}, PTZRight: {},
+	PTZLeftUp: {}, PTZLeftDown: {}, PTZRightUp: {}, PTZRightDown: {},
+	PTZZoomIn: {}, PTZZoomOut: {}, PTZFocusNear: {}, PTZFocusFar: {},
+	PTZIrisOpen: {}, PTZIrisClose: {},
+}
+
+// PTZControlInput 云台控制参数
+//
+// 停止必须显式使用 direction=stop，speed 为 0 或不传表示使用通道默认速度(Ext.PTZSpeed)，
+// 而不是停止，便于只有方向按钮的界面省略速度
+type PTZControlInput struct {
+	Direction PTZDirection `json:"direction"`
+	Speed     int          `json:"speed"` // 1~255，0 表示使用通道默认速度
+}
+
+// Validate 校验方向与速度
+func (in *PTZControlInput) Validate() error {
+	if _, ok := ptzDirections[in.Direction]; !ok {
+		return fmt.Errorf("unknown direction[%s]", in.Direction)
+	}
+	if in.Speed < 0 || in.Speed > PTZSpeedMax {
+		return fmt.Errorf("speed must be between 0 and %d", PTZSpeedMax)
+	}
+	return nil
+}
+
+// IsStop 仅 direction=stop 表示停止
+func (in *PTZControlInput) IsStop() bool {
+	return in.Direction == PTZStop
+}
+
+// applyDefaultSpeed 运动指令未指定速度时使用通道默认速度
+func (in *PTZControlInput) applyDefaultSpeed(ch *Channel) {
+	if !in.IsStop() && in.Speed == 0 {
+		in.Speed = ch.Ext.GetPTZSpeed()
+	}
+}
+
+// PTZFIInput 聚焦/光圈持续控制参数，与方向控制分开，调节镜头时不影响云台方向
+// 正负表示方向，绝对值为速度，两者均为 0 表示停止
+type PTZFIInput struct {
+	Focus int `json:"focus"` // >0 聚焦远，<0 聚焦近，-255~255
+	Iris  int `json:"iris"`  // >0 光圈放大，<0 光圈缩小，-255~255
+}
+
+// Validate 校验聚焦与光圈速度
+func (in *PTZFIInput) Validate() error {
+	if in.Focus < -PTZSpeedMax || in.Focus > PTZSpeedMax {
+		return fmt.Errorf("focus must be between -%d and %d", PTZSpeedMax, PTZSpeedMax)
+	}
+	if in.Iris < -PTZSpeedMax || in.Iris > PTZSpeedMax {
+		return fmt.Errorf("iris must be between -%d and %d", PTZSpeedMax, PTZSpeedMax)
+	}
+	return nil
+}
+
+// IsStop 聚焦与光圈均为 0 表示停止
+func (in *PTZFIInput) IsStop() bool {
+	return in.Focus == 0 && in.Iris == 0
+}
+
+// PTZCommandKind 预置位/巡航/扫描指令类别
+type PTZCommandKind string
+
+const (
+	PTZCommandPreset PTZCommandKind = "preset"
+	PTZCommandCruise PTZCommandKind = "cruise"
+	PTZCommandScan   PTZCommandKind = "scan"
+)
+
+// 预置位/巡航/扫描操作
+const (
+	PTZPresetSet  = "set"  // 设置预置位
+	PTZPresetCall = "call" // 调用预置位
+	PTZPresetDel  = "del"  // 删除设备上的预置位
+
+	PTZCruiseAdd   = "add"   // 加入巡航点，value 为预置位号
+	PTZCruiseDel   = "del"   // 删除巡航点，value 为预置位号，为 0 时删除整条巡航
+	PTZCruiseSpeed = "speed" // 设置巡航速度，value 为 1~4095
+	PTZCruiseDwell = "dwell" // 设置巡航停留时间，value 为 1~4095 秒
+	PTZCruiseStart = "start" // 开始巡航
+
+	PTZScanStart = "start" // 开始自动扫描
+	PTZScanLeft  = "left"  // 设置左边界
+	PTZScanRight = "right" // 设置右边界
+	PTZScanSpeed = "speed" // 设置扫描速度，value 为 1~4095
+)
+
+// PTZCommandInput 预置位/巡航/扫描指令参数，取值范围由协议适配器按协议校验
+type PTZCommandInput struct {
+	Kind   PTZCommandKind `json:"kind"`   // preset/cruise/scan
+	Action string         `json:"action"` // 见 PTZPresetXxx/PTZCruiseXxx/PTZScanXxx
+	ID     int            `json:"id"`     // 预置位号 1~255，巡航/扫描组号 0~255
+	Value  int            `json:"value"`  // 巡航点的预置位号，或巡航/扫描的速度、停留时间
+}
+
+// PTZCommander 预置位/巡航/扫描接口（可选实现）
+type PTZCommander interface {
+	PTZCommand(ctx context.Context, device *Device, channel *Channel, in *PTZCommandInput) error
+}
+
+// PTZController 云台控制接口（可选实现）
+// 协议适配器实现此接口表示支持云台控制
+type PTZController interface {
+	PTZControl(ctx context.Context, device *Device, channel *Channel, in *PTZControlInput) error
+	PTZFIControl(ctx context.Context, device *Device, channel *Channel, in *PTZFIInput) error
+}
+
+// PTZPositionInput 点击居中/拉框放大参数，坐标为画面归一化坐标，左上角为原点
+type PTZPositionInput struct {
+	X    float64 `json:"x"`    // 目标点横坐标 0~1
+	Y    float64 `json:"y"`    // 目标点纵坐标 0~1
+	Zoom float64 `json:"zoom"` // 相对当前画面的缩放倍数，>1 放大，<1 缩小，0 或 1 仅居中
+}
+
+// PTZZoomMax 单次定位允许的最大缩放倍数
+const PTZZoomMax = 32
+
+// Validate 校验坐标与缩放倍数
+func (in *PTZPositionInput) Validate() error {
+	if in.X < 0 || in.X > 1 || in.Y < 0 || in.Y > 1 {
+		return fmt.Errorf("x and y must be between 0 and 1")
+	}
+	if in.Zoom < 0 || in.Zoom > PTZZoomMax {
+		return fmt.Errorf("zoom must be between 0 and %d", PTZZoomMax)
+	}
+	return nil
+}
+
+// ZoomRatio 缩放倍数，未指定时为 1
+func (in *PTZPositionInput) ZoomRatio() float64 {
+	if in.Zoom <= 0 {
+		return 1
+	}
+	return in.Zoom
+}
+
+// PTZAbsoluteInput 云台绝对位置参数
+type PTZAbsoluteInput struct {
+	Pan  float64 `json:"pan"`  // 水平角 0~360 度
+	Tilt float64 `json:"tilt"` // 垂直角 -90~90 度
+	Zoom float64 `json:"zoom"` // 变倍倍数 1~PTZZoomMax
+}
+
+// Validate 校验水平角、垂直角与变倍倍数
+func (in *PTZAbsoluteInput) Validate() error {
+	if in.Pan < 0 || in.Pan > 360 {
+		return fmt.Errorf("pan must be between 0 and 360")
+	}
+	if in.Tilt < -90 || in.Tilt > 90 {
+		return fmt.Errorf("tilt must be between -90 and 90")
+	}
+	if in.Zoom < 1 || in.Zoom > PTZZoomMax {
+		return fmt.Errorf("zoom must be between 1 and %d", PTZZoomMax)
+	}
+	return nil
+}
+
+// PTZAbsoluteController 云台绝对位置接口（可选实现）
+// 设备不支持绝对定位时应返回设备的错误应答，而不是视为成功
+type PTZAbsoluteController interface {
+	PTZAbsoluteControl(ctx context.Context, device *Device, channel *Channel, in *PTZAbsoluteInput) error
+}
+
+// PTZPositioner 3D 定位接口（可选实现）
+// 国标使用拉框放大/缩小，ONVIF 使用绝对位置移动
+type PTZPositioner interface {
+	PTZPosition(ctx context.Context, device *Device, channel *Channel, in *PTZPositionInput) error
+}
+
+// PTZControl 云台控制，按设备协议分发到对应的适配器
+func (c *Core) PTZControl(ctx context.Context, channelID string, in *PTZControlInput) error {
+	if err := in.Validate(); err != nil {
+		return ErrPTZInvalidParam.With(err.Error())
+	}
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	ctl, ok := c.GetProtocol(dev.GetType()).(PTZController)
+	if !ok {
+		return ErrPTZNotSupported
+	}
+	in.applyDefaultSpeed(ch)
+	return ctl.PTZControl(ctx, dev, ch, in)
+}
+
+// PTZPosition 3D 定位，将画面中的点移到中心并按倍数缩放
+func (c *Core) PTZPosition(ctx context.Context, channelID string, in *PTZPositionInput) error {
+	if err := in.Validate(); err != nil {
+		return ErrPTZInvalidParam.With(err.Error())
+	}
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	ctl, ok := c.GetProtocol(dev.GetType()).(PTZPositioner)
+	if !ok {
+		return ErrPTZNotSupported
+	}
+	return ctl.PTZPosition(ctx, dev, ch, in)
+}
+
+// PTZFIControl 聚焦/光圈控制，按设备协议分发到对应的适配器
+func (c *Core) PTZFIControl(ctx context.Context, channelID string, in *PTZFIInput) error {
+	if err := in.Validate(); err != nil {
+		return ErrPTZInvalidParam.With(err.Error())
+	}
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	ctl, ok := c.GetProtocol(dev.GetType()).(PTZController)
+	if !ok {
+		return ErrPTZNotSupported.Withf("protocol[%s] does not support focus/iris control", dev.GetType())
+	}
+	return ctl.PTZFIControl(ctx, dev, ch, in)
+}
+
+// PTZCommand 预置位/巡航/扫描，按设备协议分发到对应的适配器
+func (c *Core) PTZCommand(ctx context.Context, channelID string, in *PTZCommandInput) error {
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	ctl, ok := c.GetProtocol(dev.GetType()).(PTZCommander)
+	if !ok {
+		return ErrPTZNotSupported.Withf("protocol[%s] does not support preset/cruise/scan", dev.GetType())
+	}
+	return ctl.PTZCommand(ctx, dev, ch, in)
+}
+
+// PTZAbsoluteControl 云台转到绝对位置，按设备协议分发到对应的适配器
+func (c *Core) PTZAbsoluteControl(ctx context.Context, channelID string, in *PTZAbsoluteInput) error {
+	if err := in.Validate(); err != nil {
+		return ErrPTZInvalidParam.With(err.Error())
+	}
+	dev, ch, err := c.getPTZTarget(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	ctl, ok := c.GetProtocol(dev.GetType()).(PTZAbsoluteController)
+	if !ok {
+		return ErrPTZNotSupported
+	}
+	return ctl.PTZAbsoluteControl(ctx, dev, ch, in)
+}
+
+// getPTZTarget 获取具备云台的通道及其设备
+func (c *Core) getPTZTarget(ctx context.Context, channelID string) (*Device, *Channel, error) {
+	ch, err := c.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ch.PTZType == PTZTypeFixed {
+		return nil, nil, ErrPTZNotSupported
+	}
+	dev, err := c.GetDevice(ctx, ch.DID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dev, ch, nil
+}
@@ -0,0 +1,49 @@
+package ipc
+
+import "testing"
+
+func TestPTZControlDefaultSpeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		ext        DeviceExt
+		in         PTZControlInput
+		expect     int
+		expectStop bool
+	}{
+		{name: "omitted speed uses global default", in: PTZControlInput{Direction: PTZLeft}, expect: DefaultPTZSpeed},
+		{name: "omitted speed uses channel default", ext: DeviceExt{PTZSpeed: 60}, in: PTZControlInput{Direction: PTZUp}, expect: 60},
+		{name: "explicit speed kept", ext: DeviceExt{PTZSpeed: 60}, in: PTZControlInput{Direction: PTZUp, Speed: 200}, expect: 200},
+		{name: "channel default capped", ext: DeviceExt{PTZSpeed: 1000}, in: PTZControlInput{Direction: PTZZoomIn}, expect: PTZSpeedMax},
+		{name: "explicit stop", ext: DeviceExt{PTZSpeed: 60}, in: PTZControlInput{Direction: PTZStop}, expect: 0, expectStop: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			in.applyDefaultSpeed(&Channel{Ext: tt.ext})
+			if in.Speed != tt.expect || in.IsStop() != tt.expectStop {
+				t.Fatalf("expect speed=%d stop=%v, got speed=%d stop=%v", tt.expect, tt.expectStop, in.Speed, in.IsStop())
+			}
+		})
+	}
+}
+
+func TestPTZAbsoluteValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    PTZAbsoluteInput
+		valid bool
+	}{
+		{name: "in range", in: PTZAbsoluteInput{Pan: 360, Tilt: -90, Zoom: 1}, valid: true},
+		{name: "pan too large", in: PTZAbsoluteInput{Pan: 361, Zoom: 1}},
+		{name: "tilt too small", in: PTZAbsoluteInput{Tilt: -91, Zoom: 1}},
+		{name: "zoom omitted", in: PTZAbsoluteInput{Pan: 10}},
+		{name: "zoom too large", in: PTZAbsoluteInput{Zoom: PTZZoomMax + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.in.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("expect valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
@@ -28,6 +28,16 @@ func (d DB) Channel() ipc.ChannelStorer {
 	return Channel(d)
 }
 
+// Preset Get business instance
+func (d DB) Preset() ipc.PresetStorer {
+	return Preset(d)
+}
+
+// DeviceStateLog Get business instance
+func (d DB) DeviceStateLog() ipc.DeviceStateLogStorer {
+	return DeviceStateLog(d)
+}
+
 // AutoMigrate sync database
 func (d DB) AutoMigrate(ok bool) DB {
 	if !ok {
@@ -36,6 +46,8 @@ func (d DB) AutoMigrate(ok bool) DB {
 	if err := d.db.AutoMigrate(
 		new(ipc.Device),
 		new(ipc.Channel),
+		new(ipc.Preset),
+		new(ipc.DeviceStateLog),
 	); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,33 @@
+package ipcdb
+
+import (
+	"context"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+var _ ipc.DeviceStateLogStorer = DeviceStateLog{}
+
+// DeviceStateLog Related business namespaces
+type DeviceStateLog DB
+
+// Find implements ipc.DeviceStateLogStorer.
+func (d DeviceStateLog) Find(ctx context.Context, bs *[]*ipc.DeviceStateLog, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Get implements ipc.DeviceStateLogStorer.
+func (d DeviceStateLog) Get(ctx context.Context, model *ipc.DeviceStateLog, opts ...orm.QueryOption) error {
+	return orm.FirstWithContext(ctx, d.db, model, opts...)
+}
+
+// Add implements ipc.DeviceStateLogStorer.
+func (d DeviceStateLog) Add(ctx context.Context, model *ipc.DeviceStateLog) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Del implements ipc.DeviceStateLogStorer.
+func (d DeviceStateLog) Del(ctx context.Context, model *ipc.DeviceStateLog, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
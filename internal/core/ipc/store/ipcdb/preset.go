@@ -0,0 +1,38 @@
+package ipcdb
+
+import (
+	"context"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+var _ ipc.PresetStorer = Preset{}
+
+// Preset Related business namespaces
+type Preset DB
+
+// Find implements ipc.PresetStorer.
+func (d Preset) Find(ctx context.Context, bs *[]*ipc.Preset, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	return orm.FindWithContext(ctx, d.db, bs, page, opts...)
+}
+
+// Get implements ipc.PresetStorer.
+func (d Preset) Get(ctx context.Context, model *ipc.Preset, opts ...orm.QueryOption) error {
+	return orm.FirstWithContext(ctx, d.db, model, opts...)
+}
+
+// Add implements ipc.PresetStorer.
+func (d Preset) Add(ctx context.Context, model *ipc.Preset) error {
+	return d.db.WithContext(ctx).Create(model).Error
+}
+
+// Edit implements ipc.PresetStorer.
+func (d Preset) Edit(ctx context.Context, model *ipc.Preset, changeFn func(*ipc.Preset) error, opts ...orm.QueryOption) error {
+	return orm.UpdateWithContext2(ctx, d.db, model, changeFn, opts...)
+}
+
+// Del implements ipc.PresetStorer.
+func (d Preset) Del(ctx context.Context, model *ipc.Preset, opts ...orm.QueryOption) error {
+	return orm.DeleteWithContext(ctx, d.db, model, opts...)
+}
@@ -0,0 +1,87 @@
+package ipc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gowvp/owl/internal/core/bz"
+)
+
+// typePrefixes ID 前缀到协议类型的映射，ZLM 回调中查不到通道时据此按 stream 判断协议
+var typePrefixes = struct {
+	mu sync.RWMutex
+	m  map[string]string
+}{m: make(map[string]string, 8)}
+
+func init() {
+	for prefix, typ := range map[string]string{
+		bz.IDPrefixGB:           TypeGB28181,
+		bz.IDPrefixGBChannel:    TypeGB28181,
+		bz.IDPrefixOnvif:        TypeOnvif,
+		bz.IDPrefixOnvifChannel: TypeOnvif,
+		bz.IDPrefixRTSP:         TypeRTSP,
+		bz.IDPrefixRTMP:         TypeRTMP,
+	} {
+		if err := RegisterTypePrefix(typ, prefix); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterTypePrefix 注册 ID 前缀对应的协议类型，新协议接入时在 init 中调用
+// 前缀不能与已注册的前缀相同或互为前缀，否则同一 ID 会命中多个类型
+func RegisterTypePrefix(typ, prefix string) error {
+	if typ == "" || prefix == "" {
+		return fmt.Errorf("type and prefix are required")
+	}
+	if !isIDBody(prefix) {
+		return fmt.Errorf("prefix[%s] must be lowercase letters or digits", prefix)
+	}
+	typePrefixes.mu.Lock()
+	defer typePrefixes.mu.Unlock()
+	for p, t := range typePrefixes.m {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			return fmt.Errorf("prefix[%s] conflicts with prefix[%s] of type[%s]", prefix, p, t)
+		}
+	}
+	typePrefixes.m[prefix] = typ
+	return nil
+}
+
+// TypePrefixes 返回已注册的前缀映射副本
+func TypePrefixes() map[string]string {
+	typePrefixes.mu.RLock()
+	defer typePrefixes.mu.RUnlock()
+	out := make(map[string]string, len(typePrefixes.m))
+	for p, t := range typePrefixes.m {
+		out[p] = t
+	}
+	return out
+}
+
+// GetType 按 ID 前缀判断协议类型，无法识别时返回空串
+// 平台生成的 ID 为前缀加小写字母与数字，前缀后含其它字符的自定义 stream(如 ch_front、mp-cam)不会被误判
+func GetType(stream string) string {
+	typePrefixes.mu.RLock()
+	defer typePrefixes.mu.RUnlock()
+	for p, t := range typePrefixes.m {
+		if rest, ok := strings.CutPrefix(stream, p); ok && isIDBody(rest) {
+			return t
+		}
+	}
+	return ""
+}
+
+// isIDBody 非空且仅包含小写字母与数字
+func isIDBody(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
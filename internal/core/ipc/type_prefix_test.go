@@ -0,0 +1,65 @@
+package ipc
+
+import "testing"
+
+func TestGetType(t *testing.T) {
+	tests := []struct {
+		stream string
+		expect string
+	}{
+		{stream: "gb2k4j7", expect: TypeGB28181},
+		{stream: "ch9x0a1", expect: TypeGB28181},
+		{stream: "on3m5q8", expect: TypeOnvif},
+		{stream: "prz81k2", expect: TypeOnvif},
+		{stream: "sp0b2c3", expect: TypeRTSP},
+		{stream: "mp7d8e9", expect: TypeRTMP},
+		{stream: "unknown1", expect: ""},
+		{stream: "", expect: ""},
+		{stream: "mp", expect: ""},
+		// 自定义 stream 与前缀同名开头时不应被误判
+		{stream: "ch_front", expect: ""},
+		{stream: "mp-cam", expect: ""},
+		{stream: "gbTest", expect: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stream, func(t *testing.T) {
+			if got := GetType(tt.stream); got != tt.expect {
+				t.Fatalf("expect %q, got %q", tt.expect, got)
+			}
+		})
+	}
+
+	for prefix, typ := range TypePrefixes() {
+		if got := GetType(prefix + "abc12"); got != typ {
+			t.Fatalf("prefix %s expect %s, got %s", prefix, typ, got)
+		}
+	}
+}
+
+func TestRegisterTypePrefix(t *testing.T) {
+	t.Cleanup(func() {
+		typePrefixes.mu.Lock()
+		delete(typePrefixes.m, "hk")
+		typePrefixes.mu.Unlock()
+	})
+
+	if err := RegisterTypePrefix("HIKSDK", "hk"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetType("hk01abc"); got != "HIKSDK" {
+		t.Fatalf("expect HIKSDK, got %q", got)
+	}
+
+	for _, tt := range []struct{ typ, prefix string }{
+		{"OTHER", "hk"},  // 重复
+		{"OTHER", "h"},   // 是已注册前缀的前缀
+		{"OTHER", "gb1"}, // 以已注册前缀开头
+		{"OTHER", ""},
+		{"", "zz"},
+		{"OTHER", "Z_"},
+	} {
+		if err := RegisterTypePrefix(tt.typ, tt.prefix); err == nil {
+			t.Fatalf("expect error for %s/%q", tt.typ, tt.prefix)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package adapter
+
+import (
+	"context"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/recording"
+)
+
+var _ recording.ChannelProvider = (*ChannelAdapter)(nil)
+
+// ChannelAdapter 实现 recording.ChannelProvider 接口
+// 将 ipc.Core 的通道查询能力适配给 recording 领域使用
+type ChannelAdapter struct {
+	ipcCore ipc.Core
+}
+
+// NewChannelAdapter 创建通道适配器，返回 recording.ChannelProvider 接口
+func NewChannelAdapter(ipcCore ipc.Core) recording.ChannelProvider {
+	return &ChannelAdapter{ipcCore: ipcCore}
+}
+
+// IsRecordingEnabled 查询通道级录像开关，找不到通道时按启用处理，保持旧逻辑
+func (a *ChannelAdapter) IsRecordingEnabled(ctx context.Context, app, stream string) bool {
+	ch, err := a.ipcCore.GetChannelByAppStreamOrID(ctx, app, stream)
+	if err != nil {
+		return true
+	}
+	return ch.Ext.IsRecordingEnabled()
+}
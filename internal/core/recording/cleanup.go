@@ -2,6 +2,8 @@ package recording
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -14,8 +16,33 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	defaultCleanupInterval = 60 * time.Minute
+	minCleanupInterval     = time.Minute
+
+	defaultCleanupBatchSize = 100
+	// maxCleanupBatchSize 单批 id IN 的参数上限，兼顾 sqlite 变量数限制
+	maxCleanupBatchSize = 1000
+)
+
+// cleanupInterval 清理巡检间隔，未配置使用默认值，过小时按下限处理避免频繁扫库
+func (c Core) cleanupInterval() time.Duration {
+	if c.conf == nil || c.conf.CleanupInterval <= 0 {
+		return defaultCleanupInterval
+	}
+	return max(time.Duration(c.conf.CleanupInterval), minCleanupInterval)
+}
+
+// cleanupBatchSize 每批删除的录像数，限制在 [1, maxCleanupBatchSize]
+func (c Core) cleanupBatchSize() int {
+	if c.conf == nil || c.conf.BatchSize <= 0 {
+		return defaultCleanupBatchSize
+	}
+	return min(c.conf.BatchSize, maxCleanupBatchSize)
+}
+
 // StartCleanupWorker 启动定时清理协程
-// 程序启动时执行一次清理，随后每 60 分钟执行一次
+// 程序启动时执行一次清理，随后按配置的间隔（默认 60 分钟）执行
 func (c Core) StartCleanupWorker() {
 	if c.conf == nil || c.conf.Disabled {
 		slog.Info("recording cleanup disabled")
@@ -26,13 +53,16 @@ func (c Core) StartCleanupWorker() {
 		"retain_days", c.conf.RetainDays,
 		"disk_threshold", c.conf.DiskUsageThreshold,
 		"storage_dir", c.conf.StorageDir,
+		"interval", c.cleanupInterval(),
+		"batch_size", c.cleanupBatchSize(),
 	)
 
+	c.logStorage()
+
 	// 程序启动时先执行一次清理
 	c.runCleanup()
 
-	// 每 60 分钟执行一次
-	ticker := time.NewTicker(60 * time.Minute)
+	ticker := time.NewTicker(c.cleanupInterval())
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -54,15 +84,12 @@ func (c Core) markExpiringRecordings() {
 	}
 
 	ctx := context.Background()
-	// 计算 1 小时后的过期时间阈值
-	// 如果录像的 started_at < (now + 1h - retain_days)，则该录像将在 1 小时内过期
-	expiryCutoff := time.Now().Add(time.Hour).AddDate(0, 0, -c.conf.RetainDays)
+	// 如果录像在 1 小时后的保留期截止时间之前开始，则该录像将在 1 小时内过期
+	expired := c.expiredRecordings(time.Now().Add(time.Hour))
 
 	// 批量更新 delete_flag
 	err := c.store.Recording().Session(ctx, func(tx *gorm.DB) error {
-		return tx.Model(&Recording{}).
-			Where("delete_flag = ?", false).
-			Where("started_at < ?", orm.Time{Time: expiryCutoff}).
+		return expired(tx.Model(&Recording{}).Where("delete_flag = ?", false)).
 			Update("delete_flag", true).Error
 	})
 	if err != nil {
@@ -75,53 +102,138 @@ func (c Core) cleanupExpiredRecordings() {
 	if c.conf.RetainDays <= 0 {
 		return
 	}
+	c.CleanupExpired(context.Background())
+}
 
-	ctx := context.Background()
-	cutoffTime := time.Now().AddDate(0, 0, -c.conf.RetainDays)
-
-	totalDeleted, filesDeleted, freedBytes, failedFiles := c.batchDeleteRecordings(ctx,
+// CleanupExpired 删除超过保留期的录像（文件+记录），返回清理统计
+// 配置了事件额外保留时，与事件时间重叠的录像按更长的保留期处理
+func (c Core) CleanupExpired(ctx context.Context) CleanupResult {
+	if c.conf == nil || c.conf.RetainDays <= 0 {
+		return CleanupResult{}
+	}
+	now := time.Now()
+	// 错误已在删除时记录日志，失败的录像下次清理时重试
+	result, _ := c.batchDeleteRecordings(ctx,
 		"expired",
-		orm.Where("started_at < ?", orm.Time{Time: cutoffTime}),
+		c.expiredRecordings(now),
 	)
 
-	if totalDeleted > 0 || failedFiles > 0 {
+	if result.Deleted > 0 || result.FailedFiles > 0 {
 		slog.Info("expired recording cleanup completed",
 			"reason", "retention_policy",
 			"retain_days", c.conf.RetainDays,
-			"cutoff_time", cutoffTime.Format(time.DateTime),
-			"recordings_deleted", totalDeleted,
-			"files_deleted", filesDeleted,
-			"failed_files", failedFiles,
-			"freed_bytes", freedBytes,
+			"event_retain_days", c.conf.EventRetainDays,
+			"cutoff_time", retentionCutoff(now, c.conf.RetainDays, c.cleanupLocation()).Format(time.DateTime),
+			"recordings_deleted", result.Deleted,
+			"files_deleted", result.FilesDeleted,
+			"failed_files", result.FailedFiles,
+			"freed_bytes", result.FreedBytes,
 		)
 	}
+	return result
 }
 
-// cleanupByDiskUsage 基于磁盘使用率清理录像
-// 当磁盘使用率超过阈值时，删除最旧的录像直到使用率降到阈值以下
-// 同时预标记未来 2 小时可能被删除的文件
-func (c Core) cleanupByDiskUsage() {
-	if c.conf.DiskUsageThreshold <= 0 || c.conf.DiskUsageThreshold >= 100 {
-		return
+// cleanupLocation 保留期按天对齐使用的时区，配置错误时使用本地时区
+func (c Core) cleanupLocation() *time.Location {
+	loc, err := c.location("")
+	if err != nil {
+		slog.Warn("invalid recording timezone, fallback to local", "tz", c.conf.Timezone)
+		return time.Local
+	}
+	return loc
+}
+
+// expiredRecordings 以 now 计算保留期，筛选已过期的录像
+// 与事件重叠的录像在额外保留期内不视为过期，事件关联通过 NOT EXISTS 子查询完成，避免逐条查询
+func (c Core) expiredRecordings(now time.Time) orm.QueryOption {
+	loc := c.cleanupLocation()
+	cutoff := retentionCutoff(now, c.conf.RetainDays, loc)
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("started_at < ?", dbTime(cutoff))
+		if c.conf.EventRetainDays <= 0 || c.eventTable == "" {
+			return db
+		}
+		eventCutoff := retentionCutoff(now, c.conf.RetainDays+c.conf.EventRetainDays, loc)
+		overlap := fmt.Sprintf(`SELECT 1 FROM %s e WHERE e.cid = recordings.cid AND %s < %s AND %s > %s`, c.eventTable,
+			normTime(db, "e.started_at"), normTime(db, "recordings.ended_at"),
+			normTime(db, "e.ended_at"), normTime(db, "recordings.started_at"),
+		)
+		return db.Where("started_at < ? OR NOT EXISTS ("+overlap+")", dbTime(eventCutoff))
 	}
+}
 
-	storageDir := c.conf.StorageDir
-	if storageDir == "" {
-		storageDir = "./recordings"
+// normTime 列比较前统一时区，sqlite 以字符串存储时间，不同偏移的时间直接比较会错位
+// 事件与录像可能由不同时区写入，用 julianday 转换为同一时间轴；postgres 按时间类型比较无需处理
+func normTime(db *gorm.DB, column string) string {
+	if db.Dialector != nil && db.Dialector.Name() == "sqlite" {
+		return "julianday(" + column + ")"
 	}
+	return column
+}
+
+// diskState 录像所在磁盘的使用情况
+type diskState struct {
+	Usage     float64 // 使用率（百分比）
+	FreeBytes uint64  // 可用字节数
+}
+
+// diskPolicy 磁盘清理策略，Threshold 不在 (0,100) 区间或 MinFreeBytes 为 0 时对应条件不生效
+type diskPolicy struct {
+	Threshold    float64
+	MinFreeBytes uint64
+}
+
+func (p diskPolicy) belowFloor(s diskState) bool {
+	return p.MinFreeBytes > 0 && s.FreeBytes < p.MinFreeBytes
+}
+
+func (p diskPolicy) overThreshold(s diskState) bool {
+	return p.Threshold > 0 && p.Threshold < 100 && s.Usage >= p.Threshold
+}
+
+// needCleanup 使用率超过阈值或可用空间低于下限时需要清理
+func (p diskPolicy) needCleanup(s diskState) bool {
+	return p.overThreshold(s) || p.belowFloor(s)
+}
 
-	absStorageDir := filepath.Join(system.Getwd(), storageDir)
+// floorBudget 为满足可用空间下限最多释放的字节数：初始缺口加上近一小时的写入量
+// 空间被录像以外的文件占用时，删光录像也无法达到下限，需设上限避免清空全部录像
+func (p diskPolicy) floorBudget(s diskState, recentBytes int64) int64 {
+	if !p.belowFloor(s) {
+		return 0
+	}
+	return int64(p.MinFreeBytes-s.FreeBytes) + recentBytes
+}
+
+// done 判断删除循环是否可以停止
+// 可用空间下限未满足且未超出 floorBudget 时持续删除；其余沿用原策略：使用率回落或已释放目标大小即停止
+func (p diskPolicy) done(s diskState, freedBytes, targetBytes, floorBudget int64) bool {
+	if p.belowFloor(s) && freedBytes < floorBudget {
+		return false
+	}
+	return !p.overThreshold(s) || freedBytes >= targetBytes
+}
+
+// cleanupByDiskUsage 基于磁盘使用率与可用空间下限清理录像
+// 当磁盘使用率超过阈值或可用空间低于 MinFreeBytes 时，从最旧的录像开始删除
+// 同时预标记未来 2 小时可能被删除的文件，清理后空间仍不足时产生磁盘告警
+func (c Core) cleanupByDiskUsage() {
+	// 解析软链接，统计录像实际所在的文件系统
+	absStorageDir := c.storageDir()
 	if _, err := os.Stat(absStorageDir); os.IsNotExist(err) {
 		return
 	}
 
-	usage, err := getDiskUsage(absStorageDir)
+	state, err := statDisk(absStorageDir)
 	if err != nil {
 		slog.Warn("failed to get disk usage", "err", err)
 		return
 	}
+	initial := state
 
-	if usage < c.conf.DiskUsageThreshold {
+	policy := diskPolicy{Threshold: c.conf.DiskUsageThreshold, MinFreeBytes: uint64(max(c.conf.MinFreeBytes, 0))}
+	if !policy.needCleanup(state) {
+		c.checkDiskFull(absStorageDir, policy, state, false)
 		return
 	}
 
@@ -146,53 +258,49 @@ func (c Core) cleanupByDiskUsage() {
 	// 删除最旧的录像
 	var freedBytes int64
 	var deletedCount, failedCount int
-	batchSize := 50
-
-	for freedBytes < recentSize {
+	batchSize := c.cleanupBatchSize()
+	// 文件删除失败的记录保留待下次重试，本轮查询需跳过，否则会反复取到同一批
+	var skipIDs []int64
+	// exhausted 已无可删除的录像，仍未达到目标
+	var exhausted bool
+	floorBudget := policy.floorBudget(state, recentSize)
+
+	for !policy.done(state, freedBytes, recentSize, floorBudget) {
 		var oldestRecordings []*Recording
 		pager := web.PagerFilter{Page: 1, Size: batchSize}
 		_, err := c.store.Recording().Find(ctx, &oldestRecordings, &pager,
+			skipRecordings(skipIDs),
 			orm.OrderBy("started_at ASC"),
 		)
 		if err != nil || len(oldestRecordings) == 0 {
+			exhausted = true
 			break
 		}
 
-		// 收集待删除的文件路径和 ID
-		var deleteIDs []int64
-		var batchFreed int64
-		var batchFailed int
-
-		for _, rec := range oldestRecordings {
-			filePath := rec.Path
-			if !filepath.IsAbs(filePath) {
-				filePath = filepath.Join(system.Getwd(), filePath)
-			}
-			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-				batchFailed++
-			} else {
-				batchFreed += rec.Size
-			}
-			deleteIDs = append(deleteIDs, rec.ID)
+		result := c.deleteRecordingBatch(ctx, oldestRecordings)
+		for _, rec := range result.Failed {
+			skipIDs = append(skipIDs, rec.ID)
 		}
+		deletedCount += result.Deleted
+		freedBytes += result.FreedBytes
+		failedCount += len(result.Failed)
 
-		// 批量删除数据库记录
-		if len(deleteIDs) > 0 {
-			_ = c.store.Recording().Session(ctx, func(tx *gorm.DB) error {
-				return tx.Where("id IN ?", deleteIDs).Delete(&Recording{}).Error
-			})
-			deletedCount += len(deleteIDs)
-		}
-
-		freedBytes += batchFreed
-		failedCount += batchFailed
-
-		// 检查磁盘使用率
-		usage, err = getDiskUsage(absStorageDir)
-		if err == nil && usage < c.conf.DiskUsageThreshold {
+		// 重新检查磁盘状态，获取失败时停止，避免盲目删除
+		if state, err = statDisk(absStorageDir); err != nil {
 			break
 		}
 	}
+	if policy.belowFloor(state) {
+		slog.Warn("recording cleanup cannot meet min free bytes",
+			"storage_dir", absStorageDir,
+			"min_free_bytes", policy.MinFreeBytes,
+			"free_bytes", state.FreeBytes,
+			"freed_bytes", freedBytes,
+			"floor_budget", floorBudget,
+			"exhausted", exhausted,
+		)
+	}
+	c.checkDiskFull(absStorageDir, policy, state, exhausted)
 
 	// 清理空目录
 	cleanupEmptyDirs(absStorageDir)
@@ -203,8 +311,11 @@ func (c Core) cleanupByDiskUsage() {
 	if deletedCount > 0 || failedCount > 0 {
 		slog.Info("disk usage cleanup completed",
 			"reason", "disk_threshold_exceeded",
-			"initial_usage", usage,
+			"storage_dir", absStorageDir,
+			"initial_usage", initial.Usage,
+			"initial_free_bytes", initial.FreeBytes,
 			"threshold", c.conf.DiskUsageThreshold,
+			"min_free_bytes", policy.MinFreeBytes,
 			"recordings_deleted", deletedCount,
 			"failed_files", failedCount,
 			"freed_bytes", freedBytes,
@@ -221,7 +332,8 @@ func (c Core) markNextDeletionCandidates(ctx context.Context, targetSize int64)
 
 	// 查询未被标记的最旧录像
 	var candidates []*Recording
-	pager := web.PagerFilter{Page: 1, Size: 200}
+	// 多取一倍，保证有足够候选凑满目标大小
+	pager := web.PagerFilter{Page: 1, Size: 2 * c.cleanupBatchSize()}
 	_, err := c.store.Recording().Find(ctx, &candidates, &pager,
 		orm.Where("delete_flag = ?", false),
 		orm.OrderBy("started_at ASC"),
@@ -249,81 +361,161 @@ func (c Core) markNextDeletionCandidates(ctx context.Context, targetSize int64)
 	}
 }
 
+// CleanupResult 录像清理统计
+type CleanupResult struct {
+	Deleted      int   // 已删除的数据库记录数
+	FilesDeleted int   // 实际删除的文件数
+	FailedFiles  int   // 文件删除失败、保留记录待重试的录像数
+	FreedBytes   int64 // 实际释放的字节数
+}
+
 // batchDeleteRecordings 批量删除录像（文件+数据库记录）
 // reason 参数用于日志记录，说明删除原因
-func (c Core) batchDeleteRecordings(ctx context.Context, reason string, conditions ...orm.QueryOption) (totalDeleted, filesDeleted, failedFiles int, freedBytes int64) {
-	batchSize := 100
+// 查询或删除记录失败时返回汇总的错误，文件删除失败计入 FailedFiles
+func (c Core) batchDeleteRecordings(ctx context.Context, reason string, conditions ...orm.QueryOption) (out CleanupResult, err error) {
+	batchSize := c.cleanupBatchSize()
+	var skipIDs []int64
 
 	for {
 		var recordings []*Recording
 		pager := web.PagerFilter{Page: 1, Size: batchSize}
-		_, err := c.store.Recording().Find(ctx, &recordings, &pager, conditions...)
-		if err != nil || len(recordings) == 0 {
+		if _, findErr := c.store.Recording().Find(ctx, &recordings, &pager, append(conditions, skipRecordings(skipIDs))...); findErr != nil {
+			slog.Warn("failed to query recordings for delete", "reason", reason, "err", findErr)
+			err = errors.Join(err, findErr)
 			break
 		}
-
-		// 收集删除结果
-		var deleteIDs []int64
-		var batchFreed int64
-		var batchFilesDeleted, batchFailed int
-
-		for _, rec := range recordings {
-			filePath := rec.Path
-			if !filepath.IsAbs(filePath) {
-				filePath = filepath.Join(system.Getwd(), filePath)
-			}
-			if err := os.Remove(filePath); err != nil {
-				if !os.IsNotExist(err) {
-					batchFailed++
-				}
-			} else {
-				batchFilesDeleted++
-				batchFreed += rec.Size
-			}
-			deleteIDs = append(deleteIDs, rec.ID)
+		if len(recordings) == 0 {
+			break
 		}
 
-		// 批量删除数据库记录
-		if len(deleteIDs) > 0 {
-			err = c.store.Recording().Session(ctx, func(tx *gorm.DB) error {
-				return tx.Where("id IN ?", deleteIDs).Delete(&Recording{}).Error
-			})
-			if err == nil {
-				totalDeleted += len(deleteIDs)
-			}
+		result := c.deleteRecordingBatch(ctx, recordings)
+		for _, rec := range result.Failed {
+			skipIDs = append(skipIDs, rec.ID)
+		}
+		out.Deleted += result.Deleted
+		out.FilesDeleted += result.FilesDeleted
+		out.FailedFiles += len(result.Failed)
+		out.FreedBytes += result.FreedBytes
+		if result.Err != nil {
+			err = errors.Join(err, result.Err)
+			// 记录删除失败时退出，避免重复查询到同一批数据导致死循环
+			break
 		}
-
-		filesDeleted += batchFilesDeleted
-		failedFiles += batchFailed
-		freedBytes += batchFreed
 	}
 
 	// 清理空目录
 	if c.conf != nil && c.conf.StorageDir != "" {
-		absStorageDir := filepath.Join(system.Getwd(), c.conf.StorageDir)
-		cleanupEmptyDirs(absStorageDir)
+		cleanupEmptyDirs(c.storageDir())
 	}
 
 	return
 }
 
-// getDiskUsage 获取指定路径所在磁盘的使用率（百分比）
-func getDiskUsage(path string) (float64, error) {
+// recordingBatchResult 一批录像的删除结果
+type recordingBatchResult struct {
+	Deleted      int          // 已删除的数据库记录数
+	FilesDeleted int          // 实际删除的文件数
+	FreedBytes   int64        // 实际释放的字节数
+	Failed       []*Recording // 文件删除失败、保留记录待重试的录像
+	Err          error        // 删除数据库记录失败的错误
+}
+
+// deleteRecordingBatch 先删文件再删记录，只删除文件已删除或确认不存在的记录
+// 文件删除失败的记录保留，避免记录消失而文件残留，造成磁盘空间泄漏
+func (c Core) deleteRecordingBatch(ctx context.Context, recs []*Recording) recordingBatchResult {
+	var out recordingBatchResult
+	deleteIDs := make([]int64, 0, len(recs))
+	for _, rec := range recs {
+		filePath := rec.Path
+		if _, ok := c.NodeStorageDir(rec.MediaServerID); ok {
+			filePath = c.RecordingPath(rec)
+		} else if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(system.Getwd(), filePath)
+		}
+		if err := os.Remove(filePath); err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("failed to remove recording file, keep record for retry",
+					"id", rec.ID, "cid", rec.CID, "path", filePath, "err", err)
+				out.Failed = append(out.Failed, rec)
+				continue
+			}
+		} else {
+			out.FilesDeleted++
+			out.FreedBytes += rec.Size
+		}
+		if rec.Thumbnail != "" {
+			// 封面图与录像同目录，删除失败不影响记录清理
+			_ = os.Remove(filepath.Join(filepath.Dir(filePath), filepath.Base(rec.Thumbnail)))
+		}
+		deleteIDs = append(deleteIDs, rec.ID)
+	}
+
+	if len(deleteIDs) == 0 {
+		return out
+	}
+	err := c.store.Recording().Session(ctx, func(tx *gorm.DB) error {
+		return tx.Where("id IN ?", deleteIDs).Delete(&Recording{}).Error
+	})
+	if err != nil {
+		// 文件已删除但记录仍在，下次清理时会按文件不存在处理
+		slog.Warn("failed to delete recording records", "count", len(deleteIDs), "err", err)
+		out.Err = err
+		return out
+	}
+	out.Deleted = len(deleteIDs)
+	return out
+}
+
+// skipRecordings 排除本轮已处理失败的录像
+func skipRecordings(ids []int64) orm.QueryOption {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(ids) == 0 {
+			return db
+		}
+		return db.Where("id NOT IN ?", ids)
+	}
+}
+
+// PurgeByChannels 删除指定通道的全部录像（文件 + 记录），用于删除通道/设备时清理孤儿录像
+// 返回已删除的记录数，有文件或记录删除失败时同时返回错误
+func (c Core) PurgeByChannels(ctx context.Context, cids ...string) (int, error) {
+	if len(cids) == 0 {
+		return 0, nil
+	}
+	result, err := c.batchDeleteRecordings(ctx, "purge", orm.Where("cid IN ?", cids))
+	slog.Info("recording purge completed",
+		"cids", cids,
+		"recordings_deleted", result.Deleted,
+		"files_deleted", result.FilesDeleted,
+		"failed_files", result.FailedFiles,
+		"freed_bytes", result.FreedBytes,
+	)
+	if result.FailedFiles > 0 {
+		err = errors.Join(err, fmt.Errorf("%d recording files failed to delete", result.FailedFiles))
+	}
+	return result.Deleted, err
+}
+
+// statDisk 获取磁盘状态，测试时替换以模拟磁盘已满
+var statDisk = getDiskState
+
+// getDiskState 获取指定路径所在磁盘的使用率（百分比）与可用空间
+func getDiskState(path string) (diskState, error) {
 	var stat syscall.Statfs_t
 	if err := syscall.Statfs(path, &stat); err != nil {
-		return 0, err
+		return diskState{}, err
 	}
 
 	total := stat.Blocks * uint64(stat.Bsize)
 	free := stat.Bfree * uint64(stat.Bsize)
 	used := total - free
 
-	if total == 0 {
-		return 0, nil
+	// 可用空间取非特权用户可用的部分，与写录像的进程实际可用一致
+	out := diskState{FreeBytes: stat.Bavail * uint64(stat.Bsize)}
+	if total > 0 {
+		out.Usage = float64(used) / float64(total) * 100
 	}
-
-	usage := float64(used) / float64(total) * 100
-	return usage, nil
+	return out, nil
 }
 
 // cleanupEmptyDirs 递归删除空目录
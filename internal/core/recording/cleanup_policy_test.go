@@ -0,0 +1,100 @@
+package recording
+
+import "testing"
+
+func TestDiskPolicy(t *testing.T) {
+	const gb = 1 << 30
+	const target = 100 << 20
+	const budget = 20 * gb
+
+	tests := []struct {
+		name   string
+		policy diskPolicy
+		state  diskState
+		freed  int64
+		need   bool
+		done   bool
+	}{
+		{
+			name:   "healthy",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 50, FreeBytes: 10 * gb},
+			need:   false,
+			done:   true,
+		},
+		{
+			name:   "over threshold until target freed",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 95, FreeBytes: 5 * gb},
+			freed:  target - 1,
+			need:   true,
+			done:   false,
+		},
+		{
+			name:   "over threshold target freed",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 95, FreeBytes: 5 * gb},
+			freed:  target,
+			need:   true,
+			done:   true,
+		},
+		{
+			// 使用率未超阈值，但可用空间不足，仍需清理
+			name:   "below floor",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 80, FreeBytes: gb - 1},
+			need:   true,
+			done:   false,
+		},
+		{
+			// 已释放目标大小也不能停，直到满足可用空间下限
+			name:   "below floor ignores target",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 99, FreeBytes: gb / 2},
+			freed:  10 * target,
+			need:   true,
+			done:   false,
+		},
+		{
+			// 释放量已超过下限预算，说明空间被录像以外的文件占用，不再继续删除
+			name:   "below floor budget exhausted",
+			policy: diskPolicy{Threshold: 90, MinFreeBytes: gb},
+			state:  diskState{Usage: 80, FreeBytes: gb / 2},
+			freed:  budget,
+			need:   true,
+			done:   true,
+		},
+		{
+			name:   "floor disabled",
+			policy: diskPolicy{Threshold: 90},
+			state:  diskState{Usage: 80, FreeBytes: 0},
+			need:   false,
+			done:   true,
+		},
+		{
+			name:   "threshold disabled",
+			policy: diskPolicy{Threshold: 100, MinFreeBytes: gb},
+			state:  diskState{Usage: 99, FreeBytes: 2 * gb},
+			need:   false,
+			done:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.needCleanup(tt.state); got != tt.need {
+				t.Fatalf("needCleanup expect %v, got %v", tt.need, got)
+			}
+			if got := tt.policy.done(tt.state, tt.freed, target, budget); got != tt.done {
+				t.Fatalf("done expect %v, got %v", tt.done, got)
+			}
+		})
+	}
+
+	p := diskPolicy{Threshold: 90, MinFreeBytes: gb}
+	if got := p.floorBudget(diskState{FreeBytes: gb / 2}, target); got != gb/2+target {
+		t.Fatalf("expect floor budget %d, got %d", gb/2+target, got)
+	}
+	if got := p.floorBudget(diskState{FreeBytes: 2 * gb}, target); got != 0 {
+		t.Fatalf("expect no floor budget above floor, got %d", got)
+	}
+}
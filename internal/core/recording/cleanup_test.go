@@ -0,0 +1,260 @@
+package recording_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestPurgeByChannels(t *testing.T) {
+	db, store := newTestDB(t)
+	core := recording.NewCore(store)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for i, cid := range []string{"cid1", "cid1", "cid2", "cid3"} {
+		path := filepath.Join(dir, cid+"_"+string(rune('a'+i))+".mp4")
+		if err := os.WriteFile(path, []byte("mp4"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Recording().Add(ctx, &recording.Recording{CID: cid, Path: path, Size: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := core.PurgeByChannels(ctx, "cid1", "cid2"); err != nil || n != 3 {
+		t.Fatalf("expect 3 recordings purged, got %d %v", n, err)
+	}
+
+	var remain []*recording.Recording
+	if err := db.Find(&remain).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remain) != 1 || remain[0].CID != "cid3" {
+		t.Fatalf("expect only cid3 remain, got %+v", remain)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expect 1 file remain, got %d", len(entries))
+	}
+}
+
+// limitRecorder 记录每次查询的分页大小，其余方法透传给真实存储
+type limitRecorder struct {
+	recording.RecordingStorer
+	limits []int
+}
+
+func (l *limitRecorder) Find(ctx context.Context, bs *[]*recording.Recording, page orm.Pager, opts ...orm.QueryOption) (int64, error) {
+	l.limits = append(l.limits, page.Limit())
+	return l.RecordingStorer.Find(ctx, bs, page, opts...)
+}
+
+type limitRecorderStore struct{ r *limitRecorder }
+
+func (s limitRecorderStore) Recording() recording.RecordingStorer { return s.r }
+
+func TestCleanupBatchSize(t *testing.T) {
+	_, store := newTestDB(t)
+	rec := &limitRecorder{RecordingStorer: store.Recording()}
+	core := recording.NewCore(limitRecorderStore{r: rec}, recording.WithConfig(&conf.ServerRecording{BatchSize: 2}))
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for i := range 5 {
+		path := filepath.Join(dir, string(rune('a'+i))+".mp4")
+		if err := os.WriteFile(path, []byte("mp4"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Add(ctx, &recording.Recording{CID: "cid1", Path: path, Size: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := core.PurgeByChannels(ctx, "cid1"); err != nil || n != 5 {
+		t.Fatalf("expect 5 recordings purged, got %d %v", n, err)
+	}
+	// 2+2+1 三批，再查一次确认已无数据
+	if !slices.Equal(rec.limits, []int{2, 2, 2, 2}) {
+		t.Fatalf("expect batch size 2 for every query, got %v", rec.limits)
+	}
+}
+
+func TestPurgeKeepsRowsWhenRemoveFails(t *testing.T) {
+	db, store := newTestDB(t)
+	core := recording.NewCore(store, recording.WithConfig(&conf.ServerRecording{BatchSize: 1}))
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	// 非空目录无法被 os.Remove 删除，模拟文件删除失败
+	locked := filepath.Join(dir, "locked.mp4")
+	if err := os.MkdirAll(filepath.Join(locked, "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ok := filepath.Join(dir, "ok.mp4")
+	if err := os.WriteFile(ok, []byte("mp4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{locked, ok, filepath.Join(dir, "missing.mp4")} {
+		if err := store.Recording().Add(ctx, &recording.Recording{CID: "cid1", Path: path, Size: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 批大小为 1，失败的记录排在最前，仍需继续处理后续记录，并向调用方报告失败
+	n, err := core.PurgeByChannels(ctx, "cid1")
+	if n != 2 {
+		t.Fatalf("expect 2 recordings purged, got %d", n)
+	}
+	if err == nil {
+		t.Fatal("expect error for recording file failed to delete")
+	}
+
+	var remain []*recording.Recording
+	if err := db.Find(&remain).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remain) != 1 || remain[0].Path != locked {
+		t.Fatalf("expect only the failed recording kept, got %+v", remain)
+	}
+	if _, err := os.Stat(ok); !os.IsNotExist(err) {
+		t.Fatalf("expect file removed, got %v", err)
+	}
+}
+
+func TestCleanupExpiredKeepsEventRecordings(t *testing.T) {
+	db, store := newTestDB(t)
+	if err := db.AutoMigrate(new(event.Event)); err != nil {
+		t.Fatal(err)
+	}
+	core := recording.NewCore(store,
+		recording.WithConfig(&conf.ServerRecording{RetainDays: 3, EventRetainDays: 7, Timezone: "UTC"}),
+		recording.WithEventTable(new(event.Event).TableName()),
+	)
+	ctx := context.Background()
+
+	// 使用本地时区，与录像入库时区一致
+	day := func(n int) time.Time {
+		return time.Now().Truncate(time.Hour).AddDate(0, 0, -n)
+	}
+	dir := t.TempDir()
+	add := func(name, cid string, start time.Time) {
+		path := filepath.Join(dir, name+".mp4")
+		if err := os.WriteFile(path, []byte("mp4"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Recording().Add(ctx, &recording.Recording{
+			CID: cid, Path: path, Size: 3,
+			StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(5 * time.Minute)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("event", "cid1", day(5))
+	add("plain", "cid1", day(5).Add(10*time.Minute))
+	add("other_channel", "cid2", day(5))
+	add("recent", "cid1", day(1))
+	add("too_old", "cid1", day(20))
+
+	for _, e := range []event.Event{
+		{CID: "cid1", StartedAt: orm.Time{Time: day(5).Add(-time.Minute)}, EndedAt: orm.Time{Time: day(5).Add(time.Minute)}},
+		{CID: "cid1", StartedAt: orm.Time{Time: day(20).Add(time.Minute)}, EndedAt: orm.Time{Time: day(20).Add(2 * time.Minute)}},
+	} {
+		if err := db.Create(&e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := core.CleanupExpired(ctx).Deleted; n != 3 {
+		t.Fatalf("expect 3 recordings deleted, got %d", n)
+	}
+
+	var remain []*recording.Recording
+	if err := db.Order("id").Find(&remain).Error; err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range remain {
+		names = append(names, filepath.Base(r.Path))
+	}
+	if !slices.Equal(names, []string{"event.mp4", "recent.mp4"}) {
+		t.Fatalf("expect event and recent recordings kept, got %v", names)
+	}
+}
+
+func TestCleanupExpiredEventOverlapMixedOffset(t *testing.T) {
+	db, store := newTestDB(t)
+	if err := db.AutoMigrate(new(event.Event)); err != nil {
+		t.Fatal(err)
+	}
+	core := recording.NewCore(store,
+		recording.WithConfig(&conf.ServerRecording{RetainDays: 3, EventRetainDays: 7}),
+		recording.WithEventTable(new(event.Event).TableName()),
+	)
+	ctx := context.Background()
+
+	// 录像按 UTC 写入，事件按 +08:00 写入，字符串比较时二者不重叠
+	start := time.Now().Truncate(time.Hour).AddDate(0, 0, -5).UTC()
+	path := filepath.Join(t.TempDir(), "event.mp4")
+	if err := os.WriteFile(path, []byte("mp4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Recording().Add(ctx, &recording.Recording{
+		CID: "cid1", Path: path, Size: 3,
+		StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(5 * time.Minute)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cst := time.FixedZone("CST", 8*3600)
+	e := event.Event{CID: "cid1", StartedAt: orm.Time{Time: start.Add(time.Minute).In(cst)}, EndedAt: orm.Time{Time: start.Add(2 * time.Minute).In(cst)}}
+	if err := db.Create(&e).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := core.CleanupExpired(ctx).Deleted; n != 0 {
+		t.Fatalf("expect recording overlapping event kept, got %d deleted", n)
+	}
+}
+
+func TestCleanupExpiredResult(t *testing.T) {
+	core, store := newTestCore(t, recording.WithConfig(&conf.ServerRecording{RetainDays: 3}))
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	// 非空目录无法被 os.Remove 删除，模拟文件删除失败
+	locked := filepath.Join(dir, "locked.mp4")
+	if err := os.MkdirAll(filepath.Join(locked, "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ok := filepath.Join(dir, "ok.mp4")
+	if err := os.WriteFile(ok, []byte("mp4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().AddDate(0, 0, -10)
+	for _, r := range []struct {
+		path string
+		size int64
+	}{{locked, 100}, {ok, 7}} {
+		if err := store.Recording().Add(ctx, &recording.Recording{
+			CID: "cid1", Path: r.path, Size: r.size,
+			StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(time.Minute)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 失败文件数与释放字节数分别统计，失败的录像不计入释放空间
+	got := core.CleanupExpired(ctx)
+	expect := recording.CleanupResult{Deleted: 1, FilesDeleted: 1, FailedFiles: 1, FreedBytes: 7}
+	if got != expect {
+		t.Fatalf("expect %+v, got %+v", expect, got)
+	}
+}
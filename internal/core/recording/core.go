@@ -1,6 +1,8 @@
 package recording
 
 import (
+	"context"
+	"path/filepath"
 	"strings"
 
 	"github.com/gowvp/owl/internal/conf"
@@ -17,11 +19,22 @@ type SMSProvider interface {
 	StopRecord(app, stream string) error
 }
 
+// ChannelProvider 通道信息提供者接口，解耦录制领域与 ipc 领域
+type ChannelProvider interface {
+	// IsRecordingEnabled 通道是否启用录像，找不到通道时应返回 true
+	IsRecordingEnabled(ctx context.Context, app, stream string) bool
+}
+
 // Core business domain
 type Core struct {
-	store       Storer
-	conf        *conf.ServerRecording
-	smsProvider SMSProvider
+	store           Storer
+	conf            *conf.ServerRecording
+	smsProvider     SMSProvider
+	channelProvider ChannelProvider
+	// 事件表名，配置了事件录像额外保留时用于关联查询
+	eventTable string
+	// alerts 存储告警，Core 按值传递，需共享同一份状态
+	alerts *storageAlerts
 }
 
 type Option func(*Core)
@@ -33,6 +46,20 @@ func WithSMSProvider(provider SMSProvider) Option {
 	}
 }
 
+// WithChannelProvider 注入通道信息提供者，用于判断通道级录像开关
+func WithChannelProvider(provider ChannelProvider) Option {
+	return func(c *Core) {
+		c.channelProvider = provider
+	}
+}
+
+// WithEventTable 注入事件表名，事件与录像位于同一数据库，清理时按通道与时间重叠关联
+func WithEventTable(table string) Option {
+	return func(c *Core) {
+		c.eventTable = table
+	}
+}
+
 // WithConfig 注入录制配置
 func WithConfig(conf *conf.ServerRecording) Option {
 	return func(c *Core) {
@@ -42,7 +69,7 @@ func WithConfig(conf *conf.ServerRecording) Option {
 
 // NewCore create business domain
 func NewCore(store Storer, opts ...Option) Core {
-	c := Core{store: store}
+	c := Core{store: store, alerts: &storageAlerts{}}
 	for _, opt := range opts {
 		opt(&c)
 	}
@@ -67,3 +94,25 @@ func (c Core) GetFullPath(relativePath string) string {
 	}
 	return c.conf.StorageDir + "/" + relativePath
 }
+
+// NodeStorageDir 返回指定媒体节点在本机的录像访问目录，未单独配置时返回 false
+func (c Core) NodeStorageDir(mediaServerID string) (string, bool) {
+	if c.conf == nil || mediaServerID == "" {
+		return "", false
+	}
+	dir, ok := c.conf.NodeStorageDirs[mediaServerID]
+	return dir, ok && dir != ""
+}
+
+// RecordingPath 获取录像文件在本机的完整路径
+// 其它节点产生的录像基于该节点配置的目录解析，其余按 StorageDir 解析
+func (c Core) RecordingPath(rec *Recording) string {
+	dir, ok := c.NodeStorageDir(rec.MediaServerID)
+	if !ok {
+		return c.GetFullPath(rec.Path)
+	}
+	if filepath.IsAbs(rec.Path) {
+		return rec.Path
+	}
+	return filepath.Join(dir, rec.Path)
+}
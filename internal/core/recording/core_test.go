@@ -0,0 +1,50 @@
+package recording
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+)
+
+func TestRecordingPathUsesNodeStorageDir(t *testing.T) {
+	nodeDir := filepath.Join(t.TempDir(), "node-2")
+	c := Core{conf: &conf.ServerRecording{
+		StorageDir:      "configs/recordings",
+		NodeStorageDirs: map[string]string{"node-2": nodeDir, "node-3": ""},
+	}}
+
+	tests := []struct {
+		name   string
+		rec    Recording
+		expect string
+	}{
+		{
+			name:   "local node",
+			rec:    Recording{MediaServerID: "local", Path: "configs/recordings/rtp/ch1/a.mp4"},
+			expect: "configs/recordings/rtp/ch1/a.mp4",
+		},
+		{
+			name:   "legacy row without node",
+			rec:    Recording{Path: "rtp/ch1/a.mp4"},
+			expect: "configs/recordings/rtp/ch1/a.mp4",
+		},
+		{
+			name:   "configured node",
+			rec:    Recording{MediaServerID: "node-2", Path: "record/rtp/ch1/a.mp4"},
+			expect: filepath.Join(nodeDir, "record/rtp/ch1/a.mp4"),
+		},
+		{
+			name:   "node with empty dir falls back",
+			rec:    Recording{MediaServerID: "node-3", Path: "rtp/ch1/a.mp4"},
+			expect: "configs/recordings/rtp/ch1/a.mp4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.RecordingPath(&tt.rec); got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package recording_test
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/gowvp/owl/internal/core/recording/store/recordingdb"
+	"gorm.io/gorm"
+)
+
+// newTestDB 打开内存 sqlite 并迁移录像表
+func newTestDB(t *testing.T) (*gorm.DB, recording.Storer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, recordingdb.NewDB(db).AutoMigrate(true)
+}
+
+// newTestCore 基于内存 sqlite 创建 Core，返回 store 用于准备数据
+func newTestCore(t *testing.T, opts ...recording.Option) (recording.Core, recording.Storer) {
+	t.Helper()
+	_, store := newTestDB(t)
+	return recording.NewCore(store, opts...), store
+}
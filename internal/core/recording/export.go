@@ -0,0 +1,51 @@
+package recording
+
+import (
+	"context"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// MaxExportRecordings 单次打包导出的录像上限，避免一次请求占用过多磁盘 IO
+const MaxExportRecordings = 200
+
+// ExportZipInput 打包导出参数，ids 与 cid+时间范围二选一，ids 优先
+type ExportZipInput struct {
+	IDs     []int64 `json:"ids"`      // 录像 ID 列表
+	CID     string  `json:"cid"`      // 通道 ID
+	StartMs int64   `json:"start_ms"` // 开始时间 (毫秒时间戳)
+	EndMs   int64   `json:"end_ms"`   // 结束时间 (毫秒时间戳)
+}
+
+// FindExportRecordings 解析导出范围，按开始时间升序返回
+// 已标记待删除的录像即将被清理，不参与导出
+func (c Core) FindExportRecordings(ctx context.Context, in *ExportZipInput) ([]*Recording, error) {
+	query := orm.NewQuery(3).Where("delete_flag = ?", false).OrderBy("started_at ASC, id ASC")
+	switch {
+	case len(in.IDs) > 0:
+		if len(in.IDs) > MaxExportRecordings {
+			return nil, reason.ErrBadRequest.Withf("ids exceeds limit %d", MaxExportRecordings)
+		}
+		query.Where("id IN ?", in.IDs)
+	case in.CID != "" && in.StartMs > 0 && in.EndMs > in.StartMs:
+		query.Where("cid = ?", in.CID)
+		query.Where("started_at >= ? AND ended_at <= ?", dbTime(time.UnixMilli(in.StartMs)), dbTime(time.UnixMilli(in.EndMs)))
+	default:
+		return nil, reason.ErrBadRequest.Withf("ids or cid with start_ms/end_ms is required")
+	}
+
+	// 多取一条用于判断是否超出上限
+	items := make([]*Recording, 0, 8)
+	if _, err := c.store.Recording().Find(ctx, &items, &defaultPager{limit: MaxExportRecordings + 1}, query.Encode()...); err != nil {
+		return nil, reason.ErrDB.Withf(`FindExportRecordings err[%s]`, err.Error())
+	}
+	if len(items) > MaxExportRecordings {
+		return nil, reason.ErrBadRequest.Withf("recordings exceeds limit %d, narrow the time range", MaxExportRecordings)
+	}
+	if len(items) == 0 {
+		return nil, reason.ErrNotFound.SetMsg("no recordings to export")
+	}
+	return items, nil
+}
@@ -6,13 +6,18 @@ import (
 )
 
 // StartRecording 启动录制，在流注册时调用
-// 根据配置决定是否录制该流，并通知 ZLM 开始 MP4 录制
+// 根据全局配置和通道级录像开关决定是否录制该流，并通知 ZLM 开始 MP4 录制
 func (c Core) StartRecording(ctx context.Context, channelType, app, stream string) error {
 	if !c.IsEnabled() {
 		slog.DebugContext(ctx, "录制未启用", "app", app, "stream", stream)
 		return nil
 	}
 
+	if c.channelProvider != nil && !c.channelProvider.IsRecordingEnabled(ctx, app, stream) {
+		slog.InfoContext(ctx, "通道已关闭录像，跳过录制", "app", app, "stream", stream)
+		return nil
+	}
+
 	if c.smsProvider == nil {
 		slog.WarnContext(ctx, "SMS provider 未配置，无法启动录制")
 		return nil
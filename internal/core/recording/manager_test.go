@@ -0,0 +1,45 @@
+package recording
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+)
+
+type testSMSProvider struct {
+	started []string
+}
+
+func (t *testSMSProvider) StartRecord(_, stream, _ string, _ int) error {
+	t.started = append(t.started, stream)
+	return nil
+}
+
+func (t *testSMSProvider) StopRecord(_, _ string) error { return nil }
+
+type testChannelProvider map[string]bool
+
+func (t testChannelProvider) IsRecordingEnabled(_ context.Context, _, stream string) bool {
+	enabled, ok := t[stream]
+	return !ok || enabled
+}
+
+func TestStartRecordingSkipDisabledChannel(t *testing.T) {
+	var sms testSMSProvider
+	core := NewCore(nil,
+		WithConfig(&conf.ServerRecording{SegmentSeconds: 300}),
+		WithSMSProvider(&sms),
+		WithChannelProvider(testChannelProvider{"disabled": false, "enabled": true}),
+	)
+
+	ctx := context.Background()
+	for _, stream := range []string{"disabled", "enabled", "unknown"} {
+		if err := core.StartRecording(ctx, "RTMP", "live", stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sms.started) != 2 || sms.started[0] != "enabled" || sms.started[1] != "unknown" {
+		t.Fatalf("expect enabled and unknown started, got %v", sms.started)
+	}
+}
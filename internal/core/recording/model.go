@@ -1,5 +1,7 @@
 package recording
 
+import "math"
+
 // TimeRange 时间轴数据项，表示一段录像的时间范围
 type TimeRange struct {
 	ID          int64   `json:"id"`           // 录像记录 ID
@@ -9,3 +11,61 @@ type TimeRange struct {
 	ObjectCount int     `json:"object_count"` // AI检测对象数量
 	DeleteFlag  bool    `json:"delete_flag"`  // 待删除标记（已被标记即将清理）
 }
+
+// mergeTimeRanges 合并间隔小于 gapMs 的时段，入参需按开始时间升序
+// 合并后保留首段 ID，时长与检测对象数量累加，任一段待删除则整段标记待删除
+func mergeTimeRanges(ranges []TimeRange, gapMs int64) []TimeRange {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	out := make([]TimeRange, 0, len(ranges))
+	cur := ranges[0]
+	for _, r := range ranges[1:] {
+		if r.StartMs-cur.EndMs < gapMs {
+			cur.EndMs = max(cur.EndMs, r.EndMs)
+			cur.Duration += r.Duration
+			cur.ObjectCount += r.ObjectCount
+			cur.DeleteFlag = cur.DeleteFlag || r.DeleteFlag
+			continue
+		}
+		out = append(out, cur)
+		cur = r
+	}
+	return append(out, cur)
+}
+
+// SyncSegment 同步回放中的一段录像，PlaylistMs 为该段在 m3u8 播放列表中的起始位置
+type SyncSegment struct {
+	StartMs    int64 `json:"start_ms"`    // 开始时间（毫秒时间戳）
+	EndMs      int64 `json:"end_ms"`      // 结束时间（毫秒时间戳）
+	PlaylistMs int64 `json:"playlist_ms"` // 播放列表内的起始位置（毫秒）
+}
+
+// SyncStream 单个通道的同步回放信息
+// OffsetMs 为首段录像相对请求开始时间的偏移，前端据此延后该路画面的起播时间；
+// 录像存在断档时，播放列表会跳过空白，需结合 Segments 将墙上时间换算为播放位置
+type SyncStream struct {
+	CID      string        `json:"cid"`
+	URL      string        `json:"url"`       // m3u8 播放地址
+	OffsetMs int64         `json:"offset_ms"` // 首段相对请求开始时间的偏移（毫秒）
+	Segments []SyncSegment `json:"segments"`
+}
+
+// buildSyncStream 根据按开始时间升序的录像计算偏移与每段在播放列表中的位置
+// 播放列表按 EXTINF 时长顺序拼接，故位置按录像时长累加而非墙上时间
+func buildSyncStream(cid string, startMs int64, recs []*Recording) SyncStream {
+	out := SyncStream{CID: cid, Segments: make([]SyncSegment, 0, len(recs))}
+	var pos int64
+	for _, r := range recs {
+		out.Segments = append(out.Segments, SyncSegment{
+			StartMs:    r.StartedAt.UnixMilli(),
+			EndMs:      r.EndedAt.UnixMilli(),
+			PlaylistMs: pos,
+		})
+		pos += int64(math.Round(r.Duration * 1000))
+	}
+	if len(recs) > 0 {
+		out.OffsetMs = recs[0].StartedAt.UnixMilli() - startMs
+	}
+	return out
+}
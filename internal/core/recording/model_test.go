@@ -0,0 +1,120 @@
+package recording
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestMergeTimeRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     []TimeRange
+		gapMs  int64
+		expect []TimeRange
+	}{
+		{
+			name: "adjacent",
+			in: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 1000, Duration: 1, ObjectCount: 1},
+				{ID: 2, StartMs: 1000, EndMs: 2000, Duration: 1, ObjectCount: 2},
+			},
+			gapMs: 500,
+			expect: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 2000, Duration: 2, ObjectCount: 3},
+			},
+		},
+		{
+			name: "gapped",
+			in: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 1000, Duration: 1},
+				{ID: 2, StartMs: 1200, EndMs: 2000, Duration: 0.8, ObjectCount: 1},
+				{ID: 3, StartMs: 5000, EndMs: 6000, Duration: 1, DeleteFlag: true},
+			},
+			gapMs: 500,
+			expect: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 2000, Duration: 1.8, ObjectCount: 1},
+				{ID: 3, StartMs: 5000, EndMs: 6000, Duration: 1, DeleteFlag: true},
+			},
+		},
+		{
+			name: "overlapping",
+			in: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 3000, Duration: 3, ObjectCount: 1},
+				{ID: 2, StartMs: 1000, EndMs: 2000, Duration: 1, ObjectCount: 1, DeleteFlag: true},
+				{ID: 3, StartMs: 2500, EndMs: 4000, Duration: 1.5},
+			},
+			gapMs: 1,
+			expect: []TimeRange{
+				{ID: 1, StartMs: 0, EndMs: 4000, Duration: 5.5, ObjectCount: 2, DeleteFlag: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeTimeRanges(tt.in, tt.gapMs); !reflect.DeepEqual(got, tt.expect) {
+				t.Fatalf("expect %+v, got %+v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestBuildSyncStream(t *testing.T) {
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+	startMs := start.UnixMilli()
+	rec := func(fromSec, durSec int) *Recording {
+		from := start.Add(time.Duration(fromSec) * time.Second)
+		return &Recording{
+			StartedAt: orm.Time{Time: from},
+			EndedAt:   orm.Time{Time: from.Add(time.Duration(durSec) * time.Second)},
+			Duration:  float64(durSec),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		recs   []*Recording
+		expect SyncStream
+	}{
+		{
+			name: "aligned",
+			recs: []*Recording{rec(0, 300), rec(300, 300)},
+			expect: SyncStream{CID: "a", OffsetMs: 0, Segments: []SyncSegment{
+				{StartMs: startMs, EndMs: startMs + 300_000, PlaylistMs: 0},
+				{StartMs: startMs + 300_000, EndMs: startMs + 600_000, PlaylistMs: 300_000},
+			}},
+		},
+		{
+			name: "shifted boundaries",
+			recs: []*Recording{rec(17, 300), rec(317, 283)},
+			expect: SyncStream{CID: "a", OffsetMs: 17_000, Segments: []SyncSegment{
+				{StartMs: startMs + 17_000, EndMs: startMs + 317_000, PlaylistMs: 0},
+				{StartMs: startMs + 317_000, EndMs: startMs + 600_000, PlaylistMs: 300_000},
+			}},
+		},
+		{
+			// 断档期间播放列表不占时长，后一段的播放位置紧接前一段
+			name: "gap",
+			recs: []*Recording{rec(5, 100), rec(400, 200)},
+			expect: SyncStream{CID: "a", OffsetMs: 5_000, Segments: []SyncSegment{
+				{StartMs: startMs + 5_000, EndMs: startMs + 105_000, PlaylistMs: 0},
+				{StartMs: startMs + 400_000, EndMs: startMs + 600_000, PlaylistMs: 100_000},
+			}},
+		},
+		{
+			name:   "empty",
+			recs:   nil,
+			expect: SyncStream{CID: "a", Segments: []SyncSegment{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildSyncStream("a", startMs, tt.recs)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Fatalf("expect %+v, got %+v", tt.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/bz"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// probeTimeout ffprobe 只读取文件头，正常情况下毫秒级完成
+const probeTimeout = 10 * time.Second
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+}
+
+// parseFFProbe 解析 ffprobe -of json 的输出，多条同类轨道取第一条
+func parseFFProbe(data []byte) (*bz.StreamCodec, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	var codec bz.StreamCodec
+	for _, s := range out.Streams {
+		switch s.CodecType {
+		case "video":
+			if codec.Video == bz.CodecUnknown {
+				codec.Video = bz.ParseCodec(s.CodecName)
+				codec.Width, codec.Height = s.Width, s.Height
+			}
+		case "audio":
+			if codec.Audio == bz.CodecUnknown {
+				codec.Audio = bz.ParseCodec(s.CodecName)
+			}
+		}
+	}
+	return &codec, nil
+}
+
+// ProbeCodec 使用 ffprobe 获取文件的音视频编码
+func ProbeCodec(ctx context.Context, path string) (*bz.StreamCodec, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	data, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,width,height",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe: %w", err)
+	}
+	return parseFFProbe(data)
+}
+
+// GetRecordingCodec 探测录像文件的编码，用于回放时决定是否需要纯视频或转码
+func (c Core) GetRecordingCodec(ctx context.Context, id int64) (*bz.StreamCodec, error) {
+	rec, err := c.GetRecording(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := ProbeCodec(ctx, c.RecordingPath(rec))
+	if err != nil {
+		return nil, reason.ErrServer.Withf("probe recording[%d] err[%s]", id, err.Error())
+	}
+	return codec, nil
+}
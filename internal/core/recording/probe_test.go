@@ -0,0 +1,31 @@
+package recording
+
+import (
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/bz"
+)
+
+func TestParseFFProbe(t *testing.T) {
+	data := []byte(`{"streams":[
+		{"codec_type":"video","codec_name":"hevc","width":1920,"height":1080},
+		{"codec_type":"audio","codec_name":"pcm_alaw"},
+		{"codec_type":"data","codec_name":"bin_data"}
+	]}`)
+	codec, err := parseFFProbe(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := bz.StreamCodec{Video: bz.CodecH265, Audio: bz.CodecG711A, Width: 1920, Height: 1080}
+	if *codec != expect {
+		t.Fatalf("expect %+v, got %+v", expect, *codec)
+	}
+
+	codec, err = parseFFProbe([]byte(`{"streams":[{"codec_type":"video","codec_name":"h264"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec.Video != bz.CodecH264 || codec.Audio != bz.CodecUnknown || codec.NeedVideoOnly() {
+		t.Fatalf("unexpected codec %+v", *codec)
+	}
+}
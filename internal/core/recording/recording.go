@@ -3,6 +3,8 @@ package recording
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ixugo/goddd/pkg/orm"
@@ -38,7 +40,7 @@ func (c Core) FindRecordings(ctx context.Context, in *FindRecordingInput) ([]*Re
 		query.Where("stream = ?", in.Stream)
 	}
 	if in.StartMs > 0 && in.EndMs > 0 {
-		query.Where("started_at >= ? AND ended_at <= ?", in.StartAt(), in.EndAt())
+		query.Where("started_at >= ? AND ended_at <= ?", dbTime(in.StartAt()), dbTime(in.EndAt()))
 	}
 
 	items := make([]*Recording, 0, in.Limit())
@@ -101,6 +103,17 @@ func (c Core) GetTimeline(ctx context.Context, in *TimelineInput) ([]TimeRange,
 	if in.CID == "" {
 		return nil, reason.ErrBadRequest.Withf("cid is required")
 	}
+	if (in.StartMs <= 0 || in.EndMs <= 0) && in.Date != "" {
+		loc, err := c.location(in.TZ)
+		if err != nil {
+			return nil, err
+		}
+		start, end, err := dayRange(in.Date, loc)
+		if err != nil {
+			return nil, err
+		}
+		in.StartMs, in.EndMs = start.UnixMilli(), end.UnixMilli()
+	}
 	if in.StartMs <= 0 || in.EndMs <= 0 {
 		return nil, reason.ErrBadRequest.Withf("start_ms and end_ms are required")
 	}
@@ -108,7 +121,7 @@ func (c Core) GetTimeline(ctx context.Context, in *TimelineInput) ([]TimeRange,
 	query := orm.NewQuery(2).OrderBy("started_at ASC")
 	query.Where("cid = ?", in.CID)
 	// 查询时间范围内有重叠的录像
-	query.Where("started_at < ? AND ended_at > ?", in.EndAt(), in.StartAt())
+	query.Where("started_at < ? AND ended_at > ?", dbTime(in.EndAt()), dbTime(in.StartAt()))
 
 	var recordings []*Recording
 	// 使用默认分页器避免 nil pointer
@@ -119,7 +132,15 @@ func (c Core) GetTimeline(ctx context.Context, in *TimelineInput) ([]TimeRange,
 	}
 
 	result := make([]TimeRange, 0, len(recordings))
+	// 流媒体重复回调会为同一文件产生多条记录，按文件去重，避免合并时重复累加时长
+	seen := make(map[string]struct{}, len(recordings))
 	for _, r := range recordings {
+		if r.Path != "" {
+			if _, ok := seen[r.Path]; ok {
+				continue
+			}
+			seen[r.Path] = struct{}{}
+		}
 		result = append(result, TimeRange{
 			ID:          r.ID,
 			StartMs:     r.StartedAt.UnixMilli(),
@@ -129,9 +150,49 @@ func (c Core) GetTimeline(ctx context.Context, in *TimelineInput) ([]TimeRange,
 			DeleteFlag:  r.DeleteFlag,
 		})
 	}
+	if in.MergeGapMs > 0 {
+		result = mergeTimeRanges(result, in.MergeGapMs)
+	}
 	return result, nil
 }
 
+// maxSyncChannels 单次同步回放的通道上限，避免一次请求扫描过多通道
+const maxSyncChannels = 16
+
+// SyncPlayback 计算多通道同步回放所需的偏移信息
+// 录像筛选条件与 m3u8 播放列表保持一致，确保偏移与播放列表内容对应
+func (c Core) SyncPlayback(ctx context.Context, in *SyncPlaybackInput) ([]SyncStream, error) {
+	if in.StartMs <= 0 || in.EndMs <= 0 || in.EndMs <= in.StartMs {
+		return nil, reason.ErrBadRequest.Withf("start_ms and end_ms are required")
+	}
+	cids := make([]string, 0, 4)
+	for cid := range strings.SplitSeq(in.CIDs, ",") {
+		if cid = strings.TrimSpace(cid); cid != "" && !slices.Contains(cids, cid) {
+			cids = append(cids, cid)
+		}
+	}
+	if len(cids) == 0 {
+		return nil, reason.ErrBadRequest.Withf("cids is required")
+	}
+	if len(cids) > maxSyncChannels {
+		return nil, reason.ErrBadRequest.Withf("cids exceeds limit %d", maxSyncChannels)
+	}
+
+	out := make([]SyncStream, 0, len(cids))
+	for _, cid := range cids {
+		query := orm.NewQuery(2).OrderBy("started_at ASC")
+		query.Where("cid = ?", cid)
+		query.Where("started_at >= ? AND ended_at <= ?", dbTime(in.StartAt()), dbTime(in.EndAt()))
+
+		var recordings []*Recording
+		if _, err := c.store.Recording().Find(ctx, &recordings, &defaultPager{limit: 10000}, query.Encode()...); err != nil {
+			return nil, reason.ErrDB.Withf(`SyncPlayback cid[%s] err[%s]`, cid, err.Error())
+		}
+		out = append(out, buildSyncStream(cid, in.StartMs, recordings))
+	}
+	return out, nil
+}
+
 // defaultPager 内部使用的分页器，避免传入 nil 导致空指针
 type defaultPager struct {
 	limit int
@@ -175,20 +236,24 @@ func (c Core) HasRecordings(ctx context.Context, cids []string) (map[string]bool
 }
 
 // GetMonthlyStats 获取月度录像统计
-// 返回指定月份每天是否有录像的位图字符串
+// 返回指定月份每天是否有录像的位图字符串，日期按配置或 tz 参数指定的时区划分
 func (c Core) GetMonthlyStats(ctx context.Context, in *MonthlyStatsInput) (*MonthlyStatsOutput, error) {
 	if in.Year <= 0 || in.Month < 1 || in.Month > 12 {
 		return nil, reason.ErrBadRequest.Withf("invalid year or month")
 	}
+	loc, err := c.location(in.TZ)
+	if err != nil {
+		return nil, err
+	}
 
 	// 计算该月的第一天和最后一天
-	firstDay := time.Date(in.Year, time.Month(in.Month), 1, 0, 0, 0, 0, time.Local)
+	firstDay := time.Date(in.Year, time.Month(in.Month), 1, 0, 0, 0, 0, loc)
 	lastDay := firstDay.AddDate(0, 1, 0).Add(-time.Nanosecond)
 	daysInMonth := lastDay.Day()
 
 	// 查询该月有录像的日期
 	query := orm.NewQuery(2)
-	query.Where("started_at >= ? AND started_at <= ?", orm.Time{Time: firstDay}, orm.Time{Time: lastDay})
+	query.Where("started_at >= ? AND started_at <= ?", dbTime(firstDay), dbTime(lastDay))
 	if in.CID != "" {
 		query.Where("cid = ?", in.CID)
 	}
@@ -196,34 +261,19 @@ func (c Core) GetMonthlyStats(ctx context.Context, in *MonthlyStatsInput) (*Mont
 	var recordings []*Recording
 	// 使用默认分页器避免 nil pointer
 	pager := &defaultPager{limit: 10000}
-	_, err := c.store.Recording().Find(ctx, &recordings, pager, query.Encode()...)
-	if err != nil {
+	if _, err := c.store.Recording().Find(ctx, &recordings, pager, query.Encode()...); err != nil {
 		return nil, reason.ErrDB.Withf(`GetMonthlyStats err[%s]`, err.Error())
 	}
 
-	// 统计每天是否有录像
-	dayHasVideo := make([]bool, daysInMonth)
+	starts := make([]time.Time, 0, len(recordings))
 	for _, r := range recordings {
-		day := r.StartedAt.Day()
-		if day >= 1 && day <= daysInMonth {
-			dayHasVideo[day-1] = true
-		}
-	}
-
-	// 构建位图字符串
-	bitmap := make([]byte, daysInMonth)
-	for i, has := range dayHasVideo {
-		if has {
-			bitmap[i] = '1'
-		} else {
-			bitmap[i] = '0'
-		}
+		starts = append(starts, r.StartedAt.Time)
 	}
 
 	return &MonthlyStatsOutput{
 		Year:     in.Year,
 		Month:    in.Month,
 		Days:     daysInMonth,
-		HasVideo: string(bitmap),
+		HasVideo: monthBitmap(starts, in.Year, in.Month, loc),
 	}, nil
 }
@@ -26,12 +26,25 @@ type AddRecordingInput struct {
 	Duration  float64  `json:"duration"`   // 持续时长（秒）
 	Path      string   `json:"path"`       // 文件相对路径
 	Size      int64    `json:"size"`       // 文件大小（字节）
+	Thumbnail string   `json:"thumbnail"`  // 封面图相对路径
+
+	MediaServerID string `json:"-"` // 产生录像的媒体节点 ID（由 webhook 填充）
 }
 
 // TimelineInput 时间轴查询参数
 type TimelineInput struct {
 	web.DateFilter
-	CID string `form:"cid"` // 通道 ID
+	CID        string `form:"cid"`          // 通道 ID
+	MergeGapMs int64  `form:"merge_gap_ms"` // 间隔小于该值的相邻录像合并为一段，0 表示不合并
+	// 未指定 start_ms/end_ms 时按日期查询一整天
+	Date string `form:"date"` // 日期 YYYY-MM-DD
+	TZ   string `form:"tz"`   // 日期所在时区，为空使用配置
+}
+
+// SyncPlaybackInput 多通道同步回放参数
+type SyncPlaybackInput struct {
+	web.DateFilter
+	CIDs string `form:"cids"` // 通道 ID 列表，逗号分隔
 }
 
 // MonthlyStatsInput 月度统计查询参数
@@ -39,6 +52,7 @@ type MonthlyStatsInput struct {
 	CID   string `form:"cid"`   // 通道 ID（可选，不传则查所有通道）
 	Year  int    `form:"year"`  // 年份，如 2024
 	Month int    `form:"month"` // 月份，1-12
+	TZ    string `form:"tz"`    // 按天统计使用的时区，如 Asia/Shanghai，为空使用配置
 }
 
 // MonthlyStatsOutput 月度统计输出
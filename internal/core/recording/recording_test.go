@@ -0,0 +1,45 @@
+package recording_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestGetTimelineDedupPath(t *testing.T) {
+	core, store := newTestCore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+	segment := func(path string, offset time.Duration) *recording.Recording {
+		start := base.Add(offset)
+		return &recording.Recording{
+			CID: "cid1", Path: path, Duration: 60,
+			StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(time.Minute)},
+		}
+	}
+	// 同一文件被重复回调入库两次
+	for _, rec := range []*recording.Recording{
+		segment("rtp/cid1/a.mp4", 0),
+		segment("rtp/cid1/a.mp4", 0),
+		segment("rtp/cid1/b.mp4", time.Minute),
+	} {
+		if err := store.Recording().Add(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var in recording.TimelineInput
+	in.CID, in.MergeGapMs = "cid1", 1000
+	in.StartMs, in.EndMs = base.UnixMilli(), base.Add(time.Hour).UnixMilli()
+	got, err := core.GetTimeline(ctx, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Duration != 120 {
+		t.Fatalf("expect one merged range of 120s, got %+v", got)
+	}
+}
@@ -0,0 +1,103 @@
+package recording
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ixugo/goddd/pkg/system"
+)
+
+// resolveDir 转为绝对路径并解析软链接，确保统计的是实际写入的文件系统
+// 目录不存在时无法解析软链接，返回绝对路径
+func resolveDir(dir string) string {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(system.Getwd(), dir)
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return real
+	}
+	return filepath.Clean(dir)
+}
+
+// storageDir 录像存储目录的真实路径
+func (c Core) storageDir() string {
+	dir := c.conf.StorageDir
+	if dir == "" {
+		dir = "./recordings"
+	}
+	return resolveDir(dir)
+}
+
+// deviceOf 返回路径所在的设备号
+func deviceOf(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// mountPoint 沿父目录向上查找，设备号变化前的最后一级即为挂载点
+func mountPoint(path string) (string, error) {
+	dev, err := deviceOf(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		pdev, err := deviceOf(parent)
+		if err != nil || pdev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
+
+// CheckStorage 启动时校验录像目录：可写，且位于期望的挂载点上
+// 数据盘未挂载时录像会写入根分区，清理也会统计错误的文件系统，需尽早暴露
+func (c Core) CheckStorage() (dir, mount string, err error) {
+	if c.conf == nil {
+		return "", "", fmt.Errorf("recording config is nil")
+	}
+	dir = c.storageDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return dir, "", fmt.Errorf("create storage dir: %w", err)
+	}
+	// 目录可能刚创建，重新解析软链接
+	dir = resolveDir(dir)
+
+	f, err := os.CreateTemp(dir, ".owl_write_check_*")
+	if err != nil {
+		return dir, "", fmt.Errorf("storage dir not writable: %w", err)
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+
+	if mount, err = mountPoint(dir); err != nil {
+		return dir, "", err
+	}
+
+	if expect := c.conf.ExpectedMount; expect != "" {
+		expect = resolveDir(expect)
+		if mount != expect {
+			return dir, mount, fmt.Errorf("storage dir %s is on mount %s, expected %s", dir, mount, expect)
+		}
+	}
+	return dir, mount, nil
+}
+
+// logStorage 记录录像目录的真实路径与挂载点，校验失败时告警但不阻止启动
+func (c Core) logStorage() {
+	dir, mount, err := c.CheckStorage()
+	if err != nil {
+		slog.Error("recording storage check failed", "storage_dir", c.conf.StorageDir, "resolved", dir, "mount", mount, "err", err)
+		return
+	}
+	slog.Info("recording storage", "storage_dir", c.conf.StorageDir, "resolved", dir, "mount", mount)
+}
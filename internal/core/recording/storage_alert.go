@@ -0,0 +1,146 @@
+package recording
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+const (
+	// StorageAlertDiskFull 清理后可用空间仍不足，流媒体将无法继续写录像
+	StorageAlertDiskFull = "disk_full"
+	// StorageAlertWriteFailed 流媒体上报的录像切片为空，写文件失败
+	StorageAlertWriteFailed = "write_failed"
+)
+
+// diskFullFreeBytes 可用空间低于该值时视为磁盘已满，未配置清理策略时同样生效
+const diskFullFreeBytes = 64 << 20
+
+// StorageAlert 录像存储告警，同一原因（写失败按通道区分）持续期间只通知一次，恢复后清除
+type StorageAlert struct {
+	Reason     string    `json:"reason"`      // 告警原因 disk_full/write_failed
+	Message    string    `json:"message"`     // 告警说明
+	StorageDir string    `json:"storage_dir"` // 录像目录
+	FreeBytes  uint64    `json:"free_bytes"`  // 告警时的可用空间
+	Usage      float64   `json:"usage"`       // 告警时的磁盘使用率（百分比）
+	CID        string    `json:"cid"`         // 写失败的通道，磁盘告警为空
+	Since      time.Time `json:"since"`       // 告警开始时间
+}
+
+// key 告警去重键，写失败按通道区分，某个通道恢复不影响其它通道的告警
+func (a StorageAlert) key() string {
+	if a.CID == "" {
+		return a.Reason
+	}
+	return a.Reason + ":" + a.CID
+}
+
+// StorageAlertHandler 新告警产生时回调，由上层注入以持久化到事件
+type StorageAlertHandler func(ctx context.Context, alert StorageAlert)
+
+// WithStorageAlertHandler 注入存储告警回调
+func WithStorageAlertHandler(fn StorageAlertHandler) Option {
+	return func(c *Core) {
+		c.alerts.handler = fn
+	}
+}
+
+type storageAlerts struct {
+	active  conc.Map[string, StorageAlert]
+	handler StorageAlertHandler
+}
+
+// StorageAlerts 当前未恢复的存储告警，按原因与通道排序
+func (c Core) StorageAlerts() []StorageAlert {
+	if c.alerts == nil {
+		return nil
+	}
+	var out []StorageAlert
+	c.alerts.active.Range(func(_ string, v StorageAlert) bool {
+		out = append(out, v)
+		return true
+	})
+	slices.SortFunc(out, func(a, b StorageAlert) int {
+		return strings.Compare(a.key(), b.key())
+	})
+	return out
+}
+
+// raiseStorageAlert 产生告警，已存在同一告警时只刷新空间信息，不重复通知
+func (c Core) raiseStorageAlert(ctx context.Context, alert StorageAlert) {
+	if c.alerts == nil {
+		return
+	}
+	key := alert.key()
+	if prev, ok := c.alerts.active.Load(key); ok {
+		alert.Since = prev.Since
+		c.alerts.active.Store(key, alert)
+		return
+	}
+	if alert.Since.IsZero() {
+		alert.Since = time.Now()
+	}
+	c.alerts.active.Store(key, alert)
+	slog.ErrorContext(ctx, "recording storage alert",
+		"reason", alert.Reason,
+		"message", alert.Message,
+		"storage_dir", alert.StorageDir,
+		"free_bytes", alert.FreeBytes,
+		"usage", alert.Usage,
+		"cid", alert.CID,
+	)
+	if c.alerts.handler != nil {
+		c.alerts.handler(ctx, alert)
+	}
+}
+
+// clearStorageAlert 告警恢复，key 见 StorageAlert.key
+func (c Core) clearStorageAlert(key string) {
+	if c.alerts == nil {
+		return
+	}
+	if alert, ok := c.alerts.active.LoadAndDelete(key); ok {
+		slog.Info("recording storage alert recovered", "reason", alert.Reason, "cid", alert.CID, "since", alert.Since)
+	}
+}
+
+// checkDiskFull 清理结束后检查磁盘，可用空间低于下限、低于 diskFullFreeBytes，
+// 或超过使用率阈值但已无录像可删时告警，否则清除磁盘告警
+func (c Core) checkDiskFull(dir string, policy diskPolicy, state diskState, exhausted bool) {
+	full := policy.belowFloor(state) || state.FreeBytes < diskFullFreeBytes || (exhausted && policy.overThreshold(state))
+	if !full {
+		c.clearStorageAlert(StorageAlertDiskFull)
+		return
+	}
+	c.raiseStorageAlert(context.Background(), StorageAlert{
+		Reason:     StorageAlertDiskFull,
+		Message:    "录像磁盘空间不足，清理后仍无法释放足够空间",
+		StorageDir: dir,
+		FreeBytes:  state.FreeBytes,
+		Usage:      state.Usage,
+	})
+}
+
+// ReportRecordWrite 记录流媒体上报的录像切片，大小为 0 视为写文件失败，同一通道的正常切片清除该通道的写失败告警
+func (c Core) ReportRecordWrite(ctx context.Context, cid string, size int64) {
+	alert := StorageAlert{
+		Reason:  StorageAlertWriteFailed,
+		Message: "流媒体写录像文件失败",
+		CID:     cid,
+	}
+	if size > 0 {
+		c.clearStorageAlert(alert.key())
+		return
+	}
+	if c.conf != nil {
+		alert.StorageDir = c.storageDir()
+		if state, err := statDisk(alert.StorageDir); err == nil {
+			alert.FreeBytes, alert.Usage = state.FreeBytes, state.Usage
+		}
+	}
+	c.raiseStorageAlert(ctx, alert)
+}
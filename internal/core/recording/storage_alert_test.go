@@ -0,0 +1,76 @@
+package recording
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+// emptyRecordings 没有可删除的录像，模拟清理已无空间可释放
+type emptyRecordings struct{ RecordingStorer }
+
+func (emptyRecordings) Find(context.Context, *[]*Recording, orm.Pager, ...orm.QueryOption) (int64, error) {
+	return 0, nil
+}
+
+type emptyStore struct{}
+
+func (emptyStore) Recording() RecordingStorer { return emptyRecordings{} }
+
+func TestDiskFullRaisesStorageAlert(t *testing.T) {
+	store := emptyStore{}
+	dir := t.TempDir()
+
+	var alerts []StorageAlert
+	c := NewCore(store,
+		WithConfig(&conf.ServerRecording{StorageDir: dir, MinFreeBytes: 1 << 30}),
+		WithStorageAlertHandler(func(_ context.Context, a StorageAlert) { alerts = append(alerts, a) }),
+	)
+
+	// 模拟磁盘已满，已无录像可删
+	state := diskState{Usage: 99.9, FreeBytes: 10 << 20}
+	orig := statDisk
+	statDisk = func(string) (diskState, error) { return state, nil }
+	defer func() { statDisk = orig }()
+
+	c.cleanupByDiskUsage()
+	got := c.StorageAlerts()
+	if len(got) != 1 || got[0].Reason != StorageAlertDiskFull || got[0].FreeBytes != 10<<20 {
+		t.Fatalf("expect disk full alert with free bytes, got %+v", got)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expect alert persisted once, got %d", len(alerts))
+	}
+
+	// 持续告警期间不重复通知，只刷新可用空间
+	state.FreeBytes = 5 << 20
+	c.cleanupByDiskUsage()
+	if got := c.StorageAlerts(); len(alerts) != 1 || len(got) != 1 || got[0].FreeBytes != 5<<20 {
+		t.Fatalf("expect single refreshed alert, got %d %+v", len(alerts), got)
+	}
+
+	// 空间恢复后清除
+	state = diskState{Usage: 50, FreeBytes: 100 << 30}
+	c.cleanupByDiskUsage()
+	if got := c.StorageAlerts(); len(got) != 0 {
+		t.Fatalf("expect alert cleared, got %+v", got)
+	}
+
+	// 流媒体上报空切片视为写失败，按通道告警
+	c.ReportRecordWrite(context.Background(), "cid1", 0)
+	c.ReportRecordWrite(context.Background(), "cid2", 0)
+	if got := c.StorageAlerts(); len(got) != 2 || got[0].Reason != StorageAlertWriteFailed || got[0].CID != "cid1" || got[1].CID != "cid2" {
+		t.Fatalf("expect write failed alert per channel, got %+v", got)
+	}
+	// 其它通道正常写入不能清除写失败告警
+	c.ReportRecordWrite(context.Background(), "cid3", 1024)
+	if got := c.StorageAlerts(); len(got) != 2 {
+		t.Fatalf("expect write alerts kept, got %+v", got)
+	}
+	c.ReportRecordWrite(context.Background(), "cid1", 1024)
+	if got := c.StorageAlerts(); len(got) != 1 || got[0].CID != "cid2" || len(alerts) != 3 {
+		t.Fatalf("expect only cid1 alert cleared, got %+v (notified %d)", got, len(alerts))
+	}
+}
@@ -0,0 +1,62 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+)
+
+func TestCheckStorageResolvesSymlink(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(root, "data", "recordings")
+	if err := os.MkdirAll(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+
+	c := Core{conf: &conf.ServerRecording{StorageDir: link}}
+	if got := c.storageDir(); got != real {
+		t.Fatalf("expect storage dir resolved to %s, got %s", real, got)
+	}
+
+	dir, mount, err := c.CheckStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != real {
+		t.Fatalf("expect %s, got %s", real, dir)
+	}
+	if !strings.HasPrefix(real, mount) {
+		t.Fatalf("mount %s should be an ancestor of %s", mount, real)
+	}
+	// 写入校验的临时文件不能残留
+	if entries, _ := os.ReadDir(real); len(entries) != 0 {
+		t.Fatalf("expect write check file removed, got %d entries", len(entries))
+	}
+
+	// 期望挂载点写成软链接同样可以匹配
+	mountLink := filepath.Join(root, "mount")
+	if err := os.Symlink(mount, mountLink); err != nil {
+		t.Fatal(err)
+	}
+	c.conf.ExpectedMount = mountLink
+	if _, _, err := c.CheckStorage(); err != nil {
+		t.Fatalf("expect mount matched through symlink, got %v", err)
+	}
+
+	c.conf.ExpectedMount = real
+	if real != mount {
+		if _, _, err := c.CheckStorage(); err == nil {
+			t.Fatal("expect mount mismatch error")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package recording
+
+import (
+	"time"
+	// 容器镜像可能缺少系统时区库，内嵌保证配置的时区可解析
+	_ "time/tzdata"
+
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// location 按天统计使用的时区，请求参数优先，其次为配置，均为空时使用服务器本地时区
+func (c Core) location(tz string) (*time.Location, error) {
+	if tz == "" && c.conf != nil {
+		tz = c.conf.Timezone
+	}
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, reason.ErrBadRequest.Withf("invalid tz[%s]", tz)
+	}
+	return loc, nil
+}
+
+// dbTime 查询条件统一转换为服务器本地时区，与录像入库格式（含历史数据）一致
+// sqlite 按字符串比较时间，混入不同时区偏移会导致范围查询错位
+func dbTime(t time.Time) orm.Time {
+	return orm.Time{Time: t.In(time.Local)}
+}
+
+// startOfDay 返回 t 在 loc 时区当天零点
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// dayRange 解析 YYYY-MM-DD，返回该日在 loc 时区的起止时间，跨夏令时当天不一定为 24 小时
+func dayRange(date string, loc *time.Location) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation(time.DateOnly, date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, reason.ErrBadRequest.Withf("invalid date[%s]", date)
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+// monthBitmap 按 loc 时区的日历日统计录像，第 n 位为 '1' 表示该月第 n 天有录像
+func monthBitmap(starts []time.Time, year, month int, loc *time.Location) string {
+	firstDay := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+	days := firstDay.AddDate(0, 1, -1).Day()
+
+	bitmap := make([]byte, days)
+	for i := range bitmap {
+		bitmap[i] = '0'
+	}
+	for _, t := range starts {
+		t = t.In(loc)
+		if t.Year() != year || int(t.Month()) != month {
+			continue
+		}
+		bitmap[t.Day()-1] = '1'
+	}
+	return string(bitmap)
+}
+
+// retentionCutoff 保留期截止时间，对齐到 loc 时区的零点
+// 同一日历日的录像一起过期，至少保留 days 个完整的 24 小时
+func retentionCutoff(now time.Time, days int, loc *time.Location) time.Time {
+	return startOfDay(now.AddDate(0, 0, -days), loc)
+}
@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthBitmapAcrossTimezone(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	newYork := time.FixedZone("EST", -5*3600)
+	starts := []time.Time{
+		// 上海 4 月 1 日凌晨 1 点，UTC 仍为 3 月 31 日
+		time.Date(2026, 3, 31, 17, 0, 0, 0, time.UTC),
+		// 上海与 UTC 均为 4 月 15 日，纽约为 4 月 14 日
+		time.Date(2026, 4, 15, 3, 0, 0, 0, time.UTC),
+		// 上海为 5 月 1 日，纽约仍为 4 月 30 日
+		time.Date(2026, 4, 30, 20, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		days []int
+	}{
+		{name: "utc", loc: time.UTC, days: []int{15, 30}},
+		{name: "shanghai", loc: shanghai, days: []int{1, 15}},
+		{name: "new york", loc: newYork, days: []int{14, 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := monthBitmap(starts, 2026, 4, tt.loc)
+			if len(got) != 30 {
+				t.Fatalf("april should have 30 days, got %d", len(got))
+			}
+			expect := []byte(got)
+			for i := range expect {
+				expect[i] = '0'
+			}
+			for _, d := range tt.days {
+				expect[d-1] = '1'
+			}
+			if got != string(expect) {
+				t.Fatalf("expect %s, got %s", expect, got)
+			}
+		})
+	}
+}
+
+func TestDayRange(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	start, end, err := dayRange("2026-04-01", shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2026, 3, 31, 16, 0, 0, 0, time.UTC)) || end.Sub(start) != 24*time.Hour {
+		t.Fatalf("unexpected range %s - %s", start.UTC(), end.UTC())
+	}
+	if _, _, err := dayRange("2026/04/01", shanghai); err == nil {
+		t.Fatal("expect error for invalid date")
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// 上海时间 4 月 10 日 01:00
+	now := time.Date(2026, 4, 9, 17, 0, 0, 0, time.UTC)
+	got := retentionCutoff(now, 3, shanghai)
+	expect := time.Date(2026, 4, 7, 0, 0, 0, 0, shanghai)
+	if !got.Equal(expect) {
+		t.Fatalf("expect %s, got %s", expect, got)
+	}
+	if now.Sub(got) < 3*24*time.Hour {
+		t.Fatalf("cutoff should keep at least 3 full days, got %s", now.Sub(got))
+	}
+}
+
+func TestLocation(t *testing.T) {
+	c := Core{}
+	if loc, err := c.location(""); err != nil || loc != time.Local {
+		t.Fatalf("expect local timezone, got %v %v", loc, err)
+	}
+	if loc, err := c.location("Asia/Shanghai"); err != nil || loc.String() != "Asia/Shanghai" {
+		t.Fatalf("expect Asia/Shanghai, got %v %v", loc, err)
+	}
+	if _, err := c.location("Mars/Base"); err == nil {
+		t.Fatal("expect error for unknown timezone")
+	}
+}
+
+func TestDBTimeUsesLocal(t *testing.T) {
+	// 历史录像以本地时区入库，查询条件须使用相同的时区格式
+	in := time.Date(2026, 4, 1, 0, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	got := dbTime(in)
+	if got.Location() != time.Local || !got.Equal(in) {
+		t.Fatalf("expect same instant in local timezone, got %v", got.Time)
+	}
+}
@@ -3,6 +3,7 @@ package sms
 import (
 	"context"
 
+	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/gowvp/owl/pkg/zlm"
 )
 
@@ -33,7 +34,25 @@ type Driver interface {
 
 	GetStreamLiveAddr(ctx context.Context, ms *MediaServer, httpPrefix, host, app, stream string) StreamLiveAddr
 
+	// ListStreams 查询节点当前在线的流，同一流的多个协议只返回一条
+	ListStreams(ctx context.Context, ms *MediaServer) ([]StreamInfo, error)
+
+	// GetStreamCodec 查询在线流的音视频编码
+	GetStreamCodec(ctx context.Context, ms *MediaServer, app, stream string) (*bz.StreamCodec, error)
+
+	// GetMediaInfo 查询在线流的分辨率、编码、码率与帧率，流不在线时返回 ErrStreamNotActive
+	GetMediaInfo(ctx context.Context, ms *MediaServer, app, stream string) (*MediaInfo, error)
+
 	// Recording Operations
 	StartRecord(ctx context.Context, ms *MediaServer, req *zlm.StartRecordRequest) (*zlm.StartRecordResponse, error)
 	StopRecord(ctx context.Context, ms *MediaServer, req *zlm.StopRecordRequest) (*zlm.StopRecordResponse, error)
+
+	// Cascade Operations
+	StartSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StartSendRTPRequest) (*zlm.StartSendRTPResponse, error)
+	StopSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StopSendRTPRequest) error
+}
+
+// ConfigCacher 缓存了节点配置的驱动实现此接口（可选实现），节点配置修改或流媒体重启后清除缓存
+type ConfigCacher interface {
+	InvalidateConfig(serverID string)
 }
@@ -1,6 +1,9 @@
 package sms
 
-import "github.com/gowvp/owl/pkg/zlm"
+import (
+	"github.com/gowvp/owl/internal/core/bz"
+	"github.com/gowvp/owl/pkg/zlm"
+)
 
 type AddStreamProxyRequest struct {
 	App     string `json:"app"`      // 添加的流的应用名，例如 live
@@ -8,6 +11,17 @@ type AddStreamProxyRequest struct {
 	URL     string `json:"url"`      // 拉流地址，例如 rtmp://live.hkstv.hk.lxdns.com/live/hks2
 	RTPType int    `json:"rtp_type"` // rtsp 拉流时，拉流方式，0：tcp，1：udp，2：组播
 
+	TimeoutSec  int   `json:"timeout_sec"`            // 拉流超时时间(秒)，0 使用默认值
+	RetryCount  int   `json:"retry_count"`            // 拉流重试次数，0 使用默认值，-1 无限重试
+	EnableAudio *bool `json:"enable_audio,omitempty"` // 是否开启音频，nil 开启
+	AutoClose   *bool `json:"auto_close,omitempty"`   // 无人观看是否自动关闭，nil 关闭
+
+	// 协议开关，按需关闭以节省流媒体资源，nil 均为开启
+	EnableRTSP    *bool `json:"enable_rtsp,omitempty"`     // 是否转 rtsp 协议
+	EnableRTMP    *bool `json:"enable_rtmp,omitempty"`     // 是否转 rtmp/flv 协议
+	EnableHLSFMP4 *bool `json:"enable_hls_fmp4,omitempty"` // 是否转 hls-fmp4 协议
+	AddMuteAudio  *bool `json:"add_mute_audio,omitempty"`  // 无音频时是否添加静音 aac 音频
+
 	// Vhost         string  `json:"vhost"`                     // 添加的流的虚拟主机，例如__defaultVhost__
 	// RetryCount    int     `json:"retry_count"`               // 拉流重试次数，默认为-1 无限重试
 	// TimeoutSec    float32 `json:"timeout_sec"`               // 拉流超时时间，单位秒，float 类型
@@ -33,6 +47,54 @@ type AddStreamProxyRequest struct {
 	// AutoClose     *bool   `json:"auto_close,omitempty"`      // 无人观看是否自动关闭流(不触发无人观看 hook)
 }
 
+// pullTimeoutMs 拉流超时时间(毫秒)，未设置时使用默认值
+func (r *AddStreamProxyRequest) pullTimeoutMs() int {
+	if r.TimeoutSec > 0 {
+		return r.TimeoutSec * 1000
+	}
+	return PullTimeoutMs
+}
+
+// EffectiveTimeoutSec 实际生效的拉流超时时间(秒)
+func (r *AddStreamProxyRequest) EffectiveTimeoutSec() int {
+	return r.pullTimeoutMs() / 1000
+}
+
+// EffectiveRetryCount 实际生效的拉流重试次数，-1 表示无限重试
+func (r *AddStreamProxyRequest) EffectiveRetryCount() int {
+	if r.RetryCount != 0 {
+		return r.RetryCount
+	}
+	return PullRetryNum
+}
+
+func boolOr(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// StreamInfo 节点上在线的流
+type StreamInfo struct {
+	App    string `json:"app"`
+	Stream string `json:"stream"`
+}
+
+// MediaInfo 在线流的画质信息，各流媒体返回格式不同，统一为此结构
+type MediaInfo struct {
+	App         string   `json:"app"`
+	Stream      string   `json:"stream"`
+	Video       bz.Codec `json:"video"`        // 视频编码
+	Audio       bz.Codec `json:"audio"`        // 音频编码，无音频时为空
+	Width       int      `json:"width"`        // 视频宽
+	Height      int      `json:"height"`       // 视频高
+	FPS         float64  `json:"fps"`          // 帧率
+	BitrateKbps int      `json:"bitrate_kbps"` // 输入码率，单位 kbit/s
+	Readers     int      `json:"readers"`      // 观看人数
+	AliveSecond int64    `json:"alive_second"` // 流存活时长，流媒体不提供时为 0
+}
+
 type GetSnapRequest struct {
 	zlm.GetSnapRequest
 	// lalmax
@@ -2,12 +2,17 @@ package sms
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"net/url"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/gowvp/owl/pkg/lalmax"
 	"github.com/gowvp/owl/pkg/zlm"
 )
@@ -44,8 +49,8 @@ func (l *LalmaxDriver) AddStreamProxy(ctx context.Context, ms *MediaServer, req
 	resp, err := engine.CtrlStartRelayPull(ctx, lalmax.ApiCtrlStartRelayPullReq{
 		StreamName:    req.Stream,
 		Url:           req.URL,
-		PullTimeoutMs: PullTimeoutMs,
-		PullRetryNum:  PullRetryNum,
+		PullTimeoutMs: req.pullTimeoutMs(),
+		PullRetryNum:  req.EffectiveRetryCount(),
 		RtspMode:      req.RTPType,
 	})
 	if err != nil {
@@ -56,6 +61,74 @@ func (l *LalmaxDriver) AddStreamProxy(ctx context.Context, ms *MediaServer, req
 	return &result, nil
 }
 
+// GetStreamCodec implements Driver.
+func (l *LalmaxDriver) GetStreamCodec(ctx context.Context, ms *MediaServer, _, stream string) (*bz.StreamCodec, error) {
+	engine := l.withConfig(ms)
+	group, err := engine.GetStatGroup(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	return &bz.StreamCodec{
+		Video:  bz.ParseCodec(group.VideoCodec),
+		Audio:  bz.ParseCodec(group.AudioCodec),
+		Width:  group.VideoWidth,
+		Height: group.VideoHeight,
+	}, nil
+}
+
+// GetMediaInfo implements Driver.
+func (l *LalmaxDriver) GetMediaInfo(ctx context.Context, ms *MediaServer, _, stream string) (*MediaInfo, error) {
+	engine := l.withConfig(ms)
+	group, err := engine.GetStatGroup(ctx, stream)
+	if errors.Is(err, lalmax.ErrGroupNotFound) {
+		return nil, ErrStreamNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lalmaxMediaInfo(group), nil
+}
+
+// lalmaxMediaInfo 帧率取最近几秒输入帧数的平均值，码率优先使用输入方向的统计
+func lalmaxMediaInfo(g *lalmax.StatGroup) *MediaInfo {
+	out := MediaInfo{
+		App:         g.AppName,
+		Stream:      g.StreamName,
+		Video:       bz.ParseCodec(g.VideoCodec),
+		Audio:       bz.ParseCodec(g.AudioCodec),
+		Width:       g.VideoWidth,
+		Height:      g.VideoHeight,
+		BitrateKbps: g.Pub.ReadBitrateKbits,
+		Readers:     len(g.Subs),
+	}
+	if out.BitrateKbps == 0 {
+		out.BitrateKbps = g.Pub.BitrateKbits
+	}
+	if n := len(g.InFramePerSec); n > 0 {
+		var sum uint32
+		for _, r := range g.InFramePerSec {
+			sum += r.V
+		}
+		out.FPS = float64(sum) / float64(n)
+	}
+	return &out
+}
+
+// ListStreams implements Driver.
+// lalmax 仅以流名区分，app 可能为空
+func (l *LalmaxDriver) ListStreams(ctx context.Context, ms *MediaServer) ([]StreamInfo, error) {
+	engine := l.withConfig(ms)
+	groups, err := engine.GetStatAllGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StreamInfo, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, StreamInfo{App: g.AppName, Stream: g.StreamName})
+	}
+	return out, nil
+}
+
 // CloseRTPServer implements Driver.
 func (l *LalmaxDriver) CloseRTPServer(ctx context.Context, ms *MediaServer, req *zlm.CloseRTPServerRequest) (*zlm.CloseRTPServerResponse, error) {
 	panic("unimplemented")
@@ -94,10 +167,43 @@ func (l *LalmaxDriver) Connect(ctx context.Context, ms *MediaServer) error {
 	return nil
 }
 
+const snapshotRetries = 5
+
+// snapshotRetryDelay 关键帧图片生成中时的重试间隔
+var snapshotRetryDelay = 300 * time.Millisecond
+
 // GetSnapshot implements Driver.
+// lalmax 直接返回流最近关键帧的图片，图片首次生成期间返回 429，短暂等待后重试
 func (l *LalmaxDriver) GetSnapshot(ctx context.Context, ms *MediaServer, req *GetSnapRequest) ([]byte, error) {
 	engine := l.withConfig(ms)
-	return engine.GetKeyFrameImage(ctx, req.Stream)
+	name := snapshotStreamName(req)
+	for i := 0; ; i++ {
+		img, err := engine.GetKeyFrameImage(ctx, name)
+		if !errors.Is(err, lalmax.ErrKeyFrameGenerating) || i >= snapshotRetries {
+			return img, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(snapshotRetryDelay):
+		}
+	}
+}
+
+// snapshotStreamName lalmax 的流名不区分 app，未指定 stream 时取播放地址的最后一段
+func snapshotStreamName(req *GetSnapRequest) string {
+	if req.Stream != "" {
+		return req.Stream
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
 }
 
 // OpenRTPServer implements Driver.
@@ -123,7 +229,8 @@ func (l *LalmaxDriver) OpenRTPServer(ctx context.Context, ms *MediaServer, req *
 
 // Ping implements Driver.
 func (l *LalmaxDriver) Ping(ctx context.Context, ms *MediaServer) error {
-	return nil
+	engine := l.withConfig(ms)
+	return engine.Ping(ctx)
 }
 
 // Protocol implements Driver.
@@ -183,3 +290,13 @@ func (l *LalmaxDriver) StartRecord(ctx context.Context, ms *MediaServer, req *zl
 func (l *LalmaxDriver) StopRecord(ctx context.Context, ms *MediaServer, req *zlm.StopRecordRequest) (*zlm.StopRecordResponse, error) {
 	return nil, fmt.Errorf("lalmax 暂不支持录制功能")
 }
+
+// StartSendRTP lalmax 暂不支持国标级联推流
+func (l *LalmaxDriver) StartSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StartSendRTPRequest) (*zlm.StartSendRTPResponse, error) {
+	return nil, fmt.Errorf("lalmax 暂不支持国标级联推流")
+}
+
+// StopSendRTP lalmax 暂不支持国标级联推流
+func (l *LalmaxDriver) StopSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StopSendRTPRequest) error {
+	return fmt.Errorf("lalmax 暂不支持国标级联推流")
+}
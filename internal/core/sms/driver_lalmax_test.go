@@ -0,0 +1,118 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/bz"
+	"github.com/gowvp/owl/pkg/lalmax"
+)
+
+func TestLalmaxDriverGetSnapshot(t *testing.T) {
+	old := snapshotRetryDelay
+	snapshotRetryDelay = time.Millisecond
+	defer func() { snapshotRetryDelay = old }()
+
+	png := []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}
+	var calls atomic.Int32
+	var busy atomic.Int32 // 前 busy 次请求返回 429
+	var stream atomic.Value
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/api/stat/key_frame" || r.URL.Query().Get("type") != "image" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		stream.Store(r.URL.Query().Get("stream_name"))
+		if busy.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{IP: host, Type: ProtocolLalmax}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+	d := NewLalmaxDriver()
+
+	t.Run("retry on 429", func(t *testing.T) {
+		calls.Store(0)
+		busy.Store(2)
+		img, err := d.GetSnapshot(context.Background(), &ms, &GetSnapRequest{Stream: "ch1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(img, png) || calls.Load() != 3 || stream.Load() != "ch1" {
+			t.Fatalf("expect png after 3 calls for ch1, got %d bytes %d calls stream %v", len(img), calls.Load(), stream.Load())
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		calls.Store(0)
+		busy.Store(100)
+		_, err := d.GetSnapshot(context.Background(), &ms, &GetSnapRequest{Stream: "ch1"})
+		if !errors.Is(err, lalmax.ErrKeyFrameGenerating) || calls.Load() != snapshotRetries+1 {
+			t.Fatalf("expect keyframe generating after %d calls, got %v %d", snapshotRetries+1, err, calls.Load())
+		}
+	})
+
+	t.Run("stream from url", func(t *testing.T) {
+		busy.Store(0)
+		req := GetSnapRequest{}
+		req.URL = "rtsp://127.0.0.1:554/ch2?session=abc"
+		if _, err := d.GetSnapshot(context.Background(), &ms, &req); err != nil {
+			t.Fatal(err)
+		}
+		if stream.Load() != "ch2" {
+			t.Fatalf("expect stream ch2, got %v", stream.Load())
+		}
+	})
+}
+
+func TestLalmaxDriverGetMediaInfo(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream_name") != "ch1" {
+			_, _ = w.Write([]byte(`{"error_code":1001,"desp":"group not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error_code":0,"desp":"succ","data":{
+			"stream_name":"ch1","app_name":"rtp","audio_codec":"AAC","video_codec":"H265",
+			"video_width":2560,"video_height":1440,
+			"pub":{"protocol":"RTMP","bitrate_kbits":2100,"read_bitrate_kbits":2048},
+			"subs":[{"protocol":"FLV"},{"protocol":"HLS"}],
+			"in_frame_per_sec":[{"unix_sec":1,"v":24},{"unix_sec":2,"v":26}]}}`))
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{IP: host, Type: ProtocolLalmax}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+	d := NewLalmaxDriver()
+
+	info, err := d.GetMediaInfo(context.Background(), &ms, "rtp", "ch1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := MediaInfo{
+		App: "rtp", Stream: "ch1", Video: bz.CodecH265, Audio: bz.CodecAAC,
+		Width: 2560, Height: 1440, FPS: 25, BitrateKbps: 2048, Readers: 2,
+	}
+	if *info != expect {
+		t.Fatalf("expect %+v, got %+v", expect, *info)
+	}
+
+	if _, err := d.GetMediaInfo(context.Background(), &ms, "rtp", "ch2"); !errors.Is(err, ErrStreamNotActive) {
+		t.Fatalf("expect ErrStreamNotActive, got %v", err)
+	}
+}
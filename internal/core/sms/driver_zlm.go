@@ -5,14 +5,34 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/gowvp/owl/pkg/zlm"
+	"github.com/ixugo/goddd/pkg/conc"
 )
 
-var _ Driver = (*ZLMDriver)(nil)
+var (
+	_ Driver       = (*ZLMDriver)(nil)
+	_ ConfigCacher = (*ZLMDriver)(nil)
+)
 
 type ZLMDriver struct {
 	engine zlm.Engine
+	// ports 各节点最近一次拉取的端口配置，节点频繁断线重连时免去每次拉取完整配置
+	ports *conc.Map[string, zlmPortCache]
+}
+
+// zlmPortCacheTTL 端口缓存有效期，ZLM 不提供配置版本号，
+// 节点配置修改与流媒体重启时主动清除，其余情况下配置文件修改后最迟在有效期后生效
+const zlmPortCacheTTL = 10 * time.Minute
+
+// zlmPortCache 节点端口配置缓存
+type zlmPortCache struct {
+	addr    string // 节点地址、http 端口与 secret，变化时缓存失效
+	version string // 节点版本，ZLM 升级或替换后缓存失效
+	ports   MediaServerPorts
+	at      time.Time
 }
 
 // GetStreamLiveAddr implements Driver.
@@ -33,6 +53,7 @@ func (d *ZLMDriver) GetStreamLiveAddr(ctx context.Context, ms *MediaServer, http
 func NewZLMDriver() *ZLMDriver {
 	return &ZLMDriver{
 		engine: zlm.NewEngine(),
+		ports:  conc.NewMap[string, zlmPortCache](),
 	}
 }
 
@@ -48,8 +69,27 @@ func (d *ZLMDriver) withConfig(ms *MediaServer) zlm.Engine {
 	})
 }
 
+// InvalidateConfig implements ConfigCacher.
+func (d *ZLMDriver) InvalidateConfig(serverID string) {
+	d.ports.Delete(serverID)
+}
+
 func (d *ZLMDriver) Connect(ctx context.Context, ms *MediaServer) error {
 	engine := d.withConfig(ms)
+
+	// 节点版本与地址未变化时使用缓存的端口，旧版本 ZLM 不支持版本接口时每次拉取完整配置
+	addr := fmt.Sprintf("%s:%d/%s", ms.IP, ms.Ports.HTTP, ms.Secret)
+	var version string
+	if resp, err := engine.GetVersion(ctx); err == nil {
+		version = resp.Data.CommitHash + "/" + resp.Data.BuildTime
+	}
+	if c, ok := d.ports.Load(ms.ID); ok && version != "" && c.addr == addr && c.version == version && time.Since(c.at) < zlmPortCacheTTL {
+		ms.Ports = c.ports
+		ms.HookAliveInterval = 10
+		ms.Status = true
+		return nil
+	}
+
 	resp, err := engine.GetServerConfig()
 	if err != nil {
 		return err
@@ -75,6 +115,9 @@ func (d *ZLMDriver) Connect(ctx context.Context, ms *MediaServer) error {
 	ms.HookAliveInterval = 10
 	ms.Status = true
 
+	if version != "" {
+		d.ports.Store(ms.ID, zlmPortCache{addr: addr, version: version, ports: ms.Ports, at: time.Now()})
+	}
 	return nil
 }
 
@@ -137,10 +180,9 @@ func (d *ZLMDriver) Setup(ctx context.Context, ms *MediaServer, webhookURL strin
 }
 
 func (d *ZLMDriver) Ping(ctx context.Context, ms *MediaServer) error {
-	// 使用 getApiList 或简单的获取配置来探测是否存活
+	// getApiList 响应小，避免 3 秒一次的探测给节点带来负担
 	engine := d.withConfig(ms)
-	// 可以使用更轻量级的接口，这里暂时复用 GetServerConfig
-	_, err := engine.GetServerConfig()
+	_, err := engine.GetAPIList(ctx)
 	return err
 }
 
@@ -162,14 +204,14 @@ func (d *ZLMDriver) AddStreamProxy(ctx context.Context, ms *MediaServer, req *Ad
 		Stream:        req.Stream,
 		URL:           req.URL,
 		RTPType:       req.RTPType,
-		RetryCount:    3,
-		TimeoutSec:    PullTimeoutMs / 1000,
-		EnableHLSFMP4: new(true),
-		EnableAudio:   new(true),
-		EnableRTSP:    new(true),
-		EnableRTMP:    new(true),
-		AddMuteAudio:  new(true),
-		AutoClose:     new(true),
+		RetryCount:    req.EffectiveRetryCount(),
+		TimeoutSec:    float32(req.pullTimeoutMs()) / 1000,
+		EnableHLSFMP4: new(boolOr(req.EnableHLSFMP4, true)),
+		EnableAudio:   new(boolOr(req.EnableAudio, true)),
+		EnableRTSP:    new(boolOr(req.EnableRTSP, true)),
+		EnableRTMP:    new(boolOr(req.EnableRTMP, true)),
+		AddMuteAudio:  new(boolOr(req.AddMuteAudio, true)),
+		AutoClose:     new(boolOr(req.AutoClose, true)),
 	})
 }
 
@@ -178,6 +220,98 @@ func (d *ZLMDriver) GetSnapshot(ctx context.Context, ms *MediaServer, req *GetSn
 	return engine.GetSnap(req.GetSnapRequest)
 }
 
+// ListStreams 通过 getMediaList 查询所有在线流，按 app+stream 去重
+func (d *ZLMDriver) ListStreams(ctx context.Context, ms *MediaServer) ([]StreamInfo, error) {
+	engine := d.withConfig(ms)
+	resp, err := engine.GetMediaList(ctx, zlm.GetMediaListRequest{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StreamInfo, 0, len(resp.Data))
+	seen := make(map[StreamInfo]struct{}, len(resp.Data))
+	for _, media := range resp.Data {
+		info := StreamInfo{App: media.App, Stream: media.Stream}
+		if _, ok := seen[info]; ok {
+			continue
+		}
+		seen[info] = struct{}{}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// GetStreamCodec 通过 getMediaList 查询流的轨道信息
+// 同一个流的各协议轨道相同，取第一条有轨道的记录即可
+func (d *ZLMDriver) GetStreamCodec(ctx context.Context, ms *MediaServer, app, stream string) (*bz.StreamCodec, error) {
+	engine := d.withConfig(ms)
+	resp, err := engine.GetMediaList(ctx, zlm.GetMediaListRequest{App: app, Stream: stream})
+	if err != nil {
+		return nil, err
+	}
+	for _, media := range resp.Data {
+		if len(media.Tracks) > 0 {
+			return ZLMTracksCodec(media.Tracks), nil
+		}
+	}
+	return nil, fmt.Errorf("stream not found app[%s] stream[%s]", app, stream)
+}
+
+// GetMediaInfo implements Driver.
+func (d *ZLMDriver) GetMediaInfo(ctx context.Context, ms *MediaServer, app, stream string) (*MediaInfo, error) {
+	engine := d.withConfig(ms)
+	resp, err := engine.GetMediaList(ctx, zlm.GetMediaListRequest{App: app, Stream: stream})
+	if err != nil {
+		return nil, err
+	}
+	out, ok := zlmMediaInfo(resp.Data)
+	if !ok {
+		return nil, ErrStreamNotActive
+	}
+	return out, nil
+}
+
+// zlmMediaInfo 合并同一流各协议的记录
+// 码率与存活时长各协议一致，观看人数 totalReaderCount 已是全部协议之和
+func zlmMediaInfo(list []zlm.MediaInfo) (*MediaInfo, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	out := MediaInfo{App: list[0].App, Stream: list[0].Stream}
+	for _, media := range list {
+		out.BitrateKbps = max(out.BitrateKbps, media.BytesSpeed*8/1000)
+		out.Readers = max(out.Readers, media.TotalReaderCount)
+		out.AliveSecond = max(out.AliveSecond, media.AliveSecond)
+		if out.Video != bz.CodecUnknown || len(media.Tracks) == 0 {
+			continue
+		}
+		codec := ZLMTracksCodec(media.Tracks)
+		out.Video, out.Audio = codec.Video, codec.Audio
+		out.Width, out.Height = codec.Width, codec.Height
+		for _, t := range media.Tracks {
+			if t.CodecType == 0 {
+				out.FPS = float64(t.Fps)
+			}
+		}
+	}
+	return &out, true
+}
+
+// ZLMTracksCodec 将 ZLM 轨道信息转为编码信息，webhook 与 getMediaList 的轨道格式一致
+func ZLMTracksCodec(tracks []zlm.MediaTrack) *bz.StreamCodec {
+	var out bz.StreamCodec
+	for _, t := range tracks {
+		codec := bz.ParseZLMCodec(t.CodecID, t.CodecIDName)
+		switch t.CodecType {
+		case 0:
+			out.Video = codec
+			out.Width, out.Height = t.Width, t.Height
+		case 1:
+			out.Audio = codec
+		}
+	}
+	return &out
+}
+
 // StartRecord 开始录制，通知 ZLM 对指定流进行 MP4 录制
 func (d *ZLMDriver) StartRecord(ctx context.Context, ms *MediaServer, req *zlm.StartRecordRequest) (*zlm.StartRecordResponse, error) {
 	engine := d.withConfig(ms)
@@ -189,3 +323,16 @@ func (d *ZLMDriver) StopRecord(ctx context.Context, ms *MediaServer, req *zlm.St
 	engine := d.withConfig(ms)
 	return engine.StopRecord(*req)
 }
+
+// StartSendRTP 将流推送到上级平台
+func (d *ZLMDriver) StartSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StartSendRTPRequest) (*zlm.StartSendRTPResponse, error) {
+	engine := d.withConfig(ms)
+	return engine.StartSendRTP(*req)
+}
+
+// StopSendRTP 停止推送到上级平台
+func (d *ZLMDriver) StopSendRTP(ctx context.Context, ms *MediaServer, req *zlm.StopSendRTPRequest) error {
+	engine := d.withConfig(ms)
+	_, err := engine.StopSendRTP(*req)
+	return err
+}
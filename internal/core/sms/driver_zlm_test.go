@@ -0,0 +1,229 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/bz"
+)
+
+func TestZLMDriverPing(t *testing.T) {
+	var paths []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"code":0,"data":["/index/api/getApiList"]}`))
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{IP: host}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+
+	if err := NewZLMDriver().Ping(context.Background(), &ms); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/index/api/getApiList" {
+		t.Fatalf("expect ping getApiList, got %v", paths)
+	}
+}
+
+func TestZLMDriverAddStreamProxy(t *testing.T) {
+	var body map[string]any
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"code":0,"data":{"key":"k"}}`))
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{IP: host}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+	d := NewZLMDriver()
+
+	// 未配置时使用默认值
+	if _, err := d.AddStreamProxy(context.Background(), &ms, &AddStreamProxyRequest{App: "rtsp", Stream: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+	if body["timeout_sec"] != float64(PullTimeoutMs/1000) || body["retry_count"] != float64(PullRetryNum) || body["enable_audio"] != true || body["auto_close"] != true {
+		t.Fatalf("unexpected default body %v", body)
+	}
+	for _, k := range []string{"enable_rtsp", "enable_rtmp", "enable_hls_fmp4", "add_mute_audio"} {
+		if body[k] != true {
+			t.Fatalf("expect %s enabled by default, got %v", k, body[k])
+		}
+	}
+
+	f := false
+	if _, err := d.AddStreamProxy(context.Background(), &ms, &AddStreamProxyRequest{
+		App: "rtsp", Stream: "s1", RTPType: 1, TimeoutSec: 30, RetryCount: 8, EnableAudio: &f, AutoClose: &f,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if body["timeout_sec"] != float64(30) || body["retry_count"] != float64(8) || body["rtp_type"] != float64(1) || body["enable_audio"] != false || body["auto_close"] != false {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	tr := true
+	if _, err := d.AddStreamProxy(context.Background(), &ms, &AddStreamProxyRequest{
+		App: "rtsp", Stream: "s1", EnableRTSP: &tr, EnableRTMP: &f, EnableHLSFMP4: &f, AddMuteAudio: &f,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if body["enable_rtsp"] != true || body["enable_rtmp"] != false || body["enable_hls_fmp4"] != false || body["add_mute_audio"] != false {
+		t.Fatalf("expect protocol toggles in body, got %v", body)
+	}
+}
+
+func TestNodeManagerReconcileStreams(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"data":[
+			{"app":"push","stream":"cam1","schema":"rtsp"},
+			{"app":"push","stream":"cam1","schema":"rtmp"},
+			{"app":"rtp","stream":"34020000001320000001","schema":"rtsp"}
+		]}`))
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{ID: DefaultMediaServerID, IP: host}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+
+	var n NodeManager
+	// 未注册校正函数时不查询
+	n.reconcileStreams(context.Background(), NewZLMDriver(), &ms)
+
+	var gotID string
+	var got []StreamInfo
+	n.SetStreamReconciler(func(_ context.Context, id string, streams []StreamInfo) {
+		gotID, got = id, streams
+	})
+	n.reconcileStreams(context.Background(), NewZLMDriver(), &ms)
+	if gotID != DefaultMediaServerID {
+		t.Fatalf("expect reconcile node %s, got %q", DefaultMediaServerID, gotID)
+	}
+	expect := []StreamInfo{{App: "push", Stream: "cam1"}, {App: "rtp", Stream: "34020000001320000001"}}
+	if len(got) != len(expect) || got[0] != expect[0] || got[1] != expect[1] {
+		t.Fatalf("expect deduplicated streams %v, got %v", expect, got)
+	}
+}
+
+func TestZLMDriverGetMediaInfo(t *testing.T) {
+	var found atomic.Bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !found.Load() {
+			_, _ = w.Write([]byte(`{"code":0,"data":[]}`))
+			return
+		}
+		// 同一流的 rtsp 与 rtmp 两条记录，rtmp 记录尚未解析出轨道
+		_, _ = w.Write([]byte(`{"code":0,"data":[
+			{"app":"live","stream":"cam","schema":"rtmp","totalReaderCount":3,"bytesSpeed":256000,"aliveSecond":120,"tracks":[]},
+			{"app":"live","stream":"cam","schema":"rtsp","totalReaderCount":3,"bytesSpeed":256000,"aliveSecond":120,"tracks":[
+				{"codec_id":0,"codec_id_name":"H264","codec_type":0,"ready":true,"fps":25,"width":1920,"height":1080},
+				{"codec_id":3,"codec_id_name":"PCMA","codec_type":1,"ready":true,"sample_rate":8000}
+			]}
+		]}`))
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	ms := MediaServer{IP: host}
+	ms.Ports.HTTP, _ = strconv.Atoi(port)
+	d := NewZLMDriver()
+
+	if _, err := d.GetMediaInfo(context.Background(), &ms, "live", "cam"); !errors.Is(err, ErrStreamNotActive) {
+		t.Fatalf("expect ErrStreamNotActive, got %v", err)
+	}
+
+	found.Store(true)
+	info, err := d.GetMediaInfo(context.Background(), &ms, "live", "cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := MediaInfo{
+		App: "live", Stream: "cam", Video: bz.CodecH264, Audio: bz.CodecG711A,
+		Width: 1920, Height: 1080, FPS: 25, BitrateKbps: 2048, Readers: 3, AliveSecond: 120,
+	}
+	if *info != expect {
+		t.Fatalf("expect %+v, got %+v", expect, *info)
+	}
+}
+
+func TestZLMDriverConnectCachePorts(t *testing.T) {
+	var configCalls atomic.Int32
+	var version atomic.Value
+	version.Store(`{"code":0,"data":{"branchName":"master","buildTime":"2026-10-01T00:00:00","commitHash":"abc"}}`)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index/api/version":
+			_, _ = w.Write([]byte(version.Load().(string)))
+		case "/index/api/getServerConfig":
+			configCalls.Add(1)
+			_, _ = w.Write([]byte(`{"code":0,"data":[{"rtsp.port":"554","rtmp.port":"1935","rtp_proxy.port":"10000"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	host, port, _ := net.SplitHostPort(svr.Listener.Addr().String())
+	newServer := func() *MediaServer {
+		ms := MediaServer{ID: "local", IP: host}
+		ms.Ports.HTTP, _ = strconv.Atoi(port)
+		return &ms
+	}
+	d := NewZLMDriver()
+
+	ms := newServer()
+	if err := d.Connect(context.Background(), ms); err != nil {
+		t.Fatal(err)
+	}
+	if configCalls.Load() != 1 || ms.Ports.RTSP != 554 || ms.Ports.RTMP != 1935 {
+		t.Fatalf("expect full fetch, calls %d ports %+v", configCalls.Load(), ms.Ports)
+	}
+
+	// 重连时版本未变化，不再拉取完整配置
+	ms = newServer()
+	if err := d.Connect(context.Background(), ms); err != nil {
+		t.Fatal(err)
+	}
+	if configCalls.Load() != 1 || ms.Ports.RTSP != 554 || ms.Ports.RTPPorxy != 10000 || !ms.Status {
+		t.Fatalf("expect cached ports, calls %d ports %+v", configCalls.Load(), ms.Ports)
+	}
+
+	// 版本变化后重新拉取
+	version.Store(`{"code":0,"data":{"branchName":"master","buildTime":"2026-10-02T00:00:00","commitHash":"def"}}`)
+	if err := d.Connect(context.Background(), newServer()); err != nil {
+		t.Fatal(err)
+	}
+	if configCalls.Load() != 2 {
+		t.Fatalf("expect full fetch after version changed, calls %d", configCalls.Load())
+	}
+
+	// 节点配置修改后清除缓存，即使版本未变化也重新拉取
+	d.InvalidateConfig("local")
+	if err := d.Connect(context.Background(), newServer()); err != nil {
+		t.Fatal(err)
+	}
+	if configCalls.Load() != 3 {
+		t.Fatalf("expect full fetch after invalidate, calls %d", configCalls.Load())
+	}
+
+	// 不支持版本接口时每次拉取
+	version.Store(`not json`)
+	for range 2 {
+		if err := d.Connect(context.Background(), newServer()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if configCalls.Load() != 5 {
+		t.Fatalf("expect full fetch without version api, calls %d", configCalls.Load())
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
 	"github.com/jinzhu/copier"
 )
 
@@ -19,20 +20,14 @@ type MediaServerStorer interface {
 	Del(context.Context, *MediaServer, ...orm.QueryOption) error
 }
 
-// FindMediaServer Paginated search
-func (c *Core) FindMediaServer(ctx context.Context, in *FindMediaServerInput) ([]*MediaServer, int64, error) {
-	items := make([]*MediaServer, 0)
-	total, err := c.storer.MediaServer().Find(ctx, &items, in)
-	if err != nil {
+// FindMediaServer 分页查询流媒体节点及其在线状态，secret 已脱敏
+// 节点数量有限，全量读取后在内存中按在线状态过滤
+func (c *Core) FindMediaServer(ctx context.Context, in *FindMediaServerInput) ([]*MediaServerItem, int64, error) {
+	servers := make([]*MediaServer, 0)
+	if _, err := c.storer.MediaServer().Find(ctx, &servers, web.NewPagerFilterMaxSize()); err != nil {
 		return nil, 0, reason.ErrDB.Withf(`Find err[%s]`, err.Error())
 	}
-
-	for _, item := range items {
-		value, ok := c.cacheServers.Load(item.ID)
-		if ok {
-			item.Status = value.IsOnline
-		}
-	}
+	items, total := c.listMediaServers(servers, in)
 	return items, total, nil
 }
 
@@ -62,15 +57,31 @@ func (c *Core) AddMediaServer(ctx context.Context, in *AddMediaServerInput) (*Me
 
 // EditMediaServer Update object information
 func (c *Core) EditMediaServer(ctx context.Context, in *EditMediaServerInput, id string, serverPort int) (*MediaServer, error) {
+	if in.MaxPlays != nil && *in.MaxPlays < 0 {
+		return nil, reason.ErrBadRequest.SetMsg("max_plays 不能小于 0")
+	}
 	var out MediaServer
 	if err := c.storer.MediaServer().Edit(ctx, &out, func(b *MediaServer) {
+		secret := b.Secret
 		if err := copier.Copy(b, in); err != nil {
 			slog.ErrorContext(ctx, "Copy", "err", err)
 		}
+		// 列表返回的是掩码，原样提交时保留原 secret
+		if in.Secret == "" || in.Secret == maskedSecret {
+			b.Secret = secret
+		}
+		if in.MaxPlays != nil {
+			b.MaxPlays = *in.MaxPlays
+		}
 	}, orm.Where("id=?", id)); err != nil {
+		if orm.IsErrRecordNotFound(err) {
+			return nil, reason.ErrNotFound.Withf(`Edit err[%s]`, err.Error())
+		}
 		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
 	}
-	c.connection(&out, serverPort)
+	// 重连失败时配置已保存，由心跳巡检继续判定在线状态
+	c.InvalidateConfig(out.ID)
+	_ = c.connection(ctx, &out, serverPort)
 	return &out, nil
 }
 
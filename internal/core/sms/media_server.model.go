@@ -29,6 +29,7 @@ type MediaServer struct {
 	RecordPath        string           `gorm:"column:record_path;notNull;default:''" json:"record_path"`
 	Type              string           `gorm:"column:type;notNull;default:''" json:"type"`
 	TranscodeSuffix   string           `gorm:"column:transcode_suffix;notNull;default:''" json:"transcode_suffix"`
+	MaxPlays          int              `gorm:"column:max_plays;notNull;default:0" json:"max_plays"` // 最大并发点播数，0 表示不限制
 }
 
 // TableName database table name
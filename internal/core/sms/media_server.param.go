@@ -8,25 +8,9 @@ import (
 
 type FindMediaServerInput struct {
 	web.PagerFilter
-	IP                string           `form:"ip"`
-	HookIP            string           `form:"hook_ip"`
-	SDPIP             string           `form:"sdpip"`
-	StreamIP          string           `form:"stream_ip"`
-	Ports             MediaServerPorts `form:"ports"`
-	AutoConfig        bool             `form:"auto_config"`
-	Secret            string           `form:"secret"`
-	HookAliveInterval int              `form:"hook_alive_interval"`
-	RTPEnable         bool             `form:"rtpenable"`
-	Status            bool             `form:"status"`
-	RTPPortRange      string           `form:"rtpport_range"`
-	SendRTPPortRange  string           `form:"send_rtpport_range"`
-	RecordAssistPort  int              `form:"record_assist_port"`
-	LastKeepaliveAt   orm.Time         `form:"last_keepalive_at"`
-	IsDefault         bool             `form:"is_default"`
-	RecordDay         int              `form:"record_day"`
-	RecordPath        string           `form:"record_path"`
-	Type              string           `form:"type"`
-	TranscodeSuffix   string           `form:"transcode_suffix"`
+	Key    string `form:"key"`    // 按 ID 或 IP 模糊匹配
+	Type   string `form:"type"`   // lalmax/zlm
+	Online *bool  `form:"online"` // 在线状态，不传表示全部
 }
 
 type EditMediaServerInput struct {
@@ -36,8 +20,10 @@ type EditMediaServerInput struct {
 	// StreamIP          string           `json:"stream_ip"`
 	// Ports MediaServerPorts `json:"ports"`
 	// AutoConfig        bool             `json:"auto_config"`
-	Secret string `json:"secret"`
-	Type   string `json:"type"` // lalmax/zlm
+	Secret string `json:"secret"` // 为空或为掩码时不修改
+	Type   string `json:"type"`   // lalmax/zlm
+	// MaxPlays 最大并发点播数，0 表示不限制，不传不修改
+	MaxPlays *int `json:"max_plays" copier:"-"`
 	// HookAliveInterval int              `json:"hook_alive_interval"`
 	// RTPEnable         bool             `json:"rtpenable"`
 	// Status            bool             `json:"status"`
@@ -0,0 +1,73 @@
+package sms
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// maskedSecret 列表与编辑结果中 secret 的占位值，编辑时传回该值表示不修改
+const maskedSecret = "******"
+
+// MediaServerItem 流媒体节点列表项，在线状态与最近心跳来自内存缓存
+type MediaServerItem struct {
+	MediaServer
+	Online        bool      `json:"online"`          // 是否在线
+	LastUpdatedAt time.Time `json:"last_updated_at"` // 最近一次心跳或探测成功的时间
+	ActivePlays   int       `json:"active_plays"`    // 当前占用的点播数
+}
+
+// maskSecret 隐藏 secret，仅保留是否已设置的信息
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
+// NewMediaServerItem 合并数据库配置与缓存状态，缓存中没有的节点视为离线
+func (n *NodeManager) NewMediaServerItem(ms *MediaServer) *MediaServerItem {
+	item := MediaServerItem{MediaServer: *ms}
+	item.Secret = maskSecret(ms.Secret)
+	if value, ok := n.cacheServers.Load(ms.ID); ok {
+		item.Online = value.IsOnline
+		item.LastUpdatedAt = value.LastUpdatedAt
+	}
+	item.Status = item.Online
+	item.ActivePlays = n.ActivePlays(ms.ID)
+	return &item
+}
+
+// listMediaServers 组装、过滤并分页，在线状态只在内存中，无法交给数据库筛选
+func (n *NodeManager) listMediaServers(servers []*MediaServer, in *FindMediaServerInput) ([]*MediaServerItem, int64) {
+	items := make([]*MediaServerItem, 0, len(servers))
+	for _, ms := range servers {
+		item := n.NewMediaServerItem(ms)
+		if !in.match(item) {
+			continue
+		}
+		items = append(items, item)
+	}
+	slices.SortStableFunc(items, func(a, b *MediaServerItem) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	total := int64(len(items))
+	start := min(in.Offset(), len(items))
+	end := min(start+in.Limit(), len(items))
+	return items[start:end], total
+}
+
+// match 按关键字、类型、在线状态过滤
+func (in *FindMediaServerInput) match(item *MediaServerItem) bool {
+	if in.Key != "" && !strings.Contains(item.ID, in.Key) && !strings.Contains(item.IP, in.Key) {
+		return false
+	}
+	if in.Type != "" && item.Type != in.Type {
+		return false
+	}
+	if in.Online != nil && item.Online != *in.Online {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+func TestListMediaServers(t *testing.T) {
+	var storer TestStorer
+	nm := NewNodeManager(&storer)
+	defer nm.Close()
+
+	now := time.Now()
+	nm.cacheServers.Store("local", &WarpMediaServer{IsOnline: true, LastUpdatedAt: now})
+	nm.cacheServers.Store("node2", &WarpMediaServer{IsOnline: false, LastUpdatedAt: now.Add(-time.Minute)})
+
+	servers := []*MediaServer{
+		{ID: "node3", IP: "10.0.0.3", Type: ProtocolZLMediaKit, Secret: "s3"},
+		{ID: "local", IP: "127.0.0.1", Type: ProtocolZLMediaKit, Secret: "secret"},
+		{ID: "node2", IP: "10.0.0.2", Type: ProtocolLalmax},
+	}
+	online, offline := true, false
+
+	tests := []struct {
+		name  string
+		in    FindMediaServerInput
+		ids   []string
+		total int64
+	}{
+		{name: "all", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}}, ids: []string{"local", "node2", "node3"}, total: 3},
+		{name: "page", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 2, Size: 2}}, ids: []string{"node3"}, total: 3},
+		{name: "online", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, Online: &online}, ids: []string{"local"}, total: 1},
+		{name: "offline", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, Online: &offline}, ids: []string{"node2", "node3"}, total: 2},
+		{name: "type", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, Type: ProtocolLalmax}, ids: []string{"node2"}, total: 1},
+		{name: "key", in: FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}, Key: "10.0.0"}, ids: []string{"node2", "node3"}, total: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total := nm.listMediaServers(servers, &tt.in)
+			if total != tt.total || len(items) != len(tt.ids) {
+				t.Fatalf("expect %v total %d, got %d items total %d", tt.ids, tt.total, len(items), total)
+			}
+			for i, item := range items {
+				if item.ID != tt.ids[i] {
+					t.Fatalf("expect %v, got item %d %s", tt.ids, i, item.ID)
+				}
+			}
+		})
+	}
+
+	items, _ := nm.listMediaServers(servers, &FindMediaServerInput{PagerFilter: web.PagerFilter{Page: 1, Size: 10}})
+	local, node2, node3 := items[0], items[1], items[2]
+	if !local.Online || !local.Status || !local.LastUpdatedAt.Equal(now) {
+		t.Fatalf("local should be online with cached time, got %+v", local)
+	}
+	if local.Secret != maskedSecret || node2.Secret != "" {
+		t.Fatalf("secret should be masked, got %q %q", local.Secret, node2.Secret)
+	}
+	if servers[1].Secret != "secret" {
+		t.Fatal("masking should not modify the source config")
+	}
+	if node3.Online || !node3.LastUpdatedAt.IsZero() {
+		t.Fatalf("server missing in cache should be offline, got %+v", node3)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/gowvp/owl/pkg/zlm"
 	"github.com/ixugo/goddd/pkg/conc"
 	"github.com/ixugo/goddd/pkg/orm"
@@ -17,31 +21,76 @@ import (
 	"github.com/ixugo/goddd/pkg/web"
 )
 
+// KeepaliveInterval 未获取到心跳间隔时，默认的离线判定时长
 const KeepaliveInterval = 2 * 15 * time.Second
 
+// ErrMediaServerOffline 流媒体节点离线或地址有误，客户端可稍后重试
+var ErrMediaServerOffline = reason.NewError("ErrMediaServerOffline", "流媒体服务离线或IP有误")
+
+// ErrStreamNotActive 流未在流媒体上注册，需先播放或等待推流
+var ErrStreamNotActive = reason.NewError("ErrStreamNotActive", "流未在线")
+
+// mediaInfoTTL 流画质信息的缓存时长，避免多个页面轮询时频繁请求流媒体
+const mediaInfoTTL = 3 * time.Second
+
+// keepaliveTimeoutFactor 离线判定时长为心跳间隔的倍数，容忍 2 次心跳丢失
+const keepaliveTimeoutFactor = 3
+
 type WarpMediaServer struct {
 	IsOnline      bool
 	LastUpdatedAt time.Time
 	Config        *MediaServer
 }
 
+// keepaliveTimeout 根据节点自身的心跳间隔计算离线判定时长
+func (w *WarpMediaServer) keepaliveTimeout() time.Duration {
+	if w.Config == nil || w.Config.HookAliveInterval <= 0 {
+		return KeepaliveInterval
+	}
+	return time.Duration(w.Config.HookAliveInterval*keepaliveTimeoutFactor) * time.Second
+}
+
 type NodeManager struct {
 	storer Storer
 
 	drivers      map[string]Driver
 	cacheServers conc.Map[string, *WarpMediaServer]
-	quit         chan struct{}
+	mediaInfos   conc.Map[string, cachedMediaInfo]
+	plays        playSlots
+	snapshots    *snapshotLimiter
+
+	// ctx 在 Close 时取消，后台任务（巡检、节点连接）均由 spawn 启动并计入 wg
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	spawnM sync.Mutex
+
+	// degraded 默认流媒体缺少 secret 时为 true，此时驱动调用都会鉴权失败
+	degraded atomic.Bool
+
+	reconciler atomic.Pointer[StreamReconciler]
+}
+
+// StreamReconciler 节点重连后，按节点上实际在线的流校正通道播放状态
+type StreamReconciler func(ctx context.Context, mediaServerID string, streams []StreamInfo)
+
+// SetStreamReconciler 注册重连后的流状态校正函数，由上层注入以避免 sms 依赖 ipc 领域
+func (n *NodeManager) SetStreamReconciler(fn StreamReconciler) {
+	n.reconciler.Store(&fn)
 }
 
 func NewNodeManager(storer Storer) *NodeManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	n := NodeManager{
-		storer:  storer,
-		drivers: make(map[string]Driver),
-		quit:    make(chan struct{}, 1),
+		storer:    storer,
+		drivers:   make(map[string]Driver),
+		snapshots: newSnapshotLimiter(0, 0),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 	n.RegisterDriver(ProtocolZLMediaKit, NewZLMDriver())
 	n.RegisterDriver(ProtocolLalmax, NewLalmaxDriver())
-	go n.tickCheck()
+	n.spawn(n.tickCheck)
 	return &n
 }
 
@@ -49,6 +98,15 @@ func (n *NodeManager) RegisterDriver(name string, driver Driver) {
 	n.drivers[name] = driver
 }
 
+// InvalidateConfig 清除驱动缓存的节点配置，下次连接时重新拉取
+func (n *NodeManager) InvalidateConfig(serverID string) {
+	for _, d := range n.drivers {
+		if c, ok := d.(ConfigCacher); ok {
+			c.InvalidateConfig(serverID)
+		}
+	}
+}
+
 func (n *NodeManager) getDriver(name string) (Driver, error) {
 	if name == "" {
 		name = "zlm"
@@ -60,47 +118,66 @@ func (n *NodeManager) getDriver(name string) (Driver, error) {
 	return d, nil
 }
 
+// Close 停止所有后台任务，等待其全部退出后返回，可重复调用
 func (n *NodeManager) Close() {
-	close(n.quit)
+	n.spawnM.Lock()
+	n.cancel()
+	n.spawnM.Unlock()
+	n.wg.Wait()
+}
+
+// spawn 启动受 Close 管理的后台任务，已关闭时不再启动并返回 false
+func (n *NodeManager) spawn(fn func(ctx context.Context)) bool {
+	n.spawnM.Lock()
+	defer n.spawnM.Unlock()
+	if n.ctx.Err() != nil {
+		return false
+	}
+	n.wg.Go(func() { fn(n.ctx) })
+	return true
 }
 
 // tickCheck 定时检查服务是否离线
-func (n *NodeManager) tickCheck() {
+func (n *NodeManager) tickCheck(ctx context.Context) {
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
 	for {
 		select {
-		case <-n.quit:
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			n.cacheServers.Range(func(_ string, ms *WarpMediaServer) bool {
-				if time.Since(ms.LastUpdatedAt) < KeepaliveInterval {
-					ms.IsOnline = true
-					return true
-				}
-
-				// 尝试主动探测
-				if ms.Config != nil {
-					driver, err := n.getDriver(ms.Config.Type)
-					if err == nil {
-						ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-						if err := driver.Ping(ctx, ms.Config); err == nil {
-							ms.LastUpdatedAt = time.Now()
-							ms.IsOnline = true
-							cancel()
-							return true
-						}
-						cancel()
-					}
-				}
-
-				ms.IsOnline = false
+				n.checkOnline(ctx, ms)
 				return true
 			})
 		}
 	}
 }
 
+// checkOnline 心跳超时后尝试主动探测，探测失败则判定离线
+func (n *NodeManager) checkOnline(ctx context.Context, ms *WarpMediaServer) {
+	if time.Since(ms.LastUpdatedAt) < ms.keepaliveTimeout() {
+		ms.IsOnline = true
+		return
+	}
+
+	// 尝试主动探测
+	if ms.Config != nil {
+		driver, err := n.getDriver(ms.Config.Type)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			if err := driver.Ping(ctx, ms.Config); err == nil {
+				ms.LastUpdatedAt = time.Now()
+				ms.IsOnline = true
+				return
+			}
+		}
+	}
+
+	ms.IsOnline = false
+}
+
 // 读取 config.ini 文件，通过正则表达式，获取 secret 的值
 func getSecret(configDir string) (string, error) {
 	for _, file := range []string{"zlm.ini", "config.ini"} {
@@ -134,10 +211,68 @@ func setupSecret(bc *conf.Bootstrap) {
 	slog.Warn("未发现 zlm 配置，请手动配置 zlm secret")
 }
 
+// checkSecret 检查默认流媒体的 secret，为空时标记降级并输出醒目的错误日志
+// lalmax 不校验 secret，无需检查
+func (n *NodeManager) checkSecret(cfg *conf.Media) bool {
+	if cfg.Type == ProtocolLalmax || cfg.Secret != "" {
+		n.degraded.Store(false)
+		return true
+	}
+	n.degraded.Store(true)
+	slog.Error("****************************************************************")
+	slog.Error("* 流媒体 secret 为空，所有流媒体接口调用都将鉴权失败")
+	slog.Error("* 请在配置文件 [Media] Secret 中填写，或调用 PUT /config/media/secret 设置")
+	slog.Error("****************************************************************")
+	return false
+}
+
+// IsDegraded 流媒体子系统是否处于降级状态
+func (n *NodeManager) IsDegraded() bool {
+	return n.degraded.Load()
+}
+
+// SetSecret 运行时设置默认流媒体的 secret
+// 先用新 secret 验证连接，验证通过才落库并重新下发配置，成功后解除降级状态
+func (n *NodeManager) SetSecret(ctx context.Context, bc *conf.Bootstrap, serverPort int, secret string) (*MediaServer, error) {
+	if secret == "" {
+		return nil, reason.ErrBadRequest.SetMsg("secret 不能为空")
+	}
+
+	var ms MediaServer
+	if err := n.storer.MediaServer().Get(ctx, &ms, orm.Where("id=?", DefaultMediaServerID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Get err[%s]`, err.Error())
+	}
+	driver, err := n.getDriver(ms.Type)
+	if err != nil {
+		return nil, reason.ErrServer.SetMsg(err.Error())
+	}
+	verify := ms
+	verify.Secret = secret
+	if err := driver.Connect(ctx, &verify); err != nil {
+		return nil, reason.ErrBadRequest.SetMsg(fmt.Sprintf("secret 验证失败: %s", err))
+	}
+
+	if err := n.storer.MediaServer().Edit(ctx, &ms, func(b *MediaServer) {
+		b.Secret = secret
+	}, orm.Where("id=?", DefaultMediaServerID)); err != nil {
+		return nil, reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
+	}
+	if err := n.connection(ctx, &ms, serverPort); err != nil {
+		return nil, reason.ErrServer.SetMsg(err.Error())
+	}
+
+	bc.Media.Secret = secret
+	n.degraded.Store(false)
+	slog.Info("流媒体 secret 已更新，解除降级状态")
+	return &ms, nil
+}
+
 func (n *NodeManager) Run(bc *conf.Bootstrap, serverPort int) error {
 	ctx := context.Background()
 	setupSecret(bc)
+	n.checkSecret(&bc.Media)
 	cfg := bc.Media
+	n.snapshots = newSnapshotLimiter(cfg.SnapshotConcurrency, cfg.SnapshotCacheTTL.Duration())
 	setValueFn := func(ms *MediaServer) {
 		ms.ID = DefaultMediaServerID
 		ms.IP = cfg.IP
@@ -152,6 +287,7 @@ func (n *NodeManager) Run(bc *conf.Bootstrap, serverPort int) error {
 		ms.RTPPortRange = cfg.RTPPortRange
 		ms.HookIP = cfg.WebHookIP
 		ms.SDPIP = cfg.SDPIP
+		ms.MaxPlays = cfg.MaxPlays
 	}
 
 	var ms MediaServer
@@ -175,17 +311,19 @@ func (n *NodeManager) Run(bc *conf.Bootstrap, serverPort int) error {
 	}
 
 	for _, ms := range mediaServers {
-		go func(ms *MediaServer) {
-			if err := n.connection(ms, serverPort); err != nil {
+		n.spawn(func(ctx context.Context) {
+			if err := n.connection(ctx, ms, serverPort); err != nil {
 				slog.Error("Connect media server failed", "id", ms.ID, "err", err)
 			}
-		}(ms)
+		})
 	}
 
 	return nil
 }
 
-func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
+func (n *NodeManager) connection(ctx context.Context, server *MediaServer, serverPort int) error {
+	// 已缓存说明是重连，重连时流会全部断开，需要校正通道状态
+	_, reconnect := n.cacheServers.Load(server.ID)
 	n.cacheServers.Store(server.ID, &WarpMediaServer{
 		LastUpdatedAt: time.Now(),
 		Config:        server,
@@ -200,13 +338,15 @@ func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
 	log := slog.With("id", server.ID, "type", server.Type)
 	log.Info("MediaServer 连接中...")
 
-	ctx := context.Background()
 	if err := driver.Connect(ctx, server); err != nil {
 		log.Error("MediaServer 连接失败", "err", err)
 		return err
 	}
 	log.Info("MediaServer 连接成功")
 
+	// 连接成功后的落库与配置下发不随 Close 中断，避免节点状态与数据库不一致
+	ctx = context.WithoutCancel(ctx)
+
 	// 更新数据库中的端口信息等
 	if err := n.storer.MediaServer().Edit(ctx, &MediaServer{}, func(b *MediaServer) {
 		// 更新字段
@@ -214,7 +354,8 @@ func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
 		b.HookAliveInterval = server.HookAliveInterval
 		b.Status = server.Status
 	}, orm.Where("id=?", server.ID)); err != nil {
-		panic(fmt.Errorf("保存 MediaServer 失败 %w", err))
+		log.Error("保存 MediaServer 失败", "err", err)
+		return reason.ErrDB.Withf(`Edit err[%s]`, err.Error())
 	}
 
 	log.Info("MediaServer 配置设置...")
@@ -224,9 +365,26 @@ func (n *NodeManager) connection(server *MediaServer, serverPort int) error {
 		return err
 	}
 
+	if reconnect {
+		n.reconcileStreams(ctx, driver, server)
+	}
 	return nil
 }
 
+// reconcileStreams 查询节点在线流并交由上层校正通道状态，查询失败时不校正，避免误将通道置为未播放
+func (n *NodeManager) reconcileStreams(ctx context.Context, driver Driver, server *MediaServer) {
+	fn := n.reconciler.Load()
+	if fn == nil {
+		return
+	}
+	streams, err := driver.ListStreams(ctx, server)
+	if err != nil {
+		slog.Warn("查询节点在线流失败，跳过通道状态校正", "id", server.ID, "err", err)
+		return
+	}
+	(*fn)(ctx, server.ID, streams)
+}
+
 func (n *NodeManager) Keepalive(serverID string) {
 	value, ok := n.cacheServers.Load(serverID)
 	if !ok {
@@ -280,12 +438,20 @@ func (n *NodeManager) AddStreamProxy(server *MediaServer, in AddStreamProxyReque
 	return driver.AddStreamProxy(context.Background(), server, &in)
 }
 
+// GetSnapshot 抓拍经过全局并发限制，同一流短时间内的重复抓拍复用结果
 func (n *NodeManager) GetSnapshot(server *MediaServer, in GetSnapRequest) ([]byte, error) {
 	driver, err := n.getDriver(server.Type)
 	if err != nil {
 		return nil, err
 	}
-	return driver.GetSnapshot(context.Background(), server, &in)
+	// 抓拍地址与过期时间不同的请求结果不能互相复用
+	var key string
+	if in.Stream != "" {
+		key = fmt.Sprintf("%s:%s:%d:%s", server.ID, in.Stream, in.ExpireSec, in.URL)
+	}
+	return n.snapshots.do(key, func() ([]byte, error) {
+		return driver.GetSnapshot(context.Background(), server, &in)
+	})
 }
 
 func (n *NodeManager) GetStreamLiveAddr(server *MediaServer, httpPrefix, host, app, stream string) StreamLiveAddr {
@@ -296,6 +462,50 @@ func (n *NodeManager) GetStreamLiveAddr(server *MediaServer, httpPrefix, host, a
 	return driver.GetStreamLiveAddr(context.Background(), server, httpPrefix, host, app, stream)
 }
 
+// GetStreamCodec 查询流的音视频编码
+func (n *NodeManager) GetStreamCodec(ctx context.Context, server *MediaServer, app, stream string) (*bz.StreamCodec, error) {
+	driver, err := n.getDriver(server.Type)
+	if err != nil {
+		return nil, err
+	}
+	return driver.GetStreamCodec(ctx, server, app, stream)
+}
+
+type cachedMediaInfo struct {
+	info     *MediaInfo
+	expireAt time.Time
+}
+
+// GetMediaInfo 查询流的分辨率、编码、码率与帧率，结果短暂缓存
+func (n *NodeManager) GetMediaInfo(ctx context.Context, server *MediaServer, app, stream string) (*MediaInfo, error) {
+	key := server.ID + "/" + app + "/" + stream
+	if v, ok := n.mediaInfos.Load(key); ok && time.Now().Before(v.expireAt) {
+		return v.info, nil
+	}
+	driver, err := n.getDriver(server.Type)
+	if err != nil {
+		return nil, err
+	}
+	info, err := driver.GetMediaInfo(ctx, server, app, stream)
+	if err != nil {
+		n.mediaInfos.Delete(key)
+		return nil, err
+	}
+	n.mediaInfos.Store(key, cachedMediaInfo{info: info, expireAt: time.Now().Add(mediaInfoTTL)})
+	return info, nil
+}
+
+// InvalidateMediaInfo 流注销或无人观看关闭时清除该流各节点的画质信息缓存
+func (n *NodeManager) InvalidateMediaInfo(app, stream string) {
+	suffix := "/" + app + "/" + stream
+	n.mediaInfos.Range(func(key string, _ cachedMediaInfo) bool {
+		if strings.HasSuffix(key, suffix) {
+			n.mediaInfos.Delete(key)
+		}
+		return true
+	})
+}
+
 // StartRecord 开始录制指定流
 func (n *NodeManager) StartRecord(server *MediaServer, in zlm.StartRecordRequest) (*zlm.StartRecordResponse, error) {
 	driver, err := n.getDriver(server.Type)
@@ -313,3 +523,21 @@ func (n *NodeManager) StopRecord(server *MediaServer, in zlm.StopRecordRequest)
 	}
 	return driver.StopRecord(context.Background(), server, &in)
 }
+
+// StartSendRTP 将流推送到上级平台
+func (n *NodeManager) StartSendRTP(server *MediaServer, in zlm.StartSendRTPRequest) (*zlm.StartSendRTPResponse, error) {
+	driver, err := n.getDriver(server.Type)
+	if err != nil {
+		return nil, err
+	}
+	return driver.StartSendRTP(context.Background(), server, &in)
+}
+
+// StopSendRTP 停止推送到上级平台
+func (n *NodeManager) StopSendRTP(server *MediaServer, in zlm.StopSendRTPRequest) error {
+	driver, err := n.getDriver(server.Type)
+	if err != nil {
+		return err
+	}
+	return driver.StopSendRTP(context.Background(), server, &in)
+}
@@ -3,9 +3,12 @@ package sms
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gowvp/owl/internal/conf"
 	"github.com/ixugo/goddd/pkg/orm"
 )
 
@@ -66,3 +69,114 @@ func TestKeepalvie(t *testing.T) {
 	// edit status: false
 	// edit status: true
 }
+
+func TestCheckSecretDegraded(t *testing.T) {
+	var storer TestStorer
+	nm := NewNodeManager(&storer)
+	defer nm.Close()
+
+	if nm.IsDegraded() {
+		t.Fatal("expect not degraded before check")
+	}
+	if nm.checkSecret(&conf.Media{Type: ProtocolZLMediaKit}) {
+		t.Fatal("expect check failed with empty secret")
+	}
+	if !nm.IsDegraded() {
+		t.Fatal("expect degraded with empty secret")
+	}
+	if !nm.checkSecret(&conf.Media{Type: ProtocolZLMediaKit, Secret: "abc"}) || nm.IsDegraded() {
+		t.Fatal("expect recovered with secret")
+	}
+	if !nm.checkSecret(&conf.Media{Type: ProtocolLalmax}) || nm.IsDegraded() {
+		t.Fatal("lalmax does not require secret")
+	}
+}
+
+func TestCheckOnlineByHookAliveInterval(t *testing.T) {
+	var storer TestStorer
+	nm := NewNodeManager(&storer)
+	defer nm.Close()
+
+	// 心跳间隔 20s，40s 未收到心跳不应判定离线
+	slow := WarpMediaServer{
+		LastUpdatedAt: time.Now().Add(-40 * time.Second),
+		Config:        &MediaServer{Type: "unknown", HookAliveInterval: 20},
+	}
+	nm.checkOnline(context.Background(), &slow)
+	if !slow.IsOnline {
+		t.Fatal("expect online within 3x hook alive interval")
+	}
+
+	// 心跳间隔 10s，40s 未收到心跳应判定离线
+	fast := WarpMediaServer{
+		IsOnline:      true,
+		LastUpdatedAt: time.Now().Add(-40 * time.Second),
+		Config:        &MediaServer{Type: "unknown", HookAliveInterval: 10},
+	}
+	nm.checkOnline(context.Background(), &fast)
+	if fast.IsOnline {
+		t.Fatal("expect offline after 3x hook alive interval")
+	}
+}
+
+// blockingDriver Connect 阻塞直到 ctx 取消，模拟连接无响应的节点
+type blockingDriver struct {
+	Driver
+	started chan struct{}
+	stopped atomic.Bool
+}
+
+func (d *blockingDriver) Connect(ctx context.Context, _ *MediaServer) error {
+	close(d.started)
+	<-ctx.Done()
+	time.Sleep(20 * time.Millisecond)
+	d.stopped.Store(true)
+	return ctx.Err()
+}
+
+func TestNodeManagerClose(t *testing.T) {
+	var storer TestStorer
+	nm := NewNodeManager(&storer)
+	d := blockingDriver{started: make(chan struct{})}
+	nm.RegisterDriver("block", &d)
+
+	if !nm.spawn(func(ctx context.Context) {
+		_ = nm.connection(ctx, &MediaServer{ID: "n1", Type: "block"}, 0)
+	}) {
+		t.Fatal("expect spawn before close")
+	}
+	<-d.started
+
+	done := make(chan struct{})
+	go func() {
+		nm.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked")
+	}
+	if !d.stopped.Load() {
+		t.Fatal("expect Close return after connection stopped")
+	}
+
+	if nm.spawn(func(context.Context) { t.Error("should not run after close") }) {
+		t.Fatal("expect spawn refused after close")
+	}
+	nm.Close()
+}
+
+func TestInvalidateMediaInfo(t *testing.T) {
+	var n NodeManager
+	expireAt := time.Now().Add(time.Minute)
+	for _, key := range []string{"local/live/cam", "node2/live/cam", "local/live/cam2", "local/rtp/cam"} {
+		n.mediaInfos.Store(key, cachedMediaInfo{info: &MediaInfo{}, expireAt: expireAt})
+	}
+	n.InvalidateMediaInfo("live", "cam")
+	keys := n.mediaInfos.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"local/live/cam2", "local/rtp/cam"}) {
+		t.Fatalf("unexpected cache keys %v", keys)
+	}
+}
@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"sync"
+
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// ErrPlayCapacityReached 媒体服务器并发点播数达到上限
+var ErrPlayCapacityReached = reason.NewError("ErrPlayCapacityReached", "媒体服务器点播数已达上限")
+
+// playSlots 记录各媒体服务器已分配的点播，key 为流 ID，value 为媒体服务器 ID
+// 计数与占用需在同一把锁内完成，避免并发点播同时通过上限检查
+type playSlots struct {
+	mu      sync.Mutex
+	streams map[string]string
+}
+
+// AcquirePlay 占用媒体服务器的点播名额，同一流重复点播不额外计数
+func (n *NodeManager) AcquirePlay(server *MediaServer, streamID string) error {
+	n.plays.mu.Lock()
+	defer n.plays.mu.Unlock()
+
+	if n.plays.streams == nil {
+		n.plays.streams = make(map[string]string)
+	}
+	if id, ok := n.plays.streams[streamID]; ok && id == server.ID {
+		return nil
+	}
+	if server.MaxPlays > 0 && n.activePlays(server.ID) >= server.MaxPlays {
+		return ErrPlayCapacityReached.Withf("media_server[%s] max_plays[%d]", server.ID, server.MaxPlays)
+	}
+	n.plays.streams[streamID] = server.ID
+	return nil
+}
+
+// ReleasePlay 释放流占用的点播名额，未占用时忽略
+func (n *NodeManager) ReleasePlay(streamID string) {
+	n.plays.mu.Lock()
+	defer n.plays.mu.Unlock()
+	delete(n.plays.streams, streamID)
+}
+
+// ActivePlays 媒体服务器当前占用的点播数
+func (n *NodeManager) ActivePlays(serverID string) int {
+	n.plays.mu.Lock()
+	defer n.plays.mu.Unlock()
+	return n.activePlays(serverID)
+}
+
+func (n *NodeManager) activePlays(serverID string) int {
+	var count int
+	for _, id := range n.plays.streams {
+		if id == serverID {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,45 @@
+package sms
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAcquirePlayCapacity(t *testing.T) {
+	var n NodeManager
+	server := &MediaServer{ID: "local", MaxPlays: 2}
+
+	for _, stream := range []string{"ch1", "ch2"} {
+		if err := n.AcquirePlay(server, stream); err != nil {
+			t.Fatalf("acquire %s: %v", stream, err)
+		}
+	}
+	if err := n.AcquirePlay(server, "ch3"); !errors.Is(err, ErrPlayCapacityReached) {
+		t.Fatalf("expect ErrPlayCapacityReached, got %v", err)
+	}
+	// 重复点播同一流不占用新名额
+	if err := n.AcquirePlay(server, "ch1"); err != nil {
+		t.Fatalf("replay should not be rejected: %v", err)
+	}
+	if got := n.ActivePlays("local"); got != 2 {
+		t.Fatalf("expect 2 active plays, got %d", got)
+	}
+
+	// 其他节点不受影响
+	if err := n.AcquirePlay(&MediaServer{ID: "node2", MaxPlays: 1}, "ch3"); err != nil {
+		t.Fatal(err)
+	}
+
+	n.ReleasePlay("ch1")
+	if err := n.AcquirePlay(server, "ch4"); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+
+	// 0 表示不限制
+	unlimited := &MediaServer{ID: "node3"}
+	for _, stream := range []string{"a", "b", "c", "d"} {
+		if err := n.AcquirePlay(unlimited, stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
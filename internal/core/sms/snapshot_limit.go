@@ -0,0 +1,107 @@
+package sms
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/conc"
+	"github.com/ixugo/goddd/pkg/reason"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrSnapshotBusy 抓拍排队超时，流媒体正忙于处理其它抓拍
+var ErrSnapshotBusy = reason.NewError("ErrSnapshotBusy", "抓拍请求过多，请稍后重试")
+
+const (
+	// DefaultSnapshotConcurrency 未配置时同时向流媒体发起的抓拍数
+	DefaultSnapshotConcurrency = 4
+	// DefaultSnapshotCacheTTL 未配置时同一通道抓拍结果的复用时长
+	DefaultSnapshotCacheTTL = 2 * time.Second
+	// snapshotWaitTimeout 排队等待抓拍名额的最长时间
+	snapshotWaitTimeout = 15 * time.Second
+)
+
+// snapshotLimiter 所有抓拍共用的并发限制与短时缓存
+// 事件回退、封面刷新、设备墙、遮挡检测等功能同时抓拍时，避免压垮流媒体
+// 同一通道并发的抓拍合并为一次，短时间内重复抓拍直接复用结果
+type snapshotLimiter struct {
+	sem   chan struct{}
+	ttl   time.Duration
+	group singleflight.Group
+	cache conc.Map[string, cachedSnapshot]
+	// pruneAt 下次清理过期缓存的时间（UnixNano），不再抓拍的通道缓存由此释放
+	pruneAt atomic.Int64
+}
+
+type cachedSnapshot struct {
+	body     []byte
+	expireAt time.Time
+}
+
+// newSnapshotLimiter concurrency 与 ttl 为 0 时使用默认值，ttl 小于 0 表示不缓存
+func newSnapshotLimiter(concurrency int, ttl time.Duration) *snapshotLimiter {
+	if concurrency <= 0 {
+		concurrency = DefaultSnapshotConcurrency
+	}
+	if ttl == 0 {
+		ttl = DefaultSnapshotCacheTTL
+	}
+	return &snapshotLimiter{sem: make(chan struct{}, concurrency), ttl: ttl}
+}
+
+// do 按 key 复用缓存或合并并发请求，key 为空时仅限制并发，零值 NodeManager 不做限制
+func (l *snapshotLimiter) do(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if l == nil {
+		return fetch()
+	}
+	if key == "" {
+		return l.acquire(fetch)
+	}
+	if v, ok := l.cache.Load(key); ok {
+		if time.Now().Before(v.expireAt) {
+			return v.body, nil
+		}
+		l.cache.Delete(key)
+	}
+
+	v, err, _ := l.group.Do(key, func() (any, error) {
+		body, err := l.acquire(fetch)
+		if err == nil && l.ttl > 0 {
+			now := time.Now()
+			l.cache.Store(key, cachedSnapshot{body: body, expireAt: now.Add(l.ttl)})
+			l.prune(now)
+		}
+		return body, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// prune 每个缓存周期最多清理一次过期缓存
+func (l *snapshotLimiter) prune(now time.Time) {
+	next := l.pruneAt.Load()
+	if now.UnixNano() < next || !l.pruneAt.CompareAndSwap(next, now.Add(l.ttl).UnixNano()) {
+		return
+	}
+	l.cache.Range(func(key string, v cachedSnapshot) bool {
+		if !now.Before(v.expireAt) {
+			l.cache.Delete(key)
+		}
+		return true
+	})
+}
+
+// acquire 占用抓拍名额后执行 fetch，排队超时返回 ErrSnapshotBusy
+func (l *snapshotLimiter) acquire(fetch func() ([]byte, error)) ([]byte, error) {
+	timer := time.NewTimer(snapshotWaitTimeout)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+	case <-timer.C:
+		return nil, ErrSnapshotBusy.Withf("wait %s", snapshotWaitTimeout)
+	}
+	defer func() { <-l.sem }()
+	return fetch()
+}
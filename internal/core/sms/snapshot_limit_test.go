@@ -0,0 +1,154 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotLimiterCache(t *testing.T) {
+	l := newSnapshotLimiter(2, time.Minute)
+	var calls atomic.Int32
+	fetch := func() ([]byte, error) {
+		calls.Add(1)
+		return []byte("jpeg"), nil
+	}
+
+	for range 3 {
+		body, err := l.do("local:ch1", fetch)
+		if err != nil || string(body) != "jpeg" {
+			t.Fatalf("unexpected %q %v", body, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expect cache hit, fetched %d times", n)
+	}
+
+	// 不同通道互不复用
+	_, _ = l.do("local:ch2", fetch)
+	// 过期后重新抓拍
+	l.cache.Store("local:ch1", cachedSnapshot{body: []byte("old"), expireAt: time.Now().Add(-time.Second)})
+	_, _ = l.do("local:ch1", fetch)
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expect 3 fetches, got %d", n)
+	}
+
+	// 失败不缓存
+	failed := newSnapshotLimiter(1, time.Minute)
+	if _, err := failed.do("local:ch1", func() ([]byte, error) { return nil, fmt.Errorf("offline") }); err == nil {
+		t.Fatal("expect error")
+	}
+	if _, ok := failed.cache.Load("local:ch1"); ok {
+		t.Fatal("expect failed snapshot not cached")
+	}
+
+	// ttl 小于 0 不缓存
+	noCache := newSnapshotLimiter(1, -1)
+	_, _ = noCache.do("local:ch1", fetch)
+	_, _ = noCache.do("local:ch1", fetch)
+	if n := calls.Load(); n != 5 {
+		t.Fatalf("expect no cache, got %d fetches", n)
+	}
+}
+
+func TestSnapshotLimiterPrune(t *testing.T) {
+	l := newSnapshotLimiter(1, time.Minute)
+	l.cache.Store("local:gone", cachedSnapshot{body: []byte("old"), expireAt: time.Now().Add(-time.Second)})
+	fetch := func() ([]byte, error) { return []byte("jpeg"), nil }
+
+	// 不再抓拍的通道，过期缓存在其它通道抓拍时清理
+	_, _ = l.do("local:ch1", fetch)
+	if _, ok := l.cache.Load("local:gone"); ok {
+		t.Fatal("expect expired snapshot pruned")
+	}
+	if _, ok := l.cache.Load("local:ch1"); !ok {
+		t.Fatal("expect fresh snapshot kept")
+	}
+}
+
+// snapDriver 记录抓拍请求
+type snapDriver struct {
+	Driver
+	calls atomic.Int32
+}
+
+func (d *snapDriver) GetSnapshot(context.Context, *MediaServer, *GetSnapRequest) ([]byte, error) {
+	d.calls.Add(1)
+	return []byte("jpeg"), nil
+}
+
+func TestNodeManagerGetSnapshotKey(t *testing.T) {
+	d := &snapDriver{}
+	n := NodeManager{drivers: map[string]Driver{"zlm": d}, snapshots: newSnapshotLimiter(1, time.Minute)}
+	svr := &MediaServer{ID: DefaultMediaServerID}
+
+	req := func(url string, expire int) GetSnapRequest {
+		var in GetSnapRequest
+		in.URL, in.ExpireSec, in.Stream = url, expire, "ch1"
+		return in
+	}
+	for _, in := range []GetSnapRequest{
+		req("rtsp://127.0.0.1/rtp/ch1", 1),
+		req("rtsp://127.0.0.1/rtp/ch1", 1),
+		req("rtsp://127.0.0.1/rtp/ch1", 30),
+		req("rtsp://127.0.0.1/rtp/ch1?sub", 1),
+	} {
+		if _, err := n.GetSnapshot(svr, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 相同请求复用，地址或过期时间不同时重新抓拍
+	if got := d.calls.Load(); got != 3 {
+		t.Fatalf("expect 3 snapshots, got %d", got)
+	}
+}
+
+func TestSnapshotLimiterConcurrency(t *testing.T) {
+	const limit = 3
+	l := newSnapshotLimiter(limit, -1)
+
+	var running, peak, calls atomic.Int32
+	fetch := func() ([]byte, error) {
+		calls.Add(1)
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return []byte("jpeg"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := range 40 {
+		wg.Go(func() {
+			if _, err := l.do(fmt.Sprintf("local:ch%d", i), fetch); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if p := peak.Load(); p > limit || p == 0 {
+		t.Fatalf("expect at most %d concurrent snapshots, got %d", limit, p)
+	}
+	if n := calls.Load(); n != 40 {
+		t.Fatalf("expect 40 fetches, got %d", n)
+	}
+
+	// 同一通道的并发抓拍合并为一次
+	calls.Store(0)
+	var same sync.WaitGroup
+	for range 20 {
+		same.Go(func() { _, _ = l.do("local:hot", fetch) })
+	}
+	same.Wait()
+	if n := calls.Load(); n >= 20 {
+		t.Fatalf("expect concurrent callers coalesced, got %d fetches", n)
+	}
+}
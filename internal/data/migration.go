@@ -185,7 +185,7 @@ func MigrateStreamData(db *gorm.DB, uni uniqueid.Core) error {
 					SourceURL:                 p.SourceURL,
 					Transport:                 p.Transport,
 					TimeoutS:                  p.TimeoutS,
-					EnabledAudio:              p.EnabledAudio,
+					EnabledAudio:              new(p.EnabledAudio),
 					EnabledRemoveNoneReader:   p.EnabledRemoveNoneReader,
 					EnabledDisabledNoneReader: p.EnabledDisabledNoneReader,
 					StreamKey:                 p.StreamKey,
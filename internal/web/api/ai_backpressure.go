@@ -0,0 +1,49 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// aiEventHighWater 待入库事件数达到该值时要求 AI 服务减速
+	aiEventHighWater = 16
+	// aiEventBackoffBase 队列刚到水位时建议暂停推送的时长，随积压按比例增加
+	aiEventBackoffBase = 2 * time.Second
+	// aiEventBackoffMax 建议暂停推送的最长时长
+	aiEventBackoffMax = 30 * time.Second
+)
+
+// aiEventQueue 统计正在入库的检测事件数，数据库变慢时积压增加
+// 以此向 AI 服务反馈背压，避免 owl 被检测事件淹没
+type aiEventQueue struct {
+	pending   atomic.Int64
+	highWater int64
+}
+
+func newAIEventQueue(highWater int64) *aiEventQueue {
+	return &aiEventQueue{highWater: highWater}
+}
+
+// enqueue 登记 n 个待入库事件，返回登记后的积压数
+func (q *aiEventQueue) enqueue(n int) int64 {
+	return q.pending.Add(int64(n))
+}
+
+// done 完成一个事件的入库
+func (q *aiEventQueue) done() {
+	q.pending.Add(-1)
+}
+
+// backpressure 积压达到水位时返回减速建议，否则为 nil
+func (q *aiEventQueue) backpressure(pending int64) *AIBackpressure {
+	if q.highWater <= 0 || pending < q.highWater {
+		return nil
+	}
+	backoff := min(aiEventBackoffBase*time.Duration(pending/q.highWater), aiEventBackoffMax)
+	return &AIBackpressure{
+		SlowDown:     true,
+		RetryAfterMs: backoff.Milliseconds(),
+		Pending:      pending,
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAIEventQueueBackpressure(t *testing.T) {
+	q := newAIEventQueue(16)
+
+	if bp := q.backpressure(q.enqueue(15)); bp != nil {
+		t.Fatalf("expect no backpressure below high water, got %+v", bp)
+	}
+	bp := q.backpressure(q.enqueue(1))
+	if bp == nil || !bp.SlowDown || bp.RetryAfterMs != 2000 || bp.Pending != 16 {
+		t.Fatalf("expect slow down at high water, got %+v", bp)
+	}
+	// 积压越深建议暂停越久，但不超过上限
+	if bp := q.backpressure(q.enqueue(32)); bp == nil || bp.RetryAfterMs != 6000 {
+		t.Fatalf("expect longer backoff, got %+v", bp)
+	}
+	if bp := q.backpressure(q.enqueue(1000)); bp == nil || bp.RetryAfterMs != aiEventBackoffMax.Milliseconds() {
+		t.Fatalf("expect capped backoff, got %+v", bp)
+	}
+
+	// 入库完成后积压回落
+	var wg sync.WaitGroup
+	for range 1048 {
+		wg.Go(q.done)
+	}
+	wg.Wait()
+	if n := q.pending.Load(); n != 0 {
+		t.Fatalf("expect drained queue, got %d", n)
+	}
+	if bp := q.backpressure(q.enqueue(3)); bp != nil {
+		t.Fatalf("expect backpressure released, got %+v", bp)
+	}
+
+	if bp := newAIEventQueue(0).backpressure(1 << 20); bp != nil {
+		t.Fatal("expect disabled queue never slows down")
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+)
+
+// aiFPSBudget 全局 AI 检测帧率预算，记录各通道占用的帧率
+// 避免大量通道同时以高帧率检测压垮解码与推理管线
+type aiFPSBudget struct {
+	m        sync.Mutex
+	max      int // 帧率之和上限，0 表示不限制
+	channels map[string]int
+}
+
+func newAIFPSBudget(max int) *aiFPSBudget {
+	return &aiFPSBudget{max: max, channels: make(map[string]int)}
+}
+
+// reserve 为通道申请检测帧率，返回实际分配的帧率
+// 通道已有的占用视为释放后重新申请；剩余预算不足时按剩余量降低帧率，一帧都不剩时拒绝
+func (b *aiFPSBudget) reserve(cid string, want int) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.max > 0 {
+		remain := b.max - b.usedExcept(cid)
+		if remain < 1 {
+			return 0, fmt.Errorf("AI 检测帧率预算已用尽(上限 %d fps)", b.max)
+		}
+		want = min(want, remain)
+	}
+	b.channels[cid] = want
+	return want, nil
+}
+
+// release 释放通道占用的帧率
+func (b *aiFPSBudget) release(cid string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	delete(b.channels, cid)
+}
+
+// fps 通道已分配的帧率，未分配时返回 false
+func (b *aiFPSBudget) fps(cid string) (int, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	v, ok := b.channels[cid]
+	return v, ok
+}
+
+// usage 返回预算使用情况
+func (b *aiFPSBudget) usage() AIFPSBudgetOutput {
+	b.m.Lock()
+	defer b.m.Unlock()
+	used := b.usedExcept("")
+	out := AIFPSBudgetOutput{MaxTotalFPS: b.max, UsedFPS: used, Channels: len(b.channels)}
+	if b.max > 0 {
+		out.RemainFPS = max(b.max-used, 0)
+	}
+	return out
+}
+
+// usedExcept 除指定通道外已占用的帧率之和，调用方需持有锁
+func (b *aiFPSBudget) usedExcept(cid string) int {
+	var used int
+	for k, v := range b.channels {
+		if k != cid {
+			used += v
+		}
+	}
+	return used
+}
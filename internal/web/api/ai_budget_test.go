@@ -0,0 +1,51 @@
+package api
+
+import "testing"
+
+func TestAIFPSBudget(t *testing.T) {
+	b := newAIFPSBudget(5)
+
+	steps := []struct {
+		cid    string
+		want   int
+		expect int
+		ok     bool
+	}{
+		{cid: "ch1", want: 2, expect: 2, ok: true},
+		{cid: "ch2", want: 2, expect: 2, ok: true},
+		// 剩余不足时按剩余量降低帧率
+		{cid: "ch3", want: 3, expect: 1, ok: true},
+		// 预算用尽时拒绝
+		{cid: "ch4", want: 1},
+		// 重复申请先释放自身占用
+		{cid: "ch1", want: 3, expect: 2, ok: true},
+	}
+	for i, s := range steps {
+		fps, err := b.reserve(s.cid, s.want)
+		if (err == nil) != s.ok || fps != s.expect {
+			t.Fatalf("step %d %s: expect (%d, ok=%v), got (%d, %v)", i, s.cid, s.expect, s.ok, fps, err)
+		}
+	}
+	if _, ok := b.fps("ch4"); ok {
+		t.Fatal("expect rejected channel holds no budget")
+	}
+	if u := b.usage(); u.UsedFPS != 5 || u.RemainFPS != 0 || u.Channels != 3 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	b.release("ch2")
+	if fps, err := b.reserve("ch4", 4); err != nil || fps != 2 {
+		t.Fatalf("expect released budget reused, got %d %v", fps, err)
+	}
+
+	// 0 表示不限制
+	unlimited := newAIFPSBudget(0)
+	for _, cid := range []string{"a", "b", "c"} {
+		if fps, err := unlimited.reserve(cid, 30); err != nil || fps != 30 {
+			t.Fatalf("expect unlimited, got %d %v", fps, err)
+		}
+	}
+	if u := unlimited.usage(); u.UsedFPS != 90 || u.MaxTotalFPS != 0 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+// fallbackAILabels 配置文件未填写默认标签时使用
+var fallbackAILabels = []string{"person", "car", "cat", "dog"}
+
+// cocoLabels 内置模型 (YOLO COCO) 支持的标签
+var cocoLabels = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat", "traffic light",
+	"fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat", "dog", "horse", "sheep", "cow",
+	"elephant", "bear", "zebra", "giraffe", "backpack", "umbrella", "handbag", "tie", "suitcase", "frisbee",
+	"skis", "snowboard", "sports ball", "kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket", "bottle",
+	"wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple", "sandwich", "orange",
+	"broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair", "couch", "potted plant", "bed",
+	"dining table", "toilet", "tv", "laptop", "mouse", "remote", "keyboard", "cell phone", "microwave", "oven",
+	"toaster", "sink", "refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier", "toothbrush",
+}
+
+// findAILabels 返回模型支持的标签目录，供前端标签选择器使用
+func (a AIWebhookAPI) findAILabels(_ *gin.Context, _ *struct{}) (gin.H, error) {
+	ai := a.conf.Server.AI
+	return gin.H{
+		"items":    aiLabelCatalog(ai.Labels),
+		"defaults": resolveAILabels(nil, ai.DefaultLabels),
+	}, nil
+}
+
+// aiLabelCatalog 优先使用配置的标签目录，未配置时使用内置 COCO 标签
+func aiLabelCatalog(configured []string) []string {
+	if len(configured) > 0 {
+		return configured
+	}
+	return cocoLabels
+}
+
+// resolveAILabels 区域标签为空时依次回退到配置的默认标签、内置默认标签
+func resolveAILabels(labels, defaults []string) []string {
+	if len(labels) > 0 {
+		return labels
+	}
+	if len(defaults) > 0 {
+		return slices.Clone(defaults)
+	}
+	return slices.Clone(fallbackAILabels)
+}
+
+// maxAILabelLen 单个标签的最大字符数
+const maxAILabelLen = 32
+
+// validAILabel 标签由字母、数字、空格、下划线、短横线组成，不能以空格开头或结尾
+func validAILabel(label string) bool {
+	n := utf8.RuneCountInString(label)
+	if n == 0 || n > maxAILabelLen || strings.TrimSpace(label) != label {
+		return false
+	}
+	for _, r := range label {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateAILabels 校验标签格式合法且均在模型支持的目录中
+func validateAILabels(labels, catalog []string) error {
+	for _, label := range labels {
+		if !validAILabel(label) {
+			return reason.ErrBadRequest.SetMsg(fmt.Sprintf("标签 %q 无效，应为 1~%d 个字母、数字、空格、下划线或短横线", label, maxAILabelLen))
+		}
+	}
+	var unknown []string
+	for _, label := range labels {
+		if !slices.Contains(catalog, label) {
+			unknown = append(unknown, label)
+		}
+	}
+	if len(unknown) > 0 {
+		return reason.ErrBadRequest.SetMsg("不支持的标签: " + strings.Join(unknown, ","))
+	}
+	return nil
+}
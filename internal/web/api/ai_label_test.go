@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ixugo/goddd/pkg/reason"
+)
+
+func TestResolveAILabels(t *testing.T) {
+	cases := []struct {
+		name     string
+		labels   []string
+		defaults []string
+		expect   []string
+	}{
+		{name: "zone labels", labels: []string{"truck"}, defaults: []string{"person"}, expect: []string{"truck"}},
+		{name: "configured defaults", defaults: []string{"person", "bus"}, expect: []string{"person", "bus"}},
+		{name: "builtin fallback", expect: fallbackAILabels},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resolveAILabels(tc.labels, tc.defaults); !slices.Equal(got, tc.expect) {
+				t.Fatalf("expect %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestValidateAILabels(t *testing.T) {
+	if err := validateAILabels([]string{"person", "traffic light"}, aiLabelCatalog(nil)); err != nil {
+		t.Fatalf("expect valid, got %v", err)
+	}
+	if err := validateAILabels([]string{"person", "ufo"}, aiLabelCatalog(nil)); err == nil {
+		t.Fatal("expect error for unknown label")
+	}
+	if err := validateAILabels([]string{"car"}, aiLabelCatalog([]string{"person"})); err == nil {
+		t.Fatal("expect error when label not in configured catalog")
+	}
+	if err := validateAILabels([]string{"安全帽", "no_helmet", "fork-lift"}, []string{"安全帽", "no_helmet", "fork-lift"}); err != nil {
+		t.Fatalf("expect custom labels valid, got %v", err)
+	}
+	for _, label := range []string{"", " person", "person ", "a,b", "<script>", "a\nb", strings.Repeat("a", maxAILabelLen+1)} {
+		err := validateAILabels([]string{label}, []string{label})
+		if !errors.Is(err, reason.ErrBadRequest) {
+			t.Fatalf("%q: expect ErrBadRequest, got %v", label, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/ffwork"
+	"github.com/gowvp/owl/protos"
+)
+
+const (
+	// 运动检测只需低分辨率低帧率，降低解码开销
+	motionFrameWidth  = 320
+	motionFrameHeight = 180
+	motionFrameFPS    = 2
+	// motionIdleTimeout 持续无运动超过该时长后暂停 AI 检测
+	motionIdleTimeout = 60 * time.Second
+	// motionSwitchTimeout 启停 AI 检测的超时时间
+	motionSwitchTimeout = 10 * time.Second
+)
+
+// motionGate 运动预过滤，通过帧差判断画面是否有运动
+// 有运动时启动 AI 检测，持续静止时暂停，降低 AI 服务负载
+type motionGate struct {
+	capture  *ffwork.FrameCapture
+	detector *ffwork.MotionDetector
+
+	m          sync.Mutex
+	running    bool // AI 检测是否运行中
+	switching  bool // 正在启停 AI 检测，调用期间不持锁，避免重复下发
+	lastMotion time.Time
+}
+
+// startMotionGate 启动通道的运动预过滤，已存在时先停止旧的
+func (a *AIWebhookAPI) startMotionGate(ch *ipc.Channel, rtspURL string) error {
+	a.stopMotionGate(ch.ID)
+
+	gate := motionGate{
+		detector: ffwork.NewMotionDetector(motionFrameWidth, motionFrameHeight, ch.Ext.MotionSensitivity),
+	}
+	capture, err := ffwork.NewFrameCapture(ffwork.Config{
+		Width:   motionFrameWidth,
+		Height:  motionFrameHeight,
+		FPS:     motionFrameFPS,
+		RTSPURL: rtspURL,
+		Name:    ch.ID,
+		OnFrame: func(frame *ffwork.FrameData) {
+			a.onMotionFrame(ch, rtspURL, &gate, frame)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := capture.Start(); err != nil {
+		return err
+	}
+	gate.capture = capture
+	a.motionGates.Store(ch.ID, &gate)
+	a.log.Info("motion pre-filter started", "channel_id", ch.ID, "sensitivity", ch.Ext.MotionSensitivity)
+	return nil
+}
+
+// stopMotionGate 停止通道的运动预过滤
+func (a *AIWebhookAPI) stopMotionGate(channelID string) {
+	gate, ok := a.motionGates.LoadAndDelete(channelID)
+	if !ok {
+		return
+	}
+	if err := gate.capture.Stop(); err != nil {
+		a.log.Warn("stop motion capture", "channel_id", channelID, "err", err)
+	}
+}
+
+// onMotionFrame 处理抓取到的帧，根据运动状态启停 AI 检测
+// 仅在持锁时判断与更新状态，gRPC 调用期间释放锁，避免 AI 服务响应慢时阻塞后续帧
+func (a *AIWebhookAPI) onMotionFrame(ch *ipc.Channel, rtspURL string, gate *motionGate, frame *ffwork.FrameData) {
+	score, moved := gate.detector.Detect(frame.Data)
+
+	gate.m.Lock()
+	if moved {
+		gate.lastMotion = frame.Timestamp
+	}
+	start := moved && !gate.running
+	stop := !moved && gate.running && frame.Timestamp.Sub(gate.lastMotion) > motionIdleTimeout
+	if gate.switching || (!start && !stop) {
+		gate.m.Unlock()
+		return
+	}
+	gate.switching = true
+	gate.m.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), motionSwitchTimeout)
+	defer cancel()
+
+	var err error
+	if start {
+		if _, err = a.startCamera(ctx, ch, rtspURL); err != nil {
+			a.log.Error("motion: start AI detection failed", "channel_id", ch.ID, "err", err)
+		} else {
+			a.log.Info("motion detected, AI detection started", "channel_id", ch.ID, "score", score)
+		}
+	} else {
+		if _, err := a.ai.StopCamera(ctx, &protos.StopCameraRequest{CameraId: ch.ID}); err != nil {
+			a.log.Warn("motion: stop AI detection failed", "channel_id", ch.ID, "err", err)
+		}
+		a.log.Info("no motion, AI detection paused", "channel_id", ch.ID)
+	}
+
+	gate.m.Lock()
+	defer gate.m.Unlock()
+	gate.switching = false
+	if start {
+		gate.running = err == nil
+	} else {
+		gate.running = false
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/protos"
+	"github.com/ixugo/goddd/pkg/conc"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// registerAITaskAPI 注册 AI 任务查询路由，便于运维查看检测管线运行情况
+func registerAITaskAPI(r gin.IRouter, api AIWebhookAPI, handler ...gin.HandlerFunc) {
+	group := r.Group("/ai", handler...)
+	group.GET("/tasks", web.WrapH(api.findAITasks))
+	group.GET("/tasks/:cid", web.WrapH(api.getAITask))
+	group.GET("/labels", web.WrapH(api.findAILabels))
+	group.GET("/budget", web.WrapH(api.getAIBudget))
+}
+
+// findAITasks 列出当前运行 AI 检测的通道
+func (a AIWebhookAPI) findAITasks(c *gin.Context, _ *struct{}) (*web.PageOutput[*AITaskItem], error) {
+	items := buildAITaskList(c.Request.Context(), a.aiTasks, a.motionGates, a.lastDetections, a.ipcCore.GetChannel)
+	for _, item := range items {
+		item.DetectFPS, _ = a.fpsBudget.fps(item.ChannelID)
+	}
+	return &web.PageOutput[*AITaskItem]{Items: items, Total: int64(len(items))}, nil
+}
+
+// getAITask 查询单个通道的 AI 检测详情，运行状态取自 AI 服务
+func (a AIWebhookAPI) getAITask(c *gin.Context, _ *struct{}) (*AITaskDetail, error) {
+	ctx := c.Request.Context()
+	cid := c.Param("cid")
+	if _, ok := a.aiTasks.Load(cid); !ok {
+		return nil, reason.ErrNotFound.SetMsg("AI 检测任务不存在")
+	}
+
+	item := newAITaskItem(ctx, cid, a.motionGates, a.lastDetections, a.ipcCore.GetChannel)
+	item.DetectFPS, _ = a.fpsBudget.fps(cid)
+	out := AITaskDetail{AITaskItem: *item}
+	if a.ai == nil {
+		return &out, nil
+	}
+
+	resp, err := a.ai.GetStatus(ctx, &protos.StatusRequest{})
+	if err != nil {
+		return nil, reason.ErrServer.SetMsg("获取 AI 服务状态失败: " + err.Error())
+	}
+	for _, cam := range resp.GetCameras() {
+		if cam.GetCameraId() != cid {
+			continue
+		}
+		out.Status = cam.GetStatus()
+		out.FramesProcessed = cam.GetFramesProcessed()
+		out.LastError = cam.GetLastError()
+		out.RetryCount = cam.GetRetryCount()
+		break
+	}
+	return &out, nil
+}
+
+// getAIBudget 查询 AI 检测帧率预算使用情况
+func (a AIWebhookAPI) getAIBudget(_ *gin.Context, _ *struct{}) (AIFPSBudgetOutput, error) {
+	return a.fpsBudget.usage(), nil
+}
+
+// buildAITaskList 由内存任务表组装任务列表，补充通道名称与最近检测时间，按通道 ID 排序
+func buildAITaskList(ctx context.Context, tasks *conc.Map[string, struct{}], gates *conc.Map[string, *motionGate], lastDetections *conc.Map[string, orm.Time], getChannel func(context.Context, string) (*ipc.Channel, error)) []*AITaskItem {
+	items := make([]*AITaskItem, 0, 8)
+	tasks.Range(func(cid string, _ struct{}) bool {
+		items = append(items, newAITaskItem(ctx, cid, gates, lastDetections, getChannel))
+		return true
+	})
+	slices.SortFunc(items, func(a, b *AITaskItem) int {
+		return strings.Compare(a.ChannelID, b.ChannelID)
+	})
+	return items
+}
+
+// newAITaskItem 组装单个任务，通道查询失败时仅保留通道 ID
+func newAITaskItem(ctx context.Context, cid string, gates *conc.Map[string, *motionGate], lastDetections *conc.Map[string, orm.Time], getChannel func(context.Context, string) (*ipc.Channel, error)) *AITaskItem {
+	item := AITaskItem{ChannelID: cid}
+	if ch, err := getChannel(ctx, cid); err == nil && ch != nil {
+		item.ChannelName = ch.Name
+	}
+	if _, ok := gates.Load(cid); ok {
+		item.MotionGated = true
+	}
+	if t, ok := lastDetections.Load(cid); ok {
+		item.LastDetectionAt = &t
+	}
+	return &item
+}
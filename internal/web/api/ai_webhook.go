@@ -9,6 +9,7 @@ import (
 	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +21,6 @@ import (
 	"github.com/gowvp/owl/protos"
 	"github.com/ixugo/goddd/pkg/conc"
 	"github.com/ixugo/goddd/pkg/orm"
-	"github.com/ixugo/goddd/pkg/system"
 	"github.com/ixugo/goddd/pkg/web"
 )
 
@@ -33,6 +33,17 @@ type AIWebhookAPI struct {
 	ai        *rpc.AIClient
 	eventCore event.Core
 	ipcCore   ipc.Core
+
+	// motionGates 启用运动预过滤的通道，有运动时才启动 AI 检测
+	motionGates *conc.Map[string, *motionGate]
+	// lastDetections 各通道最近一次收到检测事件的时间
+	lastDetections *conc.Map[string, orm.Time]
+	// fpsBudget 全局检测帧率预算
+	fpsBudget *aiFPSBudget
+	// eventQueue 检测事件入库积压，用于向 AI 服务反馈背压
+	eventQueue *aiEventQueue
+	// syncNow 请求立即对账，AI 服务就绪时触发
+	syncNow chan struct{}
 }
 
 // NewAIWebhookAPI 创建 AI Webhook API 实例
@@ -45,6 +56,12 @@ func NewAIWebhookAPI(conf *conf.Bootstrap, eventCore event.Core, ipcCore ipc.Cor
 		eventCore: eventCore,
 		ipcCore:   ipcCore,
 		limiter:   web.IDRateLimiter(0.2, 1, 3*time.Minute),
+
+		motionGates:    conc.NewMap[string, *motionGate](),
+		lastDetections: conc.NewMap[string, orm.Time](),
+		fpsBudget:      newAIFPSBudget(conf.Server.AI.MaxTotalFPS),
+		eventQueue:     newAIEventQueue(aiEventHighWater),
+		syncNow:        make(chan struct{}, 1),
 	}
 }
 
@@ -75,11 +92,13 @@ func (a AIWebhookAPI) onKeepalive(c *gin.Context, in *AIKeepaliveInput) (AIWebho
 }
 
 // onStarted 接收 AI 服务启动通知，确认 AI 服务已就绪
+// AI 服务（重新）启动后任务全部丢失，立即对账恢复检测，不必等下一个同步周期
 func (a AIWebhookAPI) onStarted(c *gin.Context, in *AIStartedInput) (AIWebhookOutput, error) {
 	a.log.InfoContext(c.Request.Context(), "ai started",
 		"timestamp", in.Timestamp,
 		"message", in.Message,
 	)
+	a.requestAISync()
 	return newAIWebhookOutputOK(), nil
 }
 
@@ -99,6 +118,9 @@ func (a AIWebhookAPI) onEvents(c *gin.Context, in *AIDetectionInput) (AIWebhookO
 
 	// 获取通道信息以确定 DID
 	cid := in.CameraID
+	if len(in.Detections) > 0 {
+		a.lastDetections.Store(cid, in.Timestamp)
+	}
 	var did string
 	channel, err := a.ipcCore.GetChannel(ctx, cid)
 	if err == nil && channel != nil {
@@ -109,12 +131,23 @@ func (a AIWebhookAPI) onEvents(c *gin.Context, in *AIDetectionInput) (AIWebhookO
 	var imagePath string
 	if in.Snapshot != "" {
 		var err error
-		imagePath, err = saveEventSnapshot(cid, in.Timestamp, in.Snapshot)
+		imagePath, err = saveEventSnapshot(snapshotPath(a.conf), snapshotVars(cid, channel, in.Timestamp.Time), in.Snapshot, a.conf.Server.Snapshot)
 		if err != nil {
 			a.log.ErrorContext(ctx, "save snapshot failed", "err", err)
 		}
 	}
 
+	// 积压超过水位时仍然入库本次事件，只在响应中要求 AI 服务减速
+	pending := a.eventQueue.enqueue(len(in.Detections))
+	out := newAIWebhookOutputOK()
+	if out.Backpressure = a.eventQueue.backpressure(pending); out.Backpressure != nil {
+		a.log.WarnContext(ctx, "ai events backlog, ask AI service to slow down",
+			"camera_id", cid,
+			"pending", pending,
+			"retry_after_ms", out.Backpressure.RetryAfterMs,
+		)
+	}
+
 	// 按 label 分别存储事件，每个 label 是一个独立事件
 	for i, det := range in.Detections {
 		a.log.InfoContext(ctx, "detection detail",
@@ -146,9 +179,10 @@ func (a AIWebhookAPI) onEvents(c *gin.Context, in *AIDetectionInput) (AIWebhookO
 				"err", err,
 			)
 		}
+		a.eventQueue.done()
 	}
 
-	return newAIWebhookOutputOK(), nil
+	return out, nil
 }
 
 // onStopped 接收 AI 任务停止通知，记录停止原因
@@ -159,29 +193,56 @@ func (a AIWebhookAPI) onStopped(c *gin.Context, in *AIStoppedInput) (AIWebhookOu
 		"reason", in.Reason,
 		"message", in.Message,
 	)
-	a.aiTasks.Delete(in.CameraID)
+	// 运动预过滤暂停检测时，任务仍视为运行中
+	if _, ok := a.motionGates.Load(in.CameraID); !ok {
+		a.aiTasks.Delete(in.CameraID)
+		a.fpsBudget.release(in.CameraID)
+	}
 	return newAIWebhookOutputOK(), nil
 }
 
+// aiSyncInterval AI 任务周期对账间隔
+const aiSyncInterval = 5 * time.Minute
+
 // StartAISyncLoop 启动 AI 任务同步协程，每 5 分钟检测一次数据库中 enabled_ai 状态与内存 aiTasks 的差异并同步
+// 启动时立即对账一次，owl 重启后依据数据库 enabled_ai 恢复检测，AI 服务已在运行的任务只接管不重复启动
 func (a *AIWebhookAPI) StartAISyncLoop(ctx context.Context, smsCore sms.Core) {
 	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				a.log.Info("AI sync loop stopped")
-				return
-			case <-ticker.C:
-				a.syncAITasks(ctx, smsCore)
-			}
-		}
+		runAISyncLoop(ctx, aiSyncInterval, a.syncNow, func(ctx context.Context) {
+			a.syncAITasks(ctx, smsCore)
+		})
+		a.log.Info("AI sync loop stopped")
 	}()
 }
 
-// syncAITasks 同步 AI 任务状态，确保数据库中 enabled_ai=true 的通道正在运行检测，enabled_ai=false 的已停止
+// requestAISync 请求立即对账，已有待处理的请求时合并
+func (a *AIWebhookAPI) requestAISync() {
+	select {
+	case a.syncNow <- struct{}{}:
+	default:
+	}
+}
+
+// runAISyncLoop 立即执行一次 sync，之后按 interval 周期或收到 trigger 时执行，直到 ctx 结束
+func runAISyncLoop(ctx context.Context, interval time.Duration, trigger <-chan struct{}, sync func(context.Context)) {
+	sync(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+			ticker.Reset(interval)
+		}
+		sync(ctx)
+	}
+}
+
+// syncAITasks 三方对账同步 AI 任务状态：数据库 enabled_ai、内存 aiTasks、AI 服务实际运行的检测任务
+// 对任意一方重启导致的状态漂移进行自愈
 func (a *AIWebhookAPI) syncAITasks(ctx context.Context, smsCore sms.Core) {
 	if a.conf.Server.AI.Disabled || a.ai == nil {
 		return
@@ -198,9 +259,11 @@ func (a *AIWebhookAPI) syncAITasks(ctx context.Context, smsCore sms.Core) {
 
 	// 构建数据库中 enabled_ai=true 的通道集合
 	dbEnabledSet := make(map[string]*ipc.Channel)
+	dbEnabled := make(map[string]struct{})
 	for _, ch := range channels {
 		if ch.Ext.EnabledAI {
 			dbEnabledSet[ch.ID] = ch
+			dbEnabled[ch.ID] = struct{}{}
 		}
 	}
 
@@ -211,25 +274,104 @@ func (a *AIWebhookAPI) syncAITasks(ctx context.Context, smsCore sms.Core) {
 		return true
 	})
 
-	// 需要启动的任务：数据库中 enabled 但内存中没有
-	for channelID, ch := range dbEnabledSet {
-		if _, exists := memoryTasks[channelID]; !exists {
-			a.log.Info("sync: starting AI task", "channel_id", channelID)
-			if err := a.startAITask(ctx, smsCore, ch); err != nil {
-				a.log.ErrorContext(ctx, "sync: start AI task failed", "channel_id", channelID, "err", err)
-			}
+	plan := planAIReconcile(dbEnabled, memoryTasks, a.remoteAITasks(ctx, memoryTasks))
+
+	for _, channelID := range plan.Adopt {
+		a.log.Info("sync: adopt running AI task", "channel_id", channelID)
+		a.aiTasks.Store(channelID, struct{}{})
+		if _, err := a.fpsBudget.reserve(channelID, dbEnabledSet[channelID].Ext.GetDetectFPS()); err != nil {
+			a.log.Warn("sync: adopted AI task exceeds fps budget", "channel_id", channelID, "err", err)
+		}
+	}
+	for _, channelID := range plan.Forget {
+		a.log.Info("sync: forget stale AI task", "channel_id", channelID)
+		a.aiTasks.Delete(channelID)
+		a.fpsBudget.release(channelID)
+	}
+	for _, channelID := range plan.Start {
+		a.log.Info("sync: starting AI task", "channel_id", channelID)
+		if err := a.startAITask(ctx, smsCore, dbEnabledSet[channelID]); err != nil {
+			a.log.ErrorContext(ctx, "sync: start AI task failed", "channel_id", channelID, "err", err)
 		}
 	}
+	for _, channelID := range plan.Stop {
+		a.log.Info("sync: stopping AI task", "channel_id", channelID)
+		if err := a.stopAITask(ctx, channelID); err != nil {
+			a.log.ErrorContext(ctx, "sync: stop AI task failed", "channel_id", channelID, "err", err)
+		}
+	}
+}
+
+// remoteAITasks 获取 AI 服务实际运行中的检测任务
+// 运动预过滤中的通道视为运行中；AI 服务不可达时以内存为准，退化为两方对账
+func (a *AIWebhookAPI) remoteAITasks(ctx context.Context, memoryTasks map[string]struct{}) map[string]struct{} {
+	resp, err := a.ai.GetStatus(ctx, &protos.StatusRequest{})
+	if err != nil {
+		a.log.WarnContext(ctx, "sync: get AI status failed, fallback to memory", "err", err)
+		return memoryTasks
+	}
 
-	// 需要停止的任务：内存中有但数据库中 disabled
-	for channelID := range memoryTasks {
-		if _, exists := dbEnabledSet[channelID]; !exists {
-			a.log.Info("sync: stopping AI task", "channel_id", channelID)
-			if err := a.stopAITask(ctx, channelID); err != nil {
-				a.log.ErrorContext(ctx, "sync: stop AI task failed", "channel_id", channelID, "err", err)
-			}
+	remote := make(map[string]struct{}, len(resp.GetCameras()))
+	for _, cam := range resp.GetCameras() {
+		if cam.GetStatus() == "running" {
+			remote[cam.GetCameraId()] = struct{}{}
 		}
 	}
+	a.motionGates.Range(func(key string, _ *motionGate) bool {
+		remote[key] = struct{}{}
+		return true
+	})
+	return remote
+}
+
+// aiReconcilePlan AI 任务对账结果
+type aiReconcilePlan struct {
+	Start  []string // 需要启动检测
+	Stop   []string // 需要停止检测
+	Adopt  []string // AI 服务已在运行，仅补充到内存
+	Forget []string // 内存中残留，仅从内存移除
+}
+
+// planAIReconcile 根据数据库(D)、内存(M)、AI 服务(R) 三方状态计算收敛动作
+//
+//	D M R  动作
+//	1 1 1  无
+//	1 1 0  Start（AI 服务重启丢失任务）
+//	1 0 1  Adopt（owl 重启丢失内存）
+//	1 0 0  Start
+//	0 1 1  Stop
+//	0 1 0  Forget
+//	0 0 1  Stop（AI 服务残留任务）
+//	0 0 0  无
+func planAIReconcile(db, memory, remote map[string]struct{}) aiReconcilePlan {
+	var plan aiReconcilePlan
+	all := make(map[string]struct{}, len(db)+len(memory)+len(remote))
+	for _, set := range []map[string]struct{}{db, memory, remote} {
+		for id := range set {
+			all[id] = struct{}{}
+		}
+	}
+
+	for id := range all {
+		_, d := db[id]
+		_, m := memory[id]
+		_, r := remote[id]
+		switch {
+		case d && !r:
+			plan.Start = append(plan.Start, id)
+		case d && !m:
+			plan.Adopt = append(plan.Adopt, id)
+		case !d && r:
+			plan.Stop = append(plan.Stop, id)
+		case !d && m:
+			plan.Forget = append(plan.Forget, id)
+		}
+	}
+
+	for _, ids := range [][]string{plan.Start, plan.Stop, plan.Adopt, plan.Forget} {
+		slices.Sort(ids)
+	}
+	return plan
 }
 
 // startAITask 启动单个通道的 AI 检测任务（内部使用，自动构建 RTSP URL）
@@ -254,13 +396,49 @@ func (a *AIWebhookAPI) StartAIDetection(ctx context.Context, ch *ipc.Channel, rt
 		return nil, fmt.Errorf("AI service not initialized")
 	}
 
+	// 运动预过滤暂停检测时仍保留预算，有运动时可以立即恢复
+	want := ch.Ext.GetDetectFPS()
+	fps, err := a.fpsBudget.reserve(ch.ID, want)
+	if err != nil {
+		return nil, err
+	}
+	if fps < want {
+		a.log.WarnContext(ctx, "AI detect fps scaled down by budget", "channel_id", ch.ID, "want", want, "fps", fps)
+	}
+
+	// 启用运动预过滤时，先由帧差检测把关，有运动才启动 AI 检测
+	if ch.Ext.MotionSensitivity > 0 {
+		if err := a.startMotionGate(ch, rtspURL); err != nil {
+			a.fpsBudget.release(ch.ID)
+			return nil, err
+		}
+		a.aiTasks.Store(ch.ID, struct{}{})
+		return &protos.StartCameraResponse{Success: true, Message: "motion pre-filter started"}, nil
+	}
+
+	resp, err := a.startCamera(ctx, ch, rtspURL)
+	if err != nil {
+		a.fpsBudget.release(ch.ID)
+		return nil, err
+	}
+
+	a.aiTasks.Store(ch.ID, struct{}{})
+	return resp, nil
+}
+
+// startCamera 调用 AI 服务开始检测，帧率取预算分配值
+func (a *AIWebhookAPI) startCamera(ctx context.Context, ch *ipc.Channel, rtspURL string) (*protos.StartCameraResponse, error) {
 	roiPoints, labels := a.extractZoneConfig(ch)
+	fps, ok := a.fpsBudget.fps(ch.ID)
+	if !ok {
+		fps = ch.Ext.GetDetectFPS()
+	}
 
-	resp, err := a.ai.StartCamera(ctx, &protos.StartCameraRequest{
+	return a.ai.StartCamera(ctx, &protos.StartCameraRequest{
 		CameraId:       ch.ID,
 		CameraName:     ch.Name,
 		RtspUrl:        rtspURL,
-		DetectFps:      1,
+		DetectFps:      int32(fps),
 		Labels:         labels,
 		Threshold:      0.75,
 		RoiPoints:      roiPoints,
@@ -268,12 +446,6 @@ func (a *AIWebhookAPI) StartAIDetection(ctx context.Context, ch *ipc.Channel, rt
 		CallbackUrl:    fmt.Sprintf("http://127.0.0.1:%d/ai", a.conf.Server.HTTP.Port),
 		CallbackSecret: "Basic 1234567890",
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	a.aiTasks.Store(ch.ID, struct{}{})
-	return resp, nil
 }
 
 // StopAIDetection 停止 AI 检测任务，供外部调用（如 ipc.go 中的 disableAI）
@@ -281,11 +453,13 @@ func (a *AIWebhookAPI) StopAIDetection(ctx context.Context, channelID string) er
 	if a.ai == nil {
 		return nil
 	}
+	a.stopMotionGate(channelID)
 	_, err := a.ai.StopCamera(ctx, &protos.StopCameraRequest{
 		CameraId: channelID,
 	})
 	// 无论是否成功都从内存中删除，避免重复尝试停止已不存在的任务
 	a.aiTasks.Delete(channelID)
+	a.fpsBudget.release(channelID)
 	return err
 }
 
@@ -296,27 +470,54 @@ func (a *AIWebhookAPI) extractZoneConfig(ch *ipc.Channel) (roiPoints []float32,
 		roiPoints = zone.Coordinates
 		labels = zone.Labels
 	}
-	if len(labels) == 0 {
-		labels = []string{"person", "car", "cat", "dog"}
-	}
+	labels = resolveAILabels(labels, a.conf.Server.AI.DefaultLabels)
 	return
 }
 
-// saveEventSnapshot 将 Base64 编码的快照保存到 configs/events/{cid}/ 目录
-// 返回相对路径: cid/年月日时分秒_随机6位.jpg
-func saveEventSnapshot(cid string, t orm.Time, snapshotB64 string) (string, error) {
-	eventsDir := filepath.Join(system.Getwd(), "configs", "events")
+// snapshotPath 按配置返回事件快照的存放位置
+func snapshotPath(bc *conf.Bootstrap) event.SnapshotPath {
+	if bc.Server.Snapshot.WithRecordings {
+		return event.RecordingSnapshotPath(bc.Server.Recording.StorageDir)
+	}
+	return event.DefaultSnapshotPath()
+}
+
+// snapshotVars 生成快照目录模板变量，通道不存在时以 cid 作为 stream
+func snapshotVars(cid string, ch *ipc.Channel, t time.Time) event.SnapshotVars {
+	v := event.SnapshotVars{CID: cid, Stream: cid, Time: t}
+	if ch != nil {
+		v.App, v.Stream = ch.GetApp(), ch.GetStream()
+	}
+	return v
+}
 
+// saveEventSnapshot 将 Base64 编码的快照校验、按配置转码后保存到 p 按模板生成的目录
+// 返回相对根目录的路径，默认为 cid/年月日时分秒_随机6位.jpg，扩展名随实际格式变化，无法解码的数据不落盘
+func saveEventSnapshot(p event.SnapshotPath, vars event.SnapshotVars, snapshotB64 string, format conf.ServerSnapshot) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(snapshotB64)
 	if err != nil {
 		return "", fmt.Errorf("decode base64: %w", err)
 	}
+	ext, err := validateSnapshot(data)
+	if err != nil {
+		return "", err
+	}
+	if format.Format != "" {
+		if data, err = normalizeSnapshot(data, format); err != nil {
+			return "", fmt.Errorf("normalize snapshot: %w", err)
+		}
+		ext = snapshotExt(data)
+	}
 
+	// 命名需与 event.SnapshotPath.LookupSnapshot 的校验一致，否则无法通过图片接口访问
 	randomSuffix := fmt.Sprintf("%06d", rand.IntN(1000000))
-	filename := fmt.Sprintf("%s_%s.jpg", t.Format("20060102150405"), randomSuffix)
+	filename := fmt.Sprintf("%s_%s%s", vars.Time.Format("20060102150405"), randomSuffix, ext)
 
-	relativePath := filepath.Join(cid, filename)
-	fullPath := filepath.Join(eventsDir, relativePath)
+	relativePath := filepath.Join(p.Dir(vars), filename)
+	fullPath, err := p.Resolve(relativePath)
+	if err != nil {
+		return "", err
+	}
 
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
 		return "", fmt.Errorf("create events dir: %w", err)
@@ -69,8 +69,44 @@ type AIGlobalStats struct {
 type AIWebhookOutput struct {
 	Code int    `json:"code"` // 错误代码，0 表示成功
 	Msg  string `json:"msg"`  // 消息
+
+	// 仅检测事件回调返回，为空表示无需减速
+	Backpressure *AIBackpressure `json:"backpressure,omitempty"` // 背压建议
+}
+
+// AIBackpressure 事件入库积压时对 AI 服务的减速建议
+type AIBackpressure struct {
+	SlowDown     bool  `json:"slow_down"`      // 是否需要减速
+	RetryAfterMs int64 `json:"retry_after_ms"` // 建议暂停推送检测事件的毫秒数
+	Pending      int64 `json:"pending"`        // 待入库的事件数
 }
 
 func newAIWebhookOutputOK() AIWebhookOutput {
 	return AIWebhookOutput{Code: 0, Msg: "success"}
 }
+
+// AITaskItem AI 检测任务
+type AITaskItem struct {
+	ChannelID       string    `json:"channel_id"`        // 通道 ID
+	ChannelName     string    `json:"channel_name"`      // 通道名称
+	MotionGated     bool      `json:"motion_gated"`      // 是否处于运动预过滤
+	LastDetectionAt *orm.Time `json:"last_detection_at"` // 最近一次检测事件时间
+	DetectFPS       int       `json:"detect_fps"`        // 实际分配的检测帧率
+}
+
+// AITaskDetail AI 检测任务详情，包含 AI 服务侧的运行状态
+type AITaskDetail struct {
+	AITaskItem
+	Status          string `json:"status"`           // AI 服务状态 running/error/stopped，未知时为空
+	FramesProcessed int64  `json:"frames_processed"` // 已处理帧数
+	LastError       string `json:"last_error"`       // 最后一次错误信息
+	RetryCount      int32  `json:"retry_count"`      // 当前重试次数
+}
+
+// AIFPSBudgetOutput AI 检测帧率预算使用情况
+type AIFPSBudgetOutput struct {
+	MaxTotalFPS int `json:"max_total_fps"` // 帧率之和上限，0 表示不限制
+	UsedFPS     int `json:"used_fps"`      // 已占用帧率
+	RemainFPS   int `json:"remain_fps"`    // 剩余帧率，不限制时为 0
+	Channels    int `json:"channels"`      // 占用预算的通道数
+}
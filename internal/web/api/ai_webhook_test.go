@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/conc"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestPlanAIReconcile(t *testing.T) {
+	set := func(ids ...string) map[string]struct{} {
+		out := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			out[id] = struct{}{}
+		}
+		return out
+	}
+
+	// 通道命名为 D/M/R 三方状态，如 c101 表示数据库启用、内存无、AI 服务运行中
+	db := set("c111", "c110", "c101", "c100")
+	memory := set("c111", "c110", "c011", "c010")
+	remote := set("c111", "c101", "c011", "c001")
+
+	got := planAIReconcile(db, memory, remote)
+	expect := aiReconcilePlan{
+		Start:  []string{"c100", "c110"},
+		Stop:   []string{"c001", "c011"},
+		Adopt:  []string{"c101"},
+		Forget: []string{"c010"},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, got)
+	}
+
+	if got := planAIReconcile(set(), set(), set()); !reflect.DeepEqual(got, aiReconcilePlan{}) {
+		t.Fatalf("expect empty plan, got %+v", got)
+	}
+}
+
+func TestBuildAITaskList(t *testing.T) {
+	tasks := conc.NewMap[string, struct{}]()
+	tasks.Store("c2", struct{}{})
+	tasks.Store("c1", struct{}{})
+	tasks.Store("c3", struct{}{})
+	gates := conc.NewMap[string, *motionGate]()
+	gates.Store("c2", &motionGate{})
+	lastDetections := conc.NewMap[string, orm.Time]()
+	detectedAt := orm.Now()
+	lastDetections.Store("c1", detectedAt)
+
+	getChannel := func(_ context.Context, id string) (*ipc.Channel, error) {
+		if id == "c3" {
+			return nil, errors.New("not found")
+		}
+		return &ipc.Channel{ID: id, Name: "name-" + id}, nil
+	}
+
+	items := buildAITaskList(context.Background(), tasks, gates, lastDetections, getChannel)
+	if len(items) != 3 {
+		t.Fatalf("expect 3 items, got %d", len(items))
+	}
+	for i, id := range []string{"c1", "c2", "c3"} {
+		if items[i].ChannelID != id {
+			t.Fatalf("items[%d] expect %s, got %s", i, id, items[i].ChannelID)
+		}
+	}
+	if items[0].ChannelName != "name-c1" || items[0].LastDetectionAt == nil || !items[0].LastDetectionAt.Equal(detectedAt.Time) {
+		t.Fatalf("unexpected c1 item %+v", items[0])
+	}
+	if !items[1].MotionGated || items[1].LastDetectionAt != nil {
+		t.Fatalf("unexpected c2 item %+v", items[1])
+	}
+	if items[2].ChannelName != "" || items[2].MotionGated {
+		t.Fatalf("unexpected c3 item %+v", items[2])
+	}
+}
+
+func TestRunAISyncLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 4)
+	trigger := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		runAISyncLoop(ctx, time.Hour, trigger, func(context.Context) { calls <- struct{}{} })
+		close(done)
+	}()
+
+	wait := func(msg string) {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatal(msg)
+		}
+	}
+	// 启动即对账，不等待同步周期
+	wait("expect immediate reconcile on startup")
+	trigger <- struct{}{}
+	wait("expect reconcile on trigger")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect loop stopped")
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expect no extra reconcile, got %d", len(calls))
+	}
+}
@@ -18,7 +18,9 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/recording"
 	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/pkg/gbs"
 	"github.com/gowvp/owl/pkg/ota"
 	"github.com/gowvp/owl/plugin/stat"
 	"github.com/gowvp/owl/plugin/stat/statapi"
@@ -47,6 +49,7 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 			c.AbortWithStatus(http.StatusInternalServerError)
 		}),
 		web.Metrics(),
+		latencyMetrics(apiLatency),
 		web.Logger(web.IgnorePrefix(staticPrefix),
 			web.IgnoreMethod(http.MethodOptions),
 			web.IgnorePrefix("/events/image"),
@@ -63,6 +66,11 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 	)
 	go web.CountGoroutines(10*time.Minute, 20)
 
+	// 上传接口使用单独的上限，流媒体代理为长连接数据流，均不受普通接口上限约束
+	r.Use(limitBody(bodyLimit(uc.Conf.Server.HTTP),
+		web.IgnorePrefix("/proxy/sms", "/app/upgrade/upload"),
+	))
+
 	r.Use(cors.New(cors.Config{
 		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
 		AllowHeaders: []string{
@@ -100,14 +108,19 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 
 	auth := web.AuthMiddleware(uc.Conf.Server.HTTP.JwtSecret)
 	r.GET("/health", web.WrapH(uc.getHealth))
+	r.GET("/app/features", web.WrapH(uc.getFeatures))
 	r.GET("/app/metrics/api", web.WrapH(uc.getMetricsAPI))
+	r.GET("/metrics", uc.getMetricsPrometheus)
 	r.GET("/app/version/check", web.WrapH(uc.checkVersion))
 	r.POST("/app/upgrade", auth, uc.upgradeApp)
+	r.GET("/app/upgrade/status", auth, web.WrapH(uc.getUpgradeStatus))
+	r.POST("/app/upgrade/upload", auth, uc.uploadUpgrade)
 
 	versionapi.Register(r, uc.Version, auth)
 	statapi.Register(r)
 	registerZLMWebhookAPI(r, uc.WebHookAPI)
 	registerGB28181(r, uc.GB28181API, auth)
+	RegisterPTZ(r, uc.PTZAPI, auth)
 	uc.ConfigAPI.uc = uc
 	registerConfig(r, uc.ConfigAPI, auth)
 	registerSms(r, uc.SMSAPI, auth)
@@ -118,8 +131,13 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 
 	// 注册 AI 分析服务回调接口
 	registerAIWebhookAPI(r, uc.AIWebhookAPI)
+	registerAITaskAPI(r, uc.AIWebhookAPI, auth)
 	// 启动 AI 任务同步协程，每 5 分钟检测一次数据库与内存状态差异
 	uc.AIWebhookAPI.StartAISyncLoop(context.Background(), uc.SMSAPI.smsCore)
+	// 启动画面篡改定时巡检，未配置间隔时不启动
+	uc.GB28181API.StartTamperLoop(context.Background())
+	// 启动通道封面刷新，设备墙直接读取缓存
+	uc.GB28181API.StartPosterLoop(context.Background())
 	// TODO: 待补充中间件
 	RegisterEvent(r, uc.EventAPI)
 	// TODO: 待补充中间件
@@ -137,15 +155,29 @@ type getHealthOutput struct {
 	StartAt   time.Time `json:"start_at"`
 	GitBranch string    `json:"git_branch"`
 	GitHash   string    `json:"git_hash"`
+	// MediaDegraded 流媒体 secret 缺失等原因导致流媒体不可用
+	MediaDegraded bool `json:"media_degraded"`
+	// SIP 当前生效的 SIP 联系地址，warnings 非空时设备可能能注册但收不到点播
+	SIP *gbs.ContactInfo `json:"sip,omitempty"`
+	// StorageAlerts 未恢复的录像存储告警，如磁盘已满、写录像失败
+	StorageAlerts []recording.StorageAlert `json:"storage_alerts,omitempty"`
 }
 
 func (uc *Usecase) getHealth(_ *gin.Context, _ *struct{}) (getHealthOutput, error) {
-	return getHealthOutput{
+	out := getHealthOutput{
 		Version:   uc.Conf.BuildVersion,
 		GitBranch: strings.Trim(expvar.Get("git_branch").String(), `"`),
 		GitHash:   strings.Trim(expvar.Get("git_hash").String(), `"`),
 		StartAt:   startRuntime,
-	}, nil
+
+		MediaDegraded: uc.SMSAPI.smsCore.IsDegraded(),
+		StorageAlerts: uc.RecordingAPI.recordingCore.StorageAlerts(),
+	}
+	if uc.SipServer != nil {
+		contact := uc.SipServer.Contact()
+		out.SIP = &contact
+	}
+	return out, nil
 }
 
 type getMetricsAPIOutput struct {
@@ -158,6 +190,8 @@ type getMetricsAPIOutput struct {
 	NumGC            uint32 `json:"num_gc"`             // gc 次数
 	SysAlloc         uint64 `json:"sys_alloc"`          // 内存占用
 	StartAt          string `json:"start_at"`           // 运行时间
+
+	LatencyTop10 []RouteLatency `json:"latency_top10"` // p99 耗时最高的路由TOP10
 }
 
 func (uc *Usecase) getMetricsAPI(_ *gin.Context, _ *struct{}) (*getMetricsAPIOutput, error) {
@@ -183,6 +217,7 @@ func (uc *Usecase) getMetricsAPI(_ *gin.Context, _ *struct{}) (*getMetricsAPIOut
 		NumGC:            stats.NumGC,
 		SysAlloc:         stats.Sys,
 		StartAt:          startRuntime.Format(time.DateTime),
+		LatencyTop10:     apiLatency.latencyTop(10),
 	}, nil
 }
 
@@ -211,7 +246,18 @@ func sortExpvarMap(data *expvar.Map, top int) []KV {
 	return kvs[:idx]
 }
 
-const repoName = "gowvp/owl"
+// defaultRepoName 未配置仓库时使用的官方仓库
+const defaultRepoName = "gowvp/owl"
+
+// newOTA 按配置的仓库与镜像创建 OTA 实例
+func (uc *Usecase) newOTA(filename string) *ota.OTA {
+	cfg := uc.Conf.Server.OTA
+	repo := cfg.Repo
+	if repo == "" {
+		repo = defaultRepoName
+	}
+	return ota.NewOTA(repo, filename, ota.WithAPIURL(cfg.APIURL), ota.WithDownloadMirror(cfg.DownloadURL))
+}
 
 type checkVersionOutput struct {
 	HasNewVersion  bool   `json:"has_new_version"`
@@ -224,7 +270,7 @@ type checkVersionOutput struct {
 // 通过 GitHub API 获取最新 release 信息，与当前版本比较
 func (uc *Usecase) checkVersion(_ *gin.Context, _ *struct{}) (checkVersionOutput, error) {
 	currentVersion := uc.Conf.BuildVersion
-	newVersion, body, err := ota.GetLastVersion(repoName)
+	newVersion, body, err := uc.newOTA("").GetLastVersion()
 	if err != nil {
 		return checkVersionOutput{}, err
 	}
@@ -273,17 +319,22 @@ func compareVersion(v1, v2 string) int {
 
 // upgradeApp 执行应用升级
 // 通过 SSE 返回下载进度，下载完成后由回调决定如何升级
+// 同一时间只允许一个升级任务，重复调用返回 409
 func (uc *Usecase) upgradeApp(c *gin.Context) {
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"msg": "不支持 SSE"})
 		return
 	}
+	if !uc.upgrade.tryStart() {
+		c.JSON(http.StatusConflict, gin.H{"msg": "升级正在进行中"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
 
 	sendEvent := func(event, data string) {
 		fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data)
@@ -297,16 +348,15 @@ func (uc *Usecase) upgradeApp(c *gin.Context) {
 		filename = "linux_arm64"
 	}
 
-	o := ota.NewOTA(repoName, filename)
+	o := uc.newOTA(filename)
 	o.SetProgressCallback(func(current, total int64) {
-		percent := 0
-		if total > 0 {
-			percent = int(current * 100 / total)
-		}
+		percent := uc.upgrade.progress(current, total)
 		sendEvent("progress", fmt.Sprintf(`{"current":%d,"total":%d,"percent":%d}`, current, total, percent))
 	})
 
-	if err := o.Download().Error(); err != nil {
+	err := o.Download().Error()
+	uc.upgrade.finish(err)
+	if err != nil {
 		sendEvent("error", fmt.Sprintf(`{"msg":"%s"}`, err.Error()))
 		return
 	}
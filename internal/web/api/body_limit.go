@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+const (
+	defaultMaxBodyMB   = 32
+	defaultMaxUploadMB = 512
+)
+
+// bodyLimit 普通接口请求体上限，旧配置文件未设置时使用默认值
+func bodyLimit(cfg conf.ServerHTTP) int64 {
+	if cfg.MaxBodyMB <= 0 {
+		return defaultMaxBodyMB << 20
+	}
+	return int64(cfg.MaxBodyMB) << 20
+}
+
+// uploadLimit 上传接口请求体上限
+func uploadLimit(cfg conf.ServerHTTP) int64 {
+	if cfg.MaxUploadMB <= 0 {
+		return defaultMaxUploadMB << 20
+	}
+	return int64(cfg.MaxUploadMB) << 20
+}
+
+// errBodyTooLarge 请求体超出上限
+var errBodyTooLarge = reason.ErrContentTooLarge.SetHTTPStatus(http.StatusRequestEntityTooLarge)
+
+// limitBody 限制请求体大小，超出返回 413
+// web.LimitContentLength 只检查 Content-Length，分块传输的请求可绕过，
+// 此处对未声明长度的请求最多预读 limit 字节，超出同样拒绝
+func limitBody(limit int64, ignoreFn ...web.IngoreOption) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, fn := range ignoreFn {
+			if fn(c) {
+				c.Next()
+				return
+			}
+		}
+		if c.Request.ContentLength > limit {
+			web.AbortWithStatusJSON(c, errBodyTooLarge)
+			return
+		}
+		if c.Request.ContentLength < 0 && c.Request.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			if err != nil {
+				web.AbortWithStatusJSON(c, reason.ErrBadRequest.SetMsg(err.Error()))
+				return
+			}
+			if int64(len(body)) > limit {
+				web.AbortWithStatusJSON(c, errBodyTooLarge)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+func TestLimitBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(limitBody(16, web.IgnorePrefix("/upload")))
+	echo := func(c *gin.Context) {
+		b, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(b))
+	}
+	r.POST("/items", echo)
+	r.POST("/upload", echo)
+
+	post := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		code    int
+	}{
+		{name: "normal", path: "/items", body: "hello", code: http.StatusOK},
+		{name: "at limit", path: "/items", body: strings.Repeat("a", 16), code: http.StatusOK},
+		{name: "oversized", path: "/items", body: strings.Repeat("a", 17), code: http.StatusRequestEntityTooLarge},
+		{name: "chunked normal", path: "/items", body: "hello", chunked: true, code: http.StatusOK},
+		{name: "chunked oversized", path: "/items", body: strings.Repeat("a", 17), chunked: true, code: http.StatusRequestEntityTooLarge},
+		{name: "exempt route", path: "/upload", body: strings.Repeat("a", 64), code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.path, tt.body, tt.chunked)
+			if w.Code != tt.code {
+				t.Fatalf("expect %d, got %d %s", tt.code, w.Code, w.Body)
+			}
+			if tt.code == http.StatusOK && w.Body.String() != tt.body {
+				t.Fatalf("body should be passed through, got %q", w.Body)
+			}
+		})
+	}
+}
@@ -38,6 +38,8 @@ func registerConfig(g gin.IRouter, api ConfigAPI, handler ...gin.HandlerFunc) {
 		group.GET("/info", web.WrapH(api.getConfigInfo))
 		group.PUT("/info/sip", web.WrapH(api.editSIP))
 	}
+	g.PUT("/config/media/secret", append(handler, web.WrapH(api.editMediaSecret))...)
+	g.PUT("/config/sip/log", append(handler, web.WrapH(api.editSIPLog))...)
 }
 
 // >>> config >>>>>>>>>>>>>>>>>>>>
@@ -88,3 +90,29 @@ func (a ConfigAPI) editSIP(_ *gin.Context, in *conf.SIP) (gin.H, error) {
 
 	return gin.H{"msg": "ok"}, nil
 }
+
+type editMediaSecretInput struct {
+	Secret string `json:"secret" binding:"required"`
+}
+
+// editMediaSecret 运行时设置流媒体 secret，验证通过后重连并回写配置文件
+func (a ConfigAPI) editMediaSecret(c *gin.Context, in *editMediaSecretInput) (gin.H, error) {
+	if _, err := a.uc.SMSAPI.smsCore.SetSecret(c.Request.Context(), a.conf, a.conf.Server.HTTP.Port, in.Secret); err != nil {
+		return nil, err
+	}
+	if err := conf.WriteConfig(a.conf, a.conf.ConfigPath); err != nil {
+		return nil, reason.ErrServer.SetMsg(err.Error())
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+type editSIPLogInput struct {
+	Enabled  bool   `json:"enabled"`   // 是否记录 SIP 报文
+	DeviceID string `json:"device_id"` // 仅记录该国标设备的报文，空串表示全部设备
+}
+
+// editSIPLog 运行时开关 SIP 报文日志，便于排查单个设备而无需重启
+func (a ConfigAPI) editSIPLog(_ *gin.Context, in *editSIPLogInput) (gin.H, error) {
+	a.uc.SipServer.SetMessageLog(in.Enabled, in.DeviceID)
+	return gin.H{"enabled": in.Enabled, "device_id": in.DeviceID}, nil
+}
@@ -2,9 +2,11 @@
 package api
 
 import (
-	"os"
+	"context"
+	"log/slog"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gowvp/owl/internal/conf"
@@ -12,7 +14,6 @@ import (
 	"github.com/gowvp/owl/internal/core/event/store/eventdb"
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/reason"
-	"github.com/ixugo/goddd/pkg/system"
 	"github.com/ixugo/goddd/pkg/web"
 	"gorm.io/gorm"
 )
@@ -23,19 +24,30 @@ type EventAPI struct {
 	conf      *conf.Bootstrap
 }
 
-func NewEventCore(db *gorm.DB, conf *conf.Bootstrap) event.Core {
+func NewEventCore(db *gorm.DB, conf *conf.Bootstrap) (event.Core, func()) {
 	var store event.Storer
 	store = eventdb.NewDB(db).AutoMigrate(orm.GetEnabledAutoMigrate())
-	core := event.NewCore(store)
+	core := event.NewCore(store, event.WithSnapshotPath(snapshotPath(conf)))
 
 	// 启动定时清理协程
 	days := conf.Server.AI.RetainDays
 	if days <= 0 {
 		days = 7
 	}
-	go core.StartCleanupWorker(days)
-
-	return core
+	hour, minute, err := event.ParseCleanupClock(conf.Server.AI.CleanupTime)
+	if err != nil {
+		slog.Warn("事件清理时间配置无效，使用默认值", "err", err, "default", event.DefaultCleanupClock)
+		hour, minute, _ = event.ParseCleanupClock("")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go core.StartCleanupWorker(ctx, event.CleanupPolicy{
+		RetainDays:    days,
+		MaxPerChannel: max(conf.Server.AI.MaxEvents, 0),
+		Hour:          hour,
+		Minute:        minute,
+	})
+
+	return core, cancel
 }
 
 func NewEventAPI(core event.Core, conf *conf.Bootstrap) EventAPI {
@@ -46,10 +58,12 @@ func RegisterEvent(g gin.IRouter, api EventAPI, handler ...gin.HandlerFunc) {
 	{
 		group := g.Group("/events", handler...)
 		group.GET("", web.WrapH(api.findEvents))
+		group.GET("/export", api.exportEvents) // 导出 csv/json 报表
 		group.GET("/:id", web.WrapH(api.getEvent))
 		group.PUT("/:id", web.WrapH(api.editEvent))
 		group.DELETE("/:id", web.WrapH(api.delEvent))
 	}
+	g.Group("/channels", handler...).GET("/:id/snapshots/recent", web.WrapH(api.findRecentSnapshots)) // 通道最近事件缩略图
 	// 图片接口不需要认证中间件
 	g.GET("/events/image/*path", api.getEventImage)
 }
@@ -60,6 +74,15 @@ func (a EventAPI) findEvents(c *gin.Context, in *event.FindEventInput) (any, err
 	return gin.H{"items": items, "total": total}, err
 }
 
+// findRecentSnapshots 通道最近的事件缩略图，用于展示近期动态
+func (a EventAPI) findRecentSnapshots(c *gin.Context, in *event.FindRecentSnapshotsInput) (gin.H, error) {
+	items, err := a.eventCore.FindRecentSnapshots(c.Request.Context(), c.Param("id"), in.Limit)
+	for _, item := range items {
+		item.URL = "/events/image/" + item.ImagePath
+	}
+	return gin.H{"items": items}, err
+}
+
 // getEvent 获取单个事件详情
 func (a EventAPI) getEvent(c *gin.Context, _ *struct{}) (*event.Event, error) {
 	eventID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -91,21 +114,21 @@ func (a EventAPI) getEventImage(c *gin.Context) {
 		imagePath = imagePath[1:]
 	}
 
-	fullPath := filepath.Join(system.Getwd(), "configs", "events", imagePath)
-
-	// 安全检查：防止路径遍历攻击
-	eventsDir := filepath.Join(system.Getwd(), "configs", "events")
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil || !filepath.HasPrefix(absPath, eventsDir) {
-		web.Fail(c, reason.ErrNotFound.SetMsg("invalid path"))
+	if _, ok := eventImageExts[strings.ToLower(filepath.Ext(imagePath))]; !ok {
+		web.Fail(c, reason.ErrNotFound.SetMsg("unsupported image type"))
 		return
 	}
 
-	body, err := os.ReadFile(fullPath)
+	// 图片接口无需认证，只提供符合快照目录与命名的文件，防止路径遍历或读取共用目录下的其它文件
+	fullPath, err := snapshotPath(a.conf).LookupSnapshot(imagePath)
 	if err != nil {
 		web.Fail(c, reason.ErrNotFound.SetMsg(err.Error()))
 		return
 	}
 
-	c.Data(200, "image/jpeg", body)
+	// c.File 按扩展名设置 Content-Type 并支持 Range/缓存协商
+	c.File(fullPath)
 }
+
+// eventImageExts 事件快照允许访问的图片扩展名，其它文件即使在快照目录下也不对外提供
+var eventImageExts = map[string]struct{}{".jpg": {}, ".jpeg": {}, ".png": {}, ".webp": {}}
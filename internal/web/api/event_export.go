@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// eventCSVHeader 导出 CSV 的列
+var eventCSVHeader = []string{"id", "started_at", "ended_at", "did", "cid", "label", "score", "zones"}
+
+// eventExporter 将事件逐批写出，close 负责补齐格式结尾
+type eventExporter interface {
+	write(items []*event.Event) error
+	close() error
+}
+
+func newEventExporter(w io.Writer, format string) eventExporter {
+	if format == event.ExportFormatJSON {
+		return &jsonEventExporter{w: w, enc: json.NewEncoder(w)}
+	}
+	return &csvEventExporter{w: csv.NewWriter(w)}
+}
+
+type csvEventExporter struct {
+	w          *csv.Writer
+	headerDone bool
+}
+
+func (e *csvEventExporter) writeHeader() error {
+	if e.headerDone {
+		return nil
+	}
+	e.headerDone = true
+	return e.w.Write(eventCSVHeader)
+}
+
+func (e *csvEventExporter) write(items []*event.Event) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for _, v := range items {
+		if err := e.w.Write([]string{
+			strconv.FormatInt(v.ID, 10),
+			v.StartedAt.Format(time.DateTime),
+			v.EndedAt.Format(time.DateTime),
+			v.DID,
+			v.CID,
+			v.Label,
+			strconv.FormatFloat(float64(v.Score), 'f', -1, 32),
+			v.Zones,
+		}); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEventExporter) close() error {
+	// 无数据时也输出表头，便于报表程序识别列
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonEventExporter 输出 JSON 数组，逐条编码避免整体序列化
+type jsonEventExporter struct {
+	w   io.Writer
+	enc *json.Encoder
+	n   int
+}
+
+func (e *jsonEventExporter) write(items []*event.Event) error {
+	for _, v := range items {
+		sep := ","
+		if e.n == 0 {
+			sep = "["
+		}
+		if _, err := io.WriteString(e.w, sep); err != nil {
+			return err
+		}
+		if err := e.enc.Encode(v); err != nil {
+			return err
+		}
+		e.n++
+	}
+	return nil
+}
+
+func (e *jsonEventExporter) close() error {
+	end := "]"
+	if e.n == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// exportEvents 按列表筛选条件导出事件，逐批写出响应，不在内存中汇总
+//
+//	GET /events/export?from=&to=&cid=&format=csv
+func (a EventAPI) exportEvents(c *gin.Context) {
+	var in event.ExportEventInput
+	if err := c.ShouldBindQuery(&in); err != nil {
+		web.Fail(c, reason.ErrBadRequest.SetMsg(err.Error()))
+		return
+	}
+	if err := in.Normalize(); err != nil {
+		web.Fail(c, err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if in.Format == event.ExportFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := "events_" + time.Now().Format("20060102150405") + "." + in.Format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	exporter := newEventExporter(c.Writer, in.Format)
+	err := a.eventCore.ExportEvents(c.Request.Context(), &in.FindEventInput, func(items []*event.Event) error {
+		if err := exporter.write(items); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		err = exporter.close()
+	}
+	// 响应头已发出，只能记录日志，客户端会收到不完整的文件
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "导出事件中断", "err", err)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestEventExporterCSV(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 0, time.Local)
+	var buf bytes.Buffer
+	exp := newEventExporter(&buf, event.ExportFormatCSV)
+	if err := exp.write([]*event.Event{
+		{ID: 1, DID: "d1", CID: "c1", Label: "person", Score: 0.85, Zones: `[{"x":1,"y":2}]`, StartedAt: orm.Time{Time: at}, EndedAt: orm.Time{Time: at.Add(5 * time.Second)}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.write([]*event.Event{{ID: 2, CID: "c1", Label: "car, red", Score: 1, StartedAt: orm.Time{Time: at}, EndedAt: orm.Time{Time: at}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "id,started_at,ended_at,did,cid,label,score,zones\n" +
+		`1,2025-03-01 08:30:00,2025-03-01 08:30:05,d1,c1,person,0.85,"[{""x"":1,""y"":2}]"` + "\n" +
+		`2,2025-03-01 08:30:00,2025-03-01 08:30:00,,c1,"car, red",1,` + "\n"
+	if buf.String() != expect {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+
+	// 无数据时仍输出表头
+	buf.Reset()
+	exp = newEventExporter(&buf, event.ExportFormatCSV)
+	if err := exp.close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "id,started_at,ended_at,did,cid,label,score,zones\n" {
+		t.Fatalf("unexpected empty csv %q", buf.String())
+	}
+}
+
+func TestEventExporterJSON(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		var buf bytes.Buffer
+		exp := newEventExporter(&buf, event.ExportFormatJSON)
+		for i := range n {
+			if err := exp.write([]*event.Event{{ID: int64(i + 1), Label: "person"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := exp.close(); err != nil {
+			t.Fatal(err)
+		}
+		var out []event.Event
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("invalid json %q: %v", buf.String(), err)
+		}
+		if len(out) != n {
+			t.Fatalf("expect %d items, got %d", n, len(out))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/sms"
+)
+
+// 功能标识，前端据此隐藏不可用的入口
+const (
+	featureAI           = "ai"            // AI 检测与事件
+	featureRecording    = "recording"     // 录像与回放
+	featurePTZ          = "ptz"           // 云台控制，具体通道是否支持以通道 ptz_type 为准
+	featureMetrics      = "metrics"       // Prometheus 指标
+	featurePProf        = "pprof"         // 性能分析
+	featureTamperPatrol = "tamper_patrol" // 画面篡改定时巡检
+	featurePoster       = "poster"        // 通道封面定时刷新
+)
+
+type getFeaturesOutput struct {
+	Version      string          `json:"version"`
+	MediaBackend string          `json:"media_backend"` // 流媒体类型 zlm/lalmax
+	Features     map[string]bool `json:"features"`
+}
+
+// getFeatures 汇总版本与按配置启用的功能
+func (uc *Usecase) getFeatures(_ *gin.Context, _ *struct{}) (*getFeaturesOutput, error) {
+	return appFeatures(uc.Conf), nil
+}
+
+// appFeatures 由配置推导功能开关，不依赖运行状态，配置修改后重新请求即可生效
+func appFeatures(bc *conf.Bootstrap) *getFeaturesOutput {
+	backend := bc.Media.Type
+	if backend == "" {
+		backend = sms.ProtocolZLMediaKit
+	}
+	return &getFeaturesOutput{
+		Version:      bc.BuildVersion,
+		MediaBackend: backend,
+		Features: map[string]bool{
+			featureAI: !bc.Server.AI.Disabled,
+			// lalmax 暂不支持录制
+			featureRecording:    !bc.Server.Recording.Disabled && backend != sms.ProtocolLalmax,
+			featurePTZ:          true,
+			featureMetrics:      true,
+			featurePProf:        bc.Server.HTTP.PProf.Enabled,
+			featureTamperPatrol: bc.Server.Tamper.Interval > 0,
+			featurePoster:       bc.Server.Poster.TTL > 0,
+		},
+	}
+}
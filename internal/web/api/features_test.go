@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+func TestGetFeatures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bc := conf.Bootstrap{BuildVersion: "v1.0.0"}
+	uc := &Usecase{Conf: &bc}
+	r := gin.New()
+	r.GET("/app/features", web.WrapH(uc.getFeatures))
+
+	get := func() getFeaturesOutput {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/features", nil))
+		var out getFeaturesOutput
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out := get()
+	if out.Version != "v1.0.0" || out.MediaBackend != "zlm" || !out.Features[featureAI] || !out.Features[featureRecording] {
+		t.Fatalf("unexpected default features %+v", out)
+	}
+
+	bc.Server.AI.Disabled = true
+	if out := get(); out.Features[featureAI] {
+		t.Fatal("ai should be disabled")
+	}
+
+	bc.Media.Type = "lalmax"
+	if out := get(); out.MediaBackend != "lalmax" || out.Features[featureRecording] {
+		t.Fatalf("recording should be unavailable on lalmax, got %+v", out)
+	}
+
+	bc.Media.Type = ""
+	bc.Server.Recording.Disabled = true
+	bc.Server.Tamper.Interval = conf.Duration(time.Minute)
+	if out := get(); out.Features[featureRecording] || !out.Features[featureTamperPatrol] {
+		t.Fatalf("unexpected features %+v", out)
+	}
+}
@@ -3,9 +3,11 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
@@ -21,6 +23,7 @@ import (
 	"github.com/gowvp/owl/internal/core/ipc"
 	"github.com/gowvp/owl/internal/core/recording"
 	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/pkg/gbs"
 	"github.com/gowvp/owl/pkg/zlm"
 	"github.com/ixugo/goddd/pkg/hook"
 	"github.com/ixugo/goddd/pkg/orm"
@@ -28,28 +31,26 @@ import (
 	"github.com/ixugo/goddd/pkg/web"
 )
 
-var ErrDevice = reason.NewError("ErrDevice", "设备错误")
+var (
+	ErrDevice = reason.NewError("ErrDevice", "设备错误")
+	// ErrMediaNotConfigured 流媒体收流地址未配置，需管理员修改配置，重试无效
+	ErrMediaNotConfigured = reason.NewError("ErrMediaNotConfigured", "请先配置流媒体 SDP 收流地址")
+)
 
 const (
 	coverDir = "cover"
 )
 
 // TODO: 快照不会删除，只会覆盖，设备删除时也不会删除快照，待实现
-func writeCover(dataDir, channelID string, body []byte) error {
-	coverPath := filepath.Join(dataDir, coverDir)
-	if err := os.MkdirAll(coverPath, 0o777); err != nil {
+func writeCover(dir, channelID string, body []byte) error {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(coverPath, channelID+".jpg"), body, 0o644)
+	return os.WriteFile(readCoverPath(dir, channelID), body, 0o644)
 }
 
-func readCoverPath(dataDir, channelID string) string {
-	coverPath := filepath.Join(dataDir, coverDir)
-	return filepath.Join(coverPath, channelID+".jpg")
-}
-
-func readCover(dataDir, channelID string) ([]byte, error) {
-	return os.ReadFile(readCoverPath(dataDir, channelID))
+func readCoverPath(dir, channelID string) string {
+	return filepath.Join(dir, channelID+".jpg")
 }
 
 type IPCAPI struct {
@@ -82,9 +83,13 @@ func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 		group.GET("/:id", web.WrapH(api.getDevice))                  // 设备详情（所有协议）
 		group.PUT("/:id", web.WrapH(api.editDevice))                 // 修改设备（所有协议）
 		group.POST("", web.WrapH(api.addDevice))                     // 添加设备（所有协议，通过 type 区分）
+		group.POST("/validate", web.WrapH(api.validateDevice))       // 校验设备连接参数，不保存
 		group.DELETE("/:id", web.WrapH(api.delDevice))               // 删除设备（所有协议）
 		group.GET("/channels", web.WrapH(api.FindChannelsForDevice)) // 设备与通道列表（所有协议）
 		group.POST("/:id/catalog", web.WrapH(api.queryCatalog))
+		group.PUT("/:id/stream-mode", web.WrapH(api.editDeviceStreamMode)) // 修改国标传输模式（GB28181）
+		group.GET("/:id/history", web.WrapH(api.findDeviceHistory))        // 设备上下线记录（所有协议）
+		group.GET("/:id/sip/trace", api.traceSIP)                          // 跟踪设备 SIP 报文，SSE 推送（GB28181）
 	}
 	{
 		// group := g.Group("/onvif", handler...)
@@ -96,17 +101,25 @@ func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 	{
 		group := g.Group("/channels", handler...)
 		group.GET("", web.WrapH(api.findChannel))                    // 通道列表（所有协议）
+		group.GET("/civil_codes", web.WrapH(api.findCivilCodes))     // 按行政区划分组（GB28181）
 		group.POST("", web.WrapH(api.addChannel))                    // 添加通道（RTMP/RTSP）
 		group.PUT("/:id", web.WrapH(api.editChannel))                // 修改通道（所有协议）
 		group.DELETE("/:id", web.WrapH(api.delChannel))              // 删除通道（RTMP/RTSP）
 		group.POST("/:id/play", web.WrapH(api.play))                 // 播放（所有协议）
+		group.GET("/:id/media-info", web.WrapH(api.getMediaInfo))    // 直播流画质信息（所有协议）
 		group.POST("/:id/snapshot", web.WrapH(api.refreshSnapshot))  // 图像抓拍（所有协议）
 		group.GET("/:id/snapshot", api.getSnapshot)                  // 获取图像（所有协议）
 		group.POST("/:id/zones", web.WrapH(api.addZone))             // 添加区域（所有协议）
 		group.GET("/:id/zones", web.WrapH(api.getZones))             // 获取区域（所有协议）
+		group.PUT("/:id/zones", web.WrapH(api.setZones))             // 替换全部区域（所有协议）
 		group.POST("/:id/ai/enable", web.WrapH(api.enableAI))        // 启用 AI 检测
 		group.POST("/:id/ai/disable", web.WrapH(api.disableAI))      // 禁用 AI 检测
 		group.POST("/:id/record_mode", web.WrapH(api.setRecordMode)) // 设置录像模式
+		group.POST("/:id/alarm/reset", web.WrapH(api.resetAlarm))    // 报警复位（GB28181）
+
+		// 画面篡改检测（遮挡/移位）
+		group.GET("/:id/tamper", web.WrapH(api.getTamper))
+		group.PUT("/:id/tamper/reference", web.WrapH(api.setTamperReference))
 	}
 }
 
@@ -114,6 +127,7 @@ func registerGB28181(g gin.IRouter, api IPCAPI, handler ...gin.HandlerFunc) {
 
 func (a IPCAPI) findDevice(c *gin.Context, in *ipc.FindDeviceInput) (any, error) {
 	items, total, err := a.ipc.FindDevice(c.Request.Context(), in)
+	a.ipc.MergeOnlineState(items)
 	return gin.H{"items": items, "total": total}, err
 }
 
@@ -124,9 +138,40 @@ func (a IPCAPI) getDevice(c *gin.Context, _ *struct{}) (any, error) {
 
 func (a IPCAPI) editDevice(c *gin.Context, in *ipc.EditDeviceInput) (any, error) {
 	deviceID := c.Param("id")
+	if in.SDPFormats != nil {
+		if _, err := gbs.ParseMediaFormats(*in.SDPFormats); err != nil {
+			return nil, reason.ErrBadRequest.SetMsg(err.Error())
+		}
+	}
+	if in.SSRCPrefix != nil || in.SSRCFormat != nil {
+		var prefix, format string
+		if in.SSRCPrefix != nil {
+			prefix = *in.SSRCPrefix
+		}
+		if in.SSRCFormat != nil {
+			format = *in.SSRCFormat
+		}
+		if err := gbs.ValidateSSRC(prefix, format); err != nil {
+			return nil, reason.ErrBadRequest.SetMsg(err.Error())
+		}
+	}
 	return a.ipc.EditDevice(c.Request.Context(), in, deviceID)
 }
 
+// editDeviceStreamMode 修改国标设备传输模式，下次点播时生效
+func (a IPCAPI) editDeviceStreamMode(c *gin.Context, in *ipc.EditStreamModeInput) (any, error) {
+	return a.ipc.EditDeviceStreamMode(c.Request.Context(), c.Param("id"), in)
+}
+
+// findDeviceHistory 设备上下线记录，按时间升序
+func (a IPCAPI) findDeviceHistory(c *gin.Context, in *ipc.FindDeviceHistoryInput) (gin.H, error) {
+	items, err := a.ipc.FindDeviceHistory(c.Request.Context(), c.Param("id"), in)
+	return gin.H{"items": items, "total": len(items)}, err
+}
+
+// addDeviceTimeout 添加设备包含协议校验与初始化（如 ONVIF 查询 Profiles），超时后返回，避免设备不可达时前端一直等待
+const addDeviceTimeout = 30 * time.Second
+
 // addDevice 添加设备（支持所有协议类型）
 // 通过 type 字段区分协议: "GB28181" 或 "ONVIF"
 //
@@ -142,20 +187,75 @@ func (a IPCAPI) editDevice(c *gin.Context, in *ipc.EditDeviceInput) (any, error)
 func (a IPCAPI) addDevice(c *gin.Context, in *ipc.AddDeviceInput) (any, error) {
 	in.Type = strings.ToUpper(in.Type)
 	if !slices.Contains([]string{ipc.TypeGB28181, ipc.TypeOnvif}, in.Type) {
-		return nil, reason.ErrBadRequest.SetMsg("不支持的设备类型")
+		return nil, ipc.ErrProtocolNotSupported.SetMsg("不支持的设备类型")
+	}
+	if _, err := gbs.ParseMediaFormats(in.SDPFormats); err != nil {
+		return nil, reason.ErrBadRequest.SetMsg(err.Error())
 	}
-	return a.ipc.AddDevice(c.Request.Context(), in)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), addDeviceTimeout)
+	defer cancel()
+	return a.ipc.AddDevice(ctx, in)
 }
 
+// validateDevice 添加设备前校验连接参数，返回设备上报的厂商/型号/固件
+func (a IPCAPI) validateDevice(c *gin.Context, in *ipc.AddDeviceInput) (*ipc.Device, error) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), ipc.ValidateDeviceTimeout)
+	defer cancel()
+	return a.ipc.ValidateDevice(ctx, in)
+}
+
+// delDevice 删除设备，?purge=true 时同时清理设备下所有通道的录像和事件
 func (a IPCAPI) delDevice(c *gin.Context, _ *struct{}) (any, error) {
+	ctx := c.Request.Context()
 	did := c.Param("id")
-	return a.ipc.DelDevice(c.Request.Context(), did)
+	purge, _ := strconv.ParseBool(c.Query("purge"))
+
+	// 设备删除会级联删除通道，需要提前记录通道 ID
+	var cids []string
+	if purge {
+		channels, _, err := a.ipc.FindChannel(ctx, &ipc.FindChannelInput{
+			PagerFilter: web.NewPagerFilterMaxSize(),
+			DID:         did,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range channels {
+			cids = append(cids, ch.ID)
+		}
+	}
+
+	out, err := a.ipc.DelDevice(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.purgeChannelData(ctx, cids...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// purgeChannelData 清理通道关联的录像和事件（文件 + 记录），未能全部清理时返回错误
+func (a IPCAPI) purgeChannelData(ctx context.Context, cids ...string) error {
+	if len(cids) == 0 {
+		return nil
+	}
+	recordings, recErr := a.recordingCore.PurgeByChannels(ctx, cids...)
+	events, eventErr := a.uc.EventAPI.eventCore.PurgeByChannels(ctx, cids...)
+	if err := errors.Join(recErr, eventErr); err != nil {
+		slog.ErrorContext(ctx, "purge channel data", "cids", cids, "recordings_deleted", recordings, "events_deleted", events, "err", err)
+		return reason.ErrServer.SetMsg(fmt.Sprintf("已删除，但录像或事件未能全部清理（已清理录像 %d 条、事件 %d 条）", recordings, events)).With(err.Error())
+	}
+	return nil
 }
 
 func (a IPCAPI) queryCatalog(c *gin.Context, _ *struct{}) (any, error) {
 	did := c.Param("id")
 
 	if err := a.ipc.QueryCatalog(c.Request.Context(), did); err != nil {
+		if reason.IsCustomError(err) {
+			return nil, err
+		}
 		return nil, ErrDevice.SetMsg(err.Error())
 	}
 
@@ -165,6 +265,7 @@ func (a IPCAPI) queryCatalog(c *gin.Context, _ *struct{}) (any, error) {
 func (a IPCAPI) FindChannelsForDevice(c *gin.Context, in *ipc.FindDeviceInput) (any, error) {
 	ctx := c.Request.Context()
 	items, total, err := a.ipc.FindChannelsForDevice(ctx, in)
+	a.ipc.MergeOnlineState(items)
 
 	// 收集所有通道 ID 用于批量查询录像
 	var cids []string
@@ -178,10 +279,12 @@ func (a IPCAPI) FindChannelsForDevice(c *gin.Context, in *ipc.FindDeviceInput) (
 	hasRecordingMap, _ := a.recordingCore.HasRecordings(ctx, cids)
 
 	// 为每个通道设置 has_recording 标记
+	now := time.Now()
 	for _, dev := range items {
 		for _, ch := range dev.Children {
 			ch.HasRecording = hasRecordingMap[ch.ID]
 		}
+		a.ipc.MergeFrameState(ctx, dev.Children, now)
 	}
 
 	// 按照在线优先排序
@@ -209,10 +312,17 @@ func (a IPCAPI) findChannel(c *gin.Context, in *ipc.FindChannelInput) (any, erro
 
 	// 为 RTMP 类型通道生成推流地址
 	a.fillRTMPPushAddr(c, items)
+	fillPullParams(items)
+	a.ipc.MergeFrameState(c.Request.Context(), items, time.Now())
 
 	return gin.H{"items": items, "total": total}, nil
 }
 
+func (a IPCAPI) findCivilCodes(c *gin.Context, _ *struct{}) (any, error) {
+	items, err := a.ipc.GroupChannelsByCivilCode(c.Request.Context())
+	return gin.H{"items": items, "total": len(items)}, err
+}
+
 // fillRTMPPushAddr 为 RTMP 类型通道填充推流地址
 func (a IPCAPI) fillRTMPPushAddr(c *gin.Context, items []*ipc.Channel) {
 	if a.uc == nil {
@@ -244,6 +354,18 @@ func (a IPCAPI) fillRTMPPushAddr(c *gin.Context, items []*ipc.Channel) {
 	}
 }
 
+// fillPullParams 为 RTSP 类型通道填充实际生效的拉流超时与重试次数
+func fillPullParams(items []*ipc.Channel) {
+	for _, item := range items {
+		if !item.IsRTSP() {
+			continue
+		}
+		req := sms.AddStreamProxyRequest{TimeoutSec: item.Config.TimeoutS, RetryCount: item.Config.RetryCount}
+		item.Config.EffectiveTimeoutS = req.EffectiveTimeoutSec()
+		item.Config.EffectiveRetryCount = req.EffectiveRetryCount()
+	}
+}
+
 // func (a GB28181API) getChannel(c *gin.Context, _ *struct{}) (any, error) {
 // 	channelID := c.Param("id")
 // 	return a.gb28181Core.GetChannel(c.Request.Context(), channelID)
@@ -251,6 +373,22 @@ func (a IPCAPI) fillRTMPPushAddr(c *gin.Context, items []*ipc.Channel) {
 
 func (a IPCAPI) editChannel(c *gin.Context, in *ipc.EditChannelInput) (any, error) {
 	cid := c.Param("id")
+	if in.Ext.Zones != nil {
+		ch, err := a.ipc.GetChannel(c.Request.Context(), cid)
+		if err != nil {
+			return nil, err
+		}
+		// 与 setZones 一致校验区域标签，原样回传的历史区域不校验
+		catalog := aiLabelCatalog(a.uc.Conf.Server.AI.Labels)
+		for _, z := range in.Ext.Zones {
+			if slices.ContainsFunc(ch.Ext.Zones, z.Equal) {
+				continue
+			}
+			if err := validateAILabels(z.Labels, catalog); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return a.ipc.EditChannel(c.Request.Context(), in, cid)
 }
 
@@ -258,7 +396,7 @@ func (a IPCAPI) editChannel(c *gin.Context, in *ipc.EditChannelInput) (any, erro
 func (a IPCAPI) addChannel(c *gin.Context, in *ipc.AddChannelInput) (any, error) {
 	in.Type = strings.ToUpper(in.Type)
 	if !slices.Contains([]string{ipc.TypeRTMP, ipc.TypeRTSP}, in.Type) {
-		return nil, reason.ErrBadRequest.SetMsg("仅支持 RTMP/RTSP 类型通道")
+		return nil, ipc.ErrProtocolNotSupported.SetMsg("仅支持 RTMP/RTSP 类型通道")
 	}
 	return a.ipc.AddChannel(c.Request.Context(), in)
 }
@@ -269,10 +407,20 @@ func (a IPCAPI) delChannel(c *gin.Context, _ *struct{}) (any, error) {
 
 	// 仅允许删除 RTMP/RTSP 类型通道
 	if !bz.IsRTMP(channelID) && !bz.IsRTSP(channelID) {
-		return nil, reason.ErrBadRequest.SetMsg("仅支持删除 RTMP/RTSP 类型通道")
+		return nil, ipc.ErrProtocolNotSupported.SetMsg("仅支持删除 RTMP/RTSP 类型通道")
 	}
 
-	return a.ipc.DelChannel(c.Request.Context(), channelID)
+	out, err := a.ipc.DelChannel(c.Request.Context(), channelID)
+	if err != nil {
+		return nil, err
+	}
+	// ?purge=true 时同时清理通道的录像和事件
+	if purge, _ := strconv.ParseBool(c.Query("purge")); purge {
+		if err := a.purgeChannelData(c.Request.Context(), channelID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
 }
 
 func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
@@ -284,12 +432,20 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 	if bz.IsGB28181(channelID) {
 		// 防止错误的配置，无法收到流
 		if a.uc.Conf.Media.SDPIP == "127.0.0.1" {
-			return nil, reason.ErrUsedLogic.SetMsg("请先配置流媒体 SDP 收流地址")
+			return nil, ErrMediaNotConfigured
 		}
 		ch, err := a.ipc.GetChannel(c.Request.Context(), channelID)
 		if err != nil {
 			return nil, err
 		}
+		if err := a.checkDeviceOnline(c.Request.Context(), ch.DID); err != nil {
+			return nil, err
+		}
+		if quality := c.Query("quality"); quality != "" {
+			if err := a.switchStreamQuality(c.Request.Context(), ch, quality); err != nil {
+				return nil, err
+			}
+		}
 
 		app = "rtp"
 		appStream = ch.ID
@@ -302,7 +458,7 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 			return nil, err
 		}
 		if !ch.IsOnline {
-			return nil, reason.ErrNotFound.SetMsg("未推流")
+			return nil, ipc.ErrStreamNotPublished
 		}
 		app = ch.App
 		appStream = ch.Stream
@@ -334,11 +490,11 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 		appStream = channelID
 		mediaServerID = sms.DefaultMediaServerID
 	} else {
-		return nil, reason.ErrNotFound.SetMsg("不支持的播放通道")
+		return nil, ipc.ErrProtocolNotSupported.SetMsg("不支持的播放通道")
 	}
 
 	if !a.uc.SMSAPI.smsCore.IsOnline(mediaServerID) {
-		return nil, reason.ErrNotFound.SetMsg("Oops! 流媒体服务离线或IP有误")
+		return nil, sms.ErrMediaServerOffline
 	}
 	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(c.Request.Context(), mediaServerID)
 	if err != nil {
@@ -388,7 +544,7 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 				slog.ErrorContext(c.Request.Context(), "get snapshot", "err", err)
 				continue
 			}
-			if err := writeCover(a.uc.Conf.ConfigDir, channelID, body); err != nil {
+			if err := a.saveCover(channelID, body); err != nil {
 				slog.ErrorContext(c.Request.Context(), "write cover", "err", err)
 			}
 			break
@@ -424,6 +580,79 @@ func (a IPCAPI) play(c *gin.Context, _ *struct{}) (*playOutput, error) {
 	return &out, nil
 }
 
+// switchStreamQuality 切换国标通道点播的码流，?quality=main/sub/third
+// 通道正在播放其它码流时重新点播，同一通道的其它观看者会短暂中断
+func (a IPCAPI) switchStreamQuality(ctx context.Context, ch *ipc.Channel, quality string) error {
+	dev, err := a.ipc.GetDevice(ctx, ch.DID)
+	if err != nil {
+		return err
+	}
+	number, err := gbs.ParseStreamQuality(quality, dev.Ext.StreamNumbers)
+	if err != nil {
+		return reason.ErrBadRequest.SetMsg(err.Error())
+	}
+	if !a.uc.SipServer.SetStreamNumber(ch.ID, number) || !ch.IsPlaying {
+		return nil
+	}
+	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(ctx, sms.DefaultMediaServerID)
+	if err != nil {
+		return err
+	}
+	return a.uc.SipServer.Play(&gbs.PlayInput{
+		Channel:      ch,
+		SMS:          svr,
+		StreamMode:   dev.StreamMode,
+		MediaFormats: dev.Ext.SDPFormats,
+		SSRCPrefix:   dev.Ext.SSRCPrefix,
+		SSRCFormat:   dev.Ext.SSRCFormat,
+		StreamNumber: number,
+	})
+}
+
+// checkDeviceOnline 播放前检查国标设备在线，以协议内存中的在线状态为准
+func (a IPCAPI) checkDeviceOnline(ctx context.Context, did string) error {
+	dev, err := a.ipc.GetDevice(ctx, did)
+	if err != nil {
+		return err
+	}
+	a.ipc.MergeOnlineState([]*ipc.Device{dev})
+	if !dev.IsOnline {
+		return ipc.ErrDeviceOffline
+	}
+	return nil
+}
+
+// getMediaInfo 查询通道当前直播流的分辨率、编码、码率与帧率，流未在线时返回 ErrStreamNotActive
+func (a IPCAPI) getMediaInfo(c *gin.Context, _ *struct{}) (*sms.MediaInfo, error) {
+	ctx := c.Request.Context()
+	ch, err := a.ipc.GetChannel(ctx, c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	app := ch.GetApp()
+	if app == "" {
+		app = "live"
+	}
+	mediaServerID := ch.Config.MediaServerID
+	if mediaServerID == "" {
+		mediaServerID = sms.DefaultMediaServerID
+	}
+
+	if !a.uc.SMSAPI.smsCore.IsOnline(mediaServerID) {
+		return nil, sms.ErrMediaServerOffline
+	}
+	svr, err := a.uc.SMSAPI.smsCore.GetMediaServer(ctx, mediaServerID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := a.uc.SMSAPI.smsCore.GetMediaInfo(ctx, svr, app, ch.GetStream())
+	if err != nil {
+		return nil, err
+	}
+	a.ipc.TouchFrame(ch.ID, time.Now())
+	return info, nil
+}
+
 type refreshSnapshotInput struct {
 	// 指定获取多少秒内创建的快照
 	WithinSeconds int64 `json:"within_seconds"`
@@ -434,7 +663,7 @@ type refreshSnapshotInput struct {
 func (a IPCAPI) refreshSnapshot(c *gin.Context, in *refreshSnapshotInput) (any, error) {
 	channelID := c.Param("id")
 
-	path := readCoverPath(a.uc.Conf.ConfigDir, channelID)
+	path := readCoverPath(a.posterDir(), channelID)
 
 	token := c.GetString("token")
 
@@ -470,7 +699,7 @@ func (a IPCAPI) refreshSnapshot(c *gin.Context, in *refreshSnapshotInput) (any,
 			// return nil, reason.ErrBadRequest.Msg(err.Error())
 		} else {
 			if hook.MD5FromBytes(img) != "" {
-				if err := writeCover(a.uc.Conf.ConfigDir, channelID, img); err != nil {
+				if err := a.saveCover(channelID, img); err != nil {
 					slog.ErrorContext(c.Request.Context(), "write cover", "err", err)
 				}
 			}
@@ -482,33 +711,65 @@ func (a IPCAPI) refreshSnapshot(c *gin.Context, in *refreshSnapshotInput) (any,
 
 func (a IPCAPI) addZone(c *gin.Context, in *ipc.AddZoneInput) (gin.H, error) {
 	channelID := c.Param("id")
-	if len(in.Labels) == 0 {
-		in.Labels = []string{"person", "car", "cat", "dog"}
+	ai := a.uc.Conf.Server.AI
+	in.Labels = resolveAILabels(in.Labels, ai.DefaultLabels)
+	if err := validateAILabels(in.Labels, aiLabelCatalog(ai.Labels)); err != nil {
+		return nil, err
 	}
 	zones, err := a.ipc.AddZone(c.Request.Context(), in, channelID)
 	return gin.H{"items": zones}, err
 }
 
+func (a IPCAPI) setZones(c *gin.Context, in *ipc.SetZonesInput) (gin.H, error) {
+	channelID := c.Param("id")
+	ai := a.uc.Conf.Server.AI
+	for i := range in.Zones {
+		in.Zones[i].Labels = resolveAILabels(in.Zones[i].Labels, ai.DefaultLabels)
+		if err := validateAILabels(in.Zones[i].Labels, aiLabelCatalog(ai.Labels)); err != nil {
+			return nil, err
+		}
+	}
+	zones, err := a.ipc.SetZones(c.Request.Context(), channelID, in.Zones)
+	return gin.H{"items": zones}, err
+}
+
 func (a IPCAPI) getZones(c *gin.Context, _ *struct{}) (any, error) {
 	channelID := c.Param("id")
 	return a.ipc.GetZones(c.Request.Context(), channelID)
 }
 
+// getSnapshot 按配置的来源顺序返回通道封面，流断开时回退到缓存封面或离线占位图，避免设备墙出现裂图
+// 来自文件时使用 c.File 以便浏览器通过 Last-Modified 协商缓存，设备墙刷新时无需重复下载
+// 通道不存在时直接报错，不返回占位图
 func (a IPCAPI) getSnapshot(c *gin.Context) {
-	channelID := c.Param("id")
-	body, err := readCover(a.uc.Conf.ConfigDir, channelID)
+	ctx := c.Request.Context()
+	if _, err := a.ipc.GetChannel(ctx, c.Param("id")); err != nil {
+		web.Fail(c, err)
+		return
+	}
+	res, err := resolveSnapshot(ctx, c.Param("id"), a.uc.Conf.Server.Poster.Fallback, a.snapshotSources())
 	if err != nil {
 		web.Fail(c, reason.ErrNotFound.SetMsg(err.Error()))
 		return
 	}
-	c.Data(200, "image/jpeg", body)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("X-Snapshot-Source", res.source)
+	if res.path == "" {
+		c.Data(http.StatusOK, http.DetectContentType(res.body), res.body)
+		return
+	}
+	// 封面可能已转码为 webp，不能按 .jpg 扩展名推断类型
+	if ct := sniffContentType(res.path); ct != "" {
+		c.Header("Content-Type", ct)
+	}
+	c.File(res.path)
 }
 
 func (a IPCAPI) discover(c *gin.Context) {
 	p := a.ipc.GetProtocol(ipc.TypeOnvif)
 	onvifAdapter, ok := p.(*onvifadapter.Adapter)
 	if !ok {
-		web.Fail(c, reason.ErrNotFound.SetMsg("不支持的协议"))
+		web.Fail(c, ipc.ErrProtocolNotSupported)
 		return
 	}
 
@@ -626,7 +887,24 @@ func (a IPCAPI) buildRTSPURL(ctx context.Context, channelID string) (string, err
 	if err != nil {
 		return "", err
 	}
+	return a.rtspURLOn(ctx, svr, channelID)
+}
+
+// channelMediaServer 返回通道流所在的媒体服务器，通道未指定时使用默认节点
+func (a IPCAPI) channelMediaServer(ctx context.Context, channelID string) (*sms.MediaServer, error) {
+	ch, err := a.ipc.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	mediaServerID := ch.Config.MediaServerID
+	if mediaServerID == "" {
+		mediaServerID = sms.DefaultMediaServerID
+	}
+	return a.uc.SMSAPI.smsCore.GetMediaServer(ctx, mediaServerID)
+}
 
+// rtspURLOn 生成由媒体服务器 svr 自身拉取通道流的 RTSP 地址
+func (a IPCAPI) rtspURLOn(ctx context.Context, svr *sms.MediaServer, channelID string) (string, error) {
 	var app, stream string
 
 	if bz.IsGB28181(channelID) {
@@ -693,3 +971,11 @@ func (a IPCAPI) setRecordMode(c *gin.Context, in *setRecordModeInput) (gin.H, er
 		"record_mode": channel.Ext.GetRecordMode(),
 	}, nil
 }
+
+// resetAlarm 清除设备上锁存的报警，设备应答成功后返回
+func (a IPCAPI) resetAlarm(c *gin.Context, in *ipc.ResetAlarmInput) (gin.H, error) {
+	if err := a.ipc.ResetAlarm(c.Request.Context(), c.Param("id"), in); err != nil {
+		return nil, err
+	}
+	return gin.H{"msg": "ok"}, nil
+}
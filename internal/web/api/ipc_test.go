@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/adapter/gbadapter"
+	"github.com/gowvp/owl/internal/adapter/rtspadapter"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/bz"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/gowvp/owl/internal/core/event/store/eventdb"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/ipc/store/ipcdb"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/gowvp/owl/internal/core/recording/store/recordingdb"
+	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/internal/core/sms/store/smsdb"
+	"github.com/ixugo/goddd/domain/uniqueid"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+func TestPlayErrorReason(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bc := conf.Bootstrap{}
+	bc.Media.SDPIP = "127.0.0.1"
+	api := IPCAPI{uc: &Usecase{Conf: &bc}}
+
+	r := gin.New()
+	r.POST("/channels/:id/play", web.WrapH(api.play))
+
+	cases := []struct {
+		name   string
+		id     string
+		reason string
+	}{
+		{name: "unknown channel type", id: "unknown", reason: "ErrProtocolNotSupported"},
+		{name: "sdp ip not configured", id: bz.IDPrefixGBChannel + "1", reason: "ErrMediaNotConfigured"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/channels/"+tc.id+"/play", nil))
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusBadRequest || body.Reason != tc.reason {
+				t.Fatalf("expect 400 %s, got %d %s", tc.reason, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+type failValidateProtocol struct{ ipc.Protocoler }
+
+func (failValidateProtocol) ValidateDevice(context.Context, *ipc.Device) error {
+	return errors.New("账号或密码错误")
+}
+
+func TestValidateDevice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core := ipc.NewCore(nil, uniqueid.Core{}, map[string]ipc.Protocoler{
+		ipc.TypeGB28181: gbadapter.NewAdapter(ipc.Adapter{}, nil, sms.Core{}),
+		ipc.TypeRTSP:    rtspadapter.NewAdapter(ipc.Core{}, sms.Core{}),
+		ipc.TypeOnvif:   failValidateProtocol{},
+	})
+	api := IPCAPI{ipc: core}
+	r := gin.New()
+	r.POST("/devices/validate", web.WrapH(api.validateDevice))
+
+	cases := []struct {
+		name   string
+		body   string
+		code   int
+		reason string
+	}{
+		{name: "gb28181 no-op", body: `{"type":"gb28181","device_id":"34020000001110000001","password":"123"}`, code: http.StatusOK},
+		{name: "rtsp no-op", body: `{"type":"RTSP","ip":"192.168.1.2","port":554}`, code: http.StatusOK},
+		{name: "onvif failed", body: `{"type":"onvif","ip":"192.168.1.3","port":80}`, code: http.StatusBadRequest, reason: "ErrDeviceValidateFailed"},
+		{name: "unknown type", body: `{"type":"hik"}`, code: http.StatusBadRequest, reason: "ErrProtocolNotSupported"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/devices/validate", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			var body struct {
+				Reason   string `json:"reason"`
+				Type     string `json:"type"`
+				Password string `json:"password"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.code || body.Reason != tc.reason {
+				t.Fatalf("expect %d %s, got %d %s", tc.code, tc.reason, w.Code, w.Body.String())
+			}
+			if tc.code == http.StatusOK && (body.Password != "" || body.Type == "") {
+				t.Fatalf("unexpected device %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeviceSDPFormatsValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var api IPCAPI
+	r := gin.New()
+	r.POST("/devices", web.WrapH(api.addDevice))
+	r.PUT("/devices/:id", web.WrapH(api.editDevice))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "add unknown encoding", method: http.MethodPost, path: "/devices", body: `{"type":"GB28181","device_id":"34020000001110000001","sdp_formats":["96 VP8/90000"]}`},
+		{name: "add bad payload", method: http.MethodPost, path: "/devices", body: `{"type":"GB28181","device_id":"34020000001110000001","sdp_formats":["200 PS/90000"]}`},
+		{name: "edit unknown encoding", method: http.MethodPut, path: "/devices/gb1", body: `{"sdp_formats":["96 PS/90000","98 AV1/90000"]}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusBadRequest || body.Reason != "ErrBadRequest" {
+				t.Fatalf("expect 400 ErrBadRequest, got %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEditChannelZoneLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := ipcdb.NewDB(newTestDB(t)).AutoMigrate(true)
+	api := IPCAPI{
+		ipc: ipc.NewCore(store, uniqueid.Core{}, nil),
+		uc:  &Usecase{Conf: &conf.Bootstrap{}},
+	}
+	r := gin.New()
+	r.PUT("/channels/:id", web.WrapH(api.editChannel))
+
+	square := []float32{0.1, 0.1, 0.5, 0.1, 0.5, 0.5}
+	legacy := ipc.Zone{Name: "old", Coordinates: square, Labels: []string{"unknown"}}
+	if err := store.Channel().Add(context.Background(), &ipc.Channel{ID: "rtsp1", Type: ipc.TypeRTSP, Ext: ipc.DeviceExt{Zones: []ipc.Zone{legacy}}}); err != nil {
+		t.Fatal(err)
+	}
+	edit := func(zones ...ipc.Zone) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ipc.EditChannelInput{Name: "new", Ext: ipc.DeviceExt{Zones: zones}})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/channels/rtsp1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name  string
+		zones []ipc.Zone
+		code  int
+	}{
+		{name: "unchanged legacy zone", zones: []ipc.Zone{legacy}, code: http.StatusOK},
+		{name: "known label", zones: []ipc.Zone{{Name: "door", Coordinates: square, Labels: []string{"person"}}}, code: http.StatusOK},
+		{name: "unknown label", zones: []ipc.Zone{{Name: "door", Coordinates: square, Labels: []string{"unicorn"}}}, code: http.StatusBadRequest},
+		{name: "invalid label", zones: []ipc.Zone{{Name: "door", Coordinates: square, Labels: []string{"a;b"}}}, code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := edit(tt.zones...)
+			if w.Code != tt.code {
+				t.Fatalf("expect %d, got %d %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code == http.StatusBadRequest && !strings.Contains(w.Body.String(), "标签") {
+				t.Fatalf("expect label error, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestChannelMediaServer(t *testing.T) {
+	db := newTestDB(t)
+	ipcStore := ipcdb.NewDB(db).AutoMigrate(true)
+	smsStore := smsdb.NewDB(db).AutoMigrate(true)
+	smsCore := sms.NewCore(smsStore)
+	defer smsCore.Close()
+	api := IPCAPI{
+		ipc: ipc.NewCore(ipcStore, uniqueid.Core{}, nil),
+		uc:  &Usecase{SMSAPI: SmsAPI{smsCore: smsCore}},
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{sms.DefaultMediaServerID, "node2"} {
+		if err := smsStore.MediaServer().Add(ctx, &sms.MediaServer{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ch := range []*ipc.Channel{
+		{ID: "rtsp1", Type: ipc.TypeRTSP},
+		{ID: "rtsp2", Type: ipc.TypeRTSP, Config: ipc.StreamConfig{MediaServerID: "node2"}},
+	} {
+		if err := ipcStore.Channel().Add(ctx, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 抓拍等需在通道流所在的节点上进行，未指定时使用默认节点
+	for cid, expect := range map[string]string{"rtsp1": sms.DefaultMediaServerID, "rtsp2": "node2"} {
+		svr, err := api.channelMediaServer(ctx, cid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if svr.ID != expect {
+			t.Fatalf("%s expect media server %s, got %s", cid, expect, svr.ID)
+		}
+	}
+	if _, err := api.channelMediaServer(ctx, "missing"); err == nil {
+		t.Fatal("expect error for unknown channel")
+	}
+}
+
+type deleteDeviceProtocol struct{ ipc.Protocoler }
+
+func (deleteDeviceProtocol) DeleteDevice(context.Context, *ipc.Device) error { return nil }
+
+func TestDelDevicePurge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	ipcStore := ipcdb.NewDB(db).AutoMigrate(true)
+	recStore := recordingdb.NewDB(db).AutoMigrate(true)
+	evStore := eventdb.NewDB(db).AutoMigrate(true)
+	api := IPCAPI{
+		ipc:           ipc.NewCore(ipcStore, uniqueid.Core{}, map[string]ipc.Protocoler{ipc.TypeGB28181: deleteDeviceProtocol{}}),
+		recordingCore: recording.NewCore(recStore),
+		uc:            &Usecase{EventAPI: EventAPI{eventCore: event.NewCore(evStore)}},
+	}
+	r := gin.New()
+	r.DELETE("/devices/:id", web.WrapH(api.delDevice))
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, dev := range []*ipc.Device{
+		{ID: "gb1", DeviceID: "34020000001110000001", Type: ipc.TypeGB28181},
+		{ID: "gb2", DeviceID: "34020000001110000002", Type: ipc.TypeGB28181},
+	} {
+		if err := ipcStore.Device().Add(ctx, dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ch := range []*ipc.Channel{
+		{ID: "ch1", DID: "gb1", Type: ipc.TypeGB28181},
+		{ID: "ch2", DID: "gb1", Type: ipc.TypeGB28181},
+		{ID: "ch3", DID: "gb2", Type: ipc.TypeGB28181},
+	} {
+		if err := ipcStore.Channel().Add(ctx, ch); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, ch.ID+".mp4")
+		if err := os.WriteFile(path, []byte("mp4"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := recStore.Recording().Add(ctx, &recording.Recording{CID: ch.ID, Path: path, Size: 3}); err != nil {
+			t.Fatal(err)
+		}
+		if err := evStore.Event().Add(ctx, &event.Event{DID: ch.DID, CID: ch.ID, Label: "person"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	remainCIDs := func(model any) []string {
+		t.Helper()
+		var cids []string
+		if err := db.Model(model).Order("cid").Pluck("cid", &cids).Error; err != nil {
+			t.Fatal(err)
+		}
+		return cids
+	}
+	del := func(url string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("DELETE %s expect 200, got %d %s", url, w.Code, w.Body.String())
+		}
+	}
+
+	// 删除设备同时清理其下所有通道的录像与事件，其它设备不受影响
+	del("/devices/gb1?purge=true")
+	if got := remainCIDs(&recording.Recording{}); !slices.Equal(got, []string{"ch3"}) {
+		t.Fatalf("expect only ch3 recordings remain, got %v", got)
+	}
+	if got := remainCIDs(&event.Event{}); !slices.Equal(got, []string{"ch3"}) {
+		t.Fatalf("expect only ch3 events remain, got %v", got)
+	}
+	for id, exist := range map[string]bool{"ch1": false, "ch2": false, "ch3": true} {
+		if _, err := os.Stat(filepath.Join(dir, id+".mp4")); (err == nil) != exist {
+			t.Fatalf("%s recording file exist expect %v, got err %v", id, exist, err)
+		}
+	}
+
+	// 不带 purge 时保留录像与事件
+	del("/devices/gb2")
+	if got := remainCIDs(&recording.Recording{}); !slices.Equal(got, []string{"ch3"}) {
+		t.Fatalf("expect ch3 recordings kept without purge, got %v", got)
+	}
+	if got := remainCIDs(&event.Event{}); !slices.Equal(got, []string{"ch3"}) {
+		t.Fatalf("expect ch3 events kept without purge, got %v", got)
+	}
+
+	// 录像文件删除失败时不能报告成功
+	if err := ipcStore.Device().Add(ctx, &ipc.Device{ID: "gb3", DeviceID: "34020000001110000003", Type: ipc.TypeGB28181}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ipcStore.Channel().Add(ctx, &ipc.Channel{ID: "ch4", DID: "gb3", Type: ipc.TypeGB28181}); err != nil {
+		t.Fatal(err)
+	}
+	locked := filepath.Join(dir, "ch4.mp4")
+	if err := os.MkdirAll(filepath.Join(locked, "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := recStore.Recording().Add(ctx, &recording.Recording{CID: "ch4", Path: locked, Size: 3}); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/devices/gb3?purge=true", nil))
+	if w.Code == http.StatusOK || !strings.Contains(w.Body.String(), "ErrServer") {
+		t.Fatalf("expect purge failure reported, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latencyBuckets HTTP 请求耗时分桶上限(秒)，覆盖普通接口到截图、代理等慢请求
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// latencyHistogram 请求耗时直方图，counts 最后一项为 +Inf 桶
+type latencyHistogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]uint64, len(latencyBuckets)+1)}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	sec := d.Seconds()
+	idx, _ := slices.BinarySearch(latencyBuckets, sec)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[idx]++
+	h.sum += sec
+	h.count++
+}
+
+// latencySnapshot 直方图某一时刻的副本，避免计算分位数时持有锁
+type latencySnapshot struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *latencyHistogram) snapshot() latencySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return latencySnapshot{counts: slices.Clone(h.counts), sum: h.sum, count: h.count}
+}
+
+// quantile 按桶内线性插值估算分位数(秒)，与 Prometheus histogram_quantile 一致
+// 落在 +Inf 桶时返回最大的有限上限
+func (s latencySnapshot) quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := q * float64(s.count)
+	var cumulative uint64
+	for i, n := range s.counts {
+		prev := cumulative
+		cumulative += n
+		if float64(cumulative) < rank || n == 0 {
+			continue
+		}
+		if i == len(latencyBuckets) {
+			return latencyBuckets[len(latencyBuckets)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		upper := latencyBuckets[i]
+		return lower + (upper-lower)*(rank-float64(prev))/float64(n)
+	}
+	return latencyBuckets[len(latencyBuckets)-1]
+}
+
+// routeLatency 按 "METHOD 路由" 统计请求耗时
+type routeLatency struct {
+	mu     sync.RWMutex
+	routes map[string]*latencyHistogram
+}
+
+func newRouteLatency() *routeLatency {
+	return &routeLatency{routes: make(map[string]*latencyHistogram)}
+}
+
+func (r *routeLatency) observe(route string, d time.Duration) {
+	r.mu.RLock()
+	h, ok := r.routes[route]
+	r.mu.RUnlock()
+	if !ok {
+		r.mu.Lock()
+		if h, ok = r.routes[route]; !ok {
+			h = newLatencyHistogram()
+			r.routes[route] = h
+		}
+		r.mu.Unlock()
+	}
+	h.observe(d)
+}
+
+// snapshots 返回按路由排序的直方图副本
+func (r *routeLatency) snapshots() ([]string, map[string]latencySnapshot) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.routes))
+	out := make(map[string]latencySnapshot, len(r.routes))
+	for k, h := range r.routes {
+		keys = append(keys, k)
+		out[k] = h.snapshot()
+	}
+	slices.Sort(keys)
+	return keys, out
+}
+
+// apiLatency 与 web.Metrics 的 expvar 计数一样为进程级统计
+var apiLatency = newRouteLatency()
+
+// latencyMetrics 记录每个路由的请求耗时，未匹配路由的请求不统计，避免扫描请求撑大路由表
+func latencyMetrics(r *routeLatency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		r.observe(c.Request.Method+" "+route, time.Since(start))
+	}
+}
+
+// RouteLatency 单个路由的耗时分位数，单位毫秒
+type RouteLatency struct {
+	Route string  `json:"route"`
+	Count uint64  `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// latencyTop 按 p99 降序返回最慢的 top 个路由
+func (r *routeLatency) latencyTop(top int) []RouteLatency {
+	keys, snaps := r.snapshots()
+	out := make([]RouteLatency, 0, len(keys))
+	ms := func(sec float64) float64 { return math.Round(sec*1e6) / 1e3 }
+	for _, k := range keys {
+		s := snaps[k]
+		out = append(out, RouteLatency{
+			Route: k,
+			Count: s.count,
+			AvgMs: ms(s.sum / float64(max(s.count, 1))),
+			P50Ms: ms(s.quantile(0.5)),
+			P90Ms: ms(s.quantile(0.9)),
+			P99Ms: ms(s.quantile(0.99)),
+		})
+	}
+	slices.SortStableFunc(out, func(a, b RouteLatency) int {
+		switch {
+		case a.P99Ms > b.P99Ms:
+			return -1
+		case a.P99Ms < b.P99Ms:
+			return 1
+		}
+		return 0
+	})
+	if len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+// writePrometheus 以 Prometheus 文本格式输出请求计数与耗时直方图
+func (r *routeLatency) writePrometheus(b *strings.Builder) {
+	writeCounter := func(name, help, typ, key string) {
+		v, ok := expvar.Get(key).(*expvar.Int)
+		if !ok {
+			return
+		}
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, typ, name, v.Value())
+	}
+	writeCounter("owl_http_requests_in_flight", "Current number of HTTP requests being served.", "gauge", "request")
+	writeCounter("owl_http_requests_total", "Total number of HTTP requests.", "counter", "requests")
+
+	const name = "owl_http_request_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s HTTP request latency by route.\n# TYPE %s histogram\n", name, name)
+	keys, snaps := r.snapshots()
+	for _, k := range keys {
+		method, route, _ := strings.Cut(k, " ")
+		labels := fmt.Sprintf(`method="%s",route=%s`, method, strconv.Quote(route))
+		s := snaps[k]
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, le, cumulative)
+		}
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, s.count)
+	}
+}
+
+// getMetricsPrometheus Prometheus 抓取接口
+func (uc *Usecase) getMetricsPrometheus(c *gin.Context) {
+	var b strings.Builder
+	apiLatency.writePrometheus(&b)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
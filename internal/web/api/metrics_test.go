@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for range 50 {
+		h.observe(3 * time.Millisecond) // le 0.005
+	}
+	for range 40 {
+		h.observe(80 * time.Millisecond) // le 0.1
+	}
+	for range 9 {
+		h.observe(400 * time.Millisecond) // le 0.5
+	}
+	h.observe(30 * time.Second) // +Inf
+
+	s := h.snapshot()
+	if s.count != 100 {
+		t.Fatalf("expect 100 observations, got %d", s.count)
+	}
+	expect := map[int]uint64{0: 50, 4: 40, 6: 9, len(latencyBuckets): 1}
+	for i, n := range s.counts {
+		if n != expect[i] {
+			t.Fatalf("bucket %d expect %d, got %d", i, expect[i], n)
+		}
+	}
+
+	if p50 := s.quantile(0.5); p50 > 0.005 {
+		t.Fatalf("p50 should fall in the first bucket, got %v", p50)
+	}
+	if p90 := s.quantile(0.9); p90 <= 0.05 || p90 > 0.1 {
+		t.Fatalf("p90 should fall in (0.05, 0.1], got %v", p90)
+	}
+	if p99 := s.quantile(0.99); p99 <= 0.25 || p99 > 0.5 {
+		t.Fatalf("p99 should fall in (0.25, 0.5], got %v", p99)
+	}
+	if q := s.quantile(1); q != latencyBuckets[len(latencyBuckets)-1] {
+		t.Fatalf("+Inf bucket should report the largest bound, got %v", q)
+	}
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rl := newRouteLatency()
+	r := gin.New()
+	r.Use(latencyMetrics(rl))
+	r.GET("/items/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/items/1", "/items/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	top := rl.latencyTop(10)
+	if len(top) != 1 || top[0].Route != "GET /items/:id" || top[0].Count != 2 {
+		t.Fatalf("unexpected latency routes %+v", top)
+	}
+
+	var b strings.Builder
+	rl.writePrometheus(&b)
+	out := b.String()
+	for _, line := range []string{
+		"# TYPE owl_http_request_duration_seconds histogram",
+		`owl_http_request_duration_seconds_bucket{method="GET",route="/items/:id",le="+Inf"} 2`,
+		`owl_http_request_duration_seconds_count{method="GET",route="/items/:id"} 2`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("missing %q in:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// posterDir 通道封面缓存目录
+func (a IPCAPI) posterDir() string {
+	if dir := a.uc.Conf.Server.Poster.Dir; dir != "" {
+		return dir
+	}
+	return filepath.Join(a.uc.Conf.ConfigDir, coverDir)
+}
+
+// saveCover 按快照配置转码后写入封面
+// 不记录画面时间，流媒体可能返回缓存的旧截图，由实时抓拍(grabKeyFrame)确认画面
+func (a IPCAPI) saveCover(channelID string, body []byte) error {
+	body, err := normalizeSnapshot(body, a.uc.Conf.Server.Snapshot)
+	if err != nil {
+		return err
+	}
+	return writeCover(a.posterDir(), channelID, body)
+}
+
+// posterExpired 封面不存在或超过有效期
+func posterExpired(dir, channelID string, now time.Time, ttl time.Duration) bool {
+	fi, err := os.Stat(readCoverPath(dir, channelID))
+	if err != nil {
+		return true
+	}
+	return now.Sub(fi.ModTime()) >= ttl
+}
+
+// expiredPosters 筛选需要刷新封面的通道
+// 只处理正在拉流的通道，抓拍未拉流的通道会触发按需拉流，反而给设备带来负担
+func expiredPosters(dir string, channels []*ipc.Channel, now time.Time, ttl time.Duration) []*ipc.Channel {
+	out := make([]*ipc.Channel, 0, len(channels))
+	for _, ch := range channels {
+		if ch.IsPlaying && posterExpired(dir, ch.ID, now, ttl) {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// StartPosterLoop 按封面有效期巡检，从关键帧刷新过期的封面，未配置有效期时不启动
+func (a IPCAPI) StartPosterLoop(ctx context.Context) {
+	ttl := time.Duration(a.uc.Conf.Server.Poster.TTL)
+	if ttl <= 0 {
+		return
+	}
+
+	go func() {
+		// 巡检间隔取有效期的一半，封面最久不超过 1.5 倍有效期
+		ticker := time.NewTicker(max(ttl/2, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.refreshPosters(ctx, ttl)
+			}
+		}
+	}()
+}
+
+// refreshPosters 执行一轮封面刷新
+func (a IPCAPI) refreshPosters(ctx context.Context, ttl time.Duration) {
+	channels, _, err := a.ipc.FindChannel(ctx, &ipc.FindChannelInput{
+		PagerFilter: web.PagerFilter{Page: 1, Size: 999},
+		IsOnline:    "true",
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "poster: find channels failed", "err", err)
+		return
+	}
+
+	dir := a.posterDir()
+	for _, ch := range expiredPosters(dir, channels, time.Now(), ttl) {
+		img, err := a.grabKeyFrame(ctx, ch.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "poster: grab key frame failed", "channel_id", ch.ID, "err", err)
+			continue
+		}
+		if err := a.saveCover(ch.ID, img); err != nil {
+			slog.ErrorContext(ctx, "poster: write failed", "channel_id", ch.ID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+func TestExpiredPosters(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	const ttl = 5 * time.Minute
+
+	writePoster := func(id string, age time.Duration) {
+		if err := writeCover(dir, id, []byte("jpg")); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(-age)
+		if err := os.Chtimes(readCoverPath(dir, id), mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePoster("fresh", time.Minute)
+	writePoster("expired", ttl)
+	writePoster("idle", time.Hour)
+
+	channels := []*ipc.Channel{
+		{ID: "fresh", IsPlaying: true},
+		{ID: "expired", IsPlaying: true},
+		{ID: "missing", IsPlaying: true},
+		// 未拉流的通道即使封面过期也不抓拍
+		{ID: "idle", IsPlaying: false},
+	}
+	got := expiredPosters(dir, channels, now, ttl)
+	if len(got) != 2 || got[0].ID != "expired" || got[1].ID != "missing" {
+		ids := make([]string, 0, len(got))
+		for _, ch := range got {
+			ids = append(ids, ch.ID)
+		}
+		t.Fatalf("expect [expired missing], got %v", ids)
+	}
+
+	// 刷新后在有效期内不再重复抓拍
+	writePoster("expired", 0)
+	if posterExpired(dir, "expired", now, ttl) {
+		t.Fatal("refreshed poster should be fresh")
+	}
+	if !posterExpired(dir, "expired", now.Add(ttl), ttl) {
+		t.Fatal("poster should expire after ttl")
+	}
+}
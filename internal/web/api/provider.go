@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
@@ -43,12 +44,13 @@ var (
 		NewIPCStore, NewGBAdapter,
 		NewIPCCoreWithProtocols,
 		NewIPCAPI,
+		NewPTZAPI,
 		NewConfigAPI,
 		NewUserAPI,
 		NewAIWebhookAPIWithDeps,
 		NewEventCore, NewEventAPI,
-		// Recording: Store -> SMSProvider(adapter) -> Core -> API
-		NewRecordingStore, NewSMSProviderAdapter, NewRecordingCore, NewRecordingAPI,
+		// Recording: Store -> SMSProvider/ChannelProvider(adapter) -> Core -> API
+		NewRecordingStore, NewSMSProviderAdapter, NewChannelProviderAdapter, NewRecordingCore, NewRecordingAPI,
 	)
 )
 
@@ -69,6 +71,9 @@ type Usecase struct {
 	EventAPI EventAPI
 
 	RecordingAPI RecordingAPI
+	PTZAPI       PTZAPI
+
+	upgrade upgradeState `wire:"-"`
 }
 
 // NewHTTPHandler 生成Gin框架路由内容
@@ -170,12 +175,39 @@ func NewIPCCoreWithProtocols(store ipc.Storer, uni uniqueid.Core, adapter ipc.Ad
 	// 第三步：将 protocols 注入到 ipc.Core
 	ipcCore.SetProtocols(protocols)
 
+	// 第四步：流媒体重连后按节点实际在线的流校正通道播放状态
+	smsCore.SetStreamReconciler(newStreamReconciler(ipcCore))
+
 	return IPCBundle{
 		Core:      ipcCore,
 		Protocols: protocols,
 	}
 }
 
+// newStreamReconciler 将节点在线流转换为 ipc 的流标识并校正通道状态
+// 未指定节点的通道使用默认节点
+func newStreamReconciler(ipcCore ipc.Core) sms.StreamReconciler {
+	return func(ctx context.Context, mediaServerID string, streams []sms.StreamInfo) {
+		keys := make([]ipc.StreamKey, 0, len(streams))
+		for _, s := range streams {
+			keys = append(keys, ipc.StreamKey{App: s.App, Stream: s.Stream})
+		}
+		onNode := func(ch *ipc.Channel) bool {
+			id := ch.Config.MediaServerID
+			if id == "" {
+				id = sms.DefaultMediaServerID
+			}
+			return id == mediaServerID
+		}
+		n, err := ipcCore.ReconcilePlaying(ctx, onNode, keys)
+		if err != nil {
+			slog.ErrorContext(ctx, "重连后校正通道状态失败", "media_server_id", mediaServerID, "err", err)
+			return
+		}
+		slog.InfoContext(ctx, "重连后校正通道状态", "media_server_id", mediaServerID, "streams", len(streams), "changed", n)
+	}
+}
+
 // NewAIWebhookAPIWithDeps 创建带依赖的 AI Webhook API
 func NewAIWebhookAPIWithDeps(conf *conf.Bootstrap, eventCore event.Core, ipcBundle IPCBundle) AIWebhookAPI {
 	return NewAIWebhookAPI(conf, eventCore, ipcBundle.Core)
@@ -186,3 +218,8 @@ func NewAIWebhookAPIWithDeps(conf *conf.Bootstrap, eventCore event.Core, ipcBund
 func NewSMSProviderAdapter(smsCore sms.Core) recording.SMSProvider {
 	return adapter.NewSMSAdapter(smsCore)
 }
+
+// NewChannelProviderAdapter 创建通道适配器，将 ipc.Core 适配为 recording.ChannelProvider
+func NewChannelProviderAdapter(bundle IPCBundle) recording.ChannelProvider {
+	return adapter.NewChannelAdapter(bundle.Core)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/conc"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+// ptzController 云台控制，由 ipc.Core 实现，测试时可替换
+type ptzController interface {
+	PTZControl(ctx context.Context, channelID string, in *ipc.PTZControlInput) error
+	PTZFIControl(ctx context.Context, channelID string, in *ipc.PTZFIInput) error
+	PTZPosition(ctx context.Context, channelID string, in *ipc.PTZPositionInput) error
+	PTZAbsoluteControl(ctx context.Context, channelID string, in *ipc.PTZAbsoluteInput) error
+	PTZCommand(ctx context.Context, channelID string, in *ipc.PTZCommandInput) error
+	FindPresets(ctx context.Context, channelID string) ([]ipc.PresetItem, error)
+	SetPreset(ctx context.Context, channelID string, in *ipc.SetPresetInput) (*ipc.Preset, error)
+	DelPreset(ctx context.Context, channelID string, presetID int) error
+}
+
+// defaultPTZAutoStop 未配置时云台移动后自动停止的时长
+const defaultPTZAutoStop = 60 * time.Second
+
+// PTZAPI 云台控制接口
+type PTZAPI struct {
+	ptz ptzController
+	// moving 已下发运动指令且未停止的通道，用于一键停止与超时自动停止
+	moving *conc.Map[string, *ptzMotion]
+	// focusing 聚焦/光圈调节中的通道，与方向运动分开记录，停止时互不打断
+	focusing *conc.Map[string, *ptzMotion]
+	autoStop time.Duration
+	// afterFunc 自动停止计时，为空使用 time.AfterFunc，返回取消函数；测试中替换为手动触发
+	afterFunc func(d time.Duration, f func()) (cancel func() bool)
+}
+
+// ptzDirectionFI 聚焦/光圈调节在运动状态中的标识，仅用于日志
+const ptzDirectionFI ipc.PTZDirection = "fi"
+
+// ptzMotion 通道的一次云台运动，新指令到达时整体替换
+type ptzMotion struct {
+	direction ipc.PTZDirection
+	stop      func(ctx context.Context) error // 停止该次运动的指令
+	cancel    func() bool                     // 取消自动停止
+}
+
+// NewPTZAPI 创建云台控制 API
+func NewPTZAPI(bundle IPCBundle, conf *conf.Bootstrap) PTZAPI {
+	core := bundle.Core
+	autoStop := time.Duration(conf.Sip.PTZAutoStop)
+	if autoStop <= 0 {
+		autoStop = defaultPTZAutoStop
+	}
+	return PTZAPI{
+		ptz:      &core,
+		moving:   conc.NewMap[string, *ptzMotion](),
+		focusing: conc.NewMap[string, *ptzMotion](),
+		autoStop: autoStop,
+	}
+}
+
+// RegisterPTZ 注册云台控制路由
+func RegisterPTZ(g gin.IRouter, api PTZAPI, handler ...gin.HandlerFunc) {
+	group := g.Group("", handler...)
+	group.POST("/channels/:id/ptz", web.WrapH(api.ptzControl))                     // 云台控制
+	group.POST("/channels/:id/ptz/fi", web.WrapH(api.ptzFI))                       // 聚焦/光圈持续调节
+	group.POST("/channels/:id/ptz/position", web.WrapH(api.ptzPosition))           // 3D 定位，点击居中/拉框缩放
+	group.POST("/channels/:id/ptz/absolute", web.WrapH(api.ptzAbsolute))           // 转到绝对位置
+	group.POST("/channels/:id/ptz/command", web.WrapH(api.ptzCommand))             // 预置位/巡航/扫描
+	group.GET("/channels/:id/ptz/presets", web.WrapH(api.findPresets))             // 预置位列表，合并设备上报与平台保存的名称
+	group.POST("/channels/:id/ptz/presets", web.WrapH(api.setPreset))              // 保存预置位名称
+	group.PUT("/channels/:id/ptz/presets/:preset_id", web.WrapH(api.editPreset))   // 修改预置位名称
+	group.DELETE("/channels/:id/ptz/presets/:preset_id", web.WrapH(api.delPreset)) // 删除平台保存的预置位名称
+	group.POST("/ptz/stop-all", web.WrapH(api.stopAll))                            // 停止所有运动中的云台
+}
+
+func (a PTZAPI) ptzControl(c *gin.Context, in *ipc.PTZControlInput) (gin.H, error) {
+	channelID := c.Param("id")
+	if err := a.ptz.PTZControl(c.Request.Context(), channelID, in); err != nil {
+		return nil, err
+	}
+	if in.IsStop() {
+		// 全停指令同时停止聚焦/光圈调节
+		a.untrackMotion(a.moving, channelID)
+		a.untrackMotion(a.focusing, channelID)
+	} else {
+		a.trackMotion(a.moving, channelID, in.Direction, func(ctx context.Context) error {
+			return a.ptz.PTZControl(ctx, channelID, &ipc.PTZControlInput{Direction: ipc.PTZStop})
+		})
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+// ptzFI 聚焦/光圈持续调节，运动状态与方向控制分开记录，超时未停止时只下发 FI 停止
+func (a PTZAPI) ptzFI(c *gin.Context, in *ipc.PTZFIInput) (gin.H, error) {
+	channelID := c.Param("id")
+	if err := a.ptz.PTZFIControl(c.Request.Context(), channelID, in); err != nil {
+		return nil, err
+	}
+	if in.IsStop() {
+		a.untrackMotion(a.focusing, channelID)
+	} else {
+		a.trackMotion(a.focusing, channelID, ptzDirectionFI, func(ctx context.Context) error {
+			return a.ptz.PTZFIControl(ctx, channelID, &ipc.PTZFIInput{})
+		})
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+// ptzPosition 3D 定位，设备定位完成后自行停止，无需记录运动状态
+func (a PTZAPI) ptzPosition(c *gin.Context, in *ipc.PTZPositionInput) (gin.H, error) {
+	if err := a.ptz.PTZPosition(c.Request.Context(), c.Param("id"), in); err != nil {
+		return nil, err
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+// ptzAbsolute 转到绝对位置，设备定位完成后自行停止，无需记录运动状态
+func (a PTZAPI) ptzAbsolute(c *gin.Context, in *ipc.PTZAbsoluteInput) (gin.H, error) {
+	if err := a.ptz.PTZAbsoluteControl(c.Request.Context(), c.Param("id"), in); err != nil {
+		return nil, err
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+// ptzCommand 预置位/巡航/扫描，由设备自行完成动作，无需记录运动状态
+func (a PTZAPI) ptzCommand(c *gin.Context, in *ipc.PTZCommandInput) (gin.H, error) {
+	if err := a.ptz.PTZCommand(c.Request.Context(), c.Param("id"), in); err != nil {
+		return nil, err
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+func (a PTZAPI) findPresets(c *gin.Context, _ *struct{}) (gin.H, error) {
+	items, err := a.ptz.FindPresets(c.Request.Context(), c.Param("id"))
+	return gin.H{"items": items}, err
+}
+
+func (a PTZAPI) setPreset(c *gin.Context, in *ipc.SetPresetInput) (*ipc.Preset, error) {
+	return a.ptz.SetPreset(c.Request.Context(), c.Param("id"), in)
+}
+
+type editPresetInput struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+func (a PTZAPI) editPreset(c *gin.Context, in *editPresetInput) (*ipc.Preset, error) {
+	presetID, err := presetIDParam(c)
+	if err != nil {
+		return nil, err
+	}
+	return a.ptz.SetPreset(c.Request.Context(), c.Param("id"), &ipc.SetPresetInput{PresetID: presetID, Name: in.Name})
+}
+
+func (a PTZAPI) delPreset(c *gin.Context, _ *struct{}) (gin.H, error) {
+	presetID, err := presetIDParam(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.ptz.DelPreset(c.Request.Context(), c.Param("id"), presetID); err != nil {
+		return nil, err
+	}
+	return gin.H{"msg": "ok"}, nil
+}
+
+func presetIDParam(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("preset_id"))
+	if err != nil || id < 1 || id > 255 {
+		return 0, ipc.ErrPTZInvalidParam.Withf("invalid preset_id[%s]", c.Param("preset_id"))
+	}
+	return id, nil
+}
+
+// trackMotion 记录通道运动状态，并在超时未收到新指令时自动下发停止
+func (a PTZAPI) trackMotion(motions *conc.Map[string, *ptzMotion], channelID string, direction ipc.PTZDirection, stop func(ctx context.Context) error) {
+	m := ptzMotion{direction: direction, stop: stop}
+	m.cancel = a.after(func() { a.autoStopMotion(motions, channelID, &m) })
+	if prev, ok := motions.Swap(channelID, &m); ok {
+		prev.cancel()
+	}
+}
+
+// after 在 autoStop 时长后执行 f
+func (a PTZAPI) after(f func()) func() bool {
+	if a.afterFunc != nil {
+		return a.afterFunc(a.autoStop, f)
+	}
+	return time.AfterFunc(a.autoStop, f).Stop
+}
+
+// untrackMotion 通道已停止，清除运动状态并取消自动停止
+func (a PTZAPI) untrackMotion(motions *conc.Map[string, *ptzMotion], channelID string) {
+	if m, ok := motions.LoadAndDelete(channelID); ok {
+		m.cancel()
+	}
+}
+
+// autoStopMotion 仅当通道仍处于该次运动时才停止，期间收到新指令则由新指令重新计时
+func (a PTZAPI) autoStopMotion(motions *conc.Map[string, *ptzMotion], channelID string, m *ptzMotion) {
+	if !motions.CompareAndDelete(channelID, m) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.stop(ctx); err != nil {
+		slog.ErrorContext(ctx, "ptz auto stop failed", "channel_id", channelID, "direction", m.direction, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "ptz auto stop", "channel_id", channelID, "direction", m.direction, "after", a.autoStop)
+}
+
+type ptzStopFailed struct {
+	ID  string `json:"id"`
+	Msg string `json:"msg"`
+}
+
+type stopAllOutput struct {
+	Stopped []string        `json:"stopped"`
+	Failed  []ptzStopFailed `json:"failed"`
+}
+
+// stopAll 向所有运动中的通道下发停止指令，失败的通道保留运动状态以便重试
+func (a PTZAPI) stopAll(c *gin.Context, _ *struct{}) (*stopAllOutput, error) {
+	ctx := c.Request.Context()
+	out := stopAllOutput{Stopped: make([]string, 0, 8), Failed: make([]ptzStopFailed, 0)}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, motions := range []*conc.Map[string, *ptzMotion]{a.moving, a.focusing} {
+		motions.Range(func(id string, m *ptzMotion) bool {
+			wg.Go(func() {
+				err := m.stop(ctx)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					slog.ErrorContext(ctx, "ptz stop failed", "channel_id", id, "direction", m.direction, "err", err)
+					out.Failed = append(out.Failed, ptzStopFailed{ID: id, Msg: err.Error()})
+					return
+				}
+				if motions.CompareAndDelete(id, m) {
+					m.cancel()
+				}
+				out.Stopped = append(out.Stopped, id)
+			})
+			return true
+		})
+	}
+	wg.Wait()
+
+	// 同一通道可能同时在转动与调焦
+	slices.Sort(out.Stopped)
+	out.Stopped = slices.Compact(out.Stopped)
+	slices.SortFunc(out.Failed, func(a, b ptzStopFailed) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return &out, nil
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+type fakePTZ struct {
+	mu    sync.Mutex
+	calls map[string][]ipc.PTZControlInput
+	fail  map[string]error
+	fi    []ipc.PTZFIInput
+}
+
+func (f *fakePTZ) PTZControl(_ context.Context, channelID string, in *ipc.PTZControlInput) error {
+	if err := f.fail[channelID]; err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[channelID] = append(f.calls[channelID], *in)
+	return nil
+}
+
+func (f *fakePTZ) PTZFIControl(_ context.Context, channelID string, in *ipc.PTZFIInput) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fi = append(f.fi, *in)
+	return nil
+}
+
+func (f *fakePTZ) PTZPosition(context.Context, string, *ipc.PTZPositionInput) error {
+	return nil
+}
+
+func (f *fakePTZ) PTZAbsoluteControl(context.Context, string, *ipc.PTZAbsoluteInput) error {
+	return nil
+}
+
+func (f *fakePTZ) PTZCommand(_ context.Context, _ string, in *ipc.PTZCommandInput) error {
+	_, err := gbs.PTZCommandCmd(in)
+	return err
+}
+
+func (f *fakePTZ) FindPresets(context.Context, string) ([]ipc.PresetItem, error) {
+	return nil, nil
+}
+
+func (f *fakePTZ) SetPreset(context.Context, string, *ipc.SetPresetInput) (*ipc.Preset, error) {
+	return nil, nil
+}
+
+func (f *fakePTZ) DelPreset(context.Context, string, int) error {
+	return nil
+}
+
+func (f *fakePTZ) called(channelID string) []ipc.PTZControlInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls[channelID])
+}
+
+// manualTimers 手动触发的自动停止计时器，按注册顺序编号
+type manualTimers struct {
+	mu     sync.Mutex
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	f        func()
+	canceled bool
+}
+
+func (m *manualTimers) afterFunc(_ time.Duration, f func()) func() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &manualTimer{f: f}
+	m.timers = append(m.timers, t)
+	return func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		active := !t.canceled
+		t.canceled = true
+		return active
+	}
+}
+
+// fire 触发第 i 个计时器，不检查是否已取消，模拟取消前计时器已到期的竞争
+func (m *manualTimers) fire(i int) {
+	m.mu.Lock()
+	t := m.timers[i]
+	m.mu.Unlock()
+	t.f()
+}
+
+func (m *manualTimers) isCanceled(i int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.timers[i].canceled
+}
+
+func newTestPTZAPI(ptz ptzController) (PTZAPI, *gin.Engine, *manualTimers) {
+	timers := &manualTimers{}
+	api := PTZAPI{
+		ptz:       ptz,
+		moving:    conc.NewMap[string, *ptzMotion](),
+		focusing:  conc.NewMap[string, *ptzMotion](),
+		autoStop:  time.Minute,
+		afterFunc: timers.afterFunc,
+	}
+	r := gin.New()
+	RegisterPTZ(r, api)
+	return api, r, timers
+}
+
+func postPTZ(r *gin.Engine, id, body string) int {
+	req := httptest.NewRequest(http.MethodPost, "/channels/"+id+"/ptz", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestPTZStopAll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ptz := &fakePTZ{
+		calls: make(map[string][]ipc.PTZControlInput),
+		fail:  map[string]error{"fixed": ipc.ErrPTZNotSupported},
+	}
+	api, r, _ := newTestPTZAPI(ptz)
+	control := func(id, body string) int { return postPTZ(r, id, body) }
+	control("ch1", `{"direction":"left","speed":100}`)
+	control("ch2", `{"direction":"zoom_in","speed":50}`)
+	control("ch3", `{"direction":"up","speed":100}`)
+	control("ch3", `{"direction":"stop"}`)
+	control("ch4", `{"direction":"up","speed":0}`)
+	if code := control("fixed", `{"direction":"up","speed":100}`); code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for non ptz channel, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ptz/stop-all", nil))
+	var out stopAllOutput
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	// 速度为 0 表示使用默认速度，仍处于运动中
+	if strings.Join(out.Stopped, ",") != "ch1,ch2,ch4" || len(out.Failed) != 0 {
+		t.Fatalf("unexpected stop-all result %s", w.Body.String())
+	}
+	for _, id := range []string{"ch1", "ch2", "ch4"} {
+		calls := ptz.calls[id]
+		if last := calls[len(calls)-1]; last.Direction != ipc.PTZStop {
+			t.Fatalf("%s expect stop, got %s", id, last.Direction)
+		}
+	}
+	if n := len(ptz.calls["ch3"]); n != 2 {
+		t.Fatalf("stopped channel should not receive another stop, got %d calls", n)
+	}
+	if api.moving.Len() != 0 {
+		t.Fatalf("expect motion state cleared, got %v", api.moving.Keys())
+	}
+}
+
+func TestPTZAutoStop(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ptz := &fakePTZ{calls: make(map[string][]ipc.PTZControlInput)}
+	api, r, timers := newTestPTZAPI(ptz)
+
+	postPTZ(r, "ch1", `{"direction":"left","speed":100}`)
+	// ch2 在超时前收到新指令，应重新计时
+	postPTZ(r, "ch2", `{"direction":"up","speed":100}`)
+	postPTZ(r, "ch2", `{"direction":"down","speed":100}`)
+	if !timers.isCanceled(1) {
+		t.Fatal("expect ch2 first timer canceled by new command")
+	}
+	// 旧计时器即使已到期也不能停止新的运动
+	timers.fire(1)
+	timers.fire(0)
+
+	if calls := ptz.called("ch1"); len(calls) != 2 || calls[1].Direction != ipc.PTZStop {
+		t.Fatalf("expect ch1 auto stopped, got %v", calls)
+	}
+	if calls := ptz.called("ch2"); len(calls) != 2 {
+		t.Fatalf("expect ch2 still moving, got %v", calls)
+	}
+
+	timers.fire(2)
+	if calls := ptz.called("ch2"); len(calls) != 3 || calls[2].Direction != ipc.PTZStop {
+		t.Fatalf("expect ch2 auto stopped after last command, got %v", calls)
+	}
+	if api.moving.Len() != 0 {
+		t.Fatalf("expect motion state cleared, got %v", api.moving.Keys())
+	}
+}
+
+func TestPTZFIAutoStop(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ptz := &fakePTZ{calls: make(map[string][]ipc.PTZControlInput)}
+	api, r, timers := newTestPTZAPI(ptz)
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/channels/ch1/ptz/fi", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(`{"focus":-20,"iris":10}`); code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", code)
+	}
+	if _, ok := api.focusing.Load("ch1"); !ok {
+		t.Fatal("expect focus/iris adjustment tracked")
+	}
+	timers.fire(0)
+	// 自动停止只下发 FI 停止，不能用全停打断方向转动
+	if calls := ptz.called("ch1"); len(calls) != 0 {
+		t.Fatalf("expect no direction stop for focus/iris adjustment, got %v", calls)
+	}
+	ptz.mu.Lock()
+	last := ptz.fi[len(ptz.fi)-1]
+	ptz.mu.Unlock()
+	if !last.IsStop() {
+		t.Fatalf("expect focus/iris auto stop, got %+v", last)
+	}
+
+	post(`{"focus":5}`)
+	post(`{"focus":0,"iris":0}`)
+	if api.focusing.Len() != 0 {
+		t.Fatalf("expect focus state cleared by stop, got %v", api.focusing.Keys())
+	}
+	if n := len(ptz.fi); n != 4 {
+		t.Fatalf("expect 4 focus/iris commands, got %d", n)
+	}
+}
+
+func TestPTZFIStopKeepsPan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ptz := &fakePTZ{calls: make(map[string][]ipc.PTZControlInput)}
+	api, r, _ := newTestPTZAPI(ptz)
+
+	postFI := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/channels/ch1/ptz/fi", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	postPTZ(r, "ch1", `{"direction":"left","speed":100}`)
+	postFI(`{"focus":10}`)
+	postFI(`{"focus":0,"iris":0}`)
+	if _, ok := api.moving.Load("ch1"); !ok {
+		t.Fatal("focus/iris stop should keep pan auto stop timer")
+	}
+	if api.focusing.Len() != 0 {
+		t.Fatalf("expect focus state cleared, got %v", api.focusing.Keys())
+	}
+
+	// 全停同时清除两类运动状态
+	postFI(`{"iris":5}`)
+	postPTZ(r, "ch1", `{"direction":"stop"}`)
+	if api.moving.Len() != 0 || api.focusing.Len() != 0 {
+		t.Fatalf("expect all motion state cleared, got %v %v", api.moving.Keys(), api.focusing.Keys())
+	}
+}
+
+func TestPTZCommand(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, r, _ := newTestPTZAPI(&fakePTZ{calls: make(map[string][]ipc.PTZControlInput)})
+
+	post := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/channels/ch1/ptz/command", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out struct {
+			Reason string `json:"reason"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out.Reason
+	}
+
+	if code, _ := post(`{"kind":"cruise","action":"start","id":1}`); code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", code)
+	}
+	for _, body := range []string{
+		`{"kind":"preset","action":"call","id":256}`,
+		`{"kind":"cruise","action":"speed","id":1,"value":4096}`,
+		`{"kind":"scan","action":"start","id":300}`,
+	} {
+		if code, reason := post(body); code != http.StatusBadRequest || reason != "ErrPTZInvalidParam" {
+			t.Fatalf("%s: expect 400 ErrPTZInvalidParam, got %d %s", body, code, reason)
+		}
+	}
+}
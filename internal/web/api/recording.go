@@ -1,16 +1,20 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/bz"
+	"github.com/gowvp/owl/internal/core/event"
 	"github.com/gowvp/owl/internal/core/recording"
 	"github.com/gowvp/owl/internal/core/recording/store/recordingdb"
 	"github.com/grafov/m3u8"
@@ -32,10 +36,16 @@ func NewRecordingStore(db *gorm.DB) recording.Storer {
 
 // NewRecordingCore 创建录像管理核心服务
 // 依赖 recording.SMSProvider 接口而非 sms.Core，避免循环依赖
-func NewRecordingCore(store recording.Storer, cfg *conf.Bootstrap, provider recording.SMSProvider) recording.Core {
+// 存储告警写入事件列表，与 AI 事件一同展示
+func NewRecordingCore(store recording.Storer, cfg *conf.Bootstrap, provider recording.SMSProvider, channels recording.ChannelProvider, eventCore event.Core) recording.Core {
 	core := recording.NewCore(store,
 		recording.WithConfig(&cfg.Server.Recording),
 		recording.WithSMSProvider(provider),
+		recording.WithChannelProvider(channels),
+		recording.WithEventTable(new(event.Event).TableName()),
+		recording.WithStorageAlertHandler(func(ctx context.Context, alert recording.StorageAlert) {
+			addStorageAlertEvent(ctx, eventCore, alert)
+		}),
 	)
 
 	// 启动清理协程
@@ -44,22 +54,48 @@ func NewRecordingCore(store recording.Storer, cfg *conf.Bootstrap, provider reco
 	return core
 }
 
+// storageAlertModel 存储告警事件的模型名，标签为告警原因
+const storageAlertModel = "recording_storage"
+
+// addStorageAlertEvent 存储告警持久化为事件，告警详情（含可用空间）存放在 zones
+func addStorageAlertEvent(ctx context.Context, eventCore event.Core, alert recording.StorageAlert) {
+	detail, _ := json.Marshal(alert)
+	now := orm.Time{Time: alert.Since}
+	if _, err := eventCore.AddEvent(ctx, &event.AddEventInput{
+		CID:       alert.CID,
+		StartedAt: now,
+		EndedAt:   now,
+		Label:     alert.Reason,
+		Zones:     string(detail),
+		Model:     storageAlertModel,
+	}); err != nil {
+		slog.ErrorContext(ctx, "storage alert: save event failed", "reason", alert.Reason, "err", err)
+	}
+}
+
 func NewRecordingAPI(core recording.Core, conf *conf.Bootstrap) RecordingAPI {
 	return RecordingAPI{recordingCore: core, conf: conf}
 }
 
+// nodeRecordingsPrefix 其它媒体节点录像的静态访问前缀，后接节点 ID
+const nodeRecordingsPrefix = "/static/recordings-nodes/"
+
 func RegisterRecording(g gin.IRouter, api RecordingAPI, handler ...gin.HandlerFunc) {
 	{
 		group := g.Group("/recordings", handler...)
 		group.GET("", web.WrapH(api.findRecordings))
 		group.GET("/timeline", web.WrapH(api.getTimeline))
 		group.GET("/monthly", web.WrapH(api.getMonthlyStats))
+		// 多通道同步回放，返回各通道播放列表及相对请求开始时间的偏移
+		group.GET("/sync", web.WrapH(api.syncPlayback))
+		group.POST("/export-zip", api.exportRecordingsZip) // 多个录像打包下载
 		// HLS 播放列表（根据通道 ID 和时间范围生成 m3u8）
 		group.GET("/channels/:cid/index.m3u8", api.channelPlaylist)
 		group.GET("/:id", web.WrapH(api.getRecording))
 		group.PUT("/:id", web.WrapH(api.editRecording))
 		group.DELETE("/:id", web.WrapH(api.delRecording))
 		group.GET("/:id/download", api.downloadRecording)
+		group.GET("/:id/codec", web.WrapH(api.getRecordingCodec))
 	}
 
 	// 静态文件服务，用于访问录像 MP4 文件
@@ -69,11 +105,25 @@ func RegisterRecording(g gin.IRouter, api RecordingAPI, handler ...gin.HandlerFu
 		slog.Info("注册录像静态文件服务", "path", "/static/recordings", "dir", api.conf.Server.Recording.StorageDir)
 		g.Static("/static/recordings", api.conf.Server.Recording.StorageDir)
 	}
+	// 其它媒体节点的录像，路径格式: /static/recordings-nodes/<节点ID>/xxx.mp4
+	if api.conf != nil {
+		for id, dir := range api.conf.Server.Recording.NodeStorageDirs {
+			if id == "" || dir == "" {
+				continue
+			}
+			slog.Info("注册节点录像静态文件服务", "path", nodeRecordingsPrefix+id, "dir", dir)
+			g.Static(nodeRecordingsPrefix+id, dir)
+		}
+	}
 }
 
 // findRecordings 分页查询录像列表
 func (a RecordingAPI) findRecordings(c *gin.Context, in *recording.FindRecordingInput) (any, error) {
 	items, total, err := a.recordingCore.FindRecordings(c.Request.Context(), in)
+	token := c.Query("token")
+	for _, item := range items {
+		item.ThumbnailURL = a.thumbnailURI(item, token)
+	}
 	return gin.H{"items": items, "total": total}, err
 }
 
@@ -85,7 +135,18 @@ func (a RecordingAPI) getTimeline(c *gin.Context, in *recording.TimelineInput) (
 
 func (a RecordingAPI) getRecording(c *gin.Context, _ *struct{}) (*recording.Recording, error) {
 	recordingID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
-	return a.recordingCore.GetRecording(c.Request.Context(), recordingID)
+	rec, err := a.recordingCore.GetRecording(c.Request.Context(), recordingID)
+	if err != nil {
+		return nil, err
+	}
+	rec.ThumbnailURL = a.thumbnailURI(rec, c.Query("token"))
+	return rec, nil
+}
+
+// getRecordingCodec 探测录像文件编码，前端据此选择播放器或请求纯视频版本
+func (a RecordingAPI) getRecordingCodec(c *gin.Context, _ *struct{}) (*bz.StreamCodec, error) {
+	recordingID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	return a.recordingCore.GetRecordingCodec(c.Request.Context(), recordingID)
 }
 
 func (a RecordingAPI) editRecording(c *gin.Context, in *recording.EditRecordingInput) (*recording.Recording, error) {
@@ -103,6 +164,33 @@ func (a RecordingAPI) getMonthlyStats(c *gin.Context, in *recording.MonthlyStats
 	return a.recordingCore.GetMonthlyStats(c.Request.Context(), in)
 }
 
+// syncPlayback 多通道按墙上时间同步回放
+// 路径: /recordings/sync?cids=a,b,c&start_ms=xxx&end_ms=xxx&token=xxx&format=fmp4
+func (a RecordingAPI) syncPlayback(c *gin.Context, in *recording.SyncPlaybackInput) (any, error) {
+	items, err := a.recordingCore.SyncPlayback(c.Request.Context(), in)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].URL = syncPlaylistURL(items[i].CID, in.DateFilter, c.Query("token"), c.Query("format"))
+	}
+	return gin.H{"start_ms": in.StartMs, "end_ms": in.EndMs, "items": items}, nil
+}
+
+// syncPlaylistURL 构造与同步偏移对应的通道播放列表地址，时间范围必须与计算偏移时一致
+func syncPlaylistURL(cid string, date web.DateFilter, token, format string) string {
+	q := url.Values{}
+	q.Set("start_ms", strconv.FormatInt(date.StartMs, 10))
+	q.Set("end_ms", strconv.FormatInt(date.EndMs, 10))
+	if token != "" {
+		q.Set("token", token)
+	}
+	if format != "" {
+		q.Set("format", format)
+	}
+	return fmt.Sprintf("/recordings/channels/%s/index.m3u8?%s", url.PathEscape(cid), q.Encode())
+}
+
 // downloadRecording 下载录像文件
 func (a RecordingAPI) downloadRecording(c *gin.Context) {
 	recordingID, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -118,7 +206,7 @@ func (a RecordingAPI) downloadRecording(c *gin.Context) {
 	}
 
 	// 构建文件完整路径
-	filePath := a.recordingCore.GetFullPath(rec.Path)
+	filePath := a.recordingCore.RecordingPath(rec)
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "recording file not found"})
 		return
@@ -127,12 +215,33 @@ func (a RecordingAPI) downloadRecording(c *gin.Context) {
 	// 设置下载文件名
 	fileName := filepath.Base(filePath)
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-	c.File(filePath)
+	serveRecordingFile(c, filePath)
+}
+
+// serveRecordingFile 以 http.ServeContent 输出录像文件，支持 Range/If-Range
+// 浏览器可据此拖动进度条与断点续传，大文件无需整体下载
+func serveRecordingFile(c *gin.Context, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "recording file not found"})
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "recording file not found"})
+		return
+	}
+	if filepath.Ext(path) == ".mp4" {
+		c.Header("Content-Type", "video/mp4")
+	}
+	http.ServeContent(c.Writer, c.Request, fi.Name(), fi.ModTime(), f)
 }
 
 // channelPlaylist 生成 HLS m3u8 播放列表
 // 根据通道 ID 和时间范围，动态生成包含多个 MP4 片段的 m3u8 文件
-// 路径: /recordings/channels/:cid/index.m3u8?start_ms=xxx&end_ms=xxx&token=xxx
+// 路径: /recordings/channels/:cid/index.m3u8?start_ms=xxx&end_ms=xxx&token=xxx&format=fmp4
+// format=fmp4 时若录像以 fMP4 存储则输出带初始化段的原生 fMP4 列表，否则回退 MP4 模式
 func (a RecordingAPI) channelPlaylist(c *gin.Context) {
 	cid := c.Param("cid")
 	if cid == "" {
@@ -173,7 +282,16 @@ func (a RecordingAPI) channelPlaylist(c *gin.Context) {
 	baseURL := fmt.Sprintf("%s://%s", scheme, c.Request.Host)
 
 	// 生成 m3u8 内容（带 token）
-	m3u8Content := a.generateM3U8WithToken(recordings, baseURL, token)
+	var m3u8Content string
+	var ok bool
+	if c.Query("format") == playlistFormatFMP4 {
+		if m3u8Content, ok = a.generateFMP4M3U8(recordings, token); !ok {
+			slog.Debug("录像不是 fMP4 格式，回退 MP4 播放列表", "cid", cid)
+		}
+	}
+	if !ok {
+		m3u8Content = a.generateM3U8WithToken(recordings, baseURL, token)
+	}
 
 	c.Header("Content-Type", "application/vnd.apple.mpegurl")
 	c.Header("Cache-Control", "no-cache")
@@ -209,8 +327,8 @@ func (a RecordingAPI) generateM3U8WithToken(recordings []*recording.Recording, b
 	}
 
 	// 添加每个录像片段
-	// URL 格式: /static/recordings/{path}?token=xxx
-	// 使用相对路径（以 / 开头），让浏览器相对于当前域名访问
+	// URL 格式: [PublicBaseURL]/static/recordings/{path}?token=xxx
+	// 未配置 PublicBaseURL 时使用相对路径（以 / 开头），让浏览器相对于当前域名访问
 	// 这样无论通过代理还是直接访问都能正常工作
 	// ZLM 录制的 fMP4 每个文件 DTS 都从 0 开始，必须在每个片段间添加 DISCONTINUITY
 	// 告诉 HLS.js 重置解码器，避免 DTS 不连续导致的解析错误
@@ -221,18 +339,9 @@ func (a RecordingAPI) generateM3U8WithToken(recordings []*recording.Recording, b
 			pl.SetDiscontinuity()
 		}
 
-		// 构建相对路径，去掉前导斜杠
-		relativePath := strings.TrimPrefix(rec.Path, "/")
-
 		// 使用相对路径（不带域名），让浏览器根据当前页面域名访问
 		// 这样开发时通过 Vite 代理、生产时通过后端都能正常访问
-		var uri string
-		if token != "" {
-			uri = fmt.Sprintf("/static/recordings/%s?token=%s", relativePath, token)
-		} else {
-			uri = fmt.Sprintf("/static/recordings/%s", relativePath)
-		}
-		_ = pl.Append(uri, rec.Duration, "")
+		_ = pl.Append(a.recordingURI(rec, token), rec.Duration, "")
 	}
 
 	// 关闭播放列表，添加 #EXT-X-ENDLIST 标签
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestExportRecordingsZip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	db := newTestDB(t)
+	api := newTestRecordingAPI(db, dir)
+	ctx := context.Background()
+
+	if err := os.MkdirAll(filepath.Join(dir, "c1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	files := map[string]string{"c1/a.mp4": "aaaa", "c1/b.mp4": "bbbbbb", "c1/d.mp4": "dd"}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := make([]int64, 0, 4)
+	for i, path := range []string{"c1/b.mp4", "c1/a.mp4", "c1/missing.mp4", "c1/d.mp4"} {
+		// a 的开始时间早于 b，导出按时间排序
+		start := base.Add(time.Duration(4-i) * time.Minute)
+		rec, err := api.recordingCore.AddRecording(ctx, &recording.AddRecordingInput{
+			CID: "c1", Path: path, Duration: 60,
+			StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(time.Minute)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, rec.ID)
+	}
+	// d 已标记待删除，不参与导出
+	if err := db.Model(&recording.Recording{}).Where("id = ?", ids[3]).Update("delete_flag", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/recordings/export-zip", api.exportRecordingsZip)
+
+	body, _ := json.Marshal(recording.ExportZipInput{IDs: ids})
+	req := httptest.NewRequest(http.MethodPost, "/recordings/export-zip", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(b)
+	}
+	if len(contents) != 3 {
+		t.Fatalf("expect 2 files and manifest, got %v", zr.File)
+	}
+
+	var manifest recordingManifest
+	if err := json.Unmarshal([]byte(contents[recordingManifestName]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Items) != 3 {
+		t.Fatalf("expect 3 manifest items, got %+v", manifest.Items)
+	}
+	// 按开始时间升序：missing(6分) < a(7分) < b(8分)
+	missing, a, b := manifest.Items[0], manifest.Items[1], manifest.Items[2]
+	if !missing.Missing || missing.File != "" || missing.ID != ids[2] {
+		t.Fatalf("unexpected missing entry %+v", missing)
+	}
+	for _, tc := range []struct {
+		entry recordingManifestEntry
+		id    int64
+		body  string
+	}{{a, ids[1], "aaaa"}, {b, ids[0], "bbbbbb"}} {
+		if tc.entry.ID != tc.id || tc.entry.CID != "c1" || tc.entry.Missing {
+			t.Fatalf("unexpected entry %+v", tc.entry)
+		}
+		if !strings.HasPrefix(tc.entry.File, "c1/") || contents[tc.entry.File] != tc.body {
+			t.Fatalf("file %q content mismatch", tc.entry.File)
+		}
+		if !tc.entry.EndedAt.Time.Equal(tc.entry.StartedAt.Time.Add(time.Minute)) {
+			t.Fatalf("unexpected timestamps %+v", tc.entry)
+		}
+	}
+
+	// 缺少导出范围
+	req = httptest.NewRequest(http.MethodPost, "/recordings/export-zip", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400, got %d", w.Code)
+	}
+}
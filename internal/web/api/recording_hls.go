@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/grafov/m3u8"
+)
+
+// playlistFormatFMP4 录像播放列表 format 参数，请求原生 fMP4 HLS
+const playlistFormatFMP4 = "fmp4"
+
+// errNotFragmented 文件不是 fMP4（没有 moof 片段）
+var errNotFragmented = errors.New("not fragmented mp4")
+
+// fmp4Layout fMP4 文件的分段信息，[0,InitSize) 为初始化段，其后为媒体片段
+type fmp4Layout struct {
+	InitSize int64
+	Size     int64
+}
+
+// probeFMP4 扫描顶层 box，判断录像是否以 fMP4 存储并计算初始化段长度
+// 流媒体开启 record.enableFmp4 后，文件由 ftyp+moov 与若干 moof+mdat 组成
+func probeFMP4(path string) (fmp4Layout, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmp4Layout{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmp4Layout{}, err
+	}
+	size := fi.Size()
+
+	var offset, moovEnd int64
+	header := make([]byte, 16)
+	// 正常文件在前几个 box 内就会出现 moof，限制次数避免扫描异常文件
+	for range 64 {
+		if offset+8 > size {
+			break
+		}
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return fmp4Layout{}, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return fmp4Layout{}, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if boxSize < 8 || offset+boxSize > size {
+			return fmp4Layout{}, io.ErrUnexpectedEOF
+		}
+
+		switch boxType {
+		case "moov":
+			moovEnd = offset + boxSize
+		case "moof":
+			if moovEnd == 0 {
+				return fmp4Layout{}, errNotFragmented
+			}
+			return fmp4Layout{InitSize: moovEnd, Size: size}, nil
+		case "mdat":
+			// 普通 MP4 的 mdat 不在 moof 之后，可直接判定
+			if moovEnd == 0 || offset < moovEnd {
+				return fmp4Layout{}, errNotFragmented
+			}
+		}
+		offset += boxSize
+	}
+	return fmp4Layout{}, errNotFragmented
+}
+
+// recordingURI 录像文件的静态访问地址，未配置 PublicBaseURL 时使用相对路径让浏览器基于当前域名访问
+// 其它节点产生的录像走该节点的静态目录
+// 配置 PublicBaseURL 时指向 CDN/对象存储，路径与本机一致，token 原样透传，由回源到本服务或 CDN 鉴权校验
+func (a RecordingAPI) recordingURI(rec *recording.Recording, token string) string {
+	return a.recordingFileURI(rec, rec.Path, token)
+}
+
+// thumbnailURI 录像封面图的访问地址，与录像文件同目录，无封面时返回空
+func (a RecordingAPI) thumbnailURI(rec *recording.Recording, token string) string {
+	if rec.Thumbnail == "" {
+		return ""
+	}
+	return a.recordingFileURI(rec, rec.Thumbnail, token)
+}
+
+// recordingFileURI 录像目录下文件的访问地址，前缀规则见 recordingURI
+func (a RecordingAPI) recordingFileURI(rec *recording.Recording, path, token string) string {
+	prefix := "/static/recordings"
+	if _, ok := a.recordingCore.NodeStorageDir(rec.MediaServerID); ok {
+		prefix = nodeRecordingsPrefix + rec.MediaServerID
+	}
+	if a.conf != nil {
+		prefix = strings.TrimSuffix(a.conf.Server.Recording.PublicBaseURL, "/") + prefix
+	}
+	relativePath := strings.TrimPrefix(path, "/")
+	if token != "" {
+		return fmt.Sprintf("%s/%s?token=%s", prefix, relativePath, token)
+	}
+	return fmt.Sprintf("%s/%s", prefix, relativePath)
+}
+
+// generateFMP4M3U8 以原生 fMP4 HLS 形式输出播放列表
+// 每个录像文件通过 EXT-X-MAP 字节范围声明自己的初始化段，媒体部分作为一个片段，
+// 播放器无需把整个 MP4 当作未知容器重新探测；文件之间时间轴各自从 0 开始，仍需 DISCONTINUITY
+// 任一文件不是 fMP4 时返回 false，由调用方回退到 MP4 模式
+func (a RecordingAPI) generateFMP4M3U8(recordings []*recording.Recording, token string) (string, bool) {
+	if len(recordings) == 0 {
+		return "", false
+	}
+	sorted := slices.Clone(recordings)
+	slices.SortStableFunc(sorted, func(x, y *recording.Recording) int {
+		return x.StartedAt.Compare(y.StartedAt.Time)
+	})
+
+	pl, err := m3u8.NewMediaPlaylist(0, uint(len(sorted)))
+	if err != nil {
+		return "", false
+	}
+	pl.MediaType = m3u8.VOD
+	// EXT-X-MAP 用于非 I 帧播放列表要求协议版本 6
+	pl.SetVersion(6)
+
+	for i, rec := range sorted {
+		layout, err := probeFMP4(a.recordingCore.RecordingPath(rec))
+		if err != nil {
+			return "", false
+		}
+		uri := a.recordingURI(rec, token)
+		if err := pl.Append(uri, rec.Duration, ""); err != nil {
+			return "", false
+		}
+		_ = pl.SetMap(uri, layout.InitSize, 0)
+		_ = pl.SetRange(layout.Size-layout.InitSize, layout.InitSize)
+		if i > 0 {
+			_ = pl.SetDiscontinuity()
+		}
+	}
+	pl.Close()
+	return pl.String(), true
+}
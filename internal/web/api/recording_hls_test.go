@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func mp4Box(typ string, payload int) []byte {
+	b := make([]byte, 8+payload)
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	copy(b[4:], typ)
+	return b
+}
+
+func writeMP4(t *testing.T, path string, boxes ...[]byte) {
+	t.Helper()
+	var data []byte
+	for _, b := range boxes {
+		data = append(data, b...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newPlaylistTestAPI(t *testing.T) (RecordingAPI, string) {
+	dir := t.TempDir()
+	core := recording.NewCore(nil, recording.WithConfig(&conf.ServerRecording{StorageDir: dir}))
+	return RecordingAPI{recordingCore: core}, dir
+}
+
+func TestProbeFMP4(t *testing.T) {
+	dir := t.TempDir()
+
+	fragmented := filepath.Join(dir, "f.mp4")
+	writeMP4(t, fragmented, mp4Box("ftyp", 8), mp4Box("moov", 32), mp4Box("moof", 16), mp4Box("mdat", 64), mp4Box("moof", 16), mp4Box("mdat", 64))
+	layout, err := probeFMP4(fragmented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.InitSize != 16+40 || layout.Size != 16+40+24+72+24+72 {
+		t.Fatalf("unexpected layout %+v", layout)
+	}
+
+	plain := filepath.Join(dir, "p.mp4")
+	writeMP4(t, plain, mp4Box("ftyp", 8), mp4Box("moov", 32), mp4Box("mdat", 64))
+	if _, err := probeFMP4(plain); err == nil {
+		t.Fatal("plain mp4 should not be detected as fmp4")
+	}
+}
+
+func TestGeneratePlaylist(t *testing.T) {
+	api, dir := newPlaylistTestAPI(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recs := []*recording.Recording{
+		{Path: "c1/b.mp4", Duration: 10, StartedAt: orm.Time{Time: base.Add(10 * time.Second)}},
+		{Path: "c1/a.mp4", Duration: 10, StartedAt: orm.Time{Time: base}},
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "c1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs {
+		writeMP4(t, filepath.Join(dir, rec.Path), mp4Box("ftyp", 8), mp4Box("moov", 32), mp4Box("moof", 16), mp4Box("mdat", 64))
+	}
+
+	t.Run("fmp4", func(t *testing.T) {
+		out, ok := api.generateFMP4M3U8(recs, "tk")
+		if !ok {
+			t.Fatal("expect fmp4 playlist")
+		}
+		for _, line := range []string{
+			"#EXT-X-VERSION:6",
+			`#EXT-X-MAP:URI="/static/recordings/c1/a.mp4?token=tk",BYTERANGE=56@0`,
+			"#EXT-X-BYTERANGE:96@56",
+			"#EXT-X-ENDLIST",
+		} {
+			if !strings.Contains(out, line) {
+				t.Fatalf("playlist missing %q:\n%s", line, out)
+			}
+		}
+		if strings.Index(out, "c1/a.mp4") > strings.Index(out, "c1/b.mp4") {
+			t.Fatalf("segments should be sorted by start time:\n%s", out)
+		}
+		if n := strings.Count(out, "#EXT-X-DISCONTINUITY"); n != 1 {
+			t.Fatalf("expect 1 discontinuity, got %d:\n%s", n, out)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		writeMP4(t, filepath.Join(dir, "c1/b.mp4"), mp4Box("ftyp", 8), mp4Box("moov", 32), mp4Box("mdat", 64))
+		if _, ok := api.generateFMP4M3U8(recs, ""); ok {
+			t.Fatal("plain mp4 recordings should fall back")
+		}
+	})
+
+	t.Run("mp4", func(t *testing.T) {
+		out := api.generateM3U8WithToken(recs, "", "")
+		if strings.Contains(out, "#EXT-X-MAP") || strings.Contains(out, "#EXT-X-BYTERANGE") {
+			t.Fatalf("mp4 playlist should not use init segment:\n%s", out)
+		}
+		for _, line := range []string{"/static/recordings/c1/a.mp4", "/static/recordings/c1/b.mp4", "#EXT-X-DISCONTINUITY"} {
+			if !strings.Contains(out, line) {
+				t.Fatalf("playlist missing %q:\n%s", line, out)
+			}
+		}
+	})
+}
+
+func TestRecordingURIPublicBaseURL(t *testing.T) {
+	api, _ := newPlaylistTestAPI(t)
+	rec := &recording.Recording{Path: "/c1/a.mp4"}
+
+	tests := []struct {
+		name    string
+		baseURL string
+		token   string
+		expect  string
+	}{
+		{name: "relative", expect: "/static/recordings/c1/a.mp4"},
+		{name: "relative with token", token: "tk", expect: "/static/recordings/c1/a.mp4?token=tk"},
+		{name: "cdn", baseURL: "https://cdn.example.com", token: "tk", expect: "https://cdn.example.com/static/recordings/c1/a.mp4?token=tk"},
+		{name: "cdn trailing slash", baseURL: "https://cdn.example.com/owl/", expect: "https://cdn.example.com/owl/static/recordings/c1/a.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bc conf.Bootstrap
+			bc.Server.Recording.PublicBaseURL = tt.baseURL
+			api.conf = &bc
+			if got := api.recordingURI(rec, tt.token); got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+
+	api.conf.Server.Recording.PublicBaseURL = "https://cdn.example.com"
+	out := api.generateM3U8WithToken([]*recording.Recording{rec}, "", "tk")
+	if !strings.Contains(out, "https://cdn.example.com/static/recordings/c1/a.mp4?token=tk") {
+		t.Fatalf("playlist should use public base url:\n%s", out)
+	}
+}
+
+func TestThumbnailURI(t *testing.T) {
+	api, _ := newPlaylistTestAPI(t)
+	if got := api.thumbnailURI(&recording.Recording{Path: "/c1/a.mp4"}, "tk"); got != "" {
+		t.Fatalf("expect empty thumbnail url, got %s", got)
+	}
+	rec := &recording.Recording{Path: "/c1/a.mp4", Thumbnail: "/c1/a.jpg"}
+	if got, expect := api.thumbnailURI(rec, "tk"), "/static/recordings/c1/a.jpg?token=tk"; got != expect {
+		t.Fatalf("expect %s, got %s", expect, got)
+	}
+}
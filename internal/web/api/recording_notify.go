@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+const (
+	// defaultRecordNotifyRetries 未配置时的重试次数
+	defaultRecordNotifyRetries = 3
+	// recordNotifyTimeout 单次通知的超时时间
+	recordNotifyTimeout = 5 * time.Second
+)
+
+// recordNotifyBackoff 第 n 次重试前的等待时间，测试中可缩短
+var recordNotifyBackoff = func(attempt int) time.Duration {
+	return time.Duration(attempt) * 2 * time.Second
+}
+
+// recordCompletedPayload 录像完成通知内容
+type recordCompletedPayload struct {
+	Event         string   `json:"event"` // 固定为 record_completed
+	ID            int64    `json:"id"`
+	CID           string   `json:"cid"`
+	App           string   `json:"app"`
+	Stream        string   `json:"stream"`
+	Path          string   `json:"path"` // 相对存储目录的路径
+	URL           string   `json:"url"`  // 静态访问地址，配置 PublicBaseURL 时为完整地址
+	StartedAt     orm.Time `json:"started_at"`
+	EndedAt       orm.Time `json:"ended_at"`
+	Duration      float64  `json:"duration"` // 秒
+	Size          int64    `json:"size"`     // 字节
+	MediaServerID string   `json:"media_server_id"`
+}
+
+// recordNotifier 将新入库的录像推送给外部系统，失败按次数重试，不阻塞 webhook 响应
+type recordNotifier struct {
+	url     string
+	retries int
+	client  *http.Client
+	urlFn   func(*recording.Recording) string
+}
+
+// newRecordNotifier 未配置通知地址时返回 nil
+func newRecordNotifier(url string, retries int, urlFn func(*recording.Recording) string) *recordNotifier {
+	if url == "" {
+		return nil
+	}
+	if retries <= 0 {
+		retries = defaultRecordNotifyRetries
+	}
+	return &recordNotifier{
+		url:     url,
+		retries: retries,
+		client:  &http.Client{Timeout: recordNotifyTimeout},
+		urlFn:   urlFn,
+	}
+}
+
+// Notify 异步发送通知，n 为 nil 时不做任何事
+func (n *recordNotifier) Notify(rec *recording.Recording) {
+	if n == nil {
+		return
+	}
+	go func() {
+		if err := n.send(context.Background(), rec); err != nil {
+			slog.Error("录像完成通知失败", "url", n.url, "recording_id", rec.ID, "err", err)
+		}
+	}()
+}
+
+// send 发送通知，首次失败后最多重试 retries 次
+func (n *recordNotifier) send(ctx context.Context, rec *recording.Recording) error {
+	body, err := json.Marshal(recordCompletedPayload{
+		Event:         "record_completed",
+		ID:            rec.ID,
+		CID:           rec.CID,
+		App:           rec.App,
+		Stream:        rec.Stream,
+		Path:          rec.Path,
+		URL:           n.urlFn(rec),
+		StartedAt:     rec.StartedAt,
+		EndedAt:       rec.EndedAt,
+		Duration:      rec.Duration,
+		Size:          rec.Size,
+		MediaServerID: rec.MediaServerID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err = n.post(ctx, body); err == nil || attempt >= n.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(recordNotifyBackoff(attempt + 1)):
+		}
+	}
+}
+
+func (n *recordNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func TestRecordNotifier(t *testing.T) {
+	backoff := recordNotifyBackoff
+	recordNotifyBackoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { recordNotifyBackoff = backoff })
+
+	var calls atomic.Int32
+	got := make(chan recordCompletedPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次失败，验证重试
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var p recordCompletedPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		got <- p
+	}))
+	defer srv.Close()
+
+	cfg := conf.Bootstrap{}
+	cfg.Server.Recording.PublicBaseURL = "https://cdn.example.com"
+	api := RecordingAPI{conf: &cfg}
+	n := newRecordNotifier(srv.URL, 2, func(rec *recording.Recording) string { return api.recordingURI(rec, "") })
+
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	rec := recording.Recording{
+		ID: 7, CID: "c1", App: "rtp", Stream: "c1", Path: "c1/2025-03-01/080000.mp4",
+		StartedAt: orm.Time{Time: start}, EndedAt: orm.Time{Time: start.Add(time.Minute)},
+		Duration: 60, Size: 1024,
+	}
+	if err := n.send(context.Background(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expect 2 calls, got %d", calls.Load())
+	}
+	p := <-got
+	if p.Event != "record_completed" || p.ID != 7 || p.CID != "c1" || p.Path != rec.Path || p.Duration != 60 || p.Size != 1024 {
+		t.Fatalf("unexpected payload %+v", p)
+	}
+	if p.URL != "https://cdn.example.com/static/recordings/c1/2025-03-01/080000.mp4" {
+		t.Fatalf("unexpected url %s", p.URL)
+	}
+	if !p.StartedAt.Time.Equal(start) || !p.EndedAt.Time.Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected time range %v %v", p.StartedAt, p.EndedAt)
+	}
+
+	// 重试耗尽后返回错误
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fail.Close()
+	if err := newRecordNotifier(fail.URL, 1, func(*recording.Recording) string { return "" }).send(context.Background(), &rec); err == nil {
+		t.Fatal("expect error after retries exhausted")
+	}
+
+	if newRecordNotifier("", 0, nil) != nil {
+		t.Fatal("notifier should be disabled without url")
+	}
+	// nil 通知器可直接调用
+	var disabled *recordNotifier
+	disabled.Notify(&rec)
+}
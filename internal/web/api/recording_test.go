@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/recording"
+	"github.com/gowvp/owl/internal/core/recording/store/recordingdb"
+	"gorm.io/gorm"
+)
+
+func TestRecordingRangeRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	api := newTestRecordingAPI(newTestDB(t), dir)
+
+	body := make([]byte, 1000)
+	for i := range body {
+		body[i] = byte(i)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "c1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	original := filepath.Join(dir, "c1", "a.mp4")
+	if err := os.WriteFile(original, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := api.recordingCore.AddRecording(context.Background(), &recording.AddRecordingInput{CID: "c1", Path: "c1/a.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 纯视频文件已存在时直接复用，无需 ffmpeg
+	cacheDir := filepath.Join(dir, ".video-cache")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	videoOnly := filepath.Join(cacheDir, fmt.Sprintf("%x.mp4", md5.Sum([]byte(original))))
+	if err := os.WriteFile(videoOnly, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/recordings/:id/download", api.downloadRecording)
+	RegisterRecordingVideoOnly(r, api)
+
+	for _, path := range []string{
+		fmt.Sprintf("/recordings/%d/download", rec.ID),
+		"/static/recordings-video/c1/a.mp4",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Range", "bytes=100-199")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusPartialContent {
+				t.Fatalf("expect 206, got %d %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Range"); got != "bytes 100-199/1000" {
+				t.Fatalf("unexpected Content-Range %q", got)
+			}
+			if got := w.Header().Get("Content-Type"); got != "video/mp4" {
+				t.Fatalf("unexpected Content-Type %q", got)
+			}
+			if b := w.Body.Bytes(); len(b) != 100 || b[0] != 100 || b[99] != 199 {
+				t.Fatalf("unexpected body len %d", len(b))
+			}
+		})
+	}
+}
+
+// newTestRecordingAPI 基于 db 创建录像接口，录像存放在 dir
+func newTestRecordingAPI(db *gorm.DB, dir string) RecordingAPI {
+	store := recordingdb.NewDB(db).AutoMigrate(true)
+	cfg := conf.Bootstrap{}
+	cfg.Server.Recording.StorageDir = dir
+	core := recording.NewCore(store, recording.WithConfig(&cfg.Server.Recording))
+	return RecordingAPI{recordingCore: core, conf: &cfg}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/system"
+)
+
+const (
+	// recordThumbDelay 流注册后等待首个关键帧的时间
+	recordThumbDelay = 3 * time.Second
+	// recordThumbRetries 流尚未就绪时的重试次数
+	recordThumbRetries = 3
+	// recordThumbInterval 重试间隔
+	recordThumbInterval = 2 * time.Second
+)
+
+// thumbSlot 单个流待使用的封面图
+type thumbSlot struct {
+	img      []byte
+	serverID string    // 录制该流的媒体节点，从该节点抓图
+	at       time.Time // 抓图时间，晚于切片结束时间的图属于下一个切片
+	active   bool      // 流仍在录制，切片入库后继续为下一个切片抓图
+}
+
+// recordThumbs 录像开始时抓取一帧画面，在切片入库时作为该录像的封面图
+// 不依赖 AI 检测，没有事件快照的通道同样有封面
+type recordThumbs struct {
+	grab     func(ctx context.Context, serverID, app, stream string) ([]byte, error)
+	delay    time.Duration
+	retries  int
+	interval time.Duration
+
+	mu    sync.Mutex
+	slots map[string]*thumbSlot // key 为 app/stream
+}
+
+func newRecordThumbs(grab func(ctx context.Context, serverID, app, stream string) ([]byte, error)) *recordThumbs {
+	return &recordThumbs{
+		grab:     grab,
+		delay:    recordThumbDelay,
+		retries:  recordThumbRetries,
+		interval: recordThumbInterval,
+		slots:    make(map[string]*thumbSlot),
+	}
+}
+
+// Start 流开始录制时调用，延迟后异步从录制该流的节点抓图
+func (t *recordThumbs) Start(serverID, app, stream string) {
+	slot := &thumbSlot{serverID: serverID, active: true}
+	t.mu.Lock()
+	t.slots[app+"/"+stream] = slot
+	t.mu.Unlock()
+	go t.capture(app, stream, slot, t.delay)
+}
+
+// Stop 流注销时调用，最后一个切片入库后释放
+func (t *recordThumbs) Stop(app, stream string) {
+	key := app + "/" + stream
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slot, ok := t.slots[key]
+	if !ok {
+		return
+	}
+	slot.active = false
+	if slot.img == nil {
+		delete(t.slots, key)
+	}
+}
+
+// Attach 将待使用的封面图写到录像文件旁，返回封面图相对路径，无可用封面时返回空
+// relPath 为录像入库的相对路径，root 为相对路径的根目录，为空时使用工作目录，end 为切片结束时间
+func (t *recordThumbs) Attach(app, stream, relPath, root string, end time.Time) string {
+	key := app + "/" + stream
+	t.mu.Lock()
+	slot, ok := t.slots[key]
+	if !ok || slot.img == nil || slot.at.After(end) {
+		t.mu.Unlock()
+		return ""
+	}
+	img := slot.img
+	slot.img = nil
+	if slot.active {
+		// 录像按时长切片，当前画面属于下一个切片
+		go t.capture(app, stream, slot, 0)
+	} else {
+		delete(t.slots, key)
+	}
+	t.mu.Unlock()
+
+	thumb := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".jpg"
+	full := thumb
+	if !filepath.IsAbs(full) {
+		if root == "" {
+			root = system.Getwd()
+		}
+		full = filepath.Join(root, full)
+	}
+	if err := os.WriteFile(full, img, 0o644); err != nil {
+		slog.Warn("写入录像封面失败", "app", app, "stream", stream, "path", full, "err", err)
+		return ""
+	}
+	return thumb
+}
+
+// capture 抓图并放入 slot，流尚未就绪时按间隔重试
+func (t *recordThumbs) capture(app, stream string, slot *thumbSlot, delay time.Duration) {
+	time.Sleep(delay)
+	for i := 0; i <= t.retries; i++ {
+		if i > 0 {
+			time.Sleep(t.interval)
+		}
+		if !t.current(app, stream, slot) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		img, err := t.grab(ctx, slot.serverID, app, stream)
+		cancel()
+		if err != nil || len(img) == 0 {
+			slog.Debug("抓取录像封面失败", "app", app, "stream", stream, "attempt", i+1, "err", err)
+			continue
+		}
+		t.mu.Lock()
+		if t.slots[app+"/"+stream] == slot {
+			slot.img = img
+			slot.at = time.Now()
+		}
+		t.mu.Unlock()
+		return
+	}
+	slog.Warn("抓取录像封面失败，跳过", "app", app, "stream", stream)
+}
+
+// current 判断 slot 是否仍属于正在录制的流，流重新注册或已注销时放弃抓图
+func (t *recordThumbs) current(app, stream string, slot *thumbSlot) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.slots[app+"/"+stream] == slot && slot.active
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordThumbs(t *testing.T) {
+	var calls atomic.Int32
+	thumbs := newRecordThumbs(func(_ context.Context, serverID, app, stream string) ([]byte, error) {
+		if serverID != "node2" {
+			return nil, errors.New("unexpected media server " + serverID)
+		}
+		// 第一次流尚未就绪，验证重试
+		if calls.Add(1) == 1 {
+			return nil, errors.New("stream not ready")
+		}
+		return []byte(app + "/" + stream), nil
+	})
+	thumbs.delay, thumbs.interval = 0, time.Millisecond
+
+	waitPending := func() {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			thumbs.mu.Lock()
+			slot := thumbs.slots["rtp/ch1"]
+			ready := slot != nil && slot.img != nil
+			thumbs.mu.Unlock()
+			if ready {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("snapshot not captured")
+	}
+
+	dir := t.TempDir()
+	seg1 := filepath.Join(dir, "rtp", "ch1", "2026-10-17", "10-00-00-0.mp4")
+	if err := os.MkdirAll(filepath.Dir(seg1), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbs.Start("node2", "rtp", "ch1")
+	waitPending()
+
+	// 抓图晚于切片结束时间，属于下一个切片
+	if got := thumbs.Attach("rtp", "ch1", seg1, "", time.Now().Add(-time.Hour)); got != "" {
+		t.Fatalf("expect no thumbnail for earlier segment, got %q", got)
+	}
+
+	got := thumbs.Attach("rtp", "ch1", seg1, "", time.Now())
+	want := filepath.Join(filepath.Dir(seg1), "10-00-00-0.jpg")
+	if got != want {
+		t.Fatalf("expect %q, got %q", want, got)
+	}
+	b, err := os.ReadFile(want)
+	if err != nil || string(b) != "rtp/ch1" {
+		t.Fatalf("unexpected thumbnail content %q err %v", b, err)
+	}
+
+	// 流注销后，下一个切片使用入库后重新抓取的画面，之后释放
+	// 其它节点的录像为相对路径，封面写到该节点录像目录下
+	waitPending()
+	thumbs.Stop("rtp", "ch1")
+	seg2 := filepath.Join("rtp", "ch1", "2026-10-17", "10-30-00-1.mp4")
+	got = thumbs.Attach("rtp", "ch1", seg2, dir, time.Now())
+	if want := filepath.Join("rtp", "ch1", "2026-10-17", "10-30-00-1.jpg"); got != want {
+		t.Fatalf("expect %q, got %q", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, got)); err != nil {
+		t.Fatalf("thumbnail not written under node dir: %v", err)
+	}
+	if got := thumbs.Attach("rtp", "ch1", seg2, dir, time.Now()); got != "" {
+		t.Fatalf("expect slot released after last segment, got %q", got)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expect 3 grab calls, got %d", calls.Load())
+	}
+}
@@ -49,7 +49,7 @@ func (a RecordingAPI) serveVideoOnly(c *gin.Context) {
 	if exists {
 		// 检查缓存文件是否存在
 		if _, err := os.Stat(cachedPath); err == nil {
-			c.File(cachedPath)
+			serveRecordingFile(c, cachedPath)
 			return
 		}
 	}
@@ -67,7 +67,7 @@ func (a RecordingAPI) serveVideoOnly(c *gin.Context) {
 	videoOnlyCache[originalPath] = videoOnlyPath
 	videoOnlyCacheLock.Unlock()
 
-	c.File(videoOnlyPath)
+	serveRecordingFile(c, videoOnlyPath)
 }
 
 // createVideoOnlyFile 使用 ffmpeg 创建纯视频文件（移除音频）
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+const (
+	// sipTraceDefaultDuration 未指定 duration 时跟踪的时长
+	sipTraceDefaultDuration = time.Minute
+	// sipTraceMaxDuration 单次跟踪的最长时长，到期自动停止
+	sipTraceMaxDuration = 10 * time.Minute
+	// sipTracePingInterval 无报文时发送注释保活，避免代理断开空闲连接
+	sipTracePingInterval = 15 * time.Second
+)
+
+// sipTraceDuration 解析 duration 查询参数（秒），超出上限时按上限处理
+func sipTraceDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return sipTraceDefaultDuration, nil
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec <= 0 {
+		return 0, fmt.Errorf("invalid duration[%s]", v)
+	}
+	return min(time.Duration(sec)*time.Second, sipTraceMaxDuration), nil
+}
+
+// traceSIP 通过 SSE 推送国标设备收发的原始 SIP 报文，仅复制该设备的报文，无需开启全局报文日志
+// GET /devices/:id/sip/trace?duration=60，到期或客户端断开后自动停止
+func (a IPCAPI) traceSIP(c *gin.Context) {
+	duration, err := sipTraceDuration(c.Query("duration"))
+	if err != nil {
+		web.Fail(c, reason.ErrBadRequest.SetMsg(err.Error()))
+		return
+	}
+	dev, err := a.ipc.GetDevice(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		web.Fail(c, err)
+		return
+	}
+	if !dev.IsGB28181() {
+		web.Fail(c, ipc.ErrProtocolNotSupported.SetMsg("仅国标设备支持 SIP 报文跟踪"))
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"msg": "不支持 SSE"})
+		return
+	}
+
+	// HTTP 服务的 WriteTimeout 短于跟踪时长，需为本连接单独延长，否则到期前被截断且收不到 end 事件
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(duration + 10*time.Second)); err != nil {
+		slog.WarnContext(c.Request.Context(), "设置 SSE 写超时失败", "err", err)
+	}
+
+	msgs, cancel := a.uc.SipServer.TraceDevice(dev.DeviceID, duration)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	sendEvent := func(event string, data any) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, b)
+		flusher.Flush()
+	}
+
+	ping := time.NewTicker(sipTracePingInterval)
+	defer ping.Stop()
+
+	sendEvent("start", gin.H{"device_id": dev.DeviceID, "duration": int(duration.Seconds())})
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			flusher.Flush()
+		case msg, ok := <-msgs:
+			if !ok {
+				sendEvent("end", gin.H{"msg": "跟踪已到期"})
+				return
+			}
+			sendEvent("message", msg)
+		}
+	}
+}
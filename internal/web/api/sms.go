@@ -17,12 +17,13 @@ type SmsAPI struct {
 	uc      *Usecase
 }
 
-func NewSMSCore(db *gorm.DB, cfg *conf.Bootstrap) sms.Core {
+// NewSMSCore 程序退出时停止节点巡检与连接任务
+func NewSMSCore(db *gorm.DB, cfg *conf.Bootstrap) (sms.Core, func()) {
 	core := sms.NewCore(smsdb.NewDB(db).AutoMigrate(orm.GetEnabledAutoMigrate()))
 	if err := core.Run(cfg, cfg.Server.HTTP.Port); err != nil {
 		panic(err)
 	}
-	return core
+	return core, core.Close
 }
 
 func NewSmsAPI(core sms.Core) SmsAPI {
@@ -65,11 +66,12 @@ func (a SmsAPI) editMediaServer(c *gin.Context, in *sms.EditMediaServerInput) (a
 		a.uc.Conf.Media.Secret = out.Secret
 		a.uc.Conf.Media.WebHookIP = out.HookIP
 		a.uc.Conf.Media.Type = out.Type
+		a.uc.Conf.Media.MaxPlays = out.MaxPlays
 		if err := conf.WriteConfig(a.uc.Conf, a.uc.Conf.ConfigPath); err != nil {
 			return nil, reason.ErrServer.SetMsg(err.Error())
 		}
 	}
-	return out, err
+	return a.smsCore.NewMediaServerItem(out), nil
 }
 
 func (a SmsAPI) addMediaServer(c *gin.Context, in *sms.AddMediaServerInput) (any, error) {
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/sms"
+)
+
+// 设备墙封面来源
+const (
+	snapshotSourceLive        = "live"
+	snapshotSourcePoster      = "poster"
+	snapshotSourcePlaceholder = "placeholder"
+)
+
+// defaultSnapshotFallback 未配置时的封面来源顺序
+var defaultSnapshotFallback = []string{snapshotSourceLive, snapshotSourcePoster, snapshotSourcePlaceholder}
+
+// snapshotResult 封面来自文件时使用 path，便于浏览器协商缓存；否则使用 body
+type snapshotResult struct {
+	source string
+	path   string
+	body   []byte
+}
+
+// snapshotSourceFunc 从一个来源获取通道封面
+type snapshotSourceFunc func(ctx context.Context, channelID string) (snapshotResult, error)
+
+// resolveSnapshot 按顺序尝试各来源，返回第一个成功的结果，全部失败时返回各来源的错误
+func resolveSnapshot(ctx context.Context, channelID string, order []string, sources map[string]snapshotSourceFunc) (snapshotResult, error) {
+	if len(order) == 0 {
+		order = defaultSnapshotFallback
+	}
+	errs := make([]error, 0, len(order))
+	for _, name := range order {
+		fn, ok := sources[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown snapshot source %q", name))
+			continue
+		}
+		res, err := fn(ctx, channelID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		res.source = name
+		return res, nil
+	}
+	return snapshotResult{}, errors.Join(errs...)
+}
+
+// snapshotSources 通道封面的各个来源
+func (a IPCAPI) snapshotSources() map[string]snapshotSourceFunc {
+	return map[string]snapshotSourceFunc{
+		snapshotSourceLive:        a.liveSnapshot,
+		snapshotSourcePoster:      a.posterSnapshot,
+		snapshotSourcePlaceholder: a.placeholderSnapshot,
+	}
+}
+
+// errPosterFresh 封面仍在有效期内，交给缓存封面处理
+var errPosterFresh = errors.New("poster still fresh")
+
+// liveSnapshot 通道正在拉流且封面已过期时实时抓拍，并更新缓存封面
+// 未拉流的通道不抓拍，避免设备墙刷新触发按需拉流
+func (a IPCAPI) liveSnapshot(ctx context.Context, channelID string) (snapshotResult, error) {
+	dir := a.posterDir()
+	if !posterExpired(dir, channelID, time.Now(), time.Duration(a.uc.Conf.Server.Poster.TTL)) {
+		return snapshotResult{}, errPosterFresh
+	}
+	ch, err := a.ipc.GetChannel(ctx, channelID)
+	if err != nil {
+		return snapshotResult{}, err
+	}
+	if !ch.IsPlaying {
+		return snapshotResult{}, sms.ErrStreamNotActive
+	}
+	return grabWithTimeout(ctx, liveSnapshotTimeout, func(ctx context.Context) (snapshotResult, error) {
+		img, err := a.grabKeyFrame(ctx, channelID)
+		if err != nil {
+			return snapshotResult{}, err
+		}
+		if err := a.saveCover(channelID, img); err != nil {
+			return snapshotResult{body: img}, nil
+		}
+		return snapshotResult{path: readCoverPath(dir, channelID)}, nil
+	})
+}
+
+// liveSnapshotTimeout 设备墙实时抓拍的最长等待时间，超时回退到下一个来源
+const liveSnapshotTimeout = 3 * time.Second
+
+// grabWithTimeout 在 timeout 内等待抓拍结果
+// 超时后抓拍在后台继续，完成后仍会更新缓存封面，供下次请求使用
+func grabWithTimeout(ctx context.Context, timeout time.Duration, grab func(ctx context.Context) (snapshotResult, error)) (snapshotResult, error) {
+	type result struct {
+		res snapshotResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := grab(context.WithoutCancel(ctx))
+		done <- result{res: res, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-timer.C:
+		return snapshotResult{}, errLiveSnapshotTimeout
+	case <-ctx.Done():
+		return snapshotResult{}, ctx.Err()
+	}
+}
+
+// errLiveSnapshotTimeout 实时抓拍超时
+var errLiveSnapshotTimeout = errors.New("live snapshot timeout")
+
+// posterSnapshot 最近一次缓存的封面
+func (a IPCAPI) posterSnapshot(_ context.Context, channelID string) (snapshotResult, error) {
+	path := readCoverPath(a.posterDir(), channelID)
+	if _, err := os.Stat(path); err != nil {
+		return snapshotResult{}, err
+	}
+	return snapshotResult{path: path}, nil
+}
+
+// placeholderSnapshot 离线占位图，优先使用配置的图片
+func (a IPCAPI) placeholderSnapshot(_ context.Context, _ string) (snapshotResult, error) {
+	if path := a.uc.Conf.Server.Poster.Placeholder; path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return snapshotResult{}, err
+		}
+		return snapshotResult{path: path}, nil
+	}
+	body, err := defaultPlaceholder()
+	if err != nil {
+		return snapshotResult{}, err
+	}
+	return snapshotResult{body: body}, nil
+}
+
+// defaultPlaceholder 内置 16:9 深灰色占位图，只生成一次
+var defaultPlaceholder = sync.OnceValues(func() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 180))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0x40, 0x40, 0x40, 0xFF}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: defaultSnapshotQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+})
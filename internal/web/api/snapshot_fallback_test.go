@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/ipc/store/ipcdb"
+	"github.com/ixugo/goddd/domain/uniqueid"
+)
+
+func TestResolveSnapshot(t *testing.T) {
+	var calls []string
+	source := func(name string, err error) snapshotSourceFunc {
+		return func(context.Context, string) (snapshotResult, error) {
+			calls = append(calls, name)
+			return snapshotResult{path: name}, err
+		}
+	}
+	down := errors.New("stream down")
+
+	tests := []struct {
+		name   string
+		order  []string
+		srcs   map[string]snapshotSourceFunc
+		expect string
+		calls  []string
+	}{
+		{
+			name:   "live",
+			srcs:   map[string]snapshotSourceFunc{"live": source("live", nil), "poster": source("poster", nil), "placeholder": source("placeholder", nil)},
+			expect: "live",
+			calls:  []string{"live"},
+		},
+		{
+			name:   "poster when live fails",
+			srcs:   map[string]snapshotSourceFunc{"live": source("live", down), "poster": source("poster", nil), "placeholder": source("placeholder", nil)},
+			expect: "poster",
+			calls:  []string{"live", "poster"},
+		},
+		{
+			name:   "placeholder when poster missing",
+			srcs:   map[string]snapshotSourceFunc{"live": source("live", down), "poster": source("poster", down), "placeholder": source("placeholder", nil)},
+			expect: "placeholder",
+			calls:  []string{"live", "poster", "placeholder"},
+		},
+		{
+			name:   "custom order skips unknown",
+			order:  []string{"unknown", "poster", "live"},
+			srcs:   map[string]snapshotSourceFunc{"live": source("live", nil), "poster": source("poster", nil)},
+			expect: "poster",
+			calls:  []string{"poster"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			res, err := resolveSnapshot(context.Background(), "ch1", tt.order, tt.srcs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.source != tt.expect || res.path != tt.expect {
+				t.Fatalf("expect source %s, got %+v", tt.expect, res)
+			}
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("expect calls %v, got %v", tt.calls, calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.calls[i] {
+					t.Fatalf("expect calls %v, got %v", tt.calls, calls)
+				}
+			}
+		})
+	}
+
+	// 全部失败时返回错误，由接口响应 404
+	_, err := resolveSnapshot(context.Background(), "ch1", []string{"live", "poster"}, map[string]snapshotSourceFunc{
+		"live": source("live", down), "poster": source("poster", down),
+	})
+	if !errors.Is(err, down) {
+		t.Fatalf("expect joined source errors, got %v", err)
+	}
+}
+
+func TestSnapshotFallbackSources(t *testing.T) {
+	dir := t.TempDir()
+	var bc conf.Bootstrap
+	bc.Server.Poster.Dir = dir
+	api := IPCAPI{uc: &Usecase{Conf: &bc}}
+	ctx := context.Background()
+
+	if _, err := api.posterSnapshot(ctx, "ch1"); err == nil {
+		t.Fatal("expect error without cached poster")
+	}
+	if err := writeCover(dir, "ch1", []byte("jpg")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := api.posterSnapshot(ctx, "ch1")
+	if err != nil || res.path != readCoverPath(dir, "ch1") {
+		t.Fatalf("expect cached poster, got %+v %v", res, err)
+	}
+
+	// 封面未过期时不实时抓拍，交给缓存封面
+	bc.Server.Poster.TTL = conf.Duration(time.Minute)
+	if _, err := api.liveSnapshot(ctx, "ch1"); !errors.Is(err, errPosterFresh) {
+		t.Fatalf("expect errPosterFresh, got %v", err)
+	}
+
+	// 内置占位图必须是可解码的 jpeg
+	res, err = api.placeholderSnapshot(ctx, "ch1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(res.body)); err != nil {
+		t.Fatalf("expect jpeg placeholder, got %v", err)
+	}
+
+	bc.Server.Poster.Placeholder = filepath.Join(dir, "offline.png")
+	if _, err := api.placeholderSnapshot(ctx, "ch1"); err == nil {
+		t.Fatal("expect error for missing custom placeholder")
+	}
+}
+
+func TestGrabWithTimeout(t *testing.T) {
+	res, err := grabWithTimeout(context.Background(), time.Minute, func(context.Context) (snapshotResult, error) {
+		return snapshotResult{path: "cover.jpg"}, nil
+	})
+	if err != nil || res.path != "cover.jpg" {
+		t.Fatalf("expect grab result, got %+v %v", res, err)
+	}
+
+	// 抓拍超时后回退，抓拍在后台继续完成
+	release, finished := make(chan struct{}), make(chan struct{})
+	_, err = grabWithTimeout(context.Background(), time.Millisecond, func(ctx context.Context) (snapshotResult, error) {
+		defer close(finished)
+		<-release
+		return snapshotResult{}, ctx.Err()
+	})
+	if !errors.Is(err, errLiveSnapshotTimeout) {
+		t.Fatalf("expect errLiveSnapshotTimeout, got %v", err)
+	}
+	close(release)
+	<-finished
+}
+
+func TestGetSnapshotUnknownChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var bc conf.Bootstrap
+	bc.Server.Poster.Dir = t.TempDir()
+	api := IPCAPI{
+		ipc: ipc.NewCore(ipcdb.NewDB(newTestDB(t)).AutoMigrate(true), uniqueid.Core{}, nil),
+		uc:  &Usecase{Conf: &bc},
+	}
+	r := gin.New()
+	r.GET("/channels/:id/snapshot", api.getSnapshot)
+
+	// 不存在的通道不能返回占位图
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/channels/missing/snapshot", nil))
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code == http.StatusOK || body.Reason != "ErrChannelNotFound" {
+		t.Fatalf("expect ErrChannelNotFound, got %d %s", w.Code, w.Body.String())
+	}
+}
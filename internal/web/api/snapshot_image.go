@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/gowvp/owl/internal/conf"
+)
+
+// 快照图片格式
+const (
+	snapshotFormatJPEG = "jpeg"
+	snapshotFormatWebP = "webp"
+
+	defaultSnapshotQuality = 80
+)
+
+// errInvalidSnapshot 快照数据无法解码为图片
+var errInvalidSnapshot = errors.New("invalid snapshot image")
+
+// validateSnapshot 完整解码一次快照，避免残缺或非图片数据落盘成为无法显示的缩略图
+// 仅支持已注册解码器的 jpeg/png，返回实际格式对应的扩展名
+func validateSnapshot(data []byte) (string, error) {
+	_, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+	if format == "png" {
+		return ".png", nil
+	}
+	return ".jpg", nil
+}
+
+// snapshotExt 按图片内容返回文件扩展名
+func snapshotExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".jpg"
+}
+
+// sniffContentType 读取文件头判断图片类型
+func sniffContentType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// normalizeSnapshot 按配置转码快照
+// 未配置格式时原样返回；原图无法解码时也原样返回，避免因转码失败丢失快照
+func normalizeSnapshot(data []byte, cfg conf.ServerSnapshot) ([]byte, error) {
+	if cfg.Format == "" {
+		return data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	switch cfg.Format {
+	case snapshotFormatJPEG, "jpg":
+		quality := cfg.Quality
+		if quality <= 0 || quality > 100 {
+			quality = defaultSnapshotQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case snapshotFormatWebP:
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot format %q", cfg.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/ixugo/goddd/pkg/orm"
+)
+
+func testSnapshotPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := range 48 {
+		for x := range 64 {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), uint8(x ^ y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizeSnapshot(t *testing.T) {
+	src := testSnapshotPNG(t)
+
+	out, err := normalizeSnapshot(src, conf.ServerSnapshot{})
+	if err != nil || !bytes.Equal(out, src) {
+		t.Fatal("empty format should keep original image")
+	}
+
+	high, err := normalizeSnapshot(src, conf.ServerSnapshot{Format: "jpeg", Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := normalizeSnapshot(src, conf.ServerSnapshot{Format: "jpeg", Quality: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := http.DetectContentType(low); ct != "image/jpeg" {
+		t.Fatalf("expect jpeg, got %s", ct)
+	}
+	if len(low) >= len(high) {
+		t.Fatalf("lower quality should be smaller, got %d >= %d", len(low), len(high))
+	}
+	if snapshotExt(low) != ".jpg" {
+		t.Fatalf("unexpected ext %s", snapshotExt(low))
+	}
+
+	webp, err := normalizeSnapshot(src, conf.ServerSnapshot{Format: "webp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := http.DetectContentType(webp); ct != "image/webp" {
+		t.Fatalf("expect webp, got %s", ct)
+	}
+	if snapshotExt(webp) != ".webp" {
+		t.Fatalf("unexpected ext %s", snapshotExt(webp))
+	}
+
+	// 无法解码的数据原样保留
+	raw := []byte("not an image")
+	if out, err := normalizeSnapshot(raw, conf.ServerSnapshot{Format: "jpeg"}); err != nil || !bytes.Equal(out, raw) {
+		t.Fatal("undecodable snapshot should be kept as is")
+	}
+	if _, err := normalizeSnapshot(src, conf.ServerSnapshot{Format: "bmp"}); err == nil {
+		t.Fatal("expect error for unsupported format")
+	}
+}
+
+func TestSaveEventSnapshotValidate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	now := orm.Now()
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		ext    string
+		hasErr bool
+	}{
+		{name: "jpeg", data: jpg.Bytes(), ext: ".jpg"},
+		{name: "png", data: testSnapshotPNG(t), ext: ".png"},
+		{name: "garbage", data: []byte("not an image"), hasErr: true},
+		{name: "truncated jpeg", data: jpg.Bytes()[:len(jpg.Bytes())/2], hasErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := saveEventSnapshot(event.DefaultSnapshotPath(), event.SnapshotVars{CID: "cid1", Time: now.Time}, base64.StdEncoding.EncodeToString(tt.data), conf.ServerSnapshot{})
+			if tt.hasErr {
+				if !errors.Is(err, errInvalidSnapshot) {
+					t.Fatalf("expect invalid snapshot error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Ext(path) != tt.ext {
+				t.Fatalf("expect ext %s, got %s", tt.ext, path)
+			}
+			b, err := os.ReadFile(filepath.Join("configs", "events", path))
+			if err != nil || !bytes.Equal(b, tt.data) {
+				t.Fatalf("snapshot not written as is, err %v", err)
+			}
+		})
+	}
+
+	entries, _ := os.ReadDir(filepath.Join("configs", "events", "cid1"))
+	if len(entries) != 2 {
+		t.Fatalf("expect only valid snapshots written, got %d files", len(entries))
+	}
+}
+
+func TestGetEventImage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir(t.TempDir())
+	dir := filepath.Join("configs", "events", "cid1")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := testSnapshotPNG(t)
+	if err := os.WriteFile(filepath.Join(dir, "20260305100000_123456.png"), img, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20260305100000_123456.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := newEventImageGetter(&conf.Bootstrap{})
+	w := get("cid1/20260305100000_123456.png")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || !bytes.Equal(w.Body.Bytes(), img) {
+		t.Fatalf("expect png served, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w := get("cid1/20260305100000_123456.txt"); w.Code == http.StatusOK {
+		t.Fatal("expect non image file rejected")
+	}
+}
+
+func TestGetEventImageWithRecordings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir(t.TempDir())
+	storage := t.TempDir()
+	dir := filepath.Join(storage, "rtp", "cid1", "2026-03-05")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := testSnapshotPNG(t)
+	for _, name := range []string{"20260305100000_123456.png", "cover.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), img, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(storage, "rtp", "cid1", "20260305100000_123456.png"), img, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var bc conf.Bootstrap
+	bc.Server.Snapshot.WithRecordings = true
+	bc.Server.Recording.StorageDir = storage
+	get := newEventImageGetter(&bc)
+
+	if w := get("rtp/cid1/2026-03-05/20260305100000_123456.png"); w.Code != http.StatusOK {
+		t.Fatalf("expect event snapshot served, got %d", w.Code)
+	}
+	// 录像目录下不符合快照目录与命名的图片不对外提供
+	for _, path := range []string{
+		"rtp/cid1/2026-03-05/cover.png",
+		"rtp/cid1/20260305100000_123456.png",
+		"rtp/cid1/../cid1/2026-03-05/20260305100000_123456.png",
+	} {
+		if w := get(path); w.Code == http.StatusOK {
+			t.Fatalf("expect %s rejected", path)
+		}
+	}
+}
+
+// newEventImageGetter 注册图片路由，返回按路径请求的函数
+func newEventImageGetter(bc *conf.Bootstrap) func(path string) *httptest.ResponseRecorder {
+	api := EventAPI{conf: bc}
+	r := gin.New()
+	r.GET("/events/image/*path", api.getEventImage)
+	return func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events/image/"+path, nil))
+		return w
+	}
+}
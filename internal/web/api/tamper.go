@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	_ "image/jpeg"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/event"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/pkg/ffwork"
+	"github.com/gowvp/owl/pkg/zlm"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/reason"
+	"github.com/ixugo/goddd/pkg/web"
+)
+
+const (
+	tamperDir = "tamper"
+	// tamperLabel 篡改事件的标签与模型名
+	tamperLabel = "tamper"
+	// defaultTamperThreshold 配置未填写阈值时使用
+	defaultTamperThreshold = 0.6
+)
+
+// tamperOutput 画面篡改检测结果
+type tamperOutput struct {
+	Score     float64 `json:"score"`     // 篡改分数 [0,1]，越大越可能被遮挡或移位
+	Threshold float64 `json:"threshold"` // 判定阈值
+	Tampered  bool    `json:"tampered"`  // 是否判定为篡改
+}
+
+func readTamperReferencePath(dataDir, channelID string) string {
+	return filepath.Join(dataDir, tamperDir, channelID+".jpg")
+}
+
+func writeTamperReference(dataDir, channelID string, body []byte) error {
+	if err := os.MkdirAll(filepath.Join(dataDir, tamperDir), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(readTamperReferencePath(dataDir, channelID), body, 0o644)
+}
+
+// tamperThreshold 返回配置的篡改阈值
+func (a IPCAPI) tamperThreshold() float64 {
+	if v := a.uc.Conf.Server.Tamper.Threshold; v > 0 && v <= 1 {
+		return v
+	}
+	return defaultTamperThreshold
+}
+
+// grabKeyFrame 从通道流所在的流媒体服务抓取当前画面
+func (a IPCAPI) grabKeyFrame(ctx context.Context, channelID string) ([]byte, error) {
+	svr, err := a.channelMediaServer(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	rtspURL, err := a.rtspURLOn(ctx, svr, channelID)
+	if err != nil {
+		return nil, err
+	}
+	img, err := a.uc.SMSAPI.smsCore.GetSnapshot(svr, sms.GetSnapRequest{
+		GetSnapRequest: zlm.GetSnapRequest{
+			URL:        rtspURL,
+			TimeoutSec: 10,
+			ExpireSec:  1,
+		},
+		Stream: channelID,
+	})
+	if err != nil {
+		return nil, reason.ErrUsedLogic.SetMsg("抓取画面失败: " + err.Error())
+	}
+	a.ipc.TouchFrame(channelID, time.Now())
+	return img, nil
+}
+
+// compareTamper 抓取当前画面并与参考图比对，返回分数与当前画面
+func (a IPCAPI) compareTamper(ctx context.Context, channelID string) (float64, []byte, error) {
+	refData, err := os.ReadFile(readTamperReferencePath(a.uc.Conf.ConfigDir, channelID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, reason.ErrNotFound.SetMsg("未设置参考画面")
+		}
+		return 0, nil, reason.ErrServer.SetMsg(err.Error())
+	}
+	ref, _, err := image.Decode(bytes.NewReader(refData))
+	if err != nil {
+		return 0, nil, reason.ErrServer.SetMsg("参考画面解码失败: " + err.Error())
+	}
+
+	curData, err := a.grabKeyFrame(ctx, channelID)
+	if err != nil {
+		return 0, nil, err
+	}
+	cur, _, err := image.Decode(bytes.NewReader(curData))
+	if err != nil {
+		return 0, nil, reason.ErrServer.SetMsg("当前画面解码失败: " + err.Error())
+	}
+	return ffwork.TamperScore(ref, cur), curData, nil
+}
+
+// getTamper 比对当前画面与参考画面，返回篡改分数
+func (a IPCAPI) getTamper(c *gin.Context, _ *struct{}) (*tamperOutput, error) {
+	score, _, err := a.compareTamper(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	threshold := a.tamperThreshold()
+	return &tamperOutput{Score: score, Threshold: threshold, Tampered: score >= threshold}, nil
+}
+
+// setTamperReference 以通道当前画面作为参考画面
+func (a IPCAPI) setTamperReference(c *gin.Context, _ *struct{}) (gin.H, error) {
+	channelID := c.Param("id")
+	img, err := a.grabKeyFrame(c.Request.Context(), channelID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := image.Decode(bytes.NewReader(img)); err != nil {
+		return nil, reason.ErrUsedLogic.SetMsg("画面解码失败: " + err.Error())
+	}
+	if err := writeTamperReference(a.uc.Conf.ConfigDir, channelID, img); err != nil {
+		return nil, reason.ErrServer.SetMsg(err.Error())
+	}
+	return gin.H{"updated_at": orm.Now()}, nil
+}
+
+// StartTamperLoop 按配置间隔巡检已设置参考画面的在线通道，分数越过阈值时产生事件
+func (a IPCAPI) StartTamperLoop(ctx context.Context) {
+	interval := time.Duration(a.uc.Conf.Server.Tamper.Interval)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		// 记录已告警的通道，恢复正常前不重复产生事件
+		tampered := make(map[string]bool)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.checkTamper(ctx, tampered)
+			}
+		}
+	}()
+}
+
+// checkTamper 执行一轮巡检
+func (a IPCAPI) checkTamper(ctx context.Context, tampered map[string]bool) {
+	channels, _, err := a.ipc.FindChannel(ctx, &ipc.FindChannelInput{
+		PagerFilter: web.PagerFilter{Page: 1, Size: 999},
+		IsOnline:    "true",
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "tamper: find channels failed", "err", err)
+		return
+	}
+
+	threshold := a.tamperThreshold()
+	for _, ch := range channels {
+		if _, err := os.Stat(readTamperReferencePath(a.uc.Conf.ConfigDir, ch.ID)); err != nil {
+			continue
+		}
+		score, img, err := a.compareTamper(ctx, ch.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "tamper: compare failed", "channel_id", ch.ID, "err", err)
+			continue
+		}
+
+		if score < threshold {
+			delete(tampered, ch.ID)
+			continue
+		}
+		if tampered[ch.ID] {
+			continue
+		}
+		tampered[ch.ID] = true
+		a.addTamperEvent(ctx, ch, score, img)
+	}
+}
+
+// addTamperEvent 保存当前画面并记录篡改事件
+func (a IPCAPI) addTamperEvent(ctx context.Context, ch *ipc.Channel, score float64, img []byte) {
+	now := orm.Now()
+	imagePath, err := saveEventSnapshot(snapshotPath(a.uc.Conf), snapshotVars(ch.ID, ch, now.Time), base64.StdEncoding.EncodeToString(img), a.uc.Conf.Server.Snapshot)
+	if err != nil {
+		slog.ErrorContext(ctx, "tamper: save snapshot failed", "channel_id", ch.ID, "err", err)
+	}
+	if _, err := a.uc.EventAPI.eventCore.AddEvent(ctx, &event.AddEventInput{
+		DID:       ch.DID,
+		CID:       ch.ID,
+		StartedAt: now,
+		EndedAt:   now,
+		Label:     tamperLabel,
+		Score:     float32(score),
+		ImagePath: imagePath,
+		Model:     tamperLabel,
+	}); err != nil {
+		slog.ErrorContext(ctx, "tamper: save event failed", "channel_id", ch.ID, "err", err)
+	}
+}
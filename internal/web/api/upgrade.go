@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/pkg/ota"
+	"github.com/ixugo/goddd/pkg/system"
+)
+
+// 升级状态
+const (
+	upgradeIdle        = "idle"
+	upgradeDownloading = "downloading"
+	upgradeInstalling  = "installing"
+	upgradeCompleted   = "completed"
+	upgradeFailed      = "failed"
+)
+
+// UpgradeStatus 升级进度快照
+type UpgradeStatus struct {
+	Running    bool      `json:"running"`
+	Status     string    `json:"status"` // idle/downloading/installing/completed/failed
+	Current    int64     `json:"current"`
+	Total      int64     `json:"total"`
+	Percent    int       `json:"percent"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// upgradeState 进程内唯一的升级任务状态，并发下载会写坏同一个升级包，必须互斥
+type upgradeState struct {
+	mu     sync.Mutex
+	status UpgradeStatus
+}
+
+// tryStart 抢占升级任务，已有升级进行中时返回 false
+func (s *upgradeState) tryStart() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		return false
+	}
+	s.status = UpgradeStatus{Running: true, Status: upgradeDownloading, StartedAt: time.Now()}
+	return true
+}
+
+// setStatus 更新当前阶段
+func (s *upgradeState) setStatus(status string) {
+	s.mu.Lock()
+	s.status.Status = status
+	s.mu.Unlock()
+}
+
+func (s *upgradeState) progress(current, total int64) int {
+	percent := 0
+	if total > 0 {
+		percent = int(current * 100 / total)
+	}
+	s.mu.Lock()
+	s.status.Current, s.status.Total, s.status.Percent = current, total, percent
+	s.mu.Unlock()
+	return percent
+}
+
+// finish 结束升级任务并释放占用，err 为 nil 表示成功
+func (s *upgradeState) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.FinishedAt = time.Now()
+	if err != nil {
+		s.status.Status = upgradeFailed
+		s.status.Error = err.Error()
+		return
+	}
+	s.status.Status = upgradeCompleted
+	s.status.Percent = 100
+}
+
+func (s *upgradeState) snapshot() UpgradeStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.status
+	if out.Status == "" {
+		out.Status = upgradeIdle
+	}
+	return out
+}
+
+// getUpgradeStatus 查询升级进度，页面刷新后可据此恢复进度展示
+func (uc *Usecase) getUpgradeStatus(_ *gin.Context, _ *struct{}) (UpgradeStatus, error) {
+	return uc.upgrade.snapshot(), nil
+}
+
+// uploadUpgrade 离线升级，上传 Release 中的 tar.gz 升级包，校验后直接 解压/备份/替换
+// 表单字段 file 为升级包，checksum 可选（sha256），提供时先校验再安装
+// 与在线升级共用互斥状态，进行中返回 409
+func (uc *Usecase) uploadUpgrade(c *gin.Context) {
+	if !uc.upgrade.tryStart() {
+		c.JSON(http.StatusConflict, gin.H{"msg": "升级正在进行中"})
+		return
+	}
+	uc.upgrade.setStatus(upgradeInstalling)
+
+	err := installUpgradePackage(c, system.Getwd(), uploadLimit(uc.Conf.Server.HTTP))
+	uc.upgrade.finish(err)
+	if err != nil {
+		status := http.StatusBadRequest
+		if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "升级完成，请手动重启服务"})
+}
+
+// installUpgradePackage 保存上传的升级包到临时文件并安装到 dir，临时文件在返回前删除
+func installUpgradePackage(c *gin.Context, dir string, limit int64) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return err
+	}
+	// 超出内存上限的表单部分会落盘，安装结束后一并清理
+	defer c.Request.MultipartForm.RemoveAll()
+	if !strings.HasSuffix(fh.Filename, ".tar.gz") && !strings.HasSuffix(fh.Filename, ".tgz") {
+		return errors.New("升级包需为 .tar.gz 格式")
+	}
+
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(dir, ".upgrade_*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if checksum := c.PostForm("checksum"); checksum != "" {
+		if err := ota.VerifyChecksum(tmp.Name(), checksum); err != nil {
+			return err
+		}
+	}
+
+	return ota.NewLocalUpgrade(tmp.Name(), dir, "").Validate().Unzip().Backup().Replace().Error()
+}
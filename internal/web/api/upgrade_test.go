@@ -0,0 +1,181 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/conf"
+)
+
+func TestUpgradeRejectsConcurrent(t *testing.T) {
+	var uc Usecase
+
+	var wg sync.WaitGroup
+	var started atomic.Int32
+	for range 16 {
+		wg.Go(func() {
+			if uc.upgrade.tryStart() {
+				started.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if n := started.Load(); n != 1 {
+		t.Fatalf("expect exactly one upgrade started, got %d", n)
+	}
+
+	// 升级进行中，再次调用接口应返回 409
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/app/upgrade", nil)
+	uc.upgradeApp(c)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expect 409, got %d", w.Code)
+	}
+
+	uc.upgrade.progress(50, 200)
+	if s := uc.upgrade.snapshot(); !s.Running || s.Percent != 25 || s.Status != upgradeDownloading {
+		t.Fatalf("unexpected status %+v", s)
+	}
+
+	uc.upgrade.finish(errors.New("network error"))
+	if s := uc.upgrade.snapshot(); s.Running || s.Status != upgradeFailed || s.Error == "" {
+		t.Fatalf("unexpected status after failure %+v", s)
+	}
+	if !uc.upgrade.tryStart() {
+		t.Fatal("expect upgrade can start again after previous one finished")
+	}
+}
+
+// buildUpgradePackage 按顺序写入 tar.gz，以 / 结尾的条目为目录
+func buildUpgradePackage(t *testing.T, entries [][2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		name, body := e[0], e[1]
+		h := &tar.Header{Name: name, Mode: 0o755, Typeflag: tar.TypeDir}
+		if name[len(name)-1] != '/' {
+			h = &tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func postUpgradePackage(t *testing.T, uc *Usecase, filename string, pkg []byte, checksum string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write(pkg)
+	if checksum != "" {
+		_ = mw.WriteField("checksum", checksum)
+	}
+	_ = mw.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/app/upgrade/upload", &body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	uc.uploadUpgrade(c)
+	return w
+}
+
+func TestUploadUpgrade(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	execName := filepath.Base(os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, execName), []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := buildUpgradePackage(t, [][2]string{
+		{"owl_linux_amd64/", ""},
+		{"owl_linux_amd64/" + execName, "new"},
+		{"owl_linux_amd64/www/", ""},
+		{"owl_linux_amd64/www/index.html", "<html>"},
+	})
+	sum := sha256.Sum256(valid)
+
+	uc := Usecase{Conf: &conf.Bootstrap{}}
+	t.Run("bad checksum", func(t *testing.T) {
+		w := postUpgradePackage(t, &uc, "owl.tar.gz", valid, hex.EncodeToString(make([]byte, 32)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expect 400, got %d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("path traversal", func(t *testing.T) {
+		pkg := buildUpgradePackage(t, [][2]string{{execName, "new"}, {"../evil", "x"}})
+		if w := postUpgradePackage(t, &uc, "owl.tar.gz", pkg, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("expect 400, got %d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("missing executable", func(t *testing.T) {
+		pkg := buildUpgradePackage(t, [][2]string{{"owl/www/index.html", "<html>"}})
+		if w := postUpgradePackage(t, &uc, "owl.tar.gz", pkg, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("expect 400, got %d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("not tar.gz", func(t *testing.T) {
+		if w := postUpgradePackage(t, &uc, "owl.zip", valid, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("expect 400, got %d %s", w.Code, w.Body)
+		}
+	})
+	if b, _ := os.ReadFile(filepath.Join(dir, execName)); string(b) != "old" {
+		t.Fatalf("rejected package must not touch executable, got %q", b)
+	}
+
+	t.Run("install", func(t *testing.T) {
+		w := postUpgradePackage(t, &uc, "owl.tar.gz", valid, "sha256:"+hex.EncodeToString(sum[:]))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expect 200, got %d %s", w.Code, w.Body)
+		}
+		for file, expect := range map[string]string{
+			execName:          "new",
+			execName + ".bak": "old",
+			"www/index.html":  "<html>",
+		} {
+			if b, _ := os.ReadFile(filepath.Join(dir, file)); string(b) != expect {
+				t.Fatalf("%s expect %q, got %q", file, expect, b)
+			}
+		}
+		if s := uc.upgrade.snapshot(); s.Running || s.Status != upgradeCompleted {
+			t.Fatalf("unexpected status %+v", s)
+		}
+		tmp, _ := filepath.Glob(filepath.Join(dir, ".upgrade_*"))
+		if len(tmp) != 0 {
+			t.Fatalf("temp package not removed: %v", tmp)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/url"
 	"path/filepath"
@@ -10,10 +11,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/bz"
 	"github.com/gowvp/owl/internal/core/ipc"
 	"github.com/gowvp/owl/internal/core/recording"
 	"github.com/gowvp/owl/internal/core/sms"
 	"github.com/gowvp/owl/pkg/gbs"
+	"github.com/gowvp/owl/pkg/zlm"
+	"github.com/ixugo/goddd/pkg/conc"
 	"github.com/ixugo/goddd/pkg/orm"
 	"github.com/ixugo/goddd/pkg/web"
 )
@@ -28,6 +32,13 @@ type WebHookAPI struct {
 	uc            *Usecase
 
 	protocols map[string]ipc.Protocoler
+
+	// noneReaders 首次收到无人观看事件的时间，key 为 app/stream，用于按通道延迟关流
+	noneReaders *conc.Map[string, time.Time]
+	// recordNotifier 录像入库后通知外部系统，未配置时为 nil
+	recordNotifier *recordNotifier
+	// recordThumbs 录像封面抓图，不依赖 AI 检测
+	recordThumbs *recordThumbs
 }
 
 func NewWebHookAPI(core sms.Core, conf *conf.Bootstrap, gbs *gbs.Server, ipcBundle IPCBundle, recordingCore recording.Core) WebHookAPI {
@@ -39,6 +50,31 @@ func NewWebHookAPI(core sms.Core, conf *conf.Bootstrap, gbs *gbs.Server, ipcBund
 		log:           slog.With("hook", "zlm"),
 		gbs:           gbs,
 		protocols:     ipcBundle.Protocols,
+		noneReaders:   conc.NewMap[string, time.Time](),
+		recordNotifier: newRecordNotifier(
+			conf.Server.Recording.NotifyURL,
+			conf.Server.Recording.NotifyRetries,
+			func(rec *recording.Recording) string {
+				return RecordingAPI{recordingCore: recordingCore, conf: conf}.recordingURI(rec, "")
+			},
+		),
+		recordThumbs: newRecordThumbs(func(ctx context.Context, serverID, app, stream string) ([]byte, error) {
+			if serverID == "" {
+				serverID = sms.DefaultMediaServerID
+			}
+			svr, err := core.GetMediaServer(ctx, serverID)
+			if err != nil {
+				return nil, err
+			}
+			return core.GetSnapshot(svr, sms.GetSnapRequest{
+				GetSnapRequest: zlm.GetSnapRequest{
+					URL:        fmt.Sprintf("rtsp://%s:%d/%s/%s", "127.0.0.1", svr.Ports.RTSP, app, stream),
+					TimeoutSec: 10,
+					ExpireSec:  1,
+				},
+				Stream: stream,
+			})
+		}),
 	}
 }
 
@@ -71,6 +107,8 @@ func (w WebHookAPI) getChannelType(ctx context.Context, app, stream string) stri
 
 func (w WebHookAPI) onServerStarted(c *gin.Context, _ *struct{}) (DefaultOutput, error) {
 	w.log.InfoContext(c.Request.Context(), "webhook onServerStarted")
+	// 流媒体重启后配置文件可能已修改，清除缓存的端口配置
+	w.smsCore.InvalidateConfig(sms.DefaultMediaServerID)
 	// 所有 rtmp 通道离线
 	if err := w.ipcCore.BatchOfflineRTMP(context.Background()); err != nil {
 		w.log.ErrorContext(c.Request.Context(), "webhook onServerStarted", "err", err)
@@ -161,20 +199,35 @@ func (w WebHookAPI) onStreamChanged(c *gin.Context, in *onStreamChangedInput) (D
 			// 找不到通道时仍尝试按旧逻辑启动录制
 			if err := w.recordingCore.StartRecording(ctx, channelType, app, stream); err != nil {
 				w.log.WarnContext(ctx, "启动录制失败", "stream", stream, "err", err)
+			} else {
+				w.recordThumbs.Start(in.MediaServerID, app, stream)
 			}
 			return newDefaultOutputOK(), nil
 		}
 
+		if len(in.Tracks) > 0 {
+			w.updateChannelCodec(ctx, ch, stream, sms.ZLMTracksCodec(in.Tracks))
+		} else {
+			go w.queryChannelCodec(ch, app, stream)
+		}
+		w.ipcCore.TouchFrame(ch.ID, time.Now())
+
 		if !ch.Ext.IsNoneRecord() {
 			// always 模式：自动启动录制
 			if err := w.recordingCore.StartRecording(ctx, channelType, app, stream); err != nil {
 				w.log.WarnContext(ctx, "启动录制失败", "stream", stream, "err", err)
+			} else {
+				w.recordThumbs.Start(in.MediaServerID, app, stream)
 			}
 			w.log.InfoContext(ctx, "自动启动录制（always模式）", "stream", stream)
 		}
 		return newDefaultOutputOK(), nil
 	}
 
+	w.noneReaders.Delete(app + "/" + stream)
+	w.recordThumbs.Stop(app, stream)
+	w.smsCore.InvalidateMediaInfo(app, stream)
+
 	// 流注销时停止录制
 	if err := w.recordingCore.StopRecording(ctx, app, stream); err != nil {
 		w.log.WarnContext(ctx, "停止录制失败", "stream", stream, "err", err)
@@ -191,6 +244,39 @@ func (w WebHookAPI) onStreamChanged(c *gin.Context, in *onStreamChangedInput) (D
 	return newDefaultOutputOK(), nil
 }
 
+// codecQueryTimeout 向流媒体查询流编码的超时时间
+const codecQueryTimeout = 5 * time.Second
+
+// queryChannelCodec lalmax 注册事件不带轨道信息，需向流媒体查询
+// 在 webhook 应答之外异步执行，避免流媒体响应慢时阻塞回调
+func (w WebHookAPI) queryChannelCodec(ch *ipc.Channel, app, stream string) {
+	ctx, cancel := context.WithTimeout(context.Background(), codecQueryTimeout)
+	defer cancel()
+	svr, err := w.smsCore.GetMediaServer(ctx, sms.DefaultMediaServerID)
+	if err != nil {
+		return
+	}
+	codec, err := w.smsCore.GetStreamCodec(ctx, svr, app, stream)
+	if err != nil {
+		w.log.WarnContext(ctx, "查询流编码失败", "stream", stream, "err", err)
+		return
+	}
+	w.updateChannelCodec(ctx, ch, stream, codec)
+}
+
+// updateChannelCodec 记录流的编码信息
+// 每种协议注册都会触发一次，编码未变化时不重复写库
+func (w WebHookAPI) updateChannelCodec(ctx context.Context, ch *ipc.Channel, stream string, codec *bz.StreamCodec) {
+	if codec.IsEmpty() || (ch.Config.Codec != nil && *ch.Config.Codec == *codec) {
+		return
+	}
+	if _, err := w.ipcCore.EditChannelConfig(ctx, ch.ID, func(cfg *ipc.StreamConfig) {
+		cfg.Codec = codec
+	}); err != nil {
+		w.log.WarnContext(ctx, "更新通道编码失败", "stream", stream, "err", err)
+	}
+}
+
 // onPlay rtsp/rtmp/http-flv/ws-flv/hls 播放触发播放器身份验证事件。
 // 播放流时会触发此事件。如果流不存在，则首先触发 on_play 事件，然后触发 on_stream_not_found 事件。
 // 播放rtsp流时，如果该流开启了rtsp专用认证（on_rtsp_realm），则不会触发on_play事件。
@@ -199,8 +285,11 @@ func (w WebHookAPI) onPlay(c *gin.Context, in *onPublishInput) (DefaultOutput, e
 	ctx := c.Request.Context()
 	w.log.InfoContext(ctx, "webhook onPlay", "app", in.App, "stream", in.Stream, "schema", in.Schema)
 
+	// 有人观看，重新计算无人观看的延迟
+	w.noneReaders.Delete(in.App + "/" + in.Stream)
+
 	// 更新通道的播放状态（所有协议统一处理）
-	if _, err := w.ipcCore.EditChannelPlaying(ctx, in.Stream, true); err != nil {
+	if _, err := w.ipcCore.EditChannelPlaying(ctx, in.App, in.Stream, true); err != nil {
 		w.log.WarnContext(ctx, "更新播放状态失败", "stream", in.Stream, "err", err)
 	}
 
@@ -219,13 +308,11 @@ func (w WebHookAPI) onStreamNoneReader(c *gin.Context, in *onStreamNoneReaderInp
 	w.log.InfoContext(ctx, "webhook onStreamNoneReader", "app", in.App, "stream", in.Stream, "mediaServerID", in.MediaServerID)
 
 	// 更新通道的播放状态为未播放（所有协议统一处理）
-	if _, err := w.ipcCore.EditChannelPlaying(ctx, in.Stream, false); err != nil {
+	if _, err := w.ipcCore.EditChannelPlaying(ctx, in.App, in.Stream, false); err != nil {
 		w.log.WarnContext(ctx, "更新播放状态失败", "stream", in.Stream, "err", err)
 	}
 
-	// 根据录像模式判断是否关闭流：
-	// - none(不录制): 无人观看时关闭流
-	// - always/ai(有录像计划): 无人观看时保持流不关闭
+	// 由通道所属协议判断是否保持流，例如常驻拉流的 RTSP 保持，临时点播的国标关闭
 	ch, err := w.ipcCore.GetChannelByAppStreamOrID(ctx, in.App, in.Stream)
 	if err != nil {
 		// 找不到通道时默认关闭流
@@ -233,18 +320,43 @@ func (w WebHookAPI) onStreamNoneReader(c *gin.Context, in *onStreamNoneReaderInp
 		return onStreamNoneReaderOutput{Close: true}, nil
 	}
 
-	// 如果录像模式为 none，则关闭流；否则保持流不关闭以继续录制
-	shouldClose := ch.Ext.IsNoneRecord()
-	w.log.InfoContext(ctx, "无人观看判断", "stream", in.Stream, "record_mode", ch.Ext.GetRecordMode(), "close", shouldClose)
+	shouldClose := !ipc.ShouldKeepAlive(ctx, w.protocols[ch.Type], ch)
+	if shouldClose {
+		// 通道配置了额外保持时长时，在时长内拒绝关闭，流媒体会在下一个无人观看周期再次询问
+		key := in.App + "/" + in.Stream
+		first, _ := w.noneReaders.LoadOrStore(key, time.Now())
+		shouldClose = noneReaderExpired(first, time.Now(), ch.Ext.GetNoneReaderDelay())
+		if shouldClose {
+			w.noneReaders.Delete(key)
+			w.smsCore.InvalidateMediaInfo(in.App, in.Stream)
+		}
+	}
+	w.log.InfoContext(ctx, "无人观看判断", "stream", in.Stream, "record_mode", ch.Ext.GetRecordMode(), "none_reader_delay", ch.Ext.NoneReaderDelay, "close", shouldClose)
 
 	return onStreamNoneReaderOutput{Close: shouldClose}, nil
 }
 
+// noneReaderExpired 自首次无人观看起已超过通道的保持时长，delay 为 0 时立即关闭
+func noneReaderExpired(first, now time.Time, delay time.Duration) bool {
+	return now.Sub(first) >= delay
+}
+
 // onRTPServerTimeout RTP 服务器超时事件
 // 调用 openRtpServer 接口，rtp server 长时间未收到数据,执行此 web hook,对回复不敏感
+// 国标点播 INVITE 成功但设备始终未推流时，流不会注册也就不会注销，需在此结束会话并释放点播名额
 // https://docs.zlmediakit.com/zh/guide/media_server/web_hook_api.html#_17%E3%80%81on-rtp-server-timeout
 func (w WebHookAPI) onRTPServerTimeout(c *gin.Context, in *onRTPServerTimeoutInput) (DefaultOutput, error) {
-	w.log.InfoContext(c.Request.Context(), "webhook onRTPServerTimeout", "local_port", in.LocalPort, "ssrc", in.SSRC, "stream_id", in.StreamID, "mediaServerID", in.MediaServerID)
+	ctx := c.Request.Context()
+	w.log.InfoContext(ctx, "webhook onRTPServerTimeout", "local_port", in.LocalPort, "ssrc", in.SSRC, "stream_id", in.StreamID, "mediaServerID", in.MediaServerID)
+
+	// openRtpServer 仅用于国标点播，stream 即通道 ID
+	if protocol, ok := w.protocols[ipc.TypeGB28181]; ok {
+		if err := protocol.OnStreamChanged(ctx, "rtp", in.StreamID); err != nil {
+			w.log.WarnContext(ctx, "RTP 超时结束点播失败", "stream_id", in.StreamID, "err", err)
+		}
+	}
+	// 会话已不在内存(如设备已删除)时 StopPlay 不会释放名额，此处兜底
+	w.smsCore.ReleasePlay(in.StreamID)
 	return newDefaultOutputOK(), nil
 }
 
@@ -293,7 +405,11 @@ func (w WebHookAPI) onRecordMP4(c *gin.Context, in *onRecordMP4Input) (DefaultOu
 
 	// 计算相对路径：从配置的存储目录开始
 	relativePath := in.FilePath
-	if w.conf.Server.Recording.StorageDir != "" {
+	nodeDir, isNode := w.recordingCore.NodeStorageDir(in.MediaServerID)
+	if isNode {
+		// 其它节点的录像以该节点 http 根目录为基准，URL 字段即相对路径
+		relativePath = in.URL
+	} else if w.conf.Server.Recording.StorageDir != "" {
 		// 尝试提取相对路径
 		storageDir := w.conf.Server.Recording.StorageDir
 		if idx := strings.Index(in.FilePath, storageDir); idx >= 0 {
@@ -305,6 +421,7 @@ func (w WebHookAPI) onRecordMP4(c *gin.Context, in *onRecordMP4Input) (DefaultOu
 	}
 
 	// 计算开始和结束时间
+	// 入库沿用服务器本地时区，与历史数据一致，按天统计时再转换为配置的时区
 	startTime := time.Unix(in.StartTime, 0)
 	endTime := startTime.Add(time.Duration(in.TimeLen * float64(time.Second)))
 
@@ -319,8 +436,16 @@ func (w WebHookAPI) onRecordMP4(c *gin.Context, in *onRecordMP4Input) (DefaultOu
 		w.log.WarnContext(ctx, "未找到对应通道，使用 stream 作为 CID", "app", in.App, "stream", in.Stream)
 	}
 
+	// 本节点的空切片说明流媒体写文件失败，其它节点的磁盘不由本节点监控
+	if !isNode {
+		w.recordingCore.ReportRecordWrite(ctx, cid, in.FileSize)
+	}
+
+	// 录像附带流开始录制时抓取的封面，其它节点的封面写到该节点录像目录下
+	thumbnail := w.recordThumbs.Attach(in.App, in.Stream, filepath.Clean(relativePath), nodeDir, endTime)
+
 	// 入库
-	_, err = w.recordingCore.AddRecording(ctx, &recording.AddRecordingInput{
+	rec, err := w.recordingCore.AddRecording(ctx, &recording.AddRecordingInput{
 		CID:       cid,
 		App:       in.App,
 		Stream:    in.Stream,
@@ -329,11 +454,16 @@ func (w WebHookAPI) onRecordMP4(c *gin.Context, in *onRecordMP4Input) (DefaultOu
 		Duration:  in.TimeLen,
 		Path:      filepath.Clean(relativePath),
 		Size:      in.FileSize,
+		Thumbnail: thumbnail,
+
+		MediaServerID: in.MediaServerID,
 	})
 	if err != nil {
 		w.log.ErrorContext(ctx, "录像入库失败", "err", err)
 		// 仍返回成功，避免 ZLM 重试
+		return newDefaultOutputOK(), nil
 	}
+	w.recordNotifier.Notify(rec)
 
 	return newDefaultOutputOK(), nil
 }
@@ -1,5 +1,7 @@
 package api
 
+import "github.com/gowvp/owl/pkg/zlm"
+
 // 注销
 //	{
 //		"mediaServerId" : "your_server_id",
@@ -54,22 +56,22 @@ package api
 //	    "vhost": "__defaultVhost__"
 //	}
 type onStreamChangedInput struct {
-	Regist           bool       `json:"regist"`
-	AliveSecond      int        `json:"aliveSecond"`
-	App              string     `json:"app"`
-	BytesSpeed       int        `json:"bytesSpeed"`
-	CreateStamp      int        `json:"createStamp"`
-	MediaServerID    string     `json:"mediaServerId"`
-	OriginSock       OriginSock `json:"originSock"`
-	OriginType       int        `json:"originType"`
-	OriginTypeStr    string     `json:"originTypeStr"`
-	OriginURL        string     `json:"originUrl"`
-	ReaderCount      int        `json:"readerCount"`
-	Schema           string     `json:"schema"`
-	Stream           string     `json:"stream"`
-	TotalReaderCount int        `json:"totalReaderCount"`
-	Tracks           []Tracks   `json:"tracks"`
-	Vhost            string     `json:"vhost"`
+	Regist           bool             `json:"regist"`
+	AliveSecond      int              `json:"aliveSecond"`
+	App              string           `json:"app"`
+	BytesSpeed       int              `json:"bytesSpeed"`
+	CreateStamp      int              `json:"createStamp"`
+	MediaServerID    string           `json:"mediaServerId"`
+	OriginSock       OriginSock       `json:"originSock"`
+	OriginType       int              `json:"originType"`
+	OriginTypeStr    string           `json:"originTypeStr"`
+	OriginURL        string           `json:"originUrl"`
+	ReaderCount      int              `json:"readerCount"`
+	Schema           string           `json:"schema"`
+	Stream           string           `json:"stream"`
+	TotalReaderCount int              `json:"totalReaderCount"`
+	Tracks           []zlm.MediaTrack `json:"tracks"`
+	Vhost            string           `json:"vhost"`
 
 	// 以下字段为 lalmax 新增
 	AppName    string `json:"app_name"`    // 流应用名
@@ -82,18 +84,6 @@ type OriginSock struct {
 	PeerIP     string `json:"peer_ip"`
 	PeerPort   int    `json:"peer_port"`
 }
-type Tracks struct {
-	Channels    int     `json:"channels,omitempty"`
-	CodecID     int     `json:"codec_id"`
-	CodecIDName string  `json:"codec_id_name"`
-	CodecType   int     `json:"codec_type"`
-	Ready       bool    `json:"ready"`
-	SampleBit   int     `json:"sample_bit,omitempty"`
-	SampleRate  int     `json:"sample_rate,omitempty"`
-	Fps         float32 `json:"fps,omitempty"`
-	Height      int     `json:"height,omitempty"`
-	Width       int     `json:"width,omitempty"`
-}
 
 // 心跳
 // {
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/sms"
+)
+
+func TestNoneReaderExpired(t *testing.T) {
+	first := time.Now()
+	tests := []struct {
+		name    string
+		ext     ipc.DeviceExt
+		elapsed time.Duration
+		expect  bool
+	}{
+		{name: "default closes immediately", ext: ipc.DeviceExt{}, elapsed: 0, expect: true},
+		{name: "keep within delay", ext: ipc.DeviceExt{NoneReaderDelay: 120}, elapsed: 30 * time.Second, expect: false},
+		{name: "close after delay", ext: ipc.DeviceExt{NoneReaderDelay: 120}, elapsed: 120 * time.Second, expect: true},
+		{name: "negative treated as zero", ext: ipc.DeviceExt{NoneReaderDelay: -5}, elapsed: 0, expect: true},
+		{name: "capped at max", ext: ipc.DeviceExt{NoneReaderDelay: ipc.NoneReaderDelayMax * 2}, elapsed: ipc.NoneReaderDelayMax * time.Second, expect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := noneReaderExpired(first, first.Add(tt.elapsed), tt.ext.GetNoneReaderDelay())
+			if got != tt.expect {
+				t.Fatalf("expect close=%v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+// stopRecorder 记录流注销通知，其余 Protocoler 方法未实现
+type stopRecorder struct {
+	ipc.Protocoler
+	streams []string
+}
+
+func (s *stopRecorder) OnStreamChanged(_ context.Context, _, stream string) error {
+	s.streams = append(s.streams, stream)
+	return nil
+}
+
+func TestRTPServerTimeoutReleasePlay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	nm := sms.NewNodeManager(nil)
+	svr := sms.MediaServer{ID: sms.DefaultMediaServerID, MaxPlays: 1}
+	if err := nm.AcquirePlay(&svr, "ch1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var gb stopRecorder
+	w := WebHookAPI{
+		smsCore:   sms.Core{NodeManager: nm},
+		log:       slog.Default(),
+		protocols: map[string]ipc.Protocoler{ipc.TypeGB28181: &gb},
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/on_rtp_server_timeout", nil)
+	if _, err := w.onRTPServerTimeout(c, &onRTPServerTimeoutInput{StreamID: "ch1", MediaServerID: svr.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gb.streams) != 1 || gb.streams[0] != "ch1" {
+		t.Fatalf("expect play session stopped, got %v", gb.streams)
+	}
+	if n := nm.ActivePlays(svr.ID); n != 0 {
+		t.Fatalf("expect play slot released, got %d", n)
+	}
+	if err := nm.AcquirePlay(&svr, "ch2"); err != nil {
+		t.Fatalf("expect new play accepted after timeout, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package ffwork
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Backpressure 帧通道已满（消费者处理不过来）时的处理策略
+type Backpressure string
+
+const (
+	// DropNewest 丢弃新帧，队列中保留较早的帧
+	DropNewest Backpressure = "drop_newest"
+	// DropOldest 丢弃队列中最旧的帧，消费者总能拿到最新画面
+	DropOldest Backpressure = "drop_oldest"
+	// Block 阻塞等待消费者，超过 BlockTimeout 仍无法写入时丢弃新帧
+	// 超时后直到消费者取走帧之前不再等待，直接丢弃新帧，避免停滞的消费者每帧都拖住采集
+	Block Backpressure = "block"
+)
+
+const (
+	// defaultBlockTimeout Block 策略默认等待时间
+	defaultBlockTimeout = time.Second
+	// defaultFrameBuffer 帧通道默认缓冲帧数
+	defaultFrameBuffer = 10
+)
+
+func (b Backpressure) valid() bool {
+	switch b {
+	case DropNewest, DropOldest, Block:
+		return true
+	}
+	return false
+}
+
+// frameSink 一个帧通道及其写入策略与丢帧统计
+type frameSink struct {
+	ch      chan *FrameData
+	policy  Backpressure
+	timeout time.Duration
+
+	dropNewest   atomic.Uint64
+	dropOldest   atomic.Uint64
+	blockTimeout atomic.Uint64
+	// stalled Block 策略上次等待超时，消费者取走帧前不再等待
+	stalled atomic.Bool
+}
+
+// newFrameSink 校验策略参数，零值使用默认值
+func newFrameSink(buffer int, policy Backpressure, timeout time.Duration) (*frameSink, error) {
+	if buffer <= 0 {
+		return nil, fmt.Errorf("invalid buffer: %d", buffer)
+	}
+	if policy == "" {
+		policy = DropNewest
+	}
+	if !policy.valid() {
+		return nil, fmt.Errorf("invalid backpressure: %q", policy)
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("invalid block timeout: %s", timeout)
+	}
+	if timeout == 0 {
+		timeout = defaultBlockTimeout
+	}
+	return &frameSink{ch: make(chan *FrameData, buffer), policy: policy, timeout: timeout}, nil
+}
+
+// push 按策略写入帧，ctx 结束时返回 false
+func (s *frameSink) push(ctx context.Context, frame *FrameData) bool {
+	select {
+	case s.ch <- frame:
+		s.stalled.Store(false)
+		return true
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	switch s.policy {
+	case DropOldest:
+		// 消费者可能同时取走帧，腾出位置后重试，直到写入成功
+		for {
+			select {
+			case <-s.ch:
+				s.dropOldest.Add(1)
+			default:
+			}
+			select {
+			case s.ch <- frame:
+				return true
+			case <-ctx.Done():
+				return false
+			default:
+			}
+		}
+	case Block:
+		if s.stalled.Load() {
+			s.blockTimeout.Add(1)
+			return true
+		}
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- frame:
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			s.blockTimeout.Add(1)
+			s.stalled.Store(true)
+		}
+	default:
+		s.dropNewest.Add(1)
+	}
+	return true
+}
+
+// skipCount 丢弃的总帧数
+func (s *frameSink) skipCount() uint64 {
+	return s.dropNewest.Load() + s.dropOldest.Load() + s.blockTimeout.Load()
+}
+
+// deliver 将帧写入 FrameCh 与所有订阅者，采集已停止时返回 false
+// 订阅者共享同一帧，不应修改 Data；Block 策略的通道停滞时最多拖慢采集一个 BlockTimeout
+func (fc *FrameCapture) deliver(frame *FrameData) bool {
+	if !fc.sink.push(fc.ctx, frame) {
+		return false
+	}
+	fc.subsM.RLock()
+	defer fc.subsM.RUnlock()
+	for _, sub := range fc.subs {
+		if !sub.sink.push(fc.ctx, frame) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package ffwork
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestCapture(t *testing.T, b Backpressure, timeout time.Duration) *FrameCapture {
+	t.Helper()
+	fc, err := NewFrameCapture(Config{
+		Width: 16, Height: 16, FPS: 1, RTSPURL: "rtsp://127.0.0.1/live/ch1",
+		Backpressure: b, BlockTimeout: timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fc.cancel)
+	return fc
+}
+
+// produce 连续写入 n 帧，帧号从 1 开始
+func produce(fc *FrameCapture, n int) {
+	for i := 1; i <= n; i++ {
+		fc.deliver(&FrameData{FrameNum: uint64(i)})
+	}
+}
+
+// drain 取出队列中已有的帧号
+func drain(fc *FrameCapture) []uint64 {
+	var out []uint64
+	for {
+		select {
+		case f := <-fc.FrameCh:
+			out = append(out, f.FrameNum)
+		default:
+			return out
+		}
+	}
+}
+
+func TestBackpressureDropNewest(t *testing.T) {
+	fc := newTestCapture(t, "", 0)
+	produce(fc, 15)
+
+	got := drain(fc)
+	if len(got) != 10 || got[0] != 1 || got[9] != 10 {
+		t.Fatalf("expect frames 1..10 kept, got %v", got)
+	}
+	s := fc.GetStats()
+	if s.Backpressure != DropNewest || s.DropNewestCount != 5 || s.SkipCount != 5 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	fc := newTestCapture(t, DropOldest, 0)
+	produce(fc, 15)
+
+	got := drain(fc)
+	if len(got) != 10 || got[0] != 6 || got[9] != 15 {
+		t.Fatalf("expect frames 6..15 kept, got %v", got)
+	}
+	s := fc.GetStats()
+	if s.DropOldestCount != 5 || s.DropNewestCount != 0 || s.SkipCount != 5 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	// 慢消费者在超时前取帧，不丢帧
+	fc := newTestCapture(t, Block, time.Second)
+	done := make(chan []uint64)
+	go func() {
+		var out []uint64
+		for len(out) < 15 {
+			f := <-fc.FrameCh
+			out = append(out, f.FrameNum)
+			time.Sleep(2 * time.Millisecond)
+		}
+		done <- out
+	}()
+	produce(fc, 15)
+	got := <-done
+	for i, v := range got {
+		if v != uint64(i+1) {
+			t.Fatalf("expect frames in order without loss, got %v", got)
+		}
+	}
+	if s := fc.GetStats(); s.SkipCount != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	// 消费者停滞，等待超时后丢弃新帧
+	fc = newTestCapture(t, Block, 5*time.Millisecond)
+	produce(fc, 12)
+	if got := drain(fc); len(got) != 10 || got[9] != 10 {
+		t.Fatalf("expect frames 1..10 kept, got %v", got)
+	}
+	if s := fc.GetStats(); s.BlockTimeoutCount != 2 || s.SkipCount != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBackpressureBlockStalled(t *testing.T) {
+	// FrameCh 无人读取时，仅第一次溢出等待超时，之后直接丢帧，订阅者不被拖住
+	fc := newTestCapture(t, Block, 200*time.Millisecond)
+	sub, err := fc.Subscribe(SubscribeOptions{Buffer: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		produce(fc, 30)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stalled FrameCh blocks the producer on every frame")
+	}
+	if s := fc.GetStats(); s.BlockTimeoutCount != 20 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if n := len(sub.C); n != 30 {
+		t.Fatalf("expect 30 frames for subscriber, got %d", n)
+	}
+
+	// 消费者恢复后重新按阻塞策略等待
+	drain(fc)
+	if !fc.sink.push(fc.ctx, &FrameData{FrameNum: 31}) || fc.sink.stalled.Load() {
+		t.Fatal("expect stalled state cleared after consumer drained")
+	}
+}
+
+func TestBackpressureInvalid(t *testing.T) {
+	cfg := Config{Width: 16, Height: 16, FPS: 1, RTSPURL: "rtsp://127.0.0.1/live/ch1", Backpressure: "latest"}
+	if _, err := NewFrameCapture(cfg); err == nil {
+		t.Fatal("expect invalid backpressure rejected")
+	}
+}
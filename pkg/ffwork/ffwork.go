@@ -19,11 +19,15 @@ type (
 		Width, Height int
 		FPS           int
 		RTSPURL       string
-		Transport     string
+		Transport     string        // 为空使用 Defaults().Transport
+		Timeout       time.Duration // 为 0 使用 Defaults().Timeout
+		ProbeSize     int64         // 为 0 使用 Defaults().ProbeSize
 		UseWallClock  bool
 		HWAccel       string
 		OnFrame       func(frame *FrameData)
 		Name          string
+		Backpressure  Backpressure  // FrameCh 已满时的处理策略，为空使用 DropNewest
+		BlockTimeout  time.Duration // Block 策略下的最长等待时间，为 0 使用 1s
 	}
 	FrameData struct {
 		FrameNum  uint64
@@ -31,21 +35,25 @@ type (
 		Data      []byte
 	}
 	FrameCapture struct {
-		Name                  string
-		config                Config
-		frameSize             int
-		FrameCh               chan *FrameData
-		errCh                 chan error
-		ctx                   context.Context
-		cancel                context.CancelFunc
-		m                     sync.Mutex
-		started               bool
-		cmd                   *exec.Cmd
-		lastFrame             time.Time
-		wg                    sync.WaitGroup
-		ffmpegLog             *queue.CirQueue[string]
-		frameCount, skipCount uint64
-		OnFrame               func(frame *FrameData)
+		Name       string
+		config     Config
+		frameSize  int
+		FrameCh    chan *FrameData
+		errCh      chan error
+		ctx        context.Context
+		cancel     context.CancelFunc
+		m          sync.Mutex
+		started    bool
+		cmd        *exec.Cmd
+		lastFrame  time.Time
+		wg         sync.WaitGroup
+		ffmpegLog  *queue.CirQueue[string]
+		frameCount uint64
+		sink       *frameSink // FrameCh 的写入策略与丢帧统计
+		subsM      sync.RWMutex
+		subs       []*Subscription
+		closed     bool // 采集已结束，订阅者通道已关闭
+		OnFrame    func(frame *FrameData)
 	}
 	Stats struct {
 		Name                  string
@@ -53,6 +61,11 @@ type (
 		LastFrame             time.Time
 		FrameSize             int
 		IsRunning             bool
+		Backpressure          Backpressure
+		// 各策略丢弃的帧数，之和为 SkipCount
+		DropNewestCount   uint64 // 丢弃新帧
+		DropOldestCount   uint64 // 丢弃队列中最旧的帧
+		BlockTimeoutCount uint64 // 阻塞等待超时后丢弃新帧
 	}
 )
 
@@ -66,15 +79,23 @@ func NewFrameCapture(cfg Config) (*FrameCapture, error) {
 	if cfg.RTSPURL == "" {
 		return nil, fmt.Errorf("resp url is required")
 	}
-	if cfg.Transport == "" {
-		cfg.Transport = "tcp"
+	opt := Options{Transport: cfg.Transport, Timeout: cfg.Timeout, ProbeSize: cfg.ProbeSize}.merge(Defaults())
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Transport, cfg.Timeout, cfg.ProbeSize = opt.Transport, opt.Timeout, opt.ProbeSize
+	sink, err := newFrameSink(defaultFrameBuffer, cfg.Backpressure, cfg.BlockTimeout)
+	if err != nil {
+		return nil, err
 	}
+	cfg.Backpressure, cfg.BlockTimeout = sink.policy, sink.timeout
 	frameSize := cfg.Width * cfg.Height * 3 / 2
 	ctx, cancel := context.WithCancel(context.Background())
 	return &FrameCapture{
 		config:    cfg,
 		frameSize: frameSize,
-		FrameCh:   make(chan *FrameData, 10),
+		FrameCh:   sink.ch,
+		sink:      sink,
 		errCh:     make(chan error, 1),
 		ctx:       ctx,
 		cancel:    cancel,
@@ -97,8 +118,11 @@ func (fc *FrameCapture) buildFFmpegArgs() []string {
 	args = append(args, "-avoid_negative_ts", "make_zero",
 		"-fflags", "+genpts+discardcorrupt",
 		"-rtsp_transport", fc.config.Transport,
-		"-timeout", "10000000",
+		"-timeout", strconv.FormatInt(fc.config.Timeout.Microseconds(), 10),
 	)
+	if fc.config.ProbeSize > 0 {
+		args = append(args, "-probesize", strconv.FormatInt(fc.config.ProbeSize, 10))
+	}
 	if fc.config.UseWallClock {
 		args = append(args, "-use_wallclock_as_timestamps", "1")
 	}
@@ -148,7 +172,7 @@ func (fc *FrameCapture) Start() error {
 // captureLoop 从 ffmpeg 的 stdout 读取原始视频帧数据
 // ffmpeg 输出的是固定大小的 YUV420P 格式帧，需要按帧大小读取
 func (fc *FrameCapture) captureLoop(stdout io.Reader) {
-	defer close(fc.FrameCh)
+	defer fc.closeSinks()
 
 	reader := bufio.NewReaderSize(stdout, fc.frameSize*10)
 	for {
@@ -196,13 +220,8 @@ func (fc *FrameCapture) captureLoop(stdout io.Reader) {
 		if fc.OnFrame != nil {
 			fc.OnFrame(&frame)
 		}
-
-		select {
-		case fc.FrameCh <- &frame:
-		case <-fc.ctx.Done():
+		if !fc.deliver(&frame) {
 			return
-		default:
-			atomic.AddUint64(&fc.skipCount, 1)
 		}
 	}
 }
@@ -283,9 +302,14 @@ func (fc *FrameCapture) GetStats() Stats {
 	return Stats{
 		Name:       fc.config.Name,
 		FrameCount: atomic.LoadUint64(&fc.frameCount),
-		SkipCount:  atomic.LoadUint64(&fc.skipCount),
+		SkipCount:  fc.sink.skipCount(),
 		LastFrame:  fc.lastFrame,
 		FrameSize:  fc.frameSize,
 		IsRunning:  fc.started,
+
+		Backpressure:      fc.config.Backpressure,
+		DropNewestCount:   fc.sink.dropNewest.Load(),
+		DropOldestCount:   fc.sink.dropOldest.Load(),
+		BlockTimeoutCount: fc.sink.blockTimeout.Load(),
 	}
 }
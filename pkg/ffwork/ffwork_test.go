@@ -0,0 +1,91 @@
+package ffwork
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// argValue 返回参数 flag 后的值，不存在时返回空
+func argValue(args []string, flag string) string {
+	if i := slices.Index(args, flag); i >= 0 && i+1 < len(args) {
+		return args[i+1]
+	}
+	return ""
+}
+
+func TestBuildFFmpegArgs(t *testing.T) {
+	base := Config{Width: 320, Height: 180, FPS: 2, RTSPURL: "rtsp://127.0.0.1/live/ch1"}
+
+	fc, err := NewFrameCapture(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := fc.buildFFmpegArgs()
+	if v := argValue(args, "-rtsp_transport"); v != "tcp" {
+		t.Fatalf("expect default transport tcp, got %q", v)
+	}
+	if v := argValue(args, "-timeout"); v != "10000000" {
+		t.Fatalf("expect default timeout 10000000, got %q", v)
+	}
+	if slices.Contains(args, "-probesize") {
+		t.Fatal("expect no probesize by default")
+	}
+
+	// 包级默认值
+	t.Cleanup(func() { _ = SetDefaults(Options{}) })
+	if err := SetDefaults(Options{Timeout: 30 * time.Second, ProbeSize: 5 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	fc, err = NewFrameCapture(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args = fc.buildFFmpegArgs()
+	if v := argValue(args, "-timeout"); v != "30000000" {
+		t.Fatalf("expect timeout 30000000, got %q", v)
+	}
+	if v := argValue(args, "-probesize"); v != "5242880" {
+		t.Fatalf("expect probesize 5242880, got %q", v)
+	}
+
+	// 单次采集覆盖包级默认值
+	cfg := base
+	cfg.Transport, cfg.Timeout, cfg.ProbeSize = "udp", 5*time.Second, 1024
+	fc, err = NewFrameCapture(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args = fc.buildFFmpegArgs()
+	if v := argValue(args, "-rtsp_transport"); v != "udp" {
+		t.Fatalf("expect transport udp, got %q", v)
+	}
+	if v := argValue(args, "-timeout"); v != "5000000" {
+		t.Fatalf("expect timeout 5000000, got %q", v)
+	}
+	if v := argValue(args, "-probesize"); v != "1024" {
+		t.Fatalf("expect probesize 1024, got %q", v)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, o := range []Options{
+		{Transport: "quic", Timeout: time.Second},
+		{Transport: "tcp", Timeout: 500 * time.Millisecond},
+		{Transport: "tcp", Timeout: time.Second, ProbeSize: 16},
+	} {
+		if err := o.Validate(); err == nil {
+			t.Fatalf("expect invalid options %+v", o)
+		}
+	}
+	if err := SetDefaults(Options{Transport: "quic"}); err == nil {
+		t.Fatal("expect SetDefaults reject invalid transport")
+	}
+	if d := Defaults(); d.Transport != defaultTransport || d.Timeout != defaultTimeout {
+		t.Fatalf("invalid options must not change defaults, got %+v", d)
+	}
+	cfg := Config{Width: 320, Height: 180, FPS: 2, RTSPURL: "rtsp://127.0.0.1/live/ch1", Timeout: -time.Second}
+	if _, err := NewFrameCapture(cfg); err == nil {
+		t.Fatal("expect NewFrameCapture reject negative timeout")
+	}
+}
@@ -0,0 +1,72 @@
+package ffwork
+
+import "sync"
+
+const (
+	// defaultPixelThreshold 亮度差超过该值的像素视为变化像素，用于过滤噪点
+	defaultPixelThreshold = 25
+	// maxAreaThreshold 灵敏度为 0 时，变化像素占比需要超过的阈值
+	maxAreaThreshold = 0.05
+	// minAreaThreshold 灵敏度为 1 时的阈值，避免噪点持续触发
+	minAreaThreshold = 0.001
+)
+
+// MotionScore 计算两帧 YUV420P 图像 Y 平面的变化像素占比，返回 [0,1]
+// 帧尺寸不一致或数据不足时返回 0
+func MotionScore(prev, cur []byte, width, height int, pixelThreshold uint8) float64 {
+	size := width * height
+	if size <= 0 || len(prev) < size || len(cur) < size {
+		return 0
+	}
+	var changed int
+	for i := range size {
+		d := int(prev[i]) - int(cur[i])
+		if d < 0 {
+			d = -d
+		}
+		if d > int(pixelThreshold) {
+			changed++
+		}
+	}
+	return float64(changed) / float64(size)
+}
+
+// MotionDetector 基于帧差的运动检测，保存上一帧用于比较
+type MotionDetector struct {
+	width, height  int
+	pixelThreshold uint8
+	areaThreshold  float64
+
+	m    sync.Mutex
+	prev []byte
+}
+
+// NewMotionDetector sensitivity 取值 [0,1]，越大越灵敏
+func NewMotionDetector(width, height int, sensitivity float64) *MotionDetector {
+	sensitivity = min(max(sensitivity, 0), 1)
+	return &MotionDetector{
+		width:          width,
+		height:         height,
+		pixelThreshold: defaultPixelThreshold,
+		areaThreshold:  max(maxAreaThreshold*(1-sensitivity), minAreaThreshold),
+	}
+}
+
+// Detect 与上一帧比较，返回变化占比及是否判定为运动，首帧不判定为运动
+func (m *MotionDetector) Detect(frame []byte) (float64, bool) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	size := m.width * m.height
+	if len(frame) < size {
+		return 0, false
+	}
+	if m.prev == nil {
+		m.prev = make([]byte, size)
+		copy(m.prev, frame[:size])
+		return 0, false
+	}
+	score := MotionScore(m.prev, frame, m.width, m.height, m.pixelThreshold)
+	copy(m.prev, frame[:size])
+	return score, score >= m.areaThreshold
+}
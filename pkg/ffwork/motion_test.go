@@ -0,0 +1,78 @@
+package ffwork
+
+import "testing"
+
+// newFrame 生成 YUV420P 帧，Y 平面填充 luma
+func newFrame(width, height int, luma byte) []byte {
+	frame := make([]byte, width*height*3/2)
+	for i := range width * height {
+		frame[i] = luma
+	}
+	return frame
+}
+
+func TestMotionScore(t *testing.T) {
+	const w, h = 16, 16
+	a := newFrame(w, h, 100)
+
+	if s := MotionScore(a, newFrame(w, h, 100), w, h, defaultPixelThreshold); s != 0 {
+		t.Fatalf("identical frames expect 0, got %f", s)
+	}
+	if s := MotionScore(a, newFrame(w, h, 110), w, h, defaultPixelThreshold); s != 0 {
+		t.Fatalf("small luma noise expect 0, got %f", s)
+	}
+	if s := MotionScore(a, newFrame(w, h, 200), w, h, defaultPixelThreshold); s != 1 {
+		t.Fatalf("full change expect 1, got %f", s)
+	}
+
+	// 左上角 4x4 区域变化，占比 16/256
+	b := newFrame(w, h, 100)
+	for y := range 4 {
+		for x := range 4 {
+			b[y*w+x] = 0
+		}
+	}
+	if s := MotionScore(a, b, w, h, defaultPixelThreshold); s != 16.0/256 {
+		t.Fatalf("expect %f, got %f", 16.0/256, s)
+	}
+
+	if s := MotionScore(a, b[:10], w, h, defaultPixelThreshold); s != 0 {
+		t.Fatalf("short frame expect 0, got %f", s)
+	}
+}
+
+func TestMotionDetector(t *testing.T) {
+	const w, h = 16, 16
+	moved := newFrame(w, h, 100)
+	for i := range 16 {
+		moved[i] = 0
+	}
+
+	low := NewMotionDetector(w, h, 0)
+	high := NewMotionDetector(w, h, 1)
+	for _, d := range []*MotionDetector{low, high} {
+		if _, ok := d.Detect(newFrame(w, h, 100)); ok {
+			t.Fatal("first frame should not be motion")
+		}
+		if _, ok := d.Detect(newFrame(w, h, 100)); ok {
+			t.Fatal("static frame should not be motion")
+		}
+	}
+
+	// 6.25% 的像素变化，两种灵敏度都能检测
+	if _, ok := low.Detect(moved); !ok {
+		t.Fatal("low sensitivity expect motion")
+	}
+
+	// 1 个像素变化，仅高灵敏度检测到
+	low2 := NewMotionDetector(w, h, 0)
+	low2.Detect(newFrame(w, h, 100))
+	one := newFrame(w, h, 100)
+	one[0] = 0
+	if _, ok := low2.Detect(one); ok {
+		t.Fatal("low sensitivity should ignore single pixel")
+	}
+	if _, ok := high.Detect(one); !ok {
+		t.Fatal("high sensitivity expect motion on single pixel")
+	}
+}
@@ -0,0 +1,76 @@
+package ffwork
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 内置默认值
+const (
+	defaultTransport = "tcp"
+	defaultTimeout   = 10 * time.Second
+	// minProbeSize ffmpeg 允许的最小探测字节数
+	minProbeSize = 32
+)
+
+// Options 拉流参数，零值字段使用默认值
+type Options struct {
+	Transport string        // -rtsp_transport tcp/udp/udp_multicast/http/https
+	Timeout   time.Duration // -timeout 读写超时，网络较差时适当调大，避免误判断流
+	ProbeSize int64         // -probesize 探测字节数，0 使用 ffmpeg 默认值
+}
+
+var (
+	defaultsM sync.RWMutex
+	defaults  = Options{Transport: defaultTransport, Timeout: defaultTimeout}
+)
+
+// SetDefaults 设置包级默认拉流参数，零值字段保持内置默认值，对之后创建的采集生效
+func SetDefaults(o Options) error {
+	o = o.merge(Options{Transport: defaultTransport, Timeout: defaultTimeout})
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	defaultsM.Lock()
+	defaults = o
+	defaultsM.Unlock()
+	return nil
+}
+
+// Defaults 当前包级默认拉流参数
+func Defaults() Options {
+	defaultsM.RLock()
+	defer defaultsM.RUnlock()
+	return defaults
+}
+
+// Validate 检查参数是否合法
+func (o Options) Validate() error {
+	switch o.Transport {
+	case "tcp", "udp", "udp_multicast", "http", "https":
+	default:
+		return fmt.Errorf("invalid rtsp transport: %q", o.Transport)
+	}
+	if o.Timeout < time.Second {
+		return fmt.Errorf("invalid timeout: %s, at least 1s", o.Timeout)
+	}
+	if o.ProbeSize != 0 && o.ProbeSize < minProbeSize {
+		return fmt.Errorf("invalid probe size: %d, at least %d", o.ProbeSize, minProbeSize)
+	}
+	return nil
+}
+
+// merge 零值字段使用 def 中的值
+func (o Options) merge(def Options) Options {
+	if o.Transport == "" {
+		o.Transport = def.Transport
+	}
+	if o.Timeout == 0 {
+		o.Timeout = def.Timeout
+	}
+	if o.ProbeSize == 0 {
+		o.ProbeSize = def.ProbeSize
+	}
+	return o
+}
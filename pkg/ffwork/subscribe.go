@@ -0,0 +1,85 @@
+package ffwork
+
+import (
+	"slices"
+	"time"
+)
+
+type (
+	// SubscribeOptions 订阅参数，零值使用默认值
+	SubscribeOptions struct {
+		Buffer       int           // 缓冲帧数，为 0 使用 10
+		Backpressure Backpressure  // 通道已满时的处理策略，为空使用 DropNewest
+		BlockTimeout time.Duration // Block 策略下的最长等待时间，为 0 使用 1s
+	}
+	// Subscription 一个帧订阅者，多个订阅者共用同一个 ffmpeg 进程
+	Subscription struct {
+		C    <-chan *FrameData // 采集结束或取消订阅后关闭
+		fc   *FrameCapture
+		sink *frameSink
+	}
+	// SubscriberStats 订阅者丢帧统计
+	SubscriberStats struct {
+		Backpressure      Backpressure
+		SkipCount         uint64
+		DropNewestCount   uint64
+		DropOldestCount   uint64
+		BlockTimeoutCount uint64
+	}
+)
+
+// Subscribe 注册一个订阅者，之后采集到的每一帧都会按其策略写入 C
+// 例如同一路摄像头同时做 AI 检测与运动预过滤，无需启动两个 ffmpeg
+func (fc *FrameCapture) Subscribe(opt SubscribeOptions) (*Subscription, error) {
+	if opt.Buffer == 0 {
+		opt.Buffer = defaultFrameBuffer
+	}
+	sink, err := newFrameSink(opt.Buffer, opt.Backpressure, opt.BlockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	sub := Subscription{C: sink.ch, fc: fc, sink: sink}
+
+	fc.subsM.Lock()
+	defer fc.subsM.Unlock()
+	if fc.closed {
+		close(sink.ch)
+		return &sub, nil
+	}
+	fc.subs = append(fc.subs, &sub)
+	return &sub, nil
+}
+
+// Close 取消订阅并关闭 C，可重复调用
+func (s *Subscription) Close() {
+	fc := s.fc
+	fc.subsM.Lock()
+	defer fc.subsM.Unlock()
+	if i := slices.Index(fc.subs, s); i >= 0 {
+		fc.subs = slices.Delete(fc.subs, i, i+1)
+		close(s.sink.ch)
+	}
+}
+
+// Stats 订阅者的丢帧统计
+func (s *Subscription) Stats() SubscriberStats {
+	return SubscriberStats{
+		Backpressure:      s.sink.policy,
+		SkipCount:         s.sink.skipCount(),
+		DropNewestCount:   s.sink.dropNewest.Load(),
+		DropOldestCount:   s.sink.dropOldest.Load(),
+		BlockTimeoutCount: s.sink.blockTimeout.Load(),
+	}
+}
+
+// closeSinks 采集结束时关闭 FrameCh 与所有订阅者通道
+func (fc *FrameCapture) closeSinks() {
+	fc.subsM.Lock()
+	defer fc.subsM.Unlock()
+	close(fc.FrameCh)
+	for _, sub := range fc.subs {
+		close(sub.sink.ch)
+	}
+	fc.subs = nil
+	fc.closed = true
+}
@@ -0,0 +1,87 @@
+package ffwork
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeFanOut(t *testing.T) {
+	fc := newTestCapture(t, "", 0)
+
+	// AI 检测要最新画面，运动预过滤不能丢帧
+	ai, err := fc.Subscribe(SubscribeOptions{Buffer: 2, Backpressure: DropOldest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	motion, err := fc.Subscribe(SubscribeOptions{Backpressure: Block, BlockTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []uint64)
+	go func() {
+		var out []uint64
+		for f := range motion.C {
+			out = append(out, f.FrameNum)
+		}
+		done <- out
+	}()
+
+	produce(fc, 20)
+	fc.closeSinks()
+
+	got := <-done
+	if len(got) != 20 {
+		t.Fatalf("expect 20 frames for motion subscriber, got %v", got)
+	}
+	for i, v := range got {
+		if v != uint64(i+1) {
+			t.Fatalf("expect frames in order, got %v", got)
+		}
+	}
+	if s := motion.Stats(); s.SkipCount != 0 {
+		t.Fatalf("unexpected motion stats %+v", s)
+	}
+
+	var latest []uint64
+	for f := range ai.C {
+		latest = append(latest, f.FrameNum)
+	}
+	if len(latest) != 2 || latest[0] != 19 || latest[1] != 20 {
+		t.Fatalf("expect latest frames 19,20 for ai subscriber, got %v", latest)
+	}
+	if s := ai.Stats(); s.Backpressure != DropOldest || s.DropOldestCount != 18 {
+		t.Fatalf("unexpected ai stats %+v", s)
+	}
+
+	// 采集结束后订阅，通道直接关闭
+	late, err := fc.Subscribe(SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-late.C; ok {
+		t.Fatal("expect closed channel after capture ended")
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	fc := newTestCapture(t, "", 0)
+	sub, err := fc.Subscribe(SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expect closed channel after Close")
+	}
+	// 取消订阅后继续采集不受影响
+	produce(fc, 3)
+	if got := drain(fc); len(got) != 3 {
+		t.Fatalf("expect 3 frames on FrameCh, got %v", got)
+	}
+
+	if _, err := fc.Subscribe(SubscribeOptions{Buffer: -1}); err == nil {
+		t.Fatal("expect invalid buffer rejected")
+	}
+}
@@ -0,0 +1,76 @@
+package ffwork
+
+import "image"
+
+const (
+	// tamperThumbSize 比对前统一缩放的边长，屏蔽分辨率差异与细节噪声
+	tamperThumbSize = 64
+	// ssimWindow SSIM 计算的分块边长
+	ssimWindow = 8
+	// ssimC1 ssimC2 SSIM 稳定常数，避免分母为 0
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// GrayThumbnail 将图像按最近邻缩放为 width*height 的灰度图
+func GrayThumbnail(img image.Image, width, height int) []byte {
+	b := img.Bounds()
+	out := make([]byte, width*height)
+	if b.Empty() {
+		return out
+	}
+	for y := range height {
+		sy := b.Min.Y + y*b.Dy()/height
+		for x := range width {
+			sx := b.Min.X + x*b.Dx()/width
+			r, g, bl, _ := img.At(sx, sy).RGBA()
+			// ITU-R BT.601 亮度，RGBA 返回 16 位分量
+			out[y*width+x] = uint8((299*r + 587*g + 114*bl) / 1000 >> 8)
+		}
+	}
+	return out
+}
+
+// SSIM 计算两张同尺寸灰度图的平均结构相似度，按 8x8 分块求均值，取值 [-1,1]
+// 尺寸不足一个分块或数据不足时返回 0
+func SSIM(a, b []byte, width, height int) float64 {
+	size := width * height
+	if len(a) < size || len(b) < size || width < ssimWindow || height < ssimWindow {
+		return 0
+	}
+
+	var total float64
+	var blocks int
+	const n = ssimWindow * ssimWindow
+	for by := 0; by+ssimWindow <= height; by += ssimWindow {
+		for bx := 0; bx+ssimWindow <= width; bx += ssimWindow {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for y := by; y < by+ssimWindow; y++ {
+				for x := bx; x < bx+ssimWindow; x++ {
+					va, vb := float64(a[y*width+x]), float64(b[y*width+x])
+					sumA += va
+					sumB += vb
+					sumAA += va * va
+					sumBB += vb * vb
+					sumAB += va * vb
+				}
+			}
+			muA, muB := sumA/n, sumB/n
+			varA := sumAA/n - muA*muA
+			varB := sumBB/n - muB*muB
+			cov := sumAB/n - muA*muB
+			total += ((2*muA*muB + ssimC1) * (2*cov + ssimC2)) /
+				((muA*muA + muB*muB + ssimC1) * (varA + varB + ssimC2))
+			blocks++
+		}
+	}
+	return total / float64(blocks)
+}
+
+// TamperScore 比较参考图与当前图，返回 [0,1] 的篡改分数
+// 画面被遮挡、移位或严重失焦时分数趋近 1，画面一致时为 0
+func TamperScore(ref, cur image.Image) float64 {
+	a := GrayThumbnail(ref, tamperThumbSize, tamperThumbSize)
+	b := GrayThumbnail(cur, tamperThumbSize, tamperThumbSize)
+	return min(max(1-SSIM(a, b, tamperThumbSize, tamperThumbSize), 0), 1)
+}
@@ -0,0 +1,52 @@
+package ffwork
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+)
+
+// texturedImage 生成带随机纹理的灰度图，模拟真实监控画面
+func texturedImage(w, h int, seed uint64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewPCG(seed, seed))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(r.IntN(256))
+	}
+	return img
+}
+
+func TestTamperScore(t *testing.T) {
+	ref := texturedImage(320, 180, 1)
+
+	if score := TamperScore(ref, ref); score > 0.001 {
+		t.Fatalf("identical image expect score 0, got %f", score)
+	}
+
+	brighter := image.NewGray(ref.Bounds())
+	for i, v := range ref.Pix {
+		brighter.Pix[i] = uint8(min(int(v)+10, 255))
+	}
+	if score := TamperScore(ref, brighter); score > 0.1 {
+		t.Fatalf("slight brightness change expect low score, got %f", score)
+	}
+
+	covered := image.NewUniform(color.Black)
+	if score := TamperScore(ref, covered); score < 0.9 {
+		t.Fatalf("covered camera expect high score, got %f", score)
+	}
+
+	if score := TamperScore(ref, texturedImage(320, 180, 2)); score < 0.5 {
+		t.Fatalf("redirected camera expect high score, got %f", score)
+	}
+}
+
+func TestSSIMInvalidInput(t *testing.T) {
+	if v := SSIM([]byte{1, 2}, []byte{1, 2}, 64, 64); v != 0 {
+		t.Fatalf("expect 0 for short input, got %f", v)
+	}
+	if v := SSIM(make([]byte, 16), make([]byte, 16), 4, 4); v != 0 {
+		t.Fatalf("expect 0 for image smaller than window, got %f", v)
+	}
+}
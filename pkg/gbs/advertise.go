@@ -0,0 +1,107 @@
+package gbs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"github.com/ixugo/netpulse/ip"
+)
+
+// 通告地址自检结果，设备按通告地址回送 SIP 消息，地址不可达时表现为能注册但无法点播
+var (
+	errAdvertiseInvalid  = errors.New("advertised ip is not a valid ip address")
+	errAdvertiseLoopback = errors.New("advertised ip is loopback, only devices on this host can reach it")
+	errAdvertiseNotLocal = errors.New("advertised ip is a private address but not found on any local interface")
+)
+
+// ContactInfo 当前生效的 SIP 联系地址
+type ContactInfo struct {
+	URI      string   `json:"uri"`      // sip:国标ID@IP:端口
+	Host     string   `json:"host"`     // 向设备通告的 IP
+	Port     int      `json:"port"`     // 监听端口，tcp/udp 相同
+	Warnings []string `json:"warnings"` // 自检发现的配置问题，为空表示未发现
+}
+
+// advertisedHost 向设备通告的 IP，未配置时使用内网 IP
+func advertisedHost(cfg *conf.SIP) string {
+	if cfg.Host != "" {
+		return cfg.Host
+	}
+	return ip.InternalIP()
+}
+
+// newFromAddress 生成平台的 From 地址，设备据此回送消息
+func newFromAddress(cfg *conf.SIP) sip.Address {
+	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s:%d", cfg.ID, advertisedHost(cfg), cfg.Port))
+	return sip.Address{
+		DisplayName: sip.String{Str: "gowvp/owl"},
+		URI:         &uri,
+		Params:      sip.NewParams(),
+	}
+}
+
+// checkAdvertisedIP 判断通告地址对设备是否可能可达
+// 本机网卡上的地址视为可达；不在本机网卡上的公网地址视为 NAT 映射地址，同样认为可达；
+// 不在本机网卡上的私有地址多为填错或网卡已变更，回环地址只有本机设备能访问
+func checkAdvertisedIP(addr string, local []net.IP) error {
+	target := net.ParseIP(addr)
+	if target == nil || target.IsUnspecified() {
+		return errAdvertiseInvalid
+	}
+	if target.IsLoopback() {
+		return errAdvertiseLoopback
+	}
+	for _, v := range local {
+		if v.Equal(target) {
+			return nil
+		}
+	}
+	if target.IsPrivate() || target.IsLinkLocalUnicast() {
+		return errAdvertiseNotLocal
+	}
+	return nil
+}
+
+// localIPs 本机所有网卡地址
+func localIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		slog.Warn("读取网卡地址失败", "err", err)
+		return nil
+	}
+	out := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if v, ok := addr.(*net.IPNet); ok {
+			out = append(out, v.IP)
+		}
+	}
+	return out
+}
+
+// Contact 返回当前生效的 SIP 联系地址与自检结果
+func (s *Server) Contact() ContactInfo {
+	cfg := s.gb.cfg
+	host := advertisedHost(cfg)
+	info := ContactInfo{
+		URI:      fmt.Sprintf("sip:%s@%s:%d", cfg.ID, host, cfg.Port),
+		Host:     host,
+		Port:     cfg.Port,
+		Warnings: make([]string, 0, 1),
+	}
+	if err := checkAdvertisedIP(host, localIPs()); err != nil {
+		info.Warnings = append(info.Warnings, err.Error())
+	}
+	return info
+}
+
+// selfCheck 启动时检查通告地址，配置有误时输出警告
+func (s *Server) selfCheck() {
+	info := s.Contact()
+	for _, w := range info.Warnings {
+		slog.Warn("SIP 通告地址可能不可达，设备能注册但无法收到点播等消息时请检查 sip.host 配置", "host", info.Host, "port", info.Port, "reason", w)
+	}
+}
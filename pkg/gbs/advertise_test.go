@@ -0,0 +1,43 @@
+package gbs
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+)
+
+func TestCheckAdvertisedIP(t *testing.T) {
+	local := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("192.168.1.10"), net.ParseIP("fe80::1")}
+	tests := []struct {
+		name string
+		addr string
+		err  error
+	}{
+		{name: "local interface", addr: "192.168.1.10"},
+		{name: "public nat ip", addr: "203.0.113.7"},
+		{name: "local link local", addr: "fe80::1"},
+		{name: "private not on interface", addr: "10.0.0.8", err: errAdvertiseNotLocal},
+		{name: "other subnet private", addr: "192.168.2.10", err: errAdvertiseNotLocal},
+		{name: "link local not on interface", addr: "169.254.1.1", err: errAdvertiseNotLocal},
+		{name: "loopback", addr: "127.0.0.1", err: errAdvertiseLoopback},
+		{name: "unspecified", addr: "0.0.0.0", err: errAdvertiseInvalid},
+		{name: "hostname", addr: "sip.example.com", err: errAdvertiseInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkAdvertisedIP(tt.addr, local); !errors.Is(err, tt.err) {
+				t.Fatalf("expect %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewFromAddressHost(t *testing.T) {
+	cfg := conf.SIP{ID: "34020000002000000001", Port: 15060, Host: "203.0.113.7"}
+	from := newFromAddress(&cfg)
+	if got := from.URI.String(); got != "sip:34020000002000000001@203.0.113.7:15060" {
+		t.Fatalf("unexpected from uri %s", got)
+	}
+}
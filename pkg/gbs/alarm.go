@@ -0,0 +1,128 @@
+package gbs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+// alarmCmdReset 报警复位指令 A.2.3.1.5
+const alarmCmdReset = "ResetAlarm"
+
+// controlResponseTimeout 等待设备应答控制结果的最长时间
+const controlResponseTimeout = 5 * time.Second
+
+var (
+	// ErrControlTimeout 设备未在超时时间内应答控制结果
+	ErrControlTimeout = errors.New("device control timeout")
+	// ErrControlFailed 设备应答控制结果为 ERROR
+	ErrControlFailed = errors.New("device control failed")
+)
+
+// ResetAlarmInput 报警复位参数，报警方式与类型为空时复位全部报警
+type ResetAlarmInput struct {
+	Channel     *ipc.Channel
+	AlarmMethod string
+	AlarmType   string
+}
+
+// DeviceControlResponse 设备控制应答 A.2.6.2
+type DeviceControlResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"` // OK/ERROR
+}
+
+// NewResetAlarmControl 生成报警复位指令
+func NewResetAlarmControl(channelID string, sn int, alarmMethod, alarmType string) *DeviceControl {
+	body := DeviceControl{
+		CmdType:  "DeviceControl",
+		SN:       sn,
+		DeviceID: channelID,
+		AlarmCmd: alarmCmdReset,
+	}
+	if alarmMethod != "" || alarmType != "" {
+		body.Info = &ControlInfo{AlarmMethod: alarmMethod, AlarmType: alarmType}
+	}
+	return &body
+}
+
+// ResetAlarm 向通道发送报警复位指令，并等待设备通过 MESSAGE 应答的结果
+func (g *GB28181API) ResetAlarm(ctx context.Context, in *ResetAlarmInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	slog.Debug("ResetAlarm", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID)
+	return g.deviceControlWait(ctx, ch, NewResetAlarmControl(in.Channel.ChannelID, 0, in.AlarmMethod, in.AlarmType))
+}
+
+// deviceControlWait 发送设备控制指令，并等待设备通过 MESSAGE 应答的结果
+func (g *GB28181API) deviceControlWait(ctx context.Context, ch *Channel, body *DeviceControl) error {
+	if body.SN == 0 {
+		body.SN = sip.RandInt(100000, 999999)
+	}
+	key := pendingKey(ch.ChannelID, body.SN)
+	resp := make(chan string, 1)
+	g.controls.Store(key, resp)
+	defer g.controls.Delete(key)
+
+	if err := g.deviceControl(ch, body); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(controlResponseTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-resp:
+		if !strings.EqualFold(result, "OK") {
+			return fmt.Errorf("%w: result[%s]", ErrControlFailed, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrControlTimeout
+	}
+}
+
+// deliverControlResult 将应答结果交给等待中的控制指令，无等待时返回 false
+func (g *GB28181API) deliverControlResult(msg *DeviceControlResponse) bool {
+	resp, ok := g.controls.Load(pendingKey(msg.DeviceID, msg.SN))
+	if !ok {
+		return false
+	}
+	select {
+	case resp <- strings.TrimSpace(msg.Result):
+	default:
+	}
+	return true
+}
+
+// sipMessageDeviceControl 设备控制应答
+func (g *GB28181API) sipMessageDeviceControl(ctx *sip.Context) {
+	var msg DeviceControlResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessageDeviceControl", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	if !g.deliverControlResult(&msg) {
+		slog.Debug("sipMessageDeviceControl 无等待中的控制指令", "deviceID", msg.DeviceID, "sn", msg.SN, "result", msg.Result)
+	}
+	ctx.String(200, "OK")
+}
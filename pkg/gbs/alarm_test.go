@@ -0,0 +1,81 @@
+package gbs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestNewResetAlarmControl(t *testing.T) {
+	tests := []struct {
+		name        string
+		method, typ string
+		contains    []string
+		excludes    []string
+	}{
+		{
+			name: "reset all",
+			contains: []string{
+				"<CmdType>DeviceControl</CmdType>",
+				"<SN>123456</SN>",
+				"<DeviceID>34020000001320000001</DeviceID>",
+				"<AlarmCmd>ResetAlarm</AlarmCmd>",
+			},
+			excludes: []string{"<Info>", "<PTZCmd>"},
+		},
+		{
+			name:     "with method and type",
+			method:   "5",
+			typ:      "2",
+			contains: []string{"<AlarmCmd>ResetAlarm</AlarmCmd><Info><AlarmMethod>5</AlarmMethod><AlarmType>2</AlarmType></Info>"},
+			excludes: []string{"<ControlPriority>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sip.XMLEncode(NewResetAlarmControl("34020000001320000001", 123456, tt.method, tt.typ))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := string(b)
+			if !strings.HasPrefix(body, "<?xml") || !strings.Contains(body, "<Control>") {
+				t.Fatalf("unexpected body %s", body)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Fatalf("expect %s in %s", s, body)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Fatalf("unexpected %s in %s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverControlResult(t *testing.T) {
+	g := GB28181API{controls: &conc.Map[string, chan string]{}}
+	resp := make(chan string, 1)
+	g.controls.Store(pendingKey("ch1", 1), resp)
+
+	var msg DeviceControlResponse
+	body := `<?xml version="1.0"?><Response><CmdType>DeviceControl</CmdType><SN>1</SN><DeviceID>ch1</DeviceID><Result>OK</Result></Response>`
+	if err := sip.XMLDecode([]byte(body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !g.deliverControlResult(&msg) {
+		t.Fatal("expect result delivered")
+	}
+	if got := <-resp; got != "OK" {
+		t.Fatalf("expect OK, got %s", got)
+	}
+
+	// SN 不匹配的应答不应被误认为本次控制的结果
+	if g.deliverControlResult(&DeviceControlResponse{DeviceID: "ch1", SN: 2, Result: "OK"}) {
+		t.Fatal("expect unmatched sn ignored")
+	}
+}
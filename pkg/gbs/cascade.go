@@ -0,0 +1,735 @@
+package gbs
+
+import (
+	"cmp"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"github.com/gowvp/owl/pkg/zlm"
+	"github.com/ixugo/goddd/pkg/orm"
+	"github.com/ixugo/goddd/pkg/web"
+	sdp "github.com/panjjo/gosdp"
+)
+
+// 级联时本平台作为下级，向上级平台注册、保活，应答目录查询并转发点播
+const (
+	cascadeDefaultExpires   = 3600
+	cascadeDefaultKeepalive = 60
+	cascadeMaxMiss          = 3                // 连续心跳失败次数达到该值后重新注册
+	cascadeRetryInterval    = 30 * time.Second // 注册失败后的重试间隔
+	cascadeCatalogBatch     = 10               // 每条目录应答携带的通道数，避免 UDP 报文超过 MTU
+	cascadeStreamWait       = 10 * time.Second // 点播本地通道后等待流注册的时长
+
+	statusNotAcceptableHere = 488 // SIP 488，媒体参数不支持
+)
+
+// cascadeSender 发送请求并返回最终应答，测试时替换为模拟的上级平台
+type cascadeSender func(req *sip.Request) (*sip.Response, error)
+
+// cascade 一个上级平台的注册会话
+type cascade struct {
+	cfg     conf.SIPCascade
+	local   *conf.SIP
+	contact *sip.Address
+	send    cascadeSender
+
+	// 注册刷新沿用同一 Call-ID 与 From tag，CSeq 递增
+	callID  sip.CallID
+	fromTag string
+	seq     atomic.Uint32
+	sn      atomic.Int32
+
+	registered atomic.Bool
+	// upstreamIPs 上级平台地址的解析结果，创建及注册成功时刷新，避免每个请求都查询 DNS
+	upstreamIPs atomic.Pointer[[]net.IP]
+	// onDown 注册失效或停止时调用，用于结束转发给该平台的推流
+	onDown func()
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newCascade(cfg conf.SIPCascade, local *conf.SIP, contact *sip.Address, send cascadeSender) *cascade {
+	c := &cascade{
+		cfg:     cfg,
+		local:   local,
+		contact: contact,
+		send:    send,
+		callID:  sip.CallID(sip.RandString(32)),
+		fromTag: sip.RandString(32),
+	}
+	c.resolveUpstream()
+	return c
+}
+
+// resolveUpstream 解析上级平台地址，失败时保留上次的结果
+func (c *cascade) resolveUpstream() {
+	ips, err := net.LookupIP(c.cfg.Host)
+	if err != nil {
+		slog.Warn("解析上级平台地址失败", "host", c.cfg.Host, "err", err)
+		return
+	}
+	c.upstreamIPs.Store(&ips)
+}
+
+// down 注册失效，上级不会再发送 BYE，由 onDown 结束转发给该平台的推流
+func (c *cascade) down() {
+	c.registered.Store(false)
+	if c.onDown != nil {
+		c.onDown()
+	}
+}
+
+// policy 注册有效期与心跳间隔，未配置时使用默认值
+func (c *cascade) policy() (time.Duration, time.Duration) {
+	expires := cmp.Or(max(c.cfg.Expires, 0), cascadeDefaultExpires)
+	keepalive := cmp.Or(max(c.cfg.Keepalive, 0), cascadeDefaultKeepalive)
+	return time.Duration(expires) * time.Second, time.Duration(keepalive) * time.Second
+}
+
+// requestURI 上级平台的请求地址
+func (c *cascade) requestURI() *sip.URI {
+	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", c.cfg.ID, net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))))
+	return &uri
+}
+
+// upstream 上级平台地址，未配置域时使用 IP 与端口
+func (c *cascade) upstream() *sip.Address {
+	host := cmp.Or(c.cfg.Domain, net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port)))
+	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", c.cfg.ID, host))
+	return &sip.Address{URI: &uri, Params: sip.NewParams()}
+}
+
+// self 本平台地址，From 带固定 tag
+func (c *cascade) self(withTag bool) *sip.Address {
+	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", c.local.ID, c.local.Domain))
+	params := sip.NewParams()
+	if withTag {
+		params.Add("tag", sip.String{Str: c.fromTag})
+	}
+	return &sip.Address{URI: &uri, Params: params}
+}
+
+func (c *cascade) newRequest(method string, to *sip.Address, contentType *sip.ContentType, body []byte) *sip.Request {
+	hb := sip.NewHeaderBuilder().
+		SetToWithParam(to).
+		SetFrom(c.self(true)).
+		SetContact(c.contact).
+		SetMethod(method).
+		SetSeqNo(uint(c.seq.Add(1))).
+		AddVia(&sip.ViaHop{
+			Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		})
+	if contentType != nil {
+		hb.SetContentType(contentType)
+	}
+	if method == sip.MethodRegister {
+		hb.SetCallID(&c.callID)
+	}
+	return sip.NewRequest("", method, c.requestURI(), sip.DefaultSipVersion, hb.Build(), body)
+}
+
+// registerRequest authHeader 为 Authorization 或 Proxy-Authorization，authorization 为空时不携带鉴权
+func (c *cascade) registerRequest(expires int, authHeader, authorization string) *sip.Request {
+	req := c.newRequest(sip.MethodRegister, c.self(false), nil, nil)
+	e := sip.Expires(expires)
+	req.AppendHeader(&e)
+	if authorization != "" {
+		req.AppendHeader(&sip.GenericHeader{HeaderName: authHeader, Contents: authorization})
+	}
+	return req
+}
+
+// register 向上级平台注册，expires 为 0 表示注销
+// 上级返回 401/407 时按摘要认证重新注册一次，用户名为本平台国标 ID
+func (c *cascade) register(expires int) error {
+	resp, err := c.send(c.registerRequest(expires, "", ""))
+	if err != nil {
+		return err
+	}
+	if code := resp.StatusCode(); code == http.StatusUnauthorized || code == http.StatusProxyAuthRequired {
+		challengeHeader, authHeader := "WWW-Authenticate", "Authorization"
+		if code == http.StatusProxyAuthRequired {
+			challengeHeader, authHeader = "Proxy-Authenticate", "Proxy-Authorization"
+		}
+		hdrs := resp.GetHeaders(challengeHeader)
+		if len(hdrs) == 0 {
+			return fmt.Errorf("上级平台 [%s] 要求鉴权但未返回 %s", c.cfg.ID, challengeHeader)
+		}
+		challenge, ok := hdrs[0].(*sip.GenericHeader)
+		if !ok {
+			return fmt.Errorf("上级平台 [%s] %s 格式错误", c.cfg.ID, challengeHeader)
+		}
+		auth := sip.AuthFromValue(challenge.Contents).
+			SetUsername(c.local.ID).
+			SetPassword(c.cfg.Password).
+			SetMethod(sip.MethodRegister).
+			SetURI(c.requestURI().String()).
+			SetCnonce(sip.RandString(16), "00000001")
+		auth.CalcResponse()
+
+		resp, err = c.send(c.registerRequest(expires, authHeader, auth.String()))
+		if err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("上级平台 [%s] 拒绝注册: %d %s", c.cfg.ID, resp.StatusCode(), resp.Reason())
+	}
+	c.registered.Store(expires > 0)
+	return nil
+}
+
+// cascadeKeepalive 下级向上级发送的心跳
+type cascadeKeepalive struct {
+	XMLName  xml.Name `xml:"Notify"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int32    `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Status   string   `xml:"Status"`
+}
+
+// message 向上级平台发送 MANSCDP 消息
+func (c *cascade) message(body []byte) error {
+	resp, err := c.send(c.newRequest(sip.MethodMessage, c.upstream(), &sip.ContentTypeXML, body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("上级平台 [%s] 应答 %d %s", c.cfg.ID, resp.StatusCode(), resp.Reason())
+	}
+	return nil
+}
+
+func (c *cascade) keepalive() error {
+	body, err := sip.XMLEncode(cascadeKeepalive{
+		CmdType:  "Keepalive",
+		SN:       c.sn.Add(1),
+		DeviceID: c.local.ID,
+		Status:   "OK",
+	})
+	if err != nil {
+		return err
+	}
+	return c.message(body)
+}
+
+// run 注册并保活，心跳连续失败或注册过半有效期时重新注册，退出时注销
+func (c *cascade) run(ctx context.Context) {
+	defer close(c.done)
+	log := slog.With("cascade", c.cfg.ID, "host", c.cfg.Host)
+	expires, interval := c.policy()
+
+	var registeredAt time.Time
+	misses := 0
+	for {
+		wait := interval
+		if !c.registered.Load() || time.Since(registeredAt) >= expires/2 {
+			if err := c.register(int(expires.Seconds())); err != nil {
+				log.Warn("向上级平台注册失败", "err", err)
+				c.down()
+				wait = cascadeRetryInterval
+			} else {
+				log.Info("已注册到上级平台")
+				c.resolveUpstream()
+				registeredAt = time.Now()
+				misses = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if c.registered.Load() {
+				if err := c.register(0); err != nil {
+					log.Warn("向上级平台注销失败", "err", err)
+				}
+			}
+			c.down()
+			return
+		case <-time.After(wait):
+		}
+
+		if !c.registered.Load() {
+			continue
+		}
+		if err := c.keepalive(); err != nil {
+			misses++
+			log.Warn("上级平台心跳失败", "err", err, "missed", misses)
+			if misses >= cascadeMaxMiss {
+				c.down()
+			}
+			continue
+		}
+		misses = 0
+	}
+}
+
+func (c *cascade) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(ctx)
+}
+
+// stop 停止保活并尽量完成注销，最多等待 3 秒
+func (c *cascade) stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-time.After(3 * time.Second):
+	}
+}
+
+// cascadeCatalogItem 目录应答中的通道
+// GB/T28181 A.2.6.4
+type cascadeCatalogItem struct {
+	DeviceID     string `xml:"DeviceID"`
+	Name         string `xml:"Name"`
+	Manufacturer string `xml:"Manufacturer"`
+	Model        string `xml:"Model"`
+	Owner        string `xml:"Owner"`
+	CivilCode    string `xml:"CivilCode"`
+	Address      string `xml:"Address"`
+	Parental     int    `xml:"Parental"`
+	ParentID     string `xml:"ParentID"`
+	RegisterWay  int    `xml:"RegisterWay"`
+	Secrecy      int    `xml:"Secrecy"`
+	Status       string `xml:"Status"`
+}
+
+type cascadeCatalogResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	SumNum   int      `xml:"SumNum"`
+	List     struct {
+		Num  int                  `xml:"Num,attr"`
+		Item []cascadeCatalogItem `xml:"Item"`
+	} `xml:"DeviceList"`
+}
+
+// cascadeCatalogBodies 将通道分批编码为目录应答，只共享编码合法的国标通道
+// 没有通道时也返回一条 SumNum 为 0 的应答，避免上级平台一直等待
+func cascadeCatalogBodies(localID string, sn int, channels []*ipc.Channel, batch int) ([][]byte, error) {
+	items := make([]cascadeCatalogItem, 0, len(channels))
+	for _, ch := range channels {
+		if filterUnknowDevices(ch.ChannelID) != nil {
+			continue
+		}
+		status := "OFF"
+		if ch.IsOnline {
+			status = "ON"
+		}
+		items = append(items, cascadeCatalogItem{
+			DeviceID:     ch.ChannelID,
+			Name:         ch.Name,
+			Manufacturer: ch.Ext.Manufacturer,
+			Model:        ch.Ext.Model,
+			CivilCode:    ipc.ParseCivilCode(ch.ChannelID),
+			ParentID:     localID,
+			RegisterWay:  1,
+			Status:       status,
+		})
+	}
+
+	batch = max(batch, 1)
+	out := make([][]byte, 0, len(items)/batch+1)
+	for i := 0; i == 0 || i < len(items); i += batch {
+		resp := cascadeCatalogResponse{CmdType: "Catalog", SN: sn, DeviceID: localID, SumNum: len(items)}
+		resp.List.Item = items[i:min(i+batch, len(items))]
+		resp.List.Num = len(resp.List.Item)
+		body, err := sip.XMLEncode(resp)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, body)
+	}
+	return out, nil
+}
+
+// cascadeOffer 上级平台 INVITE 中的媒体接收地址
+type cascadeOffer struct {
+	Name     string // Play 实时/Playback 回放
+	IP       string
+	Port     int
+	Protocol string // RTP/AVP 或 TCP/RTP/AVP
+	Setup    string // TCP 时的 setup 属性
+	SSRC     string
+}
+
+func (o cascadeOffer) isTCP() bool {
+	return strings.HasPrefix(strings.ToUpper(o.Protocol), "TCP")
+}
+
+// parseCascadeOffer 解析 INVITE 的 SDP，y 行为国标扩展的 SSRC
+func parseCascadeOffer(body []byte) (cascadeOffer, error) {
+	var o cascadeOffer
+	for line := range strings.SplitSeq(string(body), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "s":
+			o.Name = v
+		case "c":
+			if fields := strings.Fields(v); len(fields) == 3 {
+				o.IP = fields[2]
+			}
+		case "m":
+			fields := strings.Fields(v)
+			if len(fields) >= 3 && fields[0] == "video" {
+				o.Port, _ = strconv.Atoi(fields[1])
+				o.Protocol = fields[2]
+			}
+		case "a":
+			if setup, ok := strings.CutPrefix(v, "setup:"); ok {
+				o.Setup = setup
+			}
+		case "y":
+			o.SSRC = v
+		}
+	}
+	if o.IP == "" || o.Port <= 0 {
+		return o, fmt.Errorf("SDP 缺少媒体接收地址")
+	}
+	if o.SSRC == "" {
+		return o, fmt.Errorf("SDP 缺少 SSRC")
+	}
+	return o, nil
+}
+
+// cascadeAnswer 应答上级平台的 SDP，本平台只发送
+func cascadeAnswer(localID, ip string, port int, offer cascadeOffer) []byte {
+	video := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "video",
+			Port:     port,
+			Formats:  []string{"96"},
+			Protocol: offer.Protocol,
+		},
+	}
+	video.AddAttribute("sendonly")
+	if offer.isTCP() {
+		video.AddAttribute("setup", "active")
+		video.AddAttribute("connection", "new")
+	}
+	video.AddAttribute("rtpmap", "96", "PS/90000")
+
+	msg := &sdp.Message{
+		Origin: sdp.Origin{
+			Username:    localID,
+			NetworkType: "IN",
+			AddressType: "IP4",
+			Address:     ip,
+		},
+		Name: offer.Name,
+		Connection: sdp.ConnectionData{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			IP:          net.ParseIP(ip),
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{video},
+		SSRC:   offer.SSRC,
+	}
+	return msg.Append(nil).AppendTo(nil)
+}
+
+// cascadeSession 转发给上级平台的推流，key 为 INVITE 的 Call-ID
+type cascadeSession struct {
+	server   *sms.MediaServer
+	stream   string
+	ssrc     string
+	upstream string // 上级平台国标 ID
+}
+
+// startCascades 启动已启用的上级平台注册
+func (s *Server) startCascades() {
+	s.cascadeMu.Lock()
+	defer s.cascadeMu.Unlock()
+	for _, cfg := range s.gb.cfg.Cascades {
+		if !cfg.Enabled {
+			continue
+		}
+		if filterUnknowDevices(cfg.ID) != nil || cfg.Host == "" || cfg.Port <= 0 {
+			slog.Warn("上级平台配置无效，已忽略", "id", cfg.ID, "host", cfg.Host, "port", cfg.Port)
+			continue
+		}
+		c := newCascade(cfg, s.gb.cfg, &s.fromAddress, s.cascadeSender(cfg))
+		c.onDown = func() { s.stopCascadeSessions(cfg.ID) }
+		s.cascades = append(s.cascades, c)
+		c.start()
+	}
+}
+
+// stopCascades 注销并停止所有上级平台，注销在锁外进行，避免阻塞信令处理
+func (s *Server) stopCascades() {
+	s.cascadeMu.Lock()
+	cascades := s.cascades
+	s.cascades = nil
+	s.cascadeMu.Unlock()
+	for _, c := range cascades {
+		c.stop()
+	}
+}
+
+// restartCascades 配置变更后按新配置重新注册上级平台
+func (s *Server) restartCascades() {
+	s.stopCascades()
+	s.startCascades()
+}
+
+// cascadeSender 经 UDP 监听连接发送请求并等待上级平台应答
+func (s *Server) cascadeSender(cfg conf.SIPCascade) cascadeSender {
+	return func(req *sip.Request) (*sip.Response, error) {
+		addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+		if err != nil {
+			return nil, err
+		}
+		req.SetDestination(addr)
+		tx, err := s.Request(req)
+		if err != nil {
+			return nil, err
+		}
+		resp := tx.GetResponse()
+		if resp == nil {
+			return nil, sip.NewError(nil, "response timeout", "tx key:", tx.Key())
+		}
+		return resp, nil
+	}
+}
+
+// cascadeFor 按 From 中的国标 ID 查找已注册的上级平台，且请求必须来自该平台配置的地址
+// 仅凭国标 ID 可被伪造的 UDP 报文冒充，进而拉取目录或让本平台向任意地址推流
+func (s *Server) cascadeFor(id string, src net.Addr) *cascade {
+	s.cascadeMu.RLock()
+	defer s.cascadeMu.RUnlock()
+	for _, c := range s.cascades {
+		if c.cfg.ID == id && c.registered.Load() {
+			if !c.fromUpstream(src) {
+				slog.Warn("上级平台请求来源地址不匹配", "id", id, "source", src, "host", c.cfg.Host, "port", c.cfg.Port)
+				return nil
+			}
+			return c
+		}
+	}
+	return nil
+}
+
+// fromUpstream 请求来源是否为配置的上级平台地址
+func (c *cascade) fromUpstream(src net.Addr) bool {
+	if src == nil {
+		return false
+	}
+	host, port, err := net.SplitHostPort(src.String())
+	if err != nil || port != strconv.Itoa(c.cfg.Port) {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	addrs := c.upstreamIPs.Load()
+	if addrs == nil {
+		return false
+	}
+	for _, v := range *addrs {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// answerCascadeCatalog 应答上级平台的目录查询，先回复 200 再分批发送通道
+func (s *Server) answerCascadeCatalog(ctx *sip.Context, sn int) {
+	c := s.cascadeFor(ctx.DeviceID, ctx.Request.Source())
+	if c == nil {
+		ctx.String(http.StatusForbidden, "unknown superior platform")
+		return
+	}
+	ctx.String(http.StatusOK, "OK")
+
+	var channels []*ipc.Channel
+	if _, err := s.gb.core.Store().Channel().Find(context.Background(), &channels, web.NewPagerFilterMaxSize(), orm.Where("type=?", ipc.TypeGB28181)); err != nil {
+		ctx.Log.Error("查询级联通道失败", "err", err)
+		return
+	}
+	bodies, err := cascadeCatalogBodies(s.gb.cfg.ID, sn, channels, cascadeCatalogBatch)
+	if err != nil {
+		ctx.Log.Error("编码目录应答失败", "err", err)
+		return
+	}
+	for _, body := range bodies {
+		if err := c.message(body); err != nil {
+			ctx.Log.Error("向上级平台发送目录失败", "err", err)
+			return
+		}
+	}
+}
+
+// handleCascadeInvite 上级平台点播本平台的通道，拉起本地流后由媒体服务器推送给上级
+// 仅支持实时流，TCP 只支持由本平台主动连接上级
+func (s *Server) handleCascadeInvite(ctx *sip.Context) {
+	c := s.cascadeFor(ctx.DeviceID, ctx.Request.Source())
+	if c == nil {
+		ctx.String(http.StatusForbidden, "unknown superior platform")
+		return
+	}
+	offer, err := parseCascadeOffer(ctx.Request.Body())
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if offer.Name != "Play" || (offer.isTCP() && offer.Setup == "active") {
+		ctx.String(statusNotAcceptableHere, "unsupported media")
+		return
+	}
+	ctx.String(http.StatusContinue, "Trying")
+
+	var channelID string
+	if user := ctx.Request.Recipient().User(); user != nil {
+		channelID = user.String()
+	}
+	sess, port, err := s.startCascadePush(context.Background(), channelID, offer)
+	if err != nil {
+		ctx.Log.Error("级联点播失败", "channel_id", channelID, "err", err)
+		ctx.String(http.StatusNotFound, err.Error())
+		return
+	}
+	sess.upstream = c.cfg.ID
+
+	// 应答前登记会话，上级收到 200 后立即发送的 BYE 也能找到推流
+	// 拉流期间上级已掉线时不会再收到 BYE，直接结束推流
+	callID, _ := ctx.Request.CallID()
+	key := callID.String()
+	s.cascadeSessions.Store(key, sess)
+	if !c.registered.Load() {
+		s.dropCascadeSession(key)
+		ctx.String(http.StatusServiceUnavailable, "superior platform unregistered")
+		return
+	}
+
+	ip, err := GetIP(sess.server.GetSDPIP())
+	if err != nil {
+		ip = sess.server.GetSDPIP()
+	}
+	resp := sip.NewResponseFromRequest("", ctx.Request, http.StatusOK, "OK", cascadeAnswer(s.gb.cfg.ID, ip, port, offer))
+	resp.AppendHeader(&sip.ContentTypeSDP)
+	resp.AppendHeader(&sip.ContactHeader{
+		DisplayName: s.fromAddress.DisplayName,
+		Address:     s.fromAddress.URI,
+		Params:      sip.NewParams(),
+	})
+	if err := ctx.Respond(resp); err != nil {
+		s.dropCascadeSession(key)
+	}
+}
+
+// startCascadePush 确保本地通道正在拉流，再通过媒体服务器推送到上级，返回本地发送端口
+func (s *Server) startCascadePush(ctx context.Context, channelID string, offer cascadeOffer) (cascadeSession, int, error) {
+	var ch ipc.Channel
+	if err := s.gb.core.Store().Channel().Get(ctx, &ch, orm.Where("channel_id=? AND type=?", channelID, ipc.TypeGB28181)); err != nil {
+		return cascadeSession{}, 0, ErrChannelNotExist
+	}
+	// 与本地点播一致，通道流在其配置的媒体服务器上
+	svr, err := s.mediaService.GetMediaServer(ctx, cmp.Or(ch.Config.MediaServerID, sms.DefaultMediaServerID))
+	if err != nil {
+		return cascadeSession{}, 0, err
+	}
+	sess := cascadeSession{server: svr, stream: ch.ID, ssrc: offer.SSRC}
+	in := zlm.StartSendRTPRequest{
+		Vhost:   "__defaultVhost__",
+		App:     "rtp",
+		Stream:  ch.ID,
+		SSRC:    offer.SSRC,
+		DstURL:  offer.IP,
+		DstPort: offer.Port,
+		IsUDP:   !offer.isTCP(),
+	}
+	if resp, err := s.gb.sms.StartSendRTP(svr, in); err == nil {
+		return sess, resp.LocalPort, nil
+	}
+
+	// 本地流未在线，先点播设备
+	dev, err := s.gb.core.GetDevice(ctx, ch.DID)
+	if err != nil {
+		return cascadeSession{}, 0, err
+	}
+	if err := s.gb.Play(&PlayInput{
+		Channel:      &ch,
+		SMS:          svr,
+		StreamMode:   dev.StreamMode,
+		MediaFormats: dev.Ext.SDPFormats,
+		SSRCPrefix:   dev.Ext.SSRCPrefix,
+		SSRCFormat:   dev.Ext.SSRCFormat,
+	}); err != nil {
+		return cascadeSession{}, 0, err
+	}
+	deadline := time.Now().Add(cascadeStreamWait)
+	for {
+		resp, err := s.gb.sms.StartSendRTP(svr, in)
+		if err == nil {
+			return sess, resp.LocalPort, nil
+		}
+		if time.Now().After(deadline) {
+			return cascadeSession{}, 0, err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func (s *Server) stopCascadePush(sess cascadeSession) {
+	if err := s.gb.sms.StopSendRTP(sess.server, zlm.StopSendRTPRequest{
+		Vhost:  "__defaultVhost__",
+		App:    "rtp",
+		Stream: sess.stream,
+		SSRC:   sess.ssrc,
+	}); err != nil {
+		slog.Warn("停止级联推流失败", "stream", sess.stream, "err", err)
+	}
+}
+
+// handleBye 上级平台结束点播时停止推流，其余会话直接应答
+func (s *Server) handleBye(ctx *sip.Context) {
+	ctx.String(http.StatusOK, "OK")
+	callID, ok := ctx.Request.CallID()
+	if !ok {
+		return
+	}
+	s.dropCascadeSession(callID.String())
+}
+
+// dropCascadeSession 移除会话并停止推流，会话已被移除时不重复停止
+func (s *Server) dropCascadeSession(key string) {
+	if sess, ok := s.cascadeSessions.LoadAndDelete(key); ok {
+		s.stopCascadePush(sess)
+	}
+}
+
+// stopCascadeSessions 结束转发给指定上级平台的全部推流
+func (s *Server) stopCascadeSessions(upstream string) {
+	s.cascadeSessions.Range(func(key string, sess cascadeSession) bool {
+		if sess.upstream == upstream {
+			s.dropCascadeSession(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,232 @@
+package gbs
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+// fakeUpstream 模拟上级平台，按 handlerRegister 的方式校验摘要认证
+// proxy 为 true 时按代理鉴权返回 407
+type fakeUpstream struct {
+	password string
+	proxy    bool
+	reqs     []*sip.Request
+}
+
+func (f *fakeUpstream) send(req *sip.Request) (*sip.Response, error) {
+	f.reqs = append(f.reqs, req)
+	if req.Method() != sip.MethodRegister {
+		return sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil), nil
+	}
+	code, reasonPhrase, challengeHeader, authHeader := http.StatusUnauthorized, "Unauthorized", "WWW-Authenticate", "Authorization"
+	if f.proxy {
+		code, reasonPhrase, challengeHeader, authHeader = http.StatusProxyAuthRequired, "Proxy Authentication Required", "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	hdrs := req.GetHeaders(authHeader)
+	if len(hdrs) == 0 {
+		resp := sip.NewResponseFromRequest("", req, code, reasonPhrase, nil)
+		resp.AppendHeader(&sip.GenericHeader{HeaderName: challengeHeader, Contents: `Digest realm="3402000000",qop="auth",nonce="0123456789abcdef"`})
+		return resp, nil
+	}
+	from, _ := req.From()
+	auth := sip.AuthFromValue(hdrs[0].(*sip.GenericHeader).Contents)
+	auth.SetPassword(f.password)
+	auth.SetUsername(from.Address.User().String())
+	auth.SetMethod(req.Method())
+	auth.SetURI(auth.Get("uri"))
+	if auth.CalcResponse() != auth.Get("response") {
+		return sip.NewResponseFromRequest("", req, http.StatusForbidden, "Forbidden", nil), nil
+	}
+	return sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil), nil
+}
+
+func newTestCascade(password string, up *fakeUpstream) *cascade {
+	local := conf.SIP{ID: "34010000002000000001", Domain: "3401000000", Port: 15060}
+	contact := newFromAddress(&local)
+	cfg := conf.SIPCascade{ID: "34020000002000000001", Domain: "3402000000", Host: "127.0.0.1", Port: 5060, Password: password}
+	return newCascade(cfg, &local, &contact, up.send)
+}
+
+func TestCascadeRegister(t *testing.T) {
+	up := fakeUpstream{password: "12345678"}
+	c := newTestCascade("12345678", &up)
+
+	if err := c.register(3600); err != nil {
+		t.Fatal(err)
+	}
+	if !c.registered.Load() || len(up.reqs) != 2 {
+		t.Fatalf("expect registered after challenge, got registered=%v requests=%d", c.registered.Load(), len(up.reqs))
+	}
+	// 鉴权重试沿用 Call-ID，CSeq 递增
+	id1, _ := up.reqs[0].CallID()
+	id2, _ := up.reqs[1].CallID()
+	seq1, _ := up.reqs[0].CSeq()
+	seq2, _ := up.reqs[1].CSeq()
+	if id1.String() != id2.String() || seq2.SeqNo <= seq1.SeqNo {
+		t.Fatalf("expect same call-id and increasing cseq, got %s/%d %s/%d", id1, seq1.SeqNo, id2, seq2.SeqNo)
+	}
+
+	if err := c.keepalive(); err != nil {
+		t.Fatal(err)
+	}
+	last := up.reqs[len(up.reqs)-1]
+	if last.Method() != sip.MethodMessage || !strings.Contains(string(last.Body()), "<CmdType>Keepalive</CmdType>") {
+		t.Fatalf("expect keepalive message, got %s", last)
+	}
+
+	if err := c.register(0); err != nil || c.registered.Load() {
+		t.Fatalf("expect unregistered, got %v %v", c.registered.Load(), err)
+	}
+
+	wrong := newTestCascade("wrong", &fakeUpstream{password: "12345678"})
+	if err := wrong.register(3600); err == nil || wrong.registered.Load() {
+		t.Fatal("expect register rejected with wrong password")
+	}
+}
+
+func TestCascadeRegisterProxyAuth(t *testing.T) {
+	up := fakeUpstream{password: "12345678", proxy: true}
+	c := newTestCascade("12345678", &up)
+
+	if err := c.register(3600); err != nil {
+		t.Fatal(err)
+	}
+	if !c.registered.Load() || len(up.reqs) != 2 {
+		t.Fatalf("expect registered after 407 challenge, got registered=%v requests=%d", c.registered.Load(), len(up.reqs))
+	}
+	if len(up.reqs[1].GetHeaders("Authorization")) != 0 {
+		t.Fatal("expect Proxy-Authorization only for 407 challenge")
+	}
+}
+
+func TestCascadeFromUpstream(t *testing.T) {
+	c := newTestCascade("12345678", &fakeUpstream{})
+	c.registered.Store(true)
+	s := Server{cascades: []*cascade{c}}
+
+	if s.cascadeFor(c.cfg.ID, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5060}) != c {
+		t.Fatal("expect cascade matched from configured address")
+	}
+	for _, src := range []net.Addr{
+		&net.UDPAddr{IP: net.ParseIP("10.0.0.8"), Port: 5060},
+		&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5061},
+		nil,
+	} {
+		if s.cascadeFor(c.cfg.ID, src) != nil {
+			t.Fatalf("expect request from %v rejected", src)
+		}
+	}
+
+	// 地址在创建时解析，请求处理时不再查询 DNS
+	c.upstreamIPs.Store(&[]net.IP{net.ParseIP("10.0.0.8")})
+	if s.cascadeFor(c.cfg.ID, &net.UDPAddr{IP: net.ParseIP("10.0.0.8"), Port: 5060}) != c {
+		t.Fatal("expect cached upstream address used")
+	}
+}
+
+func TestCascadeStopNotifiesDown(t *testing.T) {
+	c := newTestCascade("12345678", &fakeUpstream{password: "12345678"})
+	var downs atomic.Int32
+	c.onDown = func() { downs.Add(1) }
+
+	c.start()
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.registered.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("expect registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if downs.Load() != 0 {
+		t.Fatal("expect no down while registered")
+	}
+	// 停止后上级不会再发送 BYE，需结束转发给它的推流
+	c.stop()
+	if downs.Load() != 1 || c.registered.Load() {
+		t.Fatalf("expect down notified once after stop, got %d", downs.Load())
+	}
+}
+
+func TestCascadeCatalogBodies(t *testing.T) {
+	channels := []*ipc.Channel{{ChannelID: "bad", Name: "非国标通道"}}
+	for i := range 23 {
+		channels = append(channels, &ipc.Channel{
+			ChannelID: fmt.Sprintf("340200000013200%05d", i),
+			Name:      fmt.Sprintf("通道%d", i),
+			IsOnline:  i%2 == 0,
+		})
+	}
+
+	bodies, err := cascadeCatalogBodies("34010000002000000001", 7, channels, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nums := []int{10, 10, 3}
+	if len(bodies) != len(nums) {
+		t.Fatalf("expect %d messages, got %d", len(nums), len(bodies))
+	}
+	for i, body := range bodies {
+		var msg MessageDeviceListResponse
+		if err := sip.XMLDecode(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.CmdType != "Catalog" || msg.SN != 7 || msg.SumNum != 23 || len(msg.Item) != nums[i] {
+			t.Fatalf("message %d: unexpected %+v", i, msg)
+		}
+	}
+
+	var first MessageDeviceListResponse
+	_ = sip.XMLDecode(bodies[0], &first)
+	if item := first.Item[0]; item.ChannelID != "34020000001320000000" || item.Name != "通道0" || item.Status != "ON" || item.CivilCode != "34020000" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if first.Item[1].Status != "OFF" {
+		t.Fatalf("expect offline channel OFF, got %s", first.Item[1].Status)
+	}
+
+	// 没有通道也要应答，避免上级一直等待
+	bodies, err = cascadeCatalogBodies("34010000002000000001", 8, nil, 10)
+	if err != nil || len(bodies) != 1 || !strings.Contains(string(bodies[0]), "<SumNum>0</SumNum>") {
+		t.Fatalf("expect one empty response, got %d %v", len(bodies), err)
+	}
+}
+
+func TestParseCascadeOffer(t *testing.T) {
+	const body = "v=0\r\n" +
+		"o=34020000002000000001 0 0 IN IP4 192.168.1.10\r\n" +
+		"s=Play\r\n" +
+		"c=IN IP4 192.168.1.10\r\n" +
+		"t=0 0\r\n" +
+		"m=video 30000 TCP/RTP/AVP 96\r\n" +
+		"a=recvonly\r\n" +
+		"a=setup:passive\r\n" +
+		"a=rtpmap:96 PS/90000\r\n" +
+		"y=0200000001\r\n"
+	o, err := parseCascadeOffer([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Name != "Play" || o.IP != "192.168.1.10" || o.Port != 30000 || !o.isTCP() || o.Setup != "passive" || o.SSRC != "0200000001" {
+		t.Fatalf("unexpected offer %+v", o)
+	}
+
+	answer := string(cascadeAnswer("34010000002000000001", "10.0.0.1", 40000, o))
+	for _, want := range []string{"m=video 40000 TCP/RTP/AVP 96", "a=sendonly", "a=setup:active", "y=0200000001"} {
+		if !strings.Contains(answer, want) {
+			t.Fatalf("expect %q in answer:\n%s", want, answer)
+		}
+	}
+
+	if _, err := parseCascadeOffer([]byte("v=0\r\ns=Play\r\nm=video 30000 RTP/AVP 96\r\n")); err == nil {
+		t.Fatal("expect error without connection address")
+	}
+}
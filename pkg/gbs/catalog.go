@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"log/slog"
 	"net"
+	"strings"
 
 	"github.com/gowvp/owl/pkg/gbs/sip"
 )
@@ -18,9 +19,37 @@ type MessageDeviceListResponse struct {
 	Item     []Channels `xml:"DeviceList>Item"`
 }
 
+// normalize 兼容厂商不规范的目录应答，去除字段首尾空白
+// 缺少设备编码时使用 SIP From 中的编码
+func (m *MessageDeviceListResponse) normalize(fromDeviceID string) {
+	m.DeviceID = strings.TrimSpace(m.DeviceID)
+	if m.DeviceID == "" {
+		m.DeviceID = fromDeviceID
+	}
+	for i := range m.Item {
+		d := &m.Item[i]
+		for _, f := range []*string{
+			&d.ChannelID, &d.Name, &d.Manufacturer, &d.Model, &d.Owner,
+			&d.CivilCode, &d.Address, &d.Status,
+		} {
+			*f = strings.TrimSpace(*f)
+		}
+	}
+}
+
 // sipMessageCatalog 设备目录信息查询应答
 // GB/T28181 90 页 A.2.6.4
+// 上级平台发来的是目录查询 (Query)，由级联应答本平台的通道
 func (g GB28181API) sipMessageCatalog(ctx *sip.Context) {
+	var query struct {
+		XMLName xml.Name
+		SN      int `xml:"SN"`
+	}
+	if err := sip.XMLDecode(ctx.Request.Body(), &query); err == nil && query.XMLName.Local == "Query" {
+		g.svr.answerCascadeCatalog(ctx, query.SN)
+		return
+	}
+
 	var msg MessageDeviceListResponse
 	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
 		slog.Error("Message Unmarshal xml", "err", err)
@@ -31,8 +60,13 @@ func (g GB28181API) sipMessageCatalog(ctx *sip.Context) {
 		ctx.String(200, "OK")
 		return
 	}
+	msg.normalize(ctx.DeviceID)
 
 	for _, d := range msg.Item {
+		if d.ChannelID == "" {
+			slog.Warn("catalog 条目缺少通道编码，已忽略", "deviceID", msg.DeviceID, "name", d.Name)
+			continue
+		}
 		d.DeviceID = msg.DeviceID
 		g.catalog.Write(&sip.CollectorMsg[Channels]{
 			Key:   d.DeviceID,
@@ -75,12 +109,12 @@ func (g *GB28181API) QueryCatalog(deviceID string) error {
 		return ErrDeviceOffline
 	}
 
+	// 先登记再发请求，避免设备应答的分片早于登记到达
+	g.catalog.Run(deviceID)
 	_, err := g.svr.wrapRequest(ipc, sip.MethodMessage, &sip.ContentTypeXML, sip.GetCatalogXML(deviceID))
 	if err != nil {
 		return err
 	}
-
-	g.catalog.Run(deviceID)
 	g.catalog.Wait(deviceID)
 	return nil
 }
@@ -0,0 +1,121 @@
+package gbs
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+// CatalogDiff 两次目录查询之间的通道变化
+type CatalogDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// IsEmpty 通道没有变化
+func (d CatalogDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// diffCatalog 比对前后两次目录的通道 ID，结果按 ID 排序
+func diffCatalog(before, after []string) CatalogDiff {
+	prev := make(map[string]struct{}, len(before))
+	for _, id := range before {
+		prev[id] = struct{}{}
+	}
+	cur := make(map[string]struct{}, len(after))
+	for _, id := range after {
+		cur[id] = struct{}{}
+	}
+
+	var diff CatalogDiff
+	for id := range cur {
+		if _, ok := prev[id]; !ok {
+			diff.Added = append(diff.Added, id)
+		}
+	}
+	for id := range prev {
+		if _, ok := cur[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	return diff
+}
+
+// logCatalogDiff 与该设备上一次的目录比对，通道有增减时记录日志
+// 快照只保存在内存中，服务启动后的第一次完整目录作为基准，不产生日志
+// 未收齐的目录缺少部分分片，只能说明通道存在，因此只记录新增并合入快照，不判定删除
+func (g *GB28181API) logCatalogDiff(deviceID string, channels []*Channels, complete bool) {
+	ids := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		ids = append(ids, ch.ChannelID)
+	}
+
+	prev, ok := g.catalogs.Load(deviceID)
+	if !complete {
+		if !ok {
+			return
+		}
+		diff := diffCatalog(prev, ids)
+		if len(diff.Added) == 0 {
+			return
+		}
+		g.catalogs.Store(deviceID, append(slices.Clone(prev), diff.Added...))
+		slog.Info("catalog changed",
+			"device_id", deviceID,
+			"added", diff.Added,
+			"partial", true,
+		)
+		return
+	}
+
+	g.catalogs.Store(deviceID, ids)
+	if !ok {
+		return
+	}
+	diff := diffCatalog(prev, ids)
+	if diff.IsEmpty() {
+		return
+	}
+	slog.Info("catalog changed",
+		"device_id", deviceID,
+		"added", diff.Added,
+		"removed", diff.Removed,
+		"total", len(ids),
+	)
+}
+
+// startCatalogSync 按配置间隔查询在线设备的目录
+// 每分钟检查一次是否到期，配置热更新后无需重启即可生效
+func (s *Server) startCatalogSync() {
+	var last time.Time
+	conc.Timer(context.Background(), time.Minute, time.Minute, func() {
+		interval := time.Duration(s.gb.cfg.CatalogInterval)
+		if interval <= 0 || time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		s.syncCatalogs()
+	})
+}
+
+// syncCatalogs 依次查询在线设备的目录，结果由目录收集器统一保存
+func (s *Server) syncCatalogs() {
+	var deviceIDs []string
+	s.memoryStorer.RangeDevices(func(key string, dev *Device) bool {
+		if dev.IsOnline {
+			deviceIDs = append(deviceIDs, key)
+		}
+		return true
+	})
+	for _, id := range deviceIDs {
+		if err := s.gb.QueryCatalog(id); err != nil {
+			slog.Warn("scheduled catalog query failed", "device_id", id, "err", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package gbs
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestDiffCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []string
+		after   []string
+		added   []string
+		removed []string
+	}{
+		{name: "unchanged", before: []string{"1", "2"}, after: []string{"2", "1"}},
+		{name: "added", before: []string{"1"}, after: []string{"3", "1", "2"}, added: []string{"2", "3"}},
+		{name: "removed", before: []string{"1", "2", "3"}, after: []string{"2"}, removed: []string{"1", "3"}},
+		{name: "both", before: []string{"1", "2"}, after: []string{"2", "4"}, added: []string{"4"}, removed: []string{"1"}},
+		{name: "duplicated report", before: []string{"1"}, after: []string{"1", "1", "2", "2"}, added: []string{"2"}},
+		{name: "empty before", after: []string{"1"}, added: []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffCatalog(tt.before, tt.after)
+			if !slices.Equal(diff.Added, tt.added) || !slices.Equal(diff.Removed, tt.removed) {
+				t.Fatalf("expect +%v -%v, got +%v -%v", tt.added, tt.removed, diff.Added, diff.Removed)
+			}
+			if diff.IsEmpty() != (len(tt.added)+len(tt.removed) == 0) {
+				t.Fatalf("unexpected IsEmpty %v", diff.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestLogCatalogDiffBaseline(t *testing.T) {
+	g := &GB28181API{catalogs: &conc.Map[string, []string]{}}
+	g.logCatalogDiff("dev", []*Channels{{ChannelID: "1"}, {ChannelID: "2"}}, true)
+	g.logCatalogDiff("dev", []*Channels{{ChannelID: "2"}}, true)
+	ids, ok := g.catalogs.Load("dev")
+	if !ok || !slices.Equal(ids, []string{"2"}) {
+		t.Fatalf("expect latest snapshot [2], got %v", ids)
+	}
+}
+
+func TestLogCatalogDiffPartial(t *testing.T) {
+	g := &GB28181API{catalogs: &conc.Map[string, []string]{}}
+	// 没有完整基准时，未收齐的目录不作为基准
+	g.logCatalogDiff("dev", []*Channels{{ChannelID: "1"}}, false)
+	if _, ok := g.catalogs.Load("dev"); ok {
+		t.Fatal("partial catalog should not become baseline")
+	}
+
+	g.logCatalogDiff("dev", []*Channels{{ChannelID: "1"}, {ChannelID: "2"}}, true)
+	// 缺失分片中的通道不视为删除，新增通道合入快照
+	g.logCatalogDiff("dev", []*Channels{{ChannelID: "3"}}, false)
+	ids, _ := g.catalogs.Load("dev")
+	if !slices.Equal(ids, []string{"1", "2", "3"}) {
+		t.Fatalf("expect [1 2 3], got %v", ids)
+	}
+}
@@ -0,0 +1,61 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestParseCatalogQuirks(t *testing.T) {
+	// 某厂商的目录应答：GB2312 编码、缺少 SumNum 与多数可选字段、字段带换行与空格、设备编码为空
+	const body = `<?xml version="1.0" encoding="GB2312"?>
+<Response>
+<CmdType>Catalog</CmdType>
+<SN>17</SN>
+<DeviceID> </DeviceID>
+<DeviceList Num="3">
+<Item>
+	<DeviceID>
+		34020000001310000001
+	</DeviceID>
+	<Name> 大厅 </Name>
+	<Status>ON</Status>
+	<Parental>null</Parental>
+</Item>
+<Item>
+	<DeviceID>34020000001310000002</DeviceID>
+</Item>
+<Item>
+	<Name>缺少编码</Name>
+</Item>
+</DeviceList>
+</Response>`
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg MessageDeviceListResponse
+	if err := sip.XMLDecode(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.normalize("34020000001320000001")
+
+	if msg.DeviceID != "34020000001320000001" {
+		t.Fatalf("expect device id from sip header, got %q", msg.DeviceID)
+	}
+	if len(msg.Item) != 3 {
+		t.Fatalf("expect 3 items, got %d", len(msg.Item))
+	}
+	first := msg.Item[0]
+	if first.ChannelID != "34020000001310000001" || first.Name != "大厅" || first.Status != "ON" || first.Parental != 0 {
+		t.Fatalf("unexpected first item %+v", first)
+	}
+	if msg.Item[1].ChannelID != "34020000001310000002" || msg.Item[1].Name != "" {
+		t.Fatalf("unexpected second item %+v", msg.Item[1])
+	}
+	if msg.Item[2].ChannelID != "" {
+		t.Fatalf("expect empty channel id for item without DeviceID, got %q", msg.Item[2].ChannelID)
+	}
+}
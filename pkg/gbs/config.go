@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"math"
 
+	"github.com/gowvp/owl/internal/core/ipc"
 	"github.com/gowvp/owl/pkg/gbs/sip"
 )
 
@@ -20,8 +21,8 @@ const (
 	// SVACEncodeConfig = "SVACEncodeConfig"
 	// // SVACDecodeConfig SVAC解码配置
 	// SVACDecodeConfig = "SVACDecodeConfig"
-	// // videoParamAttribute 视频参数属性配置
-	// videoParamAttribute = "VideoParamAttribute"
+	// videoParamAttribute 视频参数属性配置，每路码流一项
+	videoParamAttribute = "VideoParamAttribute"
 	// // videoRecordPlan 录像计划
 	// videoRecordPlan = "VideoRecordPlan"
 	// // videoAlarmRecord 报警录像
@@ -55,7 +56,7 @@ type ConfigDownloadResponse struct {
 	// VideoParamOpt       *VideoParamOpt       `xml:"VideoParamOpt"`
 	// SVACEncodeConfig    *SVACEncodeConfig    `xml:"SVACEncodeConfig"`
 	// SVACDecodeConfig    *SVACDecodeConfig    `xml:"SVACDecodeConfig"`
-	// VideoParamAttribute *VideoParamAttribute `xml:"VideoParamAttribute"`
+	VideoParamAttribute *VideoParamAttribute `xml:"VideoParamAttribute"`
 	// VideoRecordPlan     *VideoRecordPlan     `xml:"VideoRecordPlan"`
 	// VideoAlarmRecord    *VideoAlarmRecord    `xml:"VideoAlarmRecord"`
 	// PictureMask         *PictureMask         `xml:"PictureMask"`
@@ -72,6 +73,30 @@ type SnapShot struct {
 	SessionID string `xml:"SessionID"` // 会话ID，由平台生成，用于关联抓拍的图像与平台请求(必选)
 }
 
+// VideoParamAttribute 视频参数属性配置
+// GB/T28181-2022 A.2.6.7，按主码流、子码流的顺序列出各路码流
+type VideoParamAttribute struct {
+	Num  int                       `xml:"Num,attr"`
+	Item []VideoParamAttributeItem `xml:"Item"`
+}
+
+type VideoParamAttributeItem struct {
+	StreamName   string `xml:"StreamName"`   // 码流名称，如主码流、子码流
+	VideoFormat  string `xml:"VideoFormat"`  // 视频编码格式
+	Resolution   string `xml:"Resolution"`   // 分辨率
+	FrameRate    string `xml:"FrameRate"`    // 帧率
+	BitRateType  string `xml:"BitRateType"`  // 码率类型
+	VideoBitRate string `xml:"VideoBitRate"` // 视频码率
+}
+
+// streams 上报的码流数量，Num 与条目数不一致时以条目为准
+func (v *VideoParamAttribute) streams() int {
+	if len(v.Item) > 0 {
+		return len(v.Item)
+	}
+	return v.Num
+}
+
 // BasicParam 设备基本参数配置
 type BasicParam struct {
 	Name              string `xml:"Name"`              // 设备名称
@@ -95,12 +120,33 @@ func NewBasicParamRequest(sn int32, deviceID string) []byte {
 
 func (g *GB28181API) QueryConfigDownloadBasic(deviceID string) error {
 	slog.Debug("QueryConfigDownloadBasic", "deviceID", deviceID)
+	return g.queryConfigDownload(deviceID, NewBasicParamRequest(1, deviceID))
+}
+
+// NewVideoParamAttributeRequest 查询视频参数属性，用于获取设备支持的码流
+func NewVideoParamAttributeRequest(sn int32, deviceID string) []byte {
+	xmlData, _ := sip.XMLEncode(ConfigDownloadRequest{
+		CmdType:    CMDTypeConfigDownload,
+		SN:         sn,
+		DeviceID:   deviceID,
+		ConfigType: videoParamAttribute,
+	})
+	return xmlData
+}
+
+// QueryConfigDownloadVideoParam 查询设备的码流配置，不支持的设备会返回错误或不应答
+func (g *GB28181API) QueryConfigDownloadVideoParam(deviceID string) error {
+	slog.Debug("QueryConfigDownloadVideoParam", "deviceID", deviceID)
+	return g.queryConfigDownload(deviceID, NewVideoParamAttributeRequest(2, deviceID))
+}
+
+func (g *GB28181API) queryConfigDownload(deviceID string, body []byte) error {
 	ipc, ok := g.svr.memoryStorer.Load(deviceID)
 	if !ok || !ipc.IsOnline {
 		return ErrDeviceOffline
 	}
 
-	tx, err := g.svr.wrapRequest(ipc, sip.MethodMessage, &sip.ContentTypeXML, NewBasicParamRequest(1, deviceID))
+	tx, err := g.svr.wrapRequest(ipc, sip.MethodMessage, &sip.ContentTypeXML, body)
 	if err != nil {
 		return err
 	}
@@ -162,5 +208,15 @@ func (g *GB28181API) sipMessageConfigDownload(ctx *sip.Context) {
 		}
 	}
 
+	if msg.VideoParamAttribute != nil {
+		if n := msg.VideoParamAttribute.streams(); n > 0 {
+			if err := g.core.Edit(ctx.DeviceID, func(d *ipc.Device) {
+				d.Ext.StreamNumbers = n
+			}); err != nil {
+				ctx.Log.Error("sipMessageConfigDownload save stream numbers", "err", err)
+			}
+		}
+	}
+
 	ctx.String(200, "OK")
 }
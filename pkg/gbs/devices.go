@@ -41,6 +41,8 @@ type Device struct {
 
 	keepaliveInterval uint16
 	keepaliveTimeout  uint16
+	// keepaliveMisses 最近一次巡检时已连续丢失的心跳次数
+	keepaliveMisses int
 }
 
 func NewDevice(conn sip.Connection, d *ipc.Device) *Device {
@@ -261,11 +263,11 @@ type Channels struct {
 	Owner        string `xml:"Owner"  json:"owner"  gorm:"column:owner"`
 	CivilCode    string `xml:"CivilCode" json:"civilcode"  gorm:"column:civilcode"`
 	// Address ip地址
-	Address     string `xml:"Address"  json:"address"  gorm:"column:address"`
-	Parental    int    `xml:"Parental"  json:"parental"  gorm:"column:parental"`
-	SafetyWay   int    `xml:"SafetyWay"  json:"safetyway"  gorm:"column:safetyway"`
-	RegisterWay int    `xml:"RegisterWay"  json:"registerway"  gorm:"column:registerway"`
-	Secrecy     int    `xml:"Secrecy" json:"secrecy"  gorm:"column:secrecy"`
+	Address     string     `xml:"Address"  json:"address"  gorm:"column:address"`
+	Parental    sip.XMLInt `xml:"Parental"  json:"parental"  gorm:"column:parental"`
+	SafetyWay   sip.XMLInt `xml:"SafetyWay"  json:"safetyway"  gorm:"column:safetyway"`
+	RegisterWay sip.XMLInt `xml:"RegisterWay"  json:"registerway"  gorm:"column:registerway"`
+	Secrecy     sip.XMLInt `xml:"Secrecy" json:"secrecy"  gorm:"column:secrecy"`
 	// Status 状态  on 在线
 	Status string `xml:"Status"  json:"status"  gorm:"column:status"`
 	// Active 最后活跃时间
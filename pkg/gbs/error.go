@@ -11,6 +11,8 @@ var (
 	ErrDeviceNotExist  = errors.New("device not exist")
 	ErrChannelNotExist = errors.New("channel not exist")
 
-	ErrDeviceOffline  = errors.New("device offline")
-	ErrChannelOffline = errors.New("channel offline")
+	ErrDeviceOffline = errors.New("device offline")
+	// ErrRegisterRequired 开启 StrictKeepalive 时，离线设备需重新注册才能上线
+	ErrRegisterRequired = errors.New("register required")
+	ErrChannelOffline   = errors.New("channel offline")
 )
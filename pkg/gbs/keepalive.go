@@ -1,6 +1,9 @@
 package gbs
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/gowvp/owl/internal/core/ipc"
 	"github.com/gowvp/owl/pkg/gbs/sip"
 	"github.com/ixugo/goddd/pkg/orm"
@@ -23,27 +26,40 @@ func (g *GB28181API) sipMessageKeepalive(ctx *sip.Context) {
 		return
 	}
 
-	// 程序重启时会丢内存，收到 keepalive 时，补上
-	// 并未补充到
-	g.svr.memoryStorer.LoadOrStore(ctx.DeviceID, &Device{
-		conn:   ctx.Request.GetConnection(),
-		source: ctx.Source,
-		to:     ctx.To,
-	})
-
-	if err := g.svr.memoryStorer.Change(ctx.DeviceID, func(d *ipc.Device) error {
-		d.KeepaliveAt = orm.Now()
-		d.IsOnline = msg.Status == "OK" || msg.Status == "ON"
+	err := g.keepalive(ctx.DeviceID, msg.Status == "OK" || msg.Status == "ON", func(d *ipc.Device) {
 		d.Address = ctx.Source.String()
 		d.Transport = ctx.Source.Network()
-		return nil
 	}, func(d *Device) {
 		d.conn = ctx.Request.GetConnection()
 		d.source = ctx.Source
 		d.to = ctx.To
-	}); err != nil {
+	})
+	if errors.Is(err, ErrRegisterRequired) {
+		// 回复 403 促使设备重新发起注册
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
 		ctx.Log.Error("keepalive", "err", err)
 	}
 
 	ctx.String(200, "OK")
 }
+
+// keepalive 刷新设备心跳时间与在线状态
+// 默认快速登录，离线设备有心跳即恢复在线；开启 StrictKeepalive 后要求设备走完整的注册鉴权流程
+func (g *GB28181API) keepalive(deviceID string, online bool, changeFn func(*ipc.Device), changeFn2 func(*Device)) error {
+	if dev, ok := g.svr.memoryStorer.Load(deviceID); (!ok || !dev.IsOnline) && g.cfg.StrictKeepalive {
+		return ErrRegisterRequired
+	}
+
+	// 程序重启时会丢内存，收到 keepalive 时，补上
+	g.svr.memoryStorer.LoadOrStore(deviceID, &Device{})
+
+	return g.svr.memoryStorer.Change(deviceID, func(d *ipc.Device) error {
+		d.KeepaliveAt = orm.Now()
+		d.IsOnline = online
+		changeFn(d)
+		return nil
+	}, changeFn2)
+}
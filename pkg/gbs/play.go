@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -20,6 +21,13 @@ type PlayInput struct {
 	Channel    *ipc.Channel
 	SMS        *sms.MediaServer
 	StreamMode int8
+	// MediaFormats 设备级 SDP 媒体格式，为空时使用全局配置
+	MediaFormats []string
+	// SSRCPrefix/SSRCFormat 设备级 SSRC 前缀与格式，为空时使用全局配置
+	SSRCPrefix string
+	SSRCFormat string
+	// StreamNumber 码流编号，0 主码流/1 子码流/2 第三码流
+	StreamNumber int
 }
 
 type StopPlayInput struct {
@@ -28,17 +36,16 @@ type StopPlayInput struct {
 
 // stopPlay 不加锁的
 func (g *GB28181API) stopPlay(ch *Channel, in *StopPlayInput) error {
-	key := "play:" + in.Channel.DeviceID + ":" + in.Channel.ChannelID
-	stream, ok := g.streams.LoadAndDelete(key)
-	if !ok {
-		return nil
-	}
-
-	if stream.Resp == nil {
+	resp := g.stopSession(playKey(in.Channel.DeviceID, in.Channel.ChannelID))
+	if resp == nil {
 		return nil
 	}
+	return g.sendBye(ch, resp)
+}
 
-	req := sip.NewRequestFromResponse(sip.MethodBYE, stream.Resp)
+// sendBye 结束已建立的点播会话
+func (g *GB28181API) sendBye(ch *Channel, resp *sip.Response) error {
+	req := sip.NewRequestFromResponse(sip.MethodBYE, resp)
 	req.SetDestination(ch.Source())
 	req.SetConnection(ch.Conn())
 
@@ -80,19 +87,26 @@ func (g *GB28181API) Play(in *PlayInput) error {
 	}
 
 	// 播放中
-	key := "play:" + in.Channel.DeviceID + ":" + in.Channel.ChannelID
-	stream, ok := g.streams.LoadOrStore(key, &Streams{})
-	if ok {
+	key := playKey(in.Channel.DeviceID, in.Channel.ChannelID)
+	stream, prev := g.startSession(key, in.Channel.ID)
+	if prev != nil {
 		log.Debug("PLAY 已存在流")
 		// TODO: 临时解决方案，每次播放，先停止再播放
 		// https://github.com/gowvp/owl/issues/16
-		if err := g.stopPlay(ch, &StopPlayInput{
-			Channel: in.Channel,
-		}); err != nil {
-			slog.Error("stop play failed", "err", err)
+		if resp := prev.stop(); resp != nil {
+			if err := g.sendBye(ch, resp); err != nil {
+				slog.Error("stop play failed", "err", err)
+			}
 		}
 	}
 
+	// 名额按流占用，重复点播同一通道不额外计数
+	if err := g.sms.AcquirePlay(in.SMS, in.Channel.ID); err != nil {
+		log.Warn("媒体服务器点播数已达上限", "err", err)
+		g.abortSession(key, stream)
+		return err
+	}
+
 	log.Debug("1. 开启RTP服务器等待接收视频流")
 	// 开启RTP服务器等待接收视频流
 	resp, err := g.sms.OpenRTPServer(in.SMS, zlm.OpenRTPServerRequest{
@@ -101,14 +115,26 @@ func (g *GB28181API) Play(in *PlayInput) error {
 	})
 	if err != nil {
 		log.Debug("1.1. 开启RTP服务器失败", "err", err)
+		g.abortSession(key, stream)
 		return err
 	}
 
 	log.Debug("2. 发送SDP请求", "port", resp.Port)
-	if err := g.sipPlayPush2(ch, in, resp.Port, stream); err != nil {
+	inviteResp, err := g.sipPlayPush2(ch, in, resp.Port)
+	if err != nil {
 		log.Debug("2.1. 发送SDP请求失败", "err", err)
+		g.abortSession(key, stream)
+		if inviteResp != nil {
+			// 会话已建立但 ACK 失败，尝试结束会话
+			_ = g.sendBye(ch, inviteResp)
+		}
 		return err
 	}
+	if !stream.playing(inviteResp) {
+		// 等待响应期间会话已被停止，补发 BYE 避免设备持续推流
+		log.Debug("2.2. 会话已停止，结束点播")
+		return g.sendBye(ch, inviteResp)
+	}
 
 	g.svr.gb.core.EditPlaying(context.TODO(), in.Channel.DeviceID, in.Channel.ChannelID, true)
 
@@ -163,7 +189,8 @@ func GetIP(input string) (string, error) {
 	return input, fmt.Errorf("域名没有解析到IP地址")
 }
 
-func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *Streams) error {
+// playSDP 构建点播 INVITE 的 SDP，传输协议与 setup 由设备的 StreamMode 决定
+func (g *GB28181API) playSDP(channelID, ip4str string, port int, in *PlayInput) []byte {
 	name := "Play"
 	protocal := "TCP/RTP/AVP"
 	if in.StreamMode == 0 {
@@ -175,42 +202,16 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 	// protocal = "RTP/RTCP"
 	// }
 
-	video := sdp.Media{
-		Description: sdp.MediaDescription{
-			Type:     "video",
-			Port:     port,
-			Formats:  []string{"96", "97", "98"},
-			Protocol: protocal,
-		},
-	}
-	video.AddAttribute("recvonly")
-
-	switch in.StreamMode {
-	case 1:
-		video.AddAttribute("setup", "passive")
-		video.AddAttribute("connection", "new")
-	case 2:
-		video.AddAttribute("setup", "active")
-		video.AddAttribute("connection", "new")
+	video := newVideoMedia(port, protocal, in.StreamMode, g.mediaFormats(in.MediaFormats))
+	// 主码流不携带该属性，与历史行为保持一致
+	if in.StreamNumber > 0 {
+		video.AddAttribute("streamnumber", strconv.Itoa(in.StreamNumber))
 	}
-	video.AddAttribute("rtpmap", "96", "PS/90000")
-	video.AddAttribute("rtpmap", "97", "MPEG4/90000")
-	video.AddAttribute("rtpmap", "98", "H264/90000")
-
-	// 获取配置值
-	ipstr := in.SMS.GetSDPIP()
-	// 进行IP解析
-	ip4str, err := GetIP(ipstr)
-	if err != nil {
-		slog.Error("域名解析失败", "域名", ipstr, "错误", err)
-		return err
-	}
-	slog.Info("域名解析成功", "原始域名", ipstr, "解析IP", ip4str)
 
 	// defining message
 	msg := &sdp.Message{
 		Origin: sdp.Origin{
-			Username:    ch.ChannelID, // 媒体服务器id
+			Username:    channelID, // 媒体服务器id
 			NetworkType: "IN",
 			AddressType: "IP4",
 			Address:     ip4str,
@@ -228,12 +229,25 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 			},
 		},
 		Medias: []sdp.Media{video},
-		SSRC:   g.getSSRC(0),
+		SSRC:   g.getSSRC(0, in.SSRCPrefix, in.SSRCFormat),
 		// URI:    fmt.Sprintf("%s:0", channel.ChannelID),
 	}
 
-	// appending message to session
-	body := msg.Append(nil).AppendTo(nil)
+	return msg.Append(nil).AppendTo(nil)
+}
+
+func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int) (*sip.Response, error) {
+	// 获取配置值
+	ipstr := in.SMS.GetSDPIP()
+	// 进行IP解析
+	ip4str, err := GetIP(ipstr)
+	if err != nil {
+		slog.Error("域名解析失败", "域名", ipstr, "错误", err)
+		return nil, err
+	}
+	slog.Info("域名解析成功", "原始域名", ipstr, "解析IP", ip4str)
+
+	body := g.playSDP(ch.ChannelID, ip4str, port, in)
 
 	slog.Info(">>>", "body", string(body))
 	// appending session to byte buffer
@@ -244,11 +258,11 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 		r.AppendHeader(&sip.GenericHeader{HeaderName: "Subject", Contents: fmt.Sprintf("%s:%s,%s:%s", ch.ChannelID, in.Channel.ID, in.Channel.DeviceID, in.Channel.ID)})
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resp, err := sipResponse(tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if contact, _ := resp.Contact(); contact == nil {
@@ -259,10 +273,8 @@ func (g *GB28181API) sipPlayPush2(ch *Channel, in *PlayInput, port int, stream *
 		})
 	}
 
-	stream.Resp = resp
-
 	ackReq := sip.NewRequestFromResponse(sip.MethodACK, resp)
-	return tx.Request(ackReq)
+	return resp, g.svr.SendAck(tx, ackReq)
 
 	// data.Resp = response
 	// // ACK
@@ -0,0 +1,100 @@
+package gbs
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+// 点播会话生命周期 starting -> playing -> stopping
+// 状态只允许单向流转，通过 CAS 保证并发的播放/停止只有一方能完成切换
+const (
+	streamStarting int32 = iota // 已发起点播，尚未收到 INVITE 响应
+	streamPlaying               // 会话已建立，停止时需要发送 BYE
+	streamStopping              // 已停止，不再接受任何状态切换
+)
+
+func playKey(deviceID, channelID string) string {
+	return "play:" + deviceID + ":" + channelID
+}
+
+// playing 会话由启动中切换为播放中，返回 false 表示等待响应期间已被停止，调用方需自行 BYE
+func (s *Streams) playing(resp *sip.Response) bool {
+	s.Resp = resp
+	return atomic.CompareAndSwapInt32(&s.state, streamStarting, streamPlaying)
+}
+
+// stop 将会话切换为停止中，仅当会话处于播放中时返回需要 BYE 的响应
+func (s *Streams) stop() *sip.Response {
+	for {
+		state := atomic.LoadInt32(&s.state)
+		if state == streamStopping {
+			return nil
+		}
+		if atomic.CompareAndSwapInt32(&s.state, state, streamStopping) {
+			if state == streamPlaying {
+				return s.Resp
+			}
+			return nil
+		}
+	}
+}
+
+// startSession 以新会话替换通道当前会话，返回被替换的旧会话，调用方负责停止旧会话
+// streamID 为媒体服务器上的流 ID，用于设备删除时关闭 RTP 服务
+func (g *GB28181API) startSession(key, streamID string) (cur, prev *Streams) {
+	cur = &Streams{StreamID: streamID, state: streamStarting}
+	prev, _ = g.streams.Swap(key, cur)
+	return cur, prev
+}
+
+// abortSession 点播失败时移除会话并释放点播名额，若会话已被新的点播替换则不影响新会话
+func (g *GB28181API) abortSession(key string, s *Streams) {
+	s.stop()
+	if g.streams.CompareAndDelete(key, s) {
+		g.sms.ReleasePlay(s.StreamID)
+	}
+}
+
+// stopSession 移除并停止通道当前会话，释放点播名额，返回需要 BYE 的响应
+func (g *GB28181API) stopSession(key string) *sip.Response {
+	s, ok := g.streams.LoadAndDelete(key)
+	if !ok {
+		return nil
+	}
+	g.sms.ReleasePlay(s.StreamID)
+	return s.stop()
+}
+
+// StopDevicePlay 停止设备下所有通道的点播并清理会话，返回需要关闭 RTP 服务的流 ID
+// 用于设备删除，不依赖设备仍在线，BYE 发送失败仅记录日志
+func (g *GB28181API) StopDevicePlay(deviceID string) []string {
+	prefix := playKey(deviceID, "")
+	var streams []string
+	for _, key := range g.streams.Keys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		s, ok := g.streams.LoadAndDelete(key)
+		if !ok {
+			continue
+		}
+		streams = append(streams, s.StreamID)
+		g.sms.ReleasePlay(s.StreamID)
+
+		resp := s.stop()
+		if resp == nil {
+			continue
+		}
+		ch, ok := g.svr.memoryStorer.GetChannel(deviceID, strings.TrimPrefix(key, prefix))
+		if !ok {
+			continue
+		}
+		if err := g.sendBye(ch, resp); err != nil {
+			slog.Warn("删除设备时结束点播失败", "device_id", deviceID, "channel_id", ch.ChannelID, "err", err)
+		}
+	}
+	return streams
+}
@@ -0,0 +1,100 @@
+package gbs
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/sms"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestPlaySessionConcurrent(t *testing.T) {
+	g := &GB28181API{streams: &conc.Map[string, *Streams]{}, sms: &sms.NodeManager{}}
+	key := playKey("34020000001110000001", "34020000001320000001")
+
+	var invites, byes atomic.Int64
+	var mu sync.Mutex
+	byed := make(map[*sip.Response]int)
+	bye := func(resp *sip.Response) {
+		byes.Add(1)
+		mu.Lock()
+		byed[resp]++
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(2)
+		// 模拟 Play：替换旧会话 -> 等待 INVITE 响应 -> 进入播放中
+		go func() {
+			defer wg.Done()
+			stream, prev := g.startSession(key, "ch1")
+			if prev != nil {
+				if resp := prev.stop(); resp != nil {
+					bye(resp)
+				}
+			}
+			if i%7 == 0 {
+				g.abortSession(key, stream)
+				return
+			}
+			resp := &sip.Response{}
+			invites.Add(1)
+			if !stream.playing(resp) {
+				bye(resp)
+			}
+		}()
+		// 模拟 StopPlay
+		go func() {
+			defer wg.Done()
+			if resp := g.stopSession(key); resp != nil {
+				bye(resp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for resp, n := range byed {
+		if n != 1 {
+			t.Fatalf("session %p bye %d times", resp, n)
+		}
+	}
+
+	// 每个建立成功的会话要么已 BYE，要么是当前仍在播放的会话
+	var alive int64
+	if s, ok := g.streams.Load(key); ok {
+		if resp := g.stopSession(key); resp != nil {
+			alive = 1
+		} else if atomic.LoadInt32(&s.state) != streamStopping {
+			t.Fatalf("unexpected state %d", s.state)
+		}
+	}
+	if invites.Load() != byes.Load()+alive {
+		t.Fatalf("invites[%d] byes[%d] alive[%d]", invites.Load(), byes.Load(), alive)
+	}
+}
+
+func TestStopDevicePlay(t *testing.T) {
+	g := &GB28181API{streams: &conc.Map[string, *Streams]{}, sms: &sms.NodeManager{}}
+	g.startSession(playKey("dev1", "34020000001320000001"), "ch1")
+	g.startSession(playKey("dev1", "34020000001320000002"), "ch2")
+	other, _ := g.startSession(playKey("dev2", "34020000001320000001"), "ch3")
+
+	streams := g.StopDevicePlay("dev1")
+	slices.Sort(streams)
+	if !slices.Equal(streams, []string{"ch1", "ch2"}) {
+		t.Fatalf("expect [ch1 ch2], got %v", streams)
+	}
+	if keys := g.streams.Keys(); len(keys) != 1 || keys[0] != playKey("dev2", "34020000001320000001") {
+		t.Fatalf("only dev2 session should remain, got %v", keys)
+	}
+	if other.state != streamStarting {
+		t.Fatalf("dev2 session should not be stopped, state %d", other.state)
+	}
+	if len(g.StopDevicePlay("dev1")) != 0 {
+		t.Fatal("expect no session after stop")
+	}
+}
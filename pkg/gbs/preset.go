@@ -0,0 +1,111 @@
+package gbs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+// presetQueryTimeout 等待设备应答预置位的最长时间
+const presetQueryTimeout = 5 * time.Second
+
+// ErrPresetQueryTimeout 设备未在超时时间内应答预置位查询
+var ErrPresetQueryTimeout = errors.New("preset query timeout")
+
+// PresetQueryRequest 预置位查询 A.2.4.11
+type PresetQueryRequest struct {
+	XMLName  xml.Name `xml:"Query"`
+	CmdType  string   `xml:"CmdType"`
+	SN       int      `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+}
+
+// PresetItem 设备上报的预置位
+type PresetItem struct {
+	PresetID   string `xml:"PresetID"`
+	PresetName string `xml:"PresetName"`
+}
+
+// MessagePresetResponse 预置位查询应答 A.2.6.11
+type MessagePresetResponse struct {
+	XMLName  xml.Name     `xml:"Response"`
+	CmdType  string       `xml:"CmdType"`
+	SN       int          `xml:"SN"`
+	DeviceID string       `xml:"DeviceID"`
+	Items    []PresetItem `xml:"PresetList>Item"`
+}
+
+// pendingKey 等待设备异步应答的请求标识，由通道 ID 与 SN 组成
+func pendingKey(channelID string, sn int) string {
+	return fmt.Sprintf("%s:%d", channelID, sn)
+}
+
+// QueryPreset 查询通道的预置位，应答通过 MESSAGE 异步返回
+func (g *GB28181API) QueryPreset(ctx context.Context, channel *ipc.Channel) ([]PresetItem, error) {
+	ch, ok := g.svr.memoryStorer.GetChannel(channel.DeviceID, channel.ChannelID)
+	if !ok {
+		return nil, ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return nil, ErrDeviceOffline
+	}
+
+	sn := sip.RandInt(100000, 999999)
+	key := pendingKey(channel.ChannelID, sn)
+	resp := make(chan []PresetItem, 1)
+	g.presets.Store(key, resp)
+	defer g.presets.Delete(key)
+
+	body, err := sip.XMLEncode(PresetQueryRequest{
+		CmdType:  "PresetQuery",
+		SN:       sn,
+		DeviceID: channel.ChannelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(presetQueryTimeout)
+	defer timer.Stop()
+	select {
+	case items := <-resp:
+		return items, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrPresetQueryTimeout
+	}
+}
+
+// sipMessagePreset 预置位查询应答
+func (g *GB28181API) sipMessagePreset(ctx *sip.Context) {
+	var msg MessagePresetResponse
+	if err := sip.XMLDecode(ctx.Request.Body(), &msg); err != nil {
+		ctx.Log.Error("sipMessagePreset", "err", err, "body", hex.EncodeToString(ctx.Request.Body()))
+		ctx.String(400, ErrXMLDecode.Error())
+		return
+	}
+	if resp, ok := g.presets.Load(pendingKey(msg.DeviceID, msg.SN)); ok {
+		select {
+		case resp <- msg.Items:
+		default:
+		}
+	} else {
+		slog.Debug("sipMessagePreset 无等待中的查询", "deviceID", msg.DeviceID, "sn", msg.SN)
+	}
+	ctx.String(200, "OK")
+}
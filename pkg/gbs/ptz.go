@@ -0,0 +1,489 @@
+package gbs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+// PTZ 指令第 4 字节，GB/T 28181 附录 A.3
+const (
+	ptzCmdRight    = 0x01
+	ptzCmdLeft     = 0x02
+	ptzCmdDown     = 0x04
+	ptzCmdUp       = 0x08
+	ptzCmdZoomIn   = 0x10
+	ptzCmdZoomOut  = 0x20
+	fiCmd          = 0x40
+	fiCmdFocusFar  = fiCmd | 0x01
+	fiCmdFocusNear = fiCmd | 0x02
+	fiCmdIrisOpen  = fiCmd | 0x04
+	fiCmdIrisClose = fiCmd | 0x08
+)
+
+var ptzCmdCodes = map[ipc.PTZDirection]byte{
+	ipc.PTZStop:      0x00,
+	ipc.PTZUp:        ptzCmdUp,
+	ipc.PTZDown:      ptzCmdDown,
+	ipc.PTZLeft:      ptzCmdLeft,
+	ipc.PTZRight:     ptzCmdRight,
+	ipc.PTZLeftUp:    ptzCmdLeft | ptzCmdUp,
+	ipc.PTZLeftDown:  ptzCmdLeft | ptzCmdDown,
+	ipc.PTZRightUp:   ptzCmdRight | ptzCmdUp,
+	ipc.PTZRightDown: ptzCmdRight | ptzCmdDown,
+	ipc.PTZZoomIn:    ptzCmdZoomIn,
+	ipc.PTZZoomOut:   ptzCmdZoomOut,
+	ipc.PTZFocusNear: fiCmdFocusNear,
+	ipc.PTZFocusFar:  fiCmdFocusFar,
+	ipc.PTZIrisOpen:  fiCmdIrisOpen,
+	ipc.PTZIrisClose: fiCmdIrisClose,
+}
+
+// PTZControlInput 云台控制参数
+type PTZControlInput struct {
+	Channel   *ipc.Channel
+	Direction ipc.PTZDirection
+	Speed     int
+}
+
+// PTZPositionInput 3D 定位参数
+type PTZPositionInput struct {
+	Channel *ipc.Channel
+	X, Y    float64
+	Zoom    float64
+}
+
+// PTZFIInput 聚焦/光圈控制参数，正负表示方向，绝对值为速度，0 表示停止
+type PTZFIInput struct {
+	Channel *ipc.Channel
+	Focus   int // >0 聚焦远，<0 聚焦近
+	Iris    int // >0 光圈放大，<0 光圈缩小
+}
+
+// PTZAbsoluteInput 绝对位置参数，水平 0~360 度，垂直 -90~90 度，变倍 1~ipc.PTZZoomMax
+type PTZAbsoluteInput struct {
+	Channel *ipc.Channel
+	Pan     float64
+	Tilt    float64
+	Zoom    float64
+}
+
+// DeviceControl 设备控制 A.2.3.1
+type DeviceControl struct {
+	XMLName        xml.Name        `xml:"Control"`
+	CmdType        string          `xml:"CmdType"`
+	SN             int             `xml:"SN"`
+	DeviceID       string          `xml:"DeviceID"`
+	PTZCmd         string          `xml:"PTZCmd,omitempty"`
+	AlarmCmd       string          `xml:"AlarmCmd,omitempty"`
+	DragZoomIn     *DragZoom       `xml:"DragZoomIn,omitempty"`
+	DragZoomOut    *DragZoom       `xml:"DragZoomOut,omitempty"`
+	PTZPreciseCtrl *PTZPreciseCtrl `xml:"PTZPreciseCtrl,omitempty"`
+	Info           *ControlInfo    `xml:"Info,omitempty"`
+}
+
+// PTZPreciseCtrl 云台精准控制 GB/T 28181-2022 A.2.3.1.13，角度单位为度
+type PTZPreciseCtrl struct {
+	Pan  float64 `xml:"Pan"`  // 水平角度 0~360
+	Tilt float64 `xml:"Tilt"` // 垂直角度 -90~90
+	Zoom float64 `xml:"Zoom"` // 变倍倍数 1~ipc.PTZZoomMax
+}
+
+// ControlInfo 控制指令的附加信息，云台控制时为优先级(1 最高)，报警复位时为报警方式与类型
+type ControlInfo struct {
+	ControlPriority int    `xml:"ControlPriority,omitempty"`
+	AlarmMethod     string `xml:"AlarmMethod,omitempty"`
+	AlarmType       string `xml:"AlarmType,omitempty"`
+}
+
+// DragZoom 拉框放大/缩小 A.2.3.1.10，单位均为播放窗口像素
+type DragZoom struct {
+	Length    int `xml:"Length"`    // 播放窗口长度
+	Width     int `xml:"Width"`     // 播放窗口宽度
+	MidPointX int `xml:"MidPointX"` // 拉框中心横坐标
+	MidPointY int `xml:"MidPointY"` // 拉框中心纵坐标
+	LengthX   int `xml:"LengthX"`   // 拉框长度
+	LengthY   int `xml:"LengthY"`   // 拉框宽度
+}
+
+// 归一化坐标换算到的虚拟播放窗口，设备按比例计算，与实际分辨率无关
+const (
+	dragZoomLength = 1920
+	dragZoomWidth  = 1080
+)
+
+// NewDragZoom 将归一化坐标与缩放倍数换算为拉框指令，zoomIn 为 false 时应使用 DragZoomOut
+// 放大时拉框为画面的 1/zoom，缩小时表示当前画面将缩到拉框大小，倍数为 1 时仅居中
+func NewDragZoom(x, y, zoom float64) (box *DragZoom, zoomIn bool) {
+	if zoom <= 0 {
+		zoom = 1
+	}
+	zoomIn = zoom >= 1
+	ratio := zoom
+	if zoomIn {
+		ratio = 1 / zoom
+	}
+	return &DragZoom{
+		Length:    dragZoomLength,
+		Width:     dragZoomWidth,
+		MidPointX: int(math.Round(x * dragZoomLength)),
+		MidPointY: int(math.Round(y * dragZoomWidth)),
+		LengthX:   max(int(math.Round(ratio*dragZoomLength)), 1),
+		LengthY:   max(int(math.Round(ratio*dragZoomWidth)), 1),
+	}, zoomIn
+}
+
+// PTZCmd 生成 8 字节的 PTZ 指令并转为十六进制字符串
+// 字节 1~3 固定为 A5 0F 01，字节 4 为指令码，字节 5/6 为水平/垂直速度(聚焦/光圈速度)，
+// 字节 7 高 4 位为变倍速度，字节 8 为前 7 字节之和的低 8 位
+// 除停止外速度不能为 0，否则设备会把变倍、聚焦、光圈等动作当作停止处理
+func PTZCmd(direction ipc.PTZDirection, speed int) (string, error) {
+	code, ok := ptzCmdCodes[direction]
+	if !ok {
+		return "", fmt.Errorf("unknown ptz direction[%s]", direction)
+	}
+	if speed < 0 || speed > ipc.PTZSpeedMax {
+		return "", ipc.ErrPTZInvalidParam.Withf("speed[%d] out of range 0~%d", speed, ipc.PTZSpeedMax)
+	}
+	if speed == 0 && code != 0 {
+		return "", ipc.ErrPTZInvalidParam.Withf("speed must be greater than 0 for direction[%s]", direction)
+	}
+
+	var b5, b6, b7 byte
+	switch {
+	case code&(ptzCmdZoomIn|ptzCmdZoomOut) != 0 && code&fiCmd == 0:
+		b7 = byte(max(speed>>4, 1)) << 4
+	case code != 0:
+		b5 = byte(speed)
+		b6 = byte(speed)
+	}
+	return ptzCommand(code, b5, b6, b7), nil
+}
+
+// FICmd 生成 FI 指令 A.3.3，聚焦与光圈可在同一条指令中同时调节
+// 字节 4 为 FI 指令码，字节 5 为聚焦速度，字节 6 为光圈速度；两者均为 0 时生成 FI 停止指令
+// FI 停止指令保留 0x40 指令码，不能使用 0x00 全停，否则会打断正在进行的方向转动
+func FICmd(focus, iris int) (string, error) {
+	if focus < -ipc.PTZSpeedMax || focus > ipc.PTZSpeedMax {
+		return "", fmt.Errorf("focus[%d] out of range -%d~%d", focus, ipc.PTZSpeedMax, ipc.PTZSpeedMax)
+	}
+	if iris < -ipc.PTZSpeedMax || iris > ipc.PTZSpeedMax {
+		return "", fmt.Errorf("iris[%d] out of range -%d~%d", iris, ipc.PTZSpeedMax, ipc.PTZSpeedMax)
+	}
+
+	var code byte
+	switch {
+	case focus > 0:
+		code |= fiCmdFocusFar
+	case focus < 0:
+		code |= fiCmdFocusNear
+	}
+	switch {
+	case iris > 0:
+		code |= fiCmdIrisOpen
+	case iris < 0:
+		code |= fiCmdIrisClose
+	}
+	if code == 0 {
+		return ptzCommand(fiCmd, 0, 0, 0), nil
+	}
+	return ptzCommand(code, byte(abs(focus)), byte(abs(iris)), 0), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ptzCommand 组装 8 字节指令，字节 8 为前 7 字节之和的低 8 位
+func ptzCommand(code, b5, b6, b7 byte) string {
+	cmd := [8]byte{0xA5, 0x0F, 0x01, code, b5, b6, b7}
+	var sum int
+	for _, v := range cmd[:7] {
+		sum += int(v)
+	}
+	cmd[7] = byte(sum % 256)
+	return fmt.Sprintf("%X", cmd[:])
+}
+
+// 预置位/巡航/扫描指令码，GB/T 28181 附录 A.3.5~A.3.7
+// 组号放在字节 5，不能与指令码按位或，否则组号大于 0x7F 时会改写指令码
+const (
+	ptzCmdPresetSet    = 0x81
+	ptzCmdPresetCall   = 0x82
+	ptzCmdPresetDel    = 0x83
+	ptzCmdCruiseAdd    = 0x84
+	ptzCmdCruiseDel    = 0x85
+	ptzCmdCruiseSpeed  = 0x86
+	ptzCmdCruiseDwell  = 0x87
+	ptzCmdCruiseStart  = 0x88
+	ptzCmdScan         = 0x89
+	ptzCmdScanSpeed    = 0x8A
+	ptzGroupMax        = 0xFF  // 巡航组号/扫描组号 0~255
+	ptzPresetMax       = 0xFF  // 预置位号 1~255
+	ptzData12Max       = 0xFFF // 巡航速度、停留时间、扫描速度为 12 位数据
+	ptzScanStart       = 0x00
+	ptzScanLeftBorder  = 0x01
+	ptzScanRightBorder = 0x02
+)
+
+var presetCmdCodes = map[string]byte{
+	ipc.PTZPresetSet:  ptzCmdPresetSet,
+	ipc.PTZPresetCall: ptzCmdPresetCall,
+	ipc.PTZPresetDel:  ptzCmdPresetDel,
+}
+
+var cruiseCmdCodes = map[string]byte{
+	ipc.PTZCruiseAdd:   ptzCmdCruiseAdd,
+	ipc.PTZCruiseDel:   ptzCmdCruiseDel,
+	ipc.PTZCruiseSpeed: ptzCmdCruiseSpeed,
+	ipc.PTZCruiseDwell: ptzCmdCruiseDwell,
+	ipc.PTZCruiseStart: ptzCmdCruiseStart,
+}
+
+// PresetCmd 生成预置位指令，字节 6 为预置位号
+func PresetCmd(action string, presetID int) (string, error) {
+	code, ok := presetCmdCodes[action]
+	if !ok {
+		return "", ipc.ErrPTZInvalidParam.Withf("unknown preset action[%s]", action)
+	}
+	if presetID < 1 || presetID > ptzPresetMax {
+		return "", ipc.ErrPTZInvalidParam.Withf("preset id[%d] out of range 1~%d", presetID, ptzPresetMax)
+	}
+	return ptzCommand(code, 0, byte(presetID), 0), nil
+}
+
+// CruiseCmd 生成巡航指令，字节 5 为巡航组号
+// 加入/删除巡航点时字节 6 为预置位号；设置速度/停留时间时字节 6 为数据低 8 位，字节 7 高 4 位为数据高 4 位
+func CruiseCmd(action string, cruiseID, value int) (string, error) {
+	code, ok := cruiseCmdCodes[action]
+	if !ok {
+		return "", ipc.ErrPTZInvalidParam.Withf("unknown cruise action[%s]", action)
+	}
+	if cruiseID < 0 || cruiseID > ptzGroupMax {
+		return "", ipc.ErrPTZInvalidParam.Withf("cruise id[%d] out of range 0~%d", cruiseID, ptzGroupMax)
+	}
+	switch action {
+	case ipc.PTZCruiseAdd, ipc.PTZCruiseDel:
+		// 删除巡航点时预置位号为 0 表示删除整条巡航
+		minID := 1
+		if action == ipc.PTZCruiseDel {
+			minID = 0
+		}
+		if value < minID || value > ptzPresetMax {
+			return "", ipc.ErrPTZInvalidParam.Withf("preset id[%d] out of range %d~%d", value, minID, ptzPresetMax)
+		}
+		return ptzCommand(code, byte(cruiseID), byte(value), 0), nil
+	case ipc.PTZCruiseSpeed, ipc.PTZCruiseDwell:
+		b6, b7, err := ptzData12(value)
+		if err != nil {
+			return "", err
+		}
+		return ptzCommand(code, byte(cruiseID), b6, b7), nil
+	}
+	return ptzCommand(code, byte(cruiseID), 0, 0), nil
+}
+
+// ScanCmd 生成扫描指令，字节 5 为扫描组号
+// 开始扫描/设置边界时字节 6 为操作类型；设置速度时字节 6 为速度低 8 位，字节 7 高 4 位为速度高 4 位
+func ScanCmd(action string, scanID, value int) (string, error) {
+	if scanID < 0 || scanID > ptzGroupMax {
+		return "", ipc.ErrPTZInvalidParam.Withf("scan id[%d] out of range 0~%d", scanID, ptzGroupMax)
+	}
+	switch action {
+	case ipc.PTZScanStart:
+		return ptzCommand(ptzCmdScan, byte(scanID), ptzScanStart, 0), nil
+	case ipc.PTZScanLeft:
+		return ptzCommand(ptzCmdScan, byte(scanID), ptzScanLeftBorder, 0), nil
+	case ipc.PTZScanRight:
+		return ptzCommand(ptzCmdScan, byte(scanID), ptzScanRightBorder, 0), nil
+	case ipc.PTZScanSpeed:
+		b6, b7, err := ptzData12(value)
+		if err != nil {
+			return "", err
+		}
+		return ptzCommand(ptzCmdScanSpeed, byte(scanID), b6, b7), nil
+	}
+	return "", ipc.ErrPTZInvalidParam.Withf("unknown scan action[%s]", action)
+}
+
+// ptzData12 拆分 12 位数据，低 8 位放字节 6，高 4 位放字节 7 的高 4 位
+func ptzData12(value int) (b6, b7 byte, err error) {
+	if value < 1 || value > ptzData12Max {
+		return 0, 0, ipc.ErrPTZInvalidParam.Withf("value[%d] out of range 1~%d", value, ptzData12Max)
+	}
+	return byte(value & 0xFF), byte(value>>8) << 4, nil
+}
+
+// PTZCommandCmd 按类别生成预置位/巡航/扫描指令
+func PTZCommandCmd(in *ipc.PTZCommandInput) (string, error) {
+	switch in.Kind {
+	case ipc.PTZCommandPreset:
+		return PresetCmd(in.Action, in.ID)
+	case ipc.PTZCommandCruise:
+		return CruiseCmd(in.Action, in.ID, in.Value)
+	case ipc.PTZCommandScan:
+		return ScanCmd(in.Action, in.ID, in.Value)
+	}
+	return "", ipc.ErrPTZInvalidParam.Withf("unknown command kind[%s]", in.Kind)
+}
+
+// NewPTZPreciseCtrl 生成云台精准控制参数，超出取值范围时返回错误
+func NewPTZPreciseCtrl(pan, tilt, zoom float64) (*PTZPreciseCtrl, error) {
+	if pan < 0 || pan > 360 {
+		return nil, fmt.Errorf("pan[%v] out of range 0~360", pan)
+	}
+	if tilt < -90 || tilt > 90 {
+		return nil, fmt.Errorf("tilt[%v] out of range -90~90", tilt)
+	}
+	if zoom < 1 || zoom > ipc.PTZZoomMax {
+		return nil, fmt.Errorf("zoom[%v] out of range 1~%d", zoom, ipc.PTZZoomMax)
+	}
+	return &PTZPreciseCtrl{Pan: pan, Tilt: tilt, Zoom: zoom}, nil
+}
+
+// PTZControl 发送云台控制指令
+func (g *GB28181API) PTZControl(_ context.Context, in *PTZControlInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	cmd, err := PTZCmd(in.Direction, in.Speed)
+	if err != nil {
+		return err
+	}
+	slog.Debug("PTZControl", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "cmd", cmd)
+
+	return g.deviceControl(ch, &DeviceControl{
+		PTZCmd: cmd,
+		Info:   &ControlInfo{ControlPriority: 5},
+	})
+}
+
+// PTZFIControl 发送聚焦/光圈控制指令
+func (g *GB28181API) PTZFIControl(_ context.Context, in *PTZFIInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	cmd, err := FICmd(in.Focus, in.Iris)
+	if err != nil {
+		return err
+	}
+	slog.Debug("PTZFIControl", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "cmd", cmd)
+
+	return g.deviceControl(ch, &DeviceControl{
+		PTZCmd: cmd,
+		Info:   &ControlInfo{ControlPriority: 5},
+	})
+}
+
+// PTZCommandInput 预置位/巡航/扫描指令参数
+type PTZCommandInput struct {
+	Channel *ipc.Channel
+	ipc.PTZCommandInput
+}
+
+// PTZCommand 发送预置位/巡航/扫描指令
+func (g *GB28181API) PTZCommand(_ context.Context, in *PTZCommandInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	cmd, err := PTZCommandCmd(&in.PTZCommandInput)
+	if err != nil {
+		return err
+	}
+	slog.Debug("PTZCommand", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "kind", in.Kind, "action", in.Action, "cmd", cmd)
+
+	return g.deviceControl(ch, &DeviceControl{
+		PTZCmd: cmd,
+		Info:   &ControlInfo{ControlPriority: 5},
+	})
+}
+
+// PTZPosition 拉框放大/缩小，将画面中的点移到中心
+func (g *GB28181API) PTZPosition(_ context.Context, in *PTZPositionInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	var body DeviceControl
+	box, zoomIn := NewDragZoom(in.X, in.Y, in.Zoom)
+	if zoomIn {
+		body.DragZoomIn = box
+	} else {
+		body.DragZoomOut = box
+	}
+	slog.Debug("PTZPosition", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "zoom_in", zoomIn, "box", box)
+	return g.deviceControl(ch, &body)
+}
+
+// PTZAbsoluteControl 云台转到绝对位置，使用 2022 版的 PTZPreciseCtrl 指令
+// 设备对 MESSAGE 事务应答非 200(如不支持该指令)或不应答时返回 ErrControlFailed，而不是视为成功
+func (g *GB28181API) PTZAbsoluteControl(_ context.Context, in *PTZAbsoluteInput) error {
+	ch, ok := g.svr.memoryStorer.GetChannel(in.Channel.DeviceID, in.Channel.ChannelID)
+	if !ok {
+		return ErrChannelNotExist
+	}
+	if !ch.device.IsOnline {
+		return ErrDeviceOffline
+	}
+
+	ctrl, err := NewPTZPreciseCtrl(in.Pan, in.Tilt, in.Zoom)
+	if err != nil {
+		return err
+	}
+	slog.Debug("PTZAbsoluteControl", "deviceID", in.Channel.DeviceID, "channelID", in.Channel.ChannelID, "ctrl", ctrl)
+
+	if err := g.deviceControl(ch, &DeviceControl{
+		PTZPreciseCtrl: ctrl,
+		Info:           &ControlInfo{ControlPriority: 5},
+	}); err != nil {
+		return fmt.Errorf("%w: %s", ErrControlFailed, err)
+	}
+	return nil
+}
+
+// deviceControl 向通道发送设备控制指令，未指定 SN 时随机生成
+func (g *GB28181API) deviceControl(ch *Channel, body *DeviceControl) error {
+	body.CmdType = "DeviceControl"
+	if body.SN == 0 {
+		body.SN = sip.RandInt(100000, 999999)
+	}
+	body.DeviceID = ch.ChannelID
+	b, err := sip.XMLEncode(body)
+	if err != nil {
+		return err
+	}
+
+	tx, err := g.svr.wrapRequest(ch, sip.MethodMessage, &sip.ContentTypeXML, b)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
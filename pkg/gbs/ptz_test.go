@@ -0,0 +1,223 @@
+package gbs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+)
+
+func TestPTZCmd(t *testing.T) {
+	tests := []struct {
+		direction ipc.PTZDirection
+		speed     int
+		expect    string
+	}{
+		{direction: ipc.PTZStop, speed: 0, expect: "A50F0100000000B5"},
+		{direction: ipc.PTZLeft, speed: 0x80, expect: "A50F0102808000B7"},
+		{direction: ipc.PTZRightUp, speed: 0xFF, expect: "A50F0109FFFF00BC"},
+		{direction: ipc.PTZZoomIn, speed: 0xFF, expect: "A50F01100000F0B5"},
+		{direction: ipc.PTZZoomOut, speed: 1, expect: "A50F0120000010E5"},
+		{direction: ipc.PTZIrisClose, speed: 0x10, expect: "A50F01481010001D"},
+		{direction: ipc.PTZFocusNear, speed: 0x10, expect: "A50F014210100017"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			got, err := PTZCmd(tt.direction, tt.speed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+
+	if _, err := PTZCmd("spin", 1); err == nil {
+		t.Fatal("expect error for unknown direction")
+	}
+	for _, speed := range []int{-1, ipc.PTZSpeedMax + 1} {
+		if _, err := PTZCmd(ipc.PTZLeft, speed); !errors.Is(err, ipc.ErrPTZInvalidParam) {
+			t.Fatalf("speed %d: expect ErrPTZInvalidParam, got %v", speed, err)
+		}
+	}
+	// 速度为 0 的转动、变倍、聚焦、光圈指令不能静默变为停止
+	for _, direction := range []ipc.PTZDirection{ipc.PTZUp, ipc.PTZLeftDown, ipc.PTZZoomIn, ipc.PTZZoomOut, ipc.PTZFocusFar, ipc.PTZFocusNear, ipc.PTZIrisOpen, ipc.PTZIrisClose} {
+		if _, err := PTZCmd(direction, 0); !errors.Is(err, ipc.ErrPTZInvalidParam) {
+			t.Fatalf("%s speed 0: expect ErrPTZInvalidParam, got %v", direction, err)
+		}
+	}
+}
+
+func TestPTZCommandCmd(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     ipc.PTZCommandInput
+		expect string
+	}{
+		{name: "preset set", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandPreset, Action: ipc.PTZPresetSet, ID: 1}, expect: "A50F018100010037"},
+		{name: "preset call max", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandPreset, Action: ipc.PTZPresetCall, ID: 255}, expect: "A50F018200FF0036"},
+		// 组号 255 必须放在字节 5，指令码保持 0x84
+		{name: "cruise add max id", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseAdd, ID: 255, Value: 1}, expect: "A50F0184FF010039"},
+		{name: "cruise del all", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseDel, ID: 3}, expect: "A50F01850300003D"},
+		{name: "cruise speed", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseSpeed, ID: 1, Value: 0x123}, expect: "A50F01860123106F"},
+		{name: "cruise dwell max", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseDwell, Value: 0xFFF}, expect: "A50F018700FFF02B"},
+		{name: "cruise start", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseStart, ID: 200}, expect: "A50F0188C8000005"},
+		{name: "scan start", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandScan, Action: ipc.PTZScanStart}, expect: "A50F01890000003E"},
+		{name: "scan right border", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandScan, Action: ipc.PTZScanRight, ID: 128}, expect: "A50F0189800200C0"},
+		{name: "scan speed", in: ipc.PTZCommandInput{Kind: ipc.PTZCommandScan, Action: ipc.PTZScanSpeed, ID: 2, Value: 0x100}, expect: "A50F018A02001051"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PTZCommandCmd(&tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+
+	invalid := map[string]ipc.PTZCommandInput{
+		"preset zero":       {Kind: ipc.PTZCommandPreset, Action: ipc.PTZPresetSet},
+		"preset overflow":   {Kind: ipc.PTZCommandPreset, Action: ipc.PTZPresetCall, ID: 256},
+		"preset action":     {Kind: ipc.PTZCommandPreset, Action: "move", ID: 1},
+		"cruise overflow":   {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseStart, ID: 256},
+		"cruise negative":   {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseStart, ID: -1},
+		"cruise add zero":   {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseAdd, ID: 1},
+		"cruise add 256":    {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseAdd, ID: 1, Value: 256},
+		"cruise speed zero": {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseSpeed, ID: 1},
+		"cruise dwell 4096": {Kind: ipc.PTZCommandCruise, Action: ipc.PTZCruiseDwell, ID: 1, Value: 0x1000},
+		"cruise action":     {Kind: ipc.PTZCommandCruise, Action: "stop", ID: 1},
+		"scan overflow":     {Kind: ipc.PTZCommandScan, Action: ipc.PTZScanStart, ID: 256},
+		"scan speed 4096":   {Kind: ipc.PTZCommandScan, Action: ipc.PTZScanSpeed, ID: 1, Value: 0x1000},
+		"scan action":       {Kind: ipc.PTZCommandScan, Action: "up", ID: 1},
+		"unknown kind":      {Kind: "tour", Action: ipc.PTZCruiseStart},
+	}
+	for name, in := range invalid {
+		if got, err := PTZCommandCmd(&in); !errors.Is(err, ipc.ErrPTZInvalidParam) {
+			t.Fatalf("%s: expect ErrPTZInvalidParam, got %s %v", name, got, err)
+		}
+	}
+}
+
+func TestFICmd(t *testing.T) {
+	tests := []struct {
+		name        string
+		focus, iris int
+		expect      string
+	}{
+		{name: "stop", expect: "A50F0140000000F5"},
+		{name: "focus far", focus: 0x10, expect: "A50F014110000006"},
+		{name: "iris close", iris: -1, expect: "A50F0148000100FE"},
+		{name: "focus near and iris open", focus: -0x20, iris: 0x30, expect: "A50F01462030004B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FICmd(tt.focus, tt.iris)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+
+	if _, err := FICmd(256, 0); err == nil {
+		t.Fatal("expect error for focus out of range")
+	}
+	if _, err := FICmd(0, -256); err == nil {
+		t.Fatal("expect error for iris out of range")
+	}
+}
+
+func TestPTZPreciseCtrl(t *testing.T) {
+	ctrl, err := NewPTZPreciseCtrl(180.5, -30, 16.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := sip.XMLEncode(&DeviceControl{CmdType: "DeviceControl", SN: 1, DeviceID: "34020000001320000001", PTZPreciseCtrl: ctrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = "<PTZPreciseCtrl><Pan>180.5</Pan><Tilt>-30</Tilt><Zoom>16.5</Zoom></PTZPreciseCtrl>"
+	if body := strings.Join(strings.Fields(string(b)), ""); !strings.Contains(body, expect) || strings.Contains(body, "<PTZCmd>") {
+		t.Fatalf("expect %s in body, got %s", expect, b)
+	}
+
+	for _, v := range [][3]float64{{-1, 0, 1}, {361, 0, 1}, {0, -91, 1}, {0, 91, 1}, {0, 0, 0.5}, {0, 0, ipc.PTZZoomMax + 1}} {
+		if got, err := NewPTZPreciseCtrl(v[0], v[1], v[2]); err == nil {
+			t.Fatalf("%v: expect error, got %+v", v, got)
+		}
+	}
+}
+
+func TestPTZCmdCode(t *testing.T) {
+	// 字节 4：PTZ 指令 bit0~5 为右/左/下/上/放大/缩小，
+	// FI 指令固定 0x40，bit0~3 为聚焦远/聚焦近/光圈放大/光圈缩小
+	tests := []struct {
+		direction ipc.PTZDirection
+		code      string
+	}{
+		{ipc.PTZStop, "00"},
+		{ipc.PTZUp, "08"},
+		{ipc.PTZDown, "04"},
+		{ipc.PTZLeft, "02"},
+		{ipc.PTZRight, "01"},
+		{ipc.PTZLeftUp, "0A"},
+		{ipc.PTZLeftDown, "06"},
+		{ipc.PTZRightUp, "09"},
+		{ipc.PTZRightDown, "05"},
+		{ipc.PTZZoomIn, "10"},
+		{ipc.PTZZoomOut, "20"},
+		{ipc.PTZFocusFar, "41"},
+		{ipc.PTZFocusNear, "42"},
+		{ipc.PTZIrisOpen, "44"},
+		{ipc.PTZIrisClose, "48"},
+	}
+	if len(tests) != len(ptzCmdCodes) {
+		t.Fatalf("expect %d directions covered, got %d", len(ptzCmdCodes), len(tests))
+	}
+	seen := make(map[string]ipc.PTZDirection)
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			got, err := PTZCmd(tt.direction, 0x10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got[6:8]; code != tt.code {
+				t.Fatalf("expect code %s, got %s", tt.code, code)
+			}
+			if other, ok := seen[tt.code]; ok {
+				t.Fatalf("code %s shared with %s", tt.code, other)
+			}
+			seen[tt.code] = tt.direction
+		})
+	}
+}
+
+func TestNewDragZoom(t *testing.T) {
+	tests := []struct {
+		name   string
+		x, y   float64
+		zoom   float64
+		zoomIn bool
+		expect DragZoom
+	}{
+		{name: "center only", x: 0.25, y: 0.75, zoom: 0, zoomIn: true, expect: DragZoom{1920, 1080, 480, 810, 1920, 1080}},
+		{name: "zoom in", x: 0.5, y: 0.5, zoom: 4, zoomIn: true, expect: DragZoom{1920, 1080, 960, 540, 480, 270}},
+		{name: "zoom out", x: 1, y: 0, zoom: 0.5, zoomIn: false, expect: DragZoom{1920, 1080, 1920, 0, 960, 540}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, zoomIn := NewDragZoom(tt.x, tt.y, tt.zoom)
+			if zoomIn != tt.zoomIn || *box != tt.expect {
+				t.Fatalf("expect %v %+v, got %v %+v", tt.zoomIn, tt.expect, zoomIn, *box)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package gbs
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -16,17 +17,24 @@ import (
 	"github.com/ixugo/goddd/pkg/orm"
 )
 
-const ignorePassword = "#"
-
 type GB28181API struct {
 	cfg  *conf.SIP
 	core ipc.Adapter
 
 	catalog *sip.Collector[Channels]
+	// 各设备最近一次目录中的通道 ID，用于比对通道增减
+	catalogs *conc.Map[string, []string]
 
 	// TODO: 待替换成 redis
 	streams *conc.Map[string, *Streams]
 
+	// 等待应答的预置位查询，key 为通道 ID 与 SN
+	presets *conc.Map[string, chan []PresetItem]
+	// 等待应答的设备控制指令，key 为通道 ID 与 SN
+	controls *conc.Map[string, chan string]
+	// 通道点播使用的码流编号，key 为通道 ID
+	streamNumbers *conc.Map[string, int]
+
 	svr *Server
 
 	sms *sms.NodeManager
@@ -40,9 +48,14 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 		catalog: sip.NewCollector(func(c1, c2 *Channels) bool {
 			return c1.ChannelID == c2.ChannelID
 		}),
-		streams: &conc.Map[string, *Streams]{},
+		streams:  &conc.Map[string, *Streams]{},
+		catalogs: &conc.Map[string, []string]{},
+		presets:  &conc.Map[string, chan []PresetItem]{},
+		controls: &conc.Map[string, chan string]{},
+
+		streamNumbers: &conc.Map[string, int]{},
 	}
-	go g.catalog.Start(func(s string, channel []*Channels) {
+	go g.catalog.Start(func(s string, channel []*Channels, complete bool) {
 		// 零值不做变更，没有通道又何必注册上来
 		if len(channel) == 0 {
 			return
@@ -56,6 +69,8 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 		// 	}
 		// }
 
+		g.logCatalogDiff(s, channel, complete)
+
 		d, ok := g.svr.memoryStorer.Load(s)
 		if ok {
 			for _, ch := range channel {
@@ -82,6 +97,12 @@ func NewGB28181API(cfg *conf.Bootstrap, store ipc.Adapter, sms *sms.NodeManager)
 				Type: ipc.TypeGB28181,
 			}
 		}
+		if !complete {
+			if err := g.core.MergeChannels(out); err != nil {
+				slog.Error("MergeChannels", "err", err)
+			}
+			return
+		}
 		if err := g.core.SaveChannels(out); err != nil {
 			slog.Error("SaveChannels", "err", err)
 		}
@@ -106,6 +127,30 @@ func filterUnknowDevices(deviceID string) error {
 	return nil
 }
 
+// 注册鉴权方式，用于日志审计
+const (
+	authModePassword = "password" // 设备或全局密码鉴权
+	authModeDisabled = "disabled" // 设备显式关闭鉴权
+	authModeSentinel = "sentinel" // 历史数据，设备密码为 "#"
+	authModeNone     = "none"     // 设备与全局均未配置密码
+)
+
+// registerAuth 返回注册鉴权使用的密码与鉴权方式，密码为空表示不鉴权
+// 设备密码优先，为空时使用全局密码
+func registerAuth(dev *ipc.Device, global string) (string, string) {
+	switch {
+	case dev.Ext.AuthDisabled:
+		return "", authModeDisabled
+	case dev.Password == ipc.PasswordNoAuth:
+		return "", authModeSentinel
+	case dev.Password != "":
+		return dev.Password, authModePassword
+	case global != "":
+		return global, authModePassword
+	}
+	return "", authModeNone
+}
+
 func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 	if err := filterUnknowDevices(ctx.DeviceID); err != nil {
 		slog.Error("过滤设备，拒绝注册", "device_id", ctx.DeviceID, "err", err)
@@ -125,13 +170,9 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 		to:     ctx.To,
 	})
 
-	password := dev.Password
+	password, mode := registerAuth(dev, g.cfg.Password)
 	if password == "" {
-		password = g.cfg.Password
-	}
-	// 免鉴权
-	if dev.Password == ignorePassword {
-		password = ""
+		ctx.Log.Warn("设备未鉴权注册", "auth_mode", mode)
 	}
 
 	if password != "" {
@@ -139,7 +180,7 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 		if len(hdrs) == 0 {
 			resp := sip.NewResponseFromRequest("", ctx.Request, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
 			resp.AppendHeader(&sip.GenericHeader{HeaderName: "WWW-Authenticate", Contents: fmt.Sprintf(`Digest realm="%s",qop="auth",nonce="%s"`, g.cfg.Domain, sip.RandString(32))})
-			_ = ctx.Tx.Respond(resp)
+			_ = ctx.Respond(resp)
 			return
 		}
 		authenticateHeader := hdrs[0].(*sip.GenericHeader)
@@ -161,13 +202,13 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 			HeaderName: "Date",
 			Contents:   time.Now().Format("2006-01-02T15:04:05.000"),
 		})
-		_ = ctx.Tx.Respond(resp)
+		_ = ctx.Respond(resp)
 	}
 
 	expire := ctx.GetHeader("Expires")
 	if expire == "0" {
 		ctx.Log.Info("设备注销")
-		g.logout(ctx.DeviceID, func(b *ipc.Device) error {
+		g.logout(ctx.DeviceID, ipc.DeviceStateUnregister, func(b *ipc.Device) error {
 			b.IsOnline = false
 			b.Address = ctx.Source.String()
 			return nil
@@ -198,21 +239,37 @@ func (g *GB28181API) handlerRegister(ctx *sip.Context) {
 	g.QueryDeviceInfo(ctx)
 	_ = g.QueryCatalog(dev.GetGB28181DeviceID())
 	_ = g.QueryConfigDownloadBasic(dev.GetGB28181DeviceID())
+	_ = g.QueryConfigDownloadVideoParam(dev.GetGB28181DeviceID())
 }
 
 func (g GB28181API) login(ctx *sip.Context, fn func(d *ipc.Device) error) {
 	slog.Info("status change 设备上线", "device_id", ctx.DeviceID)
-	g.svr.memoryStorer.Change(ctx.DeviceID, fn, func(d *Device) {
+	var did string
+	if err := g.svr.memoryStorer.Change(ctx.DeviceID, func(d *ipc.Device) error {
+		did = d.ID
+		return fn(d)
+	}, func(d *Device) {
 		d.conn = ctx.Request.GetConnection()
 		d.source = ctx.Source
 		d.to = ctx.To
-	})
+	}); err == nil {
+		g.core.RecordDeviceState(context.Background(), did, true, ipc.DeviceStateRegister)
+	}
 }
 
-func (g GB28181API) logout(deviceID string, changeFn func(*ipc.Device) error) error {
-	slog.Info("status change 设备离线", "device_id", deviceID)
-	return g.svr.memoryStorer.Change(deviceID, changeFn, func(d *Device) {
+// logout 设备离线，why 为上下线记录中的原因
+func (g GB28181API) logout(deviceID, why string, changeFn func(*ipc.Device) error) error {
+	slog.Info("status change 设备离线", "device_id", deviceID, "reason", why)
+	var did string
+	if err := g.svr.memoryStorer.Change(deviceID, func(d *ipc.Device) error {
+		did = d.ID
+		return changeFn(d)
+	}, func(d *Device) {
 		d.Expires = 0
 		d.IsOnline = false
-	})
+	}); err != nil {
+		return err
+	}
+	g.core.RecordDeviceState(context.Background(), did, false, why)
+	return nil
 }
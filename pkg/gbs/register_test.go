@@ -0,0 +1,31 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/gowvp/owl/internal/core/ipc"
+)
+
+func TestRegisterAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		dev      ipc.Device
+		global   string
+		password string
+		mode     string
+	}{
+		{name: "device password", dev: ipc.Device{Password: "dev"}, global: "global", password: "dev", mode: authModePassword},
+		{name: "global password", dev: ipc.Device{}, global: "global", password: "global", mode: authModePassword},
+		{name: "disabled by flag", dev: ipc.Device{Ext: ipc.DeviceExt{AuthDisabled: true}}, global: "global", mode: authModeDisabled},
+		{name: "sentinel", dev: ipc.Device{Password: ipc.PasswordNoAuth}, global: "global", mode: authModeSentinel},
+		{name: "no password", dev: ipc.Device{}, mode: authModeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, mode := registerAuth(&tt.dev, tt.global)
+			if password != tt.password || mode != tt.mode {
+				t.Fatalf("expect (%q, %s), got (%q, %s)", tt.password, tt.mode, password, mode)
+			}
+		})
+	}
+}
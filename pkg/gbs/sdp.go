@@ -0,0 +1,109 @@
+package gbs
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	sdp "github.com/panjjo/gosdp"
+)
+
+// MediaFormat INVITE SDP 中的一项媒体格式，对应 m 行的载荷号与 a=rtpmap
+type MediaFormat struct {
+	Payload  int    // RTP 载荷类型 0~127
+	Encoding string // 编码名/时钟频率，如 PS/90000
+}
+
+// defaultMediaFormats 设备与全局均未配置时使用，与历史行为保持一致
+var defaultMediaFormats = []MediaFormat{
+	{Payload: 96, Encoding: "PS/90000"},
+	{Payload: 97, Encoding: "MPEG4/90000"},
+	{Payload: 98, Encoding: "H264/90000"},
+}
+
+// knownEncodings 国标点播支持的编码名，未知编码设备无法协商，直接拒绝
+var knownEncodings = []string{"PS", "MPEG4", "H264", "H265", "SVAC"}
+
+// ParseMediaFormats 解析 "载荷号 编码/时钟频率" 格式的列表，例如 "96 PS/90000"
+// 空列表返回 nil，由调用方决定回退策略
+func ParseMediaFormats(items []string) ([]MediaFormat, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	out := make([]MediaFormat, 0, len(items))
+	seen := make(map[int]struct{}, len(items))
+	for _, item := range items {
+		fields := strings.Fields(item)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("媒体格式 [%s] 应为 \"载荷号 编码/时钟频率\"", item)
+		}
+		pt, err := strconv.Atoi(fields[0])
+		if err != nil || pt < 0 || pt > 127 {
+			return nil, fmt.Errorf("媒体格式 [%s] 载荷号须为 0~127", item)
+		}
+		if _, ok := seen[pt]; ok {
+			return nil, fmt.Errorf("载荷号 [%d] 重复", pt)
+		}
+		name, rate, ok := strings.Cut(fields[1], "/")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("媒体格式 [%s] 缺少编码名或时钟频率", item)
+		}
+		if !slices.Contains(knownEncodings, strings.ToUpper(name)) {
+			return nil, fmt.Errorf("媒体格式 [%s] 编码 [%s] 不支持，可选 %s", item, name, strings.Join(knownEncodings, "/"))
+		}
+		if n, err := strconv.Atoi(rate); err != nil || n <= 0 {
+			return nil, fmt.Errorf("媒体格式 [%s] 时钟频率无效", item)
+		}
+		seen[pt] = struct{}{}
+		out = append(out, MediaFormat{Payload: pt, Encoding: fields[1]})
+	}
+	return out, nil
+}
+
+// mediaFormats 按 设备 > 全局配置 > 默认 的优先级选择 INVITE 使用的媒体格式
+// 配置非法时记录日志并回退，避免因配置错误导致无法点播
+func (g *GB28181API) mediaFormats(device []string) []MediaFormat {
+	for _, items := range [][]string{device, g.cfg.SDPFormats} {
+		formats, err := ParseMediaFormats(items)
+		if err != nil {
+			slog.Warn("SDP 媒体格式配置无效，已忽略", "err", err, "formats", items)
+			continue
+		}
+		if len(formats) > 0 {
+			return formats
+		}
+	}
+	return defaultMediaFormats
+}
+
+// newVideoMedia 构造 INVITE 的视频媒体描述
+func newVideoMedia(port int, protocol string, streamMode int8, formats []MediaFormat) sdp.Media {
+	payloads := make([]string, 0, len(formats))
+	for _, f := range formats {
+		payloads = append(payloads, strconv.Itoa(f.Payload))
+	}
+	video := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "video",
+			Port:     port,
+			Formats:  payloads,
+			Protocol: protocol,
+		},
+	}
+	video.AddAttribute("recvonly")
+
+	switch streamMode {
+	case 1:
+		video.AddAttribute("setup", "passive")
+		video.AddAttribute("connection", "new")
+	case 2:
+		video.AddAttribute("setup", "active")
+		video.AddAttribute("connection", "new")
+	}
+	for i, f := range formats {
+		video.AddAttribute("rtpmap", payloads[i], f.Encoding)
+	}
+	return video
+}
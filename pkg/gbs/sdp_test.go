@@ -0,0 +1,122 @@
+package gbs
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+	sdp "github.com/panjjo/gosdp"
+)
+
+func TestParseMediaFormats(t *testing.T) {
+	formats, err := ParseMediaFormats([]string{"96 PS/90000", " 99  H265/90000 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats) != 2 || formats[0] != (MediaFormat{Payload: 96, Encoding: "PS/90000"}) || formats[1] != (MediaFormat{Payload: 99, Encoding: "H265/90000"}) {
+		t.Fatalf("unexpected formats %+v", formats)
+	}
+
+	if formats, err := ParseMediaFormats(nil); err != nil || formats != nil {
+		t.Fatalf("expect nil, got %+v %v", formats, err)
+	}
+
+	for _, items := range [][]string{
+		{"128 PS/90000"},
+		{"-1 PS/90000"},
+		{"ps PS/90000"},
+		{"96"},
+		{"96 PS"},
+		{"96 /90000"},
+		{"96 PS/0"},
+		{"96 VP8/90000"},
+		{"96 PS/90000", "96 H264/90000"},
+	} {
+		if _, err := ParseMediaFormats(items); err == nil {
+			t.Fatalf("expect error for %v", items)
+		}
+	}
+}
+
+func TestMediaFormatsPriority(t *testing.T) {
+	g := GB28181API{cfg: &conf.SIP{SDPFormats: []string{"98 H264/90000"}}}
+
+	if got := g.mediaFormats([]string{"100 SVAC/90000"}); len(got) != 1 || got[0].Payload != 100 {
+		t.Fatalf("device formats should win, got %+v", got)
+	}
+	if got := g.mediaFormats(nil); len(got) != 1 || got[0].Payload != 98 {
+		t.Fatalf("expect global formats, got %+v", got)
+	}
+	// 设备配置非法时回退到全局
+	if got := g.mediaFormats([]string{"300 PS/90000"}); len(got) != 1 || got[0].Payload != 98 {
+		t.Fatalf("expect global formats on invalid device config, got %+v", got)
+	}
+
+	g.cfg.SDPFormats = nil
+	if got := g.mediaFormats(nil); len(got) != len(defaultMediaFormats) {
+		t.Fatalf("expect default formats, got %+v", got)
+	}
+}
+
+func TestNewVideoMediaCustomFormats(t *testing.T) {
+	formats, err := ParseMediaFormats([]string{"96 PS/90000", "99 H265/90000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &sdp.Message{
+		Name: "Play",
+		Connection: sdp.ConnectionData{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			IP:          net.ParseIP("127.0.0.1"),
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{newVideoMedia(30000, "TCP/RTP/AVP", 1, formats)},
+	}
+	body := string(msg.Append(nil).AppendTo(nil))
+
+	for _, line := range []string{
+		"m=video 30000 TCP/RTP/AVP 96 99",
+		"a=rtpmap:96 PS/90000",
+		"a=rtpmap:99 H265/90000",
+		"a=setup:passive",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("sdp missing %q:\n%s", line, body)
+		}
+	}
+	for _, line := range []string{"MPEG4/90000", "H264/90000"} {
+		if strings.Contains(body, line) {
+			t.Fatalf("sdp should not contain %q:\n%s", line, body)
+		}
+	}
+}
+
+func TestPlaySDPStreamMode(t *testing.T) {
+	g := GB28181API{cfg: &conf.SIP{Domain: "3402000000"}}
+
+	for _, tc := range []struct {
+		mode     int8
+		protocol string
+		setup    string
+	}{
+		{mode: 0, protocol: "m=video 30000 RTP/AVP "},
+		{mode: 1, protocol: "m=video 30000 TCP/RTP/AVP ", setup: "a=setup:passive"},
+		{mode: 2, protocol: "m=video 30000 TCP/RTP/AVP ", setup: "a=setup:active"},
+	} {
+		body := string(g.playSDP("34020000001320000001", "127.0.0.1", 30000, &PlayInput{StreamMode: tc.mode}))
+		if !strings.Contains(body, tc.protocol) {
+			t.Fatalf("mode %d: expect %q in sdp:\n%s", tc.mode, tc.protocol, body)
+		}
+		if tc.setup == "" {
+			if strings.Contains(body, "a=setup:") {
+				t.Fatalf("mode %d: unexpected setup in sdp:\n%s", tc.mode, body)
+			}
+			continue
+		}
+		if !strings.Contains(body, tc.setup) {
+			t.Fatalf("mode %d: expect %q in sdp:\n%s", tc.mode, tc.setup, body)
+		}
+	}
+}
@@ -17,7 +17,6 @@ import (
 	"github.com/gowvp/owl/pkg/gbs/m"
 	"github.com/gowvp/owl/pkg/gbs/sip"
 	"github.com/ixugo/goddd/pkg/conc"
-	"github.com/ixugo/netpulse/ip"
 )
 
 type MemoryStorer interface {
@@ -41,18 +40,17 @@ type Server struct {
 
 	fromAddress  sip.Address
 	memoryStorer MemoryStorer
+
+	// 上级平台注册会话与转发中的点播
+	cascadeMu       sync.RWMutex
+	cascades        []*cascade
+	cascadeSessions *conc.Map[string, cascadeSession]
 }
 
 func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, func()) {
 	api := NewGB28181API(cfg, store, sc.NodeManager)
 
-	iip := ip.InternalIP()
-	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s:%d", cfg.Sip.ID, iip, cfg.Sip.Port))
-	from := sip.Address{
-		DisplayName: sip.String{Str: "gowvp/owl"},
-		URI:         &uri,
-		Params:      sip.NewParams(),
-	}
+	from := newFromAddress(&cfg.Sip)
 
 	svr = sip.NewServer(&from)
 	svr.Register(api.handlerRegister)
@@ -62,20 +60,28 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 	msg.Handle("DeviceInfo", api.sipMessageDeviceInfo)
 	msg.Handle("ConfigDownload", api.sipMessageConfigDownload)
 	msg.Handle("DeviceConfig", api.handleDeviceConfig)
+	msg.Handle("PresetQuery", api.sipMessagePreset)
+	msg.Handle("DeviceControl", api.sipMessageDeviceControl)
 	// msg.Handle("RecordInfo", api.handlerMessage)
 
 	c := Server{
-		Server:       svr,
-		mediaService: sc,
-		fromAddress:  from,
-		gb:           api,
-		memoryStorer: store.Store().(MemoryStorer),
+		Server:          svr,
+		mediaService:    sc,
+		fromAddress:     from,
+		gb:              api,
+		memoryStorer:    store.Store().(MemoryStorer),
+		cascadeSessions: &conc.Map[string, cascadeSession]{},
 	}
+	svr.Invite(c.handleCascadeInvite)
+	svr.Bye(c.handleBye)
+	svr.Ack(func(*sip.Context) {})
 	api.svr = &c
+	c.selfCheck()
 
 	go svr.ListenUDPServer(fmt.Sprintf(":%d", cfg.Sip.Port))
 	go svr.ListenTCPServer(fmt.Sprintf(":%d", cfg.Sip.Port))
 	go c.startTickerCheck()
+	go c.startCatalogSync()
 	// 等待 UDP 连接
 	for {
 		time.Sleep(50 * time.Millisecond)
@@ -84,21 +90,23 @@ func NewServer(cfg *conf.Bootstrap, store ipc.Adapter, sc sms.Core) (*Server, fu
 			break
 		}
 	}
+	c.startCascades()
 	return &c, c.Close
 }
 
+// Close 注销上级平台后关闭 SIP 服务
+func (s *Server) Close() {
+	s.stopCascades()
+	s.Server.Close()
+}
+
 // SetConfig 热更新 SIP 配置，用于配置变更时更新 from 地址而无需重启服务
 func (s *Server) SetConfig() {
-	cfg := s.gb.cfg
-	iip := ip.InternalIP()
-	uri, _ := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s:%d", cfg.ID, iip, cfg.Port))
-	from := sip.Address{
-		DisplayName: sip.String{Str: "gowvp/owl"},
-		URI:         &uri,
-		Params:      sip.NewParams(),
-	}
+	from := newFromAddress(s.gb.cfg)
 	s.fromAddress = from
 	s.Server.SetFrom(&from)
+	s.selfCheck()
+	s.restartCascades()
 }
 
 // startTickerCheck 定时检查离线，通过心跳超时判断设备是否离线
@@ -113,23 +121,13 @@ func (s *Server) startTickerCheck() {
 				return true
 			}
 
-			// 计算超时时间：心跳间隔 * 超时次数
-			// 默认心跳间隔 60s，超时次数 3 次，即 3 分钟无心跳判定离线
-			interval := dev.keepaliveInterval
-			if interval == 0 {
-				interval = 60
-			}
-			timeoutCount := dev.keepaliveTimeout
-			if timeoutCount == 0 {
-				timeoutCount = 3
-			}
-			timeout := time.Duration(interval) * time.Duration(timeoutCount) * time.Second
+			interval, maxMiss := s.keepalivePolicy(dev)
 
 			// 跳过未收到过心跳的设备（LastKeepaliveAt 为零值），这类设备依赖注册超时处理
 			if dev.LastKeepaliveAt.IsZero() {
 				// 如果注册时间也超过了超时时间，则判定离线
-				if !dev.LastRegisterAt.IsZero() && now.Sub(dev.LastRegisterAt) >= timeout {
-					if err := s.gb.logout(key, func(d *ipc.Device) error {
+				if !dev.LastRegisterAt.IsZero() && now.Sub(dev.LastRegisterAt) >= interval*time.Duration(maxMiss) {
+					if err := s.gb.logout(key, ipc.DeviceStateRegisterTimeout, func(d *ipc.Device) error {
 						d.IsOnline = false
 						return nil
 					}); err != nil {
@@ -139,27 +137,58 @@ func (s *Server) startTickerCheck() {
 				return true
 			}
 
-			// 心跳超时或连接丢失，判定设备离线
-			if sub := now.Sub(dev.LastKeepaliveAt); sub >= timeout || dev.conn == nil {
-				slog.Info("device offline detected",
-					"device_id", key,
-					"last_keepalive", dev.LastKeepaliveAt,
-					"timeout", timeout,
-					"elapsed", sub,
-					"conn_nil", dev.conn == nil,
-				)
-				if err := s.gb.logout(key, func(d *ipc.Device) error {
-					d.IsOnline = false
-					return nil
-				}); err != nil {
-					slog.Error("logout device failed", "device_id", key, "err", err)
-				}
+			// 连续丢失心跳达到阈值或连接丢失，判定设备离线
+			if !s.keepaliveExpired(dev, now) && dev.conn != nil {
+				return true
+			}
+			slog.Info("device offline detected",
+				"device_id", key,
+				"last_keepalive", dev.LastKeepaliveAt,
+				"interval", interval,
+				"missed", dev.keepaliveMisses,
+				"max_miss", maxMiss,
+				"conn_nil", dev.conn == nil,
+			)
+			if err := s.gb.logout(key, ipc.DeviceStateKeepaliveTimeout, func(d *ipc.Device) error {
+				d.IsOnline = false
+				return nil
+			}); err != nil {
+				slog.Error("logout device failed", "device_id", key, "err", err)
 			}
 			return true
 		})
 	})
 }
 
+// keepalivePolicy 返回设备的心跳间隔与允许连续丢失的次数
+// 间隔优先取设备上报值，其次取配置，默认 60s；次数优先取配置，其次取设备上报值，默认 3 次
+func (s *Server) keepalivePolicy(dev *Device) (time.Duration, int) {
+	interval := int(dev.keepaliveInterval)
+	if interval == 0 {
+		interval = s.gb.cfg.KeepaliveInterval
+	}
+	if interval <= 0 {
+		interval = 60
+	}
+
+	maxMiss := s.gb.cfg.KeepaliveMaxMiss
+	if maxMiss <= 0 {
+		maxMiss = int(dev.keepaliveTimeout)
+	}
+	if maxMiss <= 0 {
+		maxMiss = 3
+	}
+	return time.Duration(interval) * time.Second, maxMiss
+}
+
+// keepaliveExpired 统计设备连续丢失的心跳次数，达到阈值时返回 true
+func (s *Server) keepaliveExpired(dev *Device, now time.Time) bool {
+	interval, maxMiss := s.keepalivePolicy(dev)
+	misses := int(now.Sub(dev.LastKeepaliveAt) / interval)
+	dev.keepaliveMisses = misses
+	return misses >= maxMiss
+}
+
 // MODDEBUG MODDEBUG
 var MODDEBUG = "DEBUG"
 
@@ -188,7 +217,7 @@ func LoadSYSInfo() {
 	config = m.MConfig
 	_activeDevices = ActiveDevices{sync.Map{}}
 
-	StreamList = streamsList{&sync.Map{}, &sync.Map{}, 0}
+	StreamList = streamsList{Response: &sync.Map{}, Succ: &sync.Map{}}
 	ssrcLock = &sync.Mutex{}
 	_recordList = &sync.Map{}
 	RecordList = apiRecordList{items: map[string]*apiRecordItem{}, l: sync.RWMutex{}}
@@ -266,7 +295,67 @@ func (s *Server) StopPlay(ctx context.Context, in *StopPlayInput) error {
 	return s.gb.StopPlay(ctx, in)
 }
 
+// SetStreamNumber 记录通道点播使用的码流，返回是否与之前不同
+func (s *Server) SetStreamNumber(channelID string, number int) bool {
+	return s.gb.SetStreamNumber(channelID, number)
+}
+
+// StreamNumber 通道点播使用的码流
+func (s *Server) StreamNumber(channelID string) int {
+	return s.gb.StreamNumber(channelID)
+}
+
+// StopDevicePlay 停止设备下所有点播，返回需要关闭 RTP 服务的流 ID
+func (s *Server) StopDevicePlay(deviceID string) []string {
+	return s.gb.StopDevicePlay(deviceID)
+}
+
+// PTZControl 云台控制
+func (s *Server) PTZControl(ctx context.Context, in *PTZControlInput) error {
+	return s.gb.PTZControl(ctx, in)
+}
+
+// PTZFIControl 聚焦/光圈控制
+func (s *Server) PTZFIControl(ctx context.Context, in *PTZFIInput) error {
+	return s.gb.PTZFIControl(ctx, in)
+}
+
+// ResetAlarm 报警复位
+func (s *Server) ResetAlarm(ctx context.Context, in *ResetAlarmInput) error {
+	return s.gb.ResetAlarm(ctx, in)
+}
+
+// QueryPreset 查询预置位
+func (s *Server) QueryPreset(ctx context.Context, channel *ipc.Channel) ([]PresetItem, error) {
+	return s.gb.QueryPreset(ctx, channel)
+}
+
+// PTZCommand 预置位/巡航/扫描
+func (s *Server) PTZCommand(ctx context.Context, in *PTZCommandInput) error {
+	return s.gb.PTZCommand(ctx, in)
+}
+
+// PTZPosition 3D 定位
+func (s *Server) PTZPosition(ctx context.Context, in *PTZPositionInput) error {
+	return s.gb.PTZPosition(ctx, in)
+}
+
+// PTZAbsoluteControl 云台绝对位置
+func (s *Server) PTZAbsoluteControl(ctx context.Context, in *PTZAbsoluteInput) error {
+	return s.gb.PTZAbsoluteControl(ctx, in)
+}
+
 // QuerySnapshot 厂商实现抓图的少，sip 层已实现，先搁置
 func (s *Server) QuerySnapshot(deviceID, channelID string) error {
 	return s.gb.QuerySnapshot(deviceID, channelID)
 }
+
+// OnlineSnapshot 一次遍历返回内存中全部设备的在线状态，key 为国标编码
+func (s *Server) OnlineSnapshot() map[string]bool {
+	out := make(map[string]bool, 64)
+	s.memoryStorer.RangeDevices(func(key string, dev *Device) bool {
+		out[key] = dev.IsOnline
+		return true
+	})
+	return out
+}
@@ -0,0 +1,124 @@
+package gbs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+// memoryStorerStub 仅实现遍历，其余方法不会被调用
+type memoryStorerStub struct {
+	MemoryStorer
+	devices *conc.Map[string, *Device]
+}
+
+func (m memoryStorerStub) RangeDevices(fn func(key string, value *Device) bool) {
+	m.devices.Range(fn)
+}
+
+func TestOnlineSnapshot(t *testing.T) {
+	devices := conc.NewMap[string, *Device]()
+	devices.Store("34020000001320000001", &Device{IsOnline: true})
+	devices.Store("34020000001320000002", &Device{IsOnline: false})
+	s := Server{memoryStorer: memoryStorerStub{devices: devices}}
+
+	snap := s.OnlineSnapshot()
+	if len(snap) != 2 || !snap["34020000001320000001"] || snap["34020000001320000002"] {
+		t.Fatalf("unexpected snapshot %v", snap)
+	}
+
+	// 快照反映调用时刻的内存状态
+	dev, _ := devices.Load("34020000001320000002")
+	dev.IsOnline = true
+	if snap := s.OnlineSnapshot(); !snap["34020000001320000002"] {
+		t.Fatalf("snapshot should reflect current memory state, got %v", snap)
+	}
+}
+
+func TestKeepaliveExpired(t *testing.T) {
+	s := Server{gb: &GB28181API{cfg: &conf.SIP{KeepaliveInterval: 30, KeepaliveMaxMiss: 4}}}
+	lastKeepalive := time.Now()
+	dev := Device{IsOnline: true, LastKeepaliveAt: lastKeepalive}
+
+	// 丢失 N-1 次心跳仍在线
+	if s.keepaliveExpired(&dev, lastKeepalive.Add(3*30*time.Second+time.Second)) {
+		t.Fatal("device should stay online after 3 missed keepalives")
+	}
+	if dev.keepaliveMisses != 3 {
+		t.Fatalf("expect 3 misses, got %d", dev.keepaliveMisses)
+	}
+
+	// 第 N 次丢失判定离线
+	if !s.keepaliveExpired(&dev, lastKeepalive.Add(4*30*time.Second)) {
+		t.Fatal("device should be offline after 4 missed keepalives")
+	}
+
+	// 设备上报的心跳间隔优先于配置
+	dev.keepaliveInterval = 10
+	if !s.keepaliveExpired(&dev, lastKeepalive.Add(40*time.Second)) {
+		t.Fatal("device reported interval should take precedence")
+	}
+}
+
+// changeStorerStub 模拟内存与数据库同步修改设备状态
+type changeStorerStub struct {
+	memoryStorerStub
+	db map[string]*ipc.Device
+}
+
+func (m changeStorerStub) Load(deviceID string) (*Device, bool) {
+	return m.devices.Load(deviceID)
+}
+
+func (m changeStorerStub) LoadOrStore(deviceID string, value *Device) {
+	m.devices.LoadOrStore(deviceID, value)
+}
+
+func (m changeStorerStub) Change(deviceID string, changeFn func(*ipc.Device) error, changeFn2 func(*Device)) error {
+	d := m.db[deviceID]
+	if err := changeFn(d); err != nil {
+		return err
+	}
+	dev, _ := m.devices.Load(deviceID)
+	dev.IsOnline = d.IsOnline
+	changeFn2(dev)
+	return nil
+}
+
+func TestKeepaliveQuickLogin(t *testing.T) {
+	const deviceID = "34020000001320000001"
+	newAPI := func(quickLogin bool) *GB28181API {
+		devices := conc.NewMap[string, *Device]()
+		devices.Store(deviceID, &Device{IsOnline: false})
+		store := changeStorerStub{
+			memoryStorerStub: memoryStorerStub{devices: devices},
+			db:               map[string]*ipc.Device{deviceID: {DeviceID: deviceID}},
+		}
+		g := GB28181API{cfg: &conf.SIP{StrictKeepalive: !quickLogin}}
+		g.svr = &Server{gb: &g, memoryStorer: store}
+		return &g
+	}
+	noop := func(*ipc.Device) {}
+	noop2 := func(*Device) {}
+
+	g := newAPI(true)
+	if err := g.keepalive(deviceID, true, noop, noop2); err != nil {
+		t.Fatal(err)
+	}
+	if dev, _ := g.svr.memoryStorer.Load(deviceID); !dev.IsOnline {
+		t.Fatal("keepalive should restore online state under quick login")
+	}
+
+	g = newAPI(false)
+	if err := g.keepalive(deviceID, true, noop, noop2); !errors.Is(err, ErrRegisterRequired) {
+		t.Fatalf("expect ErrRegisterRequired, got %v", err)
+	}
+	if dev, _ := g.svr.memoryStorer.Load(deviceID); dev.IsOnline {
+		t.Fatal("offline device should stay offline without quick login")
+	}
+}
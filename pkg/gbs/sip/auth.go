@@ -102,6 +102,14 @@ func (auth *Authorization) SetPassword(password string) *Authorization {
 	return auth
 }
 
+// SetCnonce 客户端随机数与请求计数，qop 为 auth 时参与摘要计算
+func (auth *Authorization) SetCnonce(cnonce, nc string) *Authorization {
+	auth.cnonce = cnonce
+	auth.nc = nc
+
+	return auth
+}
+
 // CalcResponse CalcResponse
 func (auth *Authorization) CalcResponse() string {
 	auth.response = CalcResponse(
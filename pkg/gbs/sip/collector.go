@@ -9,8 +9,8 @@ import (
 // Collector .
 // 1. 收集器
 // 2. 分门别类
-// 3. 定时同步，超时删除，删除之前再同步一次
-// 4. 不会去重
+// 3. 按 SumNum 收齐后立即同步，超时删除，删除之前再同步一次
+// 4. 通过 noRepeatFn 去重，大型 NVR 分多条 MESSAGE 上报目录，分片可能乱序或重复
 // 如何使用?
 // 1. 通过 NewCatalogRecv 创建一个新的收集器
 // 2. s.createCh <- deviceID
@@ -21,6 +21,11 @@ type Collector[T any] struct {
 	createCh   chan string
 	noRepeatFn NoRepeatFn[T]
 	observer   *Observer
+
+	// timeout 超过该时长未收到新分片视为缺片，按未收齐保存已收到的部分
+	timeout time.Duration
+	// checkInterval 超时检查间隔
+	checkInterval time.Duration
 }
 
 func (c *Collector[T]) Run(key string) {
@@ -42,15 +47,20 @@ type CollectorMsg[T any] struct {
 
 type NoRepeatFn[T any] func(*T, *T) bool
 
+// CollectorSaveFn 保存收集结果，complete 表示已按总数收齐
+type CollectorSaveFn[T any] func(key string, data []*T, complete bool)
+
 // newCollector 创建一个新的收集器
 // noRepeatFn 用于提前去重，避免重复数据存储
 func NewCollector[T any](noRepeatFn NoRepeatFn[T]) *Collector[T] {
 	return &Collector[T]{
-		data:       make(map[string]*Content[T]),
-		msg:        make(chan *CollectorMsg[T], 512),
-		createCh:   make(chan string, 100),
-		noRepeatFn: noRepeatFn,
-		observer:   NewObserver(),
+		data:          make(map[string]*Content[T]),
+		msg:           make(chan *CollectorMsg[T], 512),
+		createCh:      make(chan string, 100),
+		noRepeatFn:    noRepeatFn,
+		observer:      NewObserver(),
+		timeout:       10 * time.Second,
+		checkInterval: 3 * time.Second,
 	}
 }
 
@@ -60,54 +70,69 @@ type Content[T any] struct {
 	total        int
 }
 
+func newContent[T any]() *Content[T] {
+	return &Content[T]{lastUpdateAt: time.Now(), data: make([]*T, 0, 2), total: -1}
+}
+
+// complete 已收到的条目数达到设备上报的总数
+func (c *Content[T]) complete() bool {
+	return c.total > 0 && len(c.data) >= c.total
+}
+
 // Wait 在执行 Start 以后，可以调用 Wait 等待
 func (c *Collector[T]) Wait(key string) {
 	c.observer.DefaultRegister(key)
 }
 
 // Start 启动定时任务检查和保存数据
-func (c *Collector[T]) Start(save func(string, []*T)) {
-	fn := func(k string, data []*T) {
-		save(k, data)
+func (c *Collector[T]) Start(save CollectorSaveFn[T]) {
+	fn := func(k string, v *Content[T]) {
+		complete := v.complete()
+		if !complete {
+			slog.Warn("catalog 未收齐，保存已收到的部分", "key", k, "received", len(v.data), "total", v.total)
+		}
+		save(k, v.data, complete)
+		delete(c.data, k)
 		c.observer.Notify(k)
 	}
 
-	check := time.NewTicker(time.Second * 3)
+	check := time.NewTicker(c.checkInterval)
 	defer check.Stop()
 	for {
 		select {
 		case <-check.C:
 			for k, v := range c.data {
-				if time.Since(v.lastUpdateAt) > 10*time.Second {
-					fn(k, v.data)
-					delete(c.data, k)
-					continue
-				}
-				if v.total > 0 && len(v.data) >= v.total {
-					fn(k, v.data)
-					delete(c.data, k)
-					continue
+				if time.Since(v.lastUpdateAt) > c.timeout {
+					fn(k, v)
 				}
 			}
 		case v := <-c.createCh:
-			c.data[v] = &Content[T]{lastUpdateAt: time.Now(), data: make([]*T, 0, 2), total: -1}
+			// 分片可能先于查询登记到达，已有数据时不能覆盖
+			if _, ok := c.data[v]; !ok {
+				c.data[v] = newContent[T]()
+			}
 		case msg := <-c.msg:
 			data, exist := c.data[msg.Key]
 			if !exist {
-				slog.Debug("key 不存在或已过期", "key", msg.Key, "data", msg.Data)
-				continue
+				// 设备主动上报或应答先于查询登记到达
+				data = newContent[T]()
+				c.data[msg.Key] = data
+			}
+			if msg.Total > 0 {
+				data.total = msg.Total
 			}
-			// 如果数据已存在且无重复，跳过该消息
+			data.lastUpdateAt = time.Now()
+			// 如果数据已存在，跳过该消息
 			if slices.ContainsFunc(data.data, func(v *T) bool {
 				return c.noRepeatFn(v, msg.Data)
 			}) {
 				slog.Debug("catalog 发现重复数据", "key", msg.Key, "data", msg.Data)
 				continue
 			}
-			// 添加数据到对应的条目并更新最后更新时间和总量
 			data.data = append(data.data, msg.Data)
-			data.lastUpdateAt = time.Now()
-			data.total = msg.Total
+			if data.complete() {
+				fn(msg.Key, data)
+			}
 		}
 	}
 }
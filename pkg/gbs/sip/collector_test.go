@@ -0,0 +1,80 @@
+package sip
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type collectorItem struct {
+	ID string
+}
+
+type collectorResult struct {
+	key      string
+	ids      []string
+	complete bool
+}
+
+func startTestCollector(timeout time.Duration) (*Collector[collectorItem], chan collectorResult) {
+	c := NewCollector(func(a, b *collectorItem) bool { return a.ID == b.ID })
+	c.timeout = timeout
+	c.checkInterval = 10 * time.Millisecond
+	out := make(chan collectorResult, 4)
+	go c.Start(func(key string, data []*collectorItem, complete bool) {
+		ids := make([]string, 0, len(data))
+		for _, v := range data {
+			ids = append(ids, v.ID)
+		}
+		slices.Sort(ids)
+		out <- collectorResult{key: key, ids: ids, complete: complete}
+	})
+	return c, out
+}
+
+func waitCollector(t *testing.T, out chan collectorResult) collectorResult {
+	t.Helper()
+	select {
+	case r := <-out:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("collector did not flush")
+	}
+	return collectorResult{}
+}
+
+func TestCollectorMultiPart(t *testing.T) {
+	c, out := startTestCollector(time.Minute)
+
+	// 分片乱序且含重复，首个分片先于 Run 到达
+	for _, id := range []string{"3", "1", "3", "1"} {
+		c.Write(&CollectorMsg[collectorItem]{Key: "dev", Data: &collectorItem{ID: id}, Total: 4})
+	}
+	c.Run("dev")
+	for _, id := range []string{"4", "2"} {
+		c.Write(&CollectorMsg[collectorItem]{Key: "dev", Data: &collectorItem{ID: id}, Total: 4})
+	}
+
+	r := waitCollector(t, out)
+	if !r.complete || r.key != "dev" || !slices.Equal(r.ids, []string{"1", "2", "3", "4"}) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
+
+func TestCollectorMissingPartTimeout(t *testing.T) {
+	c, out := startTestCollector(50 * time.Millisecond)
+
+	c.Run("dev")
+	for _, id := range []string{"1", "2"} {
+		c.Write(&CollectorMsg[collectorItem]{Key: "dev", Data: &collectorItem{ID: id}, Total: 3})
+	}
+
+	start := time.Now()
+	r := waitCollector(t, out)
+	if r.complete || !slices.Equal(r.ids, []string{"1", "2"}) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if time.Since(start) < 40*time.Millisecond {
+		t.Fatal("flushed before timeout")
+	}
+}
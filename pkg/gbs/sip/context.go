@@ -130,7 +130,15 @@ func (c *Context) AbortString(status int, msg string) {
 }
 
 func (c *Context) String(status int, msg string) {
-	_ = c.Tx.Respond(NewResponseFromRequest("", c.Request, status, msg, nil))
+	_ = c.Respond(NewResponseFromRequest("", c.Request, status, msg, nil))
+}
+
+// Respond 发送应答，经过报文日志与设备跟踪
+func (c *Context) Respond(resp *Response) error {
+	if c.svr != nil {
+		c.svr.logMessage("send", resp)
+	}
+	return c.Tx.Respond(resp)
 }
 
 func (c *Context) Set(k string, v any) {
@@ -0,0 +1,69 @@
+package sip
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// messageLog 运行时控制 SIP 报文日志，可限定只记录单个设备的报文
+type messageLog struct {
+	m        sync.RWMutex
+	enabled  bool
+	deviceID string
+}
+
+func (l *messageLog) set(enabled bool, deviceID string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.enabled = enabled
+	l.deviceID = deviceID
+}
+
+func (l *messageLog) get() (bool, string) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.enabled, l.deviceID
+}
+
+// allow deviceID 为空表示记录全部设备
+func (l *messageLog) allow(deviceID string) bool {
+	enabled, scope := l.get()
+	return enabled && (scope == "" || scope == deviceID)
+}
+
+// SetMessageLog 运行时开关 SIP 报文日志，deviceID 非空时只记录该设备的报文
+func (s *Server) SetMessageLog(enabled bool, deviceID string) {
+	s.msgLog.set(enabled, deviceID)
+}
+
+// MessageLog 返回 SIP 报文日志开关与限定的设备
+func (s *Server) MessageLog() (bool, string) {
+	return s.msgLog.get()
+}
+
+// logMessage 记录报文并复制给跟踪该设备的订阅者，direction 为 recv/send
+func (s *Server) logMessage(direction string, msg Message) {
+	deviceID := messageDeviceID(direction, msg)
+	s.tracer.tee(deviceID, direction, msg)
+
+	if !s.msgLog.allow(deviceID) {
+		return
+	}
+	slog.Info("sip message", "direction", direction, "device_id", deviceID, "msg", msg.String())
+}
+
+// messageDeviceID 报文对端的设备 ID
+// 设备发来的请求及平台对其的应答取 From，发往设备的请求及设备的应答取 To
+func messageDeviceID(direction string, msg Message) string {
+	_, isRequest := msg.(*Request)
+	if isRequest == (direction == "recv") {
+		if from, ok := msg.From(); ok && from.Address != nil && from.Address.User() != nil {
+			return from.Address.User().String()
+		}
+		return ""
+	}
+	if to, ok := msg.To(); ok && to.Address != nil && to.Address.User() != nil {
+		return to.Address.User().String()
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package sip
+
+import "testing"
+
+func TestMessageLogGate(t *testing.T) {
+	var l messageLog
+	if l.allow("34020000001320000001") {
+		t.Fatal("log should be disabled by default")
+	}
+
+	l.set(true, "")
+	if !l.allow("34020000001320000001") || !l.allow("34020000001320000002") {
+		t.Fatal("empty scope should log all devices")
+	}
+
+	l.set(true, "34020000001320000001")
+	if !l.allow("34020000001320000001") {
+		t.Fatal("scoped device should be logged")
+	}
+	if l.allow("34020000001320000002") || l.allow("") {
+		t.Fatal("other devices should not be logged")
+	}
+
+	l.set(false, "34020000001320000001")
+	if l.allow("34020000001320000001") {
+		t.Fatal("disabled log should not log scoped device")
+	}
+}
@@ -18,6 +18,13 @@ import (
 
 var bufferSize uint16 = 65535 - 20 - 8 // IPv4 max size - IPv4 Header size - UDP Header size
 
+const (
+	// maxTCPHeaderSize TCP 报文起始行与头部的最大长度
+	maxTCPHeaderSize = 65535
+	// maxTCPBodySize TCP 报文体的最大长度，目录等 XML 报文远小于此值，防止对端声明超大 Content-Length 耗尽内存
+	maxTCPBodySize = 1 << 20
+)
+
 // Server sip
 type Server struct {
 	// udpaddr net.Addr
@@ -39,6 +46,9 @@ type Server struct {
 	cancel context.CancelFunc
 
 	from *Address
+
+	msgLog messageLog
+	tracer messageTracer
 }
 
 // NewServer sip server
@@ -77,6 +87,21 @@ func (s *Server) Notify(handler ...HandlerFunc) *RouteGroup {
 	return newRouteGroup(MethodNotify, s, handler...)
 }
 
+// Invite 上级平台的点播请求
+func (s *Server) Invite(handler ...HandlerFunc) {
+	s.addRoute(MethodInvite, handler...)
+}
+
+// Bye 结束会话请求
+func (s *Server) Bye(handler ...HandlerFunc) {
+	s.addRoute(MethodBYE, handler...)
+}
+
+// Ack INVITE 应答的确认，ACK 不需要回复，注册后避免被当作未知方法回复 405
+func (s *Server) Ack(handler ...HandlerFunc) {
+	s.addRoute(MethodACK, handler...)
+}
+
 func (s *Server) getTX(key string) *Transaction {
 	return s.txs.getTX(key)
 }
@@ -86,15 +111,19 @@ func (s *Server) mustTX(msg *Request) *Transaction {
 	tx := s.txs.getTX(key)
 
 	if tx == nil {
-		if msg.conn.Network() == "udp" {
-			tx = s.txs.newTX(key, s.udpConn)
-		} else {
-			tx = s.txs.newTX(key, msg.conn)
-		}
+		tx = s.txs.newTX(key, s.txConn(msg.conn))
 	}
 	return tx
 }
 
+// txConn 选择事务使用的连接，TCP 请求必须经原连接应答，其余走 UDP 监听连接
+func (s *Server) txConn(conn Connection) Connection {
+	if conn != nil && conn.Network() == "tcp" {
+		return conn
+	}
+	return s.udpConn
+}
+
 func (s *Server) UDPConn() Connection {
 	return s.udpConn
 }
@@ -162,8 +191,13 @@ func (s *Server) ListenTCPServer(addr string) {
 	// defer tcp.Close()
 	// 保存 TCP 监听器到服务器结构体
 	s.tcpListener = tcp
-	// 无限循环接受连接
 
+	// 所有 TCP 连接共用一个解析器，报文携带各自连接，应答时原路返回
+	parser := newParser()
+	defer parser.stop()
+	go s.handlerListen(parser.out)
+
+	// 无限循环接受连接
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -175,7 +209,7 @@ func (s *Server) ListenTCPServer(addr string) {
 				slog.Error("net.ListenTCP", "err", err, "addr", addr)
 				return
 			}
-			go s.ProcessTcpConn(conn)
+			go s.ProcessTcpConn(conn, parser.in)
 		}
 	}
 }
@@ -195,53 +229,89 @@ func (s *Server) Close() {
 	}
 }
 
-// ProcessTcpConn 处理传入的 TCP 连接。
-func (s *Server) ProcessTcpConn(conn net.Conn) {
+// ProcessTcpConn 处理传入的 TCP 连接，按 Content-Length 切分报文后交给解析器
+func (s *Server) ProcessTcpConn(conn net.Conn, out chan<- Packet) {
 	defer conn.Close()
 	reader := bufio.NewReader(conn)
 	c := NewTCPConnection(conn)
 
-	parser := newParser()
-	defer parser.stop()
-	go s.handlerListen(parser.out)
+	for {
+		data, err := readTCPMessage(reader)
+		if err != nil {
+			if err != io.EOF {
+				slog.Error("tcp conn read message", "err", err, "remote", conn.RemoteAddr())
+			}
+			return
+		}
+		select {
+		case out <- newPacket(data, conn.RemoteAddr(), c):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
 
+// readTCPMessage 从 TCP 流中读取一个完整的 SIP 报文
+// 报文之间的空行 (RFC 5626 CRLF 保活) 会被跳过，消息体长度以 Content-Length 为准
+func readTCPMessage(reader *bufio.Reader) ([]byte, error) {
+	var buffer bytes.Buffer
+	bodyLen := 0
 	for {
-		var buffer bytes.Buffer
-		bodyLen := 0
-		for {
-			// 读取一行数据，以 '\n' 为结束符
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				// logrus.Debugln("tcp conn read error:", err)
-				return
+		line, err := readTCPLine(reader, maxTCPHeaderSize-buffer.Len())
+		if err != nil {
+			if buffer.Len() > 0 && err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
 			}
-			buffer.Write(line)
-			if len(line) <= 2 {
-				if bodyLen <= 0 {
-					break
-				}
-
-				bodyBuf := make([]byte, bodyLen)
-				n, err := io.ReadFull(reader, bodyBuf)
-				if err != nil || n != bodyLen {
-					slog.Error(`error while read full`, "err", err)
-				}
-				buffer.Write(bodyBuf)
-				break
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			// 起始行之前的空行为保活报文
+			if buffer.Len() == 0 {
+				continue
 			}
+			buffer.Write(line)
+			break
+		}
+		buffer.Write(line)
 
-			if strings.Contains(string(line), "Content-Length") {
-				s := strings.Split(string(line), ":")
-				value := strings.Trim(s[len(s)-1], " \r\n")
-				bodyLen, err = strconv.Atoi(value)
-				if err != nil {
-					slog.Error("parse Content-Length failed")
-					break
-				}
+		name, value, ok := strings.Cut(string(line), ":")
+		if !ok {
+			continue
+		}
+		if name = strings.TrimSpace(name); strings.EqualFold(name, "Content-Length") || strings.EqualFold(name, "l") {
+			bodyLen, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || bodyLen < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", strings.TrimSpace(value))
+			}
+			if bodyLen > maxTCPBodySize {
+				return nil, fmt.Errorf("Content-Length %d exceeds limit %d", bodyLen, maxTCPBodySize)
 			}
 		}
+	}
 
-		parser.in <- newPacket(buffer.Bytes(), conn.RemoteAddr(), c)
+	if bodyLen > 0 {
+		body := make([]byte, bodyLen)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, fmt.Errorf("read body: %w", err)
+		}
+		buffer.Write(body)
+	}
+	return buffer.Bytes(), nil
+}
+
+// readTCPLine 读取一行，累计超过 limit 字节仍未遇到换行时返回错误
+func readTCPLine(reader *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := reader.ReadSlice('\n')
+		if len(line)+len(frag) > limit {
+			return nil, fmt.Errorf("header exceeds limit %d", maxTCPHeaderSize)
+		}
+		line = append(line, frag...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
 	}
 }
 
@@ -273,6 +343,7 @@ func (s *Server) handlerListen(msgs chan Message) {
 }
 
 func (s *Server) handlerRequest(msg *Request) {
+	s.logMessage("recv", msg)
 	tx := s.mustTX(msg)
 	// logrus.Traceln("receive request from:", msg.Source(), ",method:", msg.Method(), "txKey:", tx.key, "message: \n", msg.String())
 
@@ -294,7 +365,7 @@ func (s *Server) handlerRequest(msg *Request) {
 	handlers, ok := s.route.Load(strings.ToUpper(key))
 	if !ok {
 		slog.Debug("not found handler func", "method", msg.Method(), "msg", msg.String())
-		go handlerMethodNotAllowed(msg, tx)
+		go s.handlerMethodNotAllowed(msg, tx)
 		return
 	}
 
@@ -306,6 +377,7 @@ func (s *Server) handlerRequest(msg *Request) {
 }
 
 func (s *Server) handlerResponse(msg *Response) {
+	s.logMessage("recv", msg)
 	tx := s.getTX(getTXKey(msg))
 	if tx == nil {
 		// logrus.Infoln("not found tx. receive response from:", msg.Source(), "message: \n", msg.String())
@@ -323,6 +395,11 @@ func (s *Server) Request(req *Request) (*Transaction, error) {
 	}
 	viaHop.Host = s.host.String()
 	viaHop.Port = s.port
+	// 设备通过 TCP 注册时，信令经原连接发送，Via 需声明 TCP 传输
+	if conn := req.conn; conn != nil && conn.Network() == "tcp" {
+		viaHop.Transport = "TCP"
+		viaHop.Port = s.tcpPort
+	}
 	if viaHop.Params == nil {
 		viaHop.Params = NewParams().Add("branch", String{Str: GenerateBranch()})
 	}
@@ -330,11 +407,19 @@ func (s *Server) Request(req *Request) (*Transaction, error) {
 		viaHop.Params.Add("rport", nil)
 	}
 
+	s.logMessage("send", req)
 	tx := s.mustTX(req)
 	return tx, tx.Request(req)
 }
 
-func handlerMethodNotAllowed(req *Request, tx *Transaction) {
+// SendAck 在原事务上发送 ACK，经过报文日志与设备跟踪
+func (s *Server) SendAck(tx *Transaction, req *Request) error {
+	s.logMessage("send", req)
+	return tx.Request(req)
+}
+
+func (s *Server) handlerMethodNotAllowed(req *Request, tx *Transaction) {
 	resp := NewResponseFromRequest("", req, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), []byte{})
-	tx.Respond(resp)
+	s.logMessage("send", resp)
+	_ = tx.Respond(resp)
 }
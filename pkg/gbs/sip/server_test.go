@@ -0,0 +1,144 @@
+package sip
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTCPMessage(t *testing.T) {
+	const (
+		msg1 = "MESSAGE sip:34020000002000000001@3402000000 SIP/2.0\r\n" +
+			"Via: SIP/2.0/TCP 192.168.1.2:5060;branch=z9hG4bK1\r\n" +
+			"l: 5\r\n" +
+			"\r\n" +
+			"hello"
+		msg2 = "REGISTER sip:34020000002000000001@3402000000 SIP/2.0\r\n" +
+			"content-length: 0\r\n" +
+			"\r\n"
+	)
+	// 两条报文之间夹带 CRLF 保活，且在同一 TCP 流中连续到达
+	reader := bufio.NewReader(strings.NewReader("\r\n\r\n" + msg1 + "\r\n" + msg2))
+
+	got, err := readTCPMessage(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != msg1 {
+		t.Fatalf("expect %q, got %q", msg1, got)
+	}
+
+	got, err = readTCPMessage(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != msg2 {
+		t.Fatalf("expect %q, got %q", msg2, got)
+	}
+
+	if _, err := readTCPMessage(reader); err != io.EOF {
+		t.Fatalf("expect io.EOF, got %v", err)
+	}
+}
+
+func TestReadTCPMessageTruncated(t *testing.T) {
+	const truncated = "MESSAGE sip:a@b SIP/2.0\r\nContent-Length: 10\r\n\r\nabc"
+	if _, err := readTCPMessage(bufio.NewReader(strings.NewReader(truncated))); err == nil {
+		t.Fatal("expect error for truncated body")
+	}
+	const invalid = "MESSAGE sip:a@b SIP/2.0\r\nContent-Length: x\r\n\r\n"
+	if _, err := readTCPMessage(bufio.NewReader(strings.NewReader(invalid))); err == nil {
+		t.Fatal("expect error for invalid Content-Length")
+	}
+}
+
+func TestReadTCPMessageOversized(t *testing.T) {
+	const hugeBody = "MESSAGE sip:a@b SIP/2.0\r\nContent-Length: 2000000000\r\n\r\n"
+	if _, err := readTCPMessage(bufio.NewReader(strings.NewReader(hugeBody))); err == nil {
+		t.Fatal("expect error for oversized Content-Length")
+	}
+
+	// 头部无换行持续写入，不能无限累积
+	hugeHeader := "MESSAGE sip:a@b SIP/2.0\r\nX-Pad: " + strings.Repeat("a", maxTCPHeaderSize) + "\r\n\r\n"
+	if _, err := readTCPMessage(bufio.NewReader(strings.NewReader(hugeHeader))); err == nil {
+		t.Fatal("expect error for oversized header")
+	}
+
+	// 上限以内的长头部仍能正常读取
+	long := "MESSAGE sip:a@b SIP/2.0\r\nX-Pad: " + strings.Repeat("a", 8192) + "\r\nContent-Length: 0\r\n\r\n"
+	got, err := readTCPMessage(bufio.NewReader(strings.NewReader(long)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != long {
+		t.Fatal("long header mismatch")
+	}
+}
+
+func TestRequestOverTCPRoutesToSameConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	serverSide, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSide.Close()
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	s := NewServer(&Address{})
+	s.udpConn = NewUDPConnection(udp)
+	s.host = net.IPv4(127, 0, 0, 1)
+	s.port = NewPort(5060)
+	s.tcpPort = NewPort(5061)
+
+	tcpConn := NewTCPConnection(serverSide)
+	if got := s.txConn(tcpConn); got != tcpConn {
+		t.Fatal("tcp request should use the incoming tcp connection")
+	}
+	if got := s.txConn(nil); got != s.udpConn {
+		t.Fatal("request without connection should use udp connection")
+	}
+
+	uri, err := ParseSipURI("sip:34020000001320000001@127.0.0.1:5060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := &Address{URI: &uri, Params: NewParams()}
+	hb := NewHeaderBuilder().SetTo(addr).SetFrom(addr).SetMethod(MethodMessage).AddVia(&ViaHop{
+		Params: NewParams().Add("branch", String{Str: GenerateBranch()}),
+	})
+	req := NewRequest("", MethodMessage, &uri, DefaultSipVersion, hb.Build(), nil)
+	req.SetConnection(tcpConn)
+	req.SetDestination(client.LocalAddr())
+
+	if _, err := s.Request(req); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	data, err := readTCPMessage(bufio.NewReader(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("SIP/2.0/TCP 127.0.0.1:5061")) {
+		t.Fatalf("via should declare tcp transport, got:\n%s", data)
+	}
+}
@@ -0,0 +1,89 @@
+package sip
+
+import (
+	"sync"
+	"time"
+)
+
+// traceBufferSize 单个订阅者缓存的报文数，订阅者读取不及时时丢弃新报文，不阻塞信令处理
+const traceBufferSize = 256
+
+// TraceMessage 跟踪到的原始 SIP 报文
+type TraceMessage struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // recv/send
+	DeviceID  string    `json:"device_id"`
+	Raw       string    `json:"raw"`
+}
+
+// messageTracer 按设备跟踪 SIP 报文，只有被跟踪的设备才复制报文
+type messageTracer struct {
+	m    sync.RWMutex
+	subs map[string]map[*traceSub]struct{}
+}
+
+type traceSub struct {
+	ch   chan TraceMessage
+	once sync.Once
+}
+
+// subscribe 订阅设备报文，返回的 cancel 可重复调用
+func (t *messageTracer) subscribe(deviceID string) (*traceSub, func()) {
+	sub := traceSub{ch: make(chan TraceMessage, traceBufferSize)}
+	t.m.Lock()
+	if t.subs == nil {
+		t.subs = make(map[string]map[*traceSub]struct{})
+	}
+	if t.subs[deviceID] == nil {
+		t.subs[deviceID] = make(map[*traceSub]struct{})
+	}
+	t.subs[deviceID][&sub] = struct{}{}
+	t.m.Unlock()
+
+	return &sub, func() {
+		sub.once.Do(func() {
+			t.m.Lock()
+			defer t.m.Unlock()
+			delete(t.subs[deviceID], &sub)
+			if len(t.subs[deviceID]) == 0 {
+				delete(t.subs, deviceID)
+			}
+			close(sub.ch)
+		})
+	}
+}
+
+// tracing 设备是否正在被跟踪
+func (t *messageTracer) tracing(deviceID string) bool {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	return len(t.subs[deviceID]) > 0
+}
+
+// tee 将报文复制给跟踪该设备的订阅者，未被跟踪的设备不序列化报文
+func (t *messageTracer) tee(deviceID, direction string, msg Message) {
+	if deviceID == "" || !t.tracing(deviceID) {
+		return
+	}
+	out := TraceMessage{Time: time.Now(), Direction: direction, DeviceID: deviceID, Raw: msg.String()}
+
+	t.m.RLock()
+	defer t.m.RUnlock()
+	for sub := range t.subs[deviceID] {
+		select {
+		case sub.ch <- out:
+		default:
+		}
+	}
+}
+
+// TraceDevice 跟踪设备收发的 SIP 报文，到达 duration 后自动停止并关闭通道
+// 调用方提前结束时应调用 cancel 释放订阅
+func (s *Server) TraceDevice(deviceID string, duration time.Duration) (<-chan TraceMessage, func()) {
+	sub, cancel := s.tracer.subscribe(deviceID)
+	timer := time.AfterFunc(duration, cancel)
+	return sub.ch, func() {
+		timer.Stop()
+		cancel()
+	}
+}
@@ -0,0 +1,118 @@
+package sip
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// newTraceRequest 构造设备发往平台的请求
+func newTraceRequest(t *testing.T, deviceID string) *Request {
+	t.Helper()
+	from, err := ParseSipURI("sip:" + deviceID + "@127.0.0.1:5060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := ParseSipURI("sip:34020000002000000001@127.0.0.1:15060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb := NewHeaderBuilder().
+		SetFrom(&Address{URI: &from, Params: NewParams()}).
+		SetToWithParam(&Address{URI: &to, Params: NewParams()}).
+		SetMethod(MethodMessage).
+		AddVia(&ViaHop{Params: NewParams().Add("branch", String{Str: GenerateBranch()})})
+	return NewRequest("", MethodMessage, &to, DefaultSipVersion, hb.Build(), nil)
+}
+
+func TestTraceDeviceGate(t *testing.T) {
+	const traced, other = "34020000001320000001", "34020000001320000002"
+	var s Server
+
+	msgs, cancel := s.TraceDevice(traced, time.Minute)
+	defer cancel()
+
+	s.logMessage("recv", newTraceRequest(t, other))
+	req := newTraceRequest(t, traced)
+	s.logMessage("recv", req)
+	// 平台对设备请求的应答同样属于该设备
+	resp := NewResponse("", DefaultSipVersion, 200, "OK", []Header{}, []byte{})
+	CopyHeaders("From", req, resp)
+	CopyHeaders("To", req, resp)
+	s.logMessage("send", resp)
+
+	for _, direction := range []string{"recv", "send"} {
+		select {
+		case msg := <-msgs:
+			if msg.DeviceID != traced || msg.Direction != direction || msg.Raw == "" {
+				t.Fatalf("unexpected trace message %+v", msg)
+			}
+		default:
+			t.Fatalf("expect %s message of traced device", direction)
+		}
+	}
+	select {
+	case msg := <-msgs:
+		t.Fatalf("untraced device should not be teed, got %+v", msg)
+	default:
+	}
+
+	cancel()
+	if s.tracer.tracing(traced) {
+		t.Fatal("expect trace disabled after cancel")
+	}
+}
+
+func TestTraceDeviceExpire(t *testing.T) {
+	const deviceID = "34020000001320000001"
+	var s Server
+
+	msgs, cancel := s.TraceDevice(deviceID, 20*time.Millisecond)
+	defer cancel()
+
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Fatal("expect no message before expire")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trace should stop after duration")
+	}
+	if s.tracer.tracing(deviceID) {
+		t.Fatal("expect trace disabled after duration")
+	}
+	s.logMessage("recv", newTraceRequest(t, deviceID))
+}
+
+func TestTraceMethodNotAllowed(t *testing.T) {
+	const deviceID = "34020000001320000001"
+	device, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	var s Server
+	msgs, cancel := s.TraceDevice(deviceID, time.Minute)
+	defer cancel()
+
+	req := newTraceRequest(t, deviceID)
+	req.SetSource(device.LocalAddr())
+	tx := NewTransaction("405", NewUDPConnection(udp))
+
+	// 未注册方法的 405 应答同样经过设备跟踪
+	s.handlerMethodNotAllowed(req, tx)
+	select {
+	case msg := <-msgs:
+		if msg.Direction != "send" || msg.DeviceID != deviceID {
+			t.Fatalf("unexpected trace message %+v", msg)
+		}
+	default:
+		t.Fatal("expect 405 response traced")
+	}
+}
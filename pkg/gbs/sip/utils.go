@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -164,31 +165,43 @@ func GetRequest(url string) ([]byte, error) {
 
 // XMLDecode 解码 xml
 func XMLDecode(data []byte, v any) error {
-	decoder := xml.NewDecoder(bytes.NewReader(data))
-	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
-		if utf8.Valid(data) {
-			return input, nil
-		}
-		return simplifiedchinese.GB18030.NewDecoder().Reader(input), nil
+	decoder := xml.NewDecoder(bytes.NewReader(normalizeXML(data)))
+	// 正文已转为 UTF-8，忽略声明的编码，部分设备声明 GB2312 实际发送 UTF-8，或反之
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder.Decode(v)
+}
+
+// normalizeXML 去除 BOM 与首部空白，非 UTF-8 的正文按 GB18030 转码（兼容 GB2312/GBK）
+func normalizeXML(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if utf8.Valid(data) {
+		return data
 	}
-	if err := decoder.Decode(v); err == nil {
-		return nil
+	out, _, err := transform.Bytes(simplifiedchinese.GB18030.NewDecoder(), data)
+	if err != nil {
+		return data
 	}
-	value := string(data)
-	value = strings.Replace(value, `<?xml version="1.0"?>`, `<?xml version="1.0" encoding="GB2312"?>`, 1)
-	value = strings.Replace(value, `UTF-8`, `GB2312`, 1)
-	return xmlDecode([]byte(value), v)
+	return out
 }
 
-func xmlDecode(data []byte, v any) error {
-	decoder := xml.NewDecoder(bytes.NewReader(data))
-	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
-		if utf8.Valid(data) {
-			return input, nil
-		}
-		return simplifiedchinese.GB18030.NewDecoder().Reader(input), nil
+// XMLInt 宽松的整数字段，部分厂商填写空白、非数字等不规范的值，解析失败时为 0
+type XMLInt int
+
+// UnmarshalXML implements xml.Unmarshaler.
+func (i *XMLInt) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
 	}
-	return decoder.Decode(v)
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		n = 0
+	}
+	*i = XMLInt(n)
+	return nil
 }
 
 // XMLEncode XML编码器
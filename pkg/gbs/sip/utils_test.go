@@ -0,0 +1,76 @@
+package sip
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+type testCatalogItem struct {
+	DeviceID string `xml:"DeviceID"`
+	Name     string `xml:"Name"`
+	Parental XMLInt `xml:"Parental"`
+}
+
+type testCatalog struct {
+	XMLName  xml.Name          `xml:"Response"`
+	DeviceID string            `xml:"DeviceID"`
+	Item     []testCatalogItem `xml:"DeviceList>Item"`
+}
+
+func gbk(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestXMLDecodeCharset(t *testing.T) {
+	const body = `<Response><DeviceID>34020000001320000001</DeviceID><DeviceList><Item><DeviceID>34020000001310000001</DeviceID><Name>东门摄像头</Name></Item></DeviceList></Response>`
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "gb2312 declared", data: gbk(t, `<?xml version="1.0" encoding="GB2312"?>`+"\n"+body)},
+		{name: "utf-8 declared but gbk body", data: gbk(t, `<?xml version="1.0" encoding="utf-8"?>`+body)},
+		{name: "no declaration gbk body", data: gbk(t, `<?xml version="1.0"?>`+body)},
+		{name: "gb2312 declared but utf-8 body", data: []byte(`<?xml version="1.0" encoding="GB2312"?>` + body)},
+		{name: "bom and leading whitespace", data: []byte("\xef\xbb\xbf\r\n  <?xml version=\"1.0\" encoding=\"UTF-8\"?>" + body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg testCatalog
+			if err := XMLDecode(tt.data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if len(msg.Item) != 1 || msg.Item[0].Name != "东门摄像头" {
+				t.Fatalf("unexpected result %+v", msg)
+			}
+		})
+	}
+}
+
+func TestXMLInt(t *testing.T) {
+	const body = `<Response><DeviceList>
+		<Item><DeviceID>1</DeviceID><Parental> 1 </Parental></Item>
+		<Item><DeviceID>2</DeviceID><Parental></Parental></Item>
+		<Item><DeviceID>3</DeviceID><Parental>否</Parental></Item>
+		<Item><DeviceID>4</DeviceID></Item>
+	</DeviceList></Response>`
+	var msg testCatalog
+	if err := XMLDecode([]byte(body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := []XMLInt{1, 0, 0, 0}
+	if len(msg.Item) != len(want) {
+		t.Fatalf("expect %d items, got %d", len(want), len(msg.Item))
+	}
+	for i, v := range want {
+		if msg.Item[i].Parental != v {
+			t.Fatalf("item %d expect parental %d, got %d", i, v, msg.Item[i].Parental)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gbs
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SSRC 为 10 位十进制数，国标附录 G 的默认格式为：
+// 第 1 位 0 实时/1 历史，第 2~6 位取 SIP 域第 4~8 位，后 4 位为流序号
+// 级联的上级平台可能要求不同的前缀或排列，通过前缀与格式模板调整
+const (
+	defaultSSRCFormat = "{type}{prefix}{seq}"
+	ssrcLength        = 10
+	ssrcMinSeqDigits  = 3 // 流序号至少保留的位数，避免序号过快回绕
+	ssrcPrefixLength  = 5 // 默认前缀位数，即 SIP 域第 4~8 位
+)
+
+// ssrcLayout 校验通过的 SSRC 模板
+type ssrcLayout struct {
+	format    string
+	prefix    string
+	seqDigits int
+}
+
+// parseSSRCLayout 校验前缀与格式，格式为空使用默认格式
+// 格式只能包含数字与 {type}/{prefix}/{seq}，其中 {seq} 必须且只能出现一次，占满剩余位数
+func parseSSRCLayout(prefix, format string) (ssrcLayout, error) {
+	if format == "" {
+		format = defaultSSRCFormat
+	}
+	if !isDigits(prefix) {
+		return ssrcLayout{}, fmt.Errorf("SSRC 前缀 [%s] 只能包含数字", prefix)
+	}
+	if strings.Count(format, "{seq}") != 1 {
+		return ssrcLayout{}, fmt.Errorf("SSRC 格式 [%s] 须包含且仅包含一个 {seq}", format)
+	}
+	fixed := strings.NewReplacer("{type}", "0", "{prefix}", prefix, "{seq}", "").Replace(format)
+	if !isDigits(fixed) {
+		return ssrcLayout{}, fmt.Errorf("SSRC 格式 [%s] 只能包含数字与 {type}/{prefix}/{seq}", format)
+	}
+	l := ssrcLayout{format: format, prefix: prefix, seqDigits: ssrcLength - len(fixed)}
+	if l.seqDigits < ssrcMinSeqDigits {
+		return ssrcLayout{}, fmt.Errorf("SSRC 前缀与格式过长，流序号至少保留 %d 位", ssrcMinSeqDigits)
+	}
+	// 历史流的最大值也不能超出 32 位
+	if v, _ := strconv.ParseUint(l.ssrc(1, l.maxSeq()), 10, 64); v > math.MaxUint32 {
+		return ssrcLayout{}, fmt.Errorf("SSRC 格式 [%s] 前缀 [%s] 超出 32 位范围", format, prefix)
+	}
+	return l, nil
+}
+
+// ssrc 生成 SSRC，t 为 0 实时/1 历史
+func (l ssrcLayout) ssrc(t, seq int) string {
+	return strings.NewReplacer(
+		"{type}", strconv.Itoa(t),
+		"{prefix}", l.prefix,
+		"{seq}", fmt.Sprintf("%0*d", l.seqDigits, seq),
+	).Replace(l.format)
+}
+
+// maxSeq 流序号最大值，超过后从 1 开始
+func (l ssrcLayout) maxSeq() int {
+	return int(math.Pow10(l.seqDigits)) - 1
+}
+
+// ValidateSSRC 校验设备的 SSRC 前缀与格式，空值表示沿用全局配置
+func ValidateSSRC(prefix, format string) error {
+	if prefix == "" {
+		prefix = strings.Repeat("0", ssrcPrefixLength)
+	}
+	_, err := parseSSRCLayout(prefix, format)
+	return err
+}
+
+// ssrcLayout 按 设备 > 全局配置 > 默认 的优先级选择 SSRC 前缀与格式
+// 配置非法时记录日志并回退到默认格式，避免因配置错误导致无法点播
+func (g *GB28181API) ssrcLayout(devicePrefix, deviceFormat string) ssrcLayout {
+	prefix := cmp.Or(devicePrefix, g.cfg.SSRCPrefix, g.domainSSRCPrefix())
+	format := cmp.Or(deviceFormat, g.cfg.SSRCFormat)
+	l, err := parseSSRCLayout(prefix, format)
+	if err == nil {
+		return l
+	}
+	slog.Warn("SSRC 配置无效，已使用默认格式", "err", err, "prefix", prefix, "format", format)
+	l, err = parseSSRCLayout(g.domainSSRCPrefix(), "")
+	if err != nil {
+		l, _ = parseSSRCLayout(strings.Repeat("0", ssrcPrefixLength), "")
+	}
+	return l
+}
+
+// domainSSRCPrefix SIP 域第 4~8 位，域过短时为空
+func (g *GB28181API) domainSSRCPrefix() string {
+	if len(g.cfg.Domain) < 3+ssrcPrefixLength {
+		return ""
+	}
+	return g.cfg.Domain[3 : 3+ssrcPrefixLength]
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
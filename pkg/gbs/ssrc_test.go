@@ -0,0 +1,84 @@
+package gbs
+
+import (
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+)
+
+func TestParseSSRCLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		format string
+		t, seq int
+		expect string
+	}{
+		{name: "default", prefix: "00000", t: 0, seq: 1, expect: "0000000001"},
+		{name: "playback", prefix: "12345", t: 1, seq: 42, expect: "1123450042"},
+		{name: "long prefix", prefix: "123456", t: 0, seq: 7, expect: "0123456007"},
+		{name: "prefix first", prefix: "1234", format: "{prefix}{type}{seq}", t: 1, seq: 5, expect: "1234100005"},
+		{name: "fixed digits", prefix: "3401", format: "{type}{prefix}9{seq}", t: 0, seq: 12, expect: "0340190012"},
+		{name: "no type", prefix: "340100", format: "{prefix}{seq}", t: 1, seq: 3, expect: "3401000003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := parseSSRCLayout(tt.prefix, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := l.ssrc(tt.t, tt.seq); got != tt.expect {
+				t.Fatalf("expect %s, got %s", tt.expect, got)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ prefix, format string }{
+		{prefix: "34a01"}, // 前缀含非数字
+		{prefix: "12345", format: "{type}{prefix}"},          // 缺少 {seq}
+		{prefix: "12345", format: "{seq}{type}{seq}"},        // {seq} 重复
+		{prefix: "12345", format: "{type}-{prefix}{seq}"},    // 含非法字符
+		{prefix: "1234567"},                                  // 流序号不足 3 位
+		{prefix: "99999", format: "{prefix}{type}{seq}"},     // 超出 32 位
+		{prefix: "12345", format: "{type}{prefix}{seq}{ch}"}, // 未知占位符
+	} {
+		if _, err := parseSSRCLayout(tt.prefix, tt.format); err == nil {
+			t.Fatalf("expect invalid prefix[%s] format[%s]", tt.prefix, tt.format)
+		}
+	}
+}
+
+func TestGetSSRCPriority(t *testing.T) {
+	g := GB28181API{cfg: &conf.SIP{Domain: "3402000000"}}
+	StreamList.ssrc = 0
+
+	// 未配置时取 SIP 域第 4~8 位
+	if got := g.getSSRC(0, "", ""); got != "0200000001" {
+		t.Fatalf("expect domain prefix, got %s", got)
+	}
+	g.cfg.SSRCPrefix = "88888"
+	if got := g.getSSRC(1, "", ""); got != "1888880002" {
+		t.Fatalf("expect global prefix, got %s", got)
+	}
+	// 设备配置优先于全局配置
+	if got := g.getSSRC(0, "123456", "{prefix}{type}{seq}"); got != "1234560003" {
+		t.Fatalf("expect device layout, got %s", got)
+	}
+	// 设备配置非法时回退到默认格式
+	if got := g.getSSRC(0, "x", ""); got != "0200000004" {
+		t.Fatalf("expect fallback to domain prefix, got %s", got)
+	}
+
+	// 序号超过位数后从 1 开始
+	StreamList.ssrc = 999
+	if got := g.getSSRC(0, "123456", ""); got != "0123456001" {
+		t.Fatalf("expect seq wrap, got %s", got)
+	}
+
+	if err := ValidateSSRC("", "{prefix}{seq}"); err != nil {
+		t.Fatalf("expect empty prefix inherits global, got %v", err)
+	}
+	if err := ValidateSSRC("1234567", ""); err == nil {
+		t.Fatal("expect too long prefix rejected")
+	}
+}
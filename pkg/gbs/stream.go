@@ -1,7 +1,6 @@
 package gbs
 
 import (
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -49,6 +48,8 @@ type Streams struct {
 	ssrc string        // 国标ssrc 10进制字符串
 	Ext  int64         `json:"-" gorm:"-"` // 流等待过期时间
 	Resp *sip.Response `json:"-" gorm:"-"`
+
+	state int32 // 点播会话状态，仅通过 atomic 访问
 }
 
 // 当前系统中存在的流列表
@@ -56,26 +57,24 @@ type streamsList struct {
 	// key=ssrc value=PlayParams  播放对应的PlayParams 用来发送bye获取tag，callid等数据
 	Response *sync.Map
 	// key=channelid value={Play}  当前设备直播信息，防止重复直播
-	Succ *sync.Map
-	ssrc int
+	Succ  *sync.Map
+	ssrcM sync.Mutex
+	ssrc  int
 }
 
 var StreamList streamsList
 
-func (g *GB28181API) getSSRC(t int) string {
-	r := false
-	// for {
+// getSSRC 生成点播 SSRC，t 为 0 实时/1 历史，前缀与格式为空时使用全局配置
+func (g *GB28181API) getSSRC(t int, prefix, format string) string {
+	l := g.ssrcLayout(prefix, format)
+	StreamList.ssrcM.Lock()
+	defer StreamList.ssrcM.Unlock()
 	StreamList.ssrc++
-	// ssrc最大为四位数，超过时从1开始重新计算
-	if StreamList.ssrc > 9000 && !r {
-		StreamList.ssrc = 0
-		r = true
+	// 超过序号位数时从 1 开始重新计算
+	if StreamList.ssrc > l.maxSeq() {
+		StreamList.ssrc = 1
 	}
-	key := fmt.Sprintf("%d%s%04d", t, g.cfg.Domain[3:8], StreamList.ssrc)
-	// stream := Streams{StreamID: ssrc2stream(key), Stop: false}
-	// if err := db.Get(db.DBClient, &stream); db.RecordNotFound(err) || stream.CreatedAt == 0 {
-	return key
-	// }
+	return l.ssrc(t, StreamList.ssrc)
 }
 
 // 定时检查未关闭的流
@@ -0,0 +1,48 @@
+package gbs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 点播码流，INVITE 的 SDP 通过 a=streamnumber 指定
+// GB/T28181-2022 附录 G，0 主码流/1 子码流/2 第三码流，不支持的设备会忽略该属性
+const (
+	StreamQualityMain  = "main"
+	StreamQualitySub   = "sub"
+	StreamQualityThird = "third"
+)
+
+var streamQualities = []string{StreamQualityMain, StreamQualitySub, StreamQualityThird}
+
+// ParseStreamQuality 将 quality 转换为码流编号，reported 为设备上报的码流数量
+// 设备未上报(0)时不做限制，由设备自行处理
+func ParseStreamQuality(quality string, reported int) (int, error) {
+	number := -1
+	for i, q := range streamQualities {
+		if strings.EqualFold(quality, q) {
+			number = i
+			break
+		}
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("码流 [%s] 无效，可选 %s", quality, strings.Join(streamQualities, "/"))
+	}
+	if reported > 0 && number >= reported {
+		return 0, fmt.Errorf("设备仅支持 %d 路码流，不支持 [%s]", reported, quality)
+	}
+	return number, nil
+}
+
+// SetStreamNumber 记录通道点播使用的码流，返回是否与之前不同
+// 仅保存在内存中，重启后恢复主码流
+func (g *GB28181API) SetStreamNumber(channelID string, number int) bool {
+	prev, _ := g.streamNumbers.Swap(channelID, number)
+	return prev != number
+}
+
+// StreamNumber 通道点播使用的码流，未设置时为主码流
+func (g *GB28181API) StreamNumber(channelID string) int {
+	v, _ := g.streamNumbers.Load(channelID)
+	return v
+}
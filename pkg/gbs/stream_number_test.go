@@ -0,0 +1,74 @@
+package gbs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gowvp/owl/internal/conf"
+	"github.com/gowvp/owl/internal/core/ipc"
+	"github.com/gowvp/owl/pkg/gbs/sip"
+	"github.com/ixugo/goddd/pkg/conc"
+)
+
+func TestParseStreamQuality(t *testing.T) {
+	tests := []struct {
+		quality  string
+		reported int
+		number   int
+		ok       bool
+	}{
+		{quality: "main", number: 0, ok: true},
+		{quality: "SUB", number: 1, ok: true},
+		{quality: "third", number: 2, ok: true},
+		{quality: "sub", reported: 2, number: 1, ok: true},
+		{quality: "third", reported: 2},
+		{quality: "sub", reported: 1},
+		{quality: "hd"},
+	}
+	for _, tt := range tests {
+		number, err := ParseStreamQuality(tt.quality, tt.reported)
+		if (err == nil) != tt.ok || number != tt.number {
+			t.Fatalf("%s/%d: expect (%d, ok=%v), got (%d, %v)", tt.quality, tt.reported, tt.number, tt.ok, number, err)
+		}
+	}
+}
+
+func TestPlaySDPStreamNumber(t *testing.T) {
+	g := GB28181API{cfg: &conf.SIP{Domain: "3402000000"}, streamNumbers: &conc.Map[string, int]{}}
+	in := PlayInput{Channel: &ipc.Channel{ID: "g1"}, StreamMode: 1}
+
+	// 主码流保持原有 INVITE 内容
+	if body := string(g.playSDP("34020000001320000001", "127.0.0.1", 30000, &in)); strings.Contains(body, "streamnumber") {
+		t.Fatalf("expect no streamnumber for main stream:\n%s", body)
+	}
+
+	if !g.SetStreamNumber("g1", 1) || g.SetStreamNumber("g1", 1) {
+		t.Fatal("expect change reported only once")
+	}
+	in.StreamNumber = g.StreamNumber("g1")
+	body := string(g.playSDP("34020000001320000001", "127.0.0.1", 30000, &in))
+	if !strings.Contains(body, "a=streamnumber:1\r\n") {
+		t.Fatalf("expect sub stream requested:\n%s", body)
+	}
+}
+
+func TestParseVideoParamAttribute(t *testing.T) {
+	const body = `<?xml version="1.0" encoding="GB2312"?>
+<Response>
+<CmdType>ConfigDownload</CmdType>
+<SN>2</SN>
+<DeviceID>34020000001320000001</DeviceID>
+<Result>OK</Result>
+<VideoParamAttribute Num="2">
+<Item><StreamName>main</StreamName><VideoFormat>2</VideoFormat><Resolution>5</Resolution></Item>
+<Item><StreamName>sub</StreamName><VideoFormat>2</VideoFormat><Resolution>3</Resolution></Item>
+</VideoParamAttribute>
+</Response>`
+	var msg ConfigDownloadResponse
+	if err := sip.XMLDecode([]byte(body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.VideoParamAttribute == nil || msg.VideoParamAttribute.streams() != 2 {
+		t.Fatalf("expect 2 streams, got %+v", msg.VideoParamAttribute)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
@@ -61,3 +63,19 @@ func (e *Engine) get(ctx context.Context, path string, out any) error {
 	defer resp.Body.Close()
 	return json.NewDecoder(resp.Body).Decode(out)
 }
+
+// Ping 请求 lal_info 探测服务存活，只关心状态码，不解析响应
+func (e *Engine) Ping(ctx context.Context) error {
+	const api = `/api/stat/lal_info`
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, e.cfg.URL+api, nil)
+	resp, err := e.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lalmax: ping status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -3,9 +3,11 @@ package lalmax
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -13,6 +15,9 @@ const (
 	apiStatKeyFrame = "/api/stat/key_frame"
 )
 
+// ErrKeyFrameGenerating 服务端正在生成关键帧图片(429)，稍后重试即可
+var ErrKeyFrameGenerating = errors.New("lalmax: keyframe is being generated, please try again later")
+
 // SnapType 快照类型
 type SnapType string
 
@@ -68,13 +73,13 @@ func (e *Engine) getKeyFrame(ctx context.Context, streamName string, snapType Sn
 	}
 
 	// 构建请求 URL
-	url := fmt.Sprintf("%s%s?stream_name=%s", e.cfg.URL, apiStatKeyFrame, streamName)
+	link := fmt.Sprintf("%s%s?stream_name=%s", e.cfg.URL, apiStatKeyFrame, url.QueryEscape(streamName))
 	if snapType == SnapTypeImage {
-		url += "&type=image"
+		link += "&type=image"
 	}
 
 	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
 	if err != nil {
 		return nil, fmt.Errorf("lalmax: create request failed: %w", err)
 	}
@@ -105,7 +110,7 @@ func (e *Engine) getKeyFrame(ctx context.Context, streamName string, snapType Sn
 		}
 		return body, nil
 	case http.StatusTooManyRequests: // 429
-		return nil, fmt.Errorf("lalmax: keyframe is being generated, please try again later")
+		return nil, ErrKeyFrameGenerating
 	case http.StatusNotFound:
 		return nil, fmt.Errorf("lalmax: stream not found: %s", streamName)
 	default:
@@ -0,0 +1,99 @@
+package lalmax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const (
+	apiStatGroup    = "/api/stat/group"
+	apiStatAllGroup = "/api/stat/all_group"
+)
+
+// statCodeGroupNotFound lal 统计接口中流不存在的错误码
+const statCodeGroupNotFound = 1001
+
+// ErrGroupNotFound 流不存在或未在推流
+var ErrGroupNotFound = errors.New("lalmax: group not found")
+
+// StatGroup 流分组信息，codec 为空表示该轨道不存在或尚未解析
+type StatGroup struct {
+	StreamName  string `json:"stream_name"`
+	AppName     string `json:"app_name"`
+	AudioCodec  string `json:"audio_codec"` // 例如 AAC
+	VideoCodec  string `json:"video_codec"` // 例如 H264/H265
+	VideoWidth  int    `json:"video_width"`
+	VideoHeight int    `json:"video_height"`
+
+	Pub           StatPub        `json:"pub"`              // 推流/拉流输入会话
+	Subs          []StatSub      `json:"subs"`             // 播放会话
+	InFramePerSec []RecordPerSec `json:"in_frame_per_sec"` // 最近若干秒的输入视频帧数
+}
+
+// StatPub 输入会话统计，码率单位为 kbit/s
+type StatPub struct {
+	Protocol         string `json:"protocol"`
+	StartTime        string `json:"start_time"`
+	RemoteAddr       string `json:"remote_addr"`
+	ReadBytesSum     int64  `json:"read_bytes_sum"`
+	BitrateKbits     int    `json:"bitrate_kbits"`
+	ReadBitrateKbits int    `json:"read_bitrate_kbits"`
+}
+
+// StatSub 播放会话统计
+type StatSub struct {
+	Protocol   string `json:"protocol"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// RecordPerSec 每秒采样值
+type RecordPerSec struct {
+	UnixSec int64  `json:"unix_sec"`
+	V       uint32 `json:"v"`
+}
+
+type StatGroupResp struct {
+	ErrorCode int       `json:"error_code"`
+	Desp      string    `json:"desp"`
+	Data      StatGroup `json:"data"`
+}
+
+// GetStatGroup 查询指定流的分组信息，包括音视频编码
+func (e *Engine) GetStatGroup(ctx context.Context, streamName string) (*StatGroup, error) {
+	if streamName == "" {
+		return nil, fmt.Errorf("lalmax: stream_name is required")
+	}
+	var resp StatGroupResp
+	if err := e.get(ctx, apiStatGroup+"?stream_name="+url.QueryEscape(streamName), &resp); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode == statCodeGroupNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, streamName)
+	}
+	if resp.ErrorCode != 0 {
+		return nil, fmt.Errorf("lalmax: %d %s", resp.ErrorCode, resp.Desp)
+	}
+	return &resp.Data, nil
+}
+
+type StatAllGroupResp struct {
+	ErrorCode int    `json:"error_code"`
+	Desp      string `json:"desp"`
+	Data      struct {
+		Groups []StatGroup `json:"groups"`
+	} `json:"data"`
+}
+
+// GetStatAllGroup 查询所有在线流的分组信息
+func (e *Engine) GetStatAllGroup(ctx context.Context) ([]StatGroup, error) {
+	var resp StatAllGroupResp
+	if err := e.get(ctx, apiStatAllGroup, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != 0 {
+		return nil, fmt.Errorf("lalmax: %d %s", resp.ErrorCode, resp.Desp)
+	}
+	return resp.Data.Groups, nil
+}
@@ -0,0 +1,314 @@
+package ota
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MaxExtractSize 升级包解压后的总大小上限，防止压缩炸弹写满磁盘
+const MaxExtractSize = 2 << 30
+
+// ErrChecksumMismatch 升级包校验和不一致
+var ErrChecksumMismatch = errors.New("升级包校验和不一致")
+
+// VerifyChecksum 校验文件的 sha256，expect 支持 "sha256:<hex>" 或直接 hex，大小写不敏感
+func VerifyChecksum(file, expect string) error {
+	expect = strings.TrimSpace(expect)
+	expect = strings.TrimPrefix(strings.ToLower(expect), "sha256:")
+	if len(expect) != sha256.Size*2 {
+		return fmt.Errorf("校验和格式错误，需为 sha256")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != expect {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// LocalUpgrade 使用本地升级包执行 解压/备份/替换，不依赖网络
+// 用于无法访问外网的现场，由运维上传 Release 中的 tar.gz 升级包
+type LocalUpgrade struct {
+	tarPath  string
+	dir      string // 程序所在目录，可执行文件与 www 均在此目录下
+	execName string
+	topDir   string // 升级包顶层目录，解压时去除
+	err      error
+}
+
+// NewLocalUpgrade 创建本地升级
+// tarPath: 升级包路径；dir: 程序所在目录；execName: 可执行文件名，为空时取当前进程名
+func NewLocalUpgrade(tarPath, dir, execName string) *LocalUpgrade {
+	if execName == "" {
+		execName = filepath.Base(os.Args[0])
+	}
+	return &LocalUpgrade{tarPath: tarPath, dir: dir, execName: execName}
+}
+
+func (l *LocalUpgrade) upgradeDir() string {
+	return filepath.Join(l.dir, "upgrade")
+}
+
+// walk 依次读取升级包内的条目
+func (l *LocalUpgrade) walk(fn func(*tar.Header, *tar.Reader) error) error {
+	f, err := os.Open(l.tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("升级包不是 gzip 格式: %w", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("升级包不是 tar 格式: %w", err)
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// relative 去掉顶层目录后的相对路径，顶层目录本身返回空串
+func (l *LocalUpgrade) relative(name string) string {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	if l.topDir == "" {
+		return name
+	}
+	if name == l.topDir {
+		return ""
+	}
+	return strings.TrimPrefix(name, l.topDir+"/")
+}
+
+// Validate 校验升级包结构
+// 只允许普通文件与目录，路径不得越出解压目录，且必须包含可执行文件
+// 文件可以直接位于包根目录，也可以统一放在一个顶层目录下（Release 打包方式）
+func (l *LocalUpgrade) Validate() *LocalUpgrade {
+	if l.err != nil {
+		return l
+	}
+
+	var names []string
+	var total int64
+	err := l.walk(func(h *tar.Header, _ *tar.Reader) error {
+		name := path.Clean(h.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("升级包包含非法路径: %s", h.Name)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeReg:
+			total += h.Size
+			if total > MaxExtractSize {
+				return fmt.Errorf("升级包解压后超过 %d 字节", int64(MaxExtractSize))
+			}
+		default:
+			return fmt.Errorf("升级包包含不支持的文件类型: %s", h.Name)
+		}
+		names = append(names, strings.TrimPrefix(name, "./"))
+		return nil
+	})
+	if err != nil {
+		l.err = err
+		return l
+	}
+	if len(names) == 0 {
+		l.err = fmt.Errorf("升级包为空")
+		return l
+	}
+
+	// 所有条目都位于同一目录下时，视为顶层目录
+	top, _, _ := strings.Cut(names[0], "/")
+	for _, name := range names {
+		if name != top && !strings.HasPrefix(name, top+"/") {
+			top = ""
+			break
+		}
+	}
+	if top == l.execName {
+		top = ""
+	}
+	l.topDir = top
+
+	for _, name := range names {
+		if l.relative(name) == l.execName {
+			return l
+		}
+	}
+	l.err = fmt.Errorf("升级包缺少可执行文件 %s", l.execName)
+	return l
+}
+
+// Unzip 解压到程序目录下的 upgrade 目录，需先调用 Validate
+func (l *LocalUpgrade) Unzip() *LocalUpgrade {
+	if l.err != nil {
+		return l
+	}
+
+	upgradeDir := l.upgradeDir()
+	_ = os.RemoveAll(upgradeDir)
+	if err := os.MkdirAll(upgradeDir, 0o755); err != nil {
+		l.err = err
+		return l
+	}
+
+	l.err = l.walk(func(h *tar.Header, tr *tar.Reader) error {
+		rel := l.relative(h.Name)
+		if rel == "" || rel == "." {
+			return nil
+		}
+		target := filepath.Join(upgradeDir, filepath.FromSlash(rel))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			return os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			return writeFile(target, io.LimitReader(tr, h.Size), os.FileMode(h.Mode).Perm())
+		}
+		return nil
+	})
+	return l
+}
+
+// Backup 将当前可执行文件重命名为 .bak，升级失败时可手动恢复
+func (l *LocalUpgrade) Backup() *LocalUpgrade {
+	if l.err != nil {
+		return l
+	}
+
+	execPath := filepath.Join(l.dir, l.execName)
+	if _, err := os.Stat(execPath); errors.Is(err, os.ErrNotExist) {
+		return l
+	}
+	backupName := execPath + ".bak"
+	if err := os.RemoveAll(backupName); err != nil {
+		l.err = err
+		return l
+	}
+	if err := os.Rename(execPath, backupName); err != nil {
+		l.err = err
+	}
+	return l
+}
+
+// Replace 使用 upgrade 目录中的文件替换可执行文件与 www 目录
+func (l *LocalUpgrade) Replace() *LocalUpgrade {
+	if l.err != nil {
+		return l
+	}
+
+	upgradeDir := l.upgradeDir()
+	execPath := filepath.Join(l.dir, l.execName)
+	if err := copyFile(filepath.Join(upgradeDir, l.execName), execPath, 0o755); err != nil {
+		// 恢复备份，避免程序目录下没有可执行文件
+		_ = os.Rename(execPath+".bak", execPath)
+		l.err = fmt.Errorf("替换可执行文件失败: %w", err)
+		return l
+	}
+
+	newWww := filepath.Join(upgradeDir, "www")
+	if _, err := os.Stat(newWww); err != nil {
+		return l
+	}
+	currentWww := filepath.Join(l.dir, "www")
+	backupWww := currentWww + ".bak"
+	_ = os.RemoveAll(backupWww)
+	if _, err := os.Stat(currentWww); err == nil {
+		if err := os.Rename(currentWww, backupWww); err != nil {
+			l.err = fmt.Errorf("备份 www 目录失败: %w", err)
+			return l
+		}
+	}
+	if err := copyDir(newWww, currentWww); err != nil {
+		_ = os.RemoveAll(currentWww)
+		_ = os.Rename(backupWww, currentWww)
+		l.err = fmt.Errorf("替换 www 目录失败: %w", err)
+		return l
+	}
+	_ = os.RemoveAll(backupWww)
+	return l
+}
+
+// Error 返回错误
+func (l *LocalUpgrade) Error() error {
+	return l.err
+}
+
+func writeFile(dst string, r io.Reader, perm os.FileMode) error {
+	if perm == 0 {
+		perm = 0o644
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeFile(dst, f, perm); err != nil {
+		return err
+	}
+	// OpenFile 的权限受 umask 影响，且不会修改已有文件的权限
+	return os.Chmod(dst, perm)
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
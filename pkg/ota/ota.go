@@ -10,8 +10,12 @@ import (
 )
 
 const (
-	linuxPackage   = `/releases/latest/download/`
-	LastVersionURL = `https://api.github.com/repos/%s/releases/latest`
+	linuxPackage = `/releases/latest/download/`
+
+	// DefaultAPIURL 版本查询默认使用 GitHub API
+	DefaultAPIURL = `https://api.github.com`
+	// DefaultDownloadURL 升级包默认从 GitHub Release 下载
+	DefaultDownloadURL = `https://github.com`
 )
 
 // ReleaseInfo GitHub Release 信息
@@ -23,20 +27,51 @@ type ReleaseInfo struct {
 // OTA 提供版本检查和下载功能的结构体
 // OTA 只负责下载，不关心后续的解压、备份、替换等操作
 type OTA struct {
-	repoName   string
-	filename   string
-	err        error
-	onProgress func(current, total int64)
+	repoName    string
+	filename    string
+	apiURL      string
+	downloadURL string
+	err         error
+	onProgress  func(current, total int64)
+}
+
+// Option 可选配置
+type Option func(*OTA)
+
+// WithAPIURL 替换版本查询的 API 地址，用于无法访问 api.github.com 的网络，空串忽略
+// 镜像需与 GitHub API 路径兼容，即提供 /repos/{owner}/{repo}/releases/latest
+func WithAPIURL(base string) Option {
+	return func(o *OTA) {
+		if base != "" {
+			o.apiURL = strings.TrimRight(base, "/")
+		}
+	}
+}
+
+// WithDownloadMirror 替换升级包的下载地址，空串忽略
+// 镜像需与 GitHub 路径兼容，即提供 /{owner}/{repo}/releases/latest/download/{filename}
+func WithDownloadMirror(base string) Option {
+	return func(o *OTA) {
+		if base != "" {
+			o.downloadURL = strings.TrimRight(base, "/")
+		}
+	}
 }
 
 // NewOTA 创建 OTA 实例
 // repoName: GitHub 仓库名，如 "gowvp/owl"，也支持 "github.com/gowvp/owl" 格式
 // filename: 下载的文件名
-func NewOTA(repoName, filename string) *OTA {
-	return &OTA{
-		repoName: cleanRepoName(repoName),
-		filename: filename,
+func NewOTA(repoName, filename string, opts ...Option) *OTA {
+	o := OTA{
+		repoName:    cleanRepoName(repoName),
+		filename:    filename,
+		apiURL:      DefaultAPIURL,
+		downloadURL: DefaultDownloadURL,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
+	return &o
 }
 
 // SetProgressCallback 设置下载进度回调
@@ -45,10 +80,37 @@ func (o *OTA) SetProgressCallback(callback func(current, total int64)) *OTA {
 	return o
 }
 
-// GetLastVersion 从 GitHub API 获取最新版本信息
+// GetLastVersion 从 GitHub API（或其镜像）获取最新版本信息
 // 返回 tag_name, body(release notes), error
 func (o *OTA) GetLastVersion() (string, string, error) {
-	return GetLastVersion(o.repoName)
+	apiURL := o.getLastVersionLink()
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("请求失败，状态码: %d", resp.StatusCode)
+	}
+
+	var release ReleaseInfo
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", "", fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	return release.TagName, release.Body, nil
 }
 
 // Download 下载升级包到指定路径
@@ -70,10 +132,14 @@ func (o *OTA) Error() error {
 }
 
 // getDownloadLink 获取下载链接
+// 直接拼接而不用 url.JoinPath，以兼容 https://mirror/https://github.com 这类前缀式代理
 func (o *OTA) getDownloadLink() string {
-	repoLink := "https://github.com/" + o.repoName
-	link, _ := url.JoinPath(repoLink, linuxPackage, o.filename)
-	return link
+	return o.downloadURL + "/" + o.repoName + linuxPackage + url.PathEscape(o.filename)
+}
+
+// getLastVersionLink 获取最新版本查询链接
+func (o *OTA) getLastVersionLink() string {
+	return o.apiURL + "/repos/" + o.repoName + "/releases/latest"
 }
 
 // cleanRepoName 清理仓库名称，移除前缀
@@ -89,34 +155,6 @@ func cleanRepoName(repoName string) string {
 // GetLastVersion 从 GitHub API 获取最新版本信息
 // repoName: GitHub 仓库名，如 "gowvp/owl"
 // 返回 tag_name, body(release notes), error
-func GetLastVersion(repoName string) (string, string, error) {
-	repoName = cleanRepoName(repoName)
-	apiURL := fmt.Sprintf(LastVersionURL, repoName)
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
-	if err != nil {
-		return "", "", fmt.Errorf("创建请求失败: %w", err)
-	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("请求失败，状态码: %d", resp.StatusCode)
-	}
-
-	var release ReleaseInfo
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return "", "", fmt.Errorf("解析响应失败: %w", err)
-	}
-
-	return release.TagName, release.Body, nil
+func GetLastVersion(repoName string, opts ...Option) (string, string, error) {
+	return NewOTA(repoName, "", opts...).GetLastVersion()
 }
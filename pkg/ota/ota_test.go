@@ -1,6 +1,10 @@
 package ota
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestGetLastVersion(t *testing.T) {
 	version, desc, err := GetLastVersion("gowvp/owl")
@@ -10,3 +14,66 @@ func TestGetLastVersion(t *testing.T) {
 	t.Logf("version = %s", version)
 	t.Logf("desc = %s", desc)
 }
+
+func TestLinksWithMirror(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		download string
+		version  string
+	}{
+		{
+			name:     "default",
+			download: "https://github.com/gowvp/owl/releases/latest/download/linux_amd64",
+			version:  "https://api.github.com/repos/gowvp/owl/releases/latest",
+		},
+		{
+			name:     "host mirror",
+			opts:     []Option{WithDownloadMirror("https://mirror.example.com/github/"), WithAPIURL("https://api.mirror.example.com")},
+			download: "https://mirror.example.com/github/gowvp/owl/releases/latest/download/linux_amd64",
+			version:  "https://api.mirror.example.com/repos/gowvp/owl/releases/latest",
+		},
+		{
+			name:     "prefix proxy",
+			opts:     []Option{WithDownloadMirror("https://proxy.example.com/https://github.com")},
+			download: "https://proxy.example.com/https://github.com/gowvp/owl/releases/latest/download/linux_amd64",
+			version:  "https://api.github.com/repos/gowvp/owl/releases/latest",
+		},
+		{
+			name:     "empty mirror ignored",
+			opts:     []Option{WithDownloadMirror(""), WithAPIURL("")},
+			download: "https://github.com/gowvp/owl/releases/latest/download/linux_amd64",
+			version:  "https://api.github.com/repos/gowvp/owl/releases/latest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOTA("github.com/gowvp/owl", "linux_amd64", tt.opts...)
+			if got := o.getDownloadLink(); got != tt.download {
+				t.Fatalf("download link expect %s, got %s", tt.download, got)
+			}
+			if got := o.getLastVersionLink(); got != tt.version {
+				t.Fatalf("version link expect %s, got %s", tt.version, got)
+			}
+		})
+	}
+}
+
+func TestGetLastVersionMirror(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/fork/owl/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"tag_name":"v1.2.3","body":"notes"}`))
+	}))
+	defer svr.Close()
+
+	version, body, err := GetLastVersion("fork/owl", WithAPIURL(svr.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.2.3" || body != "notes" {
+		t.Fatalf("unexpected release %s %s", version, body)
+	}
+}
@@ -1,10 +1,15 @@
 package zlm
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 const (
 	getServerConfig = "/index/api/getServerConfig" // 获取配置
 	setServerConfig = "/index/api/setServerConfig" // 设置配置
+	getAPIList      = "/index/api/getApiList"      // 获取 API 列表
+	getVersion      = "/index/api/version"         // 获取版本信息
 )
 
 type FixedHeader struct {
@@ -360,6 +365,46 @@ func (e *Engine) GetServerConfig() (*GetServerConfigResponse, error) {
 	return &resp, nil
 }
 
+type GetAPIListResponse struct {
+	FixedHeader
+	Data []string `json:"data"`
+}
+
+// GetAPIList 获取 API 列表，响应体小且不涉及配置读取，适合做存活探测
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_1%E3%80%81-index-api-getapilist
+func (e *Engine) GetAPIList(ctx context.Context) (*GetAPIListResponse, error) {
+	var resp GetAPIListResponse
+	if err := e.postWithContext(ctx, getAPIList, nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type GetVersionResponse struct {
+	FixedHeader
+	Data struct {
+		BranchName string `json:"branchName"`
+		BuildTime  string `json:"buildTime"`
+		CommitHash string `json:"commitHash"`
+	} `json:"data"`
+}
+
+// GetVersion 获取版本信息，响应体小，可作为配置是否需要重新拉取的依据
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_31%E3%80%81-index-api-version
+func (e *Engine) GetVersion(ctx context.Context) (*GetVersionResponse, error) {
+	var resp GetVersionResponse
+	if err := e.postWithContext(ctx, getVersion, nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (e *Engine) SetServerConfig(in *SetServerConfigRequest) (*SetServerConfigReponse, error) {
 	req, err := struct2map(in)
 	if err != nil {
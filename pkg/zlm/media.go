@@ -0,0 +1,63 @@
+package zlm
+
+import "context"
+
+const getMediaList = "/index/api/getMediaList"
+
+// GetMediaListRequest 获取流列表，参数为空时不过滤
+type GetMediaListRequest struct {
+	Schema string `json:"schema,omitempty"` // 筛选协议，例如 rtsp 或 rtmp
+	Vhost  string `json:"vhost,omitempty"`  // 筛选虚拟主机，例如 __defaultVhost__
+	App    string `json:"app,omitempty"`    // 筛选应用名，例如 live
+	Stream string `json:"stream,omitempty"` // 筛选流 id，例如 livestream
+}
+
+// MediaTrack 流的音视频轨道
+type MediaTrack struct {
+	CodecID     int     `json:"codec_id"`      // H264 = 0, H265 = 1, AAC = 2, G711A = 3, G711U = 4
+	CodecIDName string  `json:"codec_id_name"` // 编码类型名称，例如 CodecH264
+	CodecType   int     `json:"codec_type"`    // Video = 0, Audio = 1
+	Ready       bool    `json:"ready"`         // 轨道是否准备就绪
+	Channels    int     `json:"channels,omitempty"`
+	SampleBit   int     `json:"sample_bit,omitempty"`
+	SampleRate  int     `json:"sample_rate,omitempty"`
+	Fps         float32 `json:"fps,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	Width       int     `json:"width,omitempty"`
+}
+
+// MediaInfo 流信息，同一个流的每种协议各有一条
+type MediaInfo struct {
+	App              string       `json:"app"`
+	Stream           string       `json:"stream"`
+	Schema           string       `json:"schema"`
+	Vhost            string       `json:"vhost"`
+	OriginType       int          `json:"originType"`
+	TotalReaderCount int          `json:"totalReaderCount"`
+	BytesSpeed       int          `json:"bytesSpeed"`  // 数据产生速度，单位 byte/s
+	AliveSecond      int64        `json:"aliveSecond"` // 存活时间，单位秒
+	Tracks           []MediaTrack `json:"tracks"`
+}
+
+type GetMediaListResponse struct {
+	FixedHeader
+	Data []MediaInfo `json:"data"`
+}
+
+// GetMediaList 获取流列表，可用于查询指定流的编码信息
+// getMediaInfo 在新版本中已被标记废弃，按 app+stream 筛选 getMediaList 可得到相同的轨道信息
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_3%E3%80%81-index-api-getmedialist
+func (e *Engine) GetMediaList(ctx context.Context, in GetMediaListRequest) (*GetMediaListResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp GetMediaListResponse
+	if err := e.postWithContext(ctx, getMediaList, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
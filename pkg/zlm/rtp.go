@@ -3,6 +3,8 @@ package zlm
 const (
 	openRtpServer  = `/index/api/openRtpServer`
 	closeRtpServer = `/index/api/closeRtpServer`
+	startSendRtp   = `/index/api/startSendRtp`
+	stopSendRtp    = `/index/api/stopSendRtp`
 )
 
 type OpenRTPServerResponse struct {
@@ -58,3 +60,60 @@ func (e *Engine) CloseRTPServer(in CloseRTPServerRequest) (*CloseRTPServerRespon
 	}
 	return &resp, nil
 }
+
+type StartSendRTPRequest struct {
+	Vhost   string `json:"vhost"`
+	App     string `json:"app"`
+	Stream  string `json:"stream"`
+	SSRC    string `json:"ssrc"`     // 推流的 rtp ssrc，取上级平台 INVITE 中的 y 字段
+	DstURL  string `json:"dst_url"`  // 目标 ip 或域名
+	DstPort int    `json:"dst_port"` // 目标端口
+	IsUDP   bool   `json:"is_udp"`   // 是否为 udp 模式，否则为 tcp 主动连接目标
+	SrcPort int    `json:"src_port"` // 使用的本机端口，0 或不传时默认为随机端口
+}
+
+type StartSendRTPResponse struct {
+	FixedHeader
+	LocalPort int `json:"local_port"` // 使用的本地端口号
+}
+
+// StartSendRTP 将流以 ps rtp 推送到上级平台，用于国标级联
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_27%E3%80%81-index-api-startsendrtp
+func (e *Engine) StartSendRTP(in StartSendRTPRequest) (*StartSendRTPResponse, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp StartSendRTPResponse
+	if err := e.post(startSendRtp, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type StopSendRTPRequest struct {
+	Vhost  string `json:"vhost"`
+	App    string `json:"app"`
+	Stream string `json:"stream"`
+	SSRC   string `json:"ssrc,omitempty"` // 为空时关闭该流的所有推流
+}
+
+// StopSendRTP 停止 GB28181 ps-rtp 推流
+// https://docs.zlmediakit.com/zh/guide/media_server/restful_api.html#_28%E3%80%81-index-api-stopsendrtp
+func (e *Engine) StopSendRTP(in StopSendRTPRequest) (*FixedHeader, error) {
+	body, err := struct2map(in)
+	if err != nil {
+		return nil, err
+	}
+	var resp FixedHeader
+	if err := e.post(stopSendRtp, body, &resp); err != nil {
+		return nil, err
+	}
+	if err := e.ErrHandle(resp.Code, resp.Msg); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -2,6 +2,7 @@ package zlm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -48,6 +49,11 @@ func (e Engine) SetConfig(cfg Config) Engine {
 }
 
 func (e *Engine) post(path string, data map[string]any, out any) error {
+	return e.postWithContext(context.Background(), path, data, out)
+}
+
+// postWithContext 支持通过 ctx 控制超时的 post
+func (e *Engine) postWithContext(ctx context.Context, path string, data map[string]any, out any) error {
 	bodyMap := make(map[string]any)
 	if e.cfg.Secret != "" {
 		bodyMap["secret"] = e.cfg.Secret
@@ -55,7 +61,12 @@ func (e *Engine) post(path string, data map[string]any, out any) error {
 	maps.Copy(bodyMap, data)
 	body, _ := json.Marshal(bodyMap)
 
-	resp, err := e.cli.Post(e.cfg.URL+path, "application/json", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.cli.Do(req)
 	if err != nil {
 		return err
 	}